@@ -4,8 +4,32 @@ import (
 	"github.com/Shopify/sarama"
 	"github.com/cloudhut/common/logging"
 	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/approval"
+	"github.com/cloudhut/kowl/backend/pkg/authentication"
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"github.com/cloudhut/kowl/backend/pkg/canary"
+	"github.com/cloudhut/kowl/backend/pkg/connect"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/dlq"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/fulltext"
+	"github.com/cloudhut/kowl/backend/pkg/headerindex"
 	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/ksql"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
 	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/principals"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"github.com/cloudhut/kowl/backend/pkg/rendering"
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"github.com/cloudhut/kowl/backend/pkg/savedfilters"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+	"github.com/cloudhut/kowl/backend/pkg/scim"
+	"github.com/cloudhut/kowl/backend/pkg/templates"
+	"github.com/cloudhut/kowl/backend/pkg/tlsreload"
+	"github.com/cloudhut/kowl/backend/pkg/topicmeta"
+	"github.com/cloudhut/kowl/backend/pkg/usage"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -15,10 +39,102 @@ import (
 type API struct {
 	Cfg *Config
 
-	Logger   *zap.Logger
+	Logger *zap.Logger
+
+	// KafkaSvc is nil if the fake cluster is enabled
 	KafkaSvc *kafka.Service
 	OwlSvc   *owl.Service
 
+	// FilterBudgets limits the cumulative CPU time of filter code per requester
+	FilterBudgets *filter.BudgetRegistry
+
+	// SchemaSvc is nil if the schema registry has not been configured
+	SchemaSvc *schema.Service
+
+	// ConnectSvc is nil if Kafka Connect has not been configured
+	ConnectSvc *connect.Service
+
+	// KsqlSvc is nil if ksqlDB has not been configured
+	KsqlSvc *ksql.Service
+
+	// Dependencies tracks the circuit breakers of the schema registries, Kafka Connect clusters and ksqlDB servers
+	Dependencies *resilience.Registry
+
+	// TemplatesSvc provides the admin defined consume templates
+	TemplatesSvc *templates.Service
+
+	// MaskingSvc creates the maskers which replace sensitive fields of consumed messages
+	MaskingSvc *masking.Service
+
+	// RenderingSvc creates the renderers which transform fields of consumed messages into a human readable form
+	RenderingSvc *rendering.Service
+
+	// DecryptionSvc decrypts keys and values of encrypted topics, it's nil if decryption is disabled
+	DecryptionSvc *decryption.Service
+
+	// SavedFiltersSvc is nil if saved filters are disabled
+	SavedFiltersSvc *savedfilters.Service
+
+	// TopicMetadataSvc holds the owners, tags and descriptions of the default cluster's topics, it's nil if topic
+	// metadata is disabled
+	TopicMetadataSvc *topicmeta.Service
+
+	// Authenticator resolves the user of each request by its bearer token, it's nil if authentication is disabled
+	Authenticator *authentication.Authenticator
+
+	// OIDCLogin logs users in with an OpenID Connect provider, it's nil unless the authentication type is oidc
+	OIDCLogin *authentication.OIDCLogin
+
+	// ApprovalSvc holds the topic operations which wait for an approval, it's nil if approvals are disabled
+	ApprovalSvc *approval.Service
+
+	// ScimDirectory holds the users and groups provisioned via SCIM, it's nil if SCIM is disabled
+	ScimDirectory *scim.Directory
+
+	// UsageTracker counts how often topics are browsed, searched and exported, it's nil if usage analytics are disabled
+	UsageTracker *usage.Tracker
+
+	// PrincipalResolver resolves Kafka principals to friendly names, it's nil if principal resolution is disabled
+	PrincipalResolver *principals.Resolver
+
+	// DLQResolver links dead letter queues to their source topics, it's nil if DLQ support is disabled
+	DLQResolver *dlq.Resolver
+
+	// HeaderIndexer indexes header values of the default cluster's topics, it's nil if the header index is disabled
+	HeaderIndexer *headerindex.Indexer
+
+	// FullTextIndexer indexes the records of selected topics of the default cluster for full-text searches, it's nil
+	// if the full-text index is disabled
+	FullTextIndexer *fulltext.Indexer
+
+	// Canary probes the default cluster's latency and availability, it's nil if the canary is disabled
+	Canary *canary.Canary
+
+	// Clusters are the additional Kafka clusters, the services above belong to the default cluster
+	Clusters []*Cluster
+
+	// clusterName is the name of the cluster which is served by this API instance
+	clusterName string
+
+	// selfEvents is nil if self events are disabled
+	selfEvents *selfEventEmitter
+
+	// auditLog is nil if the audit log is disabled
+	auditLog *auditLogger
+
+	// lagExporter collects the lags of the served cluster's consumer groups, it's nil if the lag exporter is disabled
+	lagExporter *lagExporter
+
+	// groupHistory records the state transitions and member changes of the served cluster's consumer groups, it's nil
+	// if the group history is disabled
+	groupHistory *groupHistoryTracker
+
+	// idempotencyKeys remembers the responses of mutating requests which carry an idempotency key
+	idempotencyKeys *idempotencyStore
+
+	// serverCert is nil unless the HTTP server serves TLS
+	serverCert *tlsreload.Certificate
+
 	Hooks *Hooks // Hooks to add additional functionality from the outside at different places (used by Kafka Owl Business)
 }
 
@@ -33,39 +149,294 @@ func New(cfg *Config) *API {
 	}
 	sarama.Logger = saramaLogger
 
-	// Sarama Config
-	saramaConfig, err := kafka.NewSaramaConfig(&cfg.Kafka)
+	kafkaSvc, kafkaCluster := newKafkaCluster(&cfg.Kafka, cfg.MetricsNamespace, logger)
+
+	// Proto Service
+	var protoSvc *proto.Service
+	if cfg.Proto.Enabled {
+		protoSvc, err = proto.NewService(cfg.Proto, logger)
+		if err != nil {
+			logger.Fatal("failed to create proto service", zap.Error(err))
+		}
+	}
+
+	// Schema Registry Service
+	dependencies := resilience.NewRegistry(resilience.NewMetrics(cfg.MetricsNamespace))
+	var schemaSvc *schema.Service
+	if cfg.SchemaRegistry.Enabled {
+		schemaSvc = schema.NewService(cfg.SchemaRegistry, dependencies, "schema_registry", logger)
+	}
+
+	// Kafka Connect Service
+	var connectSvc *connect.Service
+	if cfg.Connect.Enabled {
+		connectSvc = connect.NewService(cfg.Connect, dependencies, logger)
+	}
+
+	// ksqlDB Service
+	var ksqlSvc *ksql.Service
+	if cfg.Ksql.Enabled {
+		ksqlSvc = ksql.NewService(cfg.Ksql, dependencies, logger)
+	}
+
+	templatesSvc, err := templates.NewService(cfg.Templates)
 	if err != nil {
-		log.Fatal("failed to create a valid sarama config", zap.Error(err))
+		logger.Fatal("failed to create templates service", zap.Error(err))
 	}
 
-	// Sarama Client
-	logger.Info("connecting to Kafka cluster")
-	client, err := sarama.NewClient(cfg.Kafka.Brokers, saramaConfig)
+	maskingSvc, err := masking.NewService(cfg.Masking)
+	if err != nil {
+		logger.Fatal("failed to create masking service", zap.Error(err))
+	}
+
+	renderingSvc, err := rendering.NewService(cfg.Rendering)
 	if err != nil {
-		logger.Fatal("failed to create kafka client", zap.Error(err))
+		logger.Fatal("failed to create rendering service", zap.Error(err))
+	}
+
+	var decryptionSvc *decryption.Service
+	if cfg.Decryption.Enabled {
+		decryptionSvc, err = decryption.NewService(cfg.Decryption)
+		if err != nil {
+			logger.Fatal("failed to create decryption service", zap.Error(err))
+		}
+	}
+
+	var savedFiltersSvc *savedfilters.Service
+	if cfg.SavedFilters.Enabled {
+		savedFiltersSvc, err = savedfilters.NewService(cfg.SavedFilters)
+		if err != nil {
+			logger.Fatal("failed to create saved filters service", zap.Error(err))
+		}
 	}
 
-	kafkaSvc := &kafka.Service{Client: client, Logger: logger, MetricsNamespace: cfg.MetricsNamespace}
+	var topicMetadataSvc *topicmeta.Service
+	if cfg.TopicMetadata.Enabled {
+		topicMetadataSvc, err = topicmeta.NewService(cfg.TopicMetadata, kafkaCluster, logger)
+		if err != nil {
+			logger.Fatal("failed to create topic metadata service", zap.Error(err))
+		}
+	}
+
+	var scimDirectory *scim.Directory
+	if cfg.SCIM.Enabled {
+		scimDirectory, err = scim.NewDirectory(cfg.SCIM)
+		if err != nil {
+			logger.Fatal("failed to create scim directory", zap.Error(err))
+		}
+	}
 
+	var approvalSvc *approval.Service
+	if cfg.TopicApprovals.Enabled {
+		approvalSvc = approval.NewService(cfg.TopicApprovals, logger)
+	}
+
+	authenticator, err := authentication.NewAuthenticator(cfg.Authentication)
+	if err != nil {
+		logger.Fatal("failed to create authenticator", zap.Error(err))
+	}
+
+	hooks := newDefaultHooks()
+	if authorizer := authorization.NewAuthorizer(cfg.Authorization); authorizer != nil {
+		hooks.Owl = newAuthorizerHooks(authorizer, logger)
+	}
+
+	var usageTracker *usage.Tracker
+	if cfg.Usage.Enabled {
+		usageTracker, err = usage.NewTracker(cfg.Usage)
+		if err != nil {
+			logger.Fatal("failed to create usage tracker", zap.Error(err))
+		}
+	}
+
+	var principalResolver *principals.Resolver
+	if cfg.Principals.Enabled {
+		principalResolver, err = principals.NewResolver(cfg.Principals, logger)
+		if err != nil {
+			logger.Fatal("failed to create principal resolver", zap.Error(err))
+		}
+	}
+
+	var dlqResolver *dlq.Resolver
+	if cfg.DLQ.Enabled {
+		dlqResolver = dlq.NewResolver(cfg.DLQ)
+	}
+
+	var headerIndexer *headerindex.Indexer
+	if cfg.HeaderIndex.Enabled {
+		headerIndexer = headerindex.NewIndexer(cfg.HeaderIndex, kafkaCluster, logger)
+	}
+
+	var fullTextIndexer *fulltext.Indexer
+	if cfg.FullText.Enabled {
+		fullTextIndexer = fulltext.NewIndexer(cfg.FullText, kafkaCluster, protoSvc, logger)
+	}
+
+	var kafkaCanary *canary.Canary
+	if cfg.Canary.Enabled {
+		kafkaCanary, err = canary.NewCanary(cfg.Canary, kafkaCluster, cfg.MetricsNamespace, prometheus.DefaultRegisterer, logger)
+		if err != nil {
+			logger.Fatal("failed to create canary", zap.Error(err))
+		}
+	}
+
+	var selfEvents *selfEventEmitter
+	if cfg.SelfEvents.Enabled {
+		selfEvents = newSelfEventEmitter(cfg.SelfEvents, kafkaCluster, logger)
+	}
+
+	var serverCert *tlsreload.Certificate
+	if cfg.REST.TLS.Enabled {
+		tlsCfg := cfg.REST.TLS
+		serverCert, err = tlsreload.NewCertificate(tlsCfg.CertFilepath, tlsCfg.KeyFilepath, "", tlsCfg.ReloadInterval, logger)
+		if err != nil {
+			logger.Fatal("failed to load server certificate", zap.Error(err))
+		}
+	}
+
+	var auditLog *auditLogger
+	if cfg.AuditLog.Enabled {
+		auditLog, err = newAuditLogger(cfg.AuditLog, kafkaCluster, logger)
+		if err != nil {
+			logger.Fatal("failed to create audit log", zap.Error(err))
+		}
+	}
+
+	// The in-flight bytes of searches are bounded across all clusters
+	consumeLimits := owl.ConsumeLimits{
+		MaxConcurrentPartitions: cfg.Consumer.MaxConcurrentPartitions,
+		InFlightBytes:           kafka.NewByteBudget(cfg.Consumer.MaxInFlightBytes),
+	}
+
+	// Additional Kafka clusters
+	clusters := make([]*Cluster, len(cfg.Clusters))
+	for i, clusterCfg := range cfg.Clusters {
+		clusterLogger := logger.With(zap.String("cluster", clusterCfg.Name))
+		clusterNamespace := clusterMetricsNamespace(cfg.MetricsNamespace, clusterCfg.Name)
+		clusterKafkaSvc, clusterKafka := newKafkaCluster(&cfg.Clusters[i].Kafka, clusterNamespace, clusterLogger)
+
+		var clusterSchemaSvc *schema.Service
+		if clusterCfg.SchemaRegistry.Enabled {
+			clusterSchemaSvc = schema.NewService(clusterCfg.SchemaRegistry, dependencies, "schema_registry_"+clusterCfg.Name, clusterLogger)
+		}
+
+		clusterOwlSvc := owl.NewService(clusterKafka, protoSvc, clusterSchemaSvc, kafka.NewMessageMetrics(clusterNamespace), consumeLimits, clusterLogger)
+		clusters[i] = &Cluster{
+			Name:         clusterCfg.Name,
+			KafkaSvc:     clusterKafkaSvc,
+			OwlSvc:       clusterOwlSvc,
+			SchemaSvc:    clusterSchemaSvc,
+			lagExporter:  newLagExporterIfEnabled(cfg.LagExporter, clusterOwlSvc, clusterNamespace, clusterLogger),
+			groupHistory: newGroupHistoryTrackerIfEnabled(cfg.GroupHistory, clusterOwlSvc, clusterNamespace, clusterLogger),
+		}
+	}
+
+	owlSvc := owl.NewService(kafkaCluster, protoSvc, schemaSvc, kafka.NewMessageMetrics(cfg.MetricsNamespace), consumeLimits, logger)
 	return &API{
-		Cfg:      cfg,
-		Logger:   logger,
-		KafkaSvc: kafkaSvc,
-		OwlSvc:   owl.NewService(kafkaSvc, logger),
-		Hooks:    newDefaultHooks(),
+		Cfg:               cfg,
+		Logger:            logger,
+		KafkaSvc:          kafkaSvc,
+		OwlSvc:            owlSvc,
+		FilterBudgets:     filter.NewBudgetRegistry(cfg.Filter.MaxRequesterExecutionTime, cfg.Filter.RequesterBudgetWindow),
+		SchemaSvc:         schemaSvc,
+		ConnectSvc:        connectSvc,
+		KsqlSvc:           ksqlSvc,
+		Dependencies:      dependencies,
+		TemplatesSvc:      templatesSvc,
+		MaskingSvc:        maskingSvc,
+		RenderingSvc:      renderingSvc,
+		DecryptionSvc:     decryptionSvc,
+		SavedFiltersSvc:   savedFiltersSvc,
+		TopicMetadataSvc:  topicMetadataSvc,
+		Authenticator:     authenticator,
+		OIDCLogin:         authentication.NewOIDCLogin(cfg.Authentication),
+		ApprovalSvc:       approvalSvc,
+		ScimDirectory:     scimDirectory,
+		UsageTracker:      usageTracker,
+		PrincipalResolver: principalResolver,
+		DLQResolver:       dlqResolver,
+		HeaderIndexer:     headerIndexer,
+		FullTextIndexer:   fullTextIndexer,
+		Canary:            kafkaCanary,
+		Clusters:          clusters,
+		Hooks:             hooks,
+
+		clusterName:     cfg.ClusterName,
+		selfEvents:      selfEvents,
+		auditLog:        auditLog,
+		lagExporter:     newLagExporterIfEnabled(cfg.LagExporter, owlSvc, cfg.MetricsNamespace, logger),
+		groupHistory:    newGroupHistoryTrackerIfEnabled(cfg.GroupHistory, owlSvc, cfg.MetricsNamespace, logger),
+		idempotencyKeys: newIdempotencyStore(cfg.Idempotency),
+		serverCert:      serverCert,
 	}
 }
 
 // Start the API server and block
 func (api *API) Start() {
-	api.KafkaSvc.RegisterMetrics()
-	api.KafkaSvc.Start()
+	startKafkaService(api.KafkaSvc)
+	for _, cluster := range api.Clusters {
+		startKafkaService(cluster.KafkaSvc)
+	}
+	api.selfEvents.Start()
+	api.PrincipalResolver.Start()
+	api.HeaderIndexer.Start()
+	api.FullTextIndexer.Start()
+	api.TopicMetadataSvc.Start()
+	api.Canary.Start()
+	api.lagExporter.Start()
+	api.groupHistory.Start()
+	for _, cluster := range api.Clusters {
+		cluster.lagExporter.Start()
+		cluster.groupHistory.Start()
+	}
 
 	// Server
-	server := rest.NewServer(&api.Cfg.REST, api.Logger, api.routes())
-	err := server.Start()
+	server := rest.NewServer(&api.Cfg.REST.Config, api.Logger, api.routes())
+	var err error
+	if api.serverCert != nil {
+		err = startTLSServer(server, &api.Cfg.REST, api.serverCert)
+	} else {
+		err = server.Start()
+	}
 	if err != nil {
 		api.Logger.Fatal("REST Server returned an error", zap.Error(err))
 	}
 }
+
+// startKafkaService starts the keep alive and registers the metrics. Fake clusters have no Kafka service.
+func startKafkaService(kafkaSvc *kafka.Service) {
+	if kafkaSvc == nil {
+		return
+	}
+	kafkaSvc.RegisterMetrics()
+	kafkaSvc.Start()
+}
+
+// newKafkaCluster returns the in-memory fake cluster if it is enabled, otherwise it connects to the Kafka cluster.
+// The returned Kafka service is nil for fake clusters, the returned cluster caches metadata if the cache is enabled.
+func newKafkaCluster(cfg *kafka.Config, metricsNamespace string, logger *zap.Logger) (*kafka.Service, kafka.Cluster) {
+	var kafkaSvc *kafka.Service
+	var cluster kafka.Cluster
+	if cfg.Fake.Enabled {
+		cluster = kafka.NewFakeCluster(cfg.Fake, logger)
+	} else {
+		kafkaSvc = newKafkaService(cfg, metricsNamespace, logger)
+		cluster = kafkaSvc
+	}
+
+	if cfg.MetadataCache.Enabled {
+		cluster = kafka.NewCachedCluster(cluster, cfg.MetadataCache)
+	}
+	return kafkaSvc, cluster
+}
+
+// newKafkaService connects to the Kafka cluster and creates all clients which are needed to talk to it
+func newKafkaService(cfg *kafka.Config, metricsNamespace string, logger *zap.Logger) *kafka.Service {
+	logger.Info("connecting to Kafka cluster")
+	kafkaSvc, err := kafka.NewService(cfg, metricsNamespace, logger)
+	if err != nil {
+		logger.Fatal("failed to create kafka service", zap.Error(err))
+	}
+
+	return kafkaSvc
+}
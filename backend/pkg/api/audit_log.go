@@ -0,0 +1,341 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Audit results
+const (
+	auditResultSuccess = "success"
+	auditResultFailure = "failure"
+)
+
+// auditRedacted replaces the values of sensitive request body fields
+const auditRedacted = "[REDACTED]"
+
+// maxAuditErrorBytes bounds the response body which is kept to extract the error message of failed requests
+const maxAuditErrorBytes = 4096
+
+// auditSensitiveFields are substrings of body field names (at any depth) whose values are never recorded
+var auditSensitiveFields = []string{"password", "secret", "token", "credential"}
+
+// auditAction names a modifying route. Route is matched as suffix of the route pattern, so that the routes of
+// additional clusters are named alike.
+type auditAction struct {
+	method string
+	route  string
+	name   string
+
+	// redactedFields are top level body fields which are never recorded, e.g. the payloads of produced messages
+	redactedFields []string
+}
+
+var auditActions = []auditAction{
+	{http.MethodPost, "/topics/{topicName}/messages", "produceMessage", []string{"key", "value", "headers"}},
+	{http.MethodPost, "/topics", "createTopic", nil},
+	{http.MethodDelete, "/topics/{topicName}", "deleteTopic", nil},
+	{http.MethodPatch, "/topics/{topicName}/configuration", "alterTopicConfig", nil},
+	{http.MethodPatch, "/topics/{topicName}/partitions", "addPartitions", nil},
+	{http.MethodPost, "/topics/{topicName}/records/delete", "deleteRecords", nil},
+	{http.MethodPatch, "/brokers/{brokerID}/configuration", "alterBrokerConfig", nil},
+	{http.MethodPost, "/cluster/preferred-leader-election", "electPreferredLeaders", nil},
+	{http.MethodPatch, "/consumer-groups/{groupId}/offsets", "resetConsumerGroupOffsets", nil},
+	{http.MethodDelete, "/consumer-groups/{groupId}", "deleteConsumerGroup", nil},
+	{http.MethodPost, "/acls", "createACLs", nil},
+	{http.MethodDelete, "/acls", "deleteACLs", nil},
+	{http.MethodPost, "/scram-users", "upsertScramCredential", nil},
+	{http.MethodDelete, "/scram-users/{username}", "deleteScramCredential", nil},
+}
+
+// auditEntry is a single record of the audit log
+type auditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Instance  string    `json:"instance"`
+
+	// Action is the name of the operation, or method and route for operations without a name
+	Action        string   `json:"action"`
+	User          string   `json:"user"`
+	Groups        []string `json:"groups,omitempty"`
+	RemoteAddress string   `json:"remoteAddress"`
+
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Parameters map[string]string `json:"parameters,omitempty"` // Path parameters such as the topic name
+	Query      string            `json:"query,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+	// IsBodyOmitted is true if the body has not been recorded, because it's too large or not JSON
+	IsBodyOmitted bool `json:"isBodyOmitted,omitempty"`
+
+	Result     string `json:"result"`
+	Status     int    `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// auditLogger writes audit entries into a JSON file and/or produces them into a topic. Unlike self events, entries
+// are written synchronously and never sampled or dropped. Entries which can't be produced are logged as errors.
+type auditLogger struct {
+	cfg      AuditLogConfig
+	cluster  kafka.Cluster
+	file     *zap.Logger // nil if no file is configured
+	logger   *zap.Logger
+	instance string
+}
+
+func newAuditLogger(cfg AuditLogConfig, cluster kafka.Cluster, logger *zap.Logger) (*auditLogger, error) {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = "unknown"
+	}
+
+	a := &auditLogger{
+		cfg:      cfg,
+		cluster:  cluster,
+		logger:   logger.With(zap.String("source", "audit_log")),
+		instance: instance,
+	}
+	if cfg.File != "" {
+		sink, _, err := zap.Open(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log file: %w", err)
+		}
+		encoderCfg := zap.NewProductionEncoderConfig()
+		// Entries consist of their own fields only, the timestamp is the start of the request
+		encoderCfg.TimeKey = ""
+		encoderCfg.LevelKey = ""
+		encoderCfg.MessageKey = ""
+		encoderCfg.CallerKey = ""
+		a.file = zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(encoderCfg), sink, zap.InfoLevel))
+	}
+
+	return a, nil
+}
+
+// Record writes the entry to all configured sinks
+func (a *auditLogger) Record(entry auditEntry) {
+	entry.Instance = a.instance
+
+	if a.file != nil {
+		a.file.Info("audit",
+			zap.Time("timestamp", entry.Timestamp),
+			zap.String("instance", entry.Instance),
+			zap.String("action", entry.Action),
+			zap.String("user", entry.User),
+			zap.Strings("groups", entry.Groups),
+			zap.String("remoteAddress", entry.RemoteAddress),
+			zap.String("method", entry.Method),
+			zap.String("path", entry.Path),
+			zap.Reflect("parameters", entry.Parameters),
+			zap.String("query", entry.Query),
+			zap.Reflect("body", entry.Body),
+			zap.Bool("isBodyOmitted", entry.IsBodyOmitted),
+			zap.String("result", entry.Result),
+			zap.Int("status", entry.Status),
+			zap.String("error", entry.Error),
+			zap.Int64("durationMs", entry.DurationMs),
+		)
+	}
+
+	if a.cfg.Topic != "" {
+		if err := a.produce(entry); err != nil {
+			a.logger.Error("failed to produce audit log entry",
+				zap.String("topic", a.cfg.Topic),
+				zap.String("action", entry.Action),
+				zap.String("user", entry.User),
+				zap.String("path", entry.Path),
+				zap.String("result", entry.Result),
+				zap.Error(err))
+		}
+	}
+}
+
+func (a *auditLogger) produce(entry auditEntry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.cluster.Produce(kafka.ProduceRecord{
+		TopicName:   a.cfg.Topic,
+		Partitioner: kafka.PartitionerHash,
+		Key:         []byte(entry.User),
+		Value:       value,
+	}, kafka.ProduceOptions{})
+	return err
+}
+
+// recordAuditLog records each request which modifies a cluster, including those which have been denied or failed
+func (api *API) recordAuditLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isModifyingRequest(r.Method, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The body is read ahead up to the limit and handed to the handler unchanged
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(io.LimitReader(r.Body, int64(api.Cfg.AuditLog.MaxBodyBytes)+1))
+			if err != nil {
+				body = nil
+			}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		}
+
+		start := time.Now()
+		rw := &auditResponseWriter{statusResponseWriter: statusResponseWriter{ResponseWriter: w, status: http.StatusOK}}
+		next.ServeHTTP(rw, r)
+
+		route := r.URL.Path
+		routeCtx := chi.RouteContext(r.Context())
+		if routeCtx != nil && routeCtx.RoutePattern() != "" {
+			route = routeCtx.RoutePattern()
+		}
+		if !isModifyingRequest(r.Method, route) {
+			return
+		}
+
+		subject := authorization.SubjectFromContext(r.Context())
+		action := findAuditAction(r.Method, route)
+		entry := auditEntry{
+			Timestamp:     start.UTC(),
+			Action:        action.name,
+			User:          requesterID(r),
+			Groups:        subject.Roles,
+			RemoteAddress: r.RemoteAddr,
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Query:         r.URL.RawQuery,
+			Status:        rw.status,
+			Result:        auditResultSuccess,
+			DurationMs:    time.Since(start).Milliseconds(),
+		}
+		if routeCtx != nil && len(routeCtx.URLParams.Keys) > 0 {
+			entry.Parameters = make(map[string]string, len(routeCtx.URLParams.Keys))
+			for i, key := range routeCtx.URLParams.Keys {
+				entry.Parameters[key] = routeCtx.URLParams.Values[i]
+			}
+		}
+		if len(body) > 0 {
+			entry.Body, entry.IsBodyOmitted = redactAuditBody(body, api.Cfg.AuditLog.MaxBodyBytes, action.redactedFields)
+		}
+		if rw.status >= http.StatusBadRequest {
+			entry.Result = auditResultFailure
+			entry.Error = auditErrorMessage(rw.status, rw.errorBody)
+		}
+
+		api.auditLog.Record(entry)
+	})
+}
+
+// findAuditAction returns the action of the route, or an unnamed action which is named by method and route
+func findAuditAction(method string, route string) auditAction {
+	for _, action := range auditActions {
+		if action.method == method && strings.HasSuffix(route, action.route) {
+			return action
+		}
+	}
+	return auditAction{method: method, route: route, name: method + " " + route}
+}
+
+// redactAuditBody returns the JSON body with sensitive and redacted fields replaced. Bodies which exceed the limit
+// or aren't JSON are omitted.
+func redactAuditBody(body []byte, maxBytes int, redactedFields []string) (json.RawMessage, bool) {
+	if len(body) > maxBytes {
+		return nil, true
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, true
+	}
+	if obj, ok := parsed.(map[string]interface{}); ok {
+		for _, field := range redactedFields {
+			if _, exists := obj[field]; exists {
+				obj[field] = auditRedacted
+			}
+		}
+	}
+	parsed = redactSensitiveFields(parsed)
+
+	redacted, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, true
+	}
+	return redacted, false
+}
+
+// redactSensitiveFields replaces the values of all fields whose names look like they hold secrets
+func redactSensitiveFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isSensitiveField(key) {
+				v[key] = auditRedacted
+				continue
+			}
+			v[key] = redactSensitiveFields(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactSensitiveFields(child)
+		}
+	}
+	return value
+}
+
+func isSensitiveField(name string) bool {
+	lower := strings.ToLower(name)
+	for _, sensitive := range auditSensitiveFields {
+		if strings.Contains(lower, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditErrorMessage returns the message of a REST error response, or the status text if the body isn't one
+func auditErrorMessage(status int, body []byte) string {
+	var restErr struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &restErr); err == nil && restErr.Message != "" {
+		return restErr.Message
+	}
+	return http.StatusText(status)
+}
+
+// auditResponseWriter additionally keeps the beginning of error responses, which contain the error message
+type auditResponseWriter struct {
+	statusResponseWriter
+	errorBody []byte
+}
+
+func (rw *auditResponseWriter) Write(b []byte) (int, error) {
+	if rw.status >= http.StatusBadRequest && len(rw.errorBody) < maxAuditErrorBytes {
+		n := maxAuditErrorBytes - len(rw.errorBody)
+		if n > len(b) {
+			n = len(b)
+		}
+		rw.errorBody = append(rw.errorBody, b[:n]...)
+	}
+	return rw.statusResponseWriter.Write(b)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRecordAuditLog(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("kowl-audit", 1, 1, nil, false))
+
+	cfg := AuditLogConfig{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.Topic = "kowl-audit"
+	auditLog, err := newAuditLogger(cfg, cluster, zap.NewNop())
+	require.NoError(t, err)
+	api := &API{Cfg: &Config{AuditLog: cfg}, Logger: zap.NewNop(), auditLog: auditLog}
+
+	var handledBody string
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject := authorization.Subject{Name: "jane@example.com", Roles: []string{"oncall"}}
+			next.ServeHTTP(w, r.WithContext(authorization.ContextWithSubject(r.Context(), subject)))
+		})
+	})
+	router.Use(api.recordAuditLog)
+	router.Post("/api/topics/{topicName}/messages", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		require.NoError(t, rest.Decode(r, &req))
+		handledBody = req["value"].(string)
+		rest.SendResponse(w, r, zap.NewNop(), http.StatusOK, nil)
+	})
+	router.Delete("/api/topics/{topicName}", func(w http.ResponseWriter, r *http.Request) {
+		rest.SendRESTError(w, r, zap.NewNop(), &rest.Error{Status: http.StatusForbidden, Message: "You don't have permissions to delete this topic", IsSilent: true})
+	})
+	router.Get("/api/topics", func(w http.ResponseWriter, r *http.Request) {})
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/topics/orders/messages", strings.NewReader(`{"value": "secret payload", "partitioner": "hash", "options": {"saslPassword": "x"}}`)),
+		httptest.NewRequest(http.MethodGet, "/api/topics", nil),
+		httptest.NewRequest(http.MethodDelete, "/api/topics/orders", nil),
+	}
+	for _, req := range requests {
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, "secret payload", handledBody, "handler must receive the full body")
+
+	consumer, err := cluster.NewConsumer(sarama.ReadUncommitted)
+	require.NoError(t, err)
+	pc, err := consumer.ConsumePartition("kowl-audit", 0, sarama.OffsetOldest)
+	require.NoError(t, err)
+	defer pc.Close()
+
+	entries := make([]auditEntry, 2)
+	for i := range entries {
+		select {
+		case msg := <-pc.Messages():
+			require.NoError(t, json.Unmarshal(msg.Value, &entries[i]))
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for entry %v", i)
+		}
+	}
+
+	assert.Equal(t, "produceMessage", entries[0].Action)
+	assert.Equal(t, "jane@example.com", entries[0].User)
+	assert.Equal(t, []string{"oncall"}, entries[0].Groups)
+	assert.Equal(t, map[string]string{"topicName": "orders"}, entries[0].Parameters)
+	assert.JSONEq(t, `{"value": "[REDACTED]", "partitioner": "hash", "options": {"saslPassword": "[REDACTED]"}}`, string(entries[0].Body))
+	assert.Equal(t, auditResultSuccess, entries[0].Result)
+
+	assert.Equal(t, "deleteTopic", entries[1].Action)
+	assert.Equal(t, auditResultFailure, entries[1].Result)
+	assert.Equal(t, http.StatusForbidden, entries[1].Status)
+	assert.Equal(t, "You don't have permissions to delete this topic", entries[1].Error)
+}
+
+func TestRedactAuditBody(t *testing.T) {
+	tt := []struct {
+		body          string
+		maxBytes      int
+		expected      string
+		isBodyOmitted bool
+	}{
+		{`{"topicName": "orders", "partitionCount": 3}`, 100, `{"topicName": "orders", "partitionCount": 3}`, false},
+		{`{"configs": [{"name": "sasl.jaas.config", "clientSecret": "x"}]}`, 100, `{"configs": [{"name": "sasl.jaas.config", "clientSecret": "[REDACTED]"}]}`, false},
+		{`{"key": "k", "value": "v"}`, 100, `{"key": "[REDACTED]", "value": "[REDACTED]"}`, false},
+		{`{"topicName": "orders"}`, 10, ``, true},
+		{`not json`, 100, ``, true},
+	}
+
+	for i, test := range tt {
+		body, isBodyOmitted := redactAuditBody([]byte(test.body), test.maxBytes, []string{"key", "value"})
+		assert.Equal(t, test.isBodyOmitted, isBodyOmitted, "Case: ", i)
+		if test.isBodyOmitted {
+			assert.Nil(t, body, "Case: ", i)
+			continue
+		}
+		assert.JSONEq(t, test.expected, string(body), "Case: ", i)
+	}
+}
+
+func TestFindAuditAction(t *testing.T) {
+	assert.Equal(t, "createTopic", findAuditAction(http.MethodPost, "/api/topics").name)
+	assert.Equal(t, "createTopic", findAuditAction(http.MethodPost, "/api/clusters/b/topics").name)
+	assert.Equal(t, "deleteConsumerGroup", findAuditAction(http.MethodDelete, "/api/consumer-groups/{groupId}").name)
+	assert.Equal(t, "DELETE /api/topics/{topicName}/saved-filters/{filterName}",
+		findAuditAction(http.MethodDelete, "/api/topics/{topicName}/saved-filters/{filterName}").name)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/authentication"
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"go.uber.org/zap"
+)
+
+// bearerToken returns the token of the Authorization header, or the value of the cookie if the header is missing.
+// It's empty if the request carries no token.
+func bearerToken(r *http.Request, cookieName string) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(header[len("Bearer "):])
+	}
+	if cookieName != "" {
+		if cookie, err := r.Cookie(cookieName); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// authenticate resolves the user of the request's bearer token and passes it as authorization subject to the
+// handlers. Requests without token are served anonymously unless authentication is required.
+func (api *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r, api.Cfg.Authentication.SessionCookieName())
+		if token == "" {
+			if api.Cfg.Authentication.Required {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				restErr := &rest.Error{
+					Err:      fmt.Errorf("request carries no bearer token"),
+					Status:   http.StatusUnauthorized,
+					Message:  "Authentication is required",
+					IsSilent: true,
+				}
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		subject, err := api.Authenticator.Authenticate(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, authentication.ErrUnauthenticated) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				restErr := &rest.Error{
+					Err:      err,
+					Status:   http.StatusUnauthorized,
+					Message:  "The bearer token is invalid or has expired",
+					IsSilent: true,
+				}
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusServiceUnavailable,
+				Message:  "Could not authenticate the request, the authentication provider is unavailable",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger.With(zap.String("source", "authentication")), restErr)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(authorization.ContextWithSubject(r.Context(), subject)))
+	})
+}
@@ -4,8 +4,28 @@ import (
 	"flag"
 	"fmt"
 	"github.com/cloudhut/common/logging"
-	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/approval"
+	"github.com/cloudhut/kowl/backend/pkg/authentication"
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"github.com/cloudhut/kowl/backend/pkg/canary"
+	"github.com/cloudhut/kowl/backend/pkg/connect"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/dlq"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/fulltext"
+	"github.com/cloudhut/kowl/backend/pkg/headerindex"
 	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/ksql"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+	"github.com/cloudhut/kowl/backend/pkg/principals"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"github.com/cloudhut/kowl/backend/pkg/rendering"
+	"github.com/cloudhut/kowl/backend/pkg/savedfilters"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+	"github.com/cloudhut/kowl/backend/pkg/scim"
+	"github.com/cloudhut/kowl/backend/pkg/templates"
+	"github.com/cloudhut/kowl/backend/pkg/topicmeta"
+	"github.com/cloudhut/kowl/backend/pkg/usage"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 )
@@ -17,9 +37,50 @@ type Config struct {
 	ServeFrontend    bool   `yaml:"serveFrontend"`
 	FrontendPath     string `yaml:"frontendPath"`
 
-	REST   rest.Config    `yaml:"server"`
-	Kafka  kafka.Config   `yaml:"kafka"`
-	Logger logging.Config `yaml:"logger"`
+	REST        ServerConfig      `yaml:"server"`
+	Kafka       kafka.Config      `yaml:"kafka"`
+	Logger      logging.Config    `yaml:"logger"`
+	Filter      filter.Config     `yaml:"filter"`
+	LiveTail    LiveTailConfig    `yaml:"liveTail"`
+	Consumer    ConsumerConfig    `yaml:"consumer"`
+	Export      ExportConfig      `yaml:"export"`
+	Replay      ReplayConfig      `yaml:"replay"`
+	DLQ         dlq.Config        `yaml:"dlq"`
+	TableView   TableViewConfig   `yaml:"tableView"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Proto       proto.Config      `yaml:"proto"`
+	Templates   templates.Config  `yaml:"templates"`
+	Masking     masking.Config    `yaml:"masking"`
+	Rendering   rendering.Config  `yaml:"rendering"`
+	Decryption  decryption.Config `yaml:"decryption"`
+
+	PayloadTruncation PayloadTruncationConfig `yaml:"payloadTruncation"`
+
+	Authentication authentication.Config `yaml:"authentication"`
+	Authorization  authorization.Config  `yaml:"authorization"`
+	SCIM           scim.Config           `yaml:"scim"`
+	Principals     principals.Config     `yaml:"principals"`
+	TopicApprovals approval.Config       `yaml:"topicApprovals"`
+
+	SavedFilters  savedfilters.Config `yaml:"savedFilters"`
+	TopicMetadata topicmeta.Config    `yaml:"topicMetadata"`
+	HeaderIndex   headerindex.Config  `yaml:"headerIndex"`
+	FullText      fulltext.Config     `yaml:"fullText"`
+	SelfEvents    SelfEventsConfig    `yaml:"selfEvents"`
+	AuditLog      AuditLogConfig      `yaml:"auditLog"`
+	LagExporter   LagExporterConfig   `yaml:"lagExporter"`
+	GroupHistory  GroupHistoryConfig  `yaml:"groupHistory"`
+	SmokeTest     SmokeTestConfig     `yaml:"smokeTest"`
+	Canary        canary.Config       `yaml:"canary"`
+	Usage         usage.Config        `yaml:"usage"`
+
+	SchemaRegistry schema.Config  `yaml:"schemaRegistry"`
+	Connect        connect.Config `yaml:"connect"`
+	Ksql           ksql.Config    `yaml:"ksql"`
+
+	// ClusterName is the name of the cluster configured in Kafka and SchemaRegistry, Clusters are served in addition
+	ClusterName string          `yaml:"clusterName"`
+	Clusters    []ClusterConfig `yaml:"clusters"`
 }
 
 // RegisterFlags for all (sub)configs
@@ -28,6 +89,11 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 
 	// Package flags for sensitive input like passwords
 	c.Kafka.RegisterFlags(f)
+	c.SchemaRegistry.RegisterFlags(f)
+	c.Authentication.RegisterFlags(f)
+	c.Authorization.RegisterFlags(f)
+	c.SCIM.RegisterFlags(f)
+	c.Decryption.RegisterFlags(f)
 }
 
 // Validate all root and child config structs
@@ -37,11 +103,192 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("failed to validate loglevel input: %w", err)
 	}
 
+	err = c.REST.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate server config: %w", err)
+	}
+
 	err = c.Kafka.Validate()
 	if err != nil {
 		return fmt.Errorf("failed to validate Kafka config: %w", err)
 	}
 
+	err = c.Filter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate filter config: %w", err)
+	}
+
+	err = c.LiveTail.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate live tail config: %w", err)
+	}
+
+	err = c.Consumer.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate consumer config: %w", err)
+	}
+
+	err = c.Export.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate export config: %w", err)
+	}
+
+	err = c.Replay.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate replay config: %w", err)
+	}
+
+	err = c.DLQ.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate dlq config: %w", err)
+	}
+
+	err = c.TableView.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate table view config: %w", err)
+	}
+
+	err = c.PayloadTruncation.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate payload truncation config: %w", err)
+	}
+
+	err = c.Idempotency.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate idempotency config: %w", err)
+	}
+
+	err = c.Proto.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate proto config: %w", err)
+	}
+
+	err = c.SchemaRegistry.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate schema registry config: %w", err)
+	}
+
+	err = c.Connect.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate kafka connect config: %w", err)
+	}
+
+	err = c.Ksql.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate ksqlDB config: %w", err)
+	}
+
+	err = c.Templates.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate templates config: %w", err)
+	}
+
+	err = c.Masking.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate masking config: %w", err)
+	}
+	err = c.Rendering.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate rendering config: %w", err)
+	}
+	err = c.Decryption.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate decryption config: %w", err)
+	}
+
+	err = validateTemplateMaskingProfiles(c.Templates, c.Masking)
+	if err != nil {
+		return fmt.Errorf("failed to validate templates config: %w", err)
+	}
+
+	err = c.Authentication.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate authentication config: %w", err)
+	}
+
+	err = c.Authorization.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate authorization config: %w", err)
+	}
+
+	err = c.SCIM.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate scim config: %w", err)
+	}
+
+	err = c.Principals.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate principals config: %w", err)
+	}
+
+	err = c.TopicApprovals.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate topic approvals config: %w", err)
+	}
+	if c.TopicApprovals.Enabled && c.Authentication.Type == authentication.TypeNone {
+		return fmt.Errorf("topic approvals require authentication, so that requesters and approvers can be told apart")
+	}
+
+	err = c.HeaderIndex.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate header index config: %w", err)
+	}
+
+	err = c.FullText.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate full-text index config: %w", err)
+	}
+
+	err = c.SavedFilters.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate saved filters config: %w", err)
+	}
+
+	err = c.TopicMetadata.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate topic metadata config: %w", err)
+	}
+
+	err = c.SelfEvents.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate self events config: %w", err)
+	}
+
+	err = c.AuditLog.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate audit log config: %w", err)
+	}
+
+	err = c.LagExporter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate lag exporter config: %w", err)
+	}
+
+	err = c.GroupHistory.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate group history config: %w", err)
+	}
+
+	err = c.SmokeTest.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate smoke test config: %w", err)
+	}
+
+	err = c.Canary.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate canary config: %w", err)
+	}
+
+	err = c.Usage.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate usage config: %w", err)
+	}
+
+	err = validateClusters(c.ClusterName, c.Clusters)
+	if err != nil {
+		return fmt.Errorf("failed to validate clusters config: %w", err)
+	}
+
 	return nil
 }
 
@@ -50,10 +297,67 @@ func (c *Config) SetDefaults() {
 	c.ServeFrontend = true
 	c.FrontendPath = "./build"
 	c.MetricsNamespace = "kowl"
+	c.ClusterName = "default"
 
 	c.Logger.SetDefaults()
 	c.REST.SetDefaults()
 	c.Kafka.SetDefaults()
+	c.Filter.SetDefaults()
+	c.LiveTail.SetDefaults()
+	c.Consumer.SetDefaults()
+	c.Export.SetDefaults()
+	c.Replay.SetDefaults()
+	c.DLQ.SetDefaults()
+	c.TableView.SetDefaults()
+	c.PayloadTruncation.SetDefaults()
+	c.Idempotency.SetDefaults()
+	c.SchemaRegistry.SetDefaults()
+	c.Connect.SetDefaults()
+	c.Ksql.SetDefaults()
+	c.Masking.SetDefaults()
+	c.Decryption.SetDefaults()
+	c.Authentication.SetDefaults()
+	c.Authorization.SetDefaults()
+	c.SCIM.SetDefaults()
+	c.Principals.SetDefaults()
+	c.TopicApprovals.SetDefaults()
+	c.SavedFilters.SetDefaults()
+	c.TopicMetadata.SetDefaults()
+	c.HeaderIndex.SetDefaults()
+	c.FullText.SetDefaults()
+	c.SelfEvents.SetDefaults()
+	c.AuditLog.SetDefaults()
+	c.LagExporter.SetDefaults()
+	c.GroupHistory.SetDefaults()
+	c.SmokeTest.SetDefaults()
+	c.Canary.SetDefaults()
+	c.Usage.SetDefaults()
+}
+
+// validateTemplateMaskingProfiles ensures that all masking profiles which are referenced by consume templates exist
+func validateTemplateMaskingProfiles(templatesCfg templates.Config, maskingCfg masking.Config) error {
+	profiles := make(map[string]struct{}, len(maskingCfg.Profiles))
+	for _, p := range maskingCfg.Profiles {
+		profiles[p.Name] = struct{}{}
+	}
+	for _, t := range templatesCfg.Consume {
+		if t.MaskingProfile == "" {
+			continue
+		}
+		if _, exists := profiles[t.MaskingProfile]; !exists {
+			return fmt.Errorf("masking profile '%v' of consume template '%v' does not exist", t.MaskingProfile, t.Name)
+		}
+	}
+	for _, s := range templatesCfg.SavedSearches {
+		if s.MaskingProfile == "" {
+			continue
+		}
+		if _, exists := profiles[s.MaskingProfile]; !exists {
+			return fmt.Errorf("masking profile '%v' of saved search '%v' does not exist", s.MaskingProfile, s.ID)
+		}
+	}
+
+	return nil
 }
 
 // LoadConfig read YAML-formatted config from filename into cfg.
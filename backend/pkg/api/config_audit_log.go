@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+)
+
+// AuditLogConfig configures the audit log, which records every request that modifies a cluster (e.g. producing
+// messages, deleting topics or changing ACLs) along with the requester and the outcome
+type AuditLogConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// File is the path of a file to which entries are appended as JSON lines. 'stdout' and 'stderr' are supported
+	// as well.
+	File string `yaml:"file"`
+
+	// Topic of the default cluster into which entries are produced as JSON records. File and topic can be used
+	// at the same time.
+	Topic string `yaml:"topic"`
+
+	// MaxBodyBytes is the maximum size of request bodies which are recorded as parameters. Larger bodies are omitted.
+	MaxBodyBytes int `yaml:"maxBodyBytes"`
+}
+
+// SetDefaults for the audit log config
+func (c *AuditLogConfig) SetDefaults() {
+	c.MaxBodyBytes = 64 * 1024
+}
+
+// Validate the audit log config
+func (c *AuditLogConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.File == "" && c.Topic == "" {
+		return fmt.Errorf("a file, a topic or both must be set")
+	}
+	if c.Topic != "" {
+		if err := owl.ValidateTopicName(c.Topic); err != nil {
+			return fmt.Errorf("invalid topic: %w", err)
+		}
+	}
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("max body bytes must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+)
+
+// clusterNamePattern restricts cluster names to characters which can be used in URL paths. Metric names must not
+// contain '-', see clusterMetricsNamespace.
+var clusterNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ClusterConfig is an additional Kafka cluster which is served by the same kowl instance. All API routes of an
+// additional cluster are available under /api/clusters/{clusterName}, while the cluster configured in the root
+// kafka config remains available under /api.
+type ClusterConfig struct {
+	// Name is used to refer to the cluster in the API and must be unique
+	Name           string        `yaml:"name"`
+	Kafka          kafka.Config  `yaml:"kafka"`
+	SchemaRegistry schema.Config `yaml:"schemaRegistry"`
+}
+
+// UnmarshalYAML sets the defaults before decoding, because list items are not covered by Config.SetDefaults
+func (c *ClusterConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	c.Kafka.SetDefaults()
+	c.SchemaRegistry.SetDefaults()
+
+	type plain ClusterConfig
+	return unmarshal((*plain)(c))
+}
+
+// Validate the cluster config
+func (c *ClusterConfig) Validate() error {
+	if !clusterNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("cluster name '%v' must consist of 1 to 64 alphanumeric characters, '_' or '-'", c.Name)
+	}
+
+	err := c.Kafka.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate Kafka config: %w", err)
+	}
+
+	err = c.SchemaRegistry.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate schema registry config: %w", err)
+	}
+
+	return nil
+}
+
+// clusterMetricsNamespace returns the namespace of an additional cluster's metrics. Prometheus doesn't allow '-' in
+// metric names, so it's replaced with '_'.
+func clusterMetricsNamespace(metricsNamespace string, clusterName string) string {
+	return fmt.Sprintf("%v_%v", metricsNamespace, strings.ReplaceAll(clusterName, "-", "_"))
+}
+
+// validateClusters validates all additional clusters and ensures their names and metric namespaces are unique
+func validateClusters(defaultClusterName string, clusters []ClusterConfig) error {
+	if !clusterNamePattern.MatchString(defaultClusterName) {
+		return fmt.Errorf("cluster name '%v' must consist of 1 to 64 alphanumeric characters, '_' or '-'", defaultClusterName)
+	}
+
+	names := map[string]struct{}{defaultClusterName: {}}
+	metricNames := make(map[string]string)
+	for i, cluster := range clusters {
+		err := cluster.Validate()
+		if err != nil {
+			return fmt.Errorf("cluster at index '%v': %w", i, err)
+		}
+		if _, exists := names[cluster.Name]; exists {
+			return fmt.Errorf("cluster name '%v' is used more than once", cluster.Name)
+		}
+		names[cluster.Name] = struct{}{}
+
+		// Clusters must not share their metrics
+		metricName := clusterMetricsNamespace("", cluster.Name)
+		if other, exists := metricNames[metricName]; exists {
+			return fmt.Errorf("cluster names '%v' and '%v' only differ in '-' and '_'", other, cluster.Name)
+		}
+		metricNames[metricName] = cluster.Name
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidateClusters(t *testing.T) {
+	newCluster := func(name string) ClusterConfig {
+		c := ClusterConfig{Name: name}
+		c.Kafka.SetDefaults()
+		c.Kafka.Brokers = []string{"localhost:9092"}
+		c.SchemaRegistry.SetDefaults()
+		return c
+	}
+	withoutBrokers := newCluster("staging")
+	withoutBrokers.Kafka.Brokers = nil
+
+	tt := []struct {
+		defaultName string
+		clusters    []ClusterConfig
+		isValid     bool
+	}{
+		{"production", nil, true},
+		{"production", []ClusterConfig{newCluster("staging"), newCluster("dev_1")}, true},
+		{"production", []ClusterConfig{newCluster("staging"), newCluster("staging")}, false},
+		{"production", []ClusterConfig{newCluster("production")}, false}, // Collides with the default cluster
+		{"production", []ClusterConfig{newCluster("prod-eu"), newCluster("prod-us")}, true},
+		{"production", []ClusterConfig{newCluster("prod-eu"), newCluster("prod_eu")}, false}, // Same metric namespace
+		{"production", []ClusterConfig{newCluster("")}, false},
+		{"production", []ClusterConfig{newCluster("staging/eu")}, false},
+		{"production", []ClusterConfig{newCluster("staging eu")}, false},
+		{"", []ClusterConfig{newCluster("staging")}, false},
+		{"production", []ClusterConfig{withoutBrokers}, false},
+	}
+
+	for i, test := range tt {
+		err := validateClusters(test.defaultName, test.clusters)
+		if test.isValid {
+			assert.NoError(t, err, "Case: ", i)
+		} else {
+			assert.Error(t, err, "Case: ", i)
+		}
+	}
+}
+
+func TestClusterConfigUnmarshalSetsDefaults(t *testing.T) {
+	input := `
+- name: staging
+  kafka:
+    brokers: ["staging:9092"]
+`
+	var clusters []ClusterConfig
+	require.NoError(t, yaml.UnmarshalStrict([]byte(input), &clusters))
+	require.Len(t, clusters, 1)
+	assert.Equal(t, "staging", clusters[0].Name)
+	assert.Equal(t, []string{"staging:9092"}, clusters[0].Kafka.Brokers)
+	assert.NotEmpty(t, clusters[0].Kafka.ClusterVersion)
+	assert.NoError(t, validateClusters("production", clusters))
+}
+
+func TestNewWithDashedClusterName(t *testing.T) {
+	newFakeKafka := func() kafka.Config {
+		c := kafka.Config{}
+		c.SetDefaults()
+		c.Fake.Enabled = true
+		return c
+	}
+	cfg := &Config{}
+	cfg.SetDefaults()
+	cfg.Kafka = newFakeKafka()
+	cfg.Clusters = []ClusterConfig{{Name: "prod-eu", Kafka: newFakeKafka()}}
+	cfg.Clusters[0].SchemaRegistry.SetDefaults()
+	require.NoError(t, cfg.Validate())
+
+	// Metric names must not contain '-', registering them would panic
+	api := New(cfg)
+	require.Len(t, api.Clusters, 1)
+	assert.Equal(t, "prod-eu", api.Clusters[0].Name)
+	assert.Equal(t, "kowl_prod_eu", clusterMetricsNamespace(cfg.MetricsNamespace, "prod-eu"))
+}
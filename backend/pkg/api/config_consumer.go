@@ -0,0 +1,35 @@
+package api
+
+import (
+	"fmt"
+)
+
+// ConsumerConfig bounds the resources of message searches, so that a few large searches can't exhaust the memory
+// or starve other searches
+type ConsumerConfig struct {
+	// MaxConcurrentPartitions is the number of partitions a search consumes at the same time, 0 is unlimited. Live
+	// tails and searches which are ordered by timestamp consume all partitions at the same time regardless.
+	MaxConcurrentPartitions int `yaml:"maxConcurrentPartitions"`
+
+	// MaxInFlightBytes is the size of consumed messages which are processed at the same time across all searches
+	// and clusters, 0 is unlimited. Consumers wait until enough bytes have been released by others.
+	MaxInFlightBytes int64 `yaml:"maxInFlightBytes"`
+}
+
+// SetDefaults for the consumer config
+func (c *ConsumerConfig) SetDefaults() {
+	c.MaxConcurrentPartitions = 16
+	c.MaxInFlightBytes = 256 * 1024 * 1024
+}
+
+// Validate the consumer config
+func (c *ConsumerConfig) Validate() error {
+	if c.MaxConcurrentPartitions < 0 {
+		return fmt.Errorf("max concurrent partitions must not be negative")
+	}
+	if c.MaxInFlightBytes < 0 {
+		return fmt.Errorf("max in-flight bytes must not be negative")
+	}
+
+	return nil
+}
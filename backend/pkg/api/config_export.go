@@ -0,0 +1,40 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExportConfig limits message exports, which stream search results into a downloadable file
+type ExportConfig struct {
+	// MaxRows is the maximum number of messages per export. Users may request fewer rows.
+	MaxRows int64 `yaml:"maxRows"`
+
+	// MaxBytes is the maximum (uncompressed) size of an export file. Users may request a smaller size.
+	MaxBytes int64 `yaml:"maxBytes"`
+
+	// Timeout after which an export is stopped
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// SetDefaults for the export config
+func (c *ExportConfig) SetDefaults() {
+	c.MaxRows = 10000
+	c.MaxBytes = 50 * 1024 * 1024
+	c.Timeout = 10 * time.Minute
+}
+
+// Validate the export config
+func (c *ExportConfig) Validate() error {
+	if c.MaxRows <= 0 {
+		return fmt.Errorf("max rows must be greater than 0")
+	}
+	if c.MaxBytes <= 0 {
+		return fmt.Errorf("max bytes must be greater than 0")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// GroupHistoryConfig configures the background polling of all consumer groups, which records state transitions and
+// member changes, so that lag spikes can be correlated with rebalances
+type GroupHistoryConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Interval in which all groups are described. Rebalances which start and complete within an interval are still
+	// detected if the members or their assignments changed.
+	Interval time.Duration `yaml:"interval"`
+
+	// Retention is the time span of events which are kept in memory
+	Retention time.Duration `yaml:"retention"`
+
+	// MaxEventsPerGroup bounds the memory of groups which rebalance constantly, their oldest events are dropped
+	MaxEventsPerGroup int `yaml:"maxEventsPerGroup"`
+}
+
+// SetDefaults for the group history config
+func (c *GroupHistoryConfig) SetDefaults() {
+	c.Interval = 15 * time.Second
+	c.Retention = 24 * time.Hour
+	c.MaxEventsPerGroup = 1000
+}
+
+// Validate the group history config
+func (c *GroupHistoryConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Interval < time.Second {
+		return fmt.Errorf("interval must be at least 1s")
+	}
+	if c.Retention < c.Interval {
+		return fmt.Errorf("retention must not be shorter than the interval")
+	}
+	if c.MaxEventsPerGroup <= 0 {
+		return fmt.Errorf("max events per group must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// IdempotencyConfig configures how long responses of requests with an Idempotency-Key header are remembered, so
+// that retries with the same key are not applied twice
+type IdempotencyConfig struct {
+	// KeyTTL is the time after which a key can be reused for a different request
+	KeyTTL time.Duration `yaml:"keyTtl"`
+
+	// MaxKeys is the maximum number of remembered keys, the oldest keys are forgotten first
+	MaxKeys int `yaml:"maxKeys"`
+}
+
+// SetDefaults for the idempotency config
+func (c *IdempotencyConfig) SetDefaults() {
+	c.KeyTTL = 24 * time.Hour
+	c.MaxKeys = 10000
+}
+
+// Validate the idempotency config
+func (c *IdempotencyConfig) Validate() error {
+	if c.KeyTTL <= 0 {
+		return fmt.Errorf("key ttl must be greater than 0")
+	}
+	if c.MaxKeys <= 0 {
+		return fmt.Errorf("max keys must be greater than 0")
+	}
+
+	return nil
+}
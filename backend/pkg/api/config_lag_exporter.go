@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// LagExporterConfig configures the background collection of all consumer group lags, which are exposed as
+// prometheus metrics and kept as short history for the frontend
+type LagExporterConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Interval in which the lags of all groups are collected
+	Interval time.Duration `yaml:"interval"`
+
+	// HistoryRetention is the time span of collected lags which is kept in memory for the lag history endpoint
+	HistoryRetention time.Duration `yaml:"historyRetention"`
+
+	// PartitionMetrics additionally exports the lag of each partition, which may result in many time series
+	PartitionMetrics bool `yaml:"partitionMetrics"`
+}
+
+// SetDefaults for the lag exporter config
+func (c *LagExporterConfig) SetDefaults() {
+	c.Interval = 30 * time.Second
+	c.HistoryRetention = time.Hour
+}
+
+// Validate the lag exporter config
+func (c *LagExporterConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Interval < 5*time.Second {
+		return fmt.Errorf("interval must be at least 5s")
+	}
+	if c.HistoryRetention < c.Interval {
+		return fmt.Errorf("history retention must not be shorter than the interval")
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// LiveTailConfig limits live tail sessions, which stream new messages until the user stops them
+type LiveTailConfig struct {
+	// MaxMessagesPerSecond is the maximum number of messages which are sent to the frontend per second. Users may
+	// request a lower rate. Messages exceeding the rate are dropped.
+	MaxMessagesPerSecond int `yaml:"maxMessagesPerSecond"`
+
+	// MaxDuration after which a live tail session is stopped
+	MaxDuration time.Duration `yaml:"maxDuration"`
+}
+
+// SetDefaults for the live tail config
+func (c *LiveTailConfig) SetDefaults() {
+	c.MaxMessagesPerSecond = 50
+	c.MaxDuration = time.Hour
+}
+
+// Validate the live tail config
+func (c *LiveTailConfig) Validate() error {
+	if c.MaxMessagesPerSecond <= 0 {
+		return fmt.Errorf("max messages per second must be greater than 0")
+	}
+	if c.MaxDuration <= 0 {
+		return fmt.Errorf("max duration must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"fmt"
+)
+
+// PayloadTruncationConfig truncates large values of messages which are listed in the message viewer, so that they
+// don't blow up the size of responses and the browser's memory. Truncated messages can be fetched in full by their
+// partition and offset.
+type PayloadTruncationConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MaxBytes is the size of the rendered value after which it's truncated
+	MaxBytes int `yaml:"maxBytes"`
+}
+
+// SetDefaults for the payload truncation config
+func (c *PayloadTruncationConfig) SetDefaults() {
+	c.MaxBytes = 64 * 1024
+}
+
+// Validate the payload truncation config
+func (c *PayloadTruncationConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MaxBytes <= 0 {
+		return fmt.Errorf("max bytes must be greater than 0")
+	}
+
+	return nil
+}
+
+// maxValueBytes returns the size after which listed values are truncated, 0 if truncation is disabled
+func (c *PayloadTruncationConfig) maxValueBytes() int {
+	if !c.Enabled {
+		return 0
+	}
+	return c.MaxBytes
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReplayConfig limits message replays, which copy the messages of a search to another topic
+type ReplayConfig struct {
+	// MaxMessages is the maximum number of messages per replay. Users may request fewer messages.
+	MaxMessages int64 `yaml:"maxMessages"`
+
+	// MaxChunkSize is the maximum number of messages which are produced within a single transaction in
+	// transactional replays. Users may request smaller chunks.
+	MaxChunkSize int `yaml:"maxChunkSize"`
+
+	// Timeout after which a replay is stopped, the messages which have been replayed until then are kept
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// SetDefaults for the replay config
+func (c *ReplayConfig) SetDefaults() {
+	c.MaxMessages = 100000
+	c.MaxChunkSize = 1000
+	c.Timeout = 10 * time.Minute
+}
+
+// Validate the replay config
+func (c *ReplayConfig) Validate() error {
+	if c.MaxMessages <= 0 {
+		return fmt.Errorf("max messages must be greater than 0")
+	}
+	if c.MaxChunkSize <= 0 {
+		return fmt.Errorf("max chunk size must be greater than 0")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+)
+
+// SelfEventsConfig configures the emission of Kowl's own operational events (searches, errors and admin actions)
+// as JSON records into a Kafka topic of the default cluster
+type SelfEventsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Topic   string `yaml:"topic"`
+
+	// FlushInterval is the interval in which buffered events are produced. A summary event with the counters of the
+	// interval is emitted along with them.
+	FlushInterval time.Duration `yaml:"flushInterval"`
+
+	// MaxBufferedEvents bounds the memory of events which haven't been produced yet. Further events are dropped and
+	// only counted, so that an unavailable topic never slows down or grows the backend.
+	MaxBufferedEvents int `yaml:"maxBufferedEvents"`
+}
+
+// SetDefaults for the self events config
+func (c *SelfEventsConfig) SetDefaults() {
+	c.Topic = "__kowl_events"
+	c.FlushInterval = 10 * time.Second
+	c.MaxBufferedEvents = 1000
+}
+
+// Validate the self events config
+func (c *SelfEventsConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if err := owl.ValidateTopicName(c.Topic); err != nil {
+		return fmt.Errorf("invalid topic: %w", err)
+	}
+	if c.FlushInterval < time.Second {
+		return fmt.Errorf("flush interval must be at least 1s")
+	}
+	if c.MaxBufferedEvents <= 0 {
+		return fmt.Errorf("max buffered events must be greater than 0")
+	}
+
+	return nil
+}
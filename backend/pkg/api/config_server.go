@@ -0,0 +1,45 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+)
+
+// ServerConfig is the config of the HTTP server, extended by an optional TLS listener
+type ServerConfig struct {
+	rest.Config `yaml:",inline"`
+
+	TLS ServerTLSConfig `yaml:"tls"`
+}
+
+// ServerTLSConfig serves HTTPS with the given certificate and key. The files are checked for changes at the reload
+// interval, so that rotated certificates are served without a restart. A reload interval of 0 disables reloading.
+type ServerTLSConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	CertFilepath   string        `yaml:"certFilepath"`
+	KeyFilepath    string        `yaml:"keyFilepath"`
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
+}
+
+// SetDefaults for the server config
+func (c *ServerConfig) SetDefaults() {
+	c.Config.SetDefaults()
+	c.TLS.ReloadInterval = 30 * time.Second
+}
+
+// Validate the server config
+func (c *ServerConfig) Validate() error {
+	if !c.TLS.Enabled {
+		return nil
+	}
+	if c.TLS.CertFilepath == "" || c.TLS.KeyFilepath == "" {
+		return fmt.Errorf("tls cert filepath and key filepath must be set if tls is enabled")
+	}
+	if c.TLS.ReloadInterval < 0 {
+		return fmt.Errorf("tls reload interval must not be negative")
+	}
+
+	return nil
+}
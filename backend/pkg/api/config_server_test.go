@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestServerConfigUnmarshal(t *testing.T) {
+	input := `
+listenPort: 8443
+compressionLevel: 0
+tls:
+  enabled: true
+  certFilepath: /etc/kowl/tls.crt
+  keyFilepath: /etc/kowl/tls.key
+`
+	cfg := ServerConfig{}
+	cfg.SetDefaults()
+	require.NoError(t, yaml.UnmarshalStrict([]byte(input), &cfg))
+	require.NoError(t, cfg.Validate())
+
+	// The options of the common server config are inlined
+	assert.Equal(t, 8443, cfg.HTTPListenPort)
+	assert.Equal(t, 0, cfg.CompressionLevel)
+	assert.Equal(t, 30*time.Second, cfg.HTTPServerReadTimeout)
+	assert.True(t, cfg.TLS.Enabled)
+	assert.Equal(t, "/etc/kowl/tls.crt", cfg.TLS.CertFilepath)
+	assert.Equal(t, 30*time.Second, cfg.TLS.ReloadInterval)
+
+	cfg.TLS.KeyFilepath = ""
+	assert.Error(t, cfg.Validate())
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+)
+
+// SmokeTestConfig configures the smoke test, which produces a marker record to one of the test topics and consumes
+// it back to confirm that the cluster is functional end to end
+type SmokeTestConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Topics are the only topics smoke tests may produce to, so that markers never end up in business topics
+	Topics []string `yaml:"topics"`
+
+	// Timeout is the default time until the marker must have been consumed, MaxTimeout caps requested timeouts
+	Timeout    time.Duration `yaml:"timeout"`
+	MaxTimeout time.Duration `yaml:"maxTimeout"`
+}
+
+// SetDefaults for the smoke test config
+func (c *SmokeTestConfig) SetDefaults() {
+	c.Timeout = 10 * time.Second
+	c.MaxTimeout = time.Minute
+}
+
+// Validate the smoke test config
+func (c *SmokeTestConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Topics) == 0 {
+		return fmt.Errorf("at least one topic must be configured")
+	}
+	for _, topic := range c.Topics {
+		if err := owl.ValidateTopicName(topic); err != nil {
+			return fmt.Errorf("invalid topic: %w", err)
+		}
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+	if c.MaxTimeout < c.Timeout {
+		return fmt.Errorf("max timeout must not be shorter than the timeout")
+	}
+
+	return nil
+}
+
+// IsTestTopic returns true if smoke tests may produce to the topic
+func (c *SmokeTestConfig) IsTestTopic(topicName string) bool {
+	for _, topic := range c.Topics {
+		if topic == topicName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// TableViewConfig limits the table views of compacted topics, which consume the whole topic and keep the latest
+// message of each key in memory
+type TableViewConfig struct {
+	// MaxKeys is the maximum number of keys per table view. Larger topics return an incomplete table.
+	MaxKeys int `yaml:"maxKeys"`
+
+	// MaxBytes is the maximum summed size of the keys and values which are kept in memory per table view
+	MaxBytes int64 `yaml:"maxBytes"`
+
+	// Timeout after which consuming the topic is stopped
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// SetDefaults for the table view config
+func (c *TableViewConfig) SetDefaults() {
+	c.MaxKeys = 100000
+	c.MaxBytes = 64 * 1024 * 1024
+	c.Timeout = time.Minute
+}
+
+// Validate the table view config
+func (c *TableViewConfig) Validate() error {
+	if c.MaxKeys <= 0 {
+		return fmt.Errorf("max keys must be greater than 0")
+	}
+	if c.MaxBytes <= 0 {
+		return fmt.Errorf("max bytes must be greater than 0")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Types of group events
+const (
+	groupEventStateChanged = "stateChanged"
+	groupEventMemberJoined = "memberJoined"
+	groupEventMemberLeft   = "memberLeft"
+	groupEventRebalance    = "rebalance"
+)
+
+// groupEvent is a change of a consumer group which has been observed between two polls
+type groupEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+
+	// PreviousState and State are set for state changes, State is also set for rebalances
+	PreviousState string `json:"previousState,omitempty"`
+	State         string `json:"state,omitempty"`
+
+	// Member fields are set if a member joined or left
+	MemberID   string `json:"memberId,omitempty"`
+	ClientID   string `json:"clientId,omitempty"`
+	ClientHost string `json:"clientHost,omitempty"`
+
+	// ObservedGeneration is incremented on each detected rebalance. Kafka doesn't expose the group's generation id
+	// via DescribeGroups, hence it's counted since Kowl started tracking the group.
+	ObservedGeneration int `json:"observedGeneration"`
+
+	// Reason is set for rebalances, it's either the state change into a rebalancing state or the changed members or
+	// assignments if the rebalance completed between two polls
+	Reason      string `json:"reason,omitempty"`
+	MemberCount int    `json:"memberCount"`
+}
+
+// groupMemberSnapshot is the part of a member which is compared between two polls
+type groupMemberSnapshot struct {
+	ClientID    string
+	ClientHost  string
+	Assignments string // Canonical representation of the assigned partitions
+}
+
+type groupSnapshot struct {
+	State              string
+	Members            map[string]groupMemberSnapshot
+	ObservedGeneration int
+}
+
+// groupHistoryTracker periodically describes all consumer groups of a cluster and records state transitions,
+// joining and leaving members and rebalances. Events within the retention are kept for the group timeline endpoint.
+type groupHistoryTracker struct {
+	cfg          GroupHistoryConfig
+	owlSvc       *owl.Service
+	logger       *zap.Logger
+	warningLimit *rate.Limiter
+
+	rebalances *prometheus.CounterVec
+
+	mutex       sync.RWMutex
+	initialized bool
+	snapshots   map[string]*groupSnapshot
+	events      map[string][]groupEvent // Events of each group, oldest first
+}
+
+func newGroupHistoryTracker(cfg GroupHistoryConfig, owlSvc *owl.Service, namespace string, registerer prometheus.Registerer, logger *zap.Logger) *groupHistoryTracker {
+	t := &groupHistoryTracker{
+		cfg:          cfg,
+		owlSvc:       owlSvc,
+		logger:       logger.With(zap.String("source", "group_history")),
+		warningLimit: rate.NewLimiter(rate.Every(5*time.Minute), 1),
+		rebalances: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer_group",
+			Name:      "rebalances_total",
+			Help:      "Number of rebalances of the consumer group which have been observed by Kowl",
+		}, []string{"group"}),
+		snapshots: make(map[string]*groupSnapshot),
+		events:    make(map[string][]groupEvent),
+	}
+	registerer.MustRegister(t.rebalances)
+
+	return t
+}
+
+// newGroupHistoryTrackerIfEnabled returns nil if the group history is disabled, otherwise the tracker's metrics are
+// registered on the default prometheus registry
+func newGroupHistoryTrackerIfEnabled(cfg GroupHistoryConfig, owlSvc *owl.Service, namespace string, logger *zap.Logger) *groupHistoryTracker {
+	if !cfg.Enabled {
+		return nil
+	}
+	return newGroupHistoryTracker(cfg, owlSvc, namespace, prometheus.DefaultRegisterer, logger)
+}
+
+// Start describes all groups in the configured interval until the process exits. It's a no-op if the tracker is nil,
+// which is the case if the group history is disabled.
+func (t *groupHistoryTracker) Start() {
+	if t == nil {
+		return
+	}
+
+	go func() {
+		t.poll()
+		ticker := time.NewTicker(t.cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			t.poll()
+		}
+	}()
+}
+
+func (t *groupHistoryTracker) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), t.cfg.Interval)
+	defer cancel()
+
+	groups, err := t.owlSvc.DescribeAllConsumerGroups(ctx)
+	if err != nil {
+		if t.warningLimit.Allow() {
+			t.logger.Warn("failed to describe consumer groups", zap.Error(err))
+		}
+		return
+	}
+	t.record(time.Now(), groups)
+}
+
+// record compares the described groups with the previous poll and appends the observed changes to the groups'
+// events. The first poll only records the groups' snapshots, because there is nothing to compare them with.
+func (t *groupHistoryTracker) record(now time.Time, groups []*owl.ConsumerGroupOverview) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	current := make(map[string]*groupSnapshot, len(groups))
+	for _, group := range groups {
+		if group.State == "Dead" {
+			continue
+		}
+		snapshot := newGroupSnapshot(group)
+		previous, exists := t.snapshots[group.GroupID]
+		if !exists {
+			previous = &groupSnapshot{Members: map[string]groupMemberSnapshot{}}
+		}
+		snapshot.ObservedGeneration = previous.ObservedGeneration
+		current[group.GroupID] = snapshot
+
+		if t.initialized {
+			t.compare(now, group.GroupID, previous, snapshot)
+		}
+	}
+
+	// Groups which have been deleted or expired since the previous poll
+	for groupID, previous := range t.snapshots {
+		if _, exists := current[groupID]; exists {
+			continue
+		}
+		t.events[groupID] = append(t.events[groupID], groupEvent{
+			Timestamp:          now,
+			Type:               groupEventStateChanged,
+			PreviousState:      previous.State,
+			State:              "Dead",
+			ObservedGeneration: previous.ObservedGeneration,
+		})
+		t.rebalances.DeleteLabelValues(groupID)
+	}
+	t.snapshots = current
+	t.initialized = true
+
+	oldest := now.Add(-t.cfg.Retention)
+	for groupID, events := range t.events {
+		i := 0
+		for i < len(events) && events[i].Timestamp.Before(oldest) {
+			i++
+		}
+		if len(events)-i > t.cfg.MaxEventsPerGroup {
+			i = len(events) - t.cfg.MaxEventsPerGroup
+		}
+		if i == len(events) {
+			delete(t.events, groupID)
+			continue
+		}
+		t.events[groupID] = events[i:]
+	}
+}
+
+// compare appends the changes between the group's previous and current snapshot to its events. The mutex must be
+// held.
+func (t *groupHistoryTracker) compare(now time.Time, groupID string, previous *groupSnapshot, current *groupSnapshot) {
+	joined, left, reassigned := diffGroupMembers(previous.Members, current.Members)
+
+	// A rebalance which has been observed while it was in progress is only counted once. Otherwise the rebalance
+	// completed between two polls, which is noticeable by the changed members or assignments.
+	reason := ""
+	switch {
+	case isRebalancingState(current.State) && !isRebalancingState(previous.State):
+		reason = fmt.Sprintf("group entered state %v", current.State)
+	case isRebalancingState(current.State) || isRebalancingState(previous.State):
+	case previous.State == "" || current.State == "Empty":
+		// Groups which have just been created or lost all members don't rebalance
+	case len(joined) > 0 || len(left) > 0:
+		reason = "members changed"
+	case reassigned:
+		reason = "assignments changed"
+	}
+	if reason != "" {
+		current.ObservedGeneration++
+		t.rebalances.WithLabelValues(groupID).Inc()
+	}
+
+	newEvent := func(eventType string) groupEvent {
+		return groupEvent{
+			Timestamp:          now,
+			Type:               eventType,
+			State:              current.State,
+			ObservedGeneration: current.ObservedGeneration,
+			MemberCount:        len(current.Members),
+		}
+	}
+	events := make([]groupEvent, 0)
+	if previous.State != current.State {
+		e := newEvent(groupEventStateChanged)
+		e.PreviousState = previous.State
+		events = append(events, e)
+	}
+	for _, memberID := range joined {
+		e := newEvent(groupEventMemberJoined)
+		e.MemberID, e.ClientID, e.ClientHost = memberID, current.Members[memberID].ClientID, current.Members[memberID].ClientHost
+		events = append(events, e)
+	}
+	for _, memberID := range left {
+		e := newEvent(groupEventMemberLeft)
+		e.MemberID, e.ClientID, e.ClientHost = memberID, previous.Members[memberID].ClientID, previous.Members[memberID].ClientHost
+		events = append(events, e)
+	}
+	if reason != "" {
+		e := newEvent(groupEventRebalance)
+		e.Reason = reason
+		events = append(events, e)
+	}
+
+	t.events[groupID] = append(t.events[groupID], events...)
+}
+
+// Events returns the group's events within the retention, oldest first
+func (t *groupHistoryTracker) Events(groupID string) []groupEvent {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	events := make([]groupEvent, len(t.events[groupID]))
+	copy(events, t.events[groupID])
+	return events
+}
+
+func newGroupSnapshot(group *owl.ConsumerGroupOverview) *groupSnapshot {
+	snapshot := &groupSnapshot{State: group.State, Members: make(map[string]groupMemberSnapshot, len(group.Members))}
+	for _, m := range group.Members {
+		assignments := make([]string, 0, len(m.Assignments))
+		for _, a := range m.Assignments {
+			partitionIDs := append([]int32(nil), a.PartitionIDs...)
+			sort.Slice(partitionIDs, func(i, j int) bool { return partitionIDs[i] < partitionIDs[j] })
+			assignments = append(assignments, fmt.Sprintf("%v:%v", a.TopicName, partitionIDs))
+		}
+		sort.Strings(assignments)
+		snapshot.Members[m.ID] = groupMemberSnapshot{
+			ClientID:    m.ClientID,
+			ClientHost:  m.ClientHost,
+			Assignments: strings.Join(assignments, ","),
+		}
+	}
+
+	return snapshot
+}
+
+// diffGroupMembers returns the sorted ids of the members which joined and left and whether the assignment of any
+// remaining member changed
+func diffGroupMembers(previous map[string]groupMemberSnapshot, current map[string]groupMemberSnapshot) (joined []string, left []string, reassigned bool) {
+	for memberID, m := range current {
+		p, exists := previous[memberID]
+		if !exists {
+			joined = append(joined, memberID)
+			continue
+		}
+		if p.Assignments != m.Assignments {
+			reassigned = true
+		}
+	}
+	for memberID := range previous {
+		if _, exists := current[memberID]; !exists {
+			left = append(left, memberID)
+		}
+	}
+	sort.Strings(joined)
+	sort.Strings(left)
+
+	return joined, left, reassigned
+}
+
+func isRebalancingState(state string) bool {
+	return state == "PreparingRebalance" || state == "CompletingRebalance"
+}
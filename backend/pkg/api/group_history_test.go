@@ -0,0 +1,94 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func consumerGroup(groupID string, state string, members map[string][]int32) *owl.ConsumerGroupOverview {
+	group := &owl.ConsumerGroupOverview{GroupID: groupID, State: state}
+	for memberID, partitionIDs := range members {
+		group.Members = append(group.Members, &owl.GroupMemberDescription{
+			ID:          memberID,
+			ClientID:    "client-" + memberID,
+			ClientHost:  "/10.0.0.1",
+			Assignments: []*owl.GroupMemberAssignment{{TopicName: "orders", PartitionIDs: partitionIDs}},
+		})
+	}
+	return group
+}
+
+func eventTypes(events []groupEvent) []string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestGroupHistoryTracker_Record(t *testing.T) {
+	cfg := GroupHistoryConfig{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.Retention = time.Hour
+	tracker := newGroupHistoryTracker(cfg, nil, "test", prometheus.NewRegistry(), zap.NewNop())
+
+	// The first poll is the baseline
+	start := time.Now()
+	tracker.record(start, []*owl.ConsumerGroupOverview{
+		consumerGroup("billing", "Stable", map[string][]int32{"a": {0, 1}}),
+	})
+	assert.Empty(t, tracker.Events("billing"))
+
+	// A member joins, the rebalance is observed while it's in progress
+	tracker.record(start.Add(time.Minute), []*owl.ConsumerGroupOverview{
+		consumerGroup("billing", "PreparingRebalance", map[string][]int32{"a": {0, 1}, "b": {}}),
+	})
+	tracker.record(start.Add(2*time.Minute), []*owl.ConsumerGroupOverview{
+		consumerGroup("billing", "Stable", map[string][]int32{"a": {0}, "b": {1}}),
+	})
+	events := tracker.Events("billing")
+	assert.Equal(t, []string{groupEventStateChanged, groupEventMemberJoined, groupEventRebalance, groupEventStateChanged}, eventTypes(events))
+	assert.Equal(t, "b", events[1].MemberID)
+	assert.Equal(t, 1, events[3].ObservedGeneration, "the completion of an observed rebalance is not counted again")
+
+	// A member leaves and the rebalance completes between two polls
+	tracker.record(start.Add(3*time.Minute), []*owl.ConsumerGroupOverview{
+		consumerGroup("billing", "Stable", map[string][]int32{"b": {0, 1}}),
+	})
+	events = tracker.Events("billing")
+	require.Len(t, events, 6)
+	assert.Equal(t, groupEventMemberLeft, events[4].Type)
+	assert.Equal(t, groupEventRebalance, events[5].Type)
+	assert.Equal(t, "members changed", events[5].Reason)
+	assert.Equal(t, 2, events[5].ObservedGeneration)
+	assert.Equal(t, float64(2), testutil.ToFloat64(tracker.rebalances.WithLabelValues("billing")))
+
+	// Assignments change without membership changes, a new group is created and billing is deleted
+	tracker.record(start.Add(4*time.Minute), []*owl.ConsumerGroupOverview{
+		consumerGroup("billing", "Stable", map[string][]int32{"b": {0, 1, 2}}),
+		consumerGroup("shipping", "Stable", map[string][]int32{"c": {0}}),
+	})
+	assert.Equal(t, "assignments changed", tracker.Events("billing")[6].Reason)
+	assert.Equal(t, []string{groupEventStateChanged, groupEventMemberJoined}, eventTypes(tracker.Events("shipping")))
+	tracker.record(start.Add(5*time.Minute), []*owl.ConsumerGroupOverview{
+		consumerGroup("shipping", "Stable", map[string][]int32{"c": {0}}),
+	})
+	events = tracker.Events("billing")
+	assert.Equal(t, "Dead", events[len(events)-1].State)
+	assert.Equal(t, 0, testutil.CollectAndCount(tracker.rebalances))
+
+	// Events older than the retention are dropped
+	tracker.record(start.Add(65*time.Minute), []*owl.ConsumerGroupOverview{
+		consumerGroup("shipping", "Stable", map[string][]int32{"c": {0}}),
+	})
+	assert.Len(t, tracker.Events("billing"), 1)
+	assert.Empty(t, tracker.Events("shipping"))
+}
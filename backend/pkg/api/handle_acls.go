@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+)
+
+type createACLsRequest struct {
+	ACLs []owl.ACLBinding `json:"acls"`
+}
+
+func (c *createACLsRequest) OK() error {
+	if len(c.ACLs) == 0 {
+		return fmt.Errorf("at least one acl must be given")
+	}
+	return nil
+}
+
+// aclFilterFromQuery reads the ACL filter from the query parameters. Omitted parameters match any value.
+func aclFilterFromQuery(r *http.Request) owl.ACLFilter {
+	q := r.URL.Query()
+	return owl.ACLFilter{
+		ResourceType:   q.Get("resourceType"),
+		ResourceName:   q.Get("resourceName"),
+		PatternType:    q.Get("patternType"),
+		Principal:      q.Get("principal"),
+		Host:           q.Get("host"),
+		Operation:      q.Get("operation"),
+		PermissionType: q.Get("permissionType"),
+	}
+}
+
+// aclStatus maps errors of ACL operations to the http status code
+func aclStatus(err error) int {
+	switch {
+	case errors.Is(err, owl.ErrInvalidACL):
+		return http.StatusBadRequest
+	case errors.Is(err, owl.ErrAuthorizerNotConfigured):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// checkACLPermission sends a forbidden error and returns false if the requester is not allowed to view or edit ACLs
+func (api *API) checkACLPermission(w http.ResponseWriter, r *http.Request, requireEdit bool) bool {
+	isAllowed, restErr := api.Hooks.Owl.CanListACLs(r.Context())
+	if restErr == nil && isAllowed && requireEdit {
+		isAllowed, restErr = api.Hooks.Owl.CanEditACLs(r.Context())
+	}
+	if restErr != nil {
+		rest.SendRESTError(w, r, api.Logger, restErr)
+		return false
+	}
+	if !isAllowed {
+		restErr := &rest.Error{
+			Err:      fmt.Errorf("requester has no permissions to access acls"),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions to access ACLs",
+			IsSilent: false,
+		}
+		rest.SendRESTError(w, r, api.Logger, restErr)
+		return false
+	}
+
+	return true
+}
+
+func (api *API) handleGetACLs() http.HandlerFunc {
+	type response struct {
+		Resources []owl.ACLResource `json:"aclResources"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !api.checkACLPermission(w, r, false) {
+			return
+		}
+
+		resources, err := api.OwlSvc.ListACLs(r.Context(), aclFilterFromQuery(r))
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   aclStatus(err),
+				Message:  fmt.Sprintf("Could not list ACLs: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		api.resolveACLPrincipals(resources)
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Resources: resources})
+	}
+}
+
+func (api *API) handleCreateACLs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createACLsRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		if !api.checkACLPermission(w, r, true) {
+			return
+		}
+
+		err = api.OwlSvc.CreateACLs(r.Context(), req.ACLs)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   aclStatus(err),
+				Message:  fmt.Sprintf("Could not create ACLs: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusCreated, req)
+	}
+}
+
+// handleDeleteACLs deletes all ACLs matching the filter given in the query parameters. Either a resource name or a
+// principal must be given, so that a request without parameters can't delete all ACLs at once. With dryRun=true
+// the matching ACLs are returned without deleting them.
+func (api *API) handleDeleteACLs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter := aclFilterFromQuery(r)
+		if filter.ResourceName == "" && filter.Principal == "" {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("neither resource name nor principal given"),
+				Status:   http.StatusBadRequest,
+				Message:  "Either a resource name or a principal must be given to delete ACLs",
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+		if !api.checkACLPermission(w, r, true) {
+			return
+		}
+
+		res, err := api.OwlSvc.DeleteACLs(r.Context(), filter, dryRun)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   aclStatus(err),
+				Message:  fmt.Sprintf("Could not delete ACLs: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
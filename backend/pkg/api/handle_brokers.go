@@ -0,0 +1,181 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// parseBrokerID parses the brokerID url parameter
+func parseBrokerID(r *http.Request) (int32, *rest.Error) {
+	brokerIDStr := chi.URLParam(r, "brokerID")
+	brokerID, err := strconv.ParseInt(brokerIDStr, 10, 32)
+	if err != nil || brokerID < 0 {
+		return 0, &rest.Error{
+			Err:      fmt.Errorf("failed to parse broker id '%v'", brokerIDStr),
+			Status:   http.StatusBadRequest,
+			Message:  "Broker ID must be a valid, non negative int32",
+			IsSilent: true,
+		}
+	}
+
+	return int32(brokerID), nil
+}
+
+// handleGetBrokerRestartSafety reports all partitions which would lose their last in-sync replica or go below
+// min.insync.replicas if the given broker was restarted. It's meant as pre-maintenance gate for operators.
+func (api *API) handleGetBrokerRestartSafety() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		brokerID, restErr := parseBrokerID(r)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		logger := api.Logger.With(zap.Int32("broker_id", brokerID))
+
+		safety, err := api.OwlSvc.CheckBrokerRestartSafety(r.Context(), brokerID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			message := "Could not check whether the broker can be restarted safely"
+			if errors.Is(err, owl.ErrBrokerNotFound) {
+				status = http.StatusNotFound
+				message = fmt.Sprintf("Broker with id '%v' does not exist", brokerID)
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  message,
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, safety)
+	}
+}
+
+// brokerConfigStatus maps errors of the broker config service methods to the according http status
+func brokerConfigStatus(err error) int {
+	switch {
+	case errors.Is(err, owl.ErrBrokerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, owl.ErrInvalidBrokerConfigRequest):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// alterBrokerConfigRequest has the same shape and validation as the topic config counterpart
+type alterBrokerConfigRequest struct {
+	alterTopicConfigRequest
+}
+
+// handleGetBrokerConfig describes all configs of a broker, including where each value comes from and whether it
+// can be altered at runtime.
+func (api *API) handleGetBrokerConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		brokerID, restErr := parseBrokerID(r)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		logger := api.Logger.With(zap.Int32("broker_id", brokerID))
+
+		canView, restErr := api.Hooks.Owl.CanViewBrokerConfig(r.Context(), brokerID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canView {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view the config of the requested broker"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view the config of this broker",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		cfg, err := api.OwlSvc.GetBrokerConfig(r.Context(), brokerID)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   brokerConfigStatus(err),
+				Message:  fmt.Sprintf("Could not describe broker config: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, cfg)
+	}
+}
+
+// handleAlterBrokerConfig sets or resets dynamic per-broker configs. With dryRun enabled the changes are validated by
+// the broker and the config keys which would change are returned, without applying them.
+func (api *API) handleAlterBrokerConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		brokerID, restErr := parseBrokerID(r)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		logger := api.Logger.With(zap.Int32("broker_id", brokerID))
+
+		var req alterBrokerConfigRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canEdit, restErr := api.Hooks.Owl.CanEditBrokerConfig(r.Context(), brokerID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canEdit {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to edit the config of the requested broker"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to edit the config of this broker",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		res, err := api.OwlSvc.AlterBrokerConfig(r.Context(), owl.AlterBrokerConfigRequest{
+			BrokerID: brokerID,
+			Configs:  req.Configs,
+			DryRun:   req.DryRun,
+		})
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   brokerConfigStatus(err),
+				Message:  fmt.Sprintf("Could not alter broker config: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+)
+
+var errCanaryDisabled = &rest.Error{
+	Err:      fmt.Errorf("the canary is disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "The canary is disabled",
+	IsSilent: true,
+}
+
+// handleGetCanaryStatus returns the latest produce and end-to-end latencies and the availability of each canary
+// partition and of the brokers which lead them
+func (api *API) handleGetCanaryStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.Canary == nil {
+			rest.SendRESTError(w, r, api.Logger, errCanaryDisabled)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, api.Canary.Status())
+	}
+}
@@ -1,10 +1,13 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/cloudhut/common/rest"
 	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"go.uber.org/zap"
 )
 
 func (api *API) handleDescribeCluster() http.HandlerFunc {
@@ -31,3 +34,152 @@ func (api *API) handleDescribeCluster() http.HandlerFunc {
 		rest.SendResponse(w, r, api.Logger, http.StatusOK, response)
 	}
 }
+
+// handleGetUpgradeReadiness analyzes the cluster for issues which block or complicate an upgrade to the Kafka
+// version given in the targetVersion query parameter.
+func (api *API) handleGetUpgradeReadiness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		targetVersion := r.URL.Query().Get("targetVersion")
+		report, err := api.OwlSvc.GetUpgradeReadinessReport(r.Context(), targetVersion)
+		if err != nil {
+			status := http.StatusInternalServerError
+			message := "Could not create upgrade readiness report"
+			if errors.Is(err, owl.ErrInvalidKafkaVersion) {
+				status = http.StatusBadRequest
+				message = fmt.Sprintf("Target version '%v' is not a valid Kafka version", targetVersion)
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  message,
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, report)
+	}
+}
+
+// handleGetClusterCapabilities reports the cluster's metadata mode (KRaft or ZooKeeper) and which features are
+// available in that mode.
+func (api *API) handleGetClusterCapabilities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		capabilities, err := api.OwlSvc.GetClusterCapabilities(r.Context())
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  "Could not detect cluster capabilities",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, capabilities)
+	}
+}
+
+// handleGetClusterHealth returns a summary of the cluster's replication state, which the frontend polls to show the
+// cluster status
+func (api *API) handleGetClusterHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health, err := api.OwlSvc.GetClusterHealth(r.Context())
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  "Could not compute cluster health",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, health)
+	}
+}
+
+// electPreferredLeadersRequest selects the partitions to elect. Omitting the topics elects all partitions of the
+// cluster, omitting a topic's partition ids elects all its partitions.
+type electPreferredLeadersRequest struct {
+	Topics []struct {
+		TopicName    string  `json:"topicName"`
+		PartitionIDs []int32 `json:"partitionIds"`
+	} `json:"topics"`
+}
+
+func (e *electPreferredLeadersRequest) OK() error {
+	for _, topic := range e.Topics {
+		if topic.TopicName == "" {
+			return fmt.Errorf("topic name is required")
+		}
+	}
+	return nil
+}
+
+// handleElectPreferredLeaders moves partition leaderships back to the preferred replicas, e.g. after broker
+// restarts, and reports which partitions changed their leader.
+func (api *API) handleElectPreferredLeaders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req electPreferredLeadersRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		canElect, restErr := api.Hooks.Owl.CanElectLeaders(r.Context(), api.clusterName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		if !canElect {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to elect leaders"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to elect partition leaders",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		electionReq := owl.LeaderElectionRequest{}
+		if len(req.Topics) > 0 {
+			electionReq.Topics = make(map[string][]int32, len(req.Topics))
+			for _, topic := range req.Topics {
+				electionReq.Topics[topic.TopicName] = append(electionReq.Topics[topic.TopicName], topic.PartitionIDs...)
+			}
+		}
+		report, err := api.OwlSvc.ElectPreferredLeaders(r.Context(), electionReq)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrInvalidLeaderElectionRequest) {
+				status = http.StatusBadRequest
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not elect preferred leaders: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		api.Logger.Info("elected preferred leaders",
+			zap.Int("elected", report.ElectedCount),
+			zap.Int("not_needed", report.NotNeededCount),
+			zap.Int("failed", report.FailedCount))
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, report)
+	}
+}
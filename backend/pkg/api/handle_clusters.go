@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+	"github.com/go-chi/chi"
+)
+
+// Cluster holds the services of an additional Kafka cluster. Connection pools, metadata caches and schema registry
+// clients are not shared across clusters.
+type Cluster struct {
+	Name string
+
+	// KafkaSvc is nil if the fake cluster is enabled for this cluster
+	KafkaSvc *kafka.Service
+	OwlSvc   *owl.Service
+
+	// SchemaSvc is nil if the schema registry has not been configured for this cluster
+	SchemaSvc *schema.Service
+
+	// lagExporter is nil if the lag exporter is disabled
+	lagExporter *lagExporter
+
+	// groupHistory is nil if the group history is disabled
+	groupHistory *groupHistoryTracker
+}
+
+// forCluster returns a copy of the API which serves the given cluster. All other dependencies such as hooks, filter
+// budgets and Kafka Connect are shared with the default cluster.
+func (api *API) forCluster(cluster *Cluster) *API {
+	clusterAPI := *api
+	clusterAPI.clusterName = cluster.Name
+	clusterAPI.KafkaSvc = cluster.KafkaSvc
+	clusterAPI.OwlSvc = cluster.OwlSvc
+	clusterAPI.SchemaSvc = cluster.SchemaSvc
+	clusterAPI.lagExporter = cluster.lagExporter
+	clusterAPI.groupHistory = cluster.groupHistory
+	clusterAPI.HeaderIndexer = nil // Only the default cluster is indexed
+	clusterAPI.FullTextIndexer = nil
+	clusterAPI.Canary = nil // Only the default cluster is probed
+
+	return &clusterAPI
+}
+
+// clusterByName returns the API which serves the given cluster, which is either the default cluster or one of the
+// additional clusters. It returns nil if no cluster with the name is configured.
+func (api *API) clusterByName(name string) *API {
+	if name == api.Cfg.ClusterName {
+		return api
+	}
+	for _, cluster := range api.Clusters {
+		if cluster.Name == name {
+			return api.forCluster(cluster)
+		}
+	}
+	return nil
+}
+
+// checkClusterPermissions rejects requests for clusters which the requester is not allowed to see
+func (api *API) checkClusterPermissions(clusterName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			canSee, restErr := api.Hooks.Owl.CanSeeCluster(r.Context(), clusterName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			if !canSee {
+				rest.SendRESTError(w, r, api.Logger, &rest.Error{
+					Err:      fmt.Errorf("requester has no permissions to see cluster '%v'", clusterName),
+					Status:   http.StatusForbidden,
+					Message:  "You don't have permissions to see this cluster",
+					IsSilent: false,
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clusterRoutes mounts all routes of the additional clusters below /clusters/{clusterName}
+func (api *API) clusterRoutes(r chi.Router) {
+	for _, cluster := range api.Clusters {
+		clusterAPI := api.forCluster(cluster)
+		r.Route(fmt.Sprintf("/clusters/%v", cluster.Name), func(r chi.Router) {
+			r.Use(api.checkClusterPermissions(cluster.Name))
+			clusterAPI.apiRoutes(r)
+		})
+	}
+}
+
+func (api *API) handleGetClusters() http.HandlerFunc {
+	type cluster struct {
+		Name       string `json:"name"`
+		ClusterID  string `json:"clusterId"` // Empty if the cluster doesn't report an id or hasn't been reached yet
+		IsDefault  bool   `json:"isDefault"`
+		PathPrefix string `json:"pathPrefix"` // Prefix of all API routes of this cluster
+	}
+	type response struct {
+		Clusters []cluster `json:"clusters"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		names := []string{api.Cfg.ClusterName}
+		owlServices := []*owl.Service{api.OwlSvc}
+		for _, c := range api.Clusters {
+			names = append(names, c.Name)
+			owlServices = append(owlServices, c.OwlSvc)
+		}
+
+		clusters := make([]cluster, 0, len(names))
+		for i, name := range names {
+			canSee, restErr := api.Hooks.Owl.CanSeeCluster(r.Context(), name)
+			if restErr != nil {
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			if !canSee {
+				continue
+			}
+
+			c := cluster{Name: name, ClusterID: owlServices[i].ClusterID(), IsDefault: i == 0, PathPrefix: "/api"}
+			if !c.IsDefault {
+				c.PathPrefix = fmt.Sprintf("/api/clusters/%v", name)
+			}
+			clusters = append(clusters, c)
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Clusters: clusters})
+	}
+}
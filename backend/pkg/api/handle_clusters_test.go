@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/canary"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// hiddenClusterHooks allows everything except seeing the hidden cluster
+type hiddenClusterHooks struct {
+	*defaultHooks
+	hidden string
+}
+
+func (h *hiddenClusterHooks) CanSeeCluster(_ context.Context, clusterName string) (bool, *rest.Error) {
+	return clusterName != h.hidden, nil
+}
+
+// newMultiClusterAPI returns an API whose default cluster "production" has the topic "orders" with 1 partition. The
+// additional clusters "staging" and "dev" have the same topic with 3 and 5 partitions.
+func newMultiClusterAPI(t *testing.T) *API {
+	newOwlSvc := func(partitions int32) *owl.Service {
+		fakeCfg := kafka.FakeConfig{}
+		fakeCfg.SetDefaults()
+		fakeCfg.Topics = 0
+		cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+		require.NoError(t, cluster.CreateTopic("orders", partitions, 1, nil, false))
+		return owl.NewService(cluster, nil, nil, nil, owl.ConsumeLimits{}, zap.NewNop())
+	}
+
+	cfg := &Config{}
+	cfg.ClusterName = "production"
+	return &API{
+		Cfg:    cfg,
+		Logger: zap.NewNop(),
+		OwlSvc: newOwlSvc(1),
+		Clusters: []*Cluster{
+			{Name: "staging", OwlSvc: newOwlSvc(3)},
+			{Name: "dev", OwlSvc: newOwlSvc(5)},
+		},
+		Hooks: newDefaultHooks(),
+	}
+}
+
+func TestClusterByName(t *testing.T) {
+	api := newMultiClusterAPI(t)
+
+	assert.Same(t, api, api.clusterByName("production"))
+	staging := api.clusterByName("staging")
+	require.NotNil(t, staging)
+	assert.Equal(t, "staging", staging.clusterName)
+	assert.Same(t, api.Clusters[0].OwlSvc, staging.OwlSvc)
+	assert.Same(t, api.Hooks, staging.Hooks, "hooks are shared with the default cluster")
+	assert.Nil(t, api.clusterByName("unknown"))
+}
+
+func TestClusterRoutes(t *testing.T) {
+	api := newMultiClusterAPI(t)
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	canaryCfg := canary.Config{Enabled: true}
+	canaryCfg.SetDefaults()
+	kafkaCanary, err := canary.NewCanary(canaryCfg, kafka.NewFakeCluster(fakeCfg, zap.NewNop()), "kowl", prometheus.NewRegistry(), zap.NewNop())
+	require.NoError(t, err)
+	api.Canary = kafkaCanary
+	api.Hooks.Owl = &hiddenClusterHooks{defaultHooks: &defaultHooks{}, hidden: "dev"}
+	router := chi.NewRouter()
+	router.Route("/api", func(r chi.Router) {
+		r.Get("/clusters", api.handleGetClusters())
+		api.clusterRoutes(r)
+		r.Get("/topics/{topicName}/partitions", api.handleGetPartitions())
+		r.Get("/cluster/canary", api.handleGetCanaryStatus())
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	partitionCount := func(rec *httptest.ResponseRecorder) int {
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var res struct {
+			Partitions []owl.TopicPartition `json:"partitions"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return len(res.Partitions)
+	}
+
+	// Each cluster's routes are served by its own owl service
+	assert.Equal(t, 1, partitionCount(get("/api/topics/orders/partitions")))
+	assert.Equal(t, 3, partitionCount(get("/api/clusters/staging/topics/orders/partitions")))
+	assert.Equal(t, http.StatusNotFound, get("/api/clusters/unknown/topics/orders/partitions").Code)
+
+	// The canary only probes the default cluster, its status must not be reported for other clusters
+	assert.Equal(t, http.StatusOK, get("/api/cluster/canary").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/clusters/staging/cluster/canary").Code)
+
+	// Clusters which the requester can't see are rejected and not listed
+	rec := get("/api/clusters/dev/topics/orders/partitions")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = get("/api/clusters")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res struct {
+		Clusters []struct {
+			Name       string `json:"name"`
+			IsDefault  bool   `json:"isDefault"`
+			PathPrefix string `json:"pathPrefix"`
+		} `json:"clusters"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Len(t, res.Clusters, 2)
+	assert.Equal(t, "production", res.Clusters[0].Name)
+	assert.True(t, res.Clusters[0].IsDefault)
+	assert.Equal(t, "/api", res.Clusters[0].PathPrefix)
+	assert.Equal(t, "staging", res.Clusters[1].Name)
+	assert.Equal(t, "/api/clusters/staging", res.Clusters[1].PathPrefix)
+}
@@ -0,0 +1,314 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/connect"
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// connectClient returns the client for the cluster in the URL after checking the requester's permissions
+func (api *API) connectClient(r *http.Request, requireEdit bool) (*connect.Client, *rest.Error) {
+	clusterName := chi.URLParam(r, "clusterName")
+	if api.ConnectSvc == nil {
+		return nil, &rest.Error{
+			Err:      fmt.Errorf("kafka connect is not configured"),
+			Status:   http.StatusNotFound,
+			Message:  "Kafka Connect is not configured",
+			IsSilent: true,
+		}
+	}
+
+	client, err := api.ConnectSvc.Client(clusterName)
+	if err != nil {
+		return nil, &rest.Error{
+			Err:      err,
+			Status:   http.StatusNotFound,
+			Message:  fmt.Sprintf("Kafka Connect cluster '%v' does not exist", clusterName),
+			IsSilent: true,
+		}
+	}
+
+	isAllowed, restErr := api.Hooks.Owl.CanViewConnectCluster(r.Context(), clusterName)
+	if restErr == nil && isAllowed && requireEdit {
+		isAllowed, restErr = api.Hooks.Owl.CanEditConnectCluster(r.Context(), clusterName)
+	}
+	if restErr != nil {
+		return nil, restErr
+	}
+	if !isAllowed {
+		return nil, &rest.Error{
+			Err:      fmt.Errorf("requester has no permissions for kafka connect cluster '%v'", clusterName),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions for this action in the Kafka Connect cluster",
+			IsSilent: false,
+		}
+	}
+
+	return client, nil
+}
+
+// connectError converts errors from Kafka Connect into rest errors. Status codes returned by Kafka Connect (e.g. 404
+// for unknown connectors or 409 during rebalances) are passed through, while the circuit breaker is open 503 is
+// returned.
+func connectError(err error, message string) *rest.Error {
+	status := http.StatusInternalServerError
+	var connectErr *connect.RestError
+	switch {
+	case errors.As(err, &connectErr) && connectErr.StatusCode < http.StatusInternalServerError:
+		status = connectErr.StatusCode
+	case errors.Is(err, resilience.ErrCircuitOpen):
+		status = http.StatusServiceUnavailable
+	}
+
+	return &rest.Error{
+		Err:      err,
+		Status:   status,
+		Message:  fmt.Sprintf("%v: %v", message, err.Error()),
+		IsSilent: false,
+	}
+}
+
+func (api *API) handleGetConnectClusters() http.HandlerFunc {
+	type connectCluster struct {
+		ClusterName string                                     `json:"clusterName"`
+		Connectors  map[string]connect.ConnectorInfoWithStatus `json:"connectors"`
+		Error       string                                     `json:"error,omitempty"`
+	}
+	type response struct {
+		Clusters []connectCluster `json:"clusters"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := response{Clusters: make([]connectCluster, 0)}
+		if api.ConnectSvc == nil {
+			rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+			return
+		}
+
+		for _, clusterName := range api.ConnectSvc.ClusterNames() {
+			canView, restErr := api.Hooks.Owl.CanViewConnectCluster(r.Context(), clusterName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			if !canView {
+				continue
+			}
+
+			// A single unreachable cluster should not prevent the others from being shown
+			cluster := connectCluster{ClusterName: clusterName}
+			client, _ := api.ConnectSvc.Client(clusterName)
+			connectors, err := client.ListConnectors(r.Context())
+			if err != nil {
+				api.Logger.Warn("failed to list connectors", zap.String("cluster_name", clusterName), zap.Error(err))
+				cluster.Error = err.Error()
+			}
+			cluster.Connectors = connectors
+			res.Clusters = append(res.Clusters, cluster)
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
+
+func (api *API) handleGetConnectors() http.HandlerFunc {
+	type response struct {
+		Connectors []connect.ConnectorInfoWithStatus `json:"connectors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, restErr := api.connectClient(r, false)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		connectors, err := client.ListConnectors(r.Context())
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, connectError(err, "Could not list connectors"))
+			return
+		}
+
+		res := response{Connectors: make([]connect.ConnectorInfoWithStatus, 0, len(connectors))}
+		for _, c := range connectors {
+			res.Connectors = append(res.Connectors, c)
+		}
+		sort.Slice(res.Connectors, func(i, j int) bool { return res.Connectors[i].Info.Name < res.Connectors[j].Info.Name })
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
+
+func (api *API) handleGetConnector() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, restErr := api.connectClient(r, false)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		connector := chi.URLParam(r, "connector")
+
+		info, err := client.GetConnectorInfo(r.Context(), connector)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, connectError(err, "Could not get connector"))
+			return
+		}
+		status, err := client.GetConnectorStatus(r.Context(), connector)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, connectError(err, "Could not get connector status"))
+			return
+		}
+
+		res := connect.ConnectorInfoWithStatus{Info: *info, Status: *status}
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
+
+type putConnectorConfigRequest struct {
+	Config       map[string]string `json:"config"`
+	ValidateOnly bool              `json:"validateOnly"`
+}
+
+func (p *putConnectorConfigRequest) OK() error {
+	if len(p.Config) == 0 {
+		return fmt.Errorf("config must not be empty")
+	}
+	if p.Config["connector.class"] == "" {
+		return fmt.Errorf("config must contain 'connector.class'")
+	}
+
+	return nil
+}
+
+// handlePutConnectorConfig validates the submitted config with the connector plugin and only applies it if there
+// are no validation errors. With validateOnly the validation result is returned without applying the config.
+func (api *API) handlePutConnectorConfig() http.HandlerFunc {
+	type response struct {
+		Validation *connect.ConfigValidationResult `json:"validation"`
+		Connector  *connect.ConnectorInfo          `json:"connector"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req putConnectorConfigRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		client, restErr := api.connectClient(r, true)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		connector := chi.URLParam(r, "connector")
+
+		// The validate endpoint requires the connector's name as part of the config
+		config := make(map[string]string, len(req.Config)+1)
+		for k, v := range req.Config {
+			config[k] = v
+		}
+		config["name"] = connector
+
+		validation, err := client.ValidateConnectorConfig(r.Context(), config)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, connectError(err, "Could not validate connector config"))
+			return
+		}
+		if req.ValidateOnly || validation.ErrorCount > 0 {
+			status := http.StatusOK
+			if validation.ErrorCount > 0 {
+				status = http.StatusBadRequest
+			}
+			rest.SendResponse(w, r, api.Logger, status, response{Validation: validation})
+			return
+		}
+
+		info, err := client.PutConnectorConfig(r.Context(), connector, req.Config)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, connectError(err, "Could not apply connector config"))
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Validation: validation, Connector: info})
+	}
+}
+
+// Connector actions
+const (
+	connectorActionPause   = "pause"
+	connectorActionResume  = "resume"
+	connectorActionRestart = "restart"
+)
+
+func (api *API) handleConnectorAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, restErr := api.connectClient(r, true)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		connector := chi.URLParam(r, "connector")
+
+		var err error
+		switch action {
+		case connectorActionPause:
+			err = client.PauseConnector(r.Context(), connector)
+		case connectorActionResume:
+			err = client.ResumeConnector(r.Context(), connector)
+		case connectorActionRestart:
+			err = client.RestartConnector(r.Context(), connector)
+		}
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, connectError(err, fmt.Sprintf("Could not %v connector", action)))
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, struct{}{})
+	}
+}
+
+func (api *API) handleRestartConnectorTask() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, restErr := api.connectClient(r, true)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		connector := chi.URLParam(r, "connector")
+
+		taskIDStr := chi.URLParam(r, "taskID")
+		taskID, err := strconv.Atoi(taskIDStr)
+		if err != nil || taskID < 0 {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("failed to parse task id '%v'", taskIDStr),
+				Status:   http.StatusBadRequest,
+				Message:  "Task ID must be a valid, non negative integer",
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		err = client.RestartTask(r.Context(), connector, taskID)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, connectError(err, "Could not restart connector task"))
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, struct{}{})
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/templates"
+)
+
+func (api *API) handleGetConsumeTemplates() http.HandlerFunc {
+	type response struct {
+		Templates []*templates.ConsumeTemplate `json:"templates"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, restErr := api.Hooks.Owl.RequesterRoles(r.Context())
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		res := response{
+			Templates: api.TemplatesSvc.ConsumeTemplatesForRoles(roles),
+		}
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
+
+// getConsumeTemplate returns the consume template with the given name if the logged in user has been assigned to
+// it and if it may be used for the given topic.
+func (api *API) getConsumeTemplate(ctx context.Context, templateName string, topicName string) (*templates.ConsumeTemplate, *rest.Error) {
+	template, exists := api.TemplatesSvc.ConsumeTemplate(templateName)
+	if !exists {
+		return nil, &rest.Error{
+			Err:      fmt.Errorf("consume template '%v' does not exist", templateName),
+			Status:   http.StatusNotFound,
+			Message:  fmt.Sprintf("Consume template '%v' does not exist", templateName),
+			IsSilent: false,
+		}
+	}
+
+	roles, restErr := api.Hooks.Owl.RequesterRoles(ctx)
+	if restErr != nil {
+		return nil, restErr
+	}
+	if !template.IsAssignedTo(roles) {
+		return nil, &rest.Error{
+			Err:      fmt.Errorf("requester is not assigned to consume template '%v'", templateName),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions to use this consume template",
+			IsSilent: false,
+		}
+	}
+
+	if !template.MatchesTopic(topicName) {
+		return nil, &rest.Error{
+			Err:      fmt.Errorf("consume template '%v' can not be used for topic '%v'", templateName, topicName),
+			Status:   http.StatusBadRequest,
+			Message:  fmt.Sprintf("Consume template '%v' can not be used for topic '%v'", templateName, topicName),
+			IsSilent: false,
+		}
+	}
+
+	return template, nil
+}
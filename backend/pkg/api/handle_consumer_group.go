@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/cloudhut/common/rest"
 	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
 )
 
 // GetConsumerGroupsResponse represents the data which is returned for listing topics
@@ -37,6 +43,7 @@ func (api *API) handleGetConsumerGroups() http.HandlerFunc {
 			if canSee {
 				visibleGroups = append(visibleGroups, group)
 			}
+			api.resolveGroupMembers(group.Members)
 
 			// Attach allowed actions for each topic
 			group.AllowedActions, restErr = api.Hooks.Owl.AllowedConsumerGroupActions(r.Context(), group.GroupID)
@@ -52,3 +59,491 @@ func (api *API) handleGetConsumerGroups() http.HandlerFunc {
 		rest.SendResponse(w, r, api.Logger, http.StatusOK, response)
 	}
 }
+
+// handleGetGroupCoordinators returns which broker coordinates which consumer groups along with the health of the
+// offsets topic partitions. Groups the requester can not see are omitted from the group list.
+func (api *API) handleGetGroupCoordinators() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		overview, err := api.OwlSvc.GetGroupCoordinatorOverview(r.Context())
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  "Could not get the group coordinator overview",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		visibleGroups := make([]owl.GroupCoordinatorLookup, 0, len(overview.Groups))
+		for _, group := range overview.Groups {
+			canSee, restErr := api.Hooks.Owl.CanSeeConsumerGroup(r.Context(), group.GroupID)
+			if restErr != nil {
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			if canSee {
+				visibleGroups = append(visibleGroups, group)
+			}
+		}
+		overview.Groups = visibleGroups
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, overview)
+	}
+}
+
+// timeLagTimeout bounds the time we wait for the messages at the group offsets to estimate the time lag
+const timeLagTimeout = 10 * time.Second
+
+// handleGetConsumerGroupTimeLag estimates how far the group is behind in time for each partition it consumes
+func (api *API) handleGetConsumerGroupTimeLag() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "groupId")
+		logger := api.Logger.With(zap.String("group_id", groupID))
+
+		canSee, restErr := api.Hooks.Owl.CanSeeConsumerGroup(r.Context(), groupID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canSee {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to see the requested consumer group"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to see this consumer group",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeLagTimeout)
+		defer cancel()
+		res, err := api.OwlSvc.GetConsumerGroupTimeLag(ctx, groupID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrConsumerGroupNotFound) {
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not estimate the consumer group time lag: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+// errLagExporterDisabled is returned by the lag history endpoint, whose samples are collected by the lag exporter
+var errLagExporterDisabled = &rest.Error{
+	Err:      fmt.Errorf("lag exporter is disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "The lag history is not available because the lag exporter is disabled",
+	IsSilent: true,
+}
+
+// handleGetConsumerGroupLagHistory returns the group's lags which have been collected by the lag exporter within the
+// history retention
+func (api *API) handleGetConsumerGroupLagHistory() http.HandlerFunc {
+	type response struct {
+		GroupID    string      `json:"groupId"`
+		IntervalMs int64       `json:"intervalMs"`
+		Samples    []lagSample `json:"samples"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "groupId")
+		logger := api.Logger.With(zap.String("group_id", groupID))
+		if api.lagExporter == nil {
+			rest.SendRESTError(w, r, logger, errLagExporterDisabled)
+			return
+		}
+
+		canSee, restErr := api.Hooks.Owl.CanSeeConsumerGroup(r.Context(), groupID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canSee {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to see the requested consumer group"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to see this consumer group",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, response{
+			GroupID:    groupID,
+			IntervalMs: api.Cfg.LagExporter.Interval.Milliseconds(),
+			Samples:    api.lagExporter.History(groupID),
+		})
+	}
+}
+
+// errGroupHistoryDisabled is returned by the group timeline endpoint, whose events are recorded by the group history
+var errGroupHistoryDisabled = &rest.Error{
+	Err:      fmt.Errorf("group history is disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "The group timeline is not available because the group history is disabled",
+	IsSilent: true,
+}
+
+// handleGetConsumerGroupTimeline returns the group's state transitions, joining and leaving members and rebalances
+// within the retention. If the lag exporter is enabled, the lag samples are included, so that lag spikes can be
+// correlated with rebalances.
+func (api *API) handleGetConsumerGroupTimeline() http.HandlerFunc {
+	type response struct {
+		GroupID          string       `json:"groupId"`
+		PollIntervalMs   int64        `json:"pollIntervalMs"`
+		Events           []groupEvent `json:"events"`
+		LagSamples       []lagSample  `json:"lagSamples"`
+		IsLagHistoryKept bool         `json:"isLagHistoryKept"` // False if the lag exporter is disabled
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "groupId")
+		logger := api.Logger.With(zap.String("group_id", groupID))
+		if api.groupHistory == nil {
+			rest.SendRESTError(w, r, logger, errGroupHistoryDisabled)
+			return
+		}
+
+		canSee, restErr := api.Hooks.Owl.CanSeeConsumerGroup(r.Context(), groupID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canSee {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to see the requested consumer group"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to see this consumer group",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		res := response{
+			GroupID:        groupID,
+			PollIntervalMs: api.Cfg.GroupHistory.Interval.Milliseconds(),
+			Events:         api.groupHistory.Events(groupID),
+			LagSamples:     make([]lagSample, 0),
+		}
+		if api.lagExporter != nil {
+			res.LagSamples = api.lagExporter.History(groupID)
+			res.IsLagHistoryKept = true
+		}
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+// handleGetConsumerGroupMembers returns the group's coordinator and the client host, IP and software of each member
+func (api *API) handleGetConsumerGroupMembers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "groupId")
+		logger := api.Logger.With(zap.String("group_id", groupID))
+
+		canSee, restErr := api.Hooks.Owl.CanSeeConsumerGroup(r.Context(), groupID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canSee {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to see the requested consumer group"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to see this consumer group",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		res, err := api.OwlSvc.GetConsumerGroupMembers(ctx, groupID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrConsumerGroupNotFound) {
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not describe the consumer group members: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		api.resolveGroupMemberHosts(res.Members)
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+type resetConsumerGroupOffsetsRequest struct {
+	Topics []owl.ResetOffsetsTopic `json:"topics"`
+	DryRun bool                    `json:"dryRun"`
+}
+
+func (r *resetConsumerGroupOffsetsRequest) OK() error {
+	if len(r.Topics) == 0 {
+		return fmt.Errorf("at least one topic must be given")
+	}
+
+	for _, t := range r.Topics {
+		if t.TopicName == "" {
+			return fmt.Errorf("topic name is required")
+		}
+		switch t.Strategy {
+		case owl.OffsetResetEarliest, owl.OffsetResetLatest:
+		case owl.OffsetResetOffset:
+			if t.Offset < 0 {
+				return fmt.Errorf("offset for topic '%v' must not be negative", t.TopicName)
+			}
+		case owl.OffsetResetTimestamp:
+			if t.Timestamp < 0 {
+				return fmt.Errorf("timestamp for topic '%v' must not be negative", t.TopicName)
+			}
+		default:
+			return fmt.Errorf("unknown strategy '%v' for topic '%v'", t.Strategy, t.TopicName)
+		}
+	}
+
+	return nil
+}
+
+func (api *API) handleResetConsumerGroupOffsets() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "groupId")
+		logger := api.Logger.With(zap.String("group_id", groupID))
+
+		var req resetConsumerGroupOffsetsRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// Check if logged in user is allowed to reset the offsets of the given group
+		canReset, restErr := api.Hooks.Owl.CanResetConsumerGroupOffsets(r.Context(), groupID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canReset {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to reset offsets of the requested consumer group"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to reset the offsets of this consumer group",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		resetReq := owl.ResetConsumerGroupOffsetsRequest{
+			GroupID: groupID,
+			Topics:  req.Topics,
+			DryRun:  req.DryRun,
+		}
+		res, err := api.OwlSvc.ResetConsumerGroupOffsets(r.Context(), resetReq)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrGroupNotEmpty) {
+				status = http.StatusConflict
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not reset consumer group offsets: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+// handleExportConsumerGroupOffsets returns a document with the group's committed offsets, which can be imported again
+// for a group of this or another cluster. The optional, repeatable topic parameter limits the exported topics.
+func (api *API) handleExportConsumerGroupOffsets() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "groupId")
+		logger := api.Logger.With(zap.String("group_id", groupID))
+
+		canSee, restErr := api.Hooks.Owl.CanSeeConsumerGroup(r.Context(), groupID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canSee {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to see the requested consumer group"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to see this consumer group",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		res, err := api.OwlSvc.ExportConsumerGroupOffsets(groupID, r.URL.Query()["topic"])
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrConsumerGroupNotFound) {
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not export consumer group offsets: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+type importConsumerGroupOffsetsRequest struct {
+	Document              owl.GroupOffsetsDocument `json:"document"`
+	DryRun                bool                     `json:"dryRun"`
+	SkipMissingPartitions bool                     `json:"skipMissingPartitions"`
+}
+
+func (r *importConsumerGroupOffsetsRequest) OK() error {
+	return r.Document.Validate()
+}
+
+// handleImportConsumerGroupOffsets commits the offsets of an exported document for the group in the URL, which may
+// differ from the group the document has been exported from
+func (api *API) handleImportConsumerGroupOffsets() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "groupId")
+		logger := api.Logger.With(zap.String("group_id", groupID))
+
+		var req importConsumerGroupOffsetsRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// Importing offsets overrides the group's offsets just like a reset does
+		canReset, restErr := api.Hooks.Owl.CanResetConsumerGroupOffsets(r.Context(), groupID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canReset {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to import offsets of the requested consumer group"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to import the offsets of this consumer group",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		importReq := owl.ImportConsumerGroupOffsetsRequest{
+			GroupID:               groupID,
+			Document:              req.Document,
+			DryRun:                req.DryRun,
+			SkipMissingPartitions: req.SkipMissingPartitions,
+		}
+		res, err := api.OwlSvc.ImportConsumerGroupOffsets(r.Context(), importReq)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, owl.ErrGroupNotEmpty):
+				status = http.StatusConflict
+			case errors.Is(err, owl.ErrInvalidOffsetsDocument):
+				status = http.StatusBadRequest
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not import consumer group offsets: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+// handleDeleteConsumerGroup deletes a consumer group without active members, so that stale groups can be cleaned up
+func (api *API) handleDeleteConsumerGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "groupId")
+		logger := api.Logger.With(zap.String("group_id", groupID))
+
+		canDelete, restErr := api.Hooks.Owl.CanDeleteConsumerGroup(r.Context(), groupID)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canDelete {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to delete the requested consumer group"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to delete this consumer group",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		err := api.OwlSvc.DeleteConsumerGroup(r.Context(), groupID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, owl.ErrConsumerGroupNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, owl.ErrGroupNotEmpty):
+				status = http.StatusConflict
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not delete consumer group: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
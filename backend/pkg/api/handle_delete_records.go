@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type deleteRecordsRequest struct {
+	Strategy owl.DeleteRecordsStrategy `json:"strategy"`
+
+	// Partitions are only used for the offset strategy. All records before the offset are deleted, an offset of -1
+	// deletes all records of the partition.
+	Partitions []deleteRecordsPartition `json:"partitions"`
+
+	// Timestamp (unix milliseconds) and PartitionIDs are only used for the timestamp strategy, all partitions are
+	// truncated if no partition ids are given
+	Timestamp    int64   `json:"timestamp"`
+	PartitionIDs []int32 `json:"partitionIds"`
+
+	// DryRun only returns the records which would be deleted. Requests which are no dry run must confirm the number
+	// of records to delete, as returned by the dry run.
+	DryRun               bool   `json:"dryRun"`
+	ConfirmedRecordCount *int64 `json:"confirmedRecordCount"`
+}
+
+type deleteRecordsPartition struct {
+	PartitionID int32 `json:"partitionId"`
+	Offset      int64 `json:"offset"`
+}
+
+func (d *deleteRecordsRequest) OK() error {
+	switch d.Strategy {
+	case owl.DeleteRecordsOffset:
+		if len(d.Partitions) == 0 {
+			return fmt.Errorf("at least one partition must be given")
+		}
+		seen := make(map[int32]bool, len(d.Partitions))
+		for _, p := range d.Partitions {
+			if seen[p.PartitionID] {
+				return fmt.Errorf("partition '%v' is given more than once", p.PartitionID)
+			}
+			seen[p.PartitionID] = true
+			if p.Offset < -1 {
+				return fmt.Errorf("offset for partition '%v' must be -1 or greater", p.PartitionID)
+			}
+		}
+	case owl.DeleteRecordsTimestamp:
+		if d.Timestamp < 0 {
+			return fmt.Errorf("timestamp must not be negative")
+		}
+	default:
+		return fmt.Errorf("unknown strategy '%v'", d.Strategy)
+	}
+
+	if !d.DryRun && d.ConfirmedRecordCount == nil {
+		return fmt.Errorf("confirmed record count must be set to delete records, run a dry run first to preview the records which would be deleted")
+	}
+
+	return nil
+}
+
+// handleDeleteRecords deletes all records of a topic's partitions before an offset or timestamp, e.g. to skip
+// poisoned messages. A dry run returns how many records would be deleted, which must be confirmed by the actual
+// request.
+func (api *API) handleDeleteRecords() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		var req deleteRecordsRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canDelete, restErr := api.Hooks.Owl.CanDeleteTopicRecords(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canDelete {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to delete records of the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to delete records of this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		deleteReq := owl.DeleteRecordsRequest{
+			TopicName:    topicName,
+			Strategy:     req.Strategy,
+			Timestamp:    req.Timestamp,
+			PartitionIDs: req.PartitionIDs,
+			DryRun:       req.DryRun,
+		}
+		if req.Strategy == owl.DeleteRecordsOffset {
+			deleteReq.Offsets = make(map[int32]int64, len(req.Partitions))
+			for _, p := range req.Partitions {
+				deleteReq.Offsets[p.PartitionID] = p.Offset
+			}
+		}
+		if req.ConfirmedRecordCount != nil {
+			deleteReq.ConfirmedRecordCount = *req.ConfirmedRecordCount
+		}
+		res, err := api.OwlSvc.DeleteRecords(r.Context(), deleteReq)
+		if err != nil {
+			status := topicManagementStatus(err)
+			if errors.Is(err, owl.ErrDeleteRecordsPreviewOutdated) {
+				status = http.StatusConflict
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not delete records: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
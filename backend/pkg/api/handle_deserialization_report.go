@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDeserializationSampleSize = 20
+	maxDeserializationSampleSize     = 100
+
+	// deserializationReportTimeout bounds the sampling, the report is based on the messages consumed until then
+	deserializationReportTimeout = 15 * time.Second
+)
+
+// parseSampleSize parses the optional query parameter sampleSize
+func parseSampleSize(str string) (int64, error) {
+	if str == "" {
+		return defaultDeserializationSampleSize, nil
+	}
+	sampleSize, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || sampleSize < 1 || sampleSize > maxDeserializationSampleSize {
+		return 0, fmt.Errorf("sample size must be between 1 and %v", maxDeserializationSampleSize)
+	}
+	return sampleSize, nil
+}
+
+// handleGetDeserializationReport samples the most recent messages of a topic and reports which decoders have been
+// tried for their keys and values and why they failed, e.g. to find out why a topic is rendered as base64. Byte
+// level previews are omitted if masking rules apply to the requester.
+func (api *API) handleGetDeserializationReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		sampleSize, err := parseSampleSize(r.URL.Query().Get("sampleSize"))
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		decrypter, restErr := api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		masker, restErr := api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		api.Hooks.Owl.PrintListMessagesAuditLog(r, &owl.ListMessageRequest{
+			TopicName:    topicName,
+			PartitionID:  -1,
+			StartOffset:  owl.StartOffsetRecent,
+			MessageCount: sampleSize,
+		})
+
+		ctx, cancel := context.WithTimeout(r.Context(), deserializationReportTimeout)
+		defer cancel()
+		report, err := api.OwlSvc.GetDeserializationReport(ctx, owl.DeserializationReportRequest{
+			TopicName:      topicName,
+			SampleSize:     sampleSize,
+			RedactPreviews: masker != nil,
+			Decrypter:      decrypter,
+		})
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   topicManagementStatus(err),
+				Message:  fmt.Sprintf("Could not create deserialization report: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, report)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// handleGetBrokerDiskUsage returns the summed size of all replicas on each broker and each of its log dirs
+func (api *API) handleGetBrokerDiskUsage() http.HandlerFunc {
+	type response struct {
+		Brokers []owl.BrokerDiskUsage `json:"brokers"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		brokers, err := api.OwlSvc.GetBrokerDiskUsage(r.Context())
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  fmt.Sprintf("Could not describe the log dirs of the brokers: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Brokers: brokers})
+	}
+}
+
+// handleGetTopicSizes returns the disk usage of all topics which the requester can see, the largest topic first
+func (api *API) handleGetTopicSizes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sizes, err := api.OwlSvc.GetTopicSizes(r.Context())
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  fmt.Sprintf("Could not describe the log dirs of the brokers: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		visible := make([]owl.TopicDiskUsage, 0, len(sizes.Topics))
+		for _, topic := range sizes.Topics {
+			canSee, restErr := api.Hooks.Owl.CanSeeTopic(r.Context(), topic.TopicName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			if canSee {
+				visible = append(visible, topic)
+			}
+		}
+		sizes.Topics = visible
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, sizes)
+	}
+}
+
+// handleGetTopicDiskUsage returns the placement and size of each replica of the topic's partitions
+func (api *API) handleGetTopicDiskUsage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		canView, restErr := api.Hooks.Owl.CanViewTopicPartitions(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canView {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view partitions for the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view partitions for that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		usage, err := api.OwlSvc.GetTopicDiskUsage(r.Context(), topicName)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrTopicNotFound) {
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not describe the disk usage of the topic: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, usage)
+	}
+}
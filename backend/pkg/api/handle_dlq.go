@@ -0,0 +1,271 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+var errDLQDisabled = &rest.Error{
+	Err:      fmt.Errorf("dlq support is disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "Dead letter queue support is disabled",
+	IsSilent: true,
+}
+
+// handleGetTopicDLQ returns whether the topic is a dead letter queue (DLQ) and which source topic it belongs to
+// according to the configured naming conventions, along with the existing DLQs of the topic itself
+func (api *API) handleGetTopicDLQ() http.HandlerFunc {
+	type response struct {
+		TopicName string `json:"topicName"`
+		IsDLQ     bool   `json:"isDlq"`
+
+		// SourceTopic is empty unless the topic is a DLQ
+		SourceTopic string   `json:"sourceTopic,omitempty"`
+		DLQTopics   []string `json:"dlqTopics"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+		if api.DLQResolver == nil {
+			rest.SendRESTError(w, r, logger, errDLQDisabled)
+			return
+		}
+
+		canSee, restErr := api.Hooks.Owl.CanSeeTopic(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canSee {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to see the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to see this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		topics, err := api.OwlSvc.GetTopicsOverview()
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  "Could not list topics from Kafka cluster",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		topicNames := make([]string, len(topics))
+		for i, topic := range topics {
+			topicNames[i] = topic.TopicName
+		}
+
+		res := response{TopicName: topicName, DLQTopics: make([]string, 0)}
+		res.SourceTopic, res.IsDLQ = api.DLQResolver.SourceTopic(topicName)
+		for _, dlqTopic := range api.DLQResolver.DLQTopics(topicName, topicNames) {
+			canSee, restErr := api.Hooks.Owl.CanSeeTopic(r.Context(), dlqTopic)
+			if restErr != nil {
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+			if canSee {
+				res.DLQTopics = append(res.DLQTopics, dlqTopic)
+			}
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+type reprocessDLQMessagesRequest struct {
+	Messages []owl.MessagePosition `json:"messages"`
+
+	// IsolationLevel is either read_uncommitted (default) or read_committed
+	IsolationLevel string `json:"isolationLevel"`
+
+	// PreservePartitions produces each message to the partition it originates from, otherwise the partition is
+	// chosen by the hash of the message's key
+	PreservePartitions bool `json:"preservePartitions"`
+
+	// Transactional produces the messages in chunks, each within a single transaction. ChunkSize is optional and
+	// capped by the configured maximum of replays.
+	Transactional bool `json:"transactional"`
+	ChunkSize     int  `json:"chunkSize"`
+
+	// DryRun only returns the origins of the messages. Requests which are no dry run must confirm the source topic
+	// to which the messages are reprocessed, as returned by the dry run.
+	DryRun               bool   `json:"dryRun"`
+	ConfirmedSourceTopic string `json:"confirmedSourceTopic"`
+}
+
+func (req *reprocessDLQMessagesRequest) OK() error {
+	if len(req.Messages) == 0 {
+		return fmt.Errorf("at least one message must be selected")
+	}
+	seen := make(map[owl.MessagePosition]bool, len(req.Messages))
+	for _, m := range req.Messages {
+		if seen[m] {
+			return fmt.Errorf("message at partition '%v' and offset '%v' is selected more than once", m.PartitionID, m.Offset)
+		}
+		seen[m] = true
+		if m.PartitionID < 0 || m.Offset < 0 {
+			return fmt.Errorf("partition ids and offsets must not be negative")
+		}
+	}
+
+	if _, err := parseIsolationLevel(req.IsolationLevel); err != nil {
+		return err
+	}
+
+	if req.ChunkSize < 0 {
+		return fmt.Errorf("chunk size must not be negative")
+	}
+
+	if !req.DryRun && req.ConfirmedSourceTopic == "" {
+		return fmt.Errorf("confirmed source topic must be set to reprocess messages, run a dry run first to preview the origins of the messages")
+	}
+
+	return nil
+}
+
+// handleReprocessDLQMessages produces selected messages of a dead letter queue back to the topic they originate from.
+// The origin is read from the messages' headers or derived from the DLQ's name. A dry run returns the origins, which
+// must be confirmed by the actual request.
+func (api *API) handleReprocessDLQMessages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+		if api.DLQResolver == nil {
+			rest.SendRESTError(w, r, logger, errDLQDisabled)
+			return
+		}
+
+		var req reprocessDLQMessagesRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if len(req.Messages) > api.Cfg.DLQ.MaxReprocessMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("too many messages have been selected"),
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("At most %v messages can be reprocessed at once", api.Cfg.DLQ.MaxReprocessMessages),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// Messages are reprocessed as they have been consumed, which would reveal the values that are masked for the
+		// requester in the source topic
+		masker, restErr := api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if masker != nil {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester can't reprocess messages of a topic whose messages are masked for them"),
+				Status:   http.StatusForbidden,
+				Message:  "You can't reprocess messages of this topic, because some of their fields are masked for you",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// The confirmed source topic is checked against the resolved one by the owl service, permissions are checked
+		// before any message is produced
+		if !req.DryRun {
+			canPublish, restErr := api.Hooks.Owl.CanPublishTopicMessages(r.Context(), req.ConfirmedSourceTopic)
+			if restErr != nil {
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+			if !canPublish {
+				restErr := &rest.Error{
+					Err:      fmt.Errorf("requester has no permissions to publish messages to the source topic"),
+					Status:   http.StatusForbidden,
+					Message:  "You don't have permissions to publish messages to the source topic",
+					IsSilent: false,
+				}
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+		}
+
+		chunkSize := api.Cfg.Replay.MaxChunkSize
+		if req.ChunkSize > 0 && req.ChunkSize < chunkSize {
+			chunkSize = req.ChunkSize
+		}
+
+		reprocessReq := owl.ReprocessRequest{
+			DLQTopic:             topicName,
+			Messages:             req.Messages,
+			Resolver:             api.DLQResolver,
+			PreservePartitions:   req.PreservePartitions,
+			Transactional:        req.Transactional,
+			ChunkSize:            chunkSize,
+			DryRun:               req.DryRun,
+			ConfirmedSourceTopic: req.ConfirmedSourceTopic,
+		}
+		reprocessReq.IsolationLevel, _ = parseIsolationLevel(req.IsolationLevel) // Checked in OK()
+
+		ctx, cancel := context.WithTimeout(r.Context(), api.Cfg.Replay.Timeout)
+		defer cancel()
+		res, err := api.OwlSvc.ReprocessDLQMessages(ctx, reprocessReq)
+		if err != nil {
+			status := topicManagementStatus(err)
+			switch {
+			case errors.Is(err, owl.ErrReprocessPreviewOutdated):
+				status = http.StatusConflict
+			case errors.Is(err, owl.ErrMessageNotFound):
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not reprocess messages: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/fulltext"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFullTextSearchLimit = 100
+	maxFullTextSearchLimit     = 10000
+)
+
+var errFullTextIndexDisabled = &rest.Error{
+	Err:      fmt.Errorf("full-text index is disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "The full-text index is disabled for this cluster",
+	IsSilent: true,
+}
+
+// handleFullTextSearch returns the partitions and offsets of all indexed records which contain all words of the
+// query parameter 'q'. Only topics which have been opted in to the full-text index can be searched.
+func (api *API) handleFullTextSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		if api.FullTextIndexer == nil {
+			rest.SendRESTError(w, r, logger, errFullTextIndexDisabled)
+			return
+		}
+
+		canView, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canView {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// The index contains unmasked values, searching it would reveal the masked fields
+		masker, restErr := api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if masker != nil {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester sees masked messages of the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "Full-text search is not available because messages of this topic are masked for you",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		limit, err := parseUsageReportParam(r, "limit", defaultFullTextSearchLimit, maxFullTextSearchLimit)
+		if err != nil {
+			rest.SendRESTError(w, r, logger, usageReportParamError(err))
+			return
+		}
+
+		res, err := api.FullTextIndexer.Search(topicName, r.URL.Query().Get("q"), limit)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, fulltext.ErrTopicNotIndexed):
+				status = http.StatusNotFound
+			case errors.Is(err, fulltext.ErrEmptyQuery):
+				status = http.StatusBadRequest
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not search topic: %v", err.Error()),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
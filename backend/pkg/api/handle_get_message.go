@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/usage"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// handleGetMessage returns a single message identified by its partition and offset with its full value, e.g. to
+// show a message whose value has been truncated in the message viewer. Masking and rendering are applied like for
+// listed messages.
+func (api *API) handleGetMessage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		partitionID, offset, restErr := parseMessageCoordinates(r)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		getReq := owl.GetMessageRequest{
+			TopicName:   topicName,
+			PartitionID: partitionID,
+			Offset:      offset,
+			Renderer:    api.RenderingSvc.Renderer(topicName),
+		}
+		var err error
+		getReq.IsolationLevel, err = parseIsolationLevel(r.URL.Query().Get("isolationLevel"))
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		getReq.BinaryEncoding = kafka.BinaryEncoding(r.URL.Query().Get("binaryEncoding"))
+		if err := getReq.BinaryEncoding.Validate(); err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		getReq.Decrypter, restErr = api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		getReq.Masker, restErr = api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		api.Hooks.Owl.PrintListMessagesAuditLog(r, &owl.ListMessageRequest{
+			TopicName:    topicName,
+			PartitionID:  partitionID,
+			StartOffset:  offset,
+			MessageCount: 1,
+		})
+
+		api.recordUsage(r, topicName, usage.ActionBrowse)
+		msg, err := api.OwlSvc.GetMessage(r.Context(), getReq)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrMessageNotFound) || errors.Is(err, owl.ErrTopicNotFound) {
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not get message: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, msg)
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/headerindex"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultHeaderIndexLimit = 100
+	maxHeaderIndexLimit     = 10000
+)
+
+var errHeaderIndexDisabled = &rest.Error{
+	Err:      fmt.Errorf("header index is disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "The header index is disabled for this cluster",
+	IsSilent: true,
+}
+
+// handleLookupHeaderIndex returns the partitions and offsets of all recent records whose header (query parameter
+// 'key') carries the value (query parameter 'value'). The records can be fetched one by one via the get message
+// endpoint, so that the topic doesn't have to be scanned.
+func (api *API) handleLookupHeaderIndex() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		if api.HeaderIndexer == nil {
+			rest.SendRESTError(w, r, logger, errHeaderIndexDisabled)
+			return
+		}
+
+		canView, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canView {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		headerKey := r.URL.Query().Get("key")
+		if headerKey == "" {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("header key is required"),
+				Status:   http.StatusBadRequest,
+				Message:  "Query parameter 'key' is required",
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		limit, err := parseUsageReportParam(r, "limit", defaultHeaderIndexLimit, maxHeaderIndexLimit)
+		if err != nil {
+			rest.SendRESTError(w, r, logger, usageReportParamError(err))
+			return
+		}
+
+		res, err := api.HeaderIndexer.Lookup(topicName, headerKey, r.URL.Query().Get("value"), limit)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, headerindex.ErrTopicNotIndexed) || errors.Is(err, headerindex.ErrHeaderKeyNotIndexed) {
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not look up header value: %v", err.Error()),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"go.uber.org/zap"
+)
+
+// maxRequestLogDuration is the longest time window for which the Kafka request log can be enabled at once
+const maxRequestLogDuration = time.Hour
+
+type requestLogState struct {
+	IsEnabled         bool                    `json:"isEnabled"`
+	EnabledUntil      *time.Time              `json:"enabledUntil,omitempty"`
+	UnsupportedReason string                  `json:"unsupportedReason,omitempty"`
+	Entries           []kafka.RequestLogEntry `json:"entries"`
+}
+
+type putRequestLogRequest struct {
+	Enabled  bool   `json:"enabled"`
+	Duration string `json:"duration"` // e.g. "10m", required if enabled
+
+	duration time.Duration
+}
+
+func (p *putRequestLogRequest) OK() error {
+	if !p.Enabled {
+		return nil
+	}
+
+	d, err := time.ParseDuration(p.Duration)
+	if err != nil {
+		return fmt.Errorf("failed to parse duration: %w", err)
+	}
+	if d <= 0 || d > maxRequestLogDuration {
+		return fmt.Errorf("duration must be greater than 0 and at most %v", maxRequestLogDuration)
+	}
+	p.duration = d
+
+	return nil
+}
+
+// fakeClusterRequestLogReason is reported as unsupported reason if the fake cluster is enabled
+const fakeClusterRequestLogReason = "The fake cluster does not send any Kafka requests"
+
+func (api *API) requestLogState(withEntries bool) requestLogState {
+	if api.KafkaSvc == nil {
+		return requestLogState{UnsupportedReason: fakeClusterRequestLogReason, Entries: []kafka.RequestLogEntry{}}
+	}
+
+	log := api.KafkaSvc.RequestLog
+	state := requestLogState{
+		IsEnabled:         log.IsEnabled(),
+		UnsupportedReason: log.UnsupportedReason,
+		Entries:           []kafka.RequestLogEntry{},
+	}
+	if state.IsEnabled {
+		enabledUntil := log.EnabledUntil()
+		state.EnabledUntil = &enabledUntil
+	}
+	if withEntries {
+		state.Entries = log.Entries()
+	}
+
+	return state
+}
+
+// handleGetKafkaRequestLog returns all Kafka requests which have been recorded in the last enabled time window
+func (api *API) handleGetKafkaRequestLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, api.requestLogState(true))
+	}
+}
+
+// handlePutKafkaRequestLog enables the Kafka request log for the given time window or disables it
+func (api *API) handlePutKafkaRequestLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req putRequestLogRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		if api.KafkaSvc == nil {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("request log is not supported by the fake cluster"),
+				Status:   http.StatusNotImplemented,
+				Message:  fakeClusterRequestLogReason,
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		if !req.Enabled {
+			api.KafkaSvc.RequestLog.Disable()
+			rest.SendResponse(w, r, api.Logger, http.StatusOK, api.requestLogState(false))
+			return
+		}
+
+		err = api.KafkaSvc.RequestLog.Enable(req.duration)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusNotImplemented,
+				Message:  err.Error(),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		api.Logger.Info("enabled kafka request log", zap.Duration("duration", req.duration))
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, api.requestLogState(false))
+	}
+}
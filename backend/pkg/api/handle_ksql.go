@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/ksql"
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+var errKsqlNotConfigured = &rest.Error{
+	Err:      fmt.Errorf("ksqlDB is not configured"),
+	Status:   http.StatusNotFound,
+	Message:  "ksqlDB is not configured",
+	IsSilent: true,
+}
+
+// ksqlClient returns the client for the server in the URL after checking the requester's permissions
+func (api *API) ksqlClient(ctx context.Context, serverName string, requireQuery bool) (*ksql.Client, *rest.Error) {
+	if api.KsqlSvc == nil {
+		return nil, errKsqlNotConfigured
+	}
+
+	client, err := api.KsqlSvc.Client(serverName)
+	if err != nil {
+		return nil, &rest.Error{
+			Err:      err,
+			Status:   http.StatusNotFound,
+			Message:  fmt.Sprintf("ksqlDB server '%v' does not exist", serverName),
+			IsSilent: true,
+		}
+	}
+
+	isAllowed, restErr := api.Hooks.Owl.CanViewKsqlServer(ctx, serverName)
+	if restErr == nil && isAllowed && requireQuery {
+		isAllowed, restErr = api.Hooks.Owl.CanQueryKsqlServer(ctx, serverName)
+	}
+	if restErr != nil {
+		return nil, restErr
+	}
+	if !isAllowed {
+		return nil, &rest.Error{
+			Err:      fmt.Errorf("requester has no permissions for ksqlDB server '%v'", serverName),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions for this action in the ksqlDB server",
+			IsSilent: false,
+		}
+	}
+
+	return client, nil
+}
+
+// ksqlError converts errors from ksqlDB into rest errors. Status codes returned by ksqlDB (e.g. 400 for invalid
+// statements) are passed through, while the circuit breaker is open 503 is returned.
+func ksqlError(err error, message string) *rest.Error {
+	status := http.StatusInternalServerError
+	var ksqlErr *ksql.RestError
+	switch {
+	case errors.As(err, &ksqlErr) && ksqlErr.StatusCode < http.StatusInternalServerError:
+		status = ksqlErr.StatusCode
+	case errors.Is(err, resilience.ErrCircuitOpen):
+		status = http.StatusServiceUnavailable
+	}
+
+	return &rest.Error{
+		Err:      err,
+		Status:   status,
+		Message:  fmt.Sprintf("%v: %v", message, err.Error()),
+		IsSilent: false,
+	}
+}
+
+func (api *API) handleGetKsqlServers() http.HandlerFunc {
+	type response struct {
+		Servers []string `json:"servers"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := response{Servers: make([]string, 0)}
+		if api.KsqlSvc == nil {
+			rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+			return
+		}
+
+		for _, serverName := range api.KsqlSvc.ServerNames() {
+			canView, restErr := api.Hooks.Owl.CanViewKsqlServer(r.Context(), serverName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			if canView {
+				res.Servers = append(res.Servers, serverName)
+			}
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
+
+func (api *API) handleGetKsqlStreams() http.HandlerFunc {
+	type response struct {
+		Streams []ksql.Stream `json:"streams"`
+		Tables  []ksql.Stream `json:"tables"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, restErr := api.ksqlClient(r.Context(), chi.URLParam(r, "serverName"), false)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		streams, err := client.ListStreams(r.Context())
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, ksqlError(err, "Could not list streams"))
+			return
+		}
+		tables, err := client.ListTables(r.Context())
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, ksqlError(err, "Could not list tables"))
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Streams: streams, Tables: tables})
+	}
+}
+
+func (api *API) handleGetKsqlQueries() http.HandlerFunc {
+	type response struct {
+		Queries []ksql.Query `json:"queries"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, restErr := api.ksqlClient(r.Context(), chi.URLParam(r, "serverName"), false)
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		queries, err := client.ListQueries(r.Context())
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, ksqlError(err, "Could not list queries"))
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Queries: queries})
+	}
+}
+
+type ksqlQueryRequest struct {
+	KSQL string `json:"ksql"`
+
+	// Properties are passed to ksqlDB as streams properties, e.g. 'ksql.streams.auto.offset.reset: earliest'
+	Properties map[string]string `json:"properties"`
+
+	// MaxRows is optional and capped by the configured maximum
+	MaxRows int `json:"maxRows"`
+}
+
+func (req *ksqlQueryRequest) OK() error {
+	statement := strings.TrimSpace(req.KSQL)
+	if statement == "" {
+		return fmt.Errorf("ksql must be set")
+	}
+	// ksqlDB rejects other statements on its query endpoint as well, this check provides a clearer error
+	if !strings.HasPrefix(strings.ToUpper(statement), "SELECT") {
+		return fmt.Errorf("only pull and push queries (SELECT statements) can be run")
+	}
+	if strings.Contains(strings.TrimSuffix(statement, ";"), ";") {
+		return fmt.Errorf("only a single query can be run at once")
+	}
+	if req.MaxRows < 0 {
+		return fmt.Errorf("max rows must not be negative")
+	}
+
+	return nil
+}
+
+// handleKsqlQuery runs a pull or push query on the ksqlDB server and streams its rows via websocket. The first
+// message must be the query request. Push queries run until the requester closes the connection or the configured
+// limits are reached.
+func (api *API) handleKsqlQuery() http.HandlerFunc {
+	type headerMessage struct {
+		Type        string        `json:"type"`
+		QueryID     string        `json:"queryId"`
+		Columns     []ksql.Column `json:"columns"`
+		IsPushQuery bool          `json:"isPushQuery"`
+	}
+	type rowMessage struct {
+		Type      string            `json:"type"`
+		Columns   []json.RawMessage `json:"columns"`
+		Tombstone bool              `json:"tombstone,omitempty"`
+	}
+	type doneMessage struct {
+		Type string `json:"type"`
+		*ksql.QueryResult
+		ElapsedMs int64 `json:"elapsedMs"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverName := chi.URLParam(r, "serverName")
+		logger := api.Logger.With(zap.String("ksql_server", serverName))
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		wsClient := websocketClient{
+			Ctx:        ctx,
+			Cancel:     cancel,
+			Logger:     logger,
+			Connection: nil,
+			Mutex:      &sync.RWMutex{},
+		}
+		restErr := wsClient.upgrade(w, r)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		defer wsClient.sendClose()
+
+		sendError := func(msg string) {
+			wsClient.writeJSON(struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			}{"error", msg})
+		}
+
+		var req ksqlQueryRequest
+		err := wsClient.readJSON(&req)
+		if err != nil {
+			sendError("Failed to parse ksql query request")
+			return
+		}
+		go wsClient.readLoop()
+		go wsClient.producePings()
+
+		err = req.OK()
+		if err != nil {
+			sendError(fmt.Sprintf("Failed to validate ksql query request: %v", err))
+			return
+		}
+
+		client, restErr := api.ksqlClient(ctx, serverName, true)
+		if restErr != nil {
+			sendError(restErr.Message)
+			return
+		}
+
+		maxRows := api.Cfg.Ksql.MaxRows
+		if req.MaxRows > 0 && req.MaxRows < maxRows {
+			maxRows = req.MaxRows
+		}
+		isPushQuery := ksql.IsPushQuery(req.KSQL)
+		logger.Info("running ksql query", zap.String("requester", requesterID(r)), zap.Bool("is_push_query", isPushQuery))
+
+		queryCtx, cancelQuery := context.WithTimeout(ctx, api.Cfg.Ksql.MaxQueryDuration)
+		defer cancelQuery()
+		start := time.Now()
+		onHeader := func(header ksql.QueryHeader) {
+			wsClient.writeJSON(headerMessage{
+				Type:        "header",
+				QueryID:     header.QueryID,
+				Columns:     ksql.ParseSchema(header.Schema),
+				IsPushQuery: isPushQuery,
+			})
+		}
+		rows := 0
+		onRow := func(row ksql.QueryRow) bool {
+			if err := wsClient.writeJSON(rowMessage{Type: "row", Columns: row.Columns, Tombstone: row.Tombstone}); err != nil {
+				return false
+			}
+			rows++
+			return rows < maxRows
+		}
+		res, err := client.Query(queryCtx, ksql.QueryRequest{KSQL: req.KSQL, Properties: req.Properties}, onHeader, onRow)
+		if err != nil && queryCtx.Err() == nil {
+			sendError(ksqlError(err, "Query failed").Message)
+			return
+		}
+		if res == nil {
+			// The query has been cancelled before ksqlDB responded
+			res = &ksql.QueryResult{}
+		}
+		// Push queries are only ever stopped by the limits or the requester
+		res.IsStopped = res.IsStopped || queryCtx.Err() != nil
+		wsClient.writeJSON(doneMessage{Type: "done", QueryResult: res, ElapsedMs: time.Since(start).Milliseconds()})
+	}
+}
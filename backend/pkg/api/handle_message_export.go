@@ -0,0 +1,264 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/query"
+	"github.com/cloudhut/kowl/backend/pkg/usage"
+	"github.com/go-chi/chi"
+)
+
+type exportMessagesRequest struct {
+	Format                string `json:"format"`                // ndjson or csv
+	StartOffset           int64  `json:"startOffset"`           // -1 for recent (newest - results), -2 for oldest offset, -4 for timestamp
+	PartitionID           int32  `json:"partitionId"`           // -1 for all partition ids
+	FilterInterpreterCode string `json:"filterInterpreterCode"` // Base64 encoded code
+	FilterTimeoutMs       int    `json:"filterTimeoutMs"`       // Optional per message timeout, capped by the configured maximum
+
+	// StartTimestamp and EndTimestamp (unix milliseconds) are used if StartOffset is -4. EndTimestamp is optional.
+	StartTimestamp int64 `json:"startTimestamp"`
+	EndTimestamp   int64 `json:"endTimestamp"`
+
+	// MaxRows and MaxBytes are optional and capped by the configured maximum
+	MaxRows  int64 `json:"maxRows"`
+	MaxBytes int64 `json:"maxBytes"`
+
+	// OrderByTimestamp exports the messages of all partitions ordered by timestamp rather than by arrival
+	OrderByTimestamp bool `json:"orderByTimestamp"`
+
+	// OrderBy exports the first MaxRows messages of the scanned range sorted by offset, timestamp or size. Later keys
+	// break ties of earlier keys.
+	OrderBy []owl.SortKey `json:"orderBy"`
+
+	// IsolationLevel is either read_uncommitted (default) or read_committed
+	IsolationLevel string `json:"isolationLevel"`
+
+	// CanonicalJSON renders JSON based keys, values and headers deterministically, so that exports can be diffed
+	CanonicalJSON kafka.CanonicalJSONOptions `json:"canonicalJson"`
+
+	// BinaryEncoding is either base64 (default) or hexdump
+	BinaryEncoding kafka.BinaryEncoding `json:"binaryEncoding"`
+
+	// Projection is an optional list of JSONPath expressions. If given, each row consists of the message's
+	// partition, offset and timestamp followed by one column per expression.
+	Projection []string `json:"projection"`
+
+	// Query is an optional search query, e.g. partition=3 AND value.status="FAILED". Messages must match both the
+	// query and the filter code.
+	Query string `json:"query"`
+}
+
+func (e *exportMessagesRequest) OK() error {
+	if e.Format != exportFormatNDJSON && e.Format != exportFormatCSV {
+		return fmt.Errorf("format must be either '%v' or '%v'", exportFormatNDJSON, exportFormatCSV)
+	}
+
+	// Exports starting at the newest offset would wait for new messages until the timeout
+	if e.StartOffset < owl.StartOffsetTimestamp || e.StartOffset == owl.StartOffsetNewest {
+		return fmt.Errorf("start offset must be -1, -2, -4 or a specific offset")
+	}
+
+	if e.StartOffset == owl.StartOffsetTimestamp {
+		if e.StartTimestamp <= 0 {
+			return fmt.Errorf("start timestamp is required when exporting by timestamp")
+		}
+		if e.EndTimestamp != 0 && e.EndTimestamp < e.StartTimestamp {
+			return fmt.Errorf("end timestamp must not be before the start timestamp")
+		}
+	}
+
+	if e.PartitionID < -1 {
+		return fmt.Errorf("partitionID is smaller than -1")
+	}
+
+	if e.MaxRows < 0 || e.MaxBytes < 0 {
+		return fmt.Errorf("max rows and max bytes must not be negative")
+	}
+
+	if e.FilterTimeoutMs < 0 {
+		return fmt.Errorf("filter timeout must not be negative")
+	}
+
+	if len(e.OrderBy) > 0 {
+		if e.OrderByTimestamp {
+			return fmt.Errorf("order by and order by timestamp must not be combined")
+		}
+		if err := owl.ValidateSortKeys(e.OrderBy); err != nil {
+			return err
+		}
+	}
+
+	if _, err := parseIsolationLevel(e.IsolationLevel); err != nil {
+		return err
+	}
+
+	if err := e.BinaryEncoding.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := newMessageProjection(e.Projection); err != nil {
+		return err
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(e.FilterInterpreterCode); err != nil {
+		return fmt.Errorf("failed to decode interpreter code %w", err)
+	}
+
+	if _, err := parseSearchQuery(e.Query); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// handleExportMessages runs a message search and streams all matching messages into a downloadable NDJSON or CSV
+// file. The number of rows, the file size and the duration are limited, the outcome is reported as trailers.
+func (api *API) handleExportMessages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger
+
+		var req exportMessagesRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		canExport, restErr := api.Hooks.Owl.CanExportTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages || !canExport {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to export messages of the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to export messages of this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		decodedCode, _ := base64.StdEncoding.DecodeString(req.FilterInterpreterCode) // Checked in OK()
+		searchQuery, _ := parseSearchQuery(req.Query)                                // Checked in OK()
+		interpreterCode := string(decodedCode)
+		if searchQuery != nil {
+			interpreterCode = query.CombineFilterCode(interpreterCode, searchQuery.FilterCode())
+		}
+		if len(decodedCode) > 0 || searchQuery != nil {
+			canUseMessageSearchFilters, restErr := api.Hooks.Owl.CanUseMessageSearchFilters(r.Context(), topicName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+			if !canUseMessageSearchFilters {
+				restErr := &rest.Error{
+					Err:      fmt.Errorf("requester has no permissions to use message filters in the requested topic"),
+					Status:   http.StatusForbidden,
+					Message:  "You don't have permissions to use message filters in this topic",
+					IsSilent: false,
+				}
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+
+			for _, finding := range filter.Lint(interpreterCode, api.Cfg.Filter) {
+				if finding.Severity != filter.SeverityError {
+					continue
+				}
+				restErr := &rest.Error{
+					Err:      fmt.Errorf("filter code has been rejected by the linter: %v", finding.Message),
+					Status:   http.StatusBadRequest,
+					Message:  fmt.Sprintf("Filter code has been rejected: %v", finding.Message),
+					IsSilent: false,
+				}
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+		}
+
+		maxRows := api.Cfg.Export.MaxRows
+		if req.MaxRows > 0 && req.MaxRows < maxRows {
+			maxRows = req.MaxRows
+		}
+		maxBytes := api.Cfg.Export.MaxBytes
+		if req.MaxBytes > 0 && req.MaxBytes < maxBytes {
+			maxBytes = req.MaxBytes
+		}
+
+		listReq := owl.ListMessageRequest{
+			TopicName:             topicName,
+			PartitionID:           req.PartitionID,
+			StartOffset:           req.StartOffset,
+			MessageCount:          maxRows,
+			FilterInterpreterCode: interpreterCode,
+			StartTimestamp:        req.StartTimestamp,
+			EndTimestamp:          req.EndTimestamp,
+			OrderByTimestamp:      req.OrderByTimestamp,
+			SortKeys:              req.OrderBy,
+			CanonicalJSON:         req.CanonicalJSON,
+			BinaryEncoding:        req.BinaryEncoding,
+		}
+		listReq.IsolationLevel, _ = parseIsolationLevel(req.IsolationLevel) // Checked in OK()
+		listReq.Decrypter, restErr = api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		listReq.Masker, restErr = api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		listReq.Renderer = api.RenderingSvc.Renderer(topicName)
+		applySearchQuery(&listReq, searchQuery)
+		if len(interpreterCode) > 0 {
+			requesterBudget := api.FilterBudgets.ForRequester(requesterID(r))
+			listReq.FilterBudget = filter.NewBudget("export", api.Cfg.Filter.MaxSearchExecutionTime, requesterBudget)
+			listReq.FilterLimits = api.Cfg.Filter.Limits(time.Duration(req.FilterTimeoutMs) * time.Millisecond)
+		}
+		api.Hooks.Owl.PrintListMessagesAuditLog(r, &listReq)
+
+		ctx, cancel := context.WithTimeout(r.Context(), api.Cfg.Export.Timeout)
+		defer cancel()
+
+		filename := fmt.Sprintf("%v-%v.%v", topicName, time.Now().UTC().Format("20060102T150405Z"), req.Format)
+		projection, _ := newMessageProjection(req.Projection) // Checked in OK()
+		exporter := newMessageExporter(w, req.Format, filename, projection, maxRows, maxBytes, cancel, logger)
+		api.recordUsage(r, topicName, usage.ActionExport)
+		exportStart := time.Now()
+		err = api.OwlSvc.ListMessages(ctx, listReq, exporter)
+		api.emitSearchEvent(r, selfEventTypeExport, topicName, time.Since(exportStart), exporter.exportedRows(), err)
+		if err := exporter.Close(err); err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  fmt.Sprintf("Failed to export messages: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+	}
+}
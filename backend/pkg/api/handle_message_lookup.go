@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/usage"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultKeyLookupResults = 100
+	maxKeyLookupResults     = 1000
+
+	// keyLookupTimeout bounds the scan, the whole topic has to be consumed unless a partitioner is given
+	keyLookupTimeout = 60 * time.Second
+)
+
+type lookupMessagesRequest struct {
+	Key *owl.ProducePayload `json:"key"` // Serialized like the key of a produced message
+
+	// Partitioner is optional, either murmur2 (Java client) or fnv1a (Kowl and sarama). If set, only the partition
+	// which the key is assigned to is scanned.
+	Partitioner string `json:"partitioner"`
+
+	MaxResults int64 `json:"maxResults"` // Defaults to 100, at most 1000
+
+	// AllVersions returns all messages with the key, by default only the latest one is returned for compacted topics
+	AllVersions bool `json:"allVersions"`
+
+	// IsolationLevel is either read_uncommitted (default) or read_committed
+	IsolationLevel string `json:"isolationLevel"`
+}
+
+func (l *lookupMessagesRequest) OK() error {
+	if l.Key == nil || l.Key.Data == "" {
+		return fmt.Errorf("key must be set")
+	}
+
+	switch l.Partitioner {
+	case "", kafka.KeyPartitionerMurmur2, kafka.KeyPartitionerFNV1a:
+	default:
+		return fmt.Errorf("partitioner must be either '%v' or '%v'", kafka.KeyPartitionerMurmur2, kafka.KeyPartitionerFNV1a)
+	}
+
+	if l.MaxResults == 0 {
+		l.MaxResults = defaultKeyLookupResults
+	}
+	if l.MaxResults < 0 || l.MaxResults > maxKeyLookupResults {
+		return fmt.Errorf("max results must be between 1 and %v", maxKeyLookupResults)
+	}
+
+	if _, err := parseIsolationLevel(l.IsolationLevel); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// handleLookupMessages returns all messages with the requested key, or only the latest one for compacted topics
+func (api *API) handleLookupMessages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		var req lookupMessagesRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		lookupReq := owl.FindMessagesByKeyRequest{
+			TopicName:   topicName,
+			Key:         *req.Key,
+			Partitioner: req.Partitioner,
+			MaxResults:  req.MaxResults,
+			AllVersions: req.AllVersions,
+			Renderer:    api.RenderingSvc.Renderer(topicName),
+		}
+		lookupReq.IsolationLevel, _ = parseIsolationLevel(req.IsolationLevel) // Checked in OK()
+		lookupReq.Decrypter, restErr = api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		lookupReq.Masker, restErr = api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), keyLookupTimeout)
+		defer cancel()
+		api.recordUsage(r, topicName, usage.ActionSearch)
+		res, err := api.OwlSvc.FindMessagesByKey(ctx, lookupReq)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrInvalidPayload) {
+				status = http.StatusBadRequest
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Failed to look up messages: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
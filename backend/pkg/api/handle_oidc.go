@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/authentication"
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// oidcRoutes registers the endpoints of the OIDC login. They live outside of /api, because they are called before
+// the user has a session.
+func (api *API) oidcRoutes(r chi.Router) {
+	r.Get("/login", api.handleOIDCLogin())
+	r.Get("/callback", api.handleOIDCCallback())
+	r.Post("/logout", api.handleOIDCLogout())
+	r.Get("/userinfo", api.handleOIDCUserInfo())
+}
+
+// handleOIDCLogin redirects the user to the provider's login page. The optional query parameter 'redirect' is the
+// relative path the user returns to after the login.
+func (api *API) handleOIDCLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authURL, stateCookie, err := api.OIDCLogin.StartLogin(r.Context(), r.URL.Query().Get("redirect"))
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusServiceUnavailable,
+				Message:  "Could not start the login, the identity provider is unavailable",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger.With(zap.String("source", "authentication")), restErr)
+			return
+		}
+
+		http.SetCookie(w, stateCookie)
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// handleOIDCCallback completes the login after the provider redirected the user back to Kowl and sets the session
+// cookie
+func (api *API) handleOIDCCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The login state is only valid for a single callback, no matter whether the login succeeded
+		http.SetCookie(w, api.OIDCLogin.ClearLoginState())
+
+		sessionCookie, redirect, err := api.OIDCLogin.FinishLogin(r)
+		if err != nil {
+			if errors.Is(err, authentication.ErrLoginFailed) {
+				restErr := &rest.Error{
+					Err:      err,
+					Status:   http.StatusUnauthorized,
+					Message:  "Login failed, please try again",
+					IsSilent: false,
+				}
+				rest.SendRESTError(w, r, api.Logger.With(zap.String("source", "authentication")), restErr)
+				return
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusServiceUnavailable,
+				Message:  "Could not complete the login, the identity provider is unavailable",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger.With(zap.String("source", "authentication")), restErr)
+			return
+		}
+
+		http.SetCookie(w, sessionCookie)
+		http.Redirect(w, r, redirect, http.StatusFound)
+	}
+}
+
+// handleOIDCLogout removes the session cookie. The response carries the provider's logout URL, which the frontend
+// navigates to in order to end the session at the provider as well. It's empty if the provider doesn't support it.
+func (api *API) handleOIDCLogout() http.HandlerFunc {
+	type response struct {
+		LogoutURL string `json:"logoutUrl"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, logoutURL := api.OIDCLogin.Logout(r.Context())
+		http.SetCookie(w, cookie)
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{LogoutURL: logoutURL})
+	}
+}
+
+// handleOIDCUserInfo returns the logged in user, so that the frontend can show who is logged in and when the session
+// expires
+func (api *API) handleOIDCUserInfo() http.HandlerFunc {
+	type response struct {
+		authorization.Subject
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := api.OIDCLogin.Session(r)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusUnauthorized,
+				Message:  "You are not logged in or your session has expired",
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Subject: session.Subject, ExpiresAt: session.ExpiresAt})
+	}
+}
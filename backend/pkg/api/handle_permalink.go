@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// permalinkResponse contains the resolved message along with the coordinates it has been resolved from
+type permalinkResponse struct {
+	ClusterName string              `json:"clusterName"`
+	TopicName   string              `json:"topicName"`
+	PartitionID int32               `json:"partitionId"`
+	Offset      int64               `json:"offset"`
+	Message     *kafka.TopicMessage `json:"message"`
+}
+
+// parseMessageCoordinates parses the partitionID and offset url parameters which identify a single message
+func parseMessageCoordinates(r *http.Request) (int32, int64, *rest.Error) {
+	partitionIDStr := chi.URLParam(r, "partitionID")
+	partitionID, err := strconv.ParseInt(partitionIDStr, 10, 32)
+	if err != nil || partitionID < 0 {
+		return 0, 0, &rest.Error{
+			Err:      fmt.Errorf("failed to parse partition id '%v'", partitionIDStr),
+			Status:   http.StatusBadRequest,
+			Message:  "Partition ID must be a valid, non negative int32",
+			IsSilent: true,
+		}
+	}
+
+	offsetStr := chi.URLParam(r, "offset")
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, &rest.Error{
+			Err:      fmt.Errorf("failed to parse offset '%v'", offsetStr),
+			Status:   http.StatusBadRequest,
+			Message:  "Offset must be a valid, non negative int64",
+			IsSilent: true,
+		}
+	}
+
+	return int32(partitionID), offset, nil
+}
+
+// handleResolvePermalink returns a single message identified by cluster name, topic, partition and offset. The
+// link doesn't depend on the routes of a cluster, so that it stays valid for links in tickets or logs. Masking,
+// rendering and all permission checks are applied like for messages which are listed in the message viewer.
+func (api *API) handleResolvePermalink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clusterName := chi.URLParam(r, "clusterName")
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("cluster_name", clusterName), zap.String("topic_name", topicName))
+
+		partitionID, offset, restErr := parseMessageCoordinates(r)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// Unknown clusters and clusters which the requester is not allowed to see are indistinguishable
+		clusterAPI := api.clusterByName(clusterName)
+		canSeeCluster, restErr := api.Hooks.Owl.CanSeeCluster(r.Context(), clusterName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if clusterAPI == nil || !canSeeCluster {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("cluster '%v' is not configured or the requester has no permissions to see it", clusterName),
+				Status:   http.StatusNotFound,
+				Message:  fmt.Sprintf("Cluster '%v' does not exist", clusterName),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		getReq := owl.GetMessageRequest{
+			TopicName:   topicName,
+			PartitionID: partitionID,
+			Offset:      offset,
+			Renderer:    api.RenderingSvc.Renderer(topicName),
+		}
+		var err error
+		getReq.IsolationLevel, err = parseIsolationLevel(r.URL.Query().Get("isolationLevel"))
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		getReq.BinaryEncoding = kafka.BinaryEncoding(r.URL.Query().Get("binaryEncoding"))
+		if err := getReq.BinaryEncoding.Validate(); err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		getReq.Decrypter, restErr = api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		getReq.Masker, restErr = api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		api.Hooks.Owl.PrintListMessagesAuditLog(r, &owl.ListMessageRequest{
+			TopicName:    topicName,
+			PartitionID:  getReq.PartitionID,
+			StartOffset:  offset,
+			MessageCount: 1,
+		})
+
+		msg, err := clusterAPI.OwlSvc.GetMessage(r.Context(), getReq)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrMessageNotFound) || errors.Is(err, owl.ErrTopicNotFound) {
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not resolve permalink: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, permalinkResponse{
+			ClusterName: clusterName,
+			TopicName:   topicName,
+			PartitionID: partitionID,
+			Offset:      offset,
+			Message:     msg,
+		})
+	}
+}
@@ -1,18 +1,24 @@
 package api
 
 import (
-	"github.com/cloudhut/common/rest"
 	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
 )
 
+// handleLivenessProbe reports the circuit breakers of external dependencies as well. Open circuits don't fail the
+// probe, because restarting kowl doesn't make a dependency available again.
 func (api *API) handleLivenessProbe() http.HandlerFunc {
 	type response struct {
-		IsHTTPOk bool `json:"isHttpOk"`
+		IsHTTPOk     bool                `json:"isHttpOk"`
+		Dependencies []resilience.Status `json:"dependencies"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		res := &response{
-			IsHTTPOk: true,
+			IsHTTPOk:     true,
+			Dependencies: api.Dependencies.Statuses(),
 		}
 
 		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
@@ -26,11 +32,10 @@ func (api *API) handleStartupProbe() http.HandlerFunc {
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check Kafka connectivity
-		isKafkaOK := false
-		err := api.KafkaSvc.IsHealthy()
-		if err == nil {
-			isKafkaOK = true
+		// Check Kafka connectivity, the fake cluster is always healthy
+		isKafkaOK := true
+		if api.KafkaSvc != nil {
+			isKafkaOK = api.KafkaSvc.IsHealthy() == nil
 		}
 
 		res := &response{
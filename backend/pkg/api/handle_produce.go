@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type produceMessageRequest struct {
+	PartitionID int32               `json:"partitionId"` // Only used with the manual partitioner
+	Partitioner string              `json:"partitioner"` // manual, hash (default), random or roundRobin
+	Key         *owl.ProducePayload `json:"key"`         // Omit to produce a record without key
+	Value       *owl.ProducePayload `json:"value"`       // Omit to produce a tombstone
+	Headers     []owl.ProduceHeader `json:"headers"`
+	Options     produceOptions      `json:"options"`
+}
+
+// produceOptions are the per request producer settings, see kafka.ProduceOptions
+type produceOptions struct {
+	Acks        string `json:"acks"`
+	Idempotent  bool   `json:"idempotent"`
+	Compression string `json:"compression"`
+	LingerMs    int64  `json:"lingerMs"`
+}
+
+func (p produceOptions) toKafkaOptions() kafka.ProduceOptions {
+	return kafka.ProduceOptions{
+		Acks:        p.Acks,
+		Idempotent:  p.Idempotent,
+		Compression: p.Compression,
+		Linger:      time.Duration(p.LingerMs) * time.Millisecond,
+	}
+}
+
+func (p *produceMessageRequest) OK() error {
+	switch p.Partitioner {
+	case "":
+		p.Partitioner = kafka.PartitionerHash
+	case kafka.PartitionerHash, kafka.PartitionerRandom, kafka.PartitionerRoundRobin:
+	case kafka.PartitionerManual:
+		if p.PartitionID < 0 {
+			return fmt.Errorf("partition id must be set when using the manual partitioner")
+		}
+	default:
+		return fmt.Errorf("unknown partitioner '%v'", p.Partitioner)
+	}
+
+	if err := p.Options.toKafkaOptions().Validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+
+	for _, h := range p.Headers {
+		if h.Key == "" {
+			return fmt.Errorf("header keys must not be empty")
+		}
+	}
+
+	return nil
+}
+
+func (api *API) handleProduceMessage() http.HandlerFunc {
+	type response struct {
+		*kafka.ProduceResult
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		var req produceMessageRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// Check if logged in user is allowed to produce messages to the given topic
+		canPublish, restErr := api.Hooks.Owl.CanPublishTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canPublish {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to publish messages to the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to publish messages to this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		produceReq := owl.ProduceRequest{
+			TopicName:   topicName,
+			PartitionID: req.PartitionID,
+			Partitioner: req.Partitioner,
+			Key:         req.Key,
+			Value:       req.Value,
+			Headers:     req.Headers,
+			Options:     req.Options.toKafkaOptions(),
+		}
+		result, err := api.OwlSvc.ProduceMessage(r.Context(), produceReq)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrInvalidPayload) {
+				status = http.StatusBadRequest
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not produce message: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, response{result})
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	rawPayloadKey   = "key"
+	rawPayloadValue = "value"
+
+	// rawPayloadTimeout bounds the wait for the message, which only takes long if the offset can't be consumed
+	rawPayloadTimeout = 15 * time.Second
+)
+
+// handleDownloadRawPayload returns the key or value of a single message exactly as it has been produced, e.g. to
+// download binary payloads such as images or protobuf messages. Masking can't be applied to raw payloads, hence
+// the download is rejected if any masking rule applies to the requester.
+func (api *API) handleDownloadRawPayload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		partitionID, offset, restErr := parseMessageCoordinates(r)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		part := r.URL.Query().Get("part")
+		if part == "" {
+			part = rawPayloadValue
+		}
+		if part != rawPayloadKey && part != rawPayloadValue {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("invalid payload part '%v'", part),
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Part must be either '%v' or '%v'", rawPayloadKey, rawPayloadValue),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		isolationLevel, err := parseIsolationLevel(r.URL.Query().Get("isolationLevel"))
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		masker, restErr := api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if masker != nil {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("masking rules apply to the requester, raw payloads can't be masked"),
+				Status:   http.StatusForbidden,
+				Message:  "Raw payloads can't be downloaded because masking rules apply to messages of this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		api.Hooks.Owl.PrintListMessagesAuditLog(r, &owl.ListMessageRequest{
+			TopicName:    topicName,
+			PartitionID:  partitionID,
+			StartOffset:  offset,
+			MessageCount: 1,
+		})
+
+		ctx, cancel := context.WithTimeout(r.Context(), rawPayloadTimeout)
+		defer cancel()
+		msg, err := api.OwlSvc.GetRawMessage(ctx, owl.GetMessageRequest{
+			TopicName:      topicName,
+			PartitionID:    partitionID,
+			Offset:         offset,
+			IsolationLevel: isolationLevel,
+		})
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, owl.ErrMessageNotFound) || errors.Is(err, owl.ErrTopicNotFound) {
+				status = http.StatusNotFound
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not get message: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		payload := msg.Value
+		if part == rawPayloadKey {
+			payload = msg.Key
+		}
+		if payload == nil {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("the message's %v is null", part),
+				Status:   http.StatusNotFound,
+				Message:  fmt.Sprintf("The message's %v is null", part),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// Topic names only consist of characters which don't need to be escaped in the filename
+		filename := fmt.Sprintf("%v-%v-%v-%v.bin", topicName, partitionID, offset, part)
+		header := w.Header()
+		header.Set("Content-Type", "application/octet-stream")
+		header.Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		header.Set("Content-Length", strconv.Itoa(len(payload)))
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(payload); err != nil {
+			logger.Debug("failed to write raw payload", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type replayMessagesRequest struct {
+	DestinationTopic      string `json:"destinationTopic"`
+	PartitionID           int32  `json:"partitionId"`           // -1 for all partition ids
+	StartOffset           int64  `json:"startOffset"`           // -2 for oldest offset, -4 for timestamp or a specific offset
+	FilterInterpreterCode string `json:"filterInterpreterCode"` // Base64 encoded code
+	FilterTimeoutMs       int    `json:"filterTimeoutMs"`       // Optional per message timeout, capped by the configured maximum
+
+	// EndOffset is the optional last offset (inclusive) which is replayed from each partition
+	EndOffset *int64 `json:"endOffset"`
+
+	// StartTimestamp and EndTimestamp (unix milliseconds) are used if StartOffset is -4. EndTimestamp is optional.
+	StartTimestamp int64 `json:"startTimestamp"`
+	EndTimestamp   int64 `json:"endTimestamp"`
+
+	// MaxMessages is optional and capped by the configured maximum
+	MaxMessages int64 `json:"maxMessages"`
+
+	// IsolationLevel is either read_uncommitted (default) or read_committed
+	IsolationLevel string `json:"isolationLevel"`
+
+	// PreservePartitions produces each message to the partition it has been consumed from, otherwise the partition
+	// is chosen by the hash of the message's key
+	PreservePartitions bool `json:"preservePartitions"`
+
+	// Transactional produces the messages in chunks, each within a single transaction. ChunkSize is optional and
+	// capped by the configured maximum.
+	Transactional bool `json:"transactional"`
+	ChunkSize     int  `json:"chunkSize"`
+
+	// DryRun only counts the messages which would be replayed
+	DryRun bool `json:"dryRun"`
+}
+
+func (req *replayMessagesRequest) OK() error {
+	if req.DestinationTopic == "" {
+		return fmt.Errorf("destination topic must be set")
+	}
+
+	if req.StartOffset < 0 && req.StartOffset != owl.StartOffsetOldest && req.StartOffset != owl.StartOffsetTimestamp {
+		return fmt.Errorf("start offset must be -2, -4 or a specific offset")
+	}
+	if req.StartOffset == owl.StartOffsetTimestamp {
+		if req.StartTimestamp <= 0 {
+			return fmt.Errorf("start timestamp is required when replaying by timestamp")
+		}
+		if req.EndTimestamp != 0 && req.EndTimestamp < req.StartTimestamp {
+			return fmt.Errorf("end timestamp must not be before the start timestamp")
+		}
+	}
+	if req.EndOffset != nil {
+		if *req.EndOffset < 0 {
+			return fmt.Errorf("end offset must not be negative")
+		}
+		if req.StartOffset >= 0 && *req.EndOffset < req.StartOffset {
+			return fmt.Errorf("end offset must not be before the start offset")
+		}
+	}
+
+	if req.PartitionID < -1 {
+		return fmt.Errorf("partitionID is smaller than -1")
+	}
+
+	if req.MaxMessages < 0 {
+		return fmt.Errorf("max messages must not be negative")
+	}
+
+	if req.FilterTimeoutMs < 0 {
+		return fmt.Errorf("filter timeout must not be negative")
+	}
+
+	if req.ChunkSize < 0 {
+		return fmt.Errorf("chunk size must not be negative")
+	}
+
+	if _, err := parseIsolationLevel(req.IsolationLevel); err != nil {
+		return err
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(req.FilterInterpreterCode); err != nil {
+		return fmt.Errorf("failed to decode interpreter code %w", err)
+	}
+
+	return nil
+}
+
+// handleReplayMessages copies the messages of an offset or time range, optionally filtered, to another topic. The
+// messages are produced with their original keys, values and headers plus headers which refer to the source message,
+// e.g. to reprocess messages or to recover messages from a dead letter queue.
+func (api *API) handleReplayMessages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		var req replayMessagesRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		canPublish, restErr := api.Hooks.Owl.CanPublishTopicMessages(r.Context(), req.DestinationTopic)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages || !canPublish {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages of the source topic or to publish messages to the destination topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages of this topic or to publish messages to the destination topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// Messages are replayed as they have been consumed, which would reveal the values that are masked for the
+		// requester in the destination topic
+		masker, restErr := api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if masker != nil {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester can't replay messages of a topic whose messages are masked for them"),
+				Status:   http.StatusForbidden,
+				Message:  "You can't replay messages of this topic, because some of their fields are masked for you",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		decodedCode, _ := base64.StdEncoding.DecodeString(req.FilterInterpreterCode) // Checked in OK()
+		interpreterCode := string(decodedCode)
+		if len(interpreterCode) > 0 {
+			canUseMessageSearchFilters, restErr := api.Hooks.Owl.CanUseMessageSearchFilters(r.Context(), topicName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+			if !canUseMessageSearchFilters {
+				restErr := &rest.Error{
+					Err:      fmt.Errorf("requester has no permissions to use message filters in the requested topic"),
+					Status:   http.StatusForbidden,
+					Message:  "You don't have permissions to use message filters in this topic",
+					IsSilent: false,
+				}
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+
+			for _, finding := range filter.Lint(interpreterCode, api.Cfg.Filter) {
+				if finding.Severity != filter.SeverityError {
+					continue
+				}
+				restErr := &rest.Error{
+					Err:      fmt.Errorf("filter code has been rejected by the linter: %v", finding.Message),
+					Status:   http.StatusBadRequest,
+					Message:  fmt.Sprintf("Filter code has been rejected: %v", finding.Message),
+					IsSilent: false,
+				}
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+		}
+
+		maxMessages := api.Cfg.Replay.MaxMessages
+		if req.MaxMessages > 0 && req.MaxMessages < maxMessages {
+			maxMessages = req.MaxMessages
+		}
+
+		listReq := owl.ListMessageRequest{
+			TopicName:             topicName,
+			PartitionID:           req.PartitionID,
+			StartOffset:           req.StartOffset,
+			EndOffset:             req.EndOffset,
+			MessageCount:          maxMessages,
+			FilterInterpreterCode: interpreterCode,
+			StartTimestamp:        req.StartTimestamp,
+			EndTimestamp:          req.EndTimestamp,
+		}
+		listReq.IsolationLevel, _ = parseIsolationLevel(req.IsolationLevel) // Checked in OK()
+		listReq.Decrypter, restErr = api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if len(interpreterCode) > 0 {
+			requesterBudget := api.FilterBudgets.ForRequester(requesterID(r))
+			listReq.FilterBudget = filter.NewBudget("replay", api.Cfg.Filter.MaxSearchExecutionTime, requesterBudget)
+			listReq.FilterLimits = api.Cfg.Filter.Limits(time.Duration(req.FilterTimeoutMs) * time.Millisecond)
+		}
+		api.Hooks.Owl.PrintListMessagesAuditLog(r, &listReq)
+
+		chunkSize := api.Cfg.Replay.MaxChunkSize
+		if req.ChunkSize > 0 && req.ChunkSize < chunkSize {
+			chunkSize = req.ChunkSize
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), api.Cfg.Replay.Timeout)
+		defer cancel()
+
+		res, err := api.OwlSvc.ReplayMessages(ctx, owl.ReplayRequest{
+			Search:             listReq,
+			DestinationTopic:   req.DestinationTopic,
+			PreservePartitions: req.PreservePartitions,
+			Transactional:      req.Transactional,
+			ChunkSize:          chunkSize,
+			DryRun:             req.DryRun,
+		})
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   topicManagementStatus(err),
+				Message:  fmt.Sprintf("Could not replay messages: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
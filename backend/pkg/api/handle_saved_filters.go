@@ -0,0 +1,240 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/savedfilters"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+var errSavedFiltersDisabled = &rest.Error{
+	Err:      fmt.Errorf("saved filters are disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "Saved filters are disabled",
+	IsSilent: true,
+}
+
+// savedFilter is the API representation of a saved filter. The code is base64 encoded like in all other requests
+// which carry filter code, so that it can be passed to message searches as is.
+type savedFilter struct {
+	Name                  string    `json:"name"`
+	Description           string    `json:"description"`
+	FilterInterpreterCode string    `json:"filterInterpreterCode"`
+	CreatedBy             string    `json:"createdBy"`
+	CreatedAt             time.Time `json:"createdAt"`
+}
+
+func newSavedFilter(f *savedfilters.SavedFilter) savedFilter {
+	return savedFilter{
+		Name:                  f.Name,
+		Description:           f.Description,
+		FilterInterpreterCode: base64.StdEncoding.EncodeToString([]byte(f.Code)),
+		CreatedBy:             f.CreatedBy,
+		CreatedAt:             f.CreatedAt,
+	}
+}
+
+// savedFilterError converts errors of the saved filters service into rest errors
+func savedFilterError(err error, message string) *rest.Error {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, savedfilters.ErrInvalidFilter):
+		status = http.StatusBadRequest
+	case errors.Is(err, savedfilters.ErrFilterNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, savedfilters.ErrFilterAlreadyExists):
+		status = http.StatusConflict
+	}
+
+	return &rest.Error{
+		Err:      err,
+		Status:   status,
+		Message:  fmt.Sprintf("%v: %v", message, err.Error()),
+		IsSilent: false,
+	}
+}
+
+// canUseSavedFilters checks whether the requester is allowed to use message filters in the given topic, which is
+// required for all saved filter routes. Managing saved filters additionally requires the CanManageSavedFilters hook.
+func (api *API) canUseSavedFilters(r *http.Request, topicName string, manage bool) *rest.Error {
+	canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+	if restErr != nil {
+		return restErr
+	}
+	canUseFilters, restErr := api.Hooks.Owl.CanUseMessageSearchFilters(r.Context(), topicName)
+	if restErr != nil {
+		return restErr
+	}
+	if !canViewMessages || !canUseFilters {
+		return &rest.Error{
+			Err:      fmt.Errorf("requester has no permissions to use message filters in the requested topic"),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions to use message filters in this topic",
+			IsSilent: false,
+		}
+	}
+
+	if !manage {
+		return nil
+	}
+	canManage, restErr := api.Hooks.Owl.CanManageSavedFilters(r.Context(), topicName)
+	if restErr != nil {
+		return restErr
+	}
+	if !canManage {
+		return &rest.Error{
+			Err:      fmt.Errorf("requester has no permissions to manage saved filters of the requested topic"),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions to manage saved filters of this topic",
+			IsSilent: false,
+		}
+	}
+
+	return nil
+}
+
+func (api *API) handleGetSavedFilters() http.HandlerFunc {
+	type response struct {
+		TopicName string        `json:"topicName"`
+		Filters   []savedFilter `json:"filters"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		if api.SavedFiltersSvc == nil {
+			rest.SendRESTError(w, r, api.Logger, errSavedFiltersDisabled)
+			return
+		}
+		if restErr := api.canUseSavedFilters(r, topicName, false); restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		filters, err := api.SavedFiltersSvc.ListFilters(api.clusterName, topicName)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, savedFilterError(err, "Could not list saved filters"))
+			return
+		}
+
+		res := response{TopicName: topicName, Filters: make([]savedFilter, len(filters))}
+		for i, f := range filters {
+			res.Filters[i] = newSavedFilter(f)
+		}
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
+
+type createSavedFilterRequest struct {
+	Name                  string `json:"name"`
+	Description           string `json:"description"`
+	FilterInterpreterCode string `json:"filterInterpreterCode"` // Base64 encoded code
+}
+
+func (c *createSavedFilterRequest) OK() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(c.Description) > 1024 {
+		return fmt.Errorf("description must not be longer than 1024 characters")
+	}
+
+	if c.FilterInterpreterCode == "" {
+		return fmt.Errorf("filter interpreter code is required")
+	}
+	if _, err := base64.StdEncoding.DecodeString(c.FilterInterpreterCode); err != nil {
+		return fmt.Errorf("failed to decode interpreter code %w", err)
+	}
+
+	return nil
+}
+
+// handleCreateSavedFilter saves a named filter for the topic. The code must pass the linter, so that only filters
+// which can actually be used in message searches are shared.
+func (api *API) handleCreateSavedFilter() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+		if api.SavedFiltersSvc == nil {
+			rest.SendRESTError(w, r, logger, errSavedFiltersDisabled)
+			return
+		}
+
+		var req createSavedFilterRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		if restErr := api.canUseSavedFilters(r, topicName, true); restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		code, _ := base64.StdEncoding.DecodeString(req.FilterInterpreterCode) // Checked in OK()
+		for _, finding := range filter.Lint(string(code), api.Cfg.Filter) {
+			if finding.Severity != filter.SeverityError {
+				continue
+			}
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("filter code has been rejected by the linter: %v", finding.Message),
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Filter code has been rejected: %v", finding.Message),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		created, err := api.SavedFiltersSvc.CreateFilter(savedfilters.SavedFilter{
+			ClusterName: api.clusterName,
+			TopicName:   topicName,
+			Name:        req.Name,
+			Description: req.Description,
+			Code:        string(code),
+			CreatedBy:   requesterID(r),
+		})
+		if err != nil {
+			rest.SendRESTError(w, r, logger, savedFilterError(err, "Could not save filter"))
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusCreated, newSavedFilter(created))
+	}
+}
+
+func (api *API) handleDeleteSavedFilter() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		filterName := chi.URLParam(r, "filterName")
+		if api.SavedFiltersSvc == nil {
+			rest.SendRESTError(w, r, api.Logger, errSavedFiltersDisabled)
+			return
+		}
+		if restErr := api.canUseSavedFilters(r, topicName, true); restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		err := api.SavedFiltersSvc.DeleteFilter(api.clusterName, topicName, filterName)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, savedFilterError(err, "Could not delete saved filter"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/query"
+	"github.com/cloudhut/kowl/backend/pkg/templates"
+	"github.com/cloudhut/kowl/backend/pkg/usage"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+func (api *API) handleGetSavedSearches() http.HandlerFunc {
+	type response struct {
+		SavedSearches []*templates.SavedSearch `json:"savedSearches"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, restErr := api.Hooks.Owl.RequesterRoles(r.Context())
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		res := response{
+			SavedSearches: api.TemplatesSvc.SavedSearchesForRoles(roles),
+		}
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
+
+// handleRunSavedSearch runs a saved search with the URL query parameters bound to its placeholders and returns all
+// found messages at once. Unlike searches with own filter code, saved searches are curated by admins, hence they only
+// require the permission to view the topic's messages.
+func (api *API) handleRunSavedSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		logger := api.Logger.With(zap.String("saved_search", id))
+
+		search, restErr := api.getSavedSearch(r.Context(), id)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), search.TopicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		boundQuery, err := search.BindQuery(r.URL.Query())
+		var q *query.Query
+		if err == nil {
+			q, err = query.Parse(boundQuery)
+		}
+		if err != nil {
+			// The query can only be invalid for values which can't be compared with a field, e.g. a string with an offset
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Invalid parameters: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		listReq := owl.ListMessageRequest{
+			TopicName:             search.TopicName,
+			PartitionID:           -1,
+			StartOffset:           owl.StartOffsetRecent,
+			MessageCount:          int64(search.MaxResults),
+			FilterInterpreterCode: q.FilterCode(),
+			Renderer:              api.RenderingSvc.Renderer(search.TopicName),
+		}
+		switch {
+		case search.MaxAge > 0:
+			listReq.StartOffset = owl.StartOffsetTimestamp
+			listReq.StartTimestamp = time.Now().Add(-search.MaxAge).UnixNano() / int64(time.Millisecond)
+		case search.Start == templates.SearchStartOldest:
+			listReq.StartOffset = owl.StartOffsetOldest
+		}
+		applySearchQuery(&listReq, q)
+		listReq.Decrypter, restErr = api.messageDecrypter(r, search.TopicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		listReq.Masker, restErr = api.messageMasker(r.Context(), search.TopicName, search.MaskingProfile)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if listReq.FilterInterpreterCode != "" {
+			requesterBudget := api.FilterBudgets.ForRequester(requesterID(r))
+			listReq.FilterBudget = filter.NewBudget("search", api.Cfg.Filter.MaxSearchExecutionTime, requesterBudget)
+			listReq.FilterLimits = api.Cfg.Filter.Limits(0)
+		}
+		api.Hooks.Owl.PrintListMessagesAuditLog(r, &listReq)
+
+		ctx, cancel := context.WithTimeout(r.Context(), search.Timeout)
+		defer cancel()
+		api.recordUsage(r, search.TopicName, usage.ActionSearch)
+		res, err := api.OwlSvc.CollectMessages(ctx, listReq)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  fmt.Sprintf("Failed to run saved search: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+// getSavedSearch returns the saved search with the given id if the logged in user has been assigned to it
+func (api *API) getSavedSearch(ctx context.Context, id string) (*templates.SavedSearch, *rest.Error) {
+	search, exists := api.TemplatesSvc.SavedSearch(id)
+	if !exists {
+		return nil, &rest.Error{
+			Err:      fmt.Errorf("saved search '%v' does not exist", id),
+			Status:   http.StatusNotFound,
+			Message:  fmt.Sprintf("Saved search '%v' does not exist", id),
+			IsSilent: false,
+		}
+	}
+
+	roles, restErr := api.Hooks.Owl.RequesterRoles(ctx)
+	if restErr != nil {
+		return nil, restErr
+	}
+	if !search.IsAssignedTo(roles) {
+		return nil, &rest.Error{
+			Err:      fmt.Errorf("requester is not assigned to saved search '%v'", id),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions to run this saved search",
+			IsSilent: false,
+		}
+	}
+
+	return search, nil
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+	"github.com/go-chi/chi"
+)
+
+var errSchemaRegistryNotConfigured = &rest.Error{
+	Err:      fmt.Errorf("schema registry is not configured"),
+	Status:   http.StatusNotFound,
+	Message:  "Schema Registry is not configured",
+	IsSilent: true,
+}
+
+// schemaRegistryError converts errors from the schema registry into rest errors. Status codes below 500 (e.g. 404
+// for unknown subjects) are passed through, while the circuit breaker is open 503 is returned.
+func schemaRegistryError(err error, message string) *rest.Error {
+	status := http.StatusInternalServerError
+	var registryErr *schema.RestError
+	switch {
+	case errors.As(err, &registryErr) && registryErr.StatusCode < http.StatusInternalServerError:
+		status = registryErr.StatusCode
+	case errors.Is(err, resilience.ErrCircuitOpen):
+		status = http.StatusServiceUnavailable
+	}
+
+	return &rest.Error{
+		Err:      err,
+		Status:   status,
+		Message:  fmt.Sprintf("%v: %v", message, err.Error()),
+		IsSilent: false,
+	}
+}
+
+func (api *API) handleGetSchemaOverview() http.HandlerFunc {
+	type response struct {
+		CompatibilityLevel string   `json:"compatibilityLevel"`
+		Subjects           []string `json:"subjects"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.SchemaSvc == nil {
+			rest.SendRESTError(w, r, api.Logger, errSchemaRegistryNotConfigured)
+			return
+		}
+
+		cfg, err := api.SchemaSvc.GetGlobalConfig(r.Context())
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, schemaRegistryError(err, "Could not get global schema registry config"))
+			return
+		}
+
+		subjects, err := api.SchemaSvc.GetSubjects(r.Context())
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, schemaRegistryError(err, "Could not list subjects"))
+			return
+		}
+		sort.Strings(subjects)
+
+		res := response{
+			CompatibilityLevel: cfg.CompatibilityLevel,
+			Subjects:           subjects,
+		}
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, res)
+	}
+}
+
+func (api *API) handleGetSchemaSubjectDetails() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.SchemaSvc == nil {
+			rest.SendRESTError(w, r, api.Logger, errSchemaRegistryNotConfigured)
+			return
+		}
+
+		subject := chi.URLParam(r, "subject")
+		version := chi.URLParam(r, "version")
+		if version != "latest" {
+			if v, err := strconv.Atoi(version); err != nil || v < 1 {
+				restErr := &rest.Error{
+					Err:      fmt.Errorf("failed to parse schema version '%v'", version),
+					Status:   http.StatusBadRequest,
+					Message:  "Version must be 'latest' or a positive integer",
+					IsSilent: true,
+				}
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+		}
+
+		details, err := api.SchemaSvc.GetSubjectDetails(r.Context(), subject, version)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, schemaRegistryError(err, "Could not get schema details"))
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, details)
+	}
+}
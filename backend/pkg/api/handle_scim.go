@@ -0,0 +1,371 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cloudhut/kowl/backend/pkg/scim"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// scimMaxBodySize limits the size of SCIM request bodies
+const scimMaxBodySize = 1024 * 1024
+
+// scimRoutes registers the SCIM 2.0 endpoints which identity providers use to provision users and groups. They are
+// authenticated with the configured bearer token rather than the user authentication of the /api routes.
+func (api *API) scimRoutes(r chi.Router) {
+	r.Use(api.scimAuthentication)
+
+	r.Get("/ServiceProviderConfig", api.handleGetScimServiceProviderConfig())
+	r.Get("/Users", api.handleListScimUsers())
+	r.Post("/Users", api.handleCreateScimUser())
+	r.Get("/Users/{id}", api.handleGetScimUser())
+	r.Put("/Users/{id}", api.handleReplaceScimUser())
+	r.Patch("/Users/{id}", api.handlePatchScimUser())
+	r.Delete("/Users/{id}", api.handleDeleteScimUser())
+	r.Get("/Groups", api.handleListScimGroups())
+	r.Post("/Groups", api.handleCreateScimGroup())
+	r.Get("/Groups/{id}", api.handleGetScimGroup())
+	r.Put("/Groups/{id}", api.handleReplaceScimGroup())
+	r.Patch("/Groups/{id}", api.handlePatchScimGroup())
+	r.Delete("/Groups/{id}", api.handleDeleteScimGroup())
+}
+
+// scimAuthentication rejects requests which don't carry the configured bearer token
+func (api *API) scimAuthentication(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + api.Cfg.SCIM.BearerToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			api.sendScimError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (api *API) handleGetScimServiceProviderConfig() http.HandlerFunc {
+	type supported struct {
+		Supported bool `json:"supported"`
+	}
+	type filterConfig struct {
+		Supported  bool `json:"supported"`
+		MaxResults int  `json:"maxResults"`
+	}
+	type bulkConfig struct {
+		Supported      bool `json:"supported"`
+		MaxOperations  int  `json:"maxOperations"`
+		MaxPayloadSize int  `json:"maxPayloadSize"`
+	}
+	type authenticationScheme struct {
+		Type        string `json:"type"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		api.sendScim(w, http.StatusOK, struct {
+			Schemas               []string               `json:"schemas"`
+			Patch                 supported              `json:"patch"`
+			Bulk                  bulkConfig             `json:"bulk"`
+			Filter                filterConfig           `json:"filter"`
+			ChangePassword        supported              `json:"changePassword"`
+			Sort                  supported              `json:"sort"`
+			Etag                  supported              `json:"etag"`
+			AuthenticationSchemes []authenticationScheme `json:"authenticationSchemes"`
+		}{
+			Schemas: []string{scim.SchemaServiceProviderConfig},
+			Patch:   supported{true},
+			Bulk:    bulkConfig{Supported: false},
+			Filter:  filterConfig{Supported: true, MaxResults: api.Cfg.SCIM.MaxResults},
+			AuthenticationSchemes: []authenticationScheme{
+				{Type: "oauthbearertoken", Name: "Bearer Token", Description: "Static bearer token configured in Kowl"},
+			},
+		})
+	}
+}
+
+func (api *API) handleListScimUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, startIndex, count, err := api.parseScimListQuery(r)
+		if err != nil {
+			api.sendScimError(w, http.StatusBadRequest, err)
+			return
+		}
+		users, total, err := api.ScimDirectory.ListUsers(filter, startIndex, count)
+		if err != nil {
+			api.sendScimError(w, scimErrorStatus(err), err)
+			return
+		}
+		for _, u := range users {
+			u.Meta.Location = scimLocation(r, "Users", u.ID)
+		}
+		api.sendScim(w, http.StatusOK, scim.ListResponse{
+			Schemas:      []string{scim.SchemaListResponse},
+			TotalResults: total,
+			StartIndex:   startIndex,
+			ItemsPerPage: len(users),
+			Resources:    users,
+		})
+	}
+}
+
+func (api *API) handleGetScimUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := api.ScimDirectory.GetUser(chi.URLParam(r, "id"))
+		api.sendScimUser(w, r, http.StatusOK, user, err)
+	}
+}
+
+func (api *API) handleCreateScimUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var user scim.User
+		if err := decodeScim(w, r, &user); err != nil {
+			api.sendScimError(w, http.StatusBadRequest, err)
+			return
+		}
+		created, err := api.ScimDirectory.CreateUser(user)
+		if err == nil {
+			api.Logger.Info("provisioned scim user", zap.String("user_name", created.UserName))
+		}
+		api.sendScimUser(w, r, http.StatusCreated, created, err)
+	}
+}
+
+func (api *API) handleReplaceScimUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var user scim.User
+		if err := decodeScim(w, r, &user); err != nil {
+			api.sendScimError(w, http.StatusBadRequest, err)
+			return
+		}
+		replaced, err := api.ScimDirectory.ReplaceUser(chi.URLParam(r, "id"), user)
+		api.sendScimUser(w, r, http.StatusOK, replaced, err)
+	}
+}
+
+func (api *API) handlePatchScimUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req scim.PatchRequest
+		if err := decodeScim(w, r, &req); err != nil {
+			api.sendScimError(w, http.StatusBadRequest, err)
+			return
+		}
+		patched, err := api.ScimDirectory.PatchUser(chi.URLParam(r, "id"), req.Operations)
+		api.sendScimUser(w, r, http.StatusOK, patched, err)
+	}
+}
+
+func (api *API) handleDeleteScimUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := api.ScimDirectory.DeleteUser(id); err != nil {
+			api.sendScimError(w, scimErrorStatus(err), err)
+			return
+		}
+		api.Logger.Info("deprovisioned scim user", zap.String("user_id", id))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (api *API) handleListScimGroups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, startIndex, count, err := api.parseScimListQuery(r)
+		if err != nil {
+			api.sendScimError(w, http.StatusBadRequest, err)
+			return
+		}
+		groups, total, err := api.ScimDirectory.ListGroups(filter, startIndex, count)
+		if err != nil {
+			api.sendScimError(w, scimErrorStatus(err), err)
+			return
+		}
+		// Identity providers may exclude the members of large groups from list responses
+		excludeMembers := strings.EqualFold(r.URL.Query().Get("excludedAttributes"), "members")
+		for _, g := range groups {
+			g.Meta.Location = scimLocation(r, "Groups", g.ID)
+			if excludeMembers {
+				g.Members = nil
+			}
+		}
+		api.sendScim(w, http.StatusOK, scim.ListResponse{
+			Schemas:      []string{scim.SchemaListResponse},
+			TotalResults: total,
+			StartIndex:   startIndex,
+			ItemsPerPage: len(groups),
+			Resources:    groups,
+		})
+	}
+}
+
+func (api *API) handleGetScimGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		group, err := api.ScimDirectory.GetGroup(chi.URLParam(r, "id"))
+		api.sendScimGroup(w, r, http.StatusOK, group, err)
+	}
+}
+
+func (api *API) handleCreateScimGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var group scim.Group
+		if err := decodeScim(w, r, &group); err != nil {
+			api.sendScimError(w, http.StatusBadRequest, err)
+			return
+		}
+		created, err := api.ScimDirectory.CreateGroup(group)
+		if err == nil {
+			api.Logger.Info("provisioned scim group", zap.String("group", created.DisplayName))
+		}
+		api.sendScimGroup(w, r, http.StatusCreated, created, err)
+	}
+}
+
+func (api *API) handleReplaceScimGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var group scim.Group
+		if err := decodeScim(w, r, &group); err != nil {
+			api.sendScimError(w, http.StatusBadRequest, err)
+			return
+		}
+		replaced, err := api.ScimDirectory.ReplaceGroup(chi.URLParam(r, "id"), group)
+		api.sendScimGroup(w, r, http.StatusOK, replaced, err)
+	}
+}
+
+func (api *API) handlePatchScimGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req scim.PatchRequest
+		if err := decodeScim(w, r, &req); err != nil {
+			api.sendScimError(w, http.StatusBadRequest, err)
+			return
+		}
+		patched, err := api.ScimDirectory.PatchGroup(chi.URLParam(r, "id"), req.Operations)
+		api.sendScimGroup(w, r, http.StatusOK, patched, err)
+	}
+}
+
+func (api *API) handleDeleteScimGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := api.ScimDirectory.DeleteGroup(id); err != nil {
+			api.sendScimError(w, scimErrorStatus(err), err)
+			return
+		}
+		api.Logger.Info("deprovisioned scim group", zap.String("group_id", id))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseScimListQuery parses the filter and the pagination parameters. The count is capped at the configured max
+// results.
+func (api *API) parseScimListQuery(r *http.Request) (scim.Filter, int, int, error) {
+	query := r.URL.Query()
+	filter, err := scim.ParseFilter(query.Get("filter"))
+	if err != nil {
+		return scim.Filter{}, 0, 0, err
+	}
+
+	startIndex, count := 1, api.Cfg.SCIM.MaxResults
+	if str := query.Get("startIndex"); str != "" {
+		if startIndex, err = strconv.Atoi(str); err != nil {
+			return scim.Filter{}, 0, 0, fmt.Errorf("%w: startIndex must be an integer", scim.ErrInvalidValue)
+		}
+		if startIndex < 1 {
+			startIndex = 1
+		}
+	}
+	if str := query.Get("count"); str != "" {
+		if count, err = strconv.Atoi(str); err != nil {
+			return scim.Filter{}, 0, 0, fmt.Errorf("%w: count must be an integer", scim.ErrInvalidValue)
+		}
+		if count < 0 {
+			count = 0
+		}
+		if count > api.Cfg.SCIM.MaxResults {
+			count = api.Cfg.SCIM.MaxResults
+		}
+	}
+
+	return filter, startIndex, count, nil
+}
+
+func (api *API) sendScimUser(w http.ResponseWriter, r *http.Request, status int, user *scim.User, err error) {
+	if err != nil {
+		api.sendScimError(w, scimErrorStatus(err), err)
+		return
+	}
+	user.Meta.Location = scimLocation(r, "Users", user.ID)
+	w.Header().Set("Location", user.Meta.Location)
+	api.sendScim(w, status, user)
+}
+
+func (api *API) sendScimGroup(w http.ResponseWriter, r *http.Request, status int, group *scim.Group, err error) {
+	if err != nil {
+		api.sendScimError(w, scimErrorStatus(err), err)
+		return
+	}
+	group.Meta.Location = scimLocation(r, "Groups", group.ID)
+	w.Header().Set("Location", group.Meta.Location)
+	api.sendScim(w, status, group)
+}
+
+func (api *API) sendScim(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		api.Logger.Debug("failed to write scim response", zap.Error(err))
+	}
+}
+
+func (api *API) sendScimError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		api.Logger.Error("failed to handle scim request", zap.Error(err))
+	} else {
+		api.Logger.Debug("rejected scim request", zap.Int("status", status), zap.Error(err))
+	}
+	api.sendScim(w, status, scim.ErrorResponse{
+		Schemas:  []string{scim.SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scim.ScimType(err),
+		Detail:   err.Error(),
+	})
+}
+
+// scimErrorStatus returns the HTTP status for errors returned by the SCIM directory
+func scimErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, scim.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, scim.ErrUniqueness):
+		return http.StatusConflict
+	case scim.ScimType(err) != "":
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// decodeScim decodes a SCIM request body. Identity providers send the content type application/scim+json, which is
+// why rest.Decode can't be used.
+func decodeScim(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, scimMaxBodySize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: failed to parse request body: %v", scim.ErrInvalidValue, err)
+	}
+	return nil
+}
+
+// scimLocation returns the absolute URL of a SCIM resource
+func scimLocation(r *http.Request, resourceType string, id string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%v://%v/scim/v2/%v/%v", scheme, r.Host, resourceType, id)
+}
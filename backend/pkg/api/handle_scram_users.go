@@ -0,0 +1,174 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type upsertScramCredentialRequest struct {
+	owl.UpsertScramCredentialRequest
+}
+
+func (u *upsertScramCredentialRequest) OK() error {
+	if u.Username == "" {
+		return fmt.Errorf("username must be set")
+	}
+	if u.Mechanism == "" {
+		return fmt.Errorf("mechanism must be set")
+	}
+	if u.Password == "" {
+		return fmt.Errorf("password must be set")
+	}
+	return nil
+}
+
+// scramStatus maps errors of SCRAM credential operations to the http status code
+func scramStatus(err error) int {
+	switch {
+	case errors.Is(err, owl.ErrInvalidScramCredential):
+		return http.StatusBadRequest
+	case errors.Is(err, owl.ErrScramCredentialNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, sarama.ErrUnsupportedVersion):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// checkScramPermission sends a forbidden error and returns false if the requester is not allowed to list SCRAM users
+// or to edit the given user's credentials
+func (api *API) checkScramPermission(w http.ResponseWriter, r *http.Request, editedUser string) bool {
+	isAllowed, restErr := api.Hooks.Owl.CanListScramUsers(r.Context())
+	if restErr == nil && isAllowed && editedUser != "" {
+		isAllowed, restErr = api.Hooks.Owl.CanEditScramUser(r.Context(), editedUser)
+	}
+	if restErr != nil {
+		rest.SendRESTError(w, r, api.Logger, restErr)
+		return false
+	}
+	if !isAllowed {
+		restErr := &rest.Error{
+			Err:      fmt.Errorf("requester has no permissions to access scram users"),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions to access SCRAM users",
+			IsSilent: false,
+		}
+		rest.SendRESTError(w, r, api.Logger, restErr)
+		return false
+	}
+
+	return true
+}
+
+func (api *API) handleGetScramUsers() http.HandlerFunc {
+	type response struct {
+		Users []owl.ScramUser `json:"users"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !api.checkScramPermission(w, r, "") {
+			return
+		}
+
+		users, err := api.OwlSvc.ListScramUsers(r.Context())
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   scramStatus(err),
+				Message:  fmt.Sprintf("Could not list SCRAM users: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Users: users})
+	}
+}
+
+// handleUpsertScramCredential creates a SCRAM credential or replaces the user's credential of the same mechanism. The
+// password is salted and hashed before it's sent to Kafka and never returned.
+func (api *API) handleUpsertScramCredential() http.HandlerFunc {
+	type response struct {
+		Username   string              `json:"username"`
+		Credential owl.ScramCredential `json:"credential"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req upsertScramCredentialRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		if !api.checkScramPermission(w, r, req.Username) {
+			return
+		}
+
+		credential, err := api.OwlSvc.UpsertScramCredential(r.Context(), req.UpsertScramCredentialRequest)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   scramStatus(err),
+				Message:  fmt.Sprintf("Could not upsert SCRAM credential: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Username: req.Username, Credential: *credential})
+	}
+}
+
+// handleDeleteScramCredential deletes the user's SCRAM credential of the mechanism given in the query
+func (api *API) handleDeleteScramCredential() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := chi.URLParam(r, "username")
+		logger := api.Logger.With(zap.String("username", username))
+
+		mechanism := r.URL.Query().Get("mechanism")
+		if mechanism == "" {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("mechanism not given"),
+				Status:   http.StatusBadRequest,
+				Message:  "The mechanism of the credential which shall be deleted must be given",
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		if !api.checkScramPermission(w, r, username) {
+			return
+		}
+
+		err := api.OwlSvc.DeleteScramCredential(r.Context(), username, mechanism)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   scramStatus(err),
+				Message:  fmt.Sprintf("Could not delete SCRAM credential: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
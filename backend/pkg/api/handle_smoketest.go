@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// errSmokeTestDisabled is returned by the smoke test endpoint unless smoke tests have been enabled
+var errSmokeTestDisabled = &rest.Error{
+	Err:      fmt.Errorf("smoke test is disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "Smoke tests are not available because they have not been enabled",
+	IsSilent: true,
+}
+
+type smokeTestRequest struct {
+	TimeoutMs int64 `json:"timeoutMs"` // Optional, capped by the configured maximum
+}
+
+func (s *smokeTestRequest) OK() error {
+	if s.TimeoutMs < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// handleRunSmokeTest produces a marker record to a configured test topic and consumes it back. The response
+// contains the measured latencies and, if the cluster didn't pass the test, the step which has failed.
+func (api *API) handleRunSmokeTest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		if !api.Cfg.SmokeTest.Enabled {
+			rest.SendRESTError(w, r, logger, errSmokeTestDisabled)
+			return
+		}
+
+		var req smokeTestRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		if !api.Cfg.SmokeTest.IsTestTopic(topicName) {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("topic is not configured as smoke test topic"),
+				Status:   http.StatusForbidden,
+				Message:  "Smoke tests can only be run against the configured test topics",
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// The marker is produced and consumed, hence both permissions are required
+		canPublish, restErr := api.Hooks.Owl.CanPublishTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		canView, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canPublish || !canView {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to publish and view messages of the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to publish and view messages of this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		timeout := api.Cfg.SmokeTest.Timeout
+		if req.TimeoutMs > 0 {
+			timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+			if timeout > api.Cfg.SmokeTest.MaxTimeout {
+				timeout = api.Cfg.SmokeTest.MaxTimeout
+			}
+		}
+		res, err := api.OwlSvc.RunSmokeTest(r.Context(), owl.SmokeTestRequest{
+			TopicName: topicName,
+			Timeout:   timeout,
+		})
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  fmt.Sprintf("Could not run smoke test: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !res.Success {
+			logger.Warn("smoke test failed",
+				zap.String("failed_step", res.FailedStep),
+				zap.String("error", res.Error))
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
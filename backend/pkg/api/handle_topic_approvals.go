@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/approval"
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+var errTopicApprovalsDisabled = &rest.Error{
+	Err:      fmt.Errorf("topic approvals are disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "Topic approvals are disabled",
+	IsSilent: true,
+}
+
+var errTopicApprovalsUnauthenticated = &rest.Error{
+	Err:      fmt.Errorf("anonymous requesters can't take part in topic approvals"),
+	Status:   http.StatusUnauthorized,
+	Message:  "You must be logged in, because this operation requires an approval by a different user",
+	IsSilent: true,
+}
+
+type topicApprovalsResponse struct {
+	Requests []approval.Request `json:"requests"`
+}
+
+// topicApprovalError converts errors of the approval service and of applied operations into rest errors
+func topicApprovalError(err error, message string) *rest.Error {
+	status := topicManagementStatus(err)
+	switch {
+	case errors.Is(err, approval.ErrRequestNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, approval.ErrSelfApproval):
+		status = http.StatusForbidden
+	case errors.Is(err, approval.ErrDuplicateRequest):
+		status = http.StatusConflict
+	case errors.Is(err, approval.ErrTooManyRequests):
+		status = http.StatusTooManyRequests
+	}
+
+	return &rest.Error{
+		Err:      err,
+		Status:   status,
+		Message:  fmt.Sprintf("%v: %v", message, err.Error()),
+		IsSilent: false,
+	}
+}
+
+// requiresApproval returns true if the operation must be approved by a second user before it's applied
+func (api *API) requiresApproval(operation string) bool {
+	return api.ApprovalSvc != nil && api.ApprovalSvc.RequiresApproval(operation)
+}
+
+// submitForApproval stores the operation as pending request and responds with 202 Accepted. The payload is the
+// operation's request, it's nil for operations which are fully described by their topic name.
+func (api *API) submitForApproval(w http.ResponseWriter, r *http.Request, logger *zap.Logger, operation string, topicName string, payload interface{}) {
+	requester := authorization.SubjectFromContext(r.Context()).Name
+	if requester == "" {
+		rest.SendRESTError(w, r, logger, errTopicApprovalsUnauthenticated)
+		return
+	}
+	var body json.RawMessage
+	if payload != nil {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			rest.SendRESTError(w, r, logger, topicApprovalError(err, "Could not request approval"))
+			return
+		}
+	}
+	req, err := api.ApprovalSvc.Submit(approval.Request{
+		ClusterName: api.clusterName,
+		Operation:   operation,
+		TopicName:   topicName,
+		Payload:     body,
+		RequestedBy: requester,
+	})
+	if err != nil {
+		rest.SendRESTError(w, r, logger, topicApprovalError(err, "Could not request approval"))
+		return
+	}
+
+	rest.SendResponse(w, r, logger, http.StatusAccepted, req)
+}
+
+// canApplyTopicOperation checks the permission which is required to request the operation. Approvers and users who
+// reject other users' requests need the same permission.
+func (api *API) canApplyTopicOperation(ctx context.Context, operation string, topicName string) (bool, *rest.Error) {
+	switch operation {
+	case approval.OperationCreateTopic:
+		return api.Hooks.Owl.CanCreateTopic(ctx, topicName)
+	case approval.OperationDeleteTopic:
+		return api.Hooks.Owl.CanDeleteTopic(ctx, topicName)
+	default:
+		return api.Hooks.Owl.CanEditTopicConfig(ctx, topicName)
+	}
+}
+
+// applyTopicOperation applies the operation of an approved request
+func (api *API) applyTopicOperation(ctx context.Context, req *approval.Request) error {
+	switch req.Operation {
+	case approval.OperationCreateTopic:
+		var createReq owl.CreateTopicRequest
+		if err := json.Unmarshal(req.Payload, &createReq); err != nil {
+			return fmt.Errorf("failed to decode request: %w", err)
+		}
+		_, err := api.OwlSvc.CreateTopic(ctx, createReq)
+		return err
+	case approval.OperationDeleteTopic:
+		return api.OwlSvc.DeleteTopic(ctx, req.TopicName)
+	case approval.OperationAddPartitions:
+		var addReq owl.AddPartitionsRequest
+		if err := json.Unmarshal(req.Payload, &addReq); err != nil {
+			return fmt.Errorf("failed to decode request: %w", err)
+		}
+		_, err := api.OwlSvc.AddPartitions(ctx, addReq)
+		return err
+	}
+
+	return fmt.Errorf("unknown operation '%v'", req.Operation)
+}
+
+// handleGetTopicApprovals lists the pending requests of topics which the requester is allowed to see
+func (api *API) handleGetTopicApprovals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.ApprovalSvc == nil {
+			rest.SendRESTError(w, r, api.Logger, errTopicApprovalsDisabled)
+			return
+		}
+
+		pending := api.ApprovalSvc.ListPending(api.clusterName)
+		visible := make([]approval.Request, 0, len(pending))
+		for _, req := range pending {
+			canSee, restErr := api.Hooks.Owl.CanSeeTopic(r.Context(), req.TopicName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			if canSee {
+				visible = append(visible, req)
+			}
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, topicApprovalsResponse{Requests: visible})
+	}
+}
+
+// handleApproveTopicRequest applies a pending request on behalf of a second user, who needs the same permission as
+// the requester
+func (api *API) handleApproveTopicRequest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := chi.URLParam(r, "requestID")
+		logger := api.Logger.With(zap.String("request_id", requestID))
+		if api.ApprovalSvc == nil {
+			rest.SendRESTError(w, r, logger, errTopicApprovalsDisabled)
+			return
+		}
+		approver := authorization.SubjectFromContext(r.Context()).Name
+		if approver == "" {
+			rest.SendRESTError(w, r, logger, errTopicApprovalsUnauthenticated)
+			return
+		}
+
+		req, err := api.ApprovalSvc.Get(api.clusterName, requestID)
+		if err != nil {
+			rest.SendRESTError(w, r, logger, topicApprovalError(err, "Could not approve request"))
+			return
+		}
+		logger = logger.With(zap.String("topic_name", req.TopicName), zap.String("operation", req.Operation))
+		canApply, restErr := api.canApplyTopicOperation(r.Context(), req.Operation, req.TopicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canApply {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to apply the requested operation"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to approve this request",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		approved, err := api.ApprovalSvc.Approve(r.Context(), api.clusterName, requestID, approver, api.applyTopicOperation)
+		if err != nil {
+			rest.SendRESTError(w, r, logger, topicApprovalError(err, "Could not approve request"))
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, approved)
+	}
+}
+
+// handleRejectTopicRequest discards a pending request. Requesters may reject their own requests, all other users need
+// the permission which is required to request the operation.
+func (api *API) handleRejectTopicRequest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := chi.URLParam(r, "requestID")
+		logger := api.Logger.With(zap.String("request_id", requestID))
+		if api.ApprovalSvc == nil {
+			rest.SendRESTError(w, r, logger, errTopicApprovalsDisabled)
+			return
+		}
+		rejecter := authorization.SubjectFromContext(r.Context()).Name
+		if rejecter == "" {
+			rest.SendRESTError(w, r, logger, errTopicApprovalsUnauthenticated)
+			return
+		}
+
+		req, err := api.ApprovalSvc.Get(api.clusterName, requestID)
+		if err != nil {
+			rest.SendRESTError(w, r, logger, topicApprovalError(err, "Could not reject request"))
+			return
+		}
+		if req.RequestedBy != rejecter {
+			canApply, restErr := api.canApplyTopicOperation(r.Context(), req.Operation, req.TopicName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+			if !canApply {
+				restErr := &rest.Error{
+					Err:      fmt.Errorf("requester has no permissions to apply the requested operation"),
+					Status:   http.StatusForbidden,
+					Message:  "You don't have permissions to reject this request",
+					IsSilent: false,
+				}
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+		}
+
+		rejected, err := api.ApprovalSvc.Reject(api.clusterName, requestID, rejecter)
+		if err != nil {
+			rest.SendRESTError(w, r, logger, topicApprovalError(err, "Could not reject request"))
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, rejected)
+	}
+}
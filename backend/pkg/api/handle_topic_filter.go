@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type testFilterRequest struct {
+	PartitionID           int32  `json:"partitionId"` // -1 for all partition ids
+	SampleSize            uint16 `json:"sampleSize"`
+	FilterInterpreterCode string `json:"filterInterpreterCode"` // Base64 encoded code
+	FilterTimeoutMs       int    `json:"filterTimeoutMs"`       // Optional, capped by the configured maximum
+}
+
+func (t *testFilterRequest) OK() error {
+	if t.PartitionID < -1 {
+		return fmt.Errorf("partitionID is smaller than -1")
+	}
+
+	if t.SampleSize <= 0 || t.SampleSize > 100 {
+		return fmt.Errorf("sample size must be between 1 and 100")
+	}
+
+	if t.FilterInterpreterCode == "" {
+		return fmt.Errorf("filter interpreter code is required")
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(t.FilterInterpreterCode); err != nil {
+		return fmt.Errorf("failed to decode interpreter code %w", err)
+	}
+
+	if t.FilterTimeoutMs < 0 {
+		return fmt.Errorf("filter timeout must not be negative")
+	}
+
+	return nil
+}
+
+// handleTestFilter runs the submitted filter code against a small sample of recent messages and returns the
+// evaluation result for each message, so that users can debug their filter code before starting a full scan.
+func (api *API) handleTestFilter() http.HandlerFunc {
+	type response struct {
+		LintFindings []filter.Finding      `json:"lintFindings"`
+		Result       *owl.FilterTestResult `json:"result"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		var req testFilterRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		// Check if logged in user is allowed to view messages and use filters in the given topic
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		canUseFilters, restErr := api.Hooks.Owl.CanUseMessageSearchFilters(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages || !canUseFilters {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to use message filters in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to use message filters in this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		decoded, _ := base64.StdEncoding.DecodeString(req.FilterInterpreterCode) // Error has been checked in validation function
+		code := string(decoded)
+
+		findings := filter.Lint(code, api.Cfg.Filter)
+		if filter.HasErrors(findings) {
+			rest.SendResponse(w, r, logger, http.StatusOK, response{LintFindings: findings})
+			return
+		}
+
+		decrypter, restErr := api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		masker, restErr := api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		limits := api.Cfg.Filter.Limits(time.Duration(req.FilterTimeoutMs) * time.Millisecond)
+		requesterBudget := api.FilterBudgets.ForRequester(requesterID(r))
+		budget := filter.NewBudget("filter test", api.Cfg.Filter.MaxSearchExecutionTime, requesterBudget)
+		result, err := api.OwlSvc.TestFilter(ctx, topicName, req.PartitionID, req.SampleSize, code, limits, budget, decrypter, masker)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, filter.ErrBudgetExceeded) {
+				status = http.StatusTooManyRequests
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  fmt.Sprintf("Could not test filter code: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		res := response{
+			LintFindings: findings,
+			Result:       result,
+		}
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+// handleGetFilterTypings returns a TypeScript declaration of the filter function arguments with key, value and
+// header types inferred from recent messages, so that the frontend's code editor can offer autocompletion.
+func (api *API) handleGetFilterTypings() http.HandlerFunc {
+	const sampleSize = 50
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		// The inferred types expose the message structure, hence we require the same permissions as for filtering
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		canUseFilters, restErr := api.Hooks.Owl.CanUseMessageSearchFilters(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages || !canUseFilters {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to use message filters in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to use message filters in this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		decrypter, restErr := api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		masker, restErr := api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		typings, err := api.OwlSvc.GetFilterTypings(ctx, topicName, sampleSize, decrypter, masker)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  fmt.Sprintf("Could not generate filter typings: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, typings)
+	}
+}
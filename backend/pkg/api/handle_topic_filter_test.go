@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHandleTestFilterEnforcesRequesterBudget(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 1, 1, nil, false))
+	_, err := cluster.Produce(kafka.ProduceRecord{TopicName: "orders", Value: []byte(`{"id": 1}`)}, kafka.ProduceOptions{})
+	require.NoError(t, err)
+
+	cfg := &Config{}
+	cfg.Filter.SetDefaults()
+	maskingSvc, err := masking.NewService(masking.Config{})
+	require.NoError(t, err)
+	api := &API{
+		Cfg:           cfg,
+		Logger:        zap.NewNop(),
+		OwlSvc:        owl.NewService(cluster, nil, nil, nil, owl.ConsumeLimits{}, zap.NewNop()),
+		FilterBudgets: filter.NewBudgetRegistry(time.Second, time.Hour),
+		MaskingSvc:    maskingSvc,
+		Hooks:         newDefaultHooks(),
+	}
+	router := chi.NewRouter()
+	router.Post("/topics/{topicName}/filter-test", api.handleTestFilter())
+
+	testFilter := func() *httptest.ResponseRecorder {
+		code := base64.StdEncoding.EncodeToString([]byte("return value.id == 1"))
+		body := `{"partitionId": -1, "sampleSize": 10, "filterInterpreterCode": "` + code + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/topics/orders/filter-test", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := testFilter()
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var res struct {
+		Result owl.FilterTestResult `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, 1, res.Result.MatchedCount)
+
+	// httptest requests are sent from 192.0.2.1, which is the requester id of anonymous requests
+	require.Error(t, api.FilterBudgets.ForRequester("192.0.2.1").Consume(2*time.Second))
+	rec = testFilter()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "budget exceeded")
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/approval"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type createTopicRequest struct {
+	owl.CreateTopicRequest
+}
+
+func (c *createTopicRequest) OK() error {
+	if err := owl.ValidateTopicName(c.TopicName); err != nil {
+		return err
+	}
+	if c.PartitionCount < 1 {
+		return fmt.Errorf("partition count must be at least 1")
+	}
+	if c.ReplicationFactor < 1 {
+		return fmt.Errorf("replication factor must be at least 1")
+	}
+	for name := range c.Configs {
+		if name == "" {
+			return fmt.Errorf("config names must not be empty")
+		}
+	}
+
+	return nil
+}
+
+type addPartitionsRequest struct {
+	PartitionCount int32 `json:"partitionCount"` // Total partition count after the change
+	DryRun         bool  `json:"dryRun"`
+}
+
+func (a *addPartitionsRequest) OK() error {
+	if a.PartitionCount < 1 {
+		return fmt.Errorf("partition count must be at least 1")
+	}
+
+	return nil
+}
+
+type alterTopicConfigRequest struct {
+	Configs map[string]*string `json:"configs"` // A null value resets the config to its default
+	DryRun  bool               `json:"dryRun"`
+}
+
+func (a *alterTopicConfigRequest) OK() error {
+	if len(a.Configs) == 0 {
+		return fmt.Errorf("at least one config must be given")
+	}
+	for name := range a.Configs {
+		if name == "" {
+			return fmt.Errorf("config names must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// topicManagementStatus maps errors of topic management operations to the http status code
+func topicManagementStatus(err error) int {
+	switch {
+	case errors.Is(err, owl.ErrTopicNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, owl.ErrTopicAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, owl.ErrInvalidTopicRequest):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func (api *API) handleCreateTopic() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createTopicRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		logger := api.Logger.With(zap.String("topic_name", req.TopicName))
+
+		canCreate, restErr := api.Hooks.Owl.CanCreateTopic(r.Context(), req.TopicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canCreate {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to create the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to create this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		if !req.DryRun && api.requiresApproval(approval.OperationCreateTopic) {
+			// Invalid requests are rejected right away, rather than once they have been approved
+			dryRunReq := req.CreateTopicRequest
+			dryRunReq.DryRun = true
+			if _, err := api.OwlSvc.CreateTopic(r.Context(), dryRunReq); err != nil {
+				restErr := &rest.Error{
+					Err:      err,
+					Status:   topicManagementStatus(err),
+					Message:  fmt.Sprintf("Could not create topic: %v", err.Error()),
+					IsSilent: false,
+				}
+				rest.SendRESTError(w, r, logger, restErr)
+				return
+			}
+			api.submitForApproval(w, r, logger, approval.OperationCreateTopic, req.TopicName, req.CreateTopicRequest)
+			return
+		}
+
+		res, err := api.OwlSvc.CreateTopic(r.Context(), req.CreateTopicRequest)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   topicManagementStatus(err),
+				Message:  fmt.Sprintf("Could not create topic: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		status := http.StatusCreated
+		if req.DryRun {
+			status = http.StatusOK
+		}
+		rest.SendResponse(w, r, logger, status, res)
+	}
+}
+
+func (api *API) handleDeleteTopic() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		canDelete, restErr := api.Hooks.Owl.CanDeleteTopic(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canDelete {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to delete the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to delete this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		if api.requiresApproval(approval.OperationDeleteTopic) {
+			api.submitForApproval(w, r, logger, approval.OperationDeleteTopic, topicName, nil)
+			return
+		}
+
+		err := api.OwlSvc.DeleteTopic(r.Context(), topicName)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   topicManagementStatus(err),
+				Message:  fmt.Sprintf("Could not delete topic: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleAddPartitions increases the partition count of a topic, which requires the permission to edit the topic's
+// config. Keys are mapped to different partitions afterwards, which breaks the ordering of keyed messages.
+func (api *API) handleAddPartitions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		var req addPartitionsRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canEdit, restErr := api.Hooks.Owl.CanEditTopicConfig(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canEdit {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to edit the config of the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to add partitions to this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		addReq := owl.AddPartitionsRequest{
+			TopicName:      topicName,
+			PartitionCount: req.PartitionCount,
+			DryRun:         req.DryRun || api.requiresApproval(approval.OperationAddPartitions),
+		}
+		res, err := api.OwlSvc.AddPartitions(r.Context(), addReq)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   topicManagementStatus(err),
+				Message:  fmt.Sprintf("Could not add partitions: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !req.DryRun && addReq.DryRun {
+			// The request has been validated, the partitions are added once it has been approved
+			addReq.DryRun = false
+			api.submitForApproval(w, r, logger, approval.OperationAddPartitions, topicName, addReq)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
+
+// handleAlterTopicConfig sets or resets the given topic configs. With dryRun enabled the changes are validated by
+// the broker and the config keys which would change are returned, without applying them.
+func (api *API) handleAlterTopicConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		var req alterTopicConfigRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canEdit, restErr := api.Hooks.Owl.CanEditTopicConfig(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canEdit {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to edit the config of the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to edit the config of this topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		res, err := api.OwlSvc.AlterTopicConfig(r.Context(), owl.AlterTopicConfigRequest{
+			TopicName: topicName,
+			Configs:   req.Configs,
+			DryRun:    req.DryRun,
+		})
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   topicManagementStatus(err),
+				Message:  fmt.Sprintf("Could not alter topic config: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, res)
+	}
+}
@@ -8,8 +8,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
 	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/query"
+	"github.com/cloudhut/kowl/backend/pkg/usage"
 
+	"github.com/Shopify/sarama"
 	"github.com/cloudhut/common/rest"
 )
 
@@ -22,10 +27,50 @@ type GetTopicMessagesResponse struct {
 // used in Kowl business to implement the hooks.
 type ListMessagesRequest struct {
 	TopicName             string `json:"topicName"`
-	StartOffset           int64  `json:"startOffset"` // -1 for recent (newest - results), -2 for oldest offset, -3 for newest
+	StartOffset           int64  `json:"startOffset"` // -1 for recent (newest - results), -2 for oldest offset, -3 for newest, -4 for timestamp
 	PartitionID           int32  `json:"partitionId"` // -1 for all partition ids
 	MaxResults            uint16 `json:"maxResults"`
 	FilterInterpreterCode string `json:"filterInterpreterCode"` // Base64 encoded code
+	FilterTimeoutMs       int    `json:"filterTimeoutMs"`       // Optional per message timeout, capped by the configured maximum
+	TemplateName          string `json:"templateName"`          // Optional consume template the search is based on
+
+	// Query is an optional search query, e.g. partition=3 AND value.status="FAILED". Messages must match both the
+	// query and the filter code.
+	Query string `json:"query"`
+
+	// StartTimestamp and EndTimestamp (unix milliseconds) are used if StartOffset is -4. EndTimestamp is optional.
+	StartTimestamp int64 `json:"startTimestamp"`
+	EndTimestamp   int64 `json:"endTimestamp"`
+
+	// LiveTail streams new messages until the connection is closed. StartOffset and MaxResults are ignored.
+	LiveTail             bool `json:"liveTail"`
+	MaxMessagesPerSecond int  `json:"maxMessagesPerSecond"` // Optional, capped by the configured maximum
+
+	// OrderByTimestamp returns the messages of all partitions ordered by timestamp rather than by arrival
+	OrderByTimestamp bool `json:"orderByTimestamp"`
+
+	// OrderBy returns the first MaxResults messages of the scanned range sorted by offset, timestamp or size. Later
+	// keys break ties of earlier keys. The messages are sent once the scan has completed.
+	OrderBy []owl.SortKey `json:"orderBy"`
+
+	// IsolationLevel is either read_uncommitted (default) or read_committed, which excludes messages of aborted
+	// and open transactions
+	IsolationLevel string `json:"isolationLevel"`
+
+	// CanonicalJSON renders JSON based keys, values and headers deterministically, so that results can be compared
+	CanonicalJSON kafka.CanonicalJSONOptions `json:"canonicalJson"`
+
+	// BinaryEncoding is either base64 (default) or hexdump, which is applied to binary keys, values and headers
+	BinaryEncoding kafka.BinaryEncoding `json:"binaryEncoding"`
+
+	// KeyDecoder and ValueDecoder force a decoder (e.g. text or avro) instead of detecting the type of keys and
+	// values automatically. Payloads which can't be decoded fall back to the automatic detection.
+	KeyDecoder   kafka.DecoderOverride `json:"keyDecoder"`
+	ValueDecoder kafka.DecoderOverride `json:"valueDecoder"`
+
+	// Projection is an optional list of JSONPath expressions. If given, flat rows with one column per expression
+	// are returned instead of full messages.
+	Projection []string `json:"projection"`
 }
 
 func (l *ListMessagesRequest) OK() error {
@@ -33,25 +78,102 @@ func (l *ListMessagesRequest) OK() error {
 		return fmt.Errorf("topic name is required")
 	}
 
-	if l.StartOffset < -3 {
-		return fmt.Errorf("start offset is smaller than -3")
+	if l.StartOffset < -4 {
+		return fmt.Errorf("start offset is smaller than -4")
+	}
+
+	if l.StartOffset == owl.StartOffsetTimestamp {
+		if l.StartTimestamp <= 0 {
+			return fmt.Errorf("start timestamp is required when searching by timestamp")
+		}
+		if l.EndTimestamp != 0 && l.EndTimestamp < l.StartTimestamp {
+			return fmt.Errorf("end timestamp must not be before the start timestamp")
+		}
 	}
 
 	if l.PartitionID < -1 {
 		return fmt.Errorf("partitionID is smaller than -1")
 	}
 
-	if l.MaxResults <= 0 || l.MaxResults > 500 {
+	if !l.LiveTail && (l.MaxResults <= 0 || l.MaxResults > 500) {
 		return fmt.Errorf("max results must be between 1 and 500")
 	}
 
+	if l.MaxMessagesPerSecond < 0 {
+		return fmt.Errorf("max messages per second must not be negative")
+	}
+
+	if l.FilterTimeoutMs < 0 {
+		return fmt.Errorf("filter timeout must not be negative")
+	}
+
+	// The merge would have to wait for new messages in every partition
+	if l.OrderByTimestamp && (l.LiveTail || l.StartOffset == owl.StartOffsetNewest) {
+		return fmt.Errorf("ordering by timestamp is not supported when consuming from the newest offset")
+	}
+
+	if len(l.OrderBy) > 0 {
+		if l.LiveTail || l.StartOffset == owl.StartOffsetNewest {
+			return fmt.Errorf("sorting is not supported when consuming from the newest offset")
+		}
+		if l.OrderByTimestamp {
+			return fmt.Errorf("order by and order by timestamp must not be combined")
+		}
+		if err := owl.ValidateSortKeys(l.OrderBy); err != nil {
+			return err
+		}
+	}
+
+	if _, err := parseIsolationLevel(l.IsolationLevel); err != nil {
+		return err
+	}
+
+	if err := l.BinaryEncoding.Validate(); err != nil {
+		return err
+	}
+
+	if err := l.KeyDecoder.Validate(); err != nil {
+		return fmt.Errorf("invalid key decoder: %w", err)
+	}
+	if err := l.ValueDecoder.Validate(); err != nil {
+		return fmt.Errorf("invalid value decoder: %w", err)
+	}
+
+	if _, err := newMessageProjection(l.Projection); err != nil {
+		return err
+	}
+
 	if _, err := l.DecodeInterpreterCode(); err != nil {
 		return fmt.Errorf("failed to decode interpreter code %w", err)
 	}
 
+	if _, err := parseSearchQuery(l.Query); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Isolation levels of message searches
+const (
+	isolationLevelReadUncommitted = "read_uncommitted"
+	isolationLevelReadCommitted   = "read_committed"
+)
+
+// parseIsolationLevel returns the isolation level of a search request, which reads uncommitted messages unless
+// specified otherwise
+func parseIsolationLevel(level string) (sarama.IsolationLevel, error) {
+	switch level {
+	case "", isolationLevelReadUncommitted:
+		return sarama.ReadUncommitted, nil
+	case isolationLevelReadCommitted:
+		return sarama.ReadCommitted, nil
+	default:
+		return sarama.ReadUncommitted, fmt.Errorf("isolation level must be either '%v' or '%v'",
+			isolationLevelReadUncommitted, isolationLevelReadCommitted)
+	}
+}
+
 func (l *ListMessagesRequest) DecodeInterpreterCode() (string, error) {
 	code, err := base64.StdEncoding.DecodeString(l.FilterInterpreterCode)
 	if err != nil {
@@ -117,7 +239,7 @@ func (api *API) handleGetMessages() http.HandlerFunc {
 			return
 		}
 
-		if len(req.FilterInterpreterCode) > 0 {
+		if len(req.FilterInterpreterCode) > 0 || req.Query != "" {
 			canUseMessageSearchFilters, restErr := api.Hooks.Owl.CanUseMessageSearchFilters(r.Context(), req.TopicName)
 			if restErr != nil {
 				sendError(restErr.Message)
@@ -131,21 +253,105 @@ func (api *API) handleGetMessages() http.HandlerFunc {
 
 		interpreterCode, _ := req.DecodeInterpreterCode() // Error has been checked in validation function
 
+		// Searches started from a consume template fall back to the template's filter and are bound to its limits
+		maxSearchExecutionTime := api.Cfg.Filter.MaxSearchExecutionTime
+		maskingProfile := ""
+		if req.TemplateName != "" {
+			template, restErr := api.getConsumeTemplate(r.Context(), req.TemplateName, req.TopicName)
+			if restErr != nil {
+				sendError(restErr.Message)
+				return
+			}
+			if interpreterCode == "" {
+				interpreterCode = template.DefaultFilter
+			}
+			maskingProfile = template.MaskingProfile
+			if template.Limits.MaxMessageCount > 0 && req.MaxResults > template.Limits.MaxMessageCount {
+				req.MaxResults = template.Limits.MaxMessageCount
+			}
+			templateLimit := template.Limits.MaxSearchExecutionTime
+			if templateLimit > 0 && (maxSearchExecutionTime == 0 || templateLimit < maxSearchExecutionTime) {
+				maxSearchExecutionTime = templateLimit
+			}
+		}
+
+		// js() terms of the query run along with the filter code, so that they are linted and limited alike
+		searchQuery, _ := parseSearchQuery(req.Query) // Checked in OK()
+		if searchQuery != nil {
+			interpreterCode = query.CombineFilterCode(interpreterCode, searchQuery.FilterCode())
+		}
+
+		// Lint filter code before we start consuming so that users get feedback about their code as early as possible
+		if interpreterCode != "" {
+			findings := filter.Lint(interpreterCode, api.Cfg.Filter)
+			if len(findings) > 0 {
+				wsClient.writeJSON(struct {
+					Type     string           `json:"type"`
+					Findings []filter.Finding `json:"findings"`
+				}{"filterLint", findings})
+			}
+			if filter.HasErrors(findings) {
+				sendError("Filter code has been rejected, see the lint findings for details")
+				return
+			}
+		}
+
 		// Request messages from kafka and return them once we got all the messages or the context is done
 		listReq := owl.ListMessageRequest{
 			TopicName:             req.TopicName,
 			PartitionID:           req.PartitionID,
 			StartOffset:           req.StartOffset,
-			MessageCount:          req.MaxResults,
+			MessageCount:          int64(req.MaxResults),
 			FilterInterpreterCode: interpreterCode,
+			StartTimestamp:        req.StartTimestamp,
+			EndTimestamp:          req.EndTimestamp,
+			OrderByTimestamp:      req.OrderByTimestamp,
+			SortKeys:              req.OrderBy,
+			CanonicalJSON:         req.CanonicalJSON,
+			BinaryEncoding:        req.BinaryEncoding,
+			KeyDecoder:            req.KeyDecoder,
+			ValueDecoder:          req.ValueDecoder,
+		}
+		if len(req.Projection) == 0 {
+			// Projections are computed from the full values and return small rows only
+			listReq.MaxValueBytes = api.Cfg.PayloadTruncation.maxValueBytes()
+		}
+		listReq.IsolationLevel, _ = parseIsolationLevel(req.IsolationLevel) // Checked in OK()
+		listReq.Decrypter, restErr = api.messageDecrypter(r, req.TopicName)
+		if restErr != nil {
+			sendError(restErr.Message)
+			return
+		}
+		listReq.Masker, restErr = api.messageMasker(r.Context(), req.TopicName, maskingProfile)
+		if restErr != nil {
+			sendError(restErr.Message)
+			return
+		}
+		listReq.Renderer = api.RenderingSvc.Renderer(req.TopicName)
+		applySearchQuery(&listReq, searchQuery)
+		if req.LiveTail {
+			listReq.LiveTail = true
+			listReq.StartOffset = owl.StartOffsetNewest
+			listReq.MaxMessagesPerSecond = api.Cfg.LiveTail.MaxMessagesPerSecond
+			if req.MaxMessagesPerSecond > 0 && req.MaxMessagesPerSecond < listReq.MaxMessagesPerSecond {
+				listReq.MaxMessagesPerSecond = req.MaxMessagesPerSecond
+			}
+		}
+		if interpreterCode != "" {
+			requesterBudget := api.FilterBudgets.ForRequester(requesterID(r))
+			listReq.FilterBudget = filter.NewBudget("search", maxSearchExecutionTime, requesterBudget)
+			listReq.FilterLimits = api.Cfg.Filter.Limits(time.Duration(req.FilterTimeoutMs) * time.Millisecond)
 		}
 		api.Hooks.Owl.PrintListMessagesAuditLog(r, &listReq)
 
-		// Use 30min duration if we want to search a whole topic or forward messages as they arrive
+		// Use 30min duration if we want to search a whole topic, a time range or forward messages as they arrive
 		duration := 18 * time.Second
-		if listReq.FilterInterpreterCode != "" || listReq.StartOffset == owl.StartOffsetNewest {
+		if listReq.IsFiltered() || listReq.StartOffset == owl.StartOffsetNewest || listReq.StartOffset == owl.StartOffsetTimestamp {
 			duration = 30 * time.Minute
 		}
+		if listReq.LiveTail {
+			duration = api.Cfg.LiveTail.MaxDuration
+		}
 		childCtx, cancel := context.WithTimeout(ctx, duration)
 		defer cancel()
 
@@ -158,9 +364,21 @@ func (api *API) handleGetMessages() http.HandlerFunc {
 			messagesConsumed: 0,
 			bytesConsumed:    0,
 		}
+		progress.projection, _ = newMessageProjection(req.Projection) // Checked in OK()
 		progress.Start()
 
+		if listReq.IsFiltered() {
+			api.recordUsage(r, listReq.TopicName, usage.ActionSearch)
+		} else {
+			api.recordUsage(r, listReq.TopicName, usage.ActionBrowse)
+		}
+		searchStart := time.Now()
 		err = api.OwlSvc.ListMessages(childCtx, listReq, progress)
+		api.emitSearchEvent(r, selfEventTypeSearch, listReq.TopicName, time.Since(searchStart), progress.consumedMessages(), err)
+		if err != nil && listReq.LiveTail && childCtx.Err() != nil {
+			// Live tail sessions are always ended by cancellation (user closed the connection or max duration reached)
+			return
+		}
 		if err != nil {
 			progress.OnError(err.Error())
 		}
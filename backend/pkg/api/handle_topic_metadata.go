@@ -0,0 +1,251 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/topicmeta"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+var errTopicMetadataDisabled = &rest.Error{
+	Err:      fmt.Errorf("topic metadata is disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "Topic metadata is disabled",
+	IsSilent: true,
+}
+
+// topicMetadataError converts errors of the topic metadata service into rest errors
+func topicMetadataError(err error, message string) *rest.Error {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, topicmeta.ErrInvalidMetadata):
+		status = http.StatusBadRequest
+	case errors.Is(err, topicmeta.ErrMetadataNotFound):
+		status = http.StatusNotFound
+	}
+
+	return &rest.Error{
+		Err:      err,
+		Status:   status,
+		Message:  fmt.Sprintf("%v: %v", message, err.Error()),
+		IsSilent: false,
+	}
+}
+
+// topicMetadataFilter parses the repeatable 'tag' and the 'owner' query parameters
+func topicMetadataFilter(r *http.Request) topicmeta.Filter {
+	query := r.URL.Query()
+	return topicmeta.Filter{
+		Tags:  query["tag"],
+		Owner: query.Get("owner"),
+	}
+}
+
+// canAccessTopicMetadata checks whether the requester may see the topic and, if edit is true, change its metadata
+func (api *API) canAccessTopicMetadata(r *http.Request, topicName string, edit bool) *rest.Error {
+	canSee, restErr := api.Hooks.Owl.CanSeeTopic(r.Context(), topicName)
+	if restErr != nil {
+		return restErr
+	}
+	if !canSee {
+		return &rest.Error{
+			Err:      fmt.Errorf("requester has no permissions to see the requested topic"),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions to see this topic",
+			IsSilent: false,
+		}
+	}
+
+	if !edit {
+		return nil
+	}
+	canEdit, restErr := api.Hooks.Owl.CanEditTopicMetadata(r.Context(), topicName)
+	if restErr != nil {
+		return restErr
+	}
+	if !canEdit {
+		return &rest.Error{
+			Err:      fmt.Errorf("requester has no permissions to edit the metadata of the requested topic"),
+			Status:   http.StatusForbidden,
+			Message:  "You don't have permissions to edit the owner, tags and description of this topic",
+			IsSilent: false,
+		}
+	}
+
+	return nil
+}
+
+// handleGetTopicMetadataList returns the metadata of all visible topics. It can be filtered by the repeatable
+// query parameter 'tag' (all tags must match) and 'owner'. Metadata of deleted topics is listed as well.
+func (api *API) handleGetTopicMetadataList() http.HandlerFunc {
+	type response struct {
+		Metadata []*topicmeta.TopicMetadata `json:"metadata"`
+
+		// IsLoading is true while the stored metadata is still being read, the list may be incomplete until then
+		IsLoading bool `json:"isLoading"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.TopicMetadataSvc == nil {
+			rest.SendRESTError(w, r, api.Logger, errTopicMetadataDisabled)
+			return
+		}
+
+		metadata, err := api.TopicMetadataSvc.ListMetadata(api.clusterName, topicMetadataFilter(r))
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, topicMetadataError(err, "Could not list topic metadata"))
+			return
+		}
+
+		visible := make([]*topicmeta.TopicMetadata, 0, len(metadata))
+		for _, m := range metadata {
+			canSee, restErr := api.Hooks.Owl.CanSeeTopic(r.Context(), m.TopicName)
+			if restErr != nil {
+				rest.SendRESTError(w, r, api.Logger, restErr)
+				return
+			}
+			if canSee {
+				visible = append(visible, m)
+			}
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, response{Metadata: visible, IsLoading: api.TopicMetadataSvc.IsLoading()})
+	}
+}
+
+func (api *API) handleGetTopicMetadata() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		if api.TopicMetadataSvc == nil {
+			rest.SendRESTError(w, r, api.Logger, errTopicMetadataDisabled)
+			return
+		}
+		if restErr := api.canAccessTopicMetadata(r, topicName, false); restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		metadata, err := api.TopicMetadataSvc.GetMetadata(api.clusterName, topicName)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, topicMetadataError(err, "Could not get topic metadata"))
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, metadata)
+	}
+}
+
+type putTopicMetadataRequest struct {
+	Owner       string   `json:"owner"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+}
+
+func (p *putTopicMetadataRequest) OK() error {
+	if p.Owner == "" && len(p.Tags) == 0 && p.Description == "" {
+		return fmt.Errorf("at least one of owner, tags or description must be set, delete the metadata to remove all")
+	}
+
+	return nil
+}
+
+// handlePutTopicMetadata replaces the owner, tags and description of a topic. The topic must exist.
+func (api *API) handlePutTopicMetadata() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+		if api.TopicMetadataSvc == nil {
+			rest.SendRESTError(w, r, logger, errTopicMetadataDisabled)
+			return
+		}
+
+		var req putTopicMetadataRequest
+		err := rest.Decode(r, &req)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("Failed to parse request: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		if restErr := api.canAccessTopicMetadata(r, topicName, true); restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		topics, err := api.OwlSvc.GetTopicsOverview()
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  "Could not list topics from Kafka cluster",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		topicExists := false
+		for _, topic := range topics {
+			if topic.TopicName == topicName {
+				topicExists = true
+				break
+			}
+		}
+		if !topicExists {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("the requested topic does not exist"),
+				Status:   http.StatusNotFound,
+				Message:  fmt.Sprintf("Topic '%v' does not exist", topicName),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		stored, err := api.TopicMetadataSvc.PutMetadata(topicmeta.TopicMetadata{
+			ClusterName: api.clusterName,
+			TopicName:   topicName,
+			Owner:       req.Owner,
+			Tags:        req.Tags,
+			Description: req.Description,
+			UpdatedBy:   requesterID(r),
+		})
+		if err != nil {
+			rest.SendRESTError(w, r, logger, topicMetadataError(err, "Could not store topic metadata"))
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, stored)
+	}
+}
+
+// handleDeleteTopicMetadata removes the owner, tags and description of a topic. It can be used for deleted topics.
+func (api *API) handleDeleteTopicMetadata() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		if api.TopicMetadataSvc == nil {
+			rest.SendRESTError(w, r, api.Logger, errTopicMetadataDisabled)
+			return
+		}
+		if restErr := api.canAccessTopicMetadata(r, topicName, true); restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		err := api.TopicMetadataSvc.DeleteMetadata(api.clusterName, topicName)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, topicMetadataError(err, "Could not delete topic metadata"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
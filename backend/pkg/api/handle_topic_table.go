@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/usage"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTablePageSize = 50
+	maxTablePageSize     = 1000
+)
+
+// parseTablePage parses the optional query parameters page (starting at 1) and pageSize
+func parseTablePage(query url.Values) (page int, pageSize int, err error) {
+	page, pageSize = 1, defaultTablePageSize
+	if str := query.Get("page"); str != "" {
+		if page, err = strconv.Atoi(str); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("query parameter 'page' must be a positive integer")
+		}
+	}
+	if str := query.Get("pageSize"); str != "" {
+		if pageSize, err = strconv.Atoi(str); err != nil || pageSize < 1 || pageSize > maxTablePageSize {
+			return 0, 0, fmt.Errorf("query parameter 'pageSize' must be between 1 and %v", maxTablePageSize)
+		}
+	}
+	return page, pageSize, nil
+}
+
+// handleGetTopicTable consumes a compacted topic and returns a page of the latest message per key, ordered by key
+func (api *API) handleGetTopicTable() http.HandlerFunc {
+	type response struct {
+		TopicName        string                `json:"topicName"`
+		TotalKeys        int                   `json:"totalKeys"`
+		Page             int                   `json:"page"`
+		PageSize         int                   `json:"pageSize"`
+		IsComplete       bool                  `json:"isComplete"`
+		MessagesConsumed int64                 `json:"messagesConsumed"`
+		Errors           []string              `json:"errors"`
+		Messages         []*kafka.TopicMessage `json:"messages"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		page, pageSize, err := parseTablePage(r.URL.Query())
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		isolationLevel, err := parseIsolationLevel(r.URL.Query().Get("isolationLevel"))
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		canViewMessages, restErr := api.Hooks.Owl.CanViewTopicMessages(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canViewMessages {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view messages in the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view messages in that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		tableReq := owl.TopicTableRequest{
+			TopicName:      topicName,
+			MaxKeys:        api.Cfg.TableView.MaxKeys,
+			MaxBytes:       api.Cfg.TableView.MaxBytes,
+			IsolationLevel: isolationLevel,
+			Renderer:       api.RenderingSvc.Renderer(topicName),
+		}
+		tableReq.Decrypter, restErr = api.messageDecrypter(r, topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		tableReq.Masker, restErr = api.messageMasker(r.Context(), topicName, "")
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), api.Cfg.TableView.Timeout)
+		defer cancel()
+		api.recordUsage(r, topicName, usage.ActionBrowse)
+		table, err := api.OwlSvc.GetTopicTable(ctx, tableReq)
+		if err != nil {
+			status := http.StatusInternalServerError
+			message := fmt.Sprintf("Failed to build table view: %v", err.Error())
+			if errors.Is(err, owl.ErrTopicNotCompacted) {
+				status = http.StatusBadRequest
+				message = "Table views are only available for compacted topics"
+			}
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   status,
+				Message:  message,
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		start := (page - 1) * pageSize
+		if start > len(table.Messages) {
+			start = len(table.Messages)
+		}
+		end := start + pageSize
+		if end > len(table.Messages) {
+			end = len(table.Messages)
+		}
+		rest.SendResponse(w, r, logger, http.StatusOK, response{
+			TopicName:        topicName,
+			TotalKeys:        len(table.Messages),
+			Page:             page,
+			PageSize:         pageSize,
+			IsComplete:       table.IsComplete,
+			MessagesConsumed: table.MessagesConsumed,
+			Errors:           table.Errors,
+			Messages:         table.Messages[start:end],
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTimelineWindow  = 24 * time.Hour
+	defaultTimelineBuckets = 24
+	maxTimelineBuckets     = 100
+)
+
+// parseTimelineQuery parses the optional query parameters start, end (both unix milliseconds) and buckets. By
+// default the last 24 hours are split into hourly buckets.
+func parseTimelineQuery(query url.Values, now time.Time) (start int64, end int64, buckets int, err error) {
+	parseInt := func(name string, defaultValue int64) (int64, error) {
+		str := query.Get(name)
+		if str == "" {
+			return defaultValue, nil
+		}
+		value, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("query parameter '%v' must be an integer", name)
+		}
+		return value, nil
+	}
+
+	end, err = parseInt("end", now.UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	start, err = parseInt("start", end-defaultTimelineWindow.Milliseconds())
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	bucketCount, err := parseInt("buckets", defaultTimelineBuckets)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if start < 0 || end <= start {
+		return 0, 0, 0, fmt.Errorf("start must be a non negative timestamp before end")
+	}
+	if bucketCount < 1 || bucketCount > maxTimelineBuckets {
+		return 0, 0, 0, fmt.Errorf("buckets must be between 1 and %v", maxTimelineBuckets)
+	}
+	if end-start < bucketCount {
+		return 0, 0, 0, fmt.Errorf("buckets must be at least one millisecond wide")
+	}
+
+	return start, end, int(bucketCount), nil
+}
+
+// handleGetTopicTimeline returns the number of messages per time bucket for the requested window, so that the
+// frontend can render a traffic histogram without consuming any messages
+func (api *API) handleGetTopicTimeline() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topicName := chi.URLParam(r, "topicName")
+		logger := api.Logger.With(zap.String("topic_name", topicName))
+
+		// The timeline is derived from partition offsets, hence we require the same permissions as for watermarks
+		canView, restErr := api.Hooks.Owl.CanViewTopicPartitions(r.Context(), topicName)
+		if restErr != nil {
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+		if !canView {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view partitions for the requested topic"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view the timeline of that topic",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		start, end, buckets, err := parseTimelineQuery(r.URL.Query(), time.Now())
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusBadRequest,
+				Message:  err.Error(),
+				IsSilent: true,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+		timeline, err := api.OwlSvc.GetTopicTimeline(ctx, topicName, start, end, buckets)
+		if err != nil {
+			restErr := &rest.Error{
+				Err:      err,
+				Status:   http.StatusInternalServerError,
+				Message:  fmt.Sprintf("Could not get topic timeline: %v", err.Error()),
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, logger, restErr)
+			return
+		}
+
+		rest.SendResponse(w, r, logger, http.StatusOK, timeline)
+	}
+}
@@ -1,24 +1,40 @@
 package api
 
 import (
-	_ "context"
+	"context"
 	"fmt"
 	"net/http"
-	_ "time"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/cloudhut/common/rest"
 	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/topicmeta"
 	"github.com/go-chi/chi"
 )
 
+// topicOverviewWithMetadata adds the owner, tags and description to the topic overview if topic metadata is enabled
+type topicOverviewWithMetadata struct {
+	*owl.TopicOverview
+	Metadata *topicmeta.TopicMetadata `json:"metadata,omitempty"`
+}
+
+// handleGetTopics lists all visible topics. If topic metadata is enabled, the list can be filtered by the repeatable
+// query parameter 'tag' (all tags must match) and 'owner'.
 func (api *API) handleGetTopics() http.HandlerFunc {
 	type response struct {
-		Topics []*owl.TopicOverview `json:"topics"`
+		Topics []topicOverviewWithMetadata `json:"topics"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		metadataFilter := topicMetadataFilter(r)
+		if api.TopicMetadataSvc == nil && !metadataFilter.IsEmpty() {
+			rest.SendRESTError(w, r, api.Logger, errTopicMetadataDisabled)
+			return
+		}
+
 		topics, err := api.OwlSvc.GetTopicsOverview()
 		if err != nil {
 			restErr := &rest.Error{
@@ -31,8 +47,22 @@ func (api *API) handleGetTopics() http.HandlerFunc {
 			return
 		}
 
-		visibleTopics := make([]*owl.TopicOverview, 0, len(topics))
+		var metadataByTopic map[string]*topicmeta.TopicMetadata
+		if api.TopicMetadataSvc != nil {
+			metadataByTopic, err = api.TopicMetadataSvc.MetadataByTopic(api.clusterName)
+			if err != nil {
+				rest.SendRESTError(w, r, api.Logger, topicMetadataError(err, "Could not get topic metadata"))
+				return
+			}
+		}
+
+		visibleTopics := make([]topicOverviewWithMetadata, 0, len(topics))
 		for _, topic := range topics {
+			metadata := metadataByTopic[topic.TopicName]
+			if !metadataFilter.Matches(metadata) {
+				continue
+			}
+
 			// Check if logged in user is allowed to see this topic. If not remove the topic from the list.
 			canSee, restErr := api.Hooks.Owl.CanSeeTopic(r.Context(), topic.TopicName)
 			if restErr != nil {
@@ -41,7 +71,7 @@ func (api *API) handleGetTopics() http.HandlerFunc {
 			}
 
 			if canSee {
-				visibleTopics = append(visibleTopics, topic)
+				visibleTopics = append(visibleTopics, topicOverviewWithMetadata{TopicOverview: topic, Metadata: metadata})
 			}
 
 			// Attach allowed actions for each topic
@@ -59,7 +89,8 @@ func (api *API) handleGetTopics() http.HandlerFunc {
 	}
 }
 
-// handleGetPartitions returns an overview of all partitions and their watermarks in the given topic
+// handleGetPartitions returns an overview of all partitions and their watermarks in the given topic. The query
+// parameter includeTimestamps=true adds the timestamps of the first and last record of each partition.
 func (api *API) handleGetPartitions() http.HandlerFunc {
 	type response struct {
 		TopicName  string               `json:"topicName"`
@@ -87,7 +118,10 @@ func (api *API) handleGetPartitions() http.HandlerFunc {
 			return
 		}
 
-		partitions, err := api.OwlSvc.ListTopicPartitions(topicName)
+		includeTimestamps, _ := strconv.ParseBool(r.URL.Query().Get("includeTimestamps"))
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		partitions, err := api.OwlSvc.ListTopicPartitions(ctx, topicName, includeTimestamps)
 		if err != nil {
 			restErr := &rest.Error{
 				Err:      err,
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudhut/common/rest"
+)
+
+const (
+	defaultUsageReportDays  = 7
+	defaultUsageReportLimit = 50
+	maxUsageReportLimit     = 1000
+)
+
+var errUsageDisabled = &rest.Error{
+	Err:      fmt.Errorf("usage analytics are disabled"),
+	Status:   http.StatusNotFound,
+	Message:  "Usage analytics are disabled",
+	IsSilent: true,
+}
+
+// recordUsage counts the action of the requester on a topic of the served cluster
+func (api *API) recordUsage(r *http.Request, topicName string, action string) {
+	api.UsageTracker.Record(api.clusterName, topicName, requesterID(r), action)
+}
+
+// parseUsageReportParam parses an optional positive integer query parameter which must not exceed max
+func parseUsageReportParam(r *http.Request, name string, defaultValue int, max int) (int, error) {
+	str := r.URL.Query().Get(name)
+	if str == "" {
+		if defaultValue > max {
+			return max, nil
+		}
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(str)
+	if err != nil || value < 1 || value > max {
+		return 0, fmt.Errorf("%v must be between 1 and %v", name, max)
+	}
+	return value, nil
+}
+
+func usageReportParamError(err error) *rest.Error {
+	return &rest.Error{
+		Err:      err,
+		Status:   http.StatusBadRequest,
+		Message:  err.Error(),
+		IsSilent: true,
+	}
+}
+
+// handleGetUsageReport reports the most used topics and the heaviest requesters across all clusters within the last
+// days (query parameter 'days'). Topics which the requester can't see are reported nonetheless, therefore the
+// report requires its own permission.
+func (api *API) handleGetUsageReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.UsageTracker == nil {
+			rest.SendRESTError(w, r, api.Logger, errUsageDisabled)
+			return
+		}
+
+		canView, restErr := api.Hooks.Owl.CanViewUsageReport(r.Context())
+		if restErr != nil {
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+		if !canView {
+			restErr := &rest.Error{
+				Err:      fmt.Errorf("requester has no permissions to view the usage report"),
+				Status:   http.StatusForbidden,
+				Message:  "You don't have permissions to view the usage report",
+				IsSilent: false,
+			}
+			rest.SendRESTError(w, r, api.Logger, restErr)
+			return
+		}
+
+		days, err := parseUsageReportParam(r, "days", defaultUsageReportDays, api.UsageTracker.MaxReportDays())
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, usageReportParamError(err))
+			return
+		}
+		limit, err := parseUsageReportParam(r, "limit", defaultUsageReportLimit, maxUsageReportLimit)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, usageReportParamError(err))
+			return
+		}
+
+		rest.SendResponse(w, r, api.Logger, http.StatusOK, api.UsageTracker.Report(days, limit))
+	}
+}
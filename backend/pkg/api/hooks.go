@@ -33,19 +33,62 @@ type RouteHooks interface {
 
 // OwlHooks include all functions which allow you to modify
 type OwlHooks interface {
+	// Cluster Hooks
+	CanSeeCluster(ctx context.Context, clusterName string) (bool, *rest.Error)
+
 	// Topic Hooks
 	CanSeeTopic(ctx context.Context, topicName string) (bool, *rest.Error)
 	CanViewTopicPartitions(ctx context.Context, topicName string) (bool, *rest.Error)
 	CanViewTopicConfig(ctx context.Context, topicName string) (bool, *rest.Error)
 	CanViewTopicMessages(ctx context.Context, topicName string) (bool, *rest.Error)
 	CanUseMessageSearchFilters(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanExportTopicMessages(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanDecryptTopicMessages(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanManageSavedFilters(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanEditTopicMetadata(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanPublishTopicMessages(ctx context.Context, topicName string) (bool, *rest.Error)
 	CanViewTopicConsumers(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanCreateTopic(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanDeleteTopic(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanDeleteTopicRecords(ctx context.Context, topicName string) (bool, *rest.Error)
+	CanEditTopicConfig(ctx context.Context, topicName string) (bool, *rest.Error)
 	AllowedTopicActions(ctx context.Context, topicName string) ([]string, *rest.Error)
 	PrintListMessagesAuditLog(r *http.Request, req *owl.ListMessageRequest)
 
+	// RequesterRoles returns the roles of the logged in user. Roles are used to assign consume templates.
+	RequesterRoles(ctx context.Context) ([]string, *rest.Error)
+
 	// ConsumerGroup Hooks
 	CanSeeConsumerGroup(ctx context.Context, groupName string) (bool, *rest.Error)
 	AllowedConsumerGroupActions(ctx context.Context, groupName string) ([]string, *rest.Error)
+	CanResetConsumerGroupOffsets(ctx context.Context, groupName string) (bool, *rest.Error)
+	CanDeleteConsumerGroup(ctx context.Context, groupName string) (bool, *rest.Error)
+
+	// ACL Hooks
+	CanListACLs(ctx context.Context) (bool, *rest.Error)
+	CanEditACLs(ctx context.Context) (bool, *rest.Error)
+
+	// SCRAM user Hooks
+	CanListScramUsers(ctx context.Context) (bool, *rest.Error)
+	CanEditScramUser(ctx context.Context, username string) (bool, *rest.Error)
+
+	// Broker Hooks
+	CanViewBrokerConfig(ctx context.Context, brokerID int32) (bool, *rest.Error)
+	CanEditBrokerConfig(ctx context.Context, brokerID int32) (bool, *rest.Error)
+
+	// CanElectLeaders decides whether the requester may move partition leaderships to their preferred replicas
+	CanElectLeaders(ctx context.Context, clusterName string) (bool, *rest.Error)
+
+	// CanViewUsageReport decides whether the requester may see which topics are used the most and by whom
+	CanViewUsageReport(ctx context.Context) (bool, *rest.Error)
+
+	// Kafka Connect Hooks
+	CanViewConnectCluster(ctx context.Context, clusterName string) (bool, *rest.Error)
+	CanEditConnectCluster(ctx context.Context, clusterName string) (bool, *rest.Error)
+
+	// ksqlDB Hooks. Queries read the topics of streams and tables regardless of topic permissions.
+	CanViewKsqlServer(ctx context.Context, serverName string) (bool, *rest.Error)
+	CanQueryKsqlServer(ctx context.Context, serverName string) (bool, *rest.Error)
 }
 
 // defaultHooks is the default hook which is used if you don't attach your own hooks
@@ -65,6 +108,9 @@ func (*defaultHooks) ConfigWsRouter(_ chi.Router)  {}
 func (*defaultHooks) ConfigRouter(_ chi.Router)    {}
 
 // Owl Hooks
+func (*defaultHooks) CanSeeCluster(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
 func (*defaultHooks) CanSeeTopic(_ context.Context, _ string) (bool, *rest.Error) {
 	return true, nil
 }
@@ -80,14 +126,45 @@ func (*defaultHooks) CanViewTopicMessages(_ context.Context, _ string) (bool, *r
 func (*defaultHooks) CanUseMessageSearchFilters(_ context.Context, _ string) (bool, *rest.Error) {
 	return true, nil
 }
+func (*defaultHooks) CanExportTopicMessages(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanDecryptTopicMessages(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanManageSavedFilters(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanEditTopicMetadata(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanPublishTopicMessages(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
 func (*defaultHooks) CanViewTopicConsumers(_ context.Context, _ string) (bool, *rest.Error) {
 	return true, nil
 }
+func (*defaultHooks) CanCreateTopic(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanDeleteTopic(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanDeleteTopicRecords(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanEditTopicConfig(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
 func (*defaultHooks) AllowedTopicActions(_ context.Context, _ string) ([]string, *rest.Error) {
 	// "all" will be considered as wild card - all actions are allowed
 	return []string{"all"}, nil
 }
 func (*defaultHooks) PrintListMessagesAuditLog(_ *http.Request, _ *owl.ListMessageRequest) {}
+func (*defaultHooks) RequesterRoles(_ context.Context) ([]string, *rest.Error) {
+	// "all" will be considered as wild card - all roles are assigned
+	return []string{"all"}, nil
+}
 func (*defaultHooks) CanSeeConsumerGroup(_ context.Context, _ string) (bool, *rest.Error) {
 	return true, nil
 }
@@ -95,3 +172,45 @@ func (*defaultHooks) AllowedConsumerGroupActions(_ context.Context, _ string) ([
 	// "all" will be considered as wild card - all actions are allowed
 	return []string{"all"}, nil
 }
+func (*defaultHooks) CanResetConsumerGroupOffsets(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanDeleteConsumerGroup(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanListACLs(_ context.Context) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanEditACLs(_ context.Context) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanListScramUsers(_ context.Context) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanEditScramUser(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanViewBrokerConfig(_ context.Context, _ int32) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanEditBrokerConfig(_ context.Context, _ int32) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanElectLeaders(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanViewUsageReport(_ context.Context) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanViewConnectCluster(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanEditConnectCluster(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanViewKsqlServer(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
+func (*defaultHooks) CanQueryKsqlServer(_ context.Context, _ string) (bool, *rest.Error) {
+	return true, nil
+}
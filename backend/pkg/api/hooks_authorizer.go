@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"go.uber.org/zap"
+)
+
+// authorizerHooks implement the permission hooks by asking an authorizer for each decision. All other hooks behave
+// like the default hooks.
+type authorizerHooks struct {
+	*defaultHooks
+
+	authorizer authorization.Authorizer
+	logger     *zap.Logger
+}
+
+// topicActions are the actions reported to the frontend as allowed topic actions
+var topicActions = []authorization.Action{
+	authorization.ActionSeeTopic,
+	authorization.ActionViewPartitions,
+	authorization.ActionViewMessages,
+	authorization.ActionUseSearchFilter,
+	authorization.ActionViewConsumers,
+	authorization.ActionViewConfig,
+}
+
+func newAuthorizerHooks(authorizer authorization.Authorizer, logger *zap.Logger) *authorizerHooks {
+	return &authorizerHooks{
+		defaultHooks: &defaultHooks{},
+		authorizer:   authorizer,
+		logger:       logger,
+	}
+}
+
+// authorize asks the authorizer whether the requester may perform the action. If no decision can be made the
+// request is denied with an error.
+func (h *authorizerHooks) authorize(ctx context.Context, action authorization.Action, resourceType authorization.ResourceType, resourceName string) (bool, *rest.Error) {
+	req := authorization.Request{
+		Subject:  authorization.SubjectFromContext(ctx),
+		Action:   action,
+		Resource: authorization.Resource{Type: resourceType, Name: resourceName},
+	}
+	isAllowed, err := h.authorizer.Authorize(ctx, req)
+	if err != nil {
+		return false, &rest.Error{
+			Err:      fmt.Errorf("failed to authorize action '%v' on %v '%v': %w", action, resourceType, resourceName, err),
+			Status:   http.StatusServiceUnavailable,
+			Message:  "Could not check your permissions, please try again later",
+			IsSilent: false,
+		}
+	}
+	return isAllowed, nil
+}
+
+// allowedActions returns the subset of the given actions which the requester may perform
+func (h *authorizerHooks) allowedActions(ctx context.Context, actions []authorization.Action, resourceType authorization.ResourceType, resourceName string) ([]string, *rest.Error) {
+	allowed := make([]string, 0, len(actions))
+	for _, action := range actions {
+		isAllowed, restErr := h.authorize(ctx, action, resourceType, resourceName)
+		if restErr != nil {
+			return nil, restErr
+		}
+		if isAllowed {
+			allowed = append(allowed, string(action))
+		}
+	}
+	return allowed, nil
+}
+
+// RequesterRoles returns the roles of the requester's subject, which are the roles the authorizer decides on. Requests
+// without subject have no roles.
+func (h *authorizerHooks) RequesterRoles(ctx context.Context) ([]string, *rest.Error) {
+	roles := authorization.SubjectFromContext(ctx).Roles
+	if roles == nil {
+		return []string{}, nil
+	}
+	return roles, nil
+}
+
+func (h *authorizerHooks) CanSeeCluster(ctx context.Context, clusterName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionSeeCluster, authorization.ResourceCluster, clusterName)
+}
+func (h *authorizerHooks) CanSeeTopic(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionSeeTopic, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanViewTopicPartitions(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionViewPartitions, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanViewTopicConfig(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionViewConfig, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanViewTopicMessages(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionViewMessages, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanUseMessageSearchFilters(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionUseSearchFilter, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanExportTopicMessages(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionExportMessages, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanDecryptTopicMessages(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionDecryptMessages, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanManageSavedFilters(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionManageSavedFilters, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanEditTopicMetadata(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionEditTopicMetadata, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanPublishTopicMessages(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionPublishMessages, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanViewTopicConsumers(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionViewConsumers, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanCreateTopic(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionCreateTopic, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanDeleteTopic(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionDeleteTopic, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanDeleteTopicRecords(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionDeleteRecords, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanEditTopicConfig(ctx context.Context, topicName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionEditConfig, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) AllowedTopicActions(ctx context.Context, topicName string) ([]string, *rest.Error) {
+	return h.allowedActions(ctx, topicActions, authorization.ResourceTopic, topicName)
+}
+func (h *authorizerHooks) CanSeeConsumerGroup(ctx context.Context, groupName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionSeeConsumerGroup, authorization.ResourceConsumerGroup, groupName)
+}
+func (h *authorizerHooks) AllowedConsumerGroupActions(ctx context.Context, groupName string) ([]string, *rest.Error) {
+	return h.allowedActions(ctx, []authorization.Action{authorization.ActionSeeConsumerGroup}, authorization.ResourceConsumerGroup, groupName)
+}
+func (h *authorizerHooks) CanResetConsumerGroupOffsets(ctx context.Context, groupName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionResetOffsets, authorization.ResourceConsumerGroup, groupName)
+}
+func (h *authorizerHooks) CanDeleteConsumerGroup(ctx context.Context, groupName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionDeleteConsumerGroup, authorization.ResourceConsumerGroup, groupName)
+}
+func (h *authorizerHooks) CanListACLs(ctx context.Context) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionListACLs, authorization.ResourceACL, "")
+}
+func (h *authorizerHooks) CanEditACLs(ctx context.Context) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionEditACLs, authorization.ResourceACL, "")
+}
+func (h *authorizerHooks) CanListScramUsers(ctx context.Context) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionListScramUsers, authorization.ResourceScramUser, "")
+}
+func (h *authorizerHooks) CanEditScramUser(ctx context.Context, username string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionEditScramUsers, authorization.ResourceScramUser, username)
+}
+func (h *authorizerHooks) CanViewBrokerConfig(ctx context.Context, brokerID int32) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionViewBrokerConfig, authorization.ResourceBroker, strconv.Itoa(int(brokerID)))
+}
+func (h *authorizerHooks) CanEditBrokerConfig(ctx context.Context, brokerID int32) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionEditBrokerConfig, authorization.ResourceBroker, strconv.Itoa(int(brokerID)))
+}
+func (h *authorizerHooks) CanElectLeaders(ctx context.Context, clusterName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionElectLeaders, authorization.ResourceCluster, clusterName)
+}
+func (h *authorizerHooks) CanViewUsageReport(ctx context.Context) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionViewUsageReport, authorization.ResourceUsageReport, "")
+}
+func (h *authorizerHooks) CanViewConnectCluster(ctx context.Context, clusterName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionViewConnectCluster, authorization.ResourceConnectCluster, clusterName)
+}
+func (h *authorizerHooks) CanEditConnectCluster(ctx context.Context, clusterName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionEditConnectCluster, authorization.ResourceConnectCluster, clusterName)
+}
+func (h *authorizerHooks) CanViewKsqlServer(ctx context.Context, serverName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionViewKsqlServer, authorization.ResourceKsqlServer, serverName)
+}
+func (h *authorizerHooks) CanQueryKsqlServer(ctx context.Context, serverName string) (bool, *rest.Error) {
+	return h.authorize(ctx, authorization.ActionQueryKsqlServer, authorization.ResourceKsqlServer, serverName)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// roleAuthorizer allows an action if the subject has a role which is named like the action
+type roleAuthorizer struct{}
+
+func (roleAuthorizer) Authorize(_ context.Context, req authorization.Request) (bool, error) {
+	for _, role := range req.Subject.Roles {
+		if role == string(req.Action) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestAuthorizerHooksRequesterRoles(t *testing.T) {
+	hooks := newAuthorizerHooks(roleAuthorizer{}, zap.NewNop())
+
+	// The roles are resolved from the subject rather than the wildcard of the default hooks
+	subject := authorization.Subject{Name: "alice", Roles: []string{string(authorization.ActionSeeCluster), "team-orders"}}
+	ctx := authorization.ContextWithSubject(context.Background(), subject)
+	roles, restErr := hooks.RequesterRoles(ctx)
+	require.Nil(t, restErr)
+	assert.Equal(t, subject.Roles, roles)
+
+	canSee, restErr := hooks.CanSeeCluster(ctx, "production")
+	require.Nil(t, restErr)
+	assert.True(t, canSee)
+
+	roles, restErr = hooks.RequesterRoles(context.Background())
+	require.Nil(t, restErr)
+	assert.Empty(t, roles)
+	canSee, restErr = hooks.CanSeeCluster(context.Background(), "production")
+	require.Nil(t, restErr)
+	assert.False(t, canSee)
+}
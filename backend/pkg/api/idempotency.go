@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudhut/common/rest"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotentResponse is the recorded response of a request with an idempotency key. Done is false while the request
+// is still in progress.
+type idempotentResponse struct {
+	Fingerprint [sha256.Size]byte
+	CreatedAt   time.Time
+	Done        bool
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// idempotencyStore remembers the responses of requests with an idempotency key in memory. Keys are scoped per
+// requester.
+type idempotencyStore struct {
+	ttl     time.Duration
+	maxKeys int
+
+	mutex     sync.Mutex
+	responses map[string]*idempotentResponse
+}
+
+func newIdempotencyStore(cfg IdempotencyConfig) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:       cfg.KeyTTL,
+		maxKeys:   cfg.MaxKeys,
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+// begin registers a new request for the given key. If the key is known already the existing response (which may
+// still be in progress) is returned instead and the request must not be executed.
+func (s *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte, now time.Time) (existing idempotentResponse, isNew bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.evict(now)
+	if res, ok := s.responses[key]; ok {
+		return *res, false
+	}
+	s.responses[key] = &idempotentResponse{Fingerprint: fingerprint, CreatedAt: now}
+
+	return idempotentResponse{}, true
+}
+
+// complete stores the response of a request. Server errors are not stored so that the request can be retried.
+func (s *idempotencyStore) complete(key string, status int, contentType string, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res, ok := s.responses[key]
+	if !ok {
+		return
+	}
+	if status >= http.StatusInternalServerError {
+		delete(s.responses, key)
+		return
+	}
+	res.Done = true
+	res.Status = status
+	res.ContentType = contentType
+	res.Body = body
+}
+
+// evict removes expired keys and the oldest keys beyond the key limit. Callers must hold the mutex.
+func (s *idempotencyStore) evict(now time.Time) {
+	for key, res := range s.responses {
+		if now.Sub(res.CreatedAt) > s.ttl {
+			delete(s.responses, key)
+		}
+	}
+
+	for len(s.responses) >= s.maxKeys {
+		oldestKey := ""
+		var oldest time.Time
+		for key, res := range s.responses {
+			if oldestKey == "" || res.CreatedAt.Before(oldest) {
+				oldestKey, oldest = key, res.CreatedAt
+			}
+		}
+		delete(s.responses, oldestKey)
+	}
+}
+
+// idempotent deduplicates requests which carry an Idempotency-Key header. A retry with the same key receives the
+// recorded response of the first request instead of being applied again. Requests without the header are passed
+// through.
+func (api *API) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			rest.SendRESTError(w, r, api.Logger, &rest.Error{
+				Err:      fmt.Errorf("idempotency key is longer than %v characters", maxIdempotencyKeyLength),
+				Status:   http.StatusBadRequest,
+				Message:  fmt.Sprintf("The %v header must not be longer than %v characters", idempotencyKeyHeader, maxIdempotencyKeyLength),
+				IsSilent: false,
+			})
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			rest.SendRESTError(w, r, api.Logger, &rest.Error{
+				Err:      fmt.Errorf("failed to read request body: %w", err),
+				Status:   http.StatusBadRequest,
+				Message:  "Failed to read request body",
+				IsSilent: false,
+			})
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// The fingerprint ensures that a key is not reused for a different request
+		fingerprint := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		storeKey := requesterID(r) + "\n" + key
+		existing, isNew := api.idempotencyKeys.begin(storeKey, fingerprint, time.Now())
+		if !isNew {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				rest.SendRESTError(w, r, api.Logger, &rest.Error{
+					Err:      fmt.Errorf("idempotency key '%v' has been used for a different request", key),
+					Status:   http.StatusUnprocessableEntity,
+					Message:  "The idempotency key has already been used for a different request",
+					IsSilent: false,
+				})
+			case !existing.Done:
+				rest.SendRESTError(w, r, api.Logger, &rest.Error{
+					Err:      fmt.Errorf("request with idempotency key '%v' is still in progress", key),
+					Status:   http.StatusConflict,
+					Message:  "A request with the same idempotency key is still in progress",
+					IsSilent: false,
+				})
+			default:
+				if existing.ContentType != "" {
+					w.Header().Set("Content-Type", existing.ContentType)
+				}
+				w.Header().Set(idempotentReplayedHeader, "true")
+				w.WriteHeader(existing.Status)
+				_, _ = w.Write(existing.Body)
+			}
+			return
+		}
+
+		rw := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// Also release the key if the handler panics, otherwise retries would be rejected until the key expires
+			if p := recover(); p != nil {
+				api.idempotencyKeys.complete(storeKey, http.StatusInternalServerError, "", nil)
+				panic(p)
+			}
+			api.idempotencyKeys.complete(storeKey, rw.status, rw.Header().Get("Content-Type"), rw.body.Bytes())
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// recordingResponseWriter passes the response through while keeping a copy of the status and body
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(status int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
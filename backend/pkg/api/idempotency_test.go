@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestIdempotentMiddleware(t *testing.T) {
+	api := &API{Logger: zap.NewNop(), idempotencyKeys: newIdempotencyStore(IdempotencyConfig{KeyTTL: time.Hour, MaxKeys: 10})}
+
+	calls := 0
+	status := http.StatusCreated
+	handler := api.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"offset":42}`))
+	}))
+
+	send := func(key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/topics/orders/messages", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Retries with the same key are replayed
+	first := send("key-1", `{"value":"a"}`)
+	retry := send("key-1", `{"value":"a"}`)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+
+	// Reusing the key for a different request is rejected
+	assert.Equal(t, http.StatusUnprocessableEntity, send("key-1", `{"value":"b"}`).Code)
+	assert.Equal(t, 1, calls)
+
+	// Requests without key are never deduplicated
+	send("", `{"value":"a"}`)
+	send("", `{"value":"a"}`)
+	assert.Equal(t, 3, calls)
+
+	// Server errors are not recorded so that the request can be retried
+	status = http.StatusInternalServerError
+	send("key-2", `{"value":"a"}`)
+	status = http.StatusCreated
+	assert.Equal(t, http.StatusCreated, send("key-2", `{"value":"a"}`).Code)
+	assert.Equal(t, 5, calls)
+}
+
+func TestIdempotencyStoreEviction(t *testing.T) {
+	store := newIdempotencyStore(IdempotencyConfig{KeyTTL: time.Minute, MaxKeys: 2})
+	now := time.Now()
+	fingerprint := [32]byte{}
+
+	_, isNew := store.begin("a", fingerprint, now)
+	assert.True(t, isNew)
+	_, isNew = store.begin("b", fingerprint, now.Add(time.Second))
+	assert.True(t, isNew)
+
+	// Exceeding the key limit forgets the oldest key
+	_, isNew = store.begin("c", fingerprint, now.Add(2*time.Second))
+	assert.True(t, isNew)
+	_, isNew = store.begin("a", fingerprint, now.Add(3*time.Second))
+	assert.True(t, isNew)
+
+	// In progress requests are returned as not done
+	existing, isNew := store.begin("a", fingerprint, now.Add(4*time.Second))
+	assert.False(t, isNew)
+	assert.False(t, existing.Done)
+
+	// Expired keys can be reused
+	_, isNew = store.begin("a", fingerprint, now.Add(2*time.Minute))
+	assert.True(t, isNew)
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// lagSample is the lag of a single group at the time of a collection
+type lagSample struct {
+	Timestamp time.Time        `json:"timestamp"`
+	TotalLag  int64            `json:"totalLag"`
+	TopicLags map[string]int64 `json:"topicLags"` // Summed lag of the topic's partitions
+}
+
+// lagExporter periodically collects the lags of all consumer groups of a cluster. The lags are exported as
+// prometheus gauges and the samples within the retention are kept for the lag history endpoint.
+type lagExporter struct {
+	cfg          LagExporterConfig
+	owlSvc       *owl.Service
+	logger       *zap.Logger
+	warningLimit *rate.Limiter
+
+	topicLag       *prometheus.GaugeVec
+	partitionLag   *prometheus.GaugeVec // nil if partition metrics are disabled
+	lastCollection prometheus.Gauge
+	failures       prometheus.Counter
+
+	mutex   sync.RWMutex
+	history map[string][]lagSample // Samples of each group, oldest first
+	lags    map[string]*owl.ConsumerGroupLag
+}
+
+func newLagExporter(cfg LagExporterConfig, owlSvc *owl.Service, namespace string, registerer prometheus.Registerer, logger *zap.Logger) *lagExporter {
+	e := &lagExporter{
+		cfg:          cfg,
+		owlSvc:       owlSvc,
+		logger:       logger.With(zap.String("source", "lag_exporter")),
+		warningLimit: rate.NewLimiter(rate.Every(5*time.Minute), 1),
+		topicLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "consumer_group",
+			Name:      "topic_lag",
+			Help:      "Summed lag of the consumer group's partitions of the topic",
+		}, []string{"group", "topic"}),
+		lastCollection: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "consumer_group",
+			Name:      "lag_last_collection_timestamp_seconds",
+			Help:      "Unix time of the last successful collection of all consumer group lags",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer_group",
+			Name:      "lag_collection_failures_total",
+			Help:      "Number of failed collections of all consumer group lags",
+		}),
+		history: make(map[string][]lagSample),
+		lags:    make(map[string]*owl.ConsumerGroupLag),
+	}
+	registerer.MustRegister(e.topicLag, e.lastCollection, e.failures)
+
+	if cfg.PartitionMetrics {
+		e.partitionLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "consumer_group",
+			Name:      "partition_lag",
+			Help:      "Lag of the consumer group on the partition",
+		}, []string{"group", "topic", "partition"})
+		registerer.MustRegister(e.partitionLag)
+	}
+
+	return e
+}
+
+// newLagExporterIfEnabled returns nil if the lag exporter is disabled, otherwise the exporter's metrics are
+// registered on the default prometheus registry
+func newLagExporterIfEnabled(cfg LagExporterConfig, owlSvc *owl.Service, namespace string, logger *zap.Logger) *lagExporter {
+	if !cfg.Enabled {
+		return nil
+	}
+	return newLagExporter(cfg, owlSvc, namespace, prometheus.DefaultRegisterer, logger)
+}
+
+// Start collects the lags in the configured interval until the process exits. It's a no-op if the exporter is nil,
+// which is the case if the lag exporter is disabled.
+func (e *lagExporter) Start() {
+	if e == nil {
+		return
+	}
+
+	go func() {
+		e.collect()
+		ticker := time.NewTicker(e.cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			e.collect()
+		}
+	}()
+}
+
+func (e *lagExporter) collect() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.Interval)
+	defer cancel()
+
+	lags, err := e.owlSvc.GetConsumerGroupLags(ctx)
+	if err != nil {
+		e.failures.Inc()
+		if e.warningLimit.Allow() {
+			e.logger.Warn("failed to collect consumer group lags", zap.Error(err))
+		}
+		return
+	}
+	e.record(time.Now(), lags)
+}
+
+// record exports the collected lags and appends them to the history. Series of groups, topics and partitions which
+// don't have a lag anymore are removed, as are the samples which are older than the retention.
+func (e *lagExporter) record(now time.Time, lags map[string]*owl.ConsumerGroupLag) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for groupID, previous := range e.lags {
+		for _, topicLag := range previous.TopicLags {
+			var current *owl.TopicLag
+			if groupLag, exists := lags[groupID]; exists {
+				current = groupLag.GetTopicLag(topicLag.Topic)
+			}
+			if current == nil {
+				e.topicLag.DeleteLabelValues(groupID, topicLag.Topic)
+			}
+			if e.partitionLag == nil {
+				continue
+			}
+			for _, p := range topicLag.PartitionLags {
+				if current == nil || !hasPartitionLag(current, p.PartitionID) {
+					e.partitionLag.DeleteLabelValues(groupID, topicLag.Topic, strconv.Itoa(int(p.PartitionID)))
+				}
+			}
+		}
+	}
+
+	for groupID, groupLag := range lags {
+		sample := lagSample{Timestamp: now, TopicLags: make(map[string]int64, len(groupLag.TopicLags))}
+		for _, topicLag := range groupLag.TopicLags {
+			e.topicLag.WithLabelValues(groupID, topicLag.Topic).Set(float64(topicLag.SummedLag))
+			if e.partitionLag != nil {
+				for _, p := range topicLag.PartitionLags {
+					e.partitionLag.WithLabelValues(groupID, topicLag.Topic, strconv.Itoa(int(p.PartitionID))).Set(float64(p.Lag))
+				}
+			}
+			sample.TopicLags[topicLag.Topic] = topicLag.SummedLag
+			sample.TotalLag += topicLag.SummedLag
+		}
+		e.history[groupID] = append(e.history[groupID], sample)
+	}
+
+	oldest := now.Add(-e.cfg.HistoryRetention)
+	for groupID, samples := range e.history {
+		i := 0
+		for i < len(samples) && samples[i].Timestamp.Before(oldest) {
+			i++
+		}
+		if i == len(samples) {
+			delete(e.history, groupID)
+			continue
+		}
+		e.history[groupID] = samples[i:]
+	}
+
+	e.lags = lags
+	e.lastCollection.Set(float64(now.Unix()))
+}
+
+// History returns the group's samples within the retention, oldest first
+func (e *lagExporter) History(groupID string) []lagSample {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	samples := make([]lagSample, len(e.history[groupID]))
+	copy(samples, e.history[groupID])
+	return samples
+}
+
+func hasPartitionLag(topicLag *owl.TopicLag, partitionID int32) bool {
+	for _, p := range topicLag.PartitionLags {
+		if p.PartitionID == partitionID {
+			return true
+		}
+	}
+	return false
+}
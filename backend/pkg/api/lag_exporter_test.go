@@ -0,0 +1,57 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func groupLag(groupID string, topic string, partitionLags ...int64) *owl.ConsumerGroupLag {
+	topicLag := &owl.TopicLag{Topic: topic, PartitionCount: len(partitionLags), PartitionsWithOffset: len(partitionLags)}
+	for i, lag := range partitionLags {
+		topicLag.SummedLag += lag
+		topicLag.PartitionLags = append(topicLag.PartitionLags, owl.PartitionLag{PartitionID: int32(i), Lag: lag})
+	}
+	return &owl.ConsumerGroupLag{GroupID: groupID, TopicLags: []*owl.TopicLag{topicLag}}
+}
+
+func TestLagExporter_Record(t *testing.T) {
+	cfg := LagExporterConfig{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.PartitionMetrics = true
+	e := newLagExporter(cfg, nil, "test", prometheus.NewRegistry(), zap.NewNop())
+
+	start := time.Now()
+	e.record(start, map[string]*owl.ConsumerGroupLag{
+		"billing":  groupLag("billing", "orders", 5, 7),
+		"shipping": groupLag("shipping", "orders", 1),
+	})
+	assert.Equal(t, float64(12), testutil.ToFloat64(e.topicLag.WithLabelValues("billing", "orders")))
+	assert.Equal(t, 2, testutil.CollectAndCount(e.topicLag))
+	assert.Equal(t, 3, testutil.CollectAndCount(e.partitionLag))
+
+	// The shipping group has been deleted and billing lost its offset on partition 1
+	e.record(start.Add(40*time.Minute), map[string]*owl.ConsumerGroupLag{
+		"billing": groupLag("billing", "orders", 2),
+	})
+	assert.Equal(t, 1, testutil.CollectAndCount(e.topicLag))
+	assert.Equal(t, 1, testutil.CollectAndCount(e.partitionLag))
+	assert.Len(t, e.History("billing"), 2)
+	assert.Len(t, e.History("shipping"), 1)
+
+	// Samples older than the retention are dropped
+	e.record(start.Add(80*time.Minute), map[string]*owl.ConsumerGroupLag{
+		"billing": groupLag("billing", "orders", 3),
+	})
+	history := e.History("billing")
+	assert.Len(t, history, 2)
+	assert.Equal(t, int64(2), history[0].TotalLag)
+	assert.Equal(t, map[string]int64{"orders": 3}, history[1].TopicLags)
+	assert.Empty(t, e.History("shipping"))
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"go.uber.org/zap"
+)
+
+// messageDecrypter returns the decrypter for messages of the given topic which are consumed by the logged in user.
+// Nil is returned if decryption is disabled, the topic isn't encrypted or the user may not decrypt its messages, in
+// which case messages are returned as they have been consumed. Every request which decrypts messages is logged, so
+// that access to decrypted payloads can be audited.
+func (api *API) messageDecrypter(r *http.Request, topicName string) (*decryption.TopicDecrypter, *rest.Error) {
+	if api.DecryptionSvc == nil {
+		return nil, nil
+	}
+	decrypter := api.DecryptionSvc.TopicDecrypter(topicName)
+	if decrypter == nil {
+		return nil, nil
+	}
+
+	canDecrypt, restErr := api.Hooks.Owl.CanDecryptTopicMessages(r.Context(), topicName)
+	if restErr != nil {
+		return nil, restErr
+	}
+	if !canDecrypt {
+		return nil, nil
+	}
+
+	api.Logger.Info("decrypting topic messages",
+		zap.String("source", "decryption_audit"),
+		zap.String("cluster", api.clusterName),
+		zap.String("topic", topicName),
+		zap.String("requester", requesterID(r)),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path))
+
+	return decrypter, nil
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"go.uber.org/zap"
+)
+
+// Export formats
+const (
+	exportFormatNDJSON = "ndjson"
+	exportFormatCSV    = "csv"
+)
+
+// Trailers which are sent after the export file, because the outcome is not known when the download starts
+const (
+	exportRowsTrailer      = "Kowl-Export-Rows"
+	exportTruncatedTrailer = "Kowl-Export-Truncated"
+	exportErrorTrailer     = "Kowl-Export-Error"
+
+	// exportInconsistenciesTrailer lists the partitions whose leader or log has changed during the export, e.g.
+	// "0:truncated, 3:leaderChanged"
+	exportInconsistenciesTrailer = "Kowl-Export-Inconsistencies"
+)
+
+var exportCSVColumns = []string{"partitionId", "offset", "timestamp", "keyType", "key", "valueType", "value", "headers", "size"}
+
+// messageExporter implements kafka.IListMessagesProgress and streams all messages into the response as NDJSON or
+// CSV file. Once the row or byte limit would be exceeded the export is truncated and the search is cancelled.
+type messageExporter struct {
+	w          http.ResponseWriter
+	buf        *bufio.Writer
+	format     string
+	filename   string
+	projection *messageProjection // May be nil
+	maxRows    int64
+	maxBytes   int64
+	cancel     context.CancelFunc
+	logger     *zap.Logger
+
+	mutex       sync.Mutex
+	isStarted   bool
+	isClosed    bool
+	isTruncated bool
+	rows        int64
+	bytes       int64
+	errMsg      string
+
+	inconsistencies []kafka.PartitionInconsistency
+}
+
+func newMessageExporter(w http.ResponseWriter, format string, filename string, projection *messageProjection, maxRows int64, maxBytes int64, cancel context.CancelFunc, logger *zap.Logger) *messageExporter {
+	return &messageExporter{
+		w:          w,
+		buf:        bufio.NewWriterSize(w, 32*1024),
+		format:     format,
+		filename:   filename,
+		projection: projection,
+		maxRows:    maxRows,
+		maxBytes:   maxBytes,
+		cancel:     cancel,
+		logger:     logger,
+	}
+}
+
+func (e *messageExporter) OnPhase(_ string)                                             {}
+func (e *messageExporter) OnConsumeRequests(_ map[int32]*kafka.PartitionConsumeRequest) {}
+func (e *messageExporter) OnMessageConsumed(_ int32, _ int64, _ int64)                  {}
+func (e *messageExporter) OnMessageMatched(_ int32)                                     {}
+func (e *messageExporter) OnMessagesDropped(_ int64)                                    {}
+func (e *messageExporter) OnWaterMarks(_ map[int32]*kafka.WaterMark)                    {}
+func (e *messageExporter) OnComplete(_ int64, _ bool)                                   {}
+
+func (e *messageExporter) OnInconsistency(inconsistency kafka.PartitionInconsistency) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.inconsistencies = append(e.inconsistencies, inconsistency)
+}
+
+func (e *messageExporter) OnError(msg string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.errMsg == "" {
+		e.errMsg = msg
+	}
+}
+
+func (e *messageExporter) OnMessage(msg *kafka.TopicMessage) {
+	row, err := e.encodeRow(msg)
+	if err != nil {
+		e.logger.Warn("failed to encode message for export", zap.Int32("partition_id", msg.PartitionID), zap.Int64("offset", msg.Offset), zap.Error(err))
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.isClosed || e.isTruncated {
+		return
+	}
+	if !e.isStarted {
+		if err := e.start(); err != nil {
+			return
+		}
+	}
+	if e.rows >= e.maxRows || e.bytes+int64(len(row)) > e.maxBytes {
+		e.isTruncated = true
+		e.cancel()
+		return
+	}
+
+	if _, err := e.buf.Write(row); err != nil {
+		// The client has most likely gone away
+		e.logger.Debug("failed to write export row", zap.Error(err))
+		e.cancel()
+		return
+	}
+	e.rows++
+	e.bytes += int64(len(row))
+}
+
+// exportedRows returns the number of rows which have been written so far
+func (e *messageExporter) exportedRows() int64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.rows
+}
+
+// encodeRow encodes a single message as NDJSON line or CSV record
+func (e *messageExporter) encodeRow(msg *kafka.TopicMessage) ([]byte, error) {
+	if e.projection != nil {
+		return e.encodeProjectedRow(e.projection.row(msg))
+	}
+
+	if e.format == exportFormatNDJSON {
+		row, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		return append(row, '\n'), nil
+	}
+
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return nil, err
+	}
+	return encodeCSVRecord([]string{
+		strconv.Itoa(int(msg.PartitionID)),
+		strconv.FormatInt(msg.Offset, 10),
+		strconv.FormatInt(msg.Timestamp, 10),
+		msg.KeyType,
+		string(msg.Key.Value),
+		msg.ValueType,
+		string(msg.Value.Value),
+		string(headers),
+		strconv.Itoa(msg.Size),
+	})
+}
+
+// encodeProjectedRow encodes the extracted columns of a message as NDJSON line or CSV record
+func (e *messageExporter) encodeProjectedRow(row projectedRow) ([]byte, error) {
+	if e.format == exportFormatNDJSON {
+		encoded, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		return append(encoded, '\n'), nil
+	}
+
+	record := make([]string, 0, 3+len(row.Columns))
+	record = append(record,
+		strconv.Itoa(int(row.PartitionID)),
+		strconv.FormatInt(row.Offset, 10),
+		strconv.FormatInt(row.Timestamp, 10),
+	)
+	for _, value := range row.Columns {
+		column, err := csvColumn(value)
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, column)
+	}
+	return encodeCSVRecord(record)
+}
+
+func encodeCSVRecord(record []string) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// start writes the response headers and the CSV header row. The mutex must be held.
+func (e *messageExporter) start() error {
+	e.isStarted = true
+
+	contentType := "application/x-ndjson"
+	if e.format == exportFormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	header := e.w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", "attachment; filename=\""+e.filename+"\"")
+	header.Set("Trailer", strings.Join([]string{exportRowsTrailer, exportTruncatedTrailer, exportErrorTrailer, exportInconsistenciesTrailer}, ", "))
+	e.w.WriteHeader(http.StatusOK)
+
+	if e.format == exportFormatCSV {
+		columns := exportCSVColumns
+		if e.projection != nil {
+			columns = append([]string{"partitionId", "offset", "timestamp"}, e.projection.columnNames()...)
+		}
+		row, err := encodeCSVRecord(columns)
+		if err != nil {
+			return err
+		}
+		if _, err := e.buf.Write(row); err != nil {
+			return err
+		}
+		e.bytes += int64(len(row))
+	}
+
+	return nil
+}
+
+// Close finishes the export file and reports the outcome as trailers. If the search failed before anything has been
+// written, the error is returned so that the caller can respond with an error instead.
+func (e *messageExporter) Close(searchErr error) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.isClosed = true
+	errMsg := e.errMsg
+	if searchErr != nil && !e.isTruncated {
+		errMsg = searchErr.Error()
+	}
+	if !e.isStarted {
+		if errMsg != "" {
+			return errors.New(errMsg)
+		}
+		if err := e.start(); err != nil {
+			e.logger.Debug("failed to start export", zap.Error(err))
+		}
+	}
+
+	if err := e.buf.Flush(); err != nil {
+		e.logger.Debug("failed to flush export", zap.Error(err))
+	}
+	header := e.w.Header()
+	header.Set(exportRowsTrailer, strconv.FormatInt(e.rows, 10))
+	header.Set(exportTruncatedTrailer, strconv.FormatBool(e.isTruncated))
+	if errMsg != "" {
+		header.Set(exportErrorTrailer, strings.ReplaceAll(errMsg, "\n", " "))
+	}
+	if len(e.inconsistencies) > 0 {
+		partitions := make([]string, len(e.inconsistencies))
+		for i, inconsistency := range e.inconsistencies {
+			partitions[i] = fmt.Sprintf("%v:%v", inconsistency.PartitionID, inconsistency.Reason)
+		}
+		header.Set(exportInconsistenciesTrailer, strings.Join(partitions, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testExportMessage(offset int64) *kafka.TopicMessage {
+	return &kafka.TopicMessage{
+		PartitionID: 0,
+		Offset:      offset,
+		Timestamp:   1600000000,
+		Key:         kafka.DirectEmbedding{Value: []byte("key"), ValueType: "text"},
+		KeyType:     "text",
+		Value:       kafka.DirectEmbedding{Value: []byte(`{"a":"b,c"}`), ValueType: "json"},
+		ValueType:   "json",
+		Headers:     []kafka.MessageHeader{},
+		Size:        11,
+	}
+}
+
+func TestMessageExporterCSV(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	exporter := newMessageExporter(rec, exportFormatCSV, "orders.csv", nil, 2, 1024, cancel, zap.NewNop())
+
+	for i := int64(0); i < 3; i++ {
+		exporter.OnMessage(testExportMessage(i))
+	}
+	require.NoError(t, exporter.Close(errors.New("request was cancelled")))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "attachment; filename=\"orders.csv\"", rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "partitionId,offset,timestamp,keyType,key,valueType,value,headers,size\n"+
+		"0,0,1600000000,text,key,json,\"{\"\"a\"\":\"\"b,c\"\"}\",[],11\n"+
+		"0,1,1600000000,text,key,json,\"{\"\"a\"\":\"\"b,c\"\"}\",[],11\n", rec.Body.String())
+
+	// The third message exceeds the row limit which cancels the search
+	assert.Error(t, ctx.Err())
+	assert.Equal(t, "2", rec.Header().Get(exportRowsTrailer))
+	assert.Equal(t, "true", rec.Header().Get(exportTruncatedTrailer))
+	assert.Empty(t, rec.Header().Get(exportErrorTrailer), "cancellation due to truncation is no error")
+}
+
+func TestMessageExporterNDJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exporter := newMessageExporter(rec, exportFormatNDJSON, "orders.ndjson", nil, 100, 1024, cancel, zap.NewNop())
+
+	exporter.OnMessage(testExportMessage(0))
+	require.NoError(t, exporter.Close(nil))
+
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `{"partitionID":0,"offset":0,"timestamp":1600000000,"key":"key","keyType":"text","value":{"a":"b,c"},"valueType":"json","headers":[],"size":11,"isValueNull":false}`+"\n", rec.Body.String())
+	assert.Equal(t, "false", rec.Header().Get(exportTruncatedTrailer))
+}
+
+func TestMessageExporterFailsBeforeFirstRow(t *testing.T) {
+	rec := httptest.NewRecorder()
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exporter := newMessageExporter(rec, exportFormatNDJSON, "orders.ndjson", nil, 100, 1024, cancel, zap.NewNop())
+
+	// Nothing has been written, hence the caller can still respond with an error
+	assert.Error(t, exporter.Close(errors.New("failed to get partitions")))
+	assert.Equal(t, 0, rec.Body.Len())
+}
+
+func TestMessageExporterProjection(t *testing.T) {
+	projection, err := newMessageProjection([]string{"$.a", "$.missing", "$"})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exporter := newMessageExporter(rec, exportFormatCSV, "orders.csv", projection, 100, 1024, cancel, zap.NewNop())
+
+	exporter.OnMessage(testExportMessage(0))
+	require.NoError(t, exporter.Close(nil))
+
+	assert.Equal(t, "partitionId,offset,timestamp,$.a,$.missing,$\n"+
+		"0,0,1600000000,\"b,c\",,\"{\"\"a\"\":\"\"b,c\"\"}\"\n", rec.Body.String())
+}
+
+func TestMessageProjectionKeepsNumbers(t *testing.T) {
+	projection, err := newMessageProjection([]string{"$.id", "$.items[*].qty"})
+	require.NoError(t, err)
+
+	msg := testExportMessage(0)
+	msg.Value = kafka.DirectEmbedding{Value: []byte(`{"id":12345678901234567890,"items":[{"qty":1},{"qty":2.5}]}`), ValueType: "json"}
+	row, err := json.Marshal(projection.row(msg))
+	require.NoError(t, err)
+	assert.Equal(t, `{"partitionID":0,"offset":0,"timestamp":1600000000,"columns":[12345678901234567890,[1,2.5]]}`, string(row))
+
+	_, err = newMessageProjection([]string{"$..id"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"context"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+)
+
+// messageMasker returns the masker for messages of the given topic which are consumed by the logged in user. The
+// masking profile of a consume template is applied in addition to the profiles of the user's roles. Nil is returned
+// if nothing must be masked.
+func (api *API) messageMasker(ctx context.Context, topicName string, templateProfile string) (*masking.Masker, *rest.Error) {
+	roles, restErr := api.Hooks.Owl.RequesterRoles(ctx)
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	if templateProfile == "" {
+		return api.MaskingSvc.Masker(topicName, roles), nil
+	}
+	return api.MaskingSvc.Masker(topicName, roles, templateProfile), nil
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudhut/kowl/backend/pkg/jsonpath"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// maxProjectionColumns is the maximum number of JSONPath expressions of a single projection
+const maxProjectionColumns = 50
+
+// messageProjection extracts one column per JSONPath expression from message values, so that searches and exports
+// can return flat rows instead of full payloads
+type messageProjection struct {
+	paths []*jsonpath.Path
+}
+
+// projectedRow is a message reduced to its coordinates and the extracted columns. Columns whose expression didn't
+// match are null.
+type projectedRow struct {
+	PartitionID int32         `json:"partitionID"`
+	Offset      int64         `json:"offset"`
+	Timestamp   int64         `json:"timestamp"`
+	Columns     []interface{} `json:"columns"`
+}
+
+// newMessageProjection compiles the expressions. It returns nil if no expressions are given, which means that full
+// messages are returned.
+func newMessageProjection(expressions []string) (*messageProjection, error) {
+	if len(expressions) == 0 {
+		return nil, nil
+	}
+	if len(expressions) > maxProjectionColumns {
+		return nil, fmt.Errorf("projection must not have more than %d expressions", maxProjectionColumns)
+	}
+
+	paths := make([]*jsonpath.Path, len(expressions))
+	for i, expr := range expressions {
+		path, err := jsonpath.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid projection expression '%v': %w", expr, err)
+		}
+		paths[i] = path
+	}
+
+	return &messageProjection{paths: paths}, nil
+}
+
+// columnNames returns the expressions in the order of the columns
+func (p *messageProjection) columnNames() []string {
+	names := make([]string, len(p.paths))
+	for i, path := range p.paths {
+		names[i] = path.String()
+	}
+	return names
+}
+
+// row evaluates all expressions against the message value
+func (p *messageProjection) row(msg *kafka.TopicMessage) projectedRow {
+	row := projectedRow{
+		PartitionID: msg.PartitionID,
+		Offset:      msg.Offset,
+		Timestamp:   msg.Timestamp,
+		Columns:     make([]interface{}, len(p.paths)),
+	}
+
+	doc, ok := msg.Value.ParseDocument()
+	if !ok {
+		return row
+	}
+	for i, path := range p.paths {
+		if value, found := path.Lookup(doc); found {
+			row.Columns[i] = value
+		}
+	}
+
+	return row
+}
+
+// csvColumn renders a column value for CSV exports. Strings and numbers are written as they are, all other values
+// as JSON and missing values as empty field.
+func csvColumn(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+)
+
+// resolveACLPrincipals attaches the friendly names of the principals to all ACLs. It's a no-op if principal
+// resolution is disabled.
+func (api *API) resolveACLPrincipals(resources []owl.ACLResource) {
+	if api.PrincipalResolver == nil {
+		return
+	}
+	for i := range resources {
+		for j := range resources[i].ACLs {
+			acl := &resources[i].ACLs[j]
+			acl.Identity = api.PrincipalResolver.ResolvePrincipal(acl.Principal)
+		}
+	}
+}
+
+// resolveGroupMembers attaches the friendly names of the members' clients to all group members. It's a no-op if
+// principal resolution is disabled.
+func (api *API) resolveGroupMembers(members []*owl.GroupMemberDescription) {
+	if api.PrincipalResolver == nil {
+		return
+	}
+	for _, member := range members {
+		member.Identity = api.PrincipalResolver.ResolveClientID(member.ClientID)
+	}
+}
+
+// resolveGroupMemberHosts is resolveGroupMembers for the members of a group drill-down
+func (api *API) resolveGroupMemberHosts(members []owl.GroupMemberHosts) {
+	descriptions := make([]*owl.GroupMemberDescription, len(members))
+	for i := range members {
+		descriptions[i] = members[i].GroupMemberDescription
+	}
+	api.resolveGroupMembers(descriptions)
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+)
+
+// requesterID returns an identifier for the user who sent the request. That's the name of the authenticated user,
+// or the client's IP address (which has been resolved by the RealIP middleware) for anonymous requests.
+func requesterID(r *http.Request) string {
+	if subject := authorization.SubjectFromContext(r.Context()); subject.Name != "" {
+		return subject.Name
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack"
+	"go.uber.org/zap"
+)
+
+const (
+	contentTypeJSON    = "application/json"
+	contentTypeCBOR    = "application/cbor"
+	contentTypeMsgPack = "application/msgpack"
+)
+
+// compressibleContentTypes are the response content types which are compressed if the client accepts it
+var compressibleContentTypes = []string{
+	"text/html", "text/css", "text/plain", "text/csv", "text/javascript", "application/javascript",
+	"image/svg+xml", contentTypeJSON, "application/x-ndjson", contentTypeCBOR, contentTypeMsgPack,
+}
+
+// encoderZstd is a chi compressor encoder. The encoder implements Reset and is therefore pooled by the compressor.
+func encoderZstd(w io.Writer, level int) io.Writer {
+	zw, err := zstd.NewWriter(w,
+		zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+		zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil
+	}
+	return zw
+}
+
+// responseEncodings maps the accepted media types to the content type of the binary encodings we support
+var responseEncodings = map[string]string{
+	contentTypeJSON:           contentTypeJSON,
+	contentTypeCBOR:           contentTypeCBOR,
+	contentTypeMsgPack:        contentTypeMsgPack,
+	"application/x-msgpack":   contentTypeMsgPack,
+	"application/vnd.msgpack": contentTypeMsgPack,
+}
+
+// negotiateResponseEncoding returns the content type the client prefers according to its Accept header. JSON is
+// preferred on ties and if no supported type is explicitly listed (e.g. "*/*").
+func negotiateResponseEncoding(accept string) string {
+	best := contentTypeJSON
+	bestQ := -1.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		contentType, ok := responseEncodings[mediaType]
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if qStr, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(qStr, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q > bestQ || (q == bestQ && contentType == contentTypeJSON) {
+			best, bestQ = contentType, q
+		}
+	}
+	if bestQ <= 0 {
+		return contentTypeJSON
+	}
+
+	return best
+}
+
+// encodeJSONAs converts the given JSON document into CBOR or MessagePack
+func encodeJSONAs(contentType string, jsonDoc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonDoc))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode json response: %w", err)
+	}
+	doc = resolveJSONNumbers(doc)
+
+	switch contentType {
+	case contentTypeCBOR:
+		return cbor.Marshal(doc)
+	case contentTypeMsgPack:
+		return msgpack.Marshal(doc)
+	}
+
+	return nil, fmt.Errorf("unsupported content type '%v'", contentType)
+}
+
+// resolveJSONNumbers replaces all json.Numbers with int64 or float64 values, so that integers are encoded as such
+func resolveJSONNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]interface{}:
+		for k, child := range val {
+			val[k] = resolveJSONNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = resolveJSONNumbers(child)
+		}
+	}
+
+	return v
+}
+
+// responseEncoding re-encodes JSON responses as CBOR or MessagePack if the client prefers one of them via the
+// Accept header. All other responses are passed through unchanged.
+func (api *API) responseEncoding(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := negotiateResponseEncoding(r.Header.Get("Accept"))
+		if contentType == contentTypeJSON {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ew := &encodingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(ew, r)
+		if !ew.isBuffering {
+			return
+		}
+
+		encoded, err := encodeJSONAs(contentType, ew.buf.Bytes())
+		if err != nil {
+			// Fall back to the JSON response rather than failing the request
+			api.Logger.Warn("failed to encode response", zap.String("content_type", contentType), zap.Error(err))
+			contentType, encoded = contentTypeJSON, ew.buf.Bytes()
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Del("Content-Length")
+		w.Header().Add("Vary", "Accept")
+		w.WriteHeader(ew.status)
+		_, _ = w.Write(encoded)
+	})
+}
+
+// encodingResponseWriter buffers JSON responses so that they can be re-encoded once the handler returns
+type encodingResponseWriter struct {
+	http.ResponseWriter
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	isBuffering bool
+}
+
+func (ew *encodingResponseWriter) WriteHeader(status int) {
+	if ew.wroteHeader {
+		return
+	}
+	ew.wroteHeader = true
+	ew.status = status
+
+	mediaType, _, _ := mime.ParseMediaType(ew.Header().Get("Content-Type"))
+	ew.isBuffering = mediaType == contentTypeJSON
+	if !ew.isBuffering {
+		ew.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (ew *encodingResponseWriter) Write(b []byte) (int, error) {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.isBuffering {
+		return ew.buf.Write(b)
+	}
+	return ew.ResponseWriter.Write(b)
+}
+
+// Flush forwards flushes of streamed responses, buffered JSON responses are written at once when the handler returns
+func (ew *encodingResponseWriter) Flush() {
+	if ew.isBuffering {
+		return
+	}
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack"
+)
+
+func TestNegotiateResponseEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"empty header", "", contentTypeJSON},
+		{"wildcard", "*/*", contentTypeJSON},
+		{"cbor", "application/cbor", contentTypeCBOR},
+		{"msgpack alias", "application/x-msgpack", contentTypeMsgPack},
+		{"json preferred on tie", "application/cbor, application/json", contentTypeJSON},
+		{"higher quality wins", "application/json;q=0.5, application/msgpack;q=0.9", contentTypeMsgPack},
+		{"rejected type", "application/cbor;q=0", contentTypeJSON},
+		{"invalid quality is ignored", "application/cbor;q=abc, text/html", contentTypeJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiateResponseEncoding(tt.accept))
+		})
+	}
+}
+
+func TestEncodeJSONAs(t *testing.T) {
+	jsonDoc := []byte(`{"offset":12345678901,"ratio":0.5,"name":"orders","tags":["a",null,true]}`)
+
+	encoded, err := encodeJSONAs(contentTypeCBOR, jsonDoc)
+	require.NoError(t, err)
+	var cborDoc map[string]interface{}
+	require.NoError(t, cbor.Unmarshal(encoded, &cborDoc))
+	assert.Equal(t, uint64(12345678901), cborDoc["offset"])
+	assert.Equal(t, 0.5, cborDoc["ratio"])
+	assert.Equal(t, []interface{}{"a", nil, true}, cborDoc["tags"])
+
+	encoded, err = encodeJSONAs(contentTypeMsgPack, jsonDoc)
+	require.NoError(t, err)
+	var msgpackDoc map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(encoded, &msgpackDoc))
+	assert.EqualValues(t, 12345678901, msgpackDoc["offset"])
+	assert.Equal(t, "orders", msgpackDoc["name"])
+
+	_, err = encodeJSONAs(contentTypeCBOR, []byte(`{"invalid`))
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"github.com/cloudhut/common/middleware"
 	"github.com/cloudhut/common/rest"
 	"github.com/go-chi/chi"
@@ -28,9 +29,11 @@ func (api *API) routes() *chi.Mux {
 		// Init middlewares - Do set up of any shared/third-party middleware and handlers
 		if api.Cfg.REST.CompressionLevel > 0 {
 			api.Logger.Debug("using compression for all http routes", zap.Int("level", api.Cfg.REST.CompressionLevel))
-			compressor := chimiddleware.NewCompressor(api.Cfg.REST.CompressionLevel)
+			compressor := chimiddleware.NewCompressor(api.Cfg.REST.CompressionLevel, compressibleContentTypes...)
+			compressor.SetEncoder("zstd", encoderZstd)
 			router.Use(compressor.Handler)
 		}
+		router.Use(api.responseEncoding)
 
 		router.Use(
 			middleware.Intercept,
@@ -48,23 +51,48 @@ func (api *API) routes() *chi.Mux {
 				r.Handle("/metrics", promhttp.Handler())
 				r.Handle("/health", api.handleLivenessProbe())
 				r.Handle("/startup", api.handleStartupProbe())
+				r.Get("/kafka-request-log", api.handleGetKafkaRequestLog())
+				r.Put("/kafka-request-log", api.handlePutKafkaRequestLog())
 			})
 
+			// Prometheus' default scrape path
+			r.Handle("/metrics", promhttp.Handler())
+
 			// Path must be prefixed with /debug otherwise it will be overridden, see: https://golang.org/pkg/net/http/pprof/
 			r.Mount("/debug", chimiddleware.Profiler())
 		})
 
+		// SCIM routes for identity providers
+		if api.ScimDirectory != nil {
+			router.Route("/scim/v2", api.scimRoutes)
+		}
+
+		// OIDC login routes, which are called before the user has a session
+		if api.OIDCLogin != nil {
+			router.Route("/auth", api.oidcRoutes)
+		}
+
 		// API routes
 		router.Group(func(r chi.Router) {
 			api.Hooks.Route.ConfigAPIRouter(r)
 
 			r.Route("/api", func(r chi.Router) {
-				r.Get("/cluster", api.handleDescribeCluster())
-				r.Get("/topics", api.handleGetTopics())
-				r.Get("/topics/{topicName}/partitions", api.handleGetPartitions())
-				r.Get("/topics/{topicName}/configuration", api.handleGetTopicConfig())
-				r.Get("/topics/{topicName}/consumers", api.handleGetTopicConsumers())
-				r.Get("/consumer-groups", api.handleGetConsumerGroups())
+				if api.Authenticator != nil {
+					r.Use(api.authenticate)
+				}
+				if api.selfEvents != nil {
+					r.Use(api.emitRequestEvents)
+				}
+				if api.auditLog != nil {
+					r.Use(api.recordAuditLog)
+				}
+				api.apiRoutes(r)
+
+				// Additional clusters serve the same routes below /api/clusters/{clusterName}
+				r.Get("/clusters", api.handleGetClusters())
+				r.Get("/usage", api.handleGetUsageReport())
+				r.Get("/permalinks/{clusterName}/{topicName}/{partitionID}/{offset}", api.handleResolvePermalink())
+				api.clusterRoutes(r)
 			})
 		})
 
@@ -94,9 +122,108 @@ func (api *API) routes() *chi.Mux {
 	// Websockets live in it's own group because not all middlewares support websockets
 	baseRouter.Group(func(wsRouter chi.Router) {
 		api.Hooks.Route.ConfigWsRouter(wsRouter)
+		if api.Authenticator != nil {
+			wsRouter.Use(api.authenticate)
+		}
 
 		wsRouter.Get("/api/topics/{topicName}/messages", api.handleGetMessages())
+		wsRouter.Get("/api/ksql/servers/{serverName}/query", api.handleKsqlQuery())
+		for _, cluster := range api.Clusters {
+			wsRouter.With(api.checkClusterPermissions(cluster.Name)).
+				Get(fmt.Sprintf("/api/clusters/%v/topics/{topicName}/messages", cluster.Name), api.forCluster(cluster).handleGetMessages())
+		}
 	})
 
 	return baseRouter
 }
+
+// apiRoutes registers all routes which are served below /api for each cluster
+func (api *API) apiRoutes(r chi.Router) {
+	r.Get("/cluster", api.handleDescribeCluster())
+	r.Get("/cluster/capabilities", api.handleGetClusterCapabilities())
+	r.Get("/cluster/health", api.handleGetClusterHealth())
+	r.Get("/cluster/canary", api.handleGetCanaryStatus())
+	r.Get("/cluster/upgrade-readiness", api.handleGetUpgradeReadiness())
+	r.Get("/cluster/group-coordinators", api.handleGetGroupCoordinators())
+	r.Post("/cluster/preferred-leader-election", api.handleElectPreferredLeaders())
+	r.Get("/brokers/disk-usage", api.handleGetBrokerDiskUsage())
+	r.Get("/brokers/{brokerID}/restart-safety", api.handleGetBrokerRestartSafety())
+	r.Get("/brokers/{brokerID}/configuration", api.handleGetBrokerConfig())
+	r.Patch("/brokers/{brokerID}/configuration", api.handleAlterBrokerConfig())
+	r.Get("/topics", api.handleGetTopics())
+	r.With(api.idempotent).Post("/topics", api.handleCreateTopic())
+	r.Get("/topics/disk-usage", api.handleGetTopicSizes())
+	r.Delete("/topics/{topicName}", api.handleDeleteTopic())
+	r.Get("/topics/{topicName}/partitions", api.handleGetPartitions())
+	r.Patch("/topics/{topicName}/partitions", api.handleAddPartitions())
+	r.Get("/topics/{topicName}/disk-usage", api.handleGetTopicDiskUsage())
+	r.Get("/topics/{topicName}/partitions/{partitionID}/messages/{offset}", api.handleGetMessage())
+	r.Get("/topics/{topicName}/partitions/{partitionID}/messages/{offset}/raw", api.handleDownloadRawPayload())
+	r.Post("/topics/{topicName}/records/delete", api.handleDeleteRecords())
+	r.Get("/topics/{topicName}/configuration", api.handleGetTopicConfig())
+	r.Patch("/topics/{topicName}/configuration", api.handleAlterTopicConfig())
+	r.Get("/topics/{topicName}/consumers", api.handleGetTopicConsumers())
+	r.Get("/topics/{topicName}/timeline", api.handleGetTopicTimeline())
+	r.Get("/topics/{topicName}/table", api.handleGetTopicTable())
+	r.Get("/topics/{topicName}/header-index", api.handleLookupHeaderIndex())
+	r.Get("/topics/{topicName}/full-text-search", api.handleFullTextSearch())
+	r.Get("/topics/{topicName}/deserialization-report", api.handleGetDeserializationReport())
+	r.With(api.idempotent).Post("/topics/{topicName}/messages", api.handleProduceMessage())
+	r.Post("/topics/{topicName}/messages/export", api.handleExportMessages())
+	r.Post("/topics/{topicName}/messages/replay", api.handleReplayMessages())
+	r.Get("/topics/{topicName}/dlq", api.handleGetTopicDLQ())
+	r.Post("/topics/{topicName}/dlq/reprocess", api.handleReprocessDLQMessages())
+	r.Post("/topics/{topicName}/messages/lookup", api.handleLookupMessages())
+	r.Post("/topics/{topicName}/filter-test", api.handleTestFilter())
+	r.Post("/topics/{topicName}/smoke-test", api.handleRunSmokeTest())
+	r.Get("/topics/{topicName}/filter-typings", api.handleGetFilterTypings())
+	r.Get("/topics/{topicName}/saved-filters", api.handleGetSavedFilters())
+	r.Post("/topics/{topicName}/saved-filters", api.handleCreateSavedFilter())
+	r.Delete("/topics/{topicName}/saved-filters/{filterName}", api.handleDeleteSavedFilter())
+	r.Get("/topics/{topicName}/metadata", api.handleGetTopicMetadata())
+	r.Put("/topics/{topicName}/metadata", api.handlePutTopicMetadata())
+	r.Delete("/topics/{topicName}/metadata", api.handleDeleteTopicMetadata())
+	r.Get("/topic-metadata", api.handleGetTopicMetadataList())
+	r.Get("/topic-approvals", api.handleGetTopicApprovals())
+	r.Post("/topic-approvals/{requestID}/approve", api.handleApproveTopicRequest())
+	r.Post("/topic-approvals/{requestID}/reject", api.handleRejectTopicRequest())
+	r.Get("/consumer-groups", api.handleGetConsumerGroups())
+	r.Get("/acls", api.handleGetACLs())
+	r.Post("/acls", api.handleCreateACLs())
+	r.Delete("/acls", api.handleDeleteACLs())
+	r.Get("/scram-users", api.handleGetScramUsers())
+	r.Post("/scram-users", api.handleUpsertScramCredential())
+	r.Delete("/scram-users/{username}", api.handleDeleteScramCredential())
+	r.With(api.idempotent).Patch("/consumer-groups/{groupId}/offsets", api.handleResetConsumerGroupOffsets())
+	r.Get("/consumer-groups/{groupId}/offsets/export", api.handleExportConsumerGroupOffsets())
+	r.With(api.idempotent).Post("/consumer-groups/{groupId}/offsets/import", api.handleImportConsumerGroupOffsets())
+	r.Get("/consumer-groups/{groupId}/time-lag", api.handleGetConsumerGroupTimeLag())
+	r.Get("/consumer-groups/{groupId}/lag-history", api.handleGetConsumerGroupLagHistory())
+	r.Get("/consumer-groups/{groupId}/timeline", api.handleGetConsumerGroupTimeline())
+	r.Get("/consumer-groups/{groupId}/members", api.handleGetConsumerGroupMembers())
+	r.Delete("/consumer-groups/{groupId}", api.handleDeleteConsumerGroup())
+	r.Get("/consume-templates", api.handleGetConsumeTemplates())
+	r.Get("/saved-searches", api.handleGetSavedSearches())
+	r.Get("/saved-searches/{id}/run", api.handleRunSavedSearch())
+
+	// Schema Registry
+	r.Get("/schemas", api.handleGetSchemaOverview())
+	r.Get("/schemas/subjects/{subject}/versions/{version}", api.handleGetSchemaSubjectDetails())
+
+	// Kafka Connect
+	r.Get("/kafka-connect", api.handleGetConnectClusters())
+	r.Route("/kafka-connect/clusters/{clusterName}/connectors", func(r chi.Router) {
+		r.Get("/", api.handleGetConnectors())
+		r.Get("/{connector}", api.handleGetConnector())
+		r.Put("/{connector}", api.handlePutConnectorConfig())
+		r.Put("/{connector}/pause", api.handleConnectorAction(connectorActionPause))
+		r.Put("/{connector}/resume", api.handleConnectorAction(connectorActionResume))
+		r.Post("/{connector}/restart", api.handleConnectorAction(connectorActionRestart))
+		r.Post("/{connector}/tasks/{taskID}/restart", api.handleRestartConnectorTask())
+	})
+
+	// ksqlDB
+	r.Get("/ksql", api.handleGetKsqlServers())
+	r.Get("/ksql/servers/{serverName}/streams", api.handleGetKsqlStreams())
+	r.Get("/ksql/servers/{serverName}/queries", api.handleGetKsqlQueries())
+}
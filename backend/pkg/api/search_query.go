@@ -0,0 +1,32 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/query"
+)
+
+// parseSearchQuery compiles the query of a search or export. It returns nil if no query is given.
+func parseSearchQuery(searchQuery string) (*query.Query, error) {
+	if searchQuery == "" {
+		return nil, nil
+	}
+	q, err := query.Parse(searchQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	return q, nil
+}
+
+// applySearchQuery sets the native predicate of the query and restricts the request to the query's partition, unless
+// a partition has been requested explicitly. The js() terms must have been combined with the filter code already.
+func applySearchQuery(listReq *owl.ListMessageRequest, q *query.Query) {
+	if q == nil {
+		return
+	}
+	listReq.Predicate = q.Predicate()
+	if listReq.PartitionID == -1 && q.PartitionID() >= 0 {
+		listReq.PartitionID = q.PartitionID()
+	}
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Self event types
+const (
+	selfEventTypeSearch      = "search"
+	selfEventTypeExport      = "export"
+	selfEventTypeError       = "error"
+	selfEventTypeAdminAction = "adminAction"
+	selfEventTypeSummary     = "summary"
+)
+
+// selfEventReadOnlyRoutes are route suffixes of POST requests which don't modify anything and hence are no admin
+// actions
+var selfEventReadOnlyRoutes = []string{"/messages/export", "/filter-test"}
+
+// selfEvent is a single operational event which is produced as JSON record. Fields which don't apply to the event
+// type are omitted.
+type selfEvent struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Instance  string    `json:"instance"`
+
+	Requester    string `json:"requester,omitempty"`
+	Method       string `json:"method,omitempty"`
+	Route        string `json:"route,omitempty"`
+	Status       int    `json:"status,omitempty"`
+	TopicName    string `json:"topicName,omitempty"`
+	DurationMs   int64  `json:"durationMs,omitempty"`
+	MessageCount int64  `json:"messageCount,omitempty"` // Consumed messages of searches, exported rows of exports
+	Error        string `json:"error,omitempty"`
+
+	Summary *selfEventSummary `json:"summary,omitempty"`
+}
+
+// selfEventSummary counts the events of one flush interval, including those which have been dropped
+type selfEventSummary struct {
+	IntervalMs    int64 `json:"intervalMs"`
+	Searches      int64 `json:"searches"`
+	Errors        int64 `json:"errors"`
+	AdminActions  int64 `json:"adminActions"`
+	DroppedEvents int64 `json:"droppedEvents"` // Buffer was full
+	FailedEvents  int64 `json:"failedEvents"`  // Could not be produced
+}
+
+// selfEventEmitter buffers events and produces them periodically, so that requests never wait for Kafka. If the
+// buffer is full or the topic can't be written to, events are dropped and only counted in the next summary.
+type selfEventEmitter struct {
+	cfg      SelfEventsConfig
+	cluster  kafka.Cluster
+	logger   *zap.Logger
+	instance string
+
+	events       chan selfEvent
+	warningLimit *rate.Limiter
+
+	// Counters of the current interval, accessed atomically
+	searches     int64
+	errors       int64
+	adminActions int64
+	dropped      int64
+	failed       int64
+}
+
+func newSelfEventEmitter(cfg SelfEventsConfig, cluster kafka.Cluster, logger *zap.Logger) *selfEventEmitter {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = "unknown"
+	}
+
+	return &selfEventEmitter{
+		cfg:          cfg,
+		cluster:      cluster,
+		logger:       logger.With(zap.String("source", "self_events"), zap.String("topic", cfg.Topic)),
+		instance:     instance,
+		events:       make(chan selfEvent, cfg.MaxBufferedEvents),
+		warningLimit: rate.NewLimiter(rate.Every(time.Minute), 1),
+	}
+}
+
+// Emit enqueues the event without blocking. It's a no-op if the emitter is nil, which is the case if self events
+// are disabled.
+func (e *selfEventEmitter) Emit(event selfEvent) {
+	if e == nil {
+		return
+	}
+
+	switch event.Type {
+	case selfEventTypeSearch, selfEventTypeExport:
+		atomic.AddInt64(&e.searches, 1)
+	case selfEventTypeError:
+		atomic.AddInt64(&e.errors, 1)
+	case selfEventTypeAdminAction:
+		atomic.AddInt64(&e.adminActions, 1)
+	}
+
+	event.Timestamp = time.Now().UTC()
+	event.Instance = e.instance
+	select {
+	case e.events <- event:
+	default:
+		atomic.AddInt64(&e.dropped, 1)
+	}
+}
+
+// Start produces the buffered events in the configured interval until the process exits
+func (e *selfEventEmitter) Start() {
+	if e == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(e.cfg.FlushInterval)
+		defer ticker.Stop()
+
+		last := time.Now()
+		for now := range ticker.C {
+			e.flush(now.Sub(last))
+			last = now
+		}
+	}()
+}
+
+// flush produces all currently buffered events followed by the summary of the interval. Once producing fails the
+// remaining events are dropped, so that an unavailable cluster doesn't delay the next intervals.
+func (e *selfEventEmitter) flush(interval time.Duration) {
+	pending := len(e.events)
+	failed := false
+	for i := 0; i < pending; i++ {
+		event := <-e.events
+		if failed {
+			atomic.AddInt64(&e.failed, 1)
+			continue
+		}
+		if err := e.produce(event); err != nil {
+			atomic.AddInt64(&e.failed, 1)
+			failed = true
+		}
+	}
+
+	summary := &selfEventSummary{
+		IntervalMs:    interval.Milliseconds(),
+		Searches:      atomic.SwapInt64(&e.searches, 0),
+		Errors:        atomic.SwapInt64(&e.errors, 0),
+		AdminActions:  atomic.SwapInt64(&e.adminActions, 0),
+		DroppedEvents: atomic.SwapInt64(&e.dropped, 0),
+		FailedEvents:  atomic.SwapInt64(&e.failed, 0),
+	}
+	if failed {
+		// The summary would most likely fail as well, its counters are carried over into the next interval
+		atomic.AddInt64(&e.searches, summary.Searches)
+		atomic.AddInt64(&e.errors, summary.Errors)
+		atomic.AddInt64(&e.adminActions, summary.AdminActions)
+		atomic.AddInt64(&e.dropped, summary.DroppedEvents)
+		atomic.AddInt64(&e.failed, summary.FailedEvents)
+		return
+	}
+	_ = e.produce(selfEvent{Type: selfEventTypeSummary, Timestamp: time.Now().UTC(), Instance: e.instance, Summary: summary})
+}
+
+func (e *selfEventEmitter) produce(event selfEvent) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.cluster.Produce(kafka.ProduceRecord{
+		TopicName:   e.cfg.Topic,
+		Partitioner: kafka.PartitionerHash,
+		Key:         []byte(e.instance),
+		Value:       value,
+	}, kafka.ProduceOptions{})
+	if err != nil && e.warningLimit.Allow() {
+		e.logger.Warn("failed to produce self event, events are dropped until the topic can be written to", zap.Error(err))
+	}
+
+	return err
+}
+
+// emitRequestEvents emits an error event for each failed request and an admin action event for each successful
+// request which modifies the cluster
+func (api *API) emitRequestEvents(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		route := r.URL.Path
+		if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil && routeCtx.RoutePattern() != "" {
+			route = routeCtx.RoutePattern()
+		}
+		event := selfEvent{
+			Requester: requesterID(r),
+			Method:    r.Method,
+			Route:     route,
+			Status:    rw.status,
+		}
+
+		switch {
+		case rw.status >= http.StatusInternalServerError:
+			event.Type = selfEventTypeError
+		case rw.status < http.StatusBadRequest && isModifyingRequest(r.Method, route):
+			event.Type = selfEventTypeAdminAction
+		default:
+			return
+		}
+		api.selfEvents.Emit(event)
+	})
+}
+
+// emitSearchEvent emits an event for a completed message search or export
+func (api *API) emitSearchEvent(r *http.Request, eventType string, topicName string, duration time.Duration, messageCount int64, err error) {
+	event := selfEvent{
+		Type:         eventType,
+		Requester:    requesterID(r),
+		TopicName:    topicName,
+		DurationMs:   duration.Milliseconds(),
+		MessageCount: messageCount,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	api.selfEvents.Emit(event)
+}
+
+func isModifyingRequest(method string, route string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	case http.MethodPost:
+		for _, suffix := range selfEventReadOnlyRoutes {
+			if strings.HasSuffix(route, suffix) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// statusResponseWriter passes the response through while keeping its status code
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rw *statusResponseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Flush forwards flushes of streamed responses such as exports
+func (rw *statusResponseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestSelfEventEmitter(t *testing.T, maxBufferedEvents int) (*selfEventEmitter, *kafka.FakeCluster) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("kowl-events", 1, 1, nil, false))
+
+	cfg := SelfEventsConfig{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.Topic = "kowl-events"
+	cfg.MaxBufferedEvents = maxBufferedEvents
+
+	return newSelfEventEmitter(cfg, cluster, zap.NewNop()), cluster
+}
+
+func consumeSelfEvents(t *testing.T, cluster *kafka.FakeCluster, count int) []selfEvent {
+	consumer, err := cluster.NewConsumer(sarama.ReadUncommitted)
+	require.NoError(t, err)
+	pc, err := consumer.ConsumePartition("kowl-events", 0, sarama.OffsetOldest)
+	require.NoError(t, err)
+	defer pc.Close()
+
+	events := make([]selfEvent, count)
+	for i := range events {
+		select {
+		case msg := <-pc.Messages():
+			require.NoError(t, json.Unmarshal(msg.Value, &events[i]))
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %v", i)
+		}
+	}
+	return events
+}
+
+func TestSelfEventEmitterFlush(t *testing.T) {
+	emitter, cluster := newTestSelfEventEmitter(t, 2)
+	emitter.Emit(selfEvent{Type: selfEventTypeSearch, TopicName: "orders", MessageCount: 42})
+	emitter.Emit(selfEvent{Type: selfEventTypeAdminAction, Method: http.MethodDelete, Route: "/api/topics/{topicName}"})
+	emitter.Emit(selfEvent{Type: selfEventTypeError, Status: http.StatusInternalServerError}) // Buffer is full
+	emitter.flush(10 * time.Second)
+
+	events := consumeSelfEvents(t, cluster, 3)
+	assert.Equal(t, selfEventTypeSearch, events[0].Type)
+	assert.Equal(t, int64(42), events[0].MessageCount)
+	assert.NotEmpty(t, events[0].Instance)
+	assert.Equal(t, selfEventTypeAdminAction, events[1].Type)
+
+	require.Equal(t, selfEventTypeSummary, events[2].Type)
+	assert.Equal(t, selfEventSummary{IntervalMs: 10000, Searches: 1, Errors: 1, AdminActions: 1, DroppedEvents: 1}, *events[2].Summary)
+}
+
+func TestSelfEventEmitterCarriesOverCountersIfProduceFails(t *testing.T) {
+	emitter, cluster := newTestSelfEventEmitter(t, 10)
+	require.NoError(t, cluster.DeleteTopic("kowl-events"))
+	emitter.Emit(selfEvent{Type: selfEventTypeSearch})
+	emitter.Emit(selfEvent{Type: selfEventTypeSearch})
+	emitter.flush(time.Second)
+
+	require.NoError(t, cluster.CreateTopic("kowl-events", 1, 1, nil, false))
+	emitter.flush(time.Second)
+
+	events := consumeSelfEvents(t, cluster, 1)
+	require.Equal(t, selfEventTypeSummary, events[0].Type)
+	assert.Equal(t, int64(2), events[0].Summary.Searches)
+	assert.Equal(t, int64(2), events[0].Summary.FailedEvents)
+}
+
+func TestIsModifyingRequest(t *testing.T) {
+	assert.False(t, isModifyingRequest(http.MethodGet, "/api/topics"))
+	assert.True(t, isModifyingRequest(http.MethodPost, "/api/topics"))
+	assert.True(t, isModifyingRequest(http.MethodDelete, "/api/topics/{topicName}"))
+	assert.False(t, isModifyingRequest(http.MethodPost, "/api/clusters/b/topics/{topicName}/messages/export"))
+}
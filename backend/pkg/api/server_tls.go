@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/cloudhut/common/rest"
+	"github.com/cloudhut/kowl/backend/pkg/tlsreload"
+	"go.uber.org/zap"
+)
+
+// startTLSServer is like rest.Server.Start, but serves HTTPS with the given (reloadable) certificate. It blocks
+// until we either receive a signal or the HTTP server returns an error.
+func startTLSServer(server *rest.Server, cfg *ServerConfig, cert *tlsreload.Certificate) error {
+	shutdownDone := make(chan struct{})
+
+	// Listen for signals - shutdown the server if we receive one
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ServerGracefulShutdownTimeout)
+		defer cancel()
+
+		server.Logger.Info("Stopping HTTP server", zap.String("reason", "received signal"))
+		server.Server.SetKeepAlivesEnabled(false)
+		err := server.Server.Shutdown(ctx)
+		if err != nil {
+			server.Logger.Panic(err.Error())
+		}
+
+		close(shutdownDone)
+	}()
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(cfg.HTTPListenPort)))
+	if err != nil {
+		return err
+	}
+	server.Logger.Info("Server listening on address with TLS", zap.String("address", listener.Addr().String()), zap.Int("port", cfg.HTTPListenPort))
+
+	// ServeTLS takes the certificate from the TLS config's GetCertificate, if no certificate files are given
+	server.Server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cert.GetCertificate,
+	}
+	err = server.Server.ServeTLS(listener, "", "")
+	if err != http.ErrServerClosed {
+		return err
+	}
+
+	<-shutdownDone
+	server.Logger.Info("Stopped HTTP server")
+
+	return nil
+}
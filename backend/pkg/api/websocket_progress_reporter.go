@@ -5,6 +5,7 @@ import (
 	"github.com/cloudhut/kowl/backend/pkg/kafka"
 	"github.com/cloudhut/kowl/backend/pkg/owl"
 	"go.uber.org/zap"
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,15 +17,98 @@ type progressReporter struct {
 	request   *owl.ListMessageRequest
 	websocket *websocketClient
 
+	// projection is nil unless the search returns flat rows instead of messages
+	projection *messageProjection
+
 	statsMutex       *sync.RWMutex
 	messagesConsumed int64
 	bytesConsumed    int64
+	partitions       map[int32]*partitionProgress
+}
+
+// maxOffsetGapsPerPartition limits the number of gaps which are reported individually for each partition. Further
+// gaps are still counted in SkippedOffsets.
+const maxOffsetGapsPerPartition = 50
+
+// partitionProgress is the progress of a single partition consumer. CurrentOffset is -1 until the first message has
+// been consumed.
+type partitionProgress struct {
+	PartitionID      int32 `json:"partitionId"`
+	StartOffset      int64 `json:"startOffset"`
+	EndOffset        int64 `json:"endOffset"`
+	CurrentOffset    int64 `json:"currentOffset"`
+	MessagesConsumed int64 `json:"messagesConsumed"`
+	MessagesMatched  int64 `json:"messagesMatched"`
+	BytesConsumed    int64 `json:"bytesConsumed"`
+
+	// SkippedOffsets is the number of offsets within the consumed range which don't belong to a consumable message.
+	// Such offsets are taken by transaction markers, messages of aborted transactions (when reading committed
+	// messages only) or records which have been removed by compaction.
+	SkippedOffsets      int64       `json:"skippedOffsets"`
+	OffsetGaps          []offsetGap `json:"offsetGaps"`
+	OffsetGapsTruncated bool        `json:"offsetGapsTruncated"` // True if there were more than maxOffsetGapsPerPartition gaps
+
+	// Inconsistencies are reported if the partition's leader or log has changed during the search, so that the
+	// returned messages may not match the partition's current content
+	Inconsistencies []kafka.PartitionInconsistency `json:"inconsistencies,omitempty"`
+
+	// The watermarks are refreshed periodically during the search. MessagesBehind is the number of offsets between
+	// the next offset to consume and the high watermark, i.e. how far the search is behind the partition's end.
+	LowWaterMark   int64 `json:"lowWaterMark"`
+	HighWaterMark  int64 `json:"highWaterMark"`
+	MessagesBehind int64 `json:"messagesBehind"`
+
+	// initialHighWaterMark is the next offset to consume of live tails until the first message has been consumed
+	initialHighWaterMark int64
+}
+
+// offsetGap is a range of skipped offsets [StartOffset, EndOffset)
+type offsetGap struct {
+	StartOffset int64 `json:"startOffset"`
+	EndOffset   int64 `json:"endOffset"`
+}
+
+// onMessageConsumed advances the current offset and records the gap to the previously consumed offset. The start
+// offset is unknown (negative) when consuming the newest messages, gaps are then detected after the first message.
+func (p *partitionProgress) onMessageConsumed(offset int64, size int64) {
+	expected := p.CurrentOffset + 1
+	if p.CurrentOffset < 0 {
+		expected = p.StartOffset
+	}
+	if expected >= 0 && offset > expected {
+		p.SkippedOffsets += offset - expected
+		if len(p.OffsetGaps) < maxOffsetGapsPerPartition {
+			p.OffsetGaps = append(p.OffsetGaps, offsetGap{StartOffset: expected, EndOffset: offset})
+		} else {
+			p.OffsetGapsTruncated = true
+		}
+	}
+
+	p.CurrentOffset = offset
+	p.MessagesConsumed++
+	p.BytesConsumed += size
+}
+
+// messagesBehind returns the number of offsets between the next offset to consume and the high watermark
+func (p *partitionProgress) messagesBehind() int64 {
+	next := p.CurrentOffset + 1
+	switch {
+	case p.CurrentOffset >= 0:
+	case p.StartOffset >= 0:
+		next = p.StartOffset
+	default:
+		next = p.initialHighWaterMark
+	}
+	if p.HighWaterMark <= next {
+		return 0
+	}
+	return p.HighWaterMark - next
 }
 
 func (p *progressReporter) Start() {
 	// If search is disabled do not report progress regularly as each consumed message will be sent through the socket
-	// anyways
-	if p.request.FilterInterpreterCode == "" {
+	// anyways. Live tails report their progress nevertheless, so that users can see how far they are behind.
+	if !p.request.IsFiltered() && !p.request.LiveTail {
 		return
 	}
 
@@ -48,11 +132,32 @@ func (p *progressReporter) reportProgress() {
 	p.statsMutex.RLock()
 	defer p.statsMutex.RUnlock()
 
+	partitions := p.partitionProgress()
+	messagesBehind := int64(0)
+	for _, partition := range partitions {
+		messagesBehind += partition.MessagesBehind
+	}
+
 	_ = p.websocket.writeJSON(struct {
-		Type             string `json:"type"`
-		MessagesConsumed int64  `json:"messagesConsumed"`
-		BytesConsumed    int64  `json:"bytesConsumed"`
-	}{"progressUpdate", p.messagesConsumed, p.bytesConsumed})
+		Type             string              `json:"type"`
+		MessagesConsumed int64               `json:"messagesConsumed"`
+		BytesConsumed    int64               `json:"bytesConsumed"`
+		MessagesBehind   int64               `json:"messagesBehind"`
+		Partitions       []partitionProgress `json:"partitions"`
+	}{"progressUpdate", p.messagesConsumed, p.bytesConsumed, messagesBehind, partitions})
+}
+
+// partitionProgress returns a copy of the progress of all partitions ordered by partition id. The caller must hold
+// the stats lock while the result is in use, because the offset gaps are not copied.
+func (p *progressReporter) partitionProgress() []partitionProgress {
+	res := make([]partitionProgress, 0, len(p.partitions))
+	for _, partition := range p.partitions {
+		progress := *partition
+		progress.MessagesBehind = partition.messagesBehind()
+		res = append(res, progress)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].PartitionID < res[j].PartitionID })
+	return res
 }
 
 func (p *progressReporter) OnPhase(name string) {
@@ -62,32 +167,108 @@ func (p *progressReporter) OnPhase(name string) {
 	}{"phase", name})
 }
 
-func (p *progressReporter) OnMessageConsumed(size int64) {
+func (p *progressReporter) OnConsumeRequests(requests map[int32]*kafka.PartitionConsumeRequest) {
+	p.statsMutex.Lock()
+	defer p.statsMutex.Unlock()
+
+	p.partitions = make(map[int32]*partitionProgress, len(requests))
+	for partitionID, req := range requests {
+		p.partitions[partitionID] = &partitionProgress{
+			PartitionID:          partitionID,
+			StartOffset:          req.StartOffset,
+			EndOffset:            req.EndOffset,
+			CurrentOffset:        -1,
+			OffsetGaps:           make([]offsetGap, 0),
+			LowWaterMark:         req.LowWaterMark,
+			HighWaterMark:        req.HighWaterMark,
+			initialHighWaterMark: req.HighWaterMark,
+		}
+	}
+}
+
+func (p *progressReporter) OnWaterMarks(marks map[int32]*kafka.WaterMark) {
+	p.statsMutex.Lock()
+	defer p.statsMutex.Unlock()
+
+	for partitionID, mark := range marks {
+		if partition, ok := p.partitions[partitionID]; ok {
+			partition.LowWaterMark = mark.Low
+			partition.HighWaterMark = mark.High
+		}
+	}
+}
+
+func (p *progressReporter) OnMessageConsumed(partitionID int32, offset int64, size int64) {
 	p.statsMutex.Lock()
 	defer p.statsMutex.Unlock()
 
 	p.messagesConsumed++
 	p.bytesConsumed += size
+	if partition, ok := p.partitions[partitionID]; ok {
+		partition.onMessageConsumed(offset, size)
+	}
+}
+
+func (p *progressReporter) OnMessageMatched(partitionID int32) {
+	p.statsMutex.Lock()
+	defer p.statsMutex.Unlock()
+
+	if partition, ok := p.partitions[partitionID]; ok {
+		partition.MessagesMatched++
+	}
+}
+
+// consumedMessages returns the number of messages which have been consumed so far
+func (p *progressReporter) consumedMessages() int64 {
+	p.statsMutex.RLock()
+	defer p.statsMutex.RUnlock()
+
+	return p.messagesConsumed
 }
 
 func (p *progressReporter) OnMessage(message *kafka.TopicMessage) {
+	if p.projection != nil {
+		_ = p.websocket.writeJSON(struct {
+			Type string       `json:"type"`
+			Row  projectedRow `json:"row"`
+		}{"row", p.projection.row(message)})
+		return
+	}
+
 	_ = p.websocket.writeJSON(struct {
 		Type    string              `json:"type"`
 		Message *kafka.TopicMessage `json:"message"`
 	}{"message", message})
 }
 
+func (p *progressReporter) OnMessagesDropped(count int64) {
+	_ = p.websocket.writeJSON(struct {
+		Type            string `json:"type"`
+		DroppedMessages int64  `json:"droppedMessages"`
+	}{"throttled", count})
+}
+
+func (p *progressReporter) OnInconsistency(inconsistency kafka.PartitionInconsistency) {
+	p.statsMutex.Lock()
+	defer p.statsMutex.Unlock()
+
+	if partition, ok := p.partitions[inconsistency.PartitionID]; ok {
+		partition.Inconsistencies = append(partition.Inconsistencies, inconsistency)
+	}
+}
+
 func (p *progressReporter) OnComplete(elapsedMs int64, isCancelled bool) {
 	p.statsMutex.RLock()
 	defer p.statsMutex.RUnlock()
 
 	_ = p.websocket.writeJSON(struct {
-		Type             string `json:"type"`
-		ElapsedMs        int64  `json:"elapsedMs"`
-		IsCancelled      bool   `json:"isCancelled"`
-		MessagesConsumed int64  `json:"messagesConsumed"`
-		BytesConsumed    int64  `json:"bytesConsumed"`
-	}{"done", elapsedMs, isCancelled, p.messagesConsumed, p.bytesConsumed})
+		Type             string              `json:"type"`
+		ElapsedMs        int64               `json:"elapsedMs"`
+		IsCancelled      bool                `json:"isCancelled"`
+		MessagesConsumed int64               `json:"messagesConsumed"`
+		BytesConsumed    int64               `json:"bytesConsumed"`
+		Partitions       []partitionProgress `json:"partitions"`
+	}{"done", elapsedMs, isCancelled, p.messagesConsumed, p.bytesConsumed, p.partitionProgress()})
 }
 
 func (p *progressReporter) OnError(message string) {
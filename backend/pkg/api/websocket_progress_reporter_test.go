@@ -0,0 +1,57 @@
+package api
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionProgress_OffsetGaps(t *testing.T) {
+	p := &partitionProgress{StartOffset: 10, EndOffset: 30, CurrentOffset: -1, OffsetGaps: make([]offsetGap, 0)}
+	for _, offset := range []int64{12, 13, 14, 20, 21} {
+		p.onMessageConsumed(offset, 1)
+	}
+	assert.Equal(t, int64(7), p.SkippedOffsets)
+	assert.Equal(t, []offsetGap{{10, 12}, {15, 20}}, p.OffsetGaps)
+	assert.Equal(t, int64(21), p.CurrentOffset)
+	assert.Equal(t, int64(5), p.MessagesConsumed)
+
+	// Consuming the newest messages starts at an unknown offset
+	p = &partitionProgress{StartOffset: -1, CurrentOffset: -1, OffsetGaps: make([]offsetGap, 0)}
+	for i := int64(0); i <= maxOffsetGapsPerPartition+1; i++ {
+		p.onMessageConsumed(100+i*2, 1)
+	}
+	assert.Len(t, p.OffsetGaps, maxOffsetGapsPerPartition)
+	assert.True(t, p.OffsetGapsTruncated)
+	assert.Equal(t, int64(maxOffsetGapsPerPartition+1), p.SkippedOffsets)
+}
+
+func TestPartitionProgress_MessagesBehind(t *testing.T) {
+	reporter := &progressReporter{statsMutex: &sync.RWMutex{}}
+	reporter.OnConsumeRequests(map[int32]*kafka.PartitionConsumeRequest{
+		0: {PartitionID: 0, LowWaterMark: 0, HighWaterMark: 100, StartOffset: 40, EndOffset: 99},
+		1: {PartitionID: 1, LowWaterMark: 0, HighWaterMark: 50, StartOffset: sarama.OffsetNewest, EndOffset: math.MaxInt64},
+	})
+	partitions := reporter.partitionProgress()
+	assert.Equal(t, int64(60), partitions[0].MessagesBehind)
+	assert.Equal(t, int64(0), partitions[1].MessagesBehind)
+
+	// Live tails start at the high watermark, they fall behind once messages are produced faster than consumed
+	reporter.OnMessageConsumed(0, 59, 1)
+	reporter.OnWaterMarks(map[int32]*kafka.WaterMark{
+		0: {PartitionID: 0, Low: 10, High: 120},
+		1: {PartitionID: 1, Low: 0, High: 80},
+	})
+	partitions = reporter.partitionProgress()
+	assert.Equal(t, int64(10), partitions[0].LowWaterMark)
+	assert.Equal(t, int64(120), partitions[0].HighWaterMark)
+	assert.Equal(t, int64(60), partitions[0].MessagesBehind)
+	assert.Equal(t, int64(30), partitions[1].MessagesBehind)
+
+	reporter.OnMessageConsumed(1, 79, 1)
+	assert.Equal(t, int64(0), reporter.partitionProgress()[1].MessagesBehind)
+}
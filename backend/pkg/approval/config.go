@@ -0,0 +1,79 @@
+package approval
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Operations which can be configured to require an approval
+const (
+	OperationCreateTopic   = "createTopic"
+	OperationDeleteTopic   = "deleteTopic"
+	OperationAddPartitions = "addPartitions"
+)
+
+// Config for the approval workflow. If enabled, the configured topic operations are not applied when they are
+// requested, but once a second user has approved them.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Operations which require an approval, all operations by default
+	Operations []string `yaml:"operations"`
+
+	// RequestTTL is the time after which pending requests expire
+	RequestTTL time.Duration `yaml:"requestTtl"`
+
+	// MaxPendingRequests bounds the memory of pending requests, further requests are rejected
+	MaxPendingRequests int `yaml:"maxPendingRequests"`
+
+	Notifications NotificationConfig `yaml:"notifications"`
+}
+
+// NotificationConfig configures the webhook which is notified about new, approved and rejected requests
+type NotificationConfig struct {
+	// WebhookURL receives a POST request with a JSON notification for each event, notifications are disabled if empty
+	WebhookURL string        `yaml:"webhookUrl"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// SetDefaults for the approval config
+func (c *Config) SetDefaults() {
+	c.Operations = []string{OperationCreateTopic, OperationDeleteTopic, OperationAddPartitions}
+	c.RequestTTL = 72 * time.Hour
+	c.MaxPendingRequests = 1000
+	c.Notifications.Timeout = 5 * time.Second
+}
+
+// Validate the approval config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	for _, operation := range c.Operations {
+		switch operation {
+		case OperationCreateTopic, OperationDeleteTopic, OperationAddPartitions:
+		default:
+			return fmt.Errorf("operation '%v' is invalid, it must be one of: %v, %v, %v", operation,
+				OperationCreateTopic, OperationDeleteTopic, OperationAddPartitions)
+		}
+	}
+	if c.RequestTTL <= 0 {
+		return fmt.Errorf("request ttl must be greater than 0")
+	}
+	if c.MaxPendingRequests <= 0 {
+		return fmt.Errorf("max pending requests must be greater than 0")
+	}
+	if c.Notifications.WebhookURL != "" {
+		u, err := url.Parse(c.Notifications.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook url must be an absolute http or https url")
+		}
+		if c.Notifications.Timeout <= 0 {
+			return fmt.Errorf("webhook timeout must be greater than 0")
+		}
+	}
+
+	return nil
+}
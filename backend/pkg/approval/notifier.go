@@ -0,0 +1,63 @@
+package approval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Notification events
+const (
+	notificationRequested = "requested"
+	notificationApproved  = "approved"
+	notificationRejected  = "rejected"
+)
+
+// notification is the JSON body which is posted to the webhook
+type notification struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Request   Request   `json:"request"`
+}
+
+// webhookNotifier posts notifications to the configured webhook. Failed notifications are logged, but not retried.
+type webhookNotifier struct {
+	url        string
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+func newWebhookNotifier(cfg NotificationConfig, logger *zap.Logger) *webhookNotifier {
+	return &webhookNotifier{
+		url:        cfg.WebhookURL,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		logger:     logger,
+	}
+}
+
+func (n *webhookNotifier) notify(event string, req Request) {
+	if err := n.post(notification{Event: event, Timestamp: time.Now().UTC(), Request: req}); err != nil {
+		n.logger.Warn("failed to send approval notification",
+			zap.String("event", event), zap.String("request_id", req.ID), zap.Error(err))
+	}
+}
+
+func (n *webhookNotifier) post(body notification) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	res, err := n.httpClient.Post(n.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %v", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrRequestNotFound is returned if no pending request with the given id exists, e.g. because it has expired
+	ErrRequestNotFound = errors.New("approval request not found")
+	// ErrSelfApproval is returned if the requester tries to approve their own request
+	ErrSelfApproval = errors.New("requests must be approved by a different user")
+	// ErrDuplicateRequest is returned if the same operation is already pending for the topic
+	ErrDuplicateRequest = errors.New("the same operation is already pending for the topic")
+	// ErrTooManyRequests is returned if the maximum number of pending requests has been reached
+	ErrTooManyRequests = errors.New("too many pending requests")
+)
+
+// Statuses of a request
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Request is a topic operation which waits for the approval of a second user
+type Request struct {
+	ID          string `json:"id"`
+	ClusterName string `json:"clusterName"`
+	Operation   string `json:"operation"`
+	TopicName   string `json:"topicName"`
+
+	// Payload is the operation's request body, e.g. the settings of the topic which shall be created
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	RequestedBy string    `json:"requestedBy"`
+	RequestedAt time.Time `json:"requestedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+
+	Status    string     `json:"status"`
+	DecidedBy string     `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+}
+
+// ApplyFunc applies the operation of an approved request
+type ApplyFunc func(ctx context.Context, req *Request) error
+
+// Service keeps the pending requests in memory, hence they are lost on restart
+type Service struct {
+	cfg      Config
+	logger   *zap.Logger
+	notifier *webhookNotifier // nil if notifications are disabled
+
+	mutex   sync.Mutex
+	pending map[string]*Request // By id
+	now     func() time.Time
+}
+
+// NewService creates the approval service. The config is expected to be validated.
+func NewService(cfg Config, logger *zap.Logger) *Service {
+	var notifier *webhookNotifier
+	if cfg.Notifications.WebhookURL != "" {
+		notifier = newWebhookNotifier(cfg.Notifications, logger)
+	}
+
+	return &Service{
+		cfg:      cfg,
+		logger:   logger,
+		notifier: notifier,
+		pending:  make(map[string]*Request),
+		now:      time.Now,
+	}
+}
+
+// RequiresApproval returns true if the operation must be approved before it's applied
+func (s *Service) RequiresApproval(operation string) bool {
+	for _, o := range s.cfg.Operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Submit adds a pending request. ID, timestamps and status are set by the service.
+func (s *Service) Submit(req Request) (*Request, error) {
+	id, err := newRequestID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.removeExpired()
+	if len(s.pending) >= s.cfg.MaxPendingRequests {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("%w: only %v requests may be pending", ErrTooManyRequests, s.cfg.MaxPendingRequests)
+	}
+	for _, p := range s.pending {
+		if p.ClusterName == req.ClusterName && p.TopicName == req.TopicName && p.Operation == req.Operation {
+			s.mutex.Unlock()
+			return nil, fmt.Errorf("%w: request '%v' has been submitted by '%v'", ErrDuplicateRequest, p.ID, p.RequestedBy)
+		}
+	}
+	now := s.now().UTC().Truncate(time.Second)
+	req.ID = id
+	req.RequestedAt = now
+	req.ExpiresAt = now.Add(s.cfg.RequestTTL)
+	req.Status = StatusPending
+	req.DecidedBy, req.DecidedAt = "", nil
+	s.pending[id] = &req
+	s.mutex.Unlock()
+
+	s.notify(notificationRequested, req)
+	return &req, nil
+}
+
+// ListPending returns the pending requests of the cluster, the oldest first
+func (s *Service) ListPending(clusterName string) []Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeExpired()
+
+	requests := make([]Request, 0)
+	for _, req := range s.pending {
+		if req.ClusterName == clusterName {
+			requests = append(requests, *req)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if !requests[i].RequestedAt.Equal(requests[j].RequestedAt) {
+			return requests[i].RequestedAt.Before(requests[j].RequestedAt)
+		}
+		return requests[i].ID < requests[j].ID
+	})
+
+	return requests
+}
+
+// Get returns the pending request with the given id
+func (s *Service) Get(clusterName string, id string) (*Request, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeExpired()
+
+	req, exists := s.pending[id]
+	if !exists || req.ClusterName != clusterName {
+		return nil, fmt.Errorf("%w: no pending request with id '%v'", ErrRequestNotFound, id)
+	}
+	copied := *req
+	return &copied, nil
+}
+
+// Approve applies the pending request on behalf of the approver, who must not be the requester. The request is
+// removed while it's applied, so that it can't be approved twice. If it can't be applied, it stays pending.
+func (s *Service) Approve(ctx context.Context, clusterName string, id string, approver string, apply ApplyFunc) (*Request, error) {
+	s.mutex.Lock()
+	s.removeExpired()
+	req, exists := s.pending[id]
+	if !exists || req.ClusterName != clusterName {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("%w: no pending request with id '%v'", ErrRequestNotFound, id)
+	}
+	if req.RequestedBy == approver {
+		s.mutex.Unlock()
+		return nil, ErrSelfApproval
+	}
+	delete(s.pending, id)
+	s.mutex.Unlock()
+
+	if err := apply(ctx, req); err != nil {
+		s.mutex.Lock()
+		s.pending[id] = req
+		s.mutex.Unlock()
+		return nil, err
+	}
+
+	decided := *req
+	decidedAt := s.now().UTC().Truncate(time.Second)
+	decided.Status, decided.DecidedBy, decided.DecidedAt = StatusApproved, approver, &decidedAt
+	s.notify(notificationApproved, decided)
+	return &decided, nil
+}
+
+// Reject removes the pending request. Requesters may reject their own requests to withdraw them.
+func (s *Service) Reject(clusterName string, id string, rejecter string) (*Request, error) {
+	s.mutex.Lock()
+	s.removeExpired()
+	req, exists := s.pending[id]
+	if !exists || req.ClusterName != clusterName {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("%w: no pending request with id '%v'", ErrRequestNotFound, id)
+	}
+	delete(s.pending, id)
+	s.mutex.Unlock()
+
+	decided := *req
+	decidedAt := s.now().UTC().Truncate(time.Second)
+	decided.Status, decided.DecidedBy, decided.DecidedAt = StatusRejected, rejecter, &decidedAt
+	s.notify(notificationRejected, decided)
+	return &decided, nil
+}
+
+// removeExpired drops all requests which have expired. Callers must hold the mutex.
+func (s *Service) removeExpired() {
+	now := s.now()
+	for id, req := range s.pending {
+		if !now.Before(req.ExpiresAt) {
+			delete(s.pending, id)
+		}
+	}
+}
+
+// notify sends the notification in the background, so that requests never wait for the webhook
+func (s *Service) notify(event string, req Request) {
+	if s.notifier == nil {
+		return
+	}
+	go s.notifier.notify(event, req)
+}
+
+func newRequestID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate request id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,75 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestService() *Service {
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.MaxPendingRequests = 2
+	return NewService(cfg, zap.NewNop())
+}
+
+func TestApproveRequest(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+
+	req, err := svc.Submit(Request{ClusterName: "default", Operation: OperationDeleteTopic, TopicName: "orders", RequestedBy: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, req.Status)
+	assert.Len(t, req.ID, 16)
+
+	_, err = svc.Submit(Request{ClusterName: "default", Operation: OperationDeleteTopic, TopicName: "orders", RequestedBy: "bob"})
+	assert.True(t, errors.Is(err, ErrDuplicateRequest))
+
+	// Requests are scoped to their cluster
+	_, err = svc.Approve(ctx, "other", req.ID, "bob", nil)
+	assert.True(t, errors.Is(err, ErrRequestNotFound))
+
+	_, err = svc.Approve(ctx, "default", req.ID, "alice", nil)
+	assert.True(t, errors.Is(err, ErrSelfApproval))
+
+	// Requests which can't be applied stay pending
+	_, err = svc.Approve(ctx, "default", req.ID, "bob", func(context.Context, *Request) error { return errors.New("broker down") })
+	assert.Error(t, err)
+	assert.Len(t, svc.ListPending("default"), 1)
+
+	applied := 0
+	approved, err := svc.Approve(ctx, "default", req.ID, "bob", func(context.Context, *Request) error { applied++; return nil })
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.Equal(t, StatusApproved, approved.Status)
+	assert.Equal(t, "bob", approved.DecidedBy)
+	assert.Empty(t, svc.ListPending("default"))
+}
+
+func TestRejectAndExpireRequests(t *testing.T) {
+	svc := newTestService()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	first, err := svc.Submit(Request{ClusterName: "default", Operation: OperationCreateTopic, TopicName: "a", RequestedBy: "alice"})
+	require.NoError(t, err)
+	_, err = svc.Submit(Request{ClusterName: "default", Operation: OperationCreateTopic, TopicName: "b", RequestedBy: "alice"})
+	require.NoError(t, err)
+	_, err = svc.Submit(Request{ClusterName: "default", Operation: OperationCreateTopic, TopicName: "c", RequestedBy: "alice"})
+	assert.True(t, errors.Is(err, ErrTooManyRequests))
+
+	// Requesters may withdraw their own requests
+	rejected, err := svc.Reject("default", first.ID, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRejected, rejected.Status)
+	assert.Len(t, svc.ListPending("default"), 1)
+
+	now = now.Add(svc.cfg.RequestTTL)
+	assert.Empty(t, svc.ListPending("default"))
+}
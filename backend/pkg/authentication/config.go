@@ -0,0 +1,244 @@
+package authentication
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TypeNone disables authentication, all requests are anonymous
+	TypeNone = "none"
+
+	// TypeHTTP validates tokens with an OAuth 2.0 token introspection endpoint (RFC 7662)
+	TypeHTTP = "http"
+
+	// TypePlugin loads the provider from a Go plugin
+	TypePlugin = "plugin"
+
+	// TypeOIDC lets users log in with an OpenID Connect provider and authenticates them by a signed session cookie
+	TypeOIDC = "oidc"
+)
+
+// OIDC provider presets, they determine the issuer unless it's configured explicitly
+const (
+	OIDCProviderGoogle  = "google"
+	OIDCProviderAzure   = "azure"
+	OIDCProviderOkta    = "okta"
+	OIDCProviderGeneric = "generic"
+)
+
+// defaultSessionCookieName is used for OIDC sessions if no cookie name is configured
+const defaultSessionCookieName = "kowl_session"
+
+// Config for authenticating requests by bearer tokens
+type Config struct {
+	Type string `yaml:"type"`
+
+	// Required rejects requests without token. Otherwise they are served anonymously, which is only sensible if an
+	// authorizer restricts what anonymous users may do.
+	Required bool `yaml:"required"`
+
+	// CookieName is an optional cookie the token is read from if the request has no Authorization header, e.g.
+	// because it's a websocket request of the browser
+	CookieName string `yaml:"cookieName"`
+
+	// CacheTTL is the time an authenticated user is remembered for the same token. 0 disables the cache, so that
+	// every request is validated by the provider.
+	CacheTTL time.Duration `yaml:"cacheTtl"`
+
+	HTTP   HTTPConfig   `yaml:"http"`
+	Plugin PluginConfig `yaml:"plugin"`
+	OIDC   OIDCConfig   `yaml:"oidc"`
+}
+
+// HTTPConfig for validating tokens with a token introspection endpoint
+type HTTPConfig struct {
+	// URL of the introspection endpoint, the token is posted as form parameter 'token'
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+
+	// ClientID and ClientSecret are sent as basic auth if the endpoint requires client authentication
+	ClientID     string `yaml:"clientId"`
+	ClientSecret string `yaml:"clientSecret"`
+
+	// UsernameClaim, RolesClaim and AttributeClaims select the claims of the introspection response which the
+	// user's name, roles and attributes are taken from. The subject is used if there is no username.
+	UsernameClaim   string   `yaml:"usernameClaim"`
+	RolesClaim      string   `yaml:"rolesClaim"`
+	AttributeClaims []string `yaml:"attributeClaims"`
+}
+
+// OIDCConfig for the login with an OpenID Connect provider via the authorization code flow. After the login the
+// user's name, roles and attributes are kept in a session cookie, which is signed with the session secret.
+type OIDCConfig struct {
+	// Provider is one of google, azure, okta or generic. Google and Azure AD imply their issuer.
+	Provider string `yaml:"provider"`
+
+	// IssuerURL is required for okta (e.g. https://mycompany.okta.com/oauth2/default) and generic providers. The
+	// provider's endpoints are discovered via its '/.well-known/openid-configuration'.
+	IssuerURL string `yaml:"issuerUrl"`
+
+	// TenantID of the Azure AD tenant whose users may log in
+	TenantID string `yaml:"tenantId"`
+
+	ClientID     string `yaml:"clientId"`
+	ClientSecret string `yaml:"clientSecret"`
+
+	// RedirectURL is the absolute URL of Kowl's callback endpoint '/auth/callback', it must be registered with the
+	// provider
+	RedirectURL string `yaml:"redirectUrl"`
+
+	// PostLogoutRedirectURL is passed to the provider's end session endpoint, if it has one
+	PostLogoutRedirectURL string `yaml:"postLogoutRedirectUrl"`
+
+	Scopes  []string      `yaml:"scopes"`
+	Timeout time.Duration `yaml:"timeout"`
+
+	// UsernameClaim, RolesClaim and AttributeClaims select the claims of the ID token which the user's name, roles
+	// and attributes are taken from. The subject is used if there is no username. Azure AD reports app roles in the
+	// 'roles' claim.
+	UsernameClaim   string   `yaml:"usernameClaim"`
+	RolesClaim      string   `yaml:"rolesClaim"`
+	AttributeClaims []string `yaml:"attributeClaims"`
+
+	// AllowedDomains restricts the login to users whose email address belongs to one of the domains, e.g. the
+	// company's Google Workspace domain. All users of the provider may log in if it's empty.
+	AllowedDomains []string `yaml:"allowedDomains"`
+
+	// SessionSecret signs the session cookies, it must be at least 32 characters long. Changing it logs out all users.
+	SessionSecret string        `yaml:"sessionSecret"`
+	SessionTTL    time.Duration `yaml:"sessionTtl"`
+
+	// SecureCookies marks the session cookie as secure, so that browsers only send it via HTTPS
+	SecureCookies bool `yaml:"secureCookies"`
+}
+
+// PluginConfig for loading a provider from a Go plugin
+type PluginConfig struct {
+	// Path of the shared object, which must have been built with the same Go version and dependencies as Kowl
+	Path string `yaml:"path"`
+
+	// Options are passed to the plugin's constructor
+	Options map[string]string `yaml:"options"`
+}
+
+// RegisterFlags for sensitive authentication configs
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&c.HTTP.ClientSecret, "authentication.http.client-secret", "", "Client secret for authenticating against the token introspection endpoint")
+	f.StringVar(&c.OIDC.ClientSecret, "authentication.oidc.client-secret", "", "Client secret of Kowl at the OpenID Connect provider")
+	f.StringVar(&c.OIDC.SessionSecret, "authentication.oidc.session-secret", "", "Secret for signing OpenID Connect session cookies")
+}
+
+// SetDefaults for the authentication config
+func (c *Config) SetDefaults() {
+	c.Type = TypeNone
+	c.Required = true
+	c.CacheTTL = time.Minute
+	c.HTTP.Timeout = 5 * time.Second
+	c.HTTP.UsernameClaim = "username"
+	c.HTTP.RolesClaim = "roles"
+	c.OIDC.Provider = OIDCProviderGeneric
+	c.OIDC.Scopes = []string{"openid", "profile", "email"}
+	c.OIDC.Timeout = 5 * time.Second
+	c.OIDC.UsernameClaim = "email"
+	c.OIDC.RolesClaim = "groups"
+	c.OIDC.SessionTTL = 12 * time.Hour
+	c.OIDC.SecureCookies = true
+}
+
+// SessionCookieName returns the cookie which carries the token. OIDC sessions always use a cookie.
+func (c *Config) SessionCookieName() string {
+	if c.Type == TypeOIDC && c.CookieName == "" {
+		return defaultSessionCookieName
+	}
+	return c.CookieName
+}
+
+// Validate the authentication config
+func (c *Config) Validate() error {
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("cache ttl must not be negative")
+	}
+
+	switch c.Type {
+	case TypeNone:
+		return nil
+	case TypeHTTP:
+		if _, err := url.ParseRequestURI(c.HTTP.URL); err != nil {
+			return fmt.Errorf("failed to parse introspection url '%v': %w", c.HTTP.URL, err)
+		}
+		if c.HTTP.Timeout <= 0 {
+			return fmt.Errorf("http timeout must be greater than 0")
+		}
+		if c.HTTP.ClientSecret != "" && c.HTTP.ClientID == "" {
+			return fmt.Errorf("client id must be set if a client secret is given")
+		}
+		return nil
+	case TypePlugin:
+		if c.Plugin.Path == "" {
+			return fmt.Errorf("plugin path must be set")
+		}
+		return nil
+	case TypeOIDC:
+		return c.OIDC.validate()
+	default:
+		return fmt.Errorf("unknown authentication type '%v', must be one of '%v', '%v', '%v' or '%v'", c.Type, TypeNone, TypeHTTP, TypePlugin, TypeOIDC)
+	}
+}
+
+func (c *OIDCConfig) validate() error {
+	switch c.Provider {
+	case OIDCProviderGoogle:
+	case OIDCProviderAzure:
+		if c.TenantID == "" && c.IssuerURL == "" {
+			return fmt.Errorf("oidc tenant id must be set for azure")
+		}
+	case OIDCProviderOkta, OIDCProviderGeneric:
+		if c.IssuerURL == "" {
+			return fmt.Errorf("oidc issuer url must be set for %v providers", c.Provider)
+		}
+	default:
+		return fmt.Errorf("unknown oidc provider '%v', must be one of '%v', '%v', '%v' or '%v'",
+			c.Provider, OIDCProviderGoogle, OIDCProviderAzure, OIDCProviderOkta, OIDCProviderGeneric)
+	}
+	if c.IssuerURL != "" {
+		if _, err := url.ParseRequestURI(c.IssuerURL); err != nil {
+			return fmt.Errorf("failed to parse oidc issuer url '%v': %w", c.IssuerURL, err)
+		}
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("oidc client id must be set")
+	}
+	u, err := url.Parse(c.RedirectURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("oidc redirect url must be an absolute http or https url")
+	}
+	if len(c.SessionSecret) < 32 {
+		return fmt.Errorf("oidc session secret must be at least 32 characters long")
+	}
+	if c.SessionTTL < time.Minute {
+		return fmt.Errorf("oidc session ttl must be at least 1m")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("oidc timeout must be greater than 0")
+	}
+
+	return nil
+}
+
+// issuer returns the configured issuer or the one implied by the provider preset
+func (c *OIDCConfig) issuer() string {
+	if c.IssuerURL != "" {
+		return strings.TrimSuffix(c.IssuerURL, "/")
+	}
+	switch c.Provider {
+	case OIDCProviderGoogle:
+		return "https://accounts.google.com"
+	case OIDCProviderAzure:
+		return fmt.Sprintf("https://login.microsoftonline.com/%v/v2.0", c.TenantID)
+	}
+	return ""
+}
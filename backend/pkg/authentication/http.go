@@ -0,0 +1,117 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+)
+
+// HTTPProvider validates tokens with an OAuth 2.0 token introspection endpoint (RFC 7662). Homegrown SSO solutions
+// can implement the endpoint instead of a plugin: it receives the token as form parameter and responds with a JSON
+// object whose 'active' field tells whether the token is valid, along with claims about the user.
+type HTTPProvider struct {
+	cfg        HTTPConfig
+	httpClient *http.Client
+}
+
+// NewHTTPProvider creates a provider for the introspection endpoint configured in cfg
+func NewHTTPProvider(cfg HTTPConfig) *HTTPProvider {
+	return &HTTPProvider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// ValidateToken asks the introspection endpoint whether the token is active
+func (p *HTTPProvider) ValidateToken(ctx context.Context, token string) (Identity, error) {
+	form := url.Values{"token": []string{token}}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to create introspection request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if p.cfg.ClientID != "" {
+		httpReq.SetBasicAuth(p.cfg.ClientID, p.cfg.ClientSecret)
+	}
+
+	res, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to query introspection endpoint: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to read introspection response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("introspection endpoint responded with status code %v: %v", res.StatusCode, string(resBody))
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(resBody, &claims); err != nil {
+		return Identity{}, fmt.Errorf("failed to decode introspection response: %w", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return Identity{}, fmt.Errorf("%w: token is not active", ErrUnauthenticated)
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		subject, _ = claims[p.cfg.UsernameClaim].(string)
+	}
+	if subject == "" {
+		return Identity{}, fmt.Errorf("introspection response of an active token contains neither 'sub' nor '%v'", p.cfg.UsernameClaim)
+	}
+
+	return Identity{Subject: subject, Claims: claims}, nil
+}
+
+// ResolveUser takes the user's name, roles and attributes from the configured claims of the introspection response
+func (p *HTTPProvider) ResolveUser(_ context.Context, identity Identity) (authorization.Subject, error) {
+	return subjectFromClaims(identity.Subject, identity.Claims, p.cfg.UsernameClaim, p.cfg.RolesClaim, p.cfg.AttributeClaims), nil
+}
+
+// subjectFromClaims takes the user's name, roles and attributes from the given claims. The name falls back to the
+// user's id if the username claim is missing.
+func subjectFromClaims(id string, claims map[string]interface{}, usernameClaim string, rolesClaim string, attributeClaims []string) authorization.Subject {
+	subject := authorization.Subject{
+		Name:       id,
+		Roles:      claimValues(claims[rolesClaim]),
+		Attributes: make(map[string]string, len(attributeClaims)),
+	}
+	if username, _ := claims[usernameClaim].(string); username != "" {
+		subject.Name = username
+	}
+	for _, claim := range attributeClaims {
+		if value, exists := claims[claim]; exists {
+			subject.Attributes[claim] = strings.Join(claimValues(value), " ")
+		}
+	}
+
+	return subject
+}
+
+// claimValues returns the strings of a claim which is either a list or a space delimited string (like 'scope')
+func claimValues(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	case nil:
+		return []string{}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
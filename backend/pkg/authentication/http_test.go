@@ -0,0 +1,95 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "kowl", user)
+		assert.Equal(t, "secret", password)
+
+		switch r.PostFormValue("token") {
+		case "valid":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"active":     true,
+				"sub":        "u-123",
+				"username":   "jane",
+				"roles":      []string{"admin", "viewer"},
+				"department": "payments",
+			})
+		case "expired":
+			_, _ = w.Write([]byte(`{"active": false}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Type = TypeHTTP
+	cfg.HTTP.URL = server.URL
+	cfg.HTTP.ClientID = "kowl"
+	cfg.HTTP.ClientSecret = "secret"
+	cfg.HTTP.AttributeClaims = []string{"department", "missing"}
+	require.NoError(t, cfg.Validate())
+	authenticator, err := NewAuthenticator(cfg)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		subject, err := authenticator.Authenticate(ctx, "valid")
+		require.NoError(t, err)
+		assert.Equal(t, "jane", subject.Name)
+		assert.Equal(t, []string{"admin", "viewer"}, subject.Roles)
+		assert.Equal(t, map[string]string{"department": "payments"}, subject.Attributes)
+	}
+	assert.Equal(t, 1, requests, "users must be cached")
+
+	_, err = authenticator.Authenticate(ctx, "expired")
+	assert.True(t, errors.Is(err, ErrUnauthenticated))
+
+	// Failures of the endpoint don't mean that the token is invalid
+	_, err = authenticator.Authenticate(ctx, "broken")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrUnauthenticated))
+}
+
+func TestClaimValues(t *testing.T) {
+	assert.Equal(t, []string{"read", "write"}, claimValues("read write"))
+	assert.Equal(t, []string{"a", "1"}, claimValues([]interface{}{"a", 1}))
+	assert.Equal(t, []string{}, claimValues(nil))
+	assert.Equal(t, []string{"true"}, claimValues(true))
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	authenticator, err := NewAuthenticator(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, authenticator)
+
+	cfg.Type = TypePlugin
+	cfg.Plugin.Path = "/does/not/exist.so"
+	require.NoError(t, cfg.Validate())
+	_, err = NewAuthenticator(cfg)
+	assert.Error(t, err)
+
+	cfg.Type = TypeHTTP
+	cfg.HTTP.Timeout = time.Second
+	assert.Error(t, cfg.Validate(), "url is required")
+}
@@ -0,0 +1,277 @@
+package authentication
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Register the hash functions of the supported signature algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errUnknownKey is returned if a JWT has been signed with a key which is not part of the key set
+var errUnknownKey = errors.New("unknown signing key")
+
+// jwtHeader is the JOSE header of a signed JWT
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// jsonWebKey is a public key of a JWK set. Only RSA and EC keys are supported, which is what OIDC providers use to
+// sign their ID tokens.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// publicKey decodes the key. Keys of unsupported types return nil without error, so that they can be skipped.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("exponent is too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%v'", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode y coordinate: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on curve '%v'", k.Curve)
+		}
+		return key, nil
+	}
+	return nil, nil
+}
+
+// keySet holds the public keys of an identity provider by their key id. Keys are fetched again if a token has been
+// signed by an unknown key, as providers rotate their keys regularly.
+type keySet struct {
+	fetch func(ctx context.Context) ([]byte, error)
+
+	// minRefreshInterval prevents tokens with made up key ids from hammering the provider's JWKS endpoint
+	minRefreshInterval time.Duration
+
+	mutex       sync.Mutex
+	keys        map[string]crypto.PublicKey
+	refreshedAt time.Time
+}
+
+func newKeySet(fetch func(ctx context.Context) ([]byte, error)) *keySet {
+	return &keySet{fetch: fetch, minRefreshInterval: time.Minute}
+}
+
+// key returns the public key with the given id. An empty id matches the only key of the set.
+func (s *keySet) key(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if key, ok := s.lookup(keyID); ok {
+		return key, nil
+	}
+	if s.keys != nil && time.Since(s.refreshedAt) < s.minRefreshInterval {
+		return nil, fmt.Errorf("%w '%v'", errUnknownKey, keyID)
+	}
+
+	body, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseKeySet(body)
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+	s.refreshedAt = time.Now()
+
+	if key, ok := s.lookup(keyID); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w '%v'", errUnknownKey, keyID)
+}
+
+// lookup finds a key among the known keys. Callers must hold the mutex.
+func (s *keySet) lookup(keyID string) (crypto.PublicKey, bool) {
+	if keyID == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[keyID]
+	return key, ok
+}
+
+// parseKeySet decodes a JWK set and skips keys which are not meant for signatures or have an unsupported type
+func parseKeySet(body []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key '%v': %w", jwk.KeyID, err)
+		}
+		if key != nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// verifyJWT checks the signature of a compact serialized JWT and returns its claims. Only asymmetric algorithms are
+// accepted, 'none' and HMAC would let anyone who knows the client secret (or nobody at all) forge tokens. Claims
+// such as the expiry are not validated.
+func verifyJWT(ctx context.Context, token string, keys *keySet) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a signed jwt")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode jwt header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("failed to decode jwt header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode jwt signature: %w", err)
+	}
+
+	key, err := keys.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode jwt payload: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var claims map[string]interface{}
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode jwt payload: %w", err)
+	}
+
+	return claims, nil
+}
+
+// verifySignature verifies the signature of the signing input with the given JWS algorithm
+func verifySignature(algorithm string, key crypto.PublicKey, signingInput []byte, signature []byte) error {
+	if len(algorithm) != len("RS256") {
+		return fmt.Errorf("unsupported jwt algorithm '%v'", algorithm)
+	}
+	var hash crypto.Hash
+	switch algorithm[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported jwt algorithm '%v'", algorithm)
+	}
+	h := hash.New()
+	h.Write(signingInput)
+	digest := h.Sum(nil)
+
+	invalidSignature := fmt.Errorf("invalid jwt signature")
+	switch algorithm[:2] {
+	case "RS", "PS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("jwt algorithm '%v' doesn't match the key type", algorithm)
+		}
+		if algorithm[:2] == "RS" {
+			if rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature) != nil {
+				return invalidSignature
+			}
+			return nil
+		}
+		if rsa.VerifyPSS(rsaKey, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) != nil {
+			return invalidSignature
+		}
+		return nil
+	case "ES":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("jwt algorithm '%v' doesn't match the key type", algorithm)
+		}
+		// The signature is the concatenation of r and s, each padded to the size of the curve
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return invalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return invalidSignature
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported jwt algorithm '%v'", algorithm)
+}
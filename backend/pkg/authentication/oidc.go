@@ -0,0 +1,442 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLoginFailed is returned if the user could not be logged in, e.g. because the login has been denied by the
+// provider, the login state doesn't match or the user's domain is not allowed
+var ErrLoginFailed = errors.New("login failed")
+
+const (
+	// loginStateTTL is the time users have to log in at the provider
+	loginStateTTL = 10 * time.Minute
+
+	// clockSkew is tolerated when checking the expiry of ID tokens
+	clockSkew = time.Minute
+
+	// loginCookiePath restricts the login state cookie to the login endpoints
+	loginCookiePath = "/auth"
+)
+
+// oidcDiscovery is the part of the provider's OpenID configuration which is needed for the login
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// loginState is remembered in a signed cookie between redirecting the user to the provider and the callback. It
+// binds the callback to the browser which started the login.
+type loginState struct {
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"` // PKCE code verifier
+	Redirect  string    `json:"redirect"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// OIDCLogin logs users in with an OpenID Connect provider via the authorization code flow with PKCE. Once the ID
+// token has been verified the user is resolved from its claims and stored in a signed session cookie, which is
+// authenticated by the SessionProvider on subsequent requests.
+type OIDCLogin struct {
+	cfg        OIDCConfig
+	issuer     string
+	cookieName string
+	sessions   *SessionProvider
+	httpClient *http.Client
+	keys       *keySet
+
+	// The provider's configuration is discovered on the first login, so that Kowl starts if the provider is down
+	mutex     sync.Mutex
+	discovery *oidcDiscovery
+}
+
+// NewOIDCLogin creates the login for the configured provider. It returns nil if the authentication type is not OIDC.
+func NewOIDCLogin(cfg Config) *OIDCLogin {
+	if cfg.Type != TypeOIDC {
+		return nil
+	}
+
+	l := &OIDCLogin{
+		cfg:        cfg.OIDC,
+		issuer:     cfg.OIDC.issuer(),
+		cookieName: cfg.SessionCookieName(),
+		sessions:   NewSessionProvider(cfg.OIDC.SessionSecret),
+		httpClient: &http.Client{Timeout: cfg.OIDC.Timeout},
+	}
+	l.keys = newKeySet(l.fetchKeySet)
+	return l
+}
+
+// StartLogin returns the provider's authorization URL which the user must be redirected to, along with the cookie
+// that carries the login state. Redirect is the path the user returns to after the login, it must be relative.
+func (l *OIDCLogin) StartLogin(ctx context.Context, redirect string) (string, *http.Cookie, error) {
+	discovery, err := l.discover(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if !isRelativeRedirect(redirect) {
+		redirect = "/"
+	}
+
+	state := loginState{
+		State:     randomToken(),
+		Nonce:     randomToken(),
+		Verifier:  randomToken(),
+		Redirect:  redirect,
+		ExpiresAt: l.sessions.now().Add(loginStateTTL),
+	}
+	stateToken, err := l.sessions.signer.sign(purposeLoginState, state)
+	if err != nil {
+		return "", nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         []string{"code"},
+		"client_id":             []string{l.cfg.ClientID},
+		"redirect_uri":          []string{l.cfg.RedirectURL},
+		"scope":                 []string{strings.Join(l.cfg.Scopes, " ")},
+		"state":                 []string{state.State},
+		"nonce":                 []string{state.Nonce},
+		"code_challenge":        []string{base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": []string{"S256"},
+	}
+	authURL, err := appendQuery(discovery.AuthorizationEndpoint, query)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse authorization endpoint: %w", err)
+	}
+
+	return authURL, l.cookie(l.loginCookieName(), stateToken, loginCookiePath, loginStateTTL), nil
+}
+
+// FinishLogin handles the provider's redirect to the callback. It exchanges the authorization code for an ID token,
+// resolves the user and returns the session cookie along with the path the user should be redirected to. Rejected
+// logins are reported with ErrLoginFailed, all other errors mean that the provider is unavailable.
+func (l *OIDCLogin) FinishLogin(r *http.Request) (*http.Cookie, string, error) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		return nil, "", fmt.Errorf("%w: provider responded with '%v': %v", ErrLoginFailed, providerErr, query.Get("error_description"))
+	}
+
+	stateCookie, err := r.Cookie(l.loginCookieName())
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: login state cookie is missing", ErrLoginFailed)
+	}
+	var state loginState
+	if err := l.sessions.signer.verify(purposeLoginState, stateCookie.Value, &state); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrLoginFailed, err)
+	}
+	if !l.sessions.now().Before(state.ExpiresAt) {
+		return nil, "", fmt.Errorf("%w: login has expired", ErrLoginFailed)
+	}
+	if query.Get("state") != state.State {
+		return nil, "", fmt.Errorf("%w: state doesn't match", ErrLoginFailed)
+	}
+	code := query.Get("code")
+	if code == "" {
+		return nil, "", fmt.Errorf("%w: callback carries no authorization code", ErrLoginFailed)
+	}
+
+	discovery, err := l.discover(r.Context())
+	if err != nil {
+		return nil, "", err
+	}
+	idToken, err := l.exchangeCode(r.Context(), discovery, code, state.Verifier)
+	if err != nil {
+		return nil, "", err
+	}
+	claims, err := verifyJWT(r.Context(), idToken, l.keys)
+	if err != nil {
+		if errors.Is(err, errUnknownKey) {
+			return nil, "", fmt.Errorf("%w: %v", ErrLoginFailed, err)
+		}
+		return nil, "", fmt.Errorf("failed to verify id token: %w", err)
+	}
+	if err := l.validateClaims(claims, discovery.Issuer, state.Nonce); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrLoginFailed, err)
+	}
+
+	sub, _ := claims["sub"].(string)
+	subject := subjectFromClaims(sub, claims, l.cfg.UsernameClaim, l.cfg.RolesClaim, l.cfg.AttributeClaims)
+	sessionToken, _, err := l.sessions.issue(subject, l.cfg.SessionTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return l.cookie(l.cookieName, sessionToken, "/", l.cfg.SessionTTL), state.Redirect, nil
+}
+
+// Logout returns the cookie which removes the session from the browser, along with the provider's logout URL if it
+// supports RP-initiated logout. The URL is empty otherwise.
+func (l *OIDCLogin) Logout(ctx context.Context) (*http.Cookie, string) {
+	cookie := l.cookie(l.cookieName, "", "/", -1)
+
+	discovery, err := l.discover(ctx)
+	if err != nil || discovery.EndSessionEndpoint == "" {
+		return cookie, l.cfg.PostLogoutRedirectURL
+	}
+	query := url.Values{"client_id": []string{l.cfg.ClientID}}
+	if l.cfg.PostLogoutRedirectURL != "" {
+		query.Set("post_logout_redirect_uri", l.cfg.PostLogoutRedirectURL)
+	}
+	logoutURL, err := appendQuery(discovery.EndSessionEndpoint, query)
+	if err != nil {
+		return cookie, l.cfg.PostLogoutRedirectURL
+	}
+
+	return cookie, logoutURL
+}
+
+// ClearLoginState returns the cookie which removes the login state from the browser once the callback was handled
+func (l *OIDCLogin) ClearLoginState() *http.Cookie {
+	return l.cookie(l.loginCookieName(), "", loginCookiePath, -1)
+}
+
+// Session returns the session of the request's session cookie. Requests without valid session are reported with
+// ErrUnauthenticated.
+func (l *OIDCLogin) Session(r *http.Request) (Session, error) {
+	cookie, err := r.Cookie(l.cookieName)
+	if err != nil {
+		return Session{}, fmt.Errorf("%w: request carries no session cookie", ErrUnauthenticated)
+	}
+	return l.sessions.Session(cookie.Value)
+}
+
+// validateClaims checks that the ID token has been issued by the provider for Kowl during this login, and that the
+// user belongs to one of the allowed domains
+func (l *OIDCLogin) validateClaims(claims map[string]interface{}, issuer string, nonce string) error {
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return fmt.Errorf("id token has been issued by '%v' instead of '%v'", iss, issuer)
+	}
+	audiences := claimValues(claims["aud"])
+	if !containsString(audiences, l.cfg.ClientID) {
+		return fmt.Errorf("id token has not been issued for client '%v'", l.cfg.ClientID)
+	}
+	if azp, exists := claims["azp"].(string); exists && azp != l.cfg.ClientID {
+		return fmt.Errorf("id token has been issued to authorized party '%v'", azp)
+	}
+	exp, ok := claims["exp"].(json.Number)
+	if !ok {
+		return fmt.Errorf("id token has no expiry")
+	}
+	expiresAt, err := exp.Int64()
+	if err != nil {
+		return fmt.Errorf("id token has an invalid expiry: %w", err)
+	}
+	if l.sessions.now().Add(-clockSkew).After(time.Unix(expiresAt, 0)) {
+		return fmt.Errorf("id token has expired")
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return fmt.Errorf("id token nonce doesn't match")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return fmt.Errorf("id token has no subject")
+	}
+
+	if len(l.cfg.AllowedDomains) == 0 {
+		return nil
+	}
+	email, _ := claims["email"].(string)
+	if verified, exists := claims["email_verified"].(bool); exists && !verified {
+		return fmt.Errorf("email address '%v' has not been verified", email)
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return fmt.Errorf("id token has no email address to check the allowed domains")
+	}
+	for _, domain := range l.cfg.AllowedDomains {
+		if strings.EqualFold(email[at+1:], domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain of '%v' is not allowed", email)
+}
+
+// exchangeCode redeems the authorization code at the token endpoint and returns the ID token
+func (l *OIDCLogin) exchangeCode(ctx context.Context, discovery *oidcDiscovery, code string, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    []string{"authorization_code"},
+		"code":          []string{code},
+		"redirect_uri":  []string{l.cfg.RedirectURL},
+		"code_verifier": []string{verifier},
+	}
+	if l.cfg.ClientSecret == "" {
+		form.Set("client_id", l.cfg.ClientID)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if l.cfg.ClientSecret != "" {
+		httpReq.SetBasicAuth(url.QueryEscape(l.cfg.ClientID), url.QueryEscape(l.cfg.ClientSecret))
+	}
+
+	res, err := l.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to query token endpoint: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	// Invalid or already redeemed codes are rejected with status 400 and an OAuth error
+	if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized {
+		return "", fmt.Errorf("%w: token endpoint responded with status code %v: %v", ErrLoginFailed, res.StatusCode, string(resBody))
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint responded with status code %v: %v", res.StatusCode, string(resBody))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(resBody, &tokens); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("token response contains no id token, the 'openid' scope may be missing")
+	}
+
+	return tokens.IDToken, nil
+}
+
+// discover fetches the provider's OpenID configuration. Failures are not cached, so that the next login retries.
+func (l *OIDCLogin) discover(ctx context.Context) (*oidcDiscovery, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.discovery != nil {
+		return l.discovery, nil
+	}
+
+	body, err := l.get(ctx, l.issuer+"/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover openid configuration: %w", err)
+	}
+	var discovery oidcDiscovery
+	if err := json.Unmarshal(body, &discovery); err != nil {
+		return nil, fmt.Errorf("failed to decode openid configuration: %w", err)
+	}
+	if discovery.Issuer != l.issuer {
+		return nil, fmt.Errorf("openid configuration is for issuer '%v' instead of '%v'", discovery.Issuer, l.issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("openid configuration lacks the authorization, token or jwks endpoint")
+	}
+	l.discovery = &discovery
+
+	return l.discovery, nil
+}
+
+func (l *OIDCLogin) fetchKeySet(ctx context.Context) ([]byte, error) {
+	discovery, err := l.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := l.get(ctx, discovery.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key set: %w", err)
+	}
+	return body, nil
+}
+
+func (l *OIDCLogin) get(ctx context.Context, url string) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+
+	res, err := l.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v responded with status code %v", url, res.StatusCode)
+	}
+	return body, nil
+}
+
+func (l *OIDCLogin) loginCookieName() string {
+	return l.cookieName + "_login"
+}
+
+// cookie creates an HttpOnly cookie, a negative ttl removes it
+func (l *OIDCLogin) cookie(name string, value string, path string, ttl time.Duration) *http.Cookie {
+	maxAge := int(ttl / time.Second)
+	if ttl < 0 {
+		maxAge = -1
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   l.cfg.SecureCookies,
+		// Lax is required, as the callback is a top level navigation coming from the provider
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// isRelativeRedirect reports whether the redirect stays on Kowl, so that the login can't be abused to forward users
+// to arbitrary sites
+func isRelativeRedirect(redirect string) bool {
+	return strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//") && !strings.HasPrefix(redirect, "/\\")
+}
+
+func appendQuery(endpoint string, query url.Values) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for key, values := range query {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// randomToken returns 32 random bytes encoded as base64url
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
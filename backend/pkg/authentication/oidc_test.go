@@ -0,0 +1,337 @@
+package authentication
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signTestJWT(t *testing.T, key crypto.Signer, alg string, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		copy(signature[32-len(r.Bytes()):32], r.Bytes())
+		copy(signature[64-len(s.Bytes()):], s.Bytes())
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	fetches := 0
+	keys := newKeySet(func(ctx context.Context) ([]byte, error) {
+		fetches++
+		return json.Marshal(map[string]interface{}{"keys": []map[string]string{
+			rsaJWK("rsa", &rsaKey.PublicKey),
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+				"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+			},
+			{"kty": "oct", "kid": "symmetric", "k": "c2VjcmV0"},
+		}})
+	})
+	ctx := context.Background()
+	claims := map[string]interface{}{"sub": "jane"}
+
+	verified, err := verifyJWT(ctx, signTestJWT(t, rsaKey, "RS256", "rsa", claims), keys)
+	require.NoError(t, err)
+	assert.Equal(t, "jane", verified["sub"])
+
+	verified, err = verifyJWT(ctx, signTestJWT(t, ecKey, "ES256", "ec", claims), keys)
+	require.NoError(t, err)
+	assert.Equal(t, "jane", verified["sub"])
+
+	// Algorithms must match the key and symmetric algorithms are never accepted
+	_, err = verifyJWT(ctx, signTestJWT(t, rsaKey, "ES256", "rsa", claims), keys)
+	assert.Error(t, err)
+	_, err = verifyJWT(ctx, signTestJWT(t, rsaKey, "HS256", "rsa", claims), keys)
+	assert.Error(t, err)
+	_, err = verifyJWT(ctx, signTestJWT(t, rsaKey, "none", "rsa", claims), keys)
+	assert.Error(t, err)
+
+	// Tampered payloads
+	token := strings.Split(signTestJWT(t, rsaKey, "RS256", "rsa", claims), ".")
+	forged := strings.Split(signTestJWT(t, rsaKey, "RS256", "rsa", map[string]interface{}{"sub": "admin"}), ".")
+	_, err = verifyJWT(ctx, token[0]+"."+forged[1]+"."+token[2], keys)
+	assert.Error(t, err)
+
+	// Unknown keys are looked up again, but not more often than the refresh interval allows
+	_, err = verifyJWT(ctx, signTestJWT(t, rsaKey, "RS256", "rotated", claims), keys)
+	assert.True(t, errors.Is(err, errUnknownKey))
+	assert.Equal(t, 1, fetches)
+	keys.minRefreshInterval = 0
+	_, err = verifyJWT(ctx, signTestJWT(t, rsaKey, "RS256", "rotated", claims), keys)
+	assert.True(t, errors.Is(err, errUnknownKey))
+	assert.Equal(t, 2, fetches)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var server *httptest.Server
+	var nonce, challenge string
+	idTokenClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    server.URL,
+			"aud":    "kowl",
+			"sub":    "u-123",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"nonce":  nonce,
+			"email":  "jane@example.com",
+			"groups": []string{"admins", "devs"},
+		}
+	}
+	claims := idTokenClaims
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+			"end_session_endpoint":   server.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJWK("k1", &key.PublicKey)}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "kowl", user)
+		assert.Equal(t, "secret", password)
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != "valid-code" || base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signTestJWT(t, key, "RS256", "k1", claims())})
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Type = TypeOIDC
+	cfg.OIDC.IssuerURL = server.URL
+	cfg.OIDC.ClientID = "kowl"
+	cfg.OIDC.ClientSecret = "secret"
+	cfg.OIDC.RedirectURL = "https://kowl.example.com/auth/callback"
+	cfg.OIDC.SessionSecret = "0123456789abcdef0123456789abcdef"
+	cfg.OIDC.AllowedDomains = []string{"EXAMPLE.com"}
+	require.NoError(t, cfg.Validate())
+	oidcLogin := NewOIDCLogin(cfg)
+	authenticator, err := NewAuthenticator(cfg)
+	require.NoError(t, err)
+
+	// login runs the flow up to the callback and returns the callback's result
+	login := func(code string, redirect string) (*http.Cookie, string, error) {
+		authURL, stateCookie, err := oidcLogin.StartLogin(context.Background(), redirect)
+		require.NoError(t, err)
+		assert.Equal(t, "/auth", stateCookie.Path)
+		assert.True(t, stateCookie.HttpOnly)
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+		assert.Equal(t, server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+		assert.Equal(t, "kowl", u.Query().Get("client_id"))
+		assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+		nonce = u.Query().Get("nonce")
+		challenge = u.Query().Get("code_challenge")
+
+		callback := httptest.NewRequest(http.MethodGet, "/auth/callback?"+url.Values{
+			"code":  []string{code},
+			"state": []string{u.Query().Get("state")},
+		}.Encode(), nil)
+		callback.AddCookie(stateCookie)
+		return oidcLogin.FinishLogin(callback)
+	}
+
+	sessionCookie, redirect, err := login("valid-code", "/topics/orders")
+	require.NoError(t, err)
+	assert.Equal(t, "/topics/orders", redirect)
+	assert.Equal(t, "kowl_session", sessionCookie.Name)
+	assert.True(t, sessionCookie.HttpOnly)
+	assert.True(t, sessionCookie.Secure)
+	assert.Equal(t, int((12 * time.Hour).Seconds()), sessionCookie.MaxAge)
+
+	subject, err := authenticator.Authenticate(context.Background(), sessionCookie.Value)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", subject.Name)
+	assert.Equal(t, []string{"admins", "devs"}, subject.Roles)
+
+	request := httptest.NewRequest(http.MethodGet, "/auth/userinfo", nil)
+	request.AddCookie(sessionCookie)
+	session, err := oidcLogin.Session(request)
+	require.NoError(t, err)
+	assert.Equal(t, subject, session.Subject)
+
+	// Tampered sessions are rejected
+	_, err = authenticator.Authenticate(context.Background(), "x"+sessionCookie.Value)
+	assert.True(t, errors.Is(err, ErrUnauthenticated))
+
+	// Redirects to other sites fall back to the root
+	_, redirect, err = login("valid-code", "//evil.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "/", redirect)
+
+	// Rejected logins
+	_, _, err = login("invalid-code", "/")
+	assert.True(t, errors.Is(err, ErrLoginFailed))
+
+	claims = func() map[string]interface{} {
+		c := idTokenClaims()
+		c["email"] = "jane@other.com"
+		return c
+	}
+	_, _, err = login("valid-code", "/")
+	assert.True(t, errors.Is(err, ErrLoginFailed), "domain must be allowed")
+
+	claims = func() map[string]interface{} {
+		c := idTokenClaims()
+		c["nonce"] = "replayed"
+		return c
+	}
+	_, _, err = login("valid-code", "/")
+	assert.True(t, errors.Is(err, ErrLoginFailed), "nonce must match")
+
+	claims = func() map[string]interface{} {
+		c := idTokenClaims()
+		c["aud"] = []string{"other-client"}
+		return c
+	}
+	_, _, err = login("valid-code", "/")
+	assert.True(t, errors.Is(err, ErrLoginFailed), "audience must match")
+
+	// Callbacks without the state cookie of the login are rejected
+	_, stateCookie, err := oidcLogin.StartLogin(context.Background(), "/")
+	require.NoError(t, err)
+	callback := httptest.NewRequest(http.MethodGet, "/auth/callback?code=valid-code&state=guessed", nil)
+	callback.AddCookie(stateCookie)
+	_, _, err = oidcLogin.FinishLogin(callback)
+	assert.True(t, errors.Is(err, ErrLoginFailed))
+
+	logoutCookie, logoutURL := oidcLogin.Logout(context.Background())
+	assert.Equal(t, -1, logoutCookie.MaxAge)
+	assert.Equal(t, server.URL+"/logout?client_id=kowl", logoutURL)
+}
+
+func TestSessionProvider(t *testing.T) {
+	provider := NewSessionProvider("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	token, _, err := provider.issue(authorization.Subject{Name: "jane", Roles: []string{"admins"}}, time.Hour)
+	require.NoError(t, err)
+	session, err := provider.Session(token)
+	require.NoError(t, err)
+	assert.Equal(t, "jane", session.Subject.Name)
+
+	// Login states can't be passed off as sessions
+	state, err := provider.signer.sign(purposeLoginState, session)
+	require.NoError(t, err)
+	_, err = provider.Session(state)
+	assert.True(t, errors.Is(err, ErrUnauthenticated))
+
+	// Sessions signed with another secret are rejected
+	_, err = NewSessionProvider("fedcba9876543210fedcba9876543210").Session(token)
+	assert.True(t, errors.Is(err, ErrUnauthenticated))
+
+	now = now.Add(2 * time.Hour)
+	_, err = provider.Session(token)
+	assert.True(t, errors.Is(err, ErrUnauthenticated))
+}
+
+func TestOIDCConfig_Validate(t *testing.T) {
+	valid := func() Config {
+		cfg := Config{}
+		cfg.SetDefaults()
+		cfg.Type = TypeOIDC
+		cfg.OIDC.Provider = OIDCProviderGoogle
+		cfg.OIDC.ClientID = "kowl"
+		cfg.OIDC.RedirectURL = "https://kowl.example.com/auth/callback"
+		cfg.OIDC.SessionSecret = "0123456789abcdef0123456789abcdef"
+		return cfg
+	}
+
+	tt := []struct {
+		modify  func(cfg *Config)
+		isValid bool
+		issuer  string
+	}{
+		{func(cfg *Config) {}, true, "https://accounts.google.com"},
+		{func(cfg *Config) { cfg.OIDC.Provider = OIDCProviderAzure; cfg.OIDC.TenantID = "t-1" }, true, "https://login.microsoftonline.com/t-1/v2.0"},
+		{func(cfg *Config) { cfg.OIDC.Provider = OIDCProviderAzure }, false, ""},
+		{func(cfg *Config) { cfg.OIDC.Provider = OIDCProviderOkta }, false, ""},
+		{func(cfg *Config) {
+			cfg.OIDC.Provider = OIDCProviderOkta
+			cfg.OIDC.IssuerURL = "https://example.okta.com/oauth2/default/"
+		}, true, "https://example.okta.com/oauth2/default"},
+		{func(cfg *Config) { cfg.OIDC.Provider = "github" }, false, ""},
+		{func(cfg *Config) { cfg.OIDC.ClientID = "" }, false, ""},
+		{func(cfg *Config) { cfg.OIDC.RedirectURL = "/auth/callback" }, false, ""},
+		{func(cfg *Config) { cfg.OIDC.SessionSecret = "short" }, false, ""},
+	}
+
+	for i, test := range tt {
+		cfg := valid()
+		test.modify(&cfg)
+		err := cfg.Validate()
+		if !test.isValid {
+			assert.Error(t, err, "Case: ", i)
+			continue
+		}
+		assert.NoError(t, err, "Case: ", i)
+		assert.Equal(t, test.issuer, cfg.OIDC.issuer(), "Case: ", i)
+	}
+}
@@ -0,0 +1,38 @@
+package authentication
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// PluginConstructor is the name of the function a plugin must export to create its provider. Its signature must be
+// NewProvider(options map[string]string) (authentication.Provider, error).
+const PluginConstructor = "NewProvider"
+
+// LoadPlugin opens the Go plugin at path and creates its provider with the given options. Plugins must be built with
+// the same Go version and dependency versions as Kowl and are only supported on Linux and macOS.
+func LoadPlugin(path string, options map[string]string) (Provider, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin '%v': %w", path, err)
+	}
+	symbol, err := p.Lookup(PluginConstructor)
+	if err != nil {
+		return nil, fmt.Errorf("plugin '%v' doesn't export '%v': %w", path, PluginConstructor, err)
+	}
+	constructor, ok := symbol.(func(map[string]string) (Provider, error))
+	if !ok {
+		return nil, fmt.Errorf("plugin '%v' exports '%v' with signature %T, expected func(map[string]string) (authentication.Provider, error)",
+			path, PluginConstructor, symbol)
+	}
+
+	provider, err := constructor(options)
+	if err != nil {
+		return nil, fmt.Errorf("plugin '%v' failed to create provider: %w", path, err)
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("plugin '%v' returned no provider", path)
+	}
+
+	return provider, nil
+}
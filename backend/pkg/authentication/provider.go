@@ -0,0 +1,39 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+)
+
+// ErrUnauthenticated is returned by providers if a token is invalid, e.g. because it has expired or been revoked
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Identity is the owner of a valid token as it has been asserted by the token validator
+type Identity struct {
+	// Subject is a stable and unique id of the user, e.g. the 'sub' claim of a JWT
+	Subject string `json:"subject"`
+
+	// Claims are all further information the validator knows about the user, they are passed to the resolver
+	Claims map[string]interface{} `json:"claims"`
+}
+
+// TokenValidator verifies bearer tokens. Invalid tokens must be reported with ErrUnauthenticated, all other errors
+// mean that the token could not be verified.
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (Identity, error)
+}
+
+// UserInfoResolver returns the name, roles and attributes of an authenticated user, which are used to authorize
+// the user's requests
+type UserInfoResolver interface {
+	ResolveUser(ctx context.Context, identity Identity) (authorization.Subject, error)
+}
+
+// Provider authenticates the requests of a single identity provider. Companies with a homegrown SSO implement it in
+// a Go plugin or expose their token validation via HTTP.
+type Provider interface {
+	TokenValidator
+	UserInfoResolver
+}
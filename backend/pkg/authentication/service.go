@@ -0,0 +1,99 @@
+package authentication
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+)
+
+// Authenticator authenticates bearer tokens with the configured provider. Users are remembered for the cache ttl,
+// so that the provider isn't asked on every request.
+type Authenticator struct {
+	provider Provider
+	ttl      time.Duration
+
+	mutex sync.Mutex
+	users map[string]cachedUser
+}
+
+type cachedUser struct {
+	subject   authorization.Subject
+	expiresAt time.Time
+}
+
+// NewAuthenticator creates the configured provider. It returns nil if authentication is disabled.
+func NewAuthenticator(cfg Config) (*Authenticator, error) {
+	var provider Provider
+	switch cfg.Type {
+	case TypeHTTP:
+		provider = NewHTTPProvider(cfg.HTTP)
+	case TypePlugin:
+		var err error
+		provider, err = LoadPlugin(cfg.Plugin.Path, cfg.Plugin.Options)
+		if err != nil {
+			return nil, err
+		}
+	case TypeOIDC:
+		provider = NewSessionProvider(cfg.OIDC.SessionSecret)
+	default:
+		return nil, nil
+	}
+
+	return NewProviderAuthenticator(provider, cfg.CacheTTL), nil
+}
+
+// NewProviderAuthenticator creates an authenticator for the given provider, a ttl of 0 disables the cache
+func NewProviderAuthenticator(provider Provider, ttl time.Duration) *Authenticator {
+	return &Authenticator{
+		provider: provider,
+		ttl:      ttl,
+		users:    make(map[string]cachedUser),
+	}
+}
+
+// Authenticate validates the token and resolves the user it belongs to. Invalid tokens are reported with
+// ErrUnauthenticated, errors are not cached.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (authorization.Subject, error) {
+	// Users are cached by the hash of their token, so that the cache doesn't hold any tokens
+	hash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(hash[:])
+
+	now := time.Now()
+	a.mutex.Lock()
+	user, ok := a.users[key]
+	a.mutex.Unlock()
+	if ok && now.Before(user.expiresAt) {
+		return user.subject, nil
+	}
+
+	identity, err := a.provider.ValidateToken(ctx, token)
+	if err != nil {
+		return authorization.Subject{}, err
+	}
+	subject, err := a.provider.ResolveUser(ctx, identity)
+	if err != nil {
+		return authorization.Subject{}, fmt.Errorf("failed to resolve user '%v': %w", identity.Subject, err)
+	}
+	if a.ttl == 0 {
+		return subject, nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.users[key] = cachedUser{subject: subject, expiresAt: now.Add(a.ttl)}
+	// Evict expired users once in a while, so that the cache doesn't grow with every token ever seen
+	if len(a.users)%1000 == 0 {
+		for k, u := range a.users {
+			if now.After(u.expiresAt) {
+				delete(a.users, k)
+			}
+		}
+	}
+
+	return subject, nil
+}
@@ -0,0 +1,122 @@
+package authentication
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/authorization"
+)
+
+// Purposes of signed tokens. They are part of the signature, so that e.g. a login state can't be passed off as a
+// session.
+const (
+	purposeSession    = "session"
+	purposeLoginState = "login-state"
+)
+
+// Session is the logged in user as it's stored in the session cookie. Sessions are stateless: they are valid until
+// they expire, logging out removes the cookie from the browser.
+type Session struct {
+	Subject   authorization.Subject `json:"subject"`
+	ExpiresAt time.Time             `json:"expiresAt"`
+}
+
+// signer creates and verifies HMAC-SHA256 signed tokens of the form base64url(payload).base64url(signature)
+type signer struct {
+	secret []byte
+}
+
+func (s *signer) sign(purpose string, payload interface{}) (string, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %v: %w", purpose, err)
+	}
+	body := base64.RawURLEncoding.EncodeToString(encoded)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.mac(purpose, body)), nil
+}
+
+// verify decodes the payload of the token into v. Tokens whose signature doesn't match are reported with
+// ErrUnauthenticated.
+func (s *signer) verify(purpose string, token string, v interface{}) error {
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return fmt.Errorf("%w: malformed %v", ErrUnauthenticated, purpose)
+	}
+	body := token[:dot]
+	signature, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil || !hmac.Equal(signature, s.mac(purpose, body)) {
+		return fmt.Errorf("%w: invalid %v signature", ErrUnauthenticated, purpose)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return fmt.Errorf("%w: malformed %v", ErrUnauthenticated, purpose)
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("%w: malformed %v", ErrUnauthenticated, purpose)
+	}
+	return nil
+}
+
+func (s *signer) mac(purpose string, body string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(purpose))
+	h.Write([]byte{0})
+	h.Write([]byte(body))
+	return h.Sum(nil)
+}
+
+// SessionProvider authenticates the session cookies which have been issued after an OIDC login. The user has been
+// resolved during the login, hence the provider doesn't need to call the identity provider.
+type SessionProvider struct {
+	signer *signer
+	now    func() time.Time
+}
+
+// NewSessionProvider creates a provider for sessions which have been signed with the given secret
+func NewSessionProvider(secret string) *SessionProvider {
+	return &SessionProvider{signer: &signer{secret: []byte(secret)}, now: time.Now}
+}
+
+// ValidateToken verifies the session's signature and expiry
+func (p *SessionProvider) ValidateToken(_ context.Context, token string) (Identity, error) {
+	var session Session
+	if err := p.signer.verify(purposeSession, token, &session); err != nil {
+		return Identity{}, err
+	}
+	if !p.now().Before(session.ExpiresAt) {
+		return Identity{}, fmt.Errorf("%w: session has expired", ErrUnauthenticated)
+	}
+
+	return Identity{Subject: session.Subject.Name, Claims: map[string]interface{}{"session": session}}, nil
+}
+
+// ResolveUser returns the user as it has been stored in the session
+func (p *SessionProvider) ResolveUser(_ context.Context, identity Identity) (authorization.Subject, error) {
+	session, ok := identity.Claims["session"].(Session)
+	if !ok {
+		return authorization.Subject{}, fmt.Errorf("identity has not been validated by the session provider")
+	}
+	return session.Subject, nil
+}
+
+// Session returns the session of a valid session token
+func (p *SessionProvider) Session(token string) (Session, error) {
+	identity, err := p.ValidateToken(context.Background(), token)
+	if err != nil {
+		return Session{}, err
+	}
+	return identity.Claims["session"].(Session), nil
+}
+
+// issue signs a new session for the subject which expires after the ttl
+func (p *SessionProvider) issue(subject authorization.Subject, ttl time.Duration) (string, Session, error) {
+	session := Session{Subject: subject, ExpiresAt: p.now().Add(ttl).UTC().Truncate(time.Second)}
+	token, err := p.signer.sign(purposeSession, session)
+	return token, session, err
+}
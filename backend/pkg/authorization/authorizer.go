@@ -0,0 +1,109 @@
+package authorization
+
+import (
+	"context"
+)
+
+// Action is an operation a subject wants to perform on a resource. Topic and consumer group actions which are shown
+// in the frontend use the same names as the allowed actions reported to the frontend.
+type Action string
+
+const (
+	ActionSeeCluster Action = "seeCluster"
+
+	ActionSeeTopic           Action = "seeTopic"
+	ActionViewPartitions     Action = "viewPartitions"
+	ActionViewConfig         Action = "viewConfig"
+	ActionViewMessages       Action = "viewMessages"
+	ActionUseSearchFilter    Action = "useSearchFilter"
+	ActionExportMessages     Action = "exportMessages"
+	ActionDecryptMessages    Action = "decryptMessages"
+	ActionManageSavedFilters Action = "manageSavedFilters"
+	ActionEditTopicMetadata  Action = "editTopicMetadata"
+	ActionPublishMessages    Action = "publishMessages"
+	ActionViewConsumers      Action = "viewConsumers"
+	ActionCreateTopic        Action = "createTopic"
+	ActionDeleteTopic        Action = "deleteTopic"
+	ActionDeleteRecords      Action = "deleteRecords"
+	ActionEditConfig         Action = "editConfig"
+
+	ActionSeeConsumerGroup    Action = "seeConsumerGroup"
+	ActionResetOffsets        Action = "resetOffsets"
+	ActionDeleteConsumerGroup Action = "deleteConsumerGroup"
+
+	ActionListACLs Action = "listACLs"
+	ActionEditACLs Action = "editACLs"
+
+	ActionListScramUsers Action = "listScramUsers"
+	ActionEditScramUsers Action = "editScramUsers"
+
+	ActionViewBrokerConfig Action = "viewBrokerConfig"
+	ActionEditBrokerConfig Action = "editBrokerConfig"
+	ActionElectLeaders     Action = "electLeaders"
+
+	ActionViewUsageReport Action = "viewUsageReport"
+
+	ActionViewConnectCluster Action = "viewConnectCluster"
+	ActionEditConnectCluster Action = "editConnectCluster"
+
+	ActionViewKsqlServer  Action = "viewKsqlServer"
+	ActionQueryKsqlServer Action = "queryKsqlServer"
+)
+
+// ResourceType is the kind of resource an action is performed on
+type ResourceType string
+
+const (
+	ResourceCluster        ResourceType = "cluster"
+	ResourceTopic          ResourceType = "topic"
+	ResourceConsumerGroup  ResourceType = "consumerGroup"
+	ResourceACL            ResourceType = "acl"
+	ResourceScramUser      ResourceType = "scramUser"
+	ResourceBroker         ResourceType = "broker"
+	ResourceUsageReport    ResourceType = "usageReport"
+	ResourceConnectCluster ResourceType = "connectCluster"
+	ResourceKsqlServer     ResourceType = "ksqlServer"
+)
+
+// Resource identifies the resource an action is performed on. The name is empty for resources which exist only
+// once (e.g. ACLs).
+type Resource struct {
+	Type ResourceType `json:"type"`
+	Name string       `json:"name"`
+}
+
+// Subject is the requester whose permissions are checked. It's set by the authentication layer, requests of
+// unauthenticated users have an empty subject.
+type Subject struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+
+	// Attributes are additional claims of the subject (e.g. clearance level or department)
+	Attributes map[string]string `json:"attributes"`
+}
+
+// Request is a single authorization question: may the subject perform the action on the resource?
+type Request struct {
+	Subject  Subject  `json:"subject"`
+	Action   Action   `json:"action"`
+	Resource Resource `json:"resource"`
+}
+
+// Authorizer decides whether a request is allowed. An error means that no decision could be made, callers must
+// deny the request in that case.
+type Authorizer interface {
+	Authorize(ctx context.Context, req Request) (bool, error)
+}
+
+type subjectContextKey struct{}
+
+// ContextWithSubject returns a copy of the context which carries the given subject
+func ContextWithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectContextKey{}, subject)
+}
+
+// SubjectFromContext returns the subject of the request, which is empty if none has been set
+func SubjectFromContext(ctx context.Context) Subject {
+	subject, _ := ctx.Value(subjectContextKey{}).(Subject)
+	return subject
+}
@@ -0,0 +1,67 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// cachingAuthorizer remembers decisions of another authorizer for a fixed time. Listing pages check several actions
+// for each topic or group, which would otherwise result in a request to the policy engine per check.
+type cachingAuthorizer struct {
+	inner Authorizer
+	ttl   time.Duration
+
+	mutex     sync.Mutex
+	decisions map[string]cachedDecision
+}
+
+type cachedDecision struct {
+	isAllowed bool
+	expiresAt time.Time
+}
+
+// NewCachingAuthorizer caches the decisions of the given authorizer for ttl. Errors are not cached.
+func NewCachingAuthorizer(inner Authorizer, ttl time.Duration) Authorizer {
+	return &cachingAuthorizer{
+		inner:     inner,
+		ttl:       ttl,
+		decisions: make(map[string]cachedDecision),
+	}
+}
+
+func (c *cachingAuthorizer) Authorize(ctx context.Context, req Request) (bool, error) {
+	keyBytes, err := json.Marshal(req)
+	if err != nil {
+		return c.inner.Authorize(ctx, req)
+	}
+	key := string(keyBytes)
+
+	now := time.Now()
+	c.mutex.Lock()
+	decision, ok := c.decisions[key]
+	c.mutex.Unlock()
+	if ok && now.Before(decision.expiresAt) {
+		return decision.isAllowed, nil
+	}
+
+	isAllowed, err := c.inner.Authorize(ctx, req)
+	if err != nil {
+		return false, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.decisions[key] = cachedDecision{isAllowed: isAllowed, expiresAt: now.Add(c.ttl)}
+	// Evict expired decisions once in a while, so that the cache doesn't grow with every subject ever seen
+	if len(c.decisions)%1000 == 0 {
+		for k, d := range c.decisions {
+			if now.After(d.expiresAt) {
+				delete(c.decisions, k)
+			}
+		}
+	}
+
+	return isAllowed, nil
+}
@@ -0,0 +1,88 @@
+package authorization
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TypeNone disables the authorizer, permissions are then decided by the hooks only
+	TypeNone = "none"
+
+	// TypeOPA asks an Open Policy Agent for each decision
+	TypeOPA = "opa"
+
+	// TypeRBAC decides by the roles which are bound to the requester in the config
+	TypeRBAC = "rbac"
+)
+
+// Config for the authorizer which decides whether a requester may perform an action on a resource
+type Config struct {
+	Type string `yaml:"type"`
+
+	// CacheTTL is the time decisions are cached for the same subject, action and resource. 0 disables the cache.
+	// RBAC decisions are never cached.
+	CacheTTL time.Duration `yaml:"cacheTtl"`
+
+	OPA  OPAConfig  `yaml:"opa"`
+	RBAC RBACConfig `yaml:"rbac"`
+}
+
+// OPAConfig for querying decisions from the REST API of an Open Policy Agent
+type OPAConfig struct {
+	// URL of the agent, e.g. http://localhost:8181
+	URL string `yaml:"url"`
+
+	// DecisionPath is the path of the rule which returns the decision (true or false), e.g. kowl/authz/allow for
+	// the rule 'allow' in the package 'kowl.authz'. An undefined decision denies the request.
+	DecisionPath string `yaml:"decisionPath"`
+
+	Timeout time.Duration `yaml:"timeout"`
+
+	// BearerToken is sent along with each request if the agent requires authentication
+	BearerToken string `yaml:"bearerToken"`
+}
+
+// RegisterFlags for sensitive authorization configs
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&c.OPA.BearerToken, "authorization.opa.bearer-token", "", "Bearer token for authenticating against the Open Policy Agent")
+}
+
+// SetDefaults for the authorization config
+func (c *Config) SetDefaults() {
+	c.Type = TypeNone
+	c.CacheTTL = 10 * time.Second
+	c.OPA.DecisionPath = "kowl/authz/allow"
+	c.OPA.Timeout = 2 * time.Second
+	c.RBAC.setDefaults()
+}
+
+// Validate the authorization config
+func (c *Config) Validate() error {
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("cache ttl must not be negative")
+	}
+
+	switch c.Type {
+	case TypeNone:
+		return nil
+	case TypeOPA:
+		if _, err := url.ParseRequestURI(c.OPA.URL); err != nil {
+			return fmt.Errorf("failed to parse opa url '%v': %w", c.OPA.URL, err)
+		}
+		if strings.Trim(c.OPA.DecisionPath, "/") == "" {
+			return fmt.Errorf("opa decision path must be set")
+		}
+		if c.OPA.Timeout <= 0 {
+			return fmt.Errorf("opa timeout must be greater than 0")
+		}
+		return nil
+	case TypeRBAC:
+		return c.RBAC.validate()
+	default:
+		return fmt.Errorf("unknown authorizer type '%v', must be one of '%v', '%v' or '%v'", c.Type, TypeNone, TypeOPA, TypeRBAC)
+	}
+}
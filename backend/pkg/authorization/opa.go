@@ -0,0 +1,79 @@
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// OPAAuthorizer queries the data API of an Open Policy Agent for each decision. The request is passed as input
+// document, so that policies can decide based on the subject's roles and attributes, the action and the resource.
+// Policies which depend on the time of day can use the builtin time.now_ns(), note that decisions may be cached.
+type OPAAuthorizer struct {
+	cfg         OPAConfig
+	decisionURL string
+	httpClient  *http.Client
+}
+
+// NewOPAAuthorizer creates an authorizer for the agent configured in cfg
+func NewOPAAuthorizer(cfg OPAConfig) *OPAAuthorizer {
+	return &OPAAuthorizer{
+		cfg:         cfg,
+		decisionURL: strings.TrimRight(cfg.URL, "/") + "/v1/data/" + strings.Trim(cfg.DecisionPath, "/"),
+		httpClient:  &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+type opaDecisionRequest struct {
+	Input Request `json:"input"`
+}
+
+type opaDecisionResponse struct {
+	// Result is nil if the decision is undefined, e.g. because no rule matched and the rule has no default
+	Result *bool `json:"result"`
+}
+
+// Authorize asks the agent for a decision. Undefined decisions deny the request.
+func (a *OPAAuthorizer) Authorize(ctx context.Context, req Request) (bool, error) {
+	body, err := json.Marshal(opaDecisionRequest{Input: req})
+	if err != nil {
+		return false, fmt.Errorf("failed to encode opa input: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.decisionURL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create opa request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if a.cfg.BearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+a.cfg.BearerToken)
+	}
+
+	res, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("failed to query opa: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read opa response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("opa responded with status code %v: %v", res.StatusCode, string(resBody))
+	}
+
+	var decision opaDecisionResponse
+	if err := json.Unmarshal(resBody, &decision); err != nil {
+		return false, fmt.Errorf("failed to decode opa decision, the decision path must refer to a boolean rule: %w", err)
+	}
+	if decision.Result == nil {
+		return false, nil
+	}
+
+	return *decision.Result, nil
+}
@@ -0,0 +1,92 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOPAAuthorizer_Authorize(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/v1/data/kowl/authz/allow", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var body opaDecisionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body.Input.Resource.Name {
+		case "orders":
+			allowed := body.Input.Action == ActionSeeTopic && body.Input.Subject.Attributes["clearance"] == "high"
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": allowed})
+		case "undefined":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Type = TypeOPA
+	cfg.OPA.URL = server.URL + "/"
+	cfg.OPA.BearerToken = "secret"
+	require.NoError(t, cfg.Validate())
+	authorizer := NewAuthorizer(cfg)
+
+	subject := Subject{Name: "jane", Attributes: map[string]string{"clearance": "high"}}
+	req := Request{Subject: subject, Action: ActionSeeTopic, Resource: Resource{Type: ResourceTopic, Name: "orders"}}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		isAllowed, err := authorizer.Authorize(ctx, req)
+		require.NoError(t, err)
+		assert.True(t, isAllowed)
+	}
+	assert.Equal(t, 1, requests, "decisions must be cached")
+
+	req.Action = ActionViewMessages
+	isAllowed, err := authorizer.Authorize(ctx, req)
+	require.NoError(t, err)
+	assert.False(t, isAllowed)
+
+	req.Resource.Name = "undefined"
+	isAllowed, err = authorizer.Authorize(ctx, req)
+	require.NoError(t, err)
+	assert.False(t, isAllowed, "undefined decisions must deny")
+
+	req.Resource.Name = "failing"
+	_, err = authorizer.Authorize(ctx, req)
+	assert.Error(t, err)
+}
+
+func TestSubjectFromContext(t *testing.T) {
+	assert.Equal(t, Subject{}, SubjectFromContext(context.Background()))
+
+	subject := Subject{Name: "jane", Roles: []string{"admin"}}
+	assert.Equal(t, subject, SubjectFromContext(ContextWithSubject(context.Background(), subject)))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Type = TypeOPA
+	assert.Error(t, cfg.Validate(), "opa url is required")
+	cfg.OPA.URL = "http://localhost:8181"
+	cfg.OPA.Timeout = 0
+	assert.Error(t, cfg.Validate())
+	cfg.OPA.Timeout = time.Second
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Type = "ldap"
+	assert.Error(t, cfg.Validate())
+}
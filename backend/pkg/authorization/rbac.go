@@ -0,0 +1,213 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+)
+
+// ActionWildcard grants all actions to a role
+const ActionWildcard Action = "*"
+
+// Builtin roles which are configured by default
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// viewerActions only read from the cluster, which is why the viewer role is granted to everyone by default
+var viewerActions = []Action{
+	ActionSeeCluster,
+	ActionSeeTopic,
+	ActionViewPartitions,
+	ActionViewConfig,
+	ActionViewMessages,
+	ActionUseSearchFilter,
+	ActionExportMessages,
+	ActionViewConsumers,
+	ActionSeeConsumerGroup,
+	ActionListACLs,
+	ActionListScramUsers,
+	ActionViewBrokerConfig,
+	ActionViewUsageReport,
+	ActionViewConnectCluster,
+	ActionViewKsqlServer,
+}
+
+// operatorActions change data or consumers of the cluster but not its topology or access rules. Decrypting messages
+// reveals payloads which are protected beyond viewing, so it must be granted explicitly rather than by default.
+var operatorActions = []Action{
+	ActionDecryptMessages,
+	ActionPublishMessages,
+	ActionManageSavedFilters,
+	ActionEditTopicMetadata,
+	ActionCreateTopic,
+	ActionDeleteRecords,
+	ActionEditConfig,
+	ActionResetOffsets,
+	ActionDeleteConsumerGroup,
+	ActionEditConnectCluster,
+	ActionQueryKsqlServer,
+}
+
+// adminActions are the most destructive actions, which only admins may perform by default
+var adminActions = []Action{
+	ActionDeleteTopic,
+	ActionEditACLs,
+	ActionEditScramUsers,
+	ActionEditBrokerConfig,
+	ActionElectLeaders,
+}
+
+// RBACConfig binds roles, which grant a set of actions, to users and groups
+type RBACConfig struct {
+	Roles    []Role        `yaml:"roles"`
+	Bindings []RoleBinding `yaml:"bindings"`
+
+	// DefaultRole is granted to all requesters including anonymous ones, so that browsing remains open. It's not
+	// granted to anyone if empty.
+	DefaultRole string `yaml:"defaultRole"`
+}
+
+// Role grants a set of actions. The wildcard '*' grants all actions.
+type Role struct {
+	Name    string   `yaml:"name"`
+	Actions []Action `yaml:"actions"`
+}
+
+// RoleBinding grants a role to users, matched by their name, and groups, matched by the subject's roles as they have
+// been resolved by the authentication provider (e.g. the 'groups' claim of the ID token)
+type RoleBinding struct {
+	Role   string   `yaml:"role"`
+	Users  []string `yaml:"users"`
+	Groups []string `yaml:"groups"`
+}
+
+// setDefaults configures the builtin viewer, operator and admin roles, each of which includes the actions of the
+// previous one
+func (c *RBACConfig) setDefaults() {
+	operator := append(append([]Action{}, viewerActions...), operatorActions...)
+	c.Roles = []Role{
+		{Name: RoleViewer, Actions: append([]Action{}, viewerActions...)},
+		{Name: RoleOperator, Actions: operator},
+		{Name: RoleAdmin, Actions: []Action{ActionWildcard}},
+	}
+	c.DefaultRole = RoleViewer
+}
+
+func (c *RBACConfig) validate() error {
+	roles := make(map[string]struct{}, len(c.Roles))
+	for i, role := range c.Roles {
+		if role.Name == "" {
+			return fmt.Errorf("role at index '%v' must have a name", i)
+		}
+		if _, exists := roles[role.Name]; exists {
+			return fmt.Errorf("role '%v' is defined more than once", role.Name)
+		}
+		roles[role.Name] = struct{}{}
+		for _, action := range role.Actions {
+			if !isKnownAction(action) {
+				return fmt.Errorf("role '%v' grants unknown action '%v'", role.Name, action)
+			}
+		}
+	}
+
+	if _, exists := roles[c.DefaultRole]; c.DefaultRole != "" && !exists {
+		return fmt.Errorf("default role '%v' is not defined", c.DefaultRole)
+	}
+	for i, binding := range c.Bindings {
+		if _, exists := roles[binding.Role]; !exists {
+			return fmt.Errorf("binding at index '%v' refers to undefined role '%v'", i, binding.Role)
+		}
+		if len(binding.Users) == 0 && len(binding.Groups) == 0 {
+			return fmt.Errorf("binding at index '%v' must grant role '%v' to at least one user or group", i, binding.Role)
+		}
+	}
+
+	return nil
+}
+
+func isKnownAction(action Action) bool {
+	if action == ActionWildcard {
+		return true
+	}
+	for _, actions := range [][]Action{viewerActions, operatorActions, adminActions} {
+		for _, known := range actions {
+			if action == known {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RBACAuthorizer allows an action if one of the requester's roles grants it. Decisions are made in memory, hence
+// they are not cached.
+type RBACAuthorizer struct {
+	defaultRole string
+	roles       map[string]map[Action]struct{}
+	userRoles   map[string][]string
+	groupRoles  map[string][]string
+}
+
+// NewRBACAuthorizer creates an authorizer for the roles and bindings in cfg. The config is expected to be validated.
+func NewRBACAuthorizer(cfg RBACConfig) *RBACAuthorizer {
+	a := &RBACAuthorizer{
+		defaultRole: cfg.DefaultRole,
+		roles:       make(map[string]map[Action]struct{}, len(cfg.Roles)),
+		userRoles:   make(map[string][]string),
+		groupRoles:  make(map[string][]string),
+	}
+	for _, role := range cfg.Roles {
+		actions := make(map[Action]struct{}, len(role.Actions))
+		for _, action := range role.Actions {
+			actions[action] = struct{}{}
+		}
+		a.roles[role.Name] = actions
+	}
+	for _, binding := range cfg.Bindings {
+		for _, user := range binding.Users {
+			a.userRoles[user] = append(a.userRoles[user], binding.Role)
+		}
+		for _, group := range binding.Groups {
+			a.groupRoles[group] = append(a.groupRoles[group], binding.Role)
+		}
+	}
+
+	return a
+}
+
+// Authorize checks the default role and all roles bound to the subject's name or groups
+func (a *RBACAuthorizer) Authorize(_ context.Context, req Request) (bool, error) {
+	if a.grants(a.defaultRole, req.Action) {
+		return true, nil
+	}
+	if req.Subject.Name != "" {
+		for _, role := range a.userRoles[req.Subject.Name] {
+			if a.grants(role, req.Action) {
+				return true, nil
+			}
+		}
+	}
+	for _, group := range req.Subject.Roles {
+		for _, role := range a.groupRoles[group] {
+			if a.grants(role, req.Action) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (a *RBACAuthorizer) grants(role string, action Action) bool {
+	actions, exists := a.roles[role]
+	if !exists {
+		return false
+	}
+	if _, isGranted := actions[ActionWildcard]; isGranted {
+		return true
+	}
+	_, isGranted := actions[action]
+	return isGranted
+}
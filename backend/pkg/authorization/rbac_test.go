@@ -0,0 +1,91 @@
+package authorization
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBACAuthorizer(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Type = TypeRBAC
+	cfg.RBAC.Bindings = []RoleBinding{
+		{Role: RoleOperator, Groups: []string{"oncall"}},
+		{Role: RoleAdmin, Users: []string{"jane@example.com"}},
+	}
+	require.NoError(t, cfg.Validate())
+	authorizer := NewAuthorizer(cfg)
+	require.NotNil(t, authorizer)
+
+	anonymous := Subject{}
+	oncall := Subject{Name: "joe@example.com", Roles: []string{"devs", "oncall"}}
+	admin := Subject{Name: "jane@example.com"}
+
+	tt := []struct {
+		subject   Subject
+		action    Action
+		isAllowed bool
+	}{
+		{anonymous, ActionViewMessages, true},
+		{anonymous, ActionSeeConsumerGroup, true},
+		{anonymous, ActionPublishMessages, false},
+		{anonymous, ActionDecryptMessages, false}, // Must be granted explicitly, the default role only allows viewing
+		{anonymous, ActionDeleteTopic, false},
+		{oncall, ActionViewMessages, true},
+		{oncall, ActionPublishMessages, true},
+		{oncall, ActionDecryptMessages, true},
+		{oncall, ActionResetOffsets, true},
+		{oncall, ActionDeleteTopic, false},
+		{oncall, ActionEditACLs, false},
+		{admin, ActionDeleteTopic, true},
+		{admin, ActionEditACLs, true},
+		{Subject{Name: "oncall"}, ActionPublishMessages, false}, // Groups are not matched by the user's name
+	}
+
+	for i, test := range tt {
+		req := Request{Subject: test.subject, Action: test.action, Resource: Resource{Type: ResourceTopic, Name: "orders"}}
+		isAllowed, err := authorizer.Authorize(context.Background(), req)
+		require.NoError(t, err, "Case: ", i)
+		assert.Equal(t, test.isAllowed, isAllowed, "Case: ", i)
+	}
+
+	// Browsing can be restricted to bound users as well
+	cfg.RBAC.DefaultRole = ""
+	isAllowed, err := NewAuthorizer(cfg).Authorize(context.Background(), Request{Subject: anonymous, Action: ActionViewMessages})
+	require.NoError(t, err)
+	assert.False(t, isAllowed)
+}
+
+func TestRBACConfig_Validate(t *testing.T) {
+	tt := []struct {
+		modify  func(cfg *RBACConfig)
+		isValid bool
+	}{
+		{func(cfg *RBACConfig) {}, true},
+		{func(cfg *RBACConfig) { cfg.DefaultRole = "" }, true},
+		{func(cfg *RBACConfig) { cfg.DefaultRole = "guest" }, false},
+		{func(cfg *RBACConfig) { cfg.Roles = append(cfg.Roles, Role{Name: RoleViewer}) }, false},
+		{func(cfg *RBACConfig) { cfg.Roles = append(cfg.Roles, Role{Actions: []Action{ActionSeeTopic}}) }, false},
+		{func(cfg *RBACConfig) {
+			cfg.Roles = append(cfg.Roles, Role{Name: "typo", Actions: []Action{"deleteTopics"}})
+		}, false},
+		{func(cfg *RBACConfig) { cfg.Bindings = []RoleBinding{{Role: RoleAdmin, Users: []string{"jane"}}} }, true},
+		{func(cfg *RBACConfig) { cfg.Bindings = []RoleBinding{{Role: "superuser", Users: []string{"jane"}}} }, false},
+		{func(cfg *RBACConfig) { cfg.Bindings = []RoleBinding{{Role: RoleAdmin}} }, false},
+	}
+
+	for i, test := range tt {
+		cfg := Config{}
+		cfg.SetDefaults()
+		cfg.Type = TypeRBAC
+		test.modify(&cfg.RBAC)
+		if test.isValid {
+			assert.NoError(t, cfg.Validate(), "Case: ", i)
+		} else {
+			assert.Error(t, cfg.Validate(), "Case: ", i)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package authorization
+
+// NewAuthorizer creates the configured authorizer. It returns nil if no authorizer is configured.
+func NewAuthorizer(cfg Config) Authorizer {
+	var authorizer Authorizer
+	switch cfg.Type {
+	case TypeOPA:
+		authorizer = NewOPAAuthorizer(cfg.OPA)
+	case TypeRBAC:
+		return NewRBACAuthorizer(cfg.RBAC)
+	default:
+		return nil
+	}
+
+	if cfg.CacheTTL > 0 {
+		authorizer = NewCachingAuthorizer(authorizer, cfg.CacheTTL)
+	}
+	return authorizer
+}
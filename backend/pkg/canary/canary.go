@@ -0,0 +1,417 @@
+package canary
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// probeKey is the key of all probes, so that consumers of the canary topic can tell them apart from other records
+const probeKey = "kowl-canary"
+
+// Steps in which a probe can fail
+const (
+	StepProduce = "produce"
+	StepConsume = "consume"
+)
+
+// Results of probes, exported as label of the probes counter
+const (
+	probeResultSuccess       = "success"
+	probeResultProduceFailed = "produce_failed"
+	probeResultConsumeFailed = "consume_failed"
+)
+
+// probeValue is the value of each probe. The instance id tells the probes of multiple Kowl instances apart, which
+// share the canary topic.
+type probeValue struct {
+	InstanceID string `json:"instanceId"`
+	Sequence   int64  `json:"sequence"`
+	SentAt     int64  `json:"sentAt"` // Unix milliseconds
+}
+
+// pendingProbe is a probe which has been produced (or is being produced) but not consumed back yet
+type pendingProbe struct {
+	partitionID int32
+	sentAt      time.Time
+}
+
+// PartitionStatus describes the latest probes of a partition. Latencies are those of the latest successful steps.
+type PartitionStatus struct {
+	PartitionID int32 `json:"partitionId"`
+	LeaderID    int32 `json:"leaderId"` // -1 if the partition has no leader
+
+	Available    bool    `json:"available"`    // Whether the latest completed probe succeeded
+	Availability float64 `json:"availability"` // Ratio of successful probes within the availability window
+	Probes       int     `json:"probes"`       // Completed probes within the availability window
+
+	ProduceLatencyMs     int64  `json:"produceLatencyMs"`
+	EndToEndLatencyMs    int64  `json:"endToEndLatencyMs"`
+	LastSuccessTimestamp int64  `json:"lastSuccessTimestamp"` // Unix milliseconds, 0 if no probe succeeded yet
+	FailedStep           string `json:"failedStep,omitempty"` // Step in which the latest probe failed
+	Error                string `json:"error,omitempty"`
+}
+
+// BrokerStatus aggregates the status of the canary partitions a broker leads
+type BrokerStatus struct {
+	BrokerID             int32   `json:"brokerId"`
+	PartitionCount       int     `json:"partitionCount"`
+	AvailablePartitions  int     `json:"availablePartitions"`
+	Availability         float64 `json:"availability"` // Mean availability of the broker's partitions
+	MaxEndToEndLatencyMs int64   `json:"maxEndToEndLatencyMs"`
+}
+
+// Status is the current state of all canary partitions and their leaders
+type Status struct {
+	TopicName  string            `json:"topicName"`
+	IntervalMs int64             `json:"intervalMs"`
+	TimeoutMs  int64             `json:"timeoutMs"`
+	Partitions []PartitionStatus `json:"partitions"`
+	Brokers    []BrokerStatus    `json:"brokers"`
+}
+
+type partitionState struct {
+	status   PartitionStatus
+	outcomes []bool // Results of the latest completed probes, oldest first
+}
+
+// Canary produces a probe to each partition of the canary topic in the configured interval and consumes it back.
+// Probes are produced with the shared producer, hence the produce latency is the time until the leader acknowledged
+// the probe. The end-to-end latency is the time from producing until the probe has been consumed.
+type Canary struct {
+	cfg          Config
+	cluster      kafka.Cluster
+	instanceID   string
+	tailer       *kafka.TopicTailer
+	logger       *zap.Logger
+	warningLimit *rate.Limiter
+
+	produceLatency  *prometheus.HistogramVec
+	endToEndLatency *prometheus.HistogramVec
+	available       *prometheus.GaugeVec
+	probes          *prometheus.CounterVec
+
+	mutex      sync.Mutex
+	sequence   int64
+	pending    map[int64]*pendingProbe
+	partitions map[int32]*partitionState
+}
+
+// NewCanary creates a canary for the cluster and registers its metrics. Probing starts with Start.
+func NewCanary(cfg Config, cluster kafka.Cluster, namespace string, registerer prometheus.Registerer, logger *zap.Logger) (*Canary, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate canary instance id: %w", err)
+	}
+
+	latencyBuckets := prometheus.ExponentialBuckets(0.001, 2, 15) // 1ms to 16s
+	labels := []string{"partition", "broker"}
+	c := &Canary{
+		cfg:          cfg,
+		cluster:      cluster,
+		instanceID:   hex.EncodeToString(id),
+		logger:       logger.With(zap.String("source", "canary"), zap.String("topic", cfg.Topic)),
+		warningLimit: rate.NewLimiter(rate.Every(5*time.Minute), 1),
+		produceLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "canary",
+			Name:      "produce_latency_seconds",
+			Help:      "Time until a probe has been acknowledged by the partition leader",
+			Buckets:   latencyBuckets,
+		}, labels),
+		endToEndLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "canary",
+			Name:      "end_to_end_latency_seconds",
+			Help:      "Time from producing a probe until it has been consumed",
+			Buckets:   latencyBuckets,
+		}, labels),
+		available: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "canary",
+			Name:      "partition_available",
+			Help:      "Whether the latest probe of the partition has been produced and consumed in time (1) or not (0)",
+		}, labels),
+		probes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "canary",
+			Name:      "probes_total",
+			Help:      "Completed probes by result (success, produce_failed or consume_failed)",
+		}, append(labels, "result")),
+		pending:    make(map[int64]*pendingProbe),
+		partitions: make(map[int32]*partitionState),
+	}
+	registerer.MustRegister(c.produceLatency, c.endToEndLatency, c.available, c.probes)
+
+	// Probes which have been produced before the start are from other instances, only the timeout must be covered
+	c.tailer = kafka.NewTopicTailer(cluster, cfg.Topic, cfg.Timeout, cfg.Interval, c.handle, c.logger)
+
+	return c, nil
+}
+
+// Start consumes the canary topic and produces probes in the configured interval until the process exits. It's a
+// no-op if the canary is nil, which is the case if the canary is disabled.
+func (c *Canary) Start() {
+	if c == nil {
+		return
+	}
+
+	c.tailer.Start()
+	go func() {
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.probe()
+		}
+	}()
+}
+
+// probe fails all probes which have timed out and produces a new probe to each partition
+func (c *Canary) probe() {
+	c.expire(time.Now())
+
+	partitionIDs, err := c.cluster.ListPartitions(c.cfg.Topic)
+	if err != nil {
+		c.warn("failed to list partitions of the canary topic", err)
+		return
+	}
+	leaders, err := c.cluster.PartitionLeaders(c.cfg.Topic, partitionIDs)
+	if err != nil {
+		c.warn("failed to get leaders of the canary partitions", err)
+		return
+	}
+
+	// Partitions are probed concurrently, so that an unavailable broker doesn't delay the probes of other brokers
+	wg := sync.WaitGroup{}
+	for _, partitionID := range partitionIDs {
+		leaderID, ok := leaders[partitionID]
+		if !ok {
+			leaderID = -1
+		}
+		wg.Add(1)
+		go func(partitionID int32, leaderID int32) {
+			defer wg.Done()
+			c.probePartition(partitionID, leaderID)
+		}(partitionID, leaderID)
+	}
+	wg.Wait()
+}
+
+func (c *Canary) probePartition(partitionID int32, leaderID int32) {
+	c.mutex.Lock()
+	c.sequence++
+	sequence := c.sequence
+	sentAt := time.Now()
+	// The probe is pending before it's produced, because it may be consumed before the produce call returns
+	c.pending[sequence] = &pendingProbe{partitionID: partitionID, sentAt: sentAt}
+	c.partition(partitionID, leaderID)
+	c.mutex.Unlock()
+
+	value, err := json.Marshal(probeValue{
+		InstanceID: c.instanceID,
+		Sequence:   sequence,
+		SentAt:     sentAt.UnixNano() / int64(time.Millisecond),
+	})
+	if err == nil {
+		_, err = c.cluster.Produce(kafka.ProduceRecord{
+			TopicName:   c.cfg.Topic,
+			PartitionID: partitionID,
+			Partitioner: kafka.PartitionerManual,
+			Key:         []byte(probeKey),
+			Value:       value,
+		}, kafka.ProduceOptions{})
+	}
+	acknowledgedAt := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	state := c.partitions[partitionID]
+	if err != nil {
+		if p, isPending := c.pending[sequence]; isPending {
+			delete(c.pending, sequence)
+			c.recordFailure(p, StepProduce, err)
+		}
+		return
+	}
+
+	latency := acknowledgedAt.Sub(sentAt)
+	c.produceLatency.WithLabelValues(state.labels()...).Observe(latency.Seconds())
+	state.status.ProduceLatencyMs = latency.Milliseconds()
+}
+
+// handle completes the pending probe which has been consumed
+func (c *Canary) handle(msg *sarama.ConsumerMessage) {
+	consumedAt := time.Now()
+	if !bytes.Equal(msg.Key, []byte(probeKey)) {
+		return
+	}
+	var value probeValue
+	if err := json.Unmarshal(msg.Value, &value); err != nil || value.InstanceID != c.instanceID {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p, isPending := c.pending[value.Sequence]
+	if !isPending {
+		// The probe has already failed, because it has timed out
+		return
+	}
+	delete(c.pending, value.Sequence)
+	c.recordSuccess(p, consumedAt)
+}
+
+// expire fails all pending probes which haven't been consumed within the timeout
+func (c *Canary) expire(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for sequence, p := range c.pending {
+		if now.Sub(p.sentAt) <= c.cfg.Timeout {
+			continue
+		}
+		delete(c.pending, sequence)
+		c.recordFailure(p, StepConsume, fmt.Errorf("probe has not been consumed within %v", c.cfg.Timeout))
+	}
+}
+
+// partition returns the state of the partition and updates its leader. Series of the previous leader are removed,
+// so that each partition has series for a single broker. The mutex must be held.
+func (c *Canary) partition(partitionID int32, leaderID int32) *partitionState {
+	state, exists := c.partitions[partitionID]
+	if !exists {
+		state = &partitionState{status: PartitionStatus{PartitionID: partitionID, LeaderID: leaderID}}
+		c.partitions[partitionID] = state
+	}
+	if state.status.LeaderID != leaderID {
+		labels := state.labels()
+		c.produceLatency.DeleteLabelValues(labels...)
+		c.endToEndLatency.DeleteLabelValues(labels...)
+		c.available.DeleteLabelValues(labels...)
+		for _, result := range []string{probeResultSuccess, probeResultProduceFailed, probeResultConsumeFailed} {
+			c.probes.DeleteLabelValues(append(labels, result)...)
+		}
+		state.status.LeaderID = leaderID
+	}
+
+	return state
+}
+
+// recordSuccess records a probe which has been consumed back in time. The mutex must be held.
+func (c *Canary) recordSuccess(p *pendingProbe, consumedAt time.Time) {
+	state := c.partitions[p.partitionID]
+	labels := state.labels()
+	latency := consumedAt.Sub(p.sentAt)
+	c.endToEndLatency.WithLabelValues(labels...).Observe(latency.Seconds())
+	c.available.WithLabelValues(labels...).Set(1)
+	c.probes.WithLabelValues(append(labels, probeResultSuccess)...).Inc()
+
+	state.status.EndToEndLatencyMs = latency.Milliseconds()
+	state.status.LastSuccessTimestamp = consumedAt.UnixNano() / int64(time.Millisecond)
+	state.status.FailedStep = ""
+	state.status.Error = ""
+	c.addOutcome(state, true)
+}
+
+// recordFailure records a probe which could not be produced or has not been consumed in time. The mutex must be held.
+func (c *Canary) recordFailure(p *pendingProbe, step string, err error) {
+	state := c.partitions[p.partitionID]
+	labels := state.labels()
+	result := probeResultProduceFailed
+	if step == StepConsume {
+		result = probeResultConsumeFailed
+	}
+	c.available.WithLabelValues(labels...).Set(0)
+	c.probes.WithLabelValues(append(labels, result)...).Inc()
+
+	state.status.FailedStep = step
+	state.status.Error = err.Error()
+	c.addOutcome(state, false)
+	if c.warningLimit.Allow() {
+		c.logger.Warn("canary probe failed", zap.Int32("partition_id", p.partitionID), zap.String("step", step), zap.Error(err))
+	}
+}
+
+func (c *Canary) addOutcome(state *partitionState, success bool) {
+	state.outcomes = append(state.outcomes, success)
+	if len(state.outcomes) > c.cfg.AvailabilityWindow {
+		state.outcomes = state.outcomes[len(state.outcomes)-c.cfg.AvailabilityWindow:]
+	}
+
+	successes := 0
+	for _, outcome := range state.outcomes {
+		if outcome {
+			successes++
+		}
+	}
+	state.status.Available = success
+	state.status.Probes = len(state.outcomes)
+	state.status.Availability = float64(successes) / float64(len(state.outcomes))
+}
+
+func (c *Canary) warn(msg string, err error) {
+	if c.warningLimit.Allow() {
+		c.logger.Warn(msg, zap.Error(err))
+	}
+}
+
+// Status returns the status of all canary partitions sorted by partition id and of their leaders sorted by broker id.
+// Partitions without leader are not aggregated into the broker status.
+func (c *Canary) Status() *Status {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	res := &Status{
+		TopicName:  c.cfg.Topic,
+		IntervalMs: c.cfg.Interval.Milliseconds(),
+		TimeoutMs:  c.cfg.Timeout.Milliseconds(),
+		Partitions: make([]PartitionStatus, 0, len(c.partitions)),
+		Brokers:    make([]BrokerStatus, 0),
+	}
+	brokers := make(map[int32]*BrokerStatus)
+	for _, state := range c.partitions {
+		res.Partitions = append(res.Partitions, state.status)
+		if state.status.LeaderID < 0 {
+			continue
+		}
+
+		broker, exists := brokers[state.status.LeaderID]
+		if !exists {
+			broker = &BrokerStatus{BrokerID: state.status.LeaderID}
+			brokers[state.status.LeaderID] = broker
+		}
+		broker.PartitionCount++
+		if state.status.Available {
+			broker.AvailablePartitions++
+		}
+		// Summed up first, divided by the partition count below
+		broker.Availability += state.status.Availability
+		if state.status.EndToEndLatencyMs > broker.MaxEndToEndLatencyMs {
+			broker.MaxEndToEndLatencyMs = state.status.EndToEndLatencyMs
+		}
+	}
+	for _, broker := range brokers {
+		broker.Availability /= float64(broker.PartitionCount)
+		res.Brokers = append(res.Brokers, *broker)
+	}
+	sort.Slice(res.Partitions, func(i, j int) bool { return res.Partitions[i].PartitionID < res.Partitions[j].PartitionID })
+	sort.Slice(res.Brokers, func(i, j int) bool { return res.Brokers[i].BrokerID < res.Brokers[j].BrokerID })
+
+	return res
+}
+
+func (s *partitionState) labels() []string {
+	return []string{strconv.Itoa(int(s.status.PartitionID)), strconv.Itoa(int(s.status.LeaderID))}
+}
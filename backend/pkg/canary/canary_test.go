@@ -0,0 +1,86 @@
+package canary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestCanary(t *testing.T) *Canary {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.Interval = 10 * time.Millisecond
+	cfg.AvailabilityWindow = 4
+	require.NoError(t, cluster.CreateTopic(cfg.Topic, 3, 1, nil, false))
+
+	c, err := NewCanary(cfg, cluster, "test", prometheus.NewRegistry(), zap.NewNop())
+	require.NoError(t, err)
+	return c
+}
+
+func TestCanaryProbesAllPartitions(t *testing.T) {
+	c := newTestCanary(t)
+	c.tailer.Start()
+	c.probe()
+
+	require.Eventually(t, func() bool {
+		status := c.Status()
+		if len(status.Partitions) != 3 {
+			return false
+		}
+		for _, p := range status.Partitions {
+			if !p.Available {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status := c.Status()
+	for i, p := range status.Partitions {
+		assert.Equal(t, int32(i), p.PartitionID, "Case: ", i)
+		assert.Equal(t, 1.0, p.Availability, "Case: ", i)
+		assert.Equal(t, 1, p.Probes, "Case: ", i)
+		assert.NotZero(t, p.LastSuccessTimestamp, "Case: ", i)
+	}
+	require.NotEmpty(t, status.Brokers)
+	partitionCount := 0
+	for _, b := range status.Brokers {
+		partitionCount += b.PartitionCount
+		assert.Equal(t, b.PartitionCount, b.AvailablePartitions)
+	}
+	assert.Equal(t, 3, partitionCount)
+	assert.Equal(t, 3, testutil.CollectAndCount(c.endToEndLatency))
+	assert.Equal(t, 3, testutil.CollectAndCount(c.probes))
+}
+
+func TestCanaryFailsProbesWhichTimedOut(t *testing.T) {
+	c := newTestCanary(t)
+
+	// The topic isn't consumed, hence no probe can succeed
+	c.probe()
+	c.expire(time.Now().Add(c.cfg.Timeout + time.Second))
+	c.probe()
+
+	status := c.Status()
+	require.Len(t, status.Partitions, 3)
+	for i, p := range status.Partitions {
+		assert.False(t, p.Available, "Case: ", i)
+		assert.Equal(t, StepConsume, p.FailedStep, "Case: ", i)
+		assert.Equal(t, 0.0, p.Availability, "Case: ", i)
+	}
+	assert.Len(t, c.pending, 3, "probes of the second round are still pending")
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.available.WithLabelValues(c.partitions[0].labels()...)))
+}
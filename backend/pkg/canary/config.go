@@ -0,0 +1,57 @@
+package canary
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config for the canary, which periodically produces a timestamped probe to each partition of the canary topic and
+// consumes it back, so that the produce latency, the end-to-end latency and the availability of each partition and
+// its leader are measured continuously
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Topic of the default cluster the probes are produced to. It must exist and should have at least one partition
+	// led by each broker, otherwise brokers without canary partitions are not covered. Instances must not share it
+	// with business data, but multiple Kowl instances may share it.
+	Topic string `yaml:"topic"`
+
+	// Interval in which a probe is produced to each partition
+	Interval time.Duration `yaml:"interval"`
+
+	// Timeout is the time until a probe must have been consumed back, otherwise it counts as failed
+	Timeout time.Duration `yaml:"timeout"`
+
+	// AvailabilityWindow is the number of recent probes of each partition the reported availability is computed of
+	AvailabilityWindow int `yaml:"availabilityWindow"`
+}
+
+// SetDefaults for the canary config
+func (c *Config) SetDefaults() {
+	c.Topic = "__kowl_canary"
+	c.Interval = 10 * time.Second
+	c.Timeout = 10 * time.Second
+	c.AvailabilityWindow = 60
+}
+
+// Validate the canary config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Topic == "" {
+		return fmt.Errorf("topic must be set")
+	}
+	if c.Interval < time.Second {
+		return fmt.Errorf("interval must be at least 1s")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+	if c.AvailabilityWindow <= 0 {
+		return fmt.Errorf("availability window must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package collectors
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Register registers the collector on the default registry. If an equal collector has been registered already, the
+// existing one is returned, so that services with the same metrics namespace share their metrics.
+func Register(c prometheus.Collector) prometheus.Collector {
+	err := prometheus.Register(c)
+	if err == nil {
+		return c
+	}
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return are.ExistingCollector
+	}
+	panic(err)
+}
@@ -0,0 +1,20 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	opts := prometheus.CounterOpts{Namespace: "collectors_test", Name: "calls_total", Help: "Calls"}
+	first := Register(prometheus.NewCounter(opts))
+	second := Register(prometheus.NewCounter(opts))
+	assert.Same(t, first, second)
+
+	// Collectors with the same name but different labels can't be registered
+	assert.Panics(t, func() {
+		Register(prometheus.NewCounterVec(opts, []string{"result"}))
+	})
+}
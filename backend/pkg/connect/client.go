@@ -0,0 +1,232 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+)
+
+// Client talks to the REST API of a single Kafka Connect cluster. All requests go through the dependency, which
+// applies the request timeout, retries and the circuit breaker.
+type Client struct {
+	cfg        ConfigCluster
+	httpClient *http.Client
+	dependency *resilience.Dependency
+}
+
+// RestError is the error body returned by Kafka Connect
+type RestError struct {
+	StatusCode int    `json:"-"`
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *RestError) Error() string {
+	return fmt.Sprintf("kafka connect responded with status code %v: %v", e.StatusCode, e.Message)
+}
+
+// ConnectorInfo is the name, config, type and tasks of a connector
+type ConnectorInfo struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+	Type   string            `json:"type"`
+	Tasks  []struct {
+		Connector string `json:"connector"`
+		Task      int    `json:"task"`
+	} `json:"tasks"`
+}
+
+// ConnectorStatus is the state of a connector and all its tasks
+type ConnectorStatus struct {
+	Name      string `json:"name"`
+	Connector struct {
+		State    string `json:"state"`
+		WorkerID string `json:"worker_id"`
+		Trace    string `json:"trace,omitempty"`
+	} `json:"connector"`
+	Tasks []TaskStatus `json:"tasks"`
+	Type  string       `json:"type"`
+}
+
+// TaskStatus is the state of a single connector task
+type TaskStatus struct {
+	ID       int    `json:"id"`
+	State    string `json:"state"`
+	WorkerID string `json:"worker_id"`
+	Trace    string `json:"trace,omitempty"`
+}
+
+// ConnectorInfoWithStatus is returned when connectors are listed with the expand option
+type ConnectorInfoWithStatus struct {
+	Info   ConnectorInfo   `json:"info"`
+	Status ConnectorStatus `json:"status"`
+}
+
+// ConfigValidationResult is the response of Connect's config validate endpoint
+type ConfigValidationResult struct {
+	Name       string                  `json:"name"`
+	ErrorCount int                     `json:"error_count"`
+	Groups     []string                `json:"groups"`
+	Configs    []ConfigValidationEntry `json:"configs"`
+}
+
+// ConfigValidationEntry is the validation result for a single config key
+type ConfigValidationEntry struct {
+	Definition json.RawMessage `json:"definition"`
+	Value      struct {
+		Name              string   `json:"name"`
+		Value             *string  `json:"value"`
+		RecommendedValues []string `json:"recommended_values"`
+		Errors            []string `json:"errors"`
+		Visible           bool     `json:"visible"`
+	} `json:"value"`
+}
+
+// ListConnectors returns the info and status of all connectors by their name
+func (c *Client) ListConnectors(ctx context.Context) (map[string]ConnectorInfoWithStatus, error) {
+	var res map[string]ConnectorInfoWithStatus
+	err := c.do(ctx, http.MethodGet, "/connectors?expand=info&expand=status", nil, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list connectors: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetConnectorInfo returns the connector's config and tasks
+func (c *Client) GetConnectorInfo(ctx context.Context, connector string) (*ConnectorInfo, error) {
+	var res ConnectorInfo
+	err := c.do(ctx, http.MethodGet, "/connectors/"+url.PathEscape(connector), nil, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connector '%v': %w", connector, err)
+	}
+
+	return &res, nil
+}
+
+// GetConnectorStatus returns the state of the connector and its tasks
+func (c *Client) GetConnectorStatus(ctx context.Context, connector string) (*ConnectorStatus, error) {
+	var res ConnectorStatus
+	err := c.do(ctx, http.MethodGet, "/connectors/"+url.PathEscape(connector)+"/status", nil, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status of connector '%v': %w", connector, err)
+	}
+
+	return &res, nil
+}
+
+// PutConnectorConfig creates the connector or updates its config if it exists already
+func (c *Client) PutConnectorConfig(ctx context.Context, connector string, config map[string]string) (*ConnectorInfo, error) {
+	var res ConnectorInfo
+	err := c.do(ctx, http.MethodPut, "/connectors/"+url.PathEscape(connector)+"/config", config, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put config of connector '%v': %w", connector, err)
+	}
+
+	return &res, nil
+}
+
+// ValidateConnectorConfig validates the config against the connector plugin set in 'connector.class'
+func (c *Client) ValidateConnectorConfig(ctx context.Context, config map[string]string) (*ConfigValidationResult, error) {
+	pluginClass := config["connector.class"]
+	if pluginClass == "" {
+		return nil, fmt.Errorf("config must contain 'connector.class'")
+	}
+
+	var res ConfigValidationResult
+	path := "/connector-plugins/" + url.PathEscape(pluginClass) + "/config/validate"
+	err := c.do(ctx, http.MethodPut, path, config, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate connector config: %w", err)
+	}
+
+	return &res, nil
+}
+
+// PauseConnector pauses the connector and its tasks
+func (c *Client) PauseConnector(ctx context.Context, connector string) error {
+	return c.do(ctx, http.MethodPut, "/connectors/"+url.PathEscape(connector)+"/pause", nil, nil)
+}
+
+// ResumeConnector resumes a paused connector
+func (c *Client) ResumeConnector(ctx context.Context, connector string) error {
+	return c.do(ctx, http.MethodPut, "/connectors/"+url.PathEscape(connector)+"/resume", nil, nil)
+}
+
+// RestartConnector restarts the connector instance (not its tasks)
+func (c *Client) RestartConnector(ctx context.Context, connector string) error {
+	return c.do(ctx, http.MethodPost, "/connectors/"+url.PathEscape(connector)+"/restart", nil, nil)
+}
+
+// RestartTask restarts a single task of the connector
+func (c *Client) RestartTask(ctx context.Context, connector string, taskID int) error {
+	path := fmt.Sprintf("/connectors/%v/tasks/%d/restart", url.PathEscape(connector), taskID)
+	return c.do(ctx, http.MethodPost, path, nil, nil)
+}
+
+// isFailure returns false for errors which have been returned by a reachable cluster, e.g. for unknown connectors
+func isFailure(err error) bool {
+	var restErr *RestError
+	return !errors.As(err, &restErr) || restErr.StatusCode >= http.StatusInternalServerError
+}
+
+// do sends a request with the given JSON body (may be nil) and decodes the JSON response into result (may be nil).
+// POST requests (restarts) are not idempotent, hence they are not retried.
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	call := func(ctx context.Context) error {
+		return c.send(ctx, method, path, body, result)
+	}
+	if method == http.MethodPost {
+		return c.dependency.DoOnce(ctx, call)
+	}
+	return c.dependency.Do(ctx, call)
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.cfg.URL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		restErr := &RestError{StatusCode: res.StatusCode}
+		_ = json.NewDecoder(res.Body).Decode(restErr)
+		return restErr
+	}
+	if result == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
@@ -0,0 +1,71 @@
+package connect
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+)
+
+// Config for connecting to one or more Kafka Connect clusters
+type Config struct {
+	Enabled  bool            `yaml:"enabled"`
+	Clusters []ConfigCluster `yaml:"clusters"`
+
+	// RequestTimeout bounds each request. Retries and circuit breaking apply to each cluster separately.
+	RequestTimeout time.Duration     `yaml:"requestTimeout"`
+	Resilience     resilience.Config `yaml:"resilience"`
+}
+
+// ConfigCluster is a single Kafka Connect cluster which is reachable via its REST API
+type ConfigCluster struct {
+	// Name is used to refer to the cluster in the API and must be unique
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+
+	// Basic auth credentials, leave empty if the cluster does not require authentication
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// SetDefaults for the connect config
+func (c *Config) SetDefaults() {
+	c.RequestTimeout = 6 * time.Second
+	c.Resilience.SetDefaults()
+}
+
+// Validate the connect config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Clusters) == 0 {
+		return fmt.Errorf("at least one kafka connect cluster must be configured if kafka connect is enabled")
+	}
+
+	names := make(map[string]struct{}, len(c.Clusters))
+	for i, cluster := range c.Clusters {
+		if cluster.Name == "" {
+			return fmt.Errorf("name of kafka connect cluster at index '%v' must be set", i)
+		}
+		if _, exists := names[cluster.Name]; exists {
+			return fmt.Errorf("kafka connect cluster name '%v' is used more than once", cluster.Name)
+		}
+		names[cluster.Name] = struct{}{}
+
+		if _, err := url.ParseRequestURI(cluster.URL); err != nil {
+			return fmt.Errorf("failed to parse url of kafka connect cluster '%v': %w", cluster.Name, err)
+		}
+	}
+
+	if c.RequestTimeout <= 0 {
+		return fmt.Errorf("kafka connect request timeout must be greater than 0")
+	}
+	if err := c.Resilience.Validate(); err != nil {
+		return fmt.Errorf("failed to validate resilience config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package connect
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"go.uber.org/zap"
+)
+
+// ErrClusterNotFound is returned if no Kafka Connect cluster with the requested name has been configured
+var ErrClusterNotFound = errors.New("kafka connect cluster not found")
+
+// Service provides clients for all configured Kafka Connect clusters
+type Service struct {
+	cfg     Config
+	logger  *zap.Logger
+	clients map[string]*Client
+}
+
+// NewService creates a client for each configured Kafka Connect cluster. Each cluster is registered as dependency
+// named connect_<clusterName>.
+func NewService(cfg Config, dependencies *resilience.Registry, logger *zap.Logger) *Service {
+	clients := make(map[string]*Client, len(cfg.Clusters))
+	for _, cluster := range cfg.Clusters {
+		clients[cluster.Name] = &Client{
+			cfg:        cluster,
+			httpClient: &http.Client{},
+			dependency: dependencies.NewDependency("connect_"+cluster.Name, cfg.RequestTimeout, cfg.Resilience, isFailure),
+		}
+	}
+
+	return &Service{
+		cfg:     cfg,
+		logger:  logger,
+		clients: clients,
+	}
+}
+
+// ClusterNames returns the names of all configured clusters in the configured order
+func (s *Service) ClusterNames() []string {
+	names := make([]string, len(s.cfg.Clusters))
+	for i, cluster := range s.cfg.Clusters {
+		names[i] = cluster.Name
+	}
+	return names
+}
+
+// Client returns the client for the cluster with the given name
+func (s *Service) Client(clusterName string) (*Client, error) {
+	client, exists := s.clients[clusterName]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%v'", ErrClusterNotFound, clusterName)
+	}
+	return client, nil
+}
@@ -0,0 +1,141 @@
+package decryption
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/topicpattern"
+)
+
+const (
+	// TypeEnvelope decrypts AES-GCM encrypted payloads whose data key has been wrapped by a key management service
+	TypeEnvelope = "envelope"
+
+	// TypePlugin loads the decrypter from a Go plugin
+	TypePlugin = "plugin"
+)
+
+// Config for decrypting keys and values which have been encrypted at the application layer. Payloads are decrypted
+// before their type is detected, so that they can be browsed and filtered like unencrypted payloads.
+type Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Type    string `yaml:"type"`
+
+	// Rules select the topics whose keys and values are encrypted
+	Rules []Rule `yaml:"rules"`
+
+	Envelope EnvelopeConfig `yaml:"envelope"`
+	Plugin   PluginConfig   `yaml:"plugin"`
+}
+
+// Rule marks the keys and/or values of all topics which match the topic pattern as encrypted
+type Rule struct {
+	// TopicPattern is a regex which must match the whole topic name. An empty pattern matches all topics.
+	TopicPattern string `yaml:"topicPattern"`
+
+	Keys   bool `yaml:"keys"`
+	Values bool `yaml:"values"`
+
+	// KeyID is the key encryption key of the topic, it's used for records which don't name their key in a header
+	KeyID string `yaml:"keyId"`
+}
+
+// EnvelopeConfig for payloads which are encrypted with a per-record data key. The data key is wrapped by a key
+// encryption key of the KMS and sent along in a record header. Payloads consist of the 12 byte nonce followed by
+// the AES-GCM ciphertext.
+type EnvelopeConfig struct {
+	// WrappedKeyHeader is the record header which carries the wrapped data key
+	WrappedKeyHeader string `yaml:"wrappedKeyHeader"`
+
+	// KeyIDHeader is the record header which names the key encryption key, the rule's key id is used if it's absent
+	KeyIDHeader string `yaml:"keyIdHeader"`
+
+	// DataKeyCacheTTL is the time unwrapped data keys are remembered, so that the KMS isn't asked for every record
+	DataKeyCacheTTL time.Duration `yaml:"dataKeyCacheTtl"`
+
+	// MaxCachedDataKeys bounds the memory of remembered data keys
+	MaxCachedDataKeys int `yaml:"maxCachedDataKeys"`
+
+	KMS KMSConfig `yaml:"kms"`
+}
+
+// KMSConfig for the HTTP endpoint which unwraps data keys. It receives a POST request with the JSON body
+// {"keyId": "...", "ciphertext": "<base64>"} and must respond with {"plaintext": "<base64>"}.
+type KMSConfig struct {
+	URL         string        `yaml:"url"`
+	Timeout     time.Duration `yaml:"timeout"`
+	BearerToken string        `yaml:"bearerToken"`
+}
+
+// PluginConfig for loading a decrypter from a Go plugin
+type PluginConfig struct {
+	// Path of the shared object, which must have been built with the same Go version and dependencies as Kowl
+	Path string `yaml:"path"`
+
+	// Options are passed to the plugin's constructor
+	Options map[string]string `yaml:"options"`
+}
+
+// RegisterFlags for sensitive decryption configs
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&c.Envelope.KMS.BearerToken, "decryption.envelope.kms.bearer-token", "", "Bearer token for authenticating against the KMS which unwraps data keys")
+}
+
+// SetDefaults for the decryption config
+func (c *Config) SetDefaults() {
+	c.Type = TypeEnvelope
+	c.Envelope.WrappedKeyHeader = "encryption-data-key"
+	c.Envelope.KeyIDHeader = "encryption-key-id"
+	c.Envelope.DataKeyCacheTTL = 5 * time.Minute
+	c.Envelope.MaxCachedDataKeys = 10000
+	c.Envelope.KMS.Timeout = 5 * time.Second
+}
+
+// Validate the decryption config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Rules) == 0 {
+		return fmt.Errorf("at least one rule must be set")
+	}
+	for i, rule := range c.Rules {
+		if !rule.Keys && !rule.Values {
+			return fmt.Errorf("rule at index '%v' must decrypt keys, values or both", i)
+		}
+		if _, err := topicpattern.Compile(rule.TopicPattern); err != nil {
+			return fmt.Errorf("rule at index '%v' has an invalid topic pattern: %w", i, err)
+		}
+	}
+
+	switch c.Type {
+	case TypeEnvelope:
+		if c.Envelope.WrappedKeyHeader == "" {
+			return fmt.Errorf("wrapped key header must be set")
+		}
+		if c.Envelope.DataKeyCacheTTL < 0 {
+			return fmt.Errorf("data key cache ttl must not be negative")
+		}
+		if c.Envelope.MaxCachedDataKeys <= 0 {
+			return fmt.Errorf("max cached data keys must be greater than 0")
+		}
+		u, err := url.Parse(c.Envelope.KMS.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("kms url must be an absolute http or https url")
+		}
+		if c.Envelope.KMS.Timeout <= 0 {
+			return fmt.Errorf("kms timeout must be greater than 0")
+		}
+		return nil
+	case TypePlugin:
+		if c.Plugin.Path == "" {
+			return fmt.Errorf("plugin path must be set")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown decryption type '%v', must be one of '%v' or '%v'", c.Type, TypeEnvelope, TypePlugin)
+	}
+}
@@ -0,0 +1,181 @@
+package decryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// gcmNonceSize is the size of the nonce which precedes the ciphertext of envelope encrypted payloads
+const gcmNonceSize = 12
+
+// EnvelopeDecrypter decrypts AES-GCM encrypted payloads with the record's data key. The data key is sent along in a
+// record header, wrapped by a key encryption key that never leaves the KMS, which unwraps it on request.
+type EnvelopeDecrypter struct {
+	cfg        EnvelopeConfig
+	httpClient *http.Client
+
+	mutex    sync.Mutex
+	dataKeys map[dataKeyCacheKey]cachedDataKey
+	now      func() time.Time
+}
+
+type dataKeyCacheKey struct {
+	KeyID      string
+	WrappedKey string
+}
+
+type cachedDataKey struct {
+	Key       []byte
+	ExpiresAt time.Time
+}
+
+// NewEnvelopeDecrypter creates a decrypter which unwraps data keys with the KMS configured in cfg
+func NewEnvelopeDecrypter(cfg EnvelopeConfig) *EnvelopeDecrypter {
+	return &EnvelopeDecrypter{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.KMS.Timeout},
+		dataKeys:   make(map[dataKeyCacheKey]cachedDataKey),
+		now:        time.Now,
+	}
+}
+
+// Decrypt unwraps the payload's data key and decrypts the payload with it. The key id header takes precedence over
+// the key id of the matching rule.
+func (d *EnvelopeDecrypter) Decrypt(ctx context.Context, payload EncryptedPayload) ([]byte, error) {
+	wrappedKey, ok := payload.Headers[d.cfg.WrappedKeyHeader]
+	if !ok || len(wrappedKey) == 0 {
+		return nil, fmt.Errorf("record has no wrapped data key in header '%v'", d.cfg.WrappedKeyHeader)
+	}
+	keyID := payload.KeyID
+	if headerKeyID, ok := payload.Headers[d.cfg.KeyIDHeader]; ok && d.cfg.KeyIDHeader != "" && len(headerKeyID) > 0 {
+		keyID = string(headerKeyID)
+	}
+	if len(payload.Payload) < gcmNonceSize {
+		return nil, fmt.Errorf("payload is shorter than the %v byte nonce", gcmNonceSize)
+	}
+
+	dataKey, err := d.dataKey(ctx, keyID, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm cipher: %w", err)
+	}
+	plaintext, err := gcm.Open(nil, payload.Payload[:gcmNonceSize], payload.Payload[gcmNonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// dataKey returns the unwrapped data key from the cache or asks the KMS to unwrap it
+func (d *EnvelopeDecrypter) dataKey(ctx context.Context, keyID string, wrappedKey []byte) ([]byte, error) {
+	cacheKey := dataKeyCacheKey{KeyID: keyID, WrappedKey: string(wrappedKey)}
+	now := d.now()
+
+	d.mutex.Lock()
+	cached, ok := d.dataKeys[cacheKey]
+	d.mutex.Unlock()
+	if ok && now.Before(cached.ExpiresAt) {
+		return cached.Key, nil
+	}
+
+	key, err := d.unwrapKey(ctx, keyID, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.DataKeyCacheTTL == 0 {
+		return key, nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if len(d.dataKeys) >= d.cfg.MaxCachedDataKeys {
+		d.evictDataKeys(now)
+	}
+	d.dataKeys[cacheKey] = cachedDataKey{Key: key, ExpiresAt: now.Add(d.cfg.DataKeyCacheTTL)}
+
+	return key, nil
+}
+
+// evictDataKeys drops all expired data keys. If none has expired the cache is cleared, so that its size stays bound
+// without tracking the access order. Callers must hold the mutex.
+func (d *EnvelopeDecrypter) evictDataKeys(now time.Time) {
+	for key, cached := range d.dataKeys {
+		if !now.Before(cached.ExpiresAt) {
+			delete(d.dataKeys, key)
+		}
+	}
+	if len(d.dataKeys) >= d.cfg.MaxCachedDataKeys {
+		d.dataKeys = make(map[dataKeyCacheKey]cachedDataKey)
+	}
+}
+
+type unwrapKeyRequest struct {
+	KeyID      string `json:"keyId"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type unwrapKeyResponse struct {
+	Plaintext string `json:"plaintext"`
+}
+
+// unwrapKey asks the KMS to decrypt the wrapped data key with the given key encryption key
+func (d *EnvelopeDecrypter) unwrapKey(ctx context.Context, keyID string, wrappedKey []byte) ([]byte, error) {
+	reqBody, err := json.Marshal(unwrapKeyRequest{
+		KeyID:      keyID,
+		Ciphertext: base64.StdEncoding.EncodeToString(wrappedKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode kms request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.KMS.URL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kms request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	if d.cfg.KMS.BearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+d.cfg.KMS.BearerToken)
+	}
+
+	res, err := d.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query kms: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kms response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kms responded with status code %v: %v", res.StatusCode, string(resBody))
+	}
+
+	var unwrapped unwrapKeyResponse
+	if err := json.Unmarshal(resBody, &unwrapped); err != nil {
+		return nil, fmt.Errorf("failed to decode kms response: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(unwrapped.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("kms response contains no base64 encoded data key: %w", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,38 @@
+package decryption
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// PluginConstructor is the name of the function a plugin must export to create its decrypter. Its signature must be
+// NewDecrypter(options map[string]string) (decryption.Decrypter, error).
+const PluginConstructor = "NewDecrypter"
+
+// LoadPlugin opens the Go plugin at path and creates its decrypter with the given options. Plugins must be built
+// with the same Go version and dependency versions as Kowl and are only supported on Linux and macOS.
+func LoadPlugin(path string, options map[string]string) (Decrypter, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin '%v': %w", path, err)
+	}
+	symbol, err := p.Lookup(PluginConstructor)
+	if err != nil {
+		return nil, fmt.Errorf("plugin '%v' doesn't export '%v': %w", path, PluginConstructor, err)
+	}
+	constructor, ok := symbol.(func(map[string]string) (Decrypter, error))
+	if !ok {
+		return nil, fmt.Errorf("plugin '%v' exports '%v' with signature %T, expected func(map[string]string) (decryption.Decrypter, error)",
+			path, PluginConstructor, symbol)
+	}
+
+	decrypter, err := constructor(options)
+	if err != nil {
+		return nil, fmt.Errorf("plugin '%v' failed to create decrypter: %w", path, err)
+	}
+	if decrypter == nil {
+		return nil, fmt.Errorf("plugin '%v' returned no decrypter", path)
+	}
+
+	return decrypter, nil
+}
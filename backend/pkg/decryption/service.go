@@ -0,0 +1,130 @@
+package decryption
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/cloudhut/kowl/backend/pkg/topicpattern"
+)
+
+// Decrypter decrypts keys or values which have been encrypted at the application layer. Implementations must be
+// safe for concurrent use, as all partition consumers of a search share the same decrypter.
+type Decrypter interface {
+	Decrypt(ctx context.Context, payload EncryptedPayload) ([]byte, error)
+}
+
+// EncryptedPayload is an encrypted key or value along with the record headers, which usually carry the metadata
+// that is required to decrypt it (e.g. a wrapped data key)
+type EncryptedPayload struct {
+	TopicName string
+	IsKey     bool
+
+	// KeyID is the key encryption key of the matching rule, it may be empty
+	KeyID string
+
+	Payload []byte
+	Headers map[string][]byte
+}
+
+// Service selects the decryption rule of a topic and creates decrypters for consume requests
+type Service struct {
+	decrypter Decrypter
+	rules     []*rule
+}
+
+type rule struct {
+	Rule
+	topicRegex *regexp.Regexp
+}
+
+// NewService creates the configured decrypter and compiles all rules. The config is expected to be validated.
+func NewService(cfg Config) (*Service, error) {
+	var decrypter Decrypter
+	switch cfg.Type {
+	case TypeEnvelope:
+		decrypter = NewEnvelopeDecrypter(cfg.Envelope)
+	case TypePlugin:
+		var err error
+		decrypter, err = LoadPlugin(cfg.Plugin.Path, cfg.Plugin.Options)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown decryption type '%v'", cfg.Type)
+	}
+
+	return newService(decrypter, cfg.Rules)
+}
+
+func newService(decrypter Decrypter, rules []Rule) (*Service, error) {
+	svc := &Service{decrypter: decrypter, rules: make([]*rule, len(rules))}
+	for i, r := range rules {
+		topicRegex, err := topicpattern.Compile(r.TopicPattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile topic pattern of decryption rule at index '%v': %w", i, err)
+		}
+		svc.rules[i] = &rule{Rule: r, topicRegex: topicRegex}
+	}
+
+	return svc, nil
+}
+
+// TopicDecrypter returns the decrypter for messages of the given topic. The first matching rule applies. Nil is
+// returned if no rule matches, so that the topic's messages are consumed as they are.
+func (s *Service) TopicDecrypter(topicName string) *TopicDecrypter {
+	for _, r := range s.rules {
+		if r.topicRegex.MatchString(topicName) {
+			return &TopicDecrypter{
+				decrypter: s.decrypter,
+				topicName: topicName,
+				keyID:     r.KeyID,
+				keys:      r.Keys,
+				values:    r.Values,
+			}
+		}
+	}
+
+	return nil
+}
+
+// TopicDecrypter decrypts the keys and/or values of a single topic
+type TopicDecrypter struct {
+	decrypter Decrypter
+	topicName string
+	keyID     string
+	keys      bool
+	values    bool
+}
+
+// DecryptKey decrypts the record key if the topic's keys are encrypted, otherwise the key is returned unchanged.
+// Empty keys are never decrypted.
+func (d *TopicDecrypter) DecryptKey(ctx context.Context, key []byte, headers map[string][]byte) ([]byte, error) {
+	if d == nil || !d.keys {
+		return key, nil
+	}
+	return d.decrypt(ctx, key, true, headers)
+}
+
+// DecryptValue decrypts the record value if the topic's values are encrypted, otherwise the value is returned
+// unchanged. Empty values (e.g. tombstones) are never decrypted.
+func (d *TopicDecrypter) DecryptValue(ctx context.Context, value []byte, headers map[string][]byte) ([]byte, error) {
+	if d == nil || !d.values {
+		return value, nil
+	}
+	return d.decrypt(ctx, value, false, headers)
+}
+
+func (d *TopicDecrypter) decrypt(ctx context.Context, payload []byte, isKey bool, headers map[string][]byte) ([]byte, error) {
+	if len(payload) == 0 {
+		return payload, nil
+	}
+
+	return d.decrypter.Decrypt(ctx, EncryptedPayload{
+		TopicName: d.topicName,
+		IsKey:     isKey,
+		KeyID:     d.keyID,
+		Payload:   payload,
+		Headers:   headers,
+	})
+}
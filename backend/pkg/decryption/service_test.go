@@ -0,0 +1,113 @@
+package decryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encryptPayload(t *testing.T, dataKey []byte, plaintext []byte) []byte {
+	block, err := aes.NewCipher(dataKey)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, gcmNonceSize)
+	return gcm.Seal(nonce, nonce, plaintext, nil)
+}
+
+func TestEnvelopeDecryption(t *testing.T) {
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req unwrapKeyRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		wrapped, err := base64.StdEncoding.DecodeString(req.Ciphertext)
+		require.NoError(t, err)
+		if req.KeyID != "orders-kek" || string(wrapped) != "wrapped-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(unwrapKeyResponse{Plaintext: base64.StdEncoding.EncodeToString(dataKey)})
+	}))
+	defer server.Close()
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.Rules = []Rule{
+		{TopicPattern: "orders-.*", Values: true, KeyID: "orders-kek"},
+		{TopicPattern: "keys", Keys: true},
+	}
+	cfg.Envelope.KMS.URL = server.URL
+	cfg.Envelope.KMS.BearerToken = "secret"
+	require.NoError(t, cfg.Validate())
+	svc, err := NewService(cfg)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	assert.Nil(t, svc.TopicDecrypter("payments"))
+
+	d := svc.TopicDecrypter("orders-eu")
+	require.NotNil(t, d)
+	headers := map[string][]byte{"encryption-data-key": []byte("wrapped-key")}
+	encrypted := encryptPayload(t, dataKey, []byte(`{"amount":42}`))
+	for i := 0; i < 3; i++ {
+		value, err := d.DecryptValue(ctx, encrypted, headers)
+		require.NoError(t, err)
+		assert.Equal(t, `{"amount":42}`, string(value))
+	}
+	assert.Equal(t, 1, requests, "unwrapped data keys must be cached")
+
+	// Keys are only decrypted if the rule says so, tombstones are never decrypted
+	key, err := d.DecryptKey(ctx, []byte("order-1"), headers)
+	require.NoError(t, err)
+	assert.Equal(t, "order-1", string(key))
+	value, err := d.DecryptValue(ctx, nil, headers)
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	// The key id header takes precedence over the rule's key id
+	headers["encryption-key-id"] = []byte("other-kek")
+	_, err = d.DecryptValue(ctx, encrypted, headers)
+	assert.Error(t, err)
+
+	_, err = d.DecryptValue(ctx, encrypted, map[string][]byte{})
+	assert.Error(t, err)
+
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 0xff
+	_, err = d.DecryptValue(ctx, tampered, map[string][]byte{"encryption-data-key": []byte("wrapped-key")})
+	assert.Error(t, err)
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	require.NoError(t, cfg.Validate(), "disabled config must be valid")
+
+	cfg.Enabled = true
+	assert.Error(t, cfg.Validate(), "rules are required")
+
+	cfg.Rules = []Rule{{TopicPattern: "orders"}}
+	assert.Error(t, cfg.Validate(), "rules must decrypt keys or values")
+
+	cfg.Rules[0].Values = true
+	assert.Error(t, cfg.Validate(), "kms url is required")
+
+	cfg.Envelope.KMS.URL = "https://kms.example.com/unwrap"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Rules[0].TopicPattern = "orders-("
+	assert.Error(t, cfg.Validate())
+}
@@ -0,0 +1,91 @@
+package dlq
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TopicPlaceholder is replaced with the name of the source topic in topic patterns
+const TopicPlaceholder = "{topic}"
+
+// Config for recognizing dead letter queues (DLQs), the topics to which consumers move messages they have failed to
+// process, and for linking their messages back to the topic they have been consumed from
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// TopicPatterns derive the name of a DLQ from the name of its source topic, e.g. '{topic}.dlq'. The first pattern
+	// which matches a topic name applies.
+	TopicPatterns []string `yaml:"topicPatterns"`
+
+	// OriginHeaders are the headers in which DLQ producers record the origin of a message. The first set whose topic
+	// header is present applies.
+	OriginHeaders []OriginHeaders `yaml:"originHeaders"`
+
+	// MaxReprocessMessages is the maximum number of messages which can be selected to be reprocessed at once
+	MaxReprocessMessages int `yaml:"maxReprocessMessages"`
+}
+
+// OriginHeaders are the header keys of the source topic, partition and offset of a DLQ message. Partitions and
+// offsets may either be decimal strings or big-endian integers (4 bytes for partitions, 8 bytes for offsets).
+type OriginHeaders struct {
+	Name      string `yaml:"name"`
+	Topic     string `yaml:"topic"`
+	Partition string `yaml:"partition"`
+	Offset    string `yaml:"offset"`
+}
+
+// SetDefaults for the DLQ config, the origin headers cover Spring Kafka, Kafka Connect and messages which have been
+// replayed by Kowl
+func (c *Config) SetDefaults() {
+	c.TopicPatterns = []string{"{topic}.dlq", "{topic}-dlq", "{topic}.DLT", "{topic}-dlt"}
+	c.OriginHeaders = []OriginHeaders{
+		{
+			Name:      "spring",
+			Topic:     "kafka_dlt-original-topic",
+			Partition: "kafka_dlt-original-partition",
+			Offset:    "kafka_dlt-original-offset",
+		},
+		{
+			Name:      "connect",
+			Topic:     "__connect.errors.topic",
+			Partition: "__connect.errors.partition",
+			Offset:    "__connect.errors.offset",
+		},
+		{
+			Name:      "kowl",
+			Topic:     "kowl-replay-source-topic",
+			Partition: "kowl-replay-source-partition",
+			Offset:    "kowl-replay-source-offset",
+		},
+	}
+	c.MaxReprocessMessages = 500
+}
+
+// Validate the DLQ config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.TopicPatterns) == 0 && len(c.OriginHeaders) == 0 {
+		return fmt.Errorf("either topic patterns or origin headers must be set")
+	}
+	if c.MaxReprocessMessages <= 0 {
+		return fmt.Errorf("max reprocess messages must be greater than 0")
+	}
+	for i, pattern := range c.TopicPatterns {
+		if strings.Count(pattern, TopicPlaceholder) != 1 {
+			return fmt.Errorf("topic pattern at index '%v' must contain '%v' exactly once", i, TopicPlaceholder)
+		}
+		if pattern == TopicPlaceholder {
+			return fmt.Errorf("topic pattern at index '%v' must differ from the source topic name", i)
+		}
+	}
+	for i, headers := range c.OriginHeaders {
+		if headers.Topic == "" {
+			return fmt.Errorf("origin headers at index '%v' must set the topic header", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package dlq
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// OriginSourceTopicPattern is the origin source of messages whose origin has been derived from the DLQ's name
+const OriginSourceTopicPattern = "topicPattern"
+
+// Origin is the message from which a DLQ message has been created
+type Origin struct {
+	Topic string `json:"topic"`
+
+	// PartitionID and Offset are -1 if they are unknown
+	PartitionID int32 `json:"partitionId"`
+	Offset      int64 `json:"offset"`
+
+	// Source is the name of the origin headers which the origin has been read from or 'topicPattern'
+	Source string `json:"source"`
+}
+
+// Resolver links DLQs and their messages to their source topics
+type Resolver struct {
+	patterns      []topicPattern
+	originHeaders []OriginHeaders
+}
+
+type topicPattern struct {
+	prefix string
+	suffix string
+}
+
+// NewResolver returns a resolver for the config, which is expected to be validated
+func NewResolver(cfg Config) *Resolver {
+	patterns := make([]topicPattern, len(cfg.TopicPatterns))
+	for i, pattern := range cfg.TopicPatterns {
+		parts := strings.SplitN(pattern, TopicPlaceholder, 2)
+		patterns[i] = topicPattern{prefix: parts[0], suffix: parts[1]}
+	}
+
+	return &Resolver{
+		patterns:      patterns,
+		originHeaders: cfg.OriginHeaders,
+	}
+}
+
+// SourceTopic returns the name of the source topic if the topic name matches one of the DLQ topic patterns
+func (r *Resolver) SourceTopic(topic string) (string, bool) {
+	for _, p := range r.patterns {
+		if len(topic) <= len(p.prefix)+len(p.suffix) {
+			continue
+		}
+		if strings.HasPrefix(topic, p.prefix) && strings.HasSuffix(topic, p.suffix) {
+			return topic[len(p.prefix) : len(topic)-len(p.suffix)], true
+		}
+	}
+
+	return "", false
+}
+
+// DLQTopics returns the existing topics which are DLQs of the source topic according to the topic patterns
+func (r *Resolver) DLQTopics(sourceTopic string, topics []string) []string {
+	existing := make(map[string]struct{}, len(topics))
+	for _, topic := range topics {
+		existing[topic] = struct{}{}
+	}
+
+	dlqTopics := make([]string, 0)
+	for _, p := range r.patterns {
+		candidate := p.prefix + sourceTopic + p.suffix
+		if _, exists := existing[candidate]; !exists {
+			continue
+		}
+		delete(existing, candidate) // Patterns may overlap
+		dlqTopics = append(dlqTopics, candidate)
+	}
+
+	return dlqTopics
+}
+
+// Origin returns the origin of a message in the DLQ topic. The origin headers take precedence over the topic patterns,
+// because some DLQs (e.g. of Kafka Connect) collect the messages of multiple topics. Nil is returned if the origin is
+// unknown.
+func (r *Resolver) Origin(dlqTopic string, headers []*sarama.RecordHeader) *Origin {
+	for _, originHeaders := range r.originHeaders {
+		topic := headerValue(headers, originHeaders.Topic)
+		if len(topic) == 0 {
+			continue
+		}
+
+		origin := &Origin{
+			Topic:       string(topic),
+			PartitionID: -1,
+			Offset:      -1,
+			Source:      originHeaders.Name,
+		}
+		if partitionID, ok := parseInteger(headerValue(headers, originHeaders.Partition), 4); ok {
+			origin.PartitionID = int32(partitionID)
+		}
+		if offset, ok := parseInteger(headerValue(headers, originHeaders.Offset), 8); ok {
+			origin.Offset = offset
+		}
+		return origin
+	}
+
+	if sourceTopic, ok := r.SourceTopic(dlqTopic); ok {
+		return &Origin{
+			Topic:       sourceTopic,
+			PartitionID: -1,
+			Offset:      -1,
+			Source:      OriginSourceTopicPattern,
+		}
+	}
+
+	return nil
+}
+
+// headerValue returns the value of the last header with the key, nil if there is none
+func headerValue(headers []*sarama.RecordHeader, key string) []byte {
+	if key == "" {
+		return nil
+	}
+
+	var value []byte
+	for _, h := range headers {
+		if h != nil && string(h.Key) == key {
+			value = h.Value
+		}
+	}
+	return value
+}
+
+// parseInteger parses non-negative integers which are either encoded as decimal strings or as big-endian integers
+// with the given number of bytes
+func parseInteger(value []byte, binarySize int) (int64, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if parsed, err := strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64); err == nil {
+		return parsed, parsed >= 0
+	}
+
+	var parsed int64
+	switch {
+	case len(value) == binarySize && binarySize == 4:
+		parsed = int64(int32(binary.BigEndian.Uint32(value)))
+	case len(value) == binarySize && binarySize == 8:
+		parsed = int64(binary.BigEndian.Uint64(value))
+	default:
+		return 0, false
+	}
+	return parsed, parsed >= 0
+}
@@ -0,0 +1,90 @@
+package dlq
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.TopicPatterns = append(cfg.TopicPatterns, "dlq.{topic}")
+	require.NoError(t, cfg.Validate())
+	r := NewResolver(cfg)
+
+	sourceTopic, ok := r.SourceTopic("orders.dlq")
+	assert.True(t, ok)
+	assert.Equal(t, "orders", sourceTopic)
+	sourceTopic, ok = r.SourceTopic("dlq.payments")
+	assert.True(t, ok)
+	assert.Equal(t, "payments", sourceTopic)
+	_, ok = r.SourceTopic(".dlq")
+	assert.False(t, ok, "the source topic name must not be empty")
+	_, ok = r.SourceTopic("orders")
+	assert.False(t, ok)
+
+	topics := []string{"orders", "orders.dlq", "orders-dlt", "orders.dlq.dlq", "payments"}
+	assert.Equal(t, []string{"orders.dlq", "orders-dlt"}, r.DLQTopics("orders", topics))
+	assert.Empty(t, r.DLQTopics("payments", topics))
+
+	spring := func(topic string, partitionID int32, offset int64) []*sarama.RecordHeader {
+		partition := make([]byte, 4)
+		binary.BigEndian.PutUint32(partition, uint32(partitionID))
+		offsetValue := make([]byte, 8)
+		binary.BigEndian.PutUint64(offsetValue, uint64(offset))
+		return []*sarama.RecordHeader{
+			{Key: []byte("kafka_dlt-original-topic"), Value: []byte(topic)},
+			{Key: []byte("kafka_dlt-original-partition"), Value: partition},
+			{Key: []byte("kafka_dlt-original-offset"), Value: offsetValue},
+		}
+	}
+	connect := []*sarama.RecordHeader{
+		{Key: []byte("__connect.errors.topic"), Value: []byte("clicks")},
+		{Key: []byte("__connect.errors.partition"), Value: []byte("3")},
+		{Key: []byte("__connect.errors.offset"), Value: []byte("42")},
+	}
+
+	tt := []struct {
+		dlqTopic string
+		headers  []*sarama.RecordHeader
+		expected *Origin
+	}{
+		{"orders.dlq", spring("orders", 2, 1337), &Origin{Topic: "orders", PartitionID: 2, Offset: 1337, Source: "spring"}},
+		{"connect-errors", connect, &Origin{Topic: "clicks", PartitionID: 3, Offset: 42, Source: "connect"}},
+		{"orders.dlq", nil, &Origin{Topic: "orders", PartitionID: -1, Offset: -1, Source: OriginSourceTopicPattern}},
+		{"orders.dlq", []*sarama.RecordHeader{
+			{Key: []byte("kowl-replay-source-topic"), Value: []byte("orders")},
+			{Key: []byte("kowl-replay-source-offset"), Value: []byte("invalid")},
+		}, &Origin{Topic: "orders", PartitionID: -1, Offset: -1, Source: "kowl"}},
+		{"connect-errors", nil, nil},
+	}
+	for i, test := range tt {
+		assert.Equal(t, test.expected, r.Origin(test.dlqTopic, test.headers), "Case: ", i)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tt := []struct {
+		patterns []string
+		isValid  bool
+	}{
+		{[]string{"{topic}.dlq"}, true},
+		{[]string{"dlq-{topic}"}, true},
+		{[]string{"dlq"}, false},
+		{[]string{"{topic}"}, false},
+		{[]string{"{topic}.{topic}"}, false},
+	}
+	for i, test := range tt {
+		cfg := Config{}
+		cfg.SetDefaults()
+		cfg.Enabled = true
+		cfg.TopicPatterns = test.patterns
+		err := cfg.Validate()
+		assert.Equal(t, test.isValid, err == nil, "Case: ", i)
+	}
+}
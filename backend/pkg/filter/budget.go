@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded is returned (wrapped) once a budget has no CPU time left
+var ErrBudgetExceeded = errors.New("filter execution budget exceeded")
+
+// Budget limits the cumulative CPU time that may be spent executing filter code, as measured by ExecutionTimer. A search budget is shared by all
+// partition consumers of a single search and usually has the requester's budget as parent, so that executed filter
+// code is accounted on both.
+type Budget struct {
+	name   string
+	limit  time.Duration // 0 means unlimited
+	parent *Budget
+
+	mutex *sync.Mutex
+	used  time.Duration
+}
+
+// NewBudget creates a new budget with the given limit. The name is used to construct a meaningful error message
+// once the budget is exhausted. Parent may be nil.
+func NewBudget(name string, limit time.Duration, parent *Budget) *Budget {
+	return &Budget{
+		name:   name,
+		limit:  limit,
+		parent: parent,
+		mutex:  &sync.Mutex{},
+	}
+}
+
+// Consume accounts the given CPU time on this budget and all its parents. It returns an error wrapping
+// ErrBudgetExceeded if this or any parent budget has been exhausted.
+func (b *Budget) Consume(d time.Duration) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mutex.Lock()
+	b.used += d
+	used := b.used
+	b.mutex.Unlock()
+
+	if err := b.parent.Consume(d); err != nil {
+		return err
+	}
+
+	if b.limit > 0 && used > b.limit {
+		return fmt.Errorf("%w: filter code has used %v of CPU time which exceeds the %v limit of %v",
+			ErrBudgetExceeded, used.Round(time.Millisecond), b.name, b.limit)
+	}
+
+	return nil
+}
+
+// Used returns the CPU time which has been accounted on this budget so far
+func (b *Budget) Used() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.used
+}
+
+// BudgetRegistry hands out one budget per requester. Budgets are reset once their time window has passed.
+type BudgetRegistry struct {
+	limit  time.Duration
+	window time.Duration
+
+	mutex   *sync.Mutex
+	budgets map[string]*windowedBudget
+}
+
+type windowedBudget struct {
+	budget      *Budget
+	windowStart time.Time
+}
+
+// NewBudgetRegistry creates a registry whose budgets allow the given CPU time within each window
+func NewBudgetRegistry(limit time.Duration, window time.Duration) *BudgetRegistry {
+	return &BudgetRegistry{
+		limit:   limit,
+		window:  window,
+		mutex:   &sync.Mutex{},
+		budgets: make(map[string]*windowedBudget),
+	}
+}
+
+// ForRequester returns the budget for the given requester. It returns nil if per requester budgets are disabled.
+func (r *BudgetRegistry) ForRequester(requesterID string) *Budget {
+	if r.limit <= 0 {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.evictExpired(now)
+
+	b, ok := r.budgets[requesterID]
+	if !ok {
+		b = &windowedBudget{
+			budget:      NewBudget("requester", r.limit, nil),
+			windowStart: now,
+		}
+		r.budgets[requesterID] = b
+	}
+
+	return b.budget
+}
+
+// evictExpired removes all budgets whose window has passed. Callers must hold the mutex.
+func (r *BudgetRegistry) evictExpired(now time.Time) {
+	for id, b := range r.budgets {
+		if now.Sub(b.windowStart) > r.window {
+			delete(r.budgets, id)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetConsume(t *testing.T) {
+	b := NewBudget("search", 100*time.Millisecond, nil)
+
+	require.NoError(t, b.Consume(60*time.Millisecond))
+	require.NoError(t, b.Consume(40*time.Millisecond), "consuming exactly the limit is allowed")
+	err := b.Consume(time.Millisecond)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	assert.Contains(t, err.Error(), "search limit")
+	assert.Equal(t, 101*time.Millisecond, b.Used())
+}
+
+func TestBudgetConsumePropagatesToParent(t *testing.T) {
+	parent := NewBudget("requester", 100*time.Millisecond, nil)
+	first := NewBudget("search", 0, parent)
+	second := NewBudget("search", 0, parent)
+
+	require.NoError(t, first.Consume(60*time.Millisecond))
+	assert.Equal(t, 60*time.Millisecond, parent.Used())
+
+	// The second search is unlimited itself, but exhausts the parent which is shared with the first search
+	err := second.Consume(50 * time.Millisecond)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	assert.Contains(t, err.Error(), "requester limit")
+	assert.Equal(t, 110*time.Millisecond, parent.Used())
+	assert.Equal(t, 50*time.Millisecond, second.Used())
+}
+
+func TestBudgetNil(t *testing.T) {
+	var b *Budget
+	assert.NoError(t, b.Consume(time.Hour))
+	assert.NoError(t, NewBudget("search", 0, nil).Consume(time.Hour), "budgets without limit never exceed")
+}
+
+func TestBudgetRegistry(t *testing.T) {
+	assert.Nil(t, NewBudgetRegistry(0, time.Minute).ForRequester("alice"), "per requester budgets are disabled")
+
+	r := NewBudgetRegistry(100*time.Millisecond, 50*time.Millisecond)
+	alice := r.ForRequester("alice")
+	assert.Same(t, alice, r.ForRequester("alice"))
+	assert.NotSame(t, alice, r.ForRequester("bob"))
+
+	err := alice.Consume(200 * time.Millisecond)
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	assert.True(t, errors.Is(r.ForRequester("alice").Consume(time.Millisecond), ErrBudgetExceeded))
+	assert.NoError(t, r.ForRequester("bob").Consume(time.Millisecond))
+
+	// Once the window has passed, the requester gets a fresh budget
+	time.Sleep(60 * time.Millisecond)
+	renewed := r.ForRequester("alice")
+	assert.NotSame(t, alice, renewed)
+	assert.Equal(t, time.Duration(0), renewed.Used())
+	assert.NoError(t, renewed.Consume(time.Millisecond))
+}
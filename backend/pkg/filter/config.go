@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config for the JavaScript filter code users can submit along with message searches
+type Config struct {
+	// MaxCodeSize is the maximum number of bytes the (decoded) filter code may have
+	MaxCodeSize int `yaml:"maxCodeSize"`
+
+	// MessageTimeout is the time the filter code may run for a single message. Users may request a different
+	// timeout for their searches, which is capped at MaxMessageTimeout.
+	MessageTimeout    time.Duration `yaml:"messageTimeout"`
+	MaxMessageTimeout time.Duration `yaml:"maxMessageTimeout"`
+
+	// MaxIterationsPerMessage is the number of loop iterations and function calls the filter code may execute for a
+	// single message. Unlike the timeout it stops runaway filters deterministically. 0 disables the limit.
+	MaxIterationsPerMessage int `yaml:"maxIterationsPerMessage"`
+
+	// MaxSearchExecutionTime is the cumulative CPU time the filter code may use across all partitions of a single
+	// search. 0 disables the limit.
+	MaxSearchExecutionTime time.Duration `yaml:"maxSearchExecutionTime"`
+
+	// MaxRequesterExecutionTime is the cumulative CPU time the filter code of all searches from the same requester
+	// may use within RequesterBudgetWindow. 0 disables the limit.
+	MaxRequesterExecutionTime time.Duration `yaml:"maxRequesterExecutionTime"`
+	RequesterBudgetWindow     time.Duration `yaml:"requesterBudgetWindow"`
+}
+
+// SetDefaults for the filter config
+func (c *Config) SetDefaults() {
+	c.MaxCodeSize = 8 * 1024 // 8kb
+	c.MessageTimeout = 400 * time.Millisecond
+	c.MaxMessageTimeout = 2 * time.Second
+	c.MaxIterationsPerMessage = 100000
+	c.MaxSearchExecutionTime = 2 * time.Minute
+	c.MaxRequesterExecutionTime = 10 * time.Minute
+	c.RequesterBudgetWindow = time.Hour
+}
+
+// Validate the filter config
+func (c *Config) Validate() error {
+	if c.MaxCodeSize <= 0 {
+		return fmt.Errorf("max code size must be greater than 0")
+	}
+
+	if c.MessageTimeout <= 0 {
+		return fmt.Errorf("message timeout must be greater than 0")
+	}
+
+	if c.MaxMessageTimeout < c.MessageTimeout {
+		return fmt.Errorf("max message timeout must not be lower than the message timeout")
+	}
+
+	if c.MaxIterationsPerMessage < 0 {
+		return fmt.Errorf("max iterations per message must not be negative")
+	}
+
+	if c.MaxSearchExecutionTime < 0 || c.MaxRequesterExecutionTime < 0 {
+		return fmt.Errorf("max execution times must not be negative")
+	}
+
+	if c.MaxRequesterExecutionTime > 0 && c.RequesterBudgetWindow <= 0 {
+		return fmt.Errorf("requester budget window must be greater than 0 if max requester execution time is set")
+	}
+
+	return nil
+}
+
+// Limits bound the execution of the filter code for a single message
+type Limits struct {
+	Timeout       time.Duration
+	MaxIterations int // 0 disables the limit
+}
+
+// Limits returns the limits for a search which requested the given message timeout. A requested timeout of 0 falls
+// back to the configured message timeout, greater timeouts are capped at the max message timeout.
+func (c *Config) Limits(requestedTimeout time.Duration) Limits {
+	timeout := c.MessageTimeout
+	if requestedTimeout > 0 {
+		timeout = requestedTimeout
+	}
+	if timeout > c.MaxMessageTimeout {
+		timeout = c.MaxMessageTimeout
+	}
+
+	return Limits{Timeout: timeout, MaxIterations: c.MaxIterationsPerMessage}
+}
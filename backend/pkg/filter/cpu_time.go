@@ -0,0 +1,36 @@
+package filter
+
+import (
+	"runtime"
+	"time"
+)
+
+// ExecutionTimer measures the CPU time which filter code uses on the calling goroutine. The goroutine is locked to
+// its OS thread until the timer is stopped, so that the thread's CPU time can be read. Unlike the wall-clock time it
+// isn't inflated by GC pauses or scheduler preemption of a loaded backend. On platforms without per thread CPU times
+// the wall-clock time is measured instead.
+type ExecutionTimer struct {
+	startedAt  time.Time
+	startedCPU time.Duration
+	hasCPU     bool
+}
+
+// StartExecutionTimer locks the goroutine to its thread and starts measuring. Stop must be called on the same
+// goroutine.
+func StartExecutionTimer() ExecutionTimer {
+	runtime.LockOSThread()
+	cpu, ok := threadCPUTime()
+	return ExecutionTimer{startedAt: time.Now(), startedCPU: cpu, hasCPU: ok}
+}
+
+// Stop unlocks the goroutine from its thread and returns the CPU time used since the timer has been started
+func (t ExecutionTimer) Stop() time.Duration {
+	defer runtime.UnlockOSThread()
+
+	if t.hasCPU {
+		if cpu, ok := threadCPUTime(); ok {
+			return cpu - t.startedCPU
+		}
+	}
+	return time.Since(t.startedAt)
+}
@@ -0,0 +1,15 @@
+package filter
+
+import (
+	"syscall"
+	"time"
+)
+
+// threadCPUTime returns the user and system CPU time of the calling thread
+func threadCPUTime() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_THREAD, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
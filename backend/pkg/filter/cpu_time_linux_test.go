@@ -0,0 +1,20 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutionTimer(t *testing.T) {
+	// Waiting doesn't use any CPU time, like a goroutine which has been preempted or paused by the GC
+	timer := StartExecutionTimer()
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, timer.Stop() < 20*time.Millisecond)
+
+	timer = StartExecutionTimer()
+	for until := time.Now().Add(50 * time.Millisecond); time.Now().Before(until); {
+	}
+	assert.True(t, timer.Stop() > 0)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package filter
+
+import "time"
+
+// threadCPUTime is not supported on this platform, execution timers fall back to the wall-clock time
+func threadCPUTime() (time.Duration, bool) {
+	return 0, false
+}
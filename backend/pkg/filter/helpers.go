@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// RegisterHelpers defines the helper functions which are available to all filter code, so that filter authors don't
+// have to re-implement deep property access and common predicates in every filter:
+//
+//	find(obj, "a.b[0].c")          returns the value at the given path or undefined if any part of it is missing
+//	matches(value, "^ord-\\d+$")   reports whether the value (converted to a string) matches the regex
+//	base64decode("aGVsbG8=")       decodes a (padded or unpadded) base64 string
+//	dateBetween(ts, from, to)      reports whether from <= ts < to
+//
+// Dates can be passed as Go time (e.g. the message timestamp), JavaScript Date, unix milliseconds or as RFC 3339
+// string. Invalid arguments throw a TypeError.
+func RegisterHelpers(vm *goja.Runtime) error {
+	// Filter code usually passes the same literal patterns for every message, hence we compile them only once
+	regexes := make(map[string]*regexp.Regexp)
+
+	helpers := map[string]func(call goja.FunctionCall) goja.Value{
+		"find": func(call goja.FunctionCall) goja.Value {
+			path, err := parsePropertyPath(call.Argument(1).String())
+			if err != nil {
+				panic(vm.NewTypeError("find: %v", err))
+			}
+			res, ok := findProperty(call.Argument(0).Export(), path)
+			if !ok {
+				return goja.Undefined()
+			}
+			return vm.ToValue(res)
+		},
+		"matches": func(call goja.FunctionCall) goja.Value {
+			value := call.Argument(0)
+			if goja.IsUndefined(value) || goja.IsNull(value) {
+				return vm.ToValue(false)
+			}
+			pattern := call.Argument(1).String()
+			regex, ok := regexes[pattern]
+			if !ok {
+				var err error
+				regex, err = regexp.Compile(pattern)
+				if err != nil {
+					panic(vm.NewTypeError("matches: invalid regex: %v", err))
+				}
+				regexes[pattern] = regex
+			}
+			return vm.ToValue(regex.MatchString(value.String()))
+		},
+		"base64decode": func(call goja.FunctionCall) goja.Value {
+			decoded, err := decodeBase64(call.Argument(0).String())
+			if err != nil {
+				panic(vm.NewTypeError("base64decode: %v", err))
+			}
+			return vm.ToValue(decoded)
+		},
+		"dateBetween": func(call goja.FunctionCall) goja.Value {
+			var dates [3]time.Time
+			for i := range dates {
+				date, err := toTime(call.Argument(i).Export())
+				if err != nil {
+					panic(vm.NewTypeError("dateBetween: argument %d: %v", i+1, err))
+				}
+				dates[i] = date
+			}
+			ts, from, to := dates[0], dates[1], dates[2]
+			return vm.ToValue(!ts.Before(from) && ts.Before(to))
+		},
+	}
+
+	for name, fn := range helpers {
+		if err := vm.Set(name, fn); err != nil {
+			return fmt.Errorf("failed to define filter helper '%v': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// parsePropertyPath splits a path like `a.b[0]["c.d"]` into its segments: a, b, 0, c.d
+func parsePropertyPath(path string) ([]string, error) {
+	segments := make([]string, 0)
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			if i == 0 || i == len(path)-1 {
+				return nil, fmt.Errorf("path '%v' must not start or end with a dot", path)
+			}
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("path '%v' has an unclosed bracket", path)
+			}
+			segment := path[i+1 : i+end]
+			if unquoted, err := strconv.Unquote(segment); err == nil {
+				segment = unquoted
+			} else if _, err := strconv.Atoi(segment); err != nil {
+				return nil, fmt.Errorf("path '%v' must have an index or quoted key within brackets", path)
+			}
+			segments = append(segments, segment)
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end == -1 {
+				end = len(path) - i
+			}
+			segments = append(segments, path[i:i+end])
+			i += end
+		}
+	}
+
+	return segments, nil
+}
+
+// findProperty walks the given segments through nested objects and arrays. It returns false if a segment doesn't
+// exist.
+func findProperty(obj interface{}, path []string) (interface{}, bool) {
+	current := obj
+	for _, segment := range path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			current = v[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func decodeBase64(str string) (string, error) {
+	encoding := base64.StdEncoding
+	if strings.ContainsAny(str, "-_") {
+		encoding = base64.URLEncoding
+	}
+	if !strings.HasSuffix(str, "=") {
+		encoding = encoding.WithPadding(base64.NoPadding)
+	}
+	decoded, err := encoding.DecodeString(str)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// toTime converts an exported JavaScript value into a time. Numbers are unix milliseconds.
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.Unix(0, v*int64(time.Millisecond)), nil
+	case float64:
+		return time.Unix(0, int64(v*float64(time.Millisecond))), nil
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("'%v' is neither an RFC 3339 timestamp nor a date", v)
+	default:
+		return time.Time{}, fmt.Errorf("expected a date, timestamp or unix milliseconds but got '%v'", value)
+	}
+}
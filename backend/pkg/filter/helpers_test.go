@@ -0,0 +1,18 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePropertyPath(t *testing.T) {
+	segments, err := parsePropertyPath(`a.b[0]["c.d"].e`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "0", "c.d", "e"}, segments)
+
+	for _, invalid := range []string{".a", "a.", "a[0", "a[b]"} {
+		_, err := parsePropertyPath(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,216 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+)
+
+// IterationHook is the name of the function which instrumented filter code calls on every loop iteration and
+// function invocation. The interpreter must provide it, see Instrument.
+const IterationHook = "__kowlIteration"
+
+// Instrument inserts a call to IterationHook at the start of every loop body and function body of the given filter
+// code. Counting these calls allows to stop runaway filters deterministically after a number of iterations,
+// regardless of how fast the machine executing them is. Arrow functions with an expression body are not counted,
+// their recursion depth is limited by the interpreter's max call stack size.
+func Instrument(code string) (string, error) {
+	// The code is used as function body, hence it must be parsed within a function
+	prefix := fmt.Sprintf("(function(%s) {", FunctionParameters)
+	program, err := parser.ParseFile(nil, "", prefix+code+"\n})", 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse filter code: %w", err)
+	}
+
+	in := &instrumenter{code: code, prefixLength: len(prefix)}
+	walkAST(reflect.ValueOf(program), make(map[uintptr]struct{}), func(node interface{}) {
+		switch n := node.(type) {
+		case *ast.ForStatement:
+			in.instrumentBody(n.Body)
+		case *ast.ForInStatement:
+			in.instrumentBody(n.Body)
+		case *ast.ForOfStatement:
+			in.instrumentBody(n.Body)
+		case *ast.WhileStatement:
+			in.instrumentBody(n.Body)
+		case *ast.DoWhileStatement:
+			in.instrumentBody(n.Body)
+		case *ast.FunctionLiteral:
+			// The wrapping function itself is called once per message and doesn't need to be counted
+			if in.offset(n.Body.LeftBrace) >= 0 {
+				in.instrumentBody(n.Body)
+			}
+		case *ast.ArrowFunctionLiteral:
+			if body, ok := n.Body.(*ast.BlockStatement); ok {
+				in.instrumentBody(body)
+			}
+		}
+	})
+	if in.err != nil {
+		return "", in.err
+	}
+
+	// The positions reported by the parser are not always accurate, hence we double check the result
+	instrumented := in.apply()
+	if _, err := parser.ParseFunction(FunctionParameters, instrumented); err != nil {
+		return "", fmt.Errorf("failed to instrument filter code: %w", err)
+	}
+
+	return instrumented, nil
+}
+
+// insertion is a snippet of code which shall be inserted before the byte at the given offset
+type insertion struct {
+	offset int
+	code   string
+}
+
+type instrumenter struct {
+	code         string
+	prefixLength int
+	insertions   []insertion
+	err          error
+}
+
+// offset converts a position of the parsed program into a byte offset of the submitted code
+func (in *instrumenter) offset(idx file.Idx) int {
+	return int(idx) - 1 - in.prefixLength
+}
+
+// instrumentBody inserts the hook call into the body of a loop or function. The end positions reported by the
+// parser are unreliable, hence non block bodies are prefixed with an if statement which calls the hook and always
+// executes the original body (a dangling else still binds to the original statement).
+func (in *instrumenter) instrumentBody(body ast.Statement) {
+	if block, ok := body.(*ast.BlockStatement); ok {
+		in.insert(in.offset(block.LeftBrace)+1, IterationHook+"();")
+		return
+	}
+	in.insert(in.start(body), " if ("+IterationHook+"(), true) ")
+}
+
+func (in *instrumenter) insert(offset int, code string) {
+	if offset < 0 || offset > len(in.code) {
+		in.err = fmt.Errorf("failed to instrument filter code: position %v is out of range", offset)
+		return
+	}
+	in.insertions = append(in.insertions, insertion{offset, code})
+}
+
+// start returns the offset of the first character of the given node including all opening parentheses, which are
+// dropped by the parser. Unlike Idx0 it handles postfix operations (whose Idx0 is the operator's position) and
+// statements whose keyword position isn't recorded.
+func (in *instrumenter) start(node ast.Node) int {
+	switch n := node.(type) {
+	case *ast.ExpressionStatement:
+		return in.start(n.Expression)
+	case *ast.UnaryExpression:
+		if n.Postfix {
+			return in.start(n.Operand)
+		}
+	case *ast.AssignExpression:
+		return in.start(n.Left)
+	case *ast.BinaryExpression:
+		return in.start(n.Left)
+	case *ast.BracketExpression:
+		return in.start(n.Left)
+	case *ast.DotExpression:
+		return in.start(n.Left)
+	case *ast.CallExpression:
+		return in.start(n.Callee)
+	case *ast.ConditionalExpression:
+		return in.start(n.Test)
+	case *ast.SequenceExpression:
+		return in.start(n.Sequence[0])
+	case *ast.IfStatement:
+		return in.keywordBefore(in.start(n.Test), "if")
+	case *ast.WhileStatement:
+		return in.keywordBefore(in.start(n.Test), "while")
+	case *ast.SwitchStatement:
+		return in.keywordBefore(in.start(n.Discriminant), "switch")
+	case *ast.WithStatement:
+		return in.keywordBefore(in.start(n.Object), "with")
+	case *ast.DoWhileStatement:
+		if block, ok := n.Body.(*ast.BlockStatement); ok {
+			return in.keywordBefore(in.offset(block.LeftBrace), "do")
+		}
+		return in.keywordBefore(in.start(n.Body), "do")
+	}
+
+	offset := in.offset(node.Idx0())
+	for offset > 0 && offset <= len(in.code) && in.code[offset-1] == '(' {
+		offset--
+	}
+	return offset
+}
+
+// keywordBefore returns the offset of the keyword which must precede the given offset, separated by whitespace and
+// opening parentheses only
+func (in *instrumenter) keywordBefore(offset int, keyword string) int {
+	if offset < 0 || offset > len(in.code) {
+		return offset
+	}
+	trimmed := strings.TrimRight(in.code[:offset], " \t\r\n(")
+	if !strings.HasSuffix(trimmed, keyword) {
+		in.err = fmt.Errorf("failed to instrument filter code: expected '%v' before position %v", keyword, offset)
+		return offset
+	}
+	return len(trimmed) - len(keyword)
+}
+
+// apply returns the code with all insertions
+func (in *instrumenter) apply() string {
+	sort.SliceStable(in.insertions, func(i, j int) bool { return in.insertions[i].offset < in.insertions[j].offset })
+
+	var sb strings.Builder
+	last := 0
+	for _, ins := range in.insertions {
+		sb.WriteString(in.code[last:ins.offset])
+		sb.WriteString(ins.code)
+		last = ins.offset
+	}
+	sb.WriteString(in.code[last:])
+
+	return sb.String()
+}
+
+// walkAST calls visit for every node which is reachable from the given value. Nodes are visited only once, even if
+// they are referenced multiple times (e.g. by declaration lists).
+func walkAST(v reflect.Value, visited map[uintptr]struct{}, visit func(node interface{})) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if _, ok := visited[v.Pointer()]; ok {
+			return
+		}
+		visited[v.Pointer()] = struct{}{}
+		if _, isFile := v.Interface().(*file.File); isFile {
+			return
+		}
+		if _, isNode := v.Interface().(ast.Node); isNode {
+			visit(v.Interface())
+		}
+		walkAST(v.Elem(), visited, visit)
+	case reflect.Interface:
+		if !v.IsNil() {
+			walkAST(v.Elem(), visited, visit)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue // Unexported
+			}
+			walkAST(v.Field(i), visited, visit)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkAST(v.Index(i), visited, visit)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{
+			name:     "block bodies",
+			code:     "for (const k in value) { if (k) continue }\nreturn [1].some(x => { return x })",
+			expected: "for (const k in value) {__kowlIteration(); if (k) continue }\nreturn [1].some(x => {__kowlIteration(); return x })",
+		},
+		{
+			name:     "statement bodies",
+			code:     "var i = 0\ndo i++; while (i < 3)\nwhile (i) if (a) i--; else (i)--\nreturn true",
+			expected: "var i = 0\ndo  if (__kowlIteration(), true) i++; while (i < 3)\nwhile (i)  if (__kowlIteration(), true) if (a) i--; else (i)--\nreturn true",
+		},
+		{
+			name:     "nested loops without keyword positions",
+			code:     "for (;;) while ((a)) ((b)).c++",
+			expected: "for (;;)  if (__kowlIteration(), true) while ((a))  if (__kowlIteration(), true) ((b)).c++",
+		},
+		{
+			name:     "functions except expression bodies",
+			code:     "function g(n) { return n ? g(n-1) : 0 }\nreturn [1].map(x => x * 2).length == g(1)",
+			expected: "function g(n) {__kowlIteration(); return n ? g(n-1) : 0 }\nreturn [1].map(x => x * 2).length == g(1)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instrumented, err := Instrument(test.code)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, instrumented)
+		})
+	}
+
+	_, err := Instrument("return (")
+	assert.Error(t, err)
+}
+
+func TestConfig_Limits(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, cfg.MessageTimeout, cfg.Limits(0).Timeout)
+	assert.Equal(t, cfg.MaxMessageTimeout, cfg.Limits(time.Hour).Timeout)
+	assert.Equal(t, time.Second, cfg.Limits(time.Second).Timeout)
+	assert.Equal(t, cfg.MaxIterationsPerMessage, cfg.Limits(0).MaxIterations)
+}
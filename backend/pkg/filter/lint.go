@@ -0,0 +1,148 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja/parser"
+)
+
+// Severity describes how severe a lint finding is. Findings with SeverityError will prevent a search from being
+// started, while warnings are only reported to the user.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a single issue found by the linter in the submitted filter code
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"` // 0 if the finding is not bound to a specific line
+	Message  string   `json:"message"`
+}
+
+// bannedConstruct is a pattern which we do not allow in filter code, because it's very likely to either block the
+// interpreter until the execution timeout kicks in or it tries to break out of the filter's scope.
+type bannedConstruct struct {
+	Rule    string
+	Pattern *regexp.Regexp
+	Message string
+}
+
+var bannedConstructs = []bannedConstruct{
+	{
+		Rule:    "no-infinite-while",
+		Pattern: regexp.MustCompile(`while\s*\(\s*(true|1|!0|!false)\s*\)`),
+		Message: "infinite while loops are not allowed",
+	},
+	{
+		Rule:    "no-infinite-for",
+		Pattern: regexp.MustCompile(`for\s*\(\s*[^;()]*;\s*;[^)]*\)`),
+		Message: "for loops without a condition are not allowed",
+	},
+	{
+		Rule:    "no-eval",
+		Pattern: regexp.MustCompile(`\beval\s*\(`),
+		Message: "eval() is not allowed",
+	},
+	{
+		Rule:    "no-function-constructor",
+		Pattern: regexp.MustCompile(`\bFunction\s*\(`),
+		Message: "creating functions via the Function constructor is not allowed",
+	},
+	{
+		Rule:    "no-iteration-hook",
+		Pattern: regexp.MustCompile(`\b` + IterationHook + `\b`),
+		Message: "the identifier " + IterationHook + " is reserved",
+	},
+}
+
+var returnPattern = regexp.MustCompile(`\breturn\b`)
+
+// FunctionParameters are the arguments which are passed into the filter function for each message. The submitted
+// filter code is used as the body of this function.
+const FunctionParameters = "partitionId, offset, timestamp, key, value, headers"
+
+// Lint runs a pre-execution check on the given filter code. It enforces the configured size limit, rejects banned
+// constructs (e.g. obvious infinite loops) and checks whether the code can be parsed at all. The returned findings
+// are sorted by the order they have been detected, not by line.
+func Lint(code string, cfg Config) []Finding {
+	findings := make([]Finding, 0)
+
+	if len(code) > cfg.MaxCodeSize {
+		findings = append(findings, Finding{
+			Rule:     "max-code-size",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("filter code is %d bytes long, but only %d bytes are allowed", len(code), cfg.MaxCodeSize),
+		})
+		// Do not bother running further checks on code we won't accept anyways
+		return findings
+	}
+
+	for i, line := range strings.Split(code, "\n") {
+		for _, construct := range bannedConstructs {
+			if construct.Pattern.MatchString(line) {
+				findings = append(findings, Finding{
+					Rule:     construct.Rule,
+					Severity: SeverityError,
+					Line:     i + 1,
+					Message:  construct.Message,
+				})
+			}
+		}
+	}
+
+	// The code is used as function body, hence we parse it the very same way the interpreter will do later
+	_, err := parser.ParseFunction(FunctionParameters, code)
+	if err != nil {
+		findings = append(findings, syntaxErrorFinding(err))
+	}
+
+	if !returnPattern.MatchString(code) {
+		findings = append(findings, Finding{
+			Rule:     "missing-return",
+			Severity: SeverityWarning,
+			Message:  "filter code does not contain a return statement, therefore no message will pass the filter",
+		})
+	}
+
+	return findings
+}
+
+// HasErrors returns true if at least one of the findings has the severity error
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func syntaxErrorFinding(err error) Finding {
+	errList, ok := err.(parser.ErrorList)
+	if !ok || len(errList) == 0 {
+		return Finding{Rule: "syntax", Severity: SeverityError, Message: err.Error()}
+	}
+
+	// Only the first syntax error is meaningful, all subsequent errors are usually caused by the first one
+	errList.Sort()
+	first := errList[0]
+
+	// ParseFunction puts the function header into the first line, hence the body starts at line 2
+	line := first.Position.Line - 1
+	if line < 1 {
+		line = 1
+	}
+
+	return Finding{
+		Rule:     "syntax",
+		Severity: SeverityError,
+		Line:     line,
+		Message:  first.Message,
+	}
+}
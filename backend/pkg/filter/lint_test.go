@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+
+	tt := []struct {
+		code          string
+		expectedRules []string
+		hasErrors     bool
+	}{
+		{`return value.id == 5`, []string{}, false},
+		{"if (offset > 10) {\n  return true\n}\nreturn false", []string{}, false},
+		{"while(true) {}\nreturn true", []string{"no-infinite-while"}, true},
+		{"while ( 1 ) { }\nreturn true", []string{"no-infinite-while"}, true},
+		{"for (;;) {}\nreturn true", []string{"no-infinite-for"}, true},
+		{"for (var i = 0; ; i++) {}\nreturn true", []string{"no-infinite-for"}, true},
+		{"for (var i = 0; i < 10; i++) {}\nreturn true", []string{}, false},
+		{`return eval("true")`, []string{"no-eval"}, true},
+		{`return new Function("return true")()`, []string{"no-function-constructor"}, true},
+		{"__kowlIteration = null\nreturn true", []string{"no-iteration-hook"}, true},
+		{`value.id == 5`, []string{"missing-return"}, false},
+		{"return (value.id == ", []string{"syntax"}, true},
+		{strings.Repeat("a", cfg.MaxCodeSize+1), []string{"max-code-size"}, true},
+	}
+
+	for i, table := range tt {
+		findings := Lint(table.code, cfg)
+		rules := make([]string, len(findings))
+		for j, f := range findings {
+			rules[j] = f.Rule
+		}
+		assert.Equal(t, table.expectedRules, rules, "expected other findings. Case: ", i)
+		assert.Equal(t, table.hasErrors, HasErrors(findings), "expected other error state. Case: ", i)
+	}
+}
+
+func TestLint_SyntaxErrorLine(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+
+	findings := Lint("var a = 1;\nvar b = ;\nreturn true", cfg)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, 2, findings[0].Line)
+}
@@ -0,0 +1,186 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TypingSamples are parsed message properties (as they are passed into the filter function) which are used to
+// infer the TypeScript types of keys, values and headers.
+type TypingSamples struct {
+	Keys    []interface{}
+	Values  []interface{}
+	Headers []map[string]interface{}
+}
+
+// GenerateTypings returns a TypeScript declaration of the filter function arguments whose key, value and header
+// types have been inferred from the given samples. It can be loaded into the frontend's code editor to offer
+// autocompletion for filter authors.
+func GenerateTypings(samples TypingSamples) string {
+	keyType := newTsType()
+	for _, k := range samples.Keys {
+		keyType.add(k)
+	}
+	valueType := newTsType()
+	for _, v := range samples.Values {
+		valueType.add(v)
+	}
+	headersType := newTsType()
+	for _, h := range samples.Headers {
+		headers := make(map[string]interface{}, len(h))
+		for k, v := range h {
+			headers[k] = v
+		}
+		headersType.add(headers)
+	}
+	if len(samples.Headers) == 0 {
+		headersType.add(map[string]interface{}{})
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// Generated by Kowl from a sample of recent messages. Types may be incomplete.\n\n")
+	sb.WriteString("/** Timestamp of the message. Methods of Go's time.Time are accessible. */\n")
+	sb.WriteString("declare interface GoTime {\n")
+	sb.WriteString("    Unix(): number;\n")
+	sb.WriteString("    UnixNano(): number;\n")
+	sb.WriteString("    Format(layout: string): string;\n")
+	sb.WriteString("    Before(t: GoTime): boolean;\n")
+	sb.WriteString("    After(t: GoTime): boolean;\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("/** Returns the value at a path like \"a.b[0].c\" or undefined if any part of it is missing */\n")
+	sb.WriteString("declare function find(obj: any, path: string): any;\n")
+	sb.WriteString("/** Reports whether the value converted to a string matches the regex (Go RE2 syntax) */\n")
+	sb.WriteString("declare function matches(value: any, regex: string): boolean;\n")
+	sb.WriteString("declare function base64decode(str: string): string;\n")
+	sb.WriteString("/** Reports whether from <= ts < to. Dates may be given as time, Date, unix milliseconds or RFC 3339 string. */\n")
+	sb.WriteString("declare function dateBetween(ts: GoTime | Date | number | string, from: GoTime | Date | number | string, to: GoTime | Date | number | string): boolean;\n\n")
+	sb.WriteString(fmt.Sprintf("declare type MessageKey = %v;\n\n", keyType.render(0)))
+	sb.WriteString(fmt.Sprintf("declare type MessageValue = %v;\n\n", valueType.render(0)))
+	sb.WriteString(fmt.Sprintf("declare type MessageHeaders = %v;\n\n", headersType.render(0)))
+	sb.WriteString("/** ID of the partition the message has been consumed from */\n")
+	sb.WriteString("declare const partitionId: number;\n")
+	sb.WriteString("/** Offset of the message within its partition */\n")
+	sb.WriteString("declare const offset: number;\n")
+	sb.WriteString("declare const timestamp: GoTime;\n")
+	sb.WriteString("declare const key: MessageKey;\n")
+	sb.WriteString("declare const value: MessageValue;\n")
+	sb.WriteString("/** Record headers where the header key is the property name */\n")
+	sb.WriteString("declare const headers: MessageHeaders;\n")
+
+	return sb.String()
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsType accumulates all observed values at a certain position of the sampled documents
+type tsType struct {
+	primitives map[string]bool // string, number, boolean, null, any
+
+	objectCount int
+	properties  map[string]*tsType
+	propCounts  map[string]int
+
+	isArray  bool
+	elements *tsType
+}
+
+func newTsType() *tsType {
+	return &tsType{
+		primitives: make(map[string]bool),
+		properties: make(map[string]*tsType),
+		propCounts: make(map[string]int),
+	}
+}
+
+func (t *tsType) add(v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		t.primitives["null"] = true
+	case string:
+		t.primitives["string"] = true
+	case bool:
+		t.primitives["boolean"] = true
+	case float64, float32, int, int32, int64:
+		t.primitives["number"] = true
+	case map[string]interface{}:
+		t.objectCount++
+		for k, propValue := range val {
+			if _, exists := t.properties[k]; !exists {
+				t.properties[k] = newTsType()
+			}
+			t.properties[k].add(propValue)
+			t.propCounts[k]++
+		}
+	case []interface{}:
+		t.isArray = true
+		if t.elements == nil {
+			t.elements = newTsType()
+		}
+		for _, elem := range val {
+			t.elements.add(elem)
+		}
+	default:
+		t.primitives["any"] = true
+	}
+}
+
+func (t *tsType) render(indent int) string {
+	if t.primitives["any"] {
+		return "any"
+	}
+
+	variants := make([]string, 0)
+	if t.objectCount > 0 {
+		variants = append(variants, t.renderObject(indent))
+	}
+	if t.isArray {
+		elementType := t.elements.render(indent)
+		if strings.Contains(elementType, "|") {
+			elementType = "(" + elementType + ")"
+		}
+		variants = append(variants, elementType+"[]")
+	}
+	for _, primitive := range []string{"string", "number", "boolean", "null"} {
+		if t.primitives[primitive] {
+			variants = append(variants, primitive)
+		}
+	}
+
+	if len(variants) == 0 {
+		// We haven't seen a single value at this position (e.g. empty array)
+		return "any"
+	}
+	return strings.Join(variants, " | ")
+}
+
+func (t *tsType) renderObject(indent int) string {
+	if len(t.properties) == 0 {
+		return "{}"
+	}
+
+	names := make([]string, 0, len(t.properties))
+	for name := range t.properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	padding := strings.Repeat("    ", indent+1)
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for _, name := range names {
+		propName := name
+		if !identifierPattern.MatchString(name) {
+			propName = fmt.Sprintf("%q", name)
+		}
+		optional := ""
+		if t.propCounts[name] < t.objectCount {
+			optional = "?"
+		}
+		sb.WriteString(fmt.Sprintf("%v%v%v: %v;\n", padding, propName, optional, t.properties[name].render(indent+1)))
+	}
+	sb.WriteString(strings.Repeat("    ", indent) + "}")
+
+	return sb.String()
+}
@@ -0,0 +1,35 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTsType_Render(t *testing.T) {
+	tt := []struct {
+		samples  []interface{}
+		expected string
+	}{
+		{[]interface{}{"a", "b"}, "string"},
+		{[]interface{}{"a", 1.0, nil}, "string | number | null"},
+		{[]interface{}{[]interface{}{1.0, "x"}}, "(string | number)[]"},
+		{[]interface{}{[]interface{}{}}, "any[]"},
+		{[]interface{}{[]byte("binary")}, "any"},
+		{
+			[]interface{}{
+				map[string]interface{}{"id": 1.0, "status": "ok", "nested": map[string]interface{}{"a": true}},
+				map[string]interface{}{"id": 2.0, "trace-id": "abc"},
+			},
+			"{\n    id: number;\n    nested?: {\n        a: boolean;\n    };\n    status?: string;\n    \"trace-id\"?: string;\n}",
+		},
+	}
+
+	for i, table := range tt {
+		typ := newTsType()
+		for _, s := range table.samples {
+			typ.add(s)
+		}
+		assert.Equal(t, table.expected, typ.render(0), "expected other rendered type. Case: ", i)
+	}
+}
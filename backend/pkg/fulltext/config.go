@@ -0,0 +1,69 @@
+package fulltext
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config for the full-text index of explicitly selected topics of the default cluster. The index is kept in memory,
+// hence it's meant for small or critical topics which are searched often. Keys and values are indexed as they are
+// decoded, without masking, so users whose messages are masked can't search indexed topics.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Topics whose records are indexed. There are no patterns, every topic must be opted in by its name.
+	Topics []string `yaml:"topics"`
+
+	// Retention is the time span of records which are indexed. On startup the indexer reads all records within the
+	// retention, older records are dropped from the index.
+	Retention time.Duration `yaml:"retention"`
+
+	// MaxRecordsPerTopic bounds the memory of each topic's index. The oldest records are dropped if it's full.
+	MaxRecordsPerTopic int `yaml:"maxRecordsPerTopic"`
+
+	// MaxTermsPerRecord bounds the number of distinct terms which are indexed for a single record
+	MaxTermsPerRecord int `yaml:"maxTermsPerRecord"`
+
+	// MaxPayloadBytes is the number of bytes of a key or value which are indexed, the remainder is ignored
+	MaxPayloadBytes int `yaml:"maxPayloadBytes"`
+
+	// RetryInterval is the time to wait before the indexing of a topic is restarted after an error
+	RetryInterval time.Duration `yaml:"retryInterval"`
+}
+
+// SetDefaults for the full-text index config
+func (c *Config) SetDefaults() {
+	c.Retention = 24 * time.Hour
+	c.MaxRecordsPerTopic = 100000
+	c.MaxTermsPerRecord = 500
+	c.MaxPayloadBytes = 64 * 1024
+	c.RetryInterval = 30 * time.Second
+}
+
+// Validate the full-text index config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Topics) == 0 {
+		return fmt.Errorf("at least one topic must be set")
+	}
+	if c.Retention < time.Minute {
+		return fmt.Errorf("retention must be at least 1m")
+	}
+	if c.MaxRecordsPerTopic <= 0 {
+		return fmt.Errorf("max records per topic must be greater than 0")
+	}
+	if c.MaxTermsPerRecord <= 0 {
+		return fmt.Errorf("max terms per record must be greater than 0")
+	}
+	if c.MaxPayloadBytes <= 0 {
+		return fmt.Errorf("max payload bytes must be greater than 0")
+	}
+	if c.RetryInterval < time.Second {
+		return fmt.Errorf("retry interval must be at least 1s")
+	}
+
+	return nil
+}
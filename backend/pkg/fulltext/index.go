@@ -0,0 +1,171 @@
+package fulltext
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Match is the location of a record which contains all searched terms
+type Match struct {
+	PartitionID int32     `json:"partitionId"`
+	Offset      int64     `json:"offset"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// recordID identifies a record within its topic
+type recordID struct {
+	PartitionID int32
+	Offset      int64
+}
+
+// indexedRecord is a record along with its terms, which are needed to remove it from the postings
+type indexedRecord struct {
+	Timestamp time.Time
+	Terms     []string
+}
+
+// topicIndex is an inverted index of a single topic's records. Records are dropped in the order they have been
+// added once the index is full, and by their timestamp once they are beyond the retention.
+type topicIndex struct {
+	maxRecords int
+
+	mutex    sync.RWMutex
+	records  map[recordID]*indexedRecord
+	order    []recordID // Oldest first
+	postings map[string]map[recordID]struct{}
+
+	// evictedUntil is the latest timestamp of all records which have been dropped before leaving the retention
+	evictedUntil time.Time
+}
+
+func newTopicIndex(maxRecords int) *topicIndex {
+	return &topicIndex{
+		maxRecords: maxRecords,
+		records:    make(map[recordID]*indexedRecord),
+		order:      make([]recordID, 0),
+		postings:   make(map[string]map[recordID]struct{}),
+	}
+}
+
+// add indexes the terms of a record, the oldest record is dropped if the index is full
+func (t *topicIndex) add(id recordID, timestamp time.Time, terms []string) {
+	if len(terms) == 0 {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, exists := t.records[id]; exists {
+		return
+	}
+	if len(t.order) >= t.maxRecords {
+		evicted := t.order[0]
+		t.order = t.order[1:]
+		if ts := t.records[evicted].Timestamp; ts.After(t.evictedUntil) {
+			t.evictedUntil = ts
+		}
+		t.remove(evicted)
+	}
+
+	t.records[id] = &indexedRecord{Timestamp: timestamp, Terms: terms}
+	t.order = append(t.order, id)
+	for _, term := range terms {
+		ids, ok := t.postings[term]
+		if !ok {
+			ids = make(map[recordID]struct{})
+			t.postings[term] = ids
+		}
+		ids[id] = struct{}{}
+	}
+}
+
+// prune drops all records which are older than the given time
+func (t *topicIndex) prune(olderThan time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	remaining := make([]recordID, 0, len(t.order))
+	for _, id := range t.order {
+		if t.records[id].Timestamp.Before(olderThan) {
+			t.remove(id)
+			continue
+		}
+		remaining = append(remaining, id)
+	}
+	t.order = remaining
+}
+
+// remove drops the record from the postings, the mutex must be held
+func (t *topicIndex) remove(id recordID) {
+	for _, term := range t.records[id].Terms {
+		delete(t.postings[term], id)
+		if len(t.postings[term]) == 0 {
+			delete(t.postings, term)
+		}
+	}
+	delete(t.records, id)
+}
+
+// search returns the records which contain all terms, the newest first, along with the total number of matches.
+// Limit caps the number of returned matches, 0 returns all.
+func (t *topicIndex) search(terms []string, limit int) ([]Match, int) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// Intersect the postings starting with the rarest term
+	postings := make([]map[recordID]struct{}, len(terms))
+	for i, term := range terms {
+		postings[i] = t.postings[term]
+		if len(postings[i]) == 0 {
+			return []Match{}, 0
+		}
+	}
+	sort.Slice(postings, func(i, j int) bool { return len(postings[i]) < len(postings[j]) })
+
+	matches := make([]Match, 0)
+	for id := range postings[0] {
+		containsAll := true
+		for _, ids := range postings[1:] {
+			if _, ok := ids[id]; !ok {
+				containsAll = false
+				break
+			}
+		}
+		if containsAll {
+			matches = append(matches, Match{PartitionID: id.PartitionID, Offset: id.Offset, Timestamp: t.records[id].Timestamp})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].Timestamp.Equal(matches[j].Timestamp) {
+			return matches[i].Timestamp.After(matches[j].Timestamp)
+		}
+		if matches[i].PartitionID != matches[j].PartitionID {
+			return matches[i].PartitionID < matches[j].PartitionID
+		}
+		return matches[i].Offset > matches[j].Offset
+	})
+
+	total := len(matches)
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, total
+}
+
+// stats returns the number of indexed records and distinct terms
+func (t *topicIndex) stats() (int, int) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return len(t.records), len(t.postings)
+}
+
+// lastEvicted returns the latest timestamp of all records which have been dropped because the index was full
+func (t *topicIndex) lastEvicted() time.Time {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.evictedUntil
+}
@@ -0,0 +1,157 @@
+package fulltext
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrTopicNotIndexed is returned for searches in topics which are not configured to be indexed
+	ErrTopicNotIndexed = errors.New("topic is not indexed")
+	// ErrEmptyQuery is returned if the query doesn't contain a single searchable term
+	ErrEmptyQuery = errors.New("query contains no searchable terms")
+)
+
+// SearchResult lists the records which contain all terms of the query, the newest first. Records older than
+// IndexedSince are not covered by the index. While the indexer is still reading the records within the retention,
+// which happens after startup, the result may be incomplete.
+type SearchResult struct {
+	TopicName     string    `json:"topicName"`
+	Terms         []string  `json:"terms"`
+	IndexedSince  time.Time `json:"indexedSince"`
+	IsBackfilling bool      `json:"isBackfilling"`
+	IndexedCount  int       `json:"indexedCount"`
+	TermCount     int       `json:"termCount"`
+	TotalMatches  int       `json:"totalMatches"`
+	Matches       []Match   `json:"matches"`
+}
+
+// indexedTopic is the index of a topic along with the tailer which feeds it
+type indexedTopic struct {
+	index  *topicIndex
+	tailer *kafka.TopicTailer
+}
+
+// Indexer tails the configured topics and indexes the terms of their keys and values
+type Indexer struct {
+	cfg      Config
+	protoSvc *proto.Service // May be nil if proto deserialization is disabled
+	topics   map[string]*indexedTopic
+}
+
+// NewIndexer creates the full-text indexer. The config is expected to be validated.
+func NewIndexer(cfg Config, kafkaSvc kafka.Cluster, protoSvc *proto.Service, logger *zap.Logger) *Indexer {
+	i := &Indexer{
+		cfg:      cfg,
+		protoSvc: protoSvc,
+		topics:   make(map[string]*indexedTopic, len(cfg.Topics)),
+	}
+	logger = logger.With(zap.String("source", "full_text_index"))
+	for _, topicName := range cfg.Topics {
+		topic := &indexedTopic{index: newTopicIndex(cfg.MaxRecordsPerTopic)}
+		topic.tailer = kafka.NewTopicTailer(kafkaSvc, topicName, cfg.Retention, cfg.RetryInterval, func(msg *sarama.ConsumerMessage) {
+			i.indexMessage(topic.index, msg)
+		}, logger)
+		i.topics[topicName] = topic
+	}
+
+	return i
+}
+
+// Start tails all configured topics and prunes expired records until the process exits. It's a no-op if the indexer
+// is nil, which is the case if the full-text index is disabled.
+func (i *Indexer) Start() {
+	if i == nil {
+		return
+	}
+
+	for _, topic := range i.topics {
+		topic.tailer.Start()
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			for _, topic := range i.topics {
+				topic.index.prune(time.Now().Add(-i.cfg.Retention))
+			}
+		}
+	}()
+}
+
+// Search returns the records of the topic which contain all words of the query, regardless of their case. Limit
+// caps the number of returned matches, 0 returns all.
+func (i *Indexer) Search(topicName string, query string, limit int) (*SearchResult, error) {
+	topic, ok := i.topics[topicName]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrTopicNotIndexed, topicName)
+	}
+	queryTerms := terms(query, i.cfg.MaxTermsPerRecord, make(map[string]struct{}))
+	if len(queryTerms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	indexedSince := time.Now().Add(-i.cfg.Retention)
+	if lastEvicted := topic.index.lastEvicted(); lastEvicted.After(indexedSince) {
+		indexedSince = lastEvicted
+	}
+	matches, total := topic.index.search(queryTerms, limit)
+	indexedCount, termCount := topic.index.stats()
+
+	return &SearchResult{
+		TopicName:     topicName,
+		Terms:         queryTerms,
+		IndexedSince:  indexedSince,
+		IsBackfilling: topic.tailer.IsBackfilling(),
+		IndexedCount:  indexedCount,
+		TermCount:     termCount,
+		TotalMatches:  total,
+		Matches:       matches,
+	}, nil
+}
+
+// indexMessage adds the terms of the message's key and value to the topic's index
+func (i *Indexer) indexMessage(index *topicIndex, msg *sarama.ConsumerMessage) {
+	if msg.Timestamp.Before(time.Now().Add(-i.cfg.Retention)) {
+		return
+	}
+
+	seen := make(map[string]struct{})
+	recordTerms := i.payloadTerms(msg.Topic, msg.Key, proto.RecordKey, seen)
+	recordTerms = append(recordTerms, i.payloadTerms(msg.Topic, msg.Value, proto.RecordValue, seen)...)
+	index.add(recordID{PartitionID: msg.Partition, Offset: msg.Offset}, msg.Timestamp, recordTerms)
+}
+
+// payloadTerms decodes the payload like the frontend does and returns its terms. Binary payloads have no terms.
+func (i *Indexer) payloadTerms(topicName string, payload []byte, property proto.RecordPropertyType, seen map[string]struct{}) []string {
+	if len(payload) == 0 {
+		return nil
+	}
+
+	if i.protoSvc != nil {
+		json, err := i.protoSvc.UnmarshalPayload(payload, topicName, property)
+		if err == nil {
+			return payloadTerms(i.truncate(json), true, i.cfg.MaxTermsPerRecord, seen)
+		}
+	}
+	text, isJSON := kafka.PayloadText(payload)
+	if text == nil {
+		return nil
+	}
+
+	// Truncated JSON can't be parsed, it's indexed as plain text instead
+	return payloadTerms(i.truncate(text), isJSON, i.cfg.MaxTermsPerRecord, seen)
+}
+
+func (i *Indexer) truncate(payload []byte) []byte {
+	if len(payload) > i.cfg.MaxPayloadBytes {
+		return payload[:i.cfg.MaxPayloadBytes]
+	}
+	return payload
+}
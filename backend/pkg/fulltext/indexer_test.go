@@ -0,0 +1,100 @@
+package fulltext
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPayloadTerms(t *testing.T) {
+	tt := []struct {
+		payload  string
+		isJSON   bool
+		maxTerms int
+		expected []string
+	}{
+		{"Order shipped to Berlin, order 4711", false, 10, []string{"order", "shipped", "to", "berlin", "4711"}},
+		{"a b c", false, 10, []string{}},
+		{`{"customer": {"name": "Jane Doe"}, "items": [{"sku": "X-100"}], "total": 12.50}`, true, 10, []string{"jane", "doe", "100", "12", "50"}},
+		{`{"name": "Jane`, true, 10, []string{"name", "jane"}},
+		{"one two three", false, 2, []string{"one", "two"}},
+	}
+
+	for i, table := range tt {
+		res := payloadTerms([]byte(table.payload), table.isJSON, table.maxTerms, make(map[string]struct{}))
+		assert.ElementsMatch(t, table.expected, res, "Case: ", i)
+	}
+}
+
+func TestTopicIndex(t *testing.T) {
+	now := time.Now()
+	idx := newTopicIndex(2)
+	idx.add(recordID{PartitionID: 0, Offset: 0}, now.Add(-2*time.Hour), []string{"order", "berlin"})
+	idx.add(recordID{PartitionID: 1, Offset: 0}, now.Add(-time.Hour), []string{"order", "paris"})
+
+	matches, total := idx.search([]string{"order"}, 1)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []Match{{PartitionID: 1, Offset: 0, Timestamp: now.Add(-time.Hour)}}, matches)
+	_, total = idx.search([]string{"order", "berlin"}, 0)
+	assert.Equal(t, 1, total)
+	_, total = idx.search([]string{"order", "rome"}, 0)
+	assert.Equal(t, 0, total)
+
+	// The oldest added record is evicted if the index is full
+	idx.add(recordID{PartitionID: 0, Offset: 1}, now, []string{"invoice", "rome"})
+	_, total = idx.search([]string{"berlin"}, 0)
+	assert.Equal(t, 0, total)
+	assert.Equal(t, now.Add(-2*time.Hour), idx.lastEvicted())
+
+	idx.prune(now.Add(-30 * time.Minute))
+	records, termCount := idx.stats()
+	assert.Equal(t, 1, records)
+	assert.Equal(t, 2, termCount)
+}
+
+func TestIndexerSearch(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 2, 1, nil, false))
+	for i, value := range []string{`{"city": "Berlin", "status": "shipped"}`, `{"city": "Paris", "status": "shipped"}`, "<order><city>Berlin</city></order>"} {
+		_, err := cluster.Produce(kafka.ProduceRecord{
+			TopicName:   "orders",
+			PartitionID: int32(i % 2),
+			Partitioner: kafka.PartitionerManual,
+			Value:       []byte(value),
+		}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+
+	cfg := Config{Enabled: true, Topics: []string{"orders"}}
+	cfg.SetDefaults()
+	require.NoError(t, cfg.Validate())
+	indexer := NewIndexer(cfg, cluster, nil, zap.NewNop())
+	indexer.Start()
+
+	var res *SearchResult
+	require.Eventually(t, func() bool {
+		var err error
+		res, err = indexer.Search("orders", "BERLIN", 0)
+		require.NoError(t, err)
+		return !res.IsBackfilling && res.TotalMatches == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, []string{"berlin"}, res.Terms)
+	assert.Equal(t, 3, res.IndexedCount)
+
+	res, err := indexer.Search("orders", "berlin shipped", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.TotalMatches)
+
+	_, err = indexer.Search("orders", "!", 0)
+	assert.True(t, errors.Is(err, ErrEmptyQuery))
+	_, err = indexer.Search("payments", "berlin", 0)
+	assert.True(t, errors.Is(err, ErrTopicNotIndexed))
+}
@@ -0,0 +1,73 @@
+package fulltext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/valyala/fastjson"
+)
+
+const (
+	minTermLength = 2  // Runes
+	maxTermLength = 64 // Runes, longer terms are likely ids or encoded blobs which are searched by prefix anyway
+)
+
+// terms splits the text into distinct lower case words and numbers. At most maxTerms terms are returned.
+func terms(text string, maxTerms int, seen map[string]struct{}) []string {
+	res := make([]string, 0)
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if len(seen) >= maxTerms {
+			break
+		}
+		length := utf8.RuneCountInString(word)
+		if length < minTermLength || length > maxTermLength {
+			continue
+		}
+		term := strings.ToLower(word)
+		if _, ok := seen[term]; ok {
+			continue
+		}
+		seen[term] = struct{}{}
+		res = append(res, term)
+	}
+	return res
+}
+
+// payloadTerms returns the terms of a decoded payload. Only the string and number values of JSON documents are
+// indexed, member names would match almost every record of a topic.
+func payloadTerms(text []byte, isJSON bool, maxTerms int, seen map[string]struct{}) []string {
+	if !isJSON {
+		return terms(string(text), maxTerms, seen)
+	}
+
+	v, err := fastjson.ParseBytes(text)
+	if err != nil {
+		return terms(string(text), maxTerms, seen)
+	}
+	res := make([]string, 0)
+	var visit func(v *fastjson.Value)
+	visit = func(v *fastjson.Value) {
+		switch v.Type() {
+		case fastjson.TypeObject:
+			o, _ := v.Object()
+			o.Visit(func(_ []byte, member *fastjson.Value) { visit(member) })
+		case fastjson.TypeArray:
+			items, _ := v.Array()
+			for _, item := range items {
+				visit(item)
+			}
+		case fastjson.TypeString:
+			s, _ := v.StringBytes()
+			res = append(res, terms(string(s), maxTerms, seen)...)
+		case fastjson.TypeNumber:
+			res = append(res, terms(v.String(), maxTerms, seen)...)
+		}
+	}
+	visit(v)
+
+	return res
+}
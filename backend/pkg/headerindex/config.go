@@ -0,0 +1,61 @@
+package headerindex
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config for the background indexer which tails the configured topics of the default cluster and remembers where
+// records with selected header values (e.g. a trace id) are located, so that they can be looked up without scanning
+// the topic
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Topics whose records are indexed
+	Topics []string `yaml:"topics"`
+
+	// HeaderKeys are the record headers whose values are indexed
+	HeaderKeys []string `yaml:"headerKeys"`
+
+	// Retention is the time span of records which are indexed. On startup the indexer reads all records within the
+	// retention, older entries are dropped from the index.
+	Retention time.Duration `yaml:"retention"`
+
+	// MaxEntries bounds the memory of the index. The oldest entries are dropped if the index is full.
+	MaxEntries int `yaml:"maxEntries"`
+
+	// RetryInterval is the time to wait before the indexing of a topic is restarted after an error
+	RetryInterval time.Duration `yaml:"retryInterval"`
+}
+
+// SetDefaults for the header index config
+func (c *Config) SetDefaults() {
+	c.Retention = 6 * time.Hour
+	c.MaxEntries = 1000000
+	c.RetryInterval = 30 * time.Second
+}
+
+// Validate the header index config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Topics) == 0 {
+		return fmt.Errorf("at least one topic must be set")
+	}
+	if len(c.HeaderKeys) == 0 {
+		return fmt.Errorf("at least one header key must be set")
+	}
+	if c.Retention < time.Minute {
+		return fmt.Errorf("retention must be at least 1m")
+	}
+	if c.MaxEntries <= 0 {
+		return fmt.Errorf("max entries must be greater than 0")
+	}
+	if c.RetryInterval < time.Second {
+		return fmt.Errorf("retry interval must be at least 1s")
+	}
+
+	return nil
+}
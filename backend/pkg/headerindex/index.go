@@ -0,0 +1,137 @@
+package headerindex
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Location of an indexed record within its topic
+type Location struct {
+	PartitionID int32     `json:"partitionId"`
+	Offset      int64     `json:"offset"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// indexKey identifies all records of a topic which carry the same value in the same header
+type indexKey struct {
+	TopicName string
+	HeaderKey string
+	Value     string
+}
+
+// indexEntry is a single indexed record, entries are kept in the order they have been added
+type indexEntry struct {
+	Key      indexKey
+	Location Location
+}
+
+// index keeps the locations of records by their header values in memory. Entries are dropped in the order they have
+// been added once the index is full, and by their timestamp once they are beyond the retention.
+type index struct {
+	maxEntries int
+
+	mutex     sync.RWMutex
+	locations map[indexKey][]Location
+	entries   []indexEntry // Oldest first
+
+	// evictedUntil is the latest timestamp of all entries which have been dropped before leaving the retention
+	evictedUntil time.Time
+}
+
+func newIndex(maxEntries int) *index {
+	return &index{
+		maxEntries: maxEntries,
+		locations:  make(map[indexKey][]Location),
+		entries:    make([]indexEntry, 0),
+	}
+}
+
+// add indexes a record, the oldest entry is dropped if the index is full
+func (i *index) add(key indexKey, location Location) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if len(i.entries) >= i.maxEntries {
+		evicted := i.entries[0]
+		i.entries = i.entries[1:]
+		i.remove(evicted)
+		if evicted.Location.Timestamp.After(i.evictedUntil) {
+			i.evictedUntil = evicted.Location.Timestamp
+		}
+	}
+	i.entries = append(i.entries, indexEntry{Key: key, Location: location})
+	i.locations[key] = append(i.locations[key], location)
+}
+
+// prune drops all entries which are older than the given time
+func (i *index) prune(olderThan time.Time) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	remaining := make([]indexEntry, 0, len(i.entries))
+	for _, entry := range i.entries {
+		if entry.Location.Timestamp.Before(olderThan) {
+			i.remove(entry)
+			continue
+		}
+		remaining = append(remaining, entry)
+	}
+	i.entries = remaining
+}
+
+// remove drops the entry's location, the mutex must be held
+func (i *index) remove(entry indexEntry) {
+	locations := i.locations[entry.Key]
+	for j, location := range locations {
+		if location == entry.Location {
+			locations = append(locations[:j], locations[j+1:]...)
+			break
+		}
+	}
+	if len(locations) == 0 {
+		delete(i.locations, entry.Key)
+		return
+	}
+	i.locations[entry.Key] = locations
+}
+
+// lookup returns the locations of all records with the header value, the newest first. Limit caps the number of
+// returned locations, 0 returns all.
+func (i *index) lookup(key indexKey, limit int) []Location {
+	i.mutex.RLock()
+	res := make([]Location, len(i.locations[key]))
+	copy(res, i.locations[key])
+	i.mutex.RUnlock()
+
+	sort.Slice(res, func(a, b int) bool {
+		if !res[a].Timestamp.Equal(res[b].Timestamp) {
+			return res[a].Timestamp.After(res[b].Timestamp)
+		}
+		if res[a].PartitionID != res[b].PartitionID {
+			return res[a].PartitionID < res[b].PartitionID
+		}
+		return res[a].Offset > res[b].Offset
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res
+}
+
+// size returns the number of indexed records
+func (i *index) size() int {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	return len(i.entries)
+}
+
+// lastEvicted returns the latest timestamp of all entries which have been dropped because the index was full
+func (i *index) lastEvicted() time.Time {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	return i.evictedUntil
+}
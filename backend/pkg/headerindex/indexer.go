@@ -0,0 +1,127 @@
+package headerindex
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrTopicNotIndexed is returned for lookups in topics which are not configured to be indexed
+	ErrTopicNotIndexed = errors.New("topic is not indexed")
+	// ErrHeaderKeyNotIndexed is returned for lookups of header keys which are not configured to be indexed
+	ErrHeaderKeyNotIndexed = errors.New("header key is not indexed")
+)
+
+// LookupResult lists the locations of all indexed records which carry the header value, the newest first. Records
+// older than IndexedSince are not covered by the index. While the indexer is still reading the records within the
+// retention, which happens after startup, the result may be incomplete.
+type LookupResult struct {
+	TopicName     string     `json:"topicName"`
+	HeaderKey     string     `json:"headerKey"`
+	Value         string     `json:"value"`
+	IndexedSince  time.Time  `json:"indexedSince"`
+	IsBackfilling bool       `json:"isBackfilling"`
+	Locations     []Location `json:"locations"`
+}
+
+// Indexer tails the configured topics and indexes the values of the configured record headers
+type Indexer struct {
+	cfg     Config
+	tailers map[string]*kafka.TopicTailer
+
+	index      *index
+	headerKeys map[string]struct{}
+}
+
+// NewIndexer creates the header indexer. The config is expected to be validated.
+func NewIndexer(cfg Config, kafkaSvc kafka.Cluster, logger *zap.Logger) *Indexer {
+	headerKeys := make(map[string]struct{}, len(cfg.HeaderKeys))
+	for _, key := range cfg.HeaderKeys {
+		headerKeys[key] = struct{}{}
+	}
+
+	i := &Indexer{
+		cfg:        cfg,
+		tailers:    make(map[string]*kafka.TopicTailer, len(cfg.Topics)),
+		index:      newIndex(cfg.MaxEntries),
+		headerKeys: headerKeys,
+	}
+	logger = logger.With(zap.String("source", "header_index"))
+	for _, topicName := range cfg.Topics {
+		i.tailers[topicName] = kafka.NewTopicTailer(kafkaSvc, topicName, cfg.Retention, cfg.RetryInterval, i.indexMessage, logger)
+	}
+
+	return i
+}
+
+// Start tails all configured topics and prunes expired entries until the process exits. It's a no-op if the
+// indexer is nil, which is the case if the header index is disabled.
+func (i *Indexer) Start() {
+	if i == nil {
+		return
+	}
+
+	for _, tailer := range i.tailers {
+		tailer.Start()
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			i.index.prune(time.Now().Add(-i.cfg.Retention))
+		}
+	}()
+}
+
+// Lookup returns the locations of all records in the topic whose header carries the value. Limit caps the number
+// of returned locations, 0 returns all.
+func (i *Indexer) Lookup(topicName string, headerKey string, value string, limit int) (*LookupResult, error) {
+	tailer, ok := i.tailers[topicName]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrTopicNotIndexed, topicName)
+	}
+	if _, ok := i.headerKeys[headerKey]; !ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrHeaderKeyNotIndexed, headerKey)
+	}
+
+	indexedSince := time.Now().Add(-i.cfg.Retention)
+	if lastEvicted := i.index.lastEvicted(); lastEvicted.After(indexedSince) {
+		indexedSince = lastEvicted
+	}
+
+	return &LookupResult{
+		TopicName:     topicName,
+		HeaderKey:     headerKey,
+		Value:         value,
+		IndexedSince:  indexedSince,
+		IsBackfilling: tailer.IsBackfilling(),
+		Locations:     i.index.lookup(indexKey{TopicName: topicName, HeaderKey: headerKey, Value: value}, limit),
+	}, nil
+}
+
+// indexMessage adds the message's values of all indexed headers to the index
+func (i *Indexer) indexMessage(msg *sarama.ConsumerMessage) {
+	location := Location{PartitionID: msg.Partition, Offset: msg.Offset, Timestamp: msg.Timestamp}
+	if location.Timestamp.After(time.Now().Add(-i.cfg.Retention)) {
+		indexed := make(map[indexKey]struct{})
+		for _, header := range msg.Headers {
+			if header == nil {
+				continue
+			}
+			if _, ok := i.headerKeys[string(header.Key)]; !ok {
+				continue
+			}
+			key := indexKey{TopicName: msg.Topic, HeaderKey: string(header.Key), Value: string(header.Value)}
+			if _, isDuplicate := indexed[key]; isDuplicate {
+				continue
+			}
+			indexed[key] = struct{}{}
+			i.index.add(key, location)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package headerindex
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIndex(t *testing.T) {
+	now := time.Now()
+	traceA := indexKey{TopicName: "orders", HeaderKey: "trace-id", Value: "a"}
+	traceB := indexKey{TopicName: "orders", HeaderKey: "trace-id", Value: "b"}
+
+	idx := newIndex(3)
+	idx.add(traceA, Location{PartitionID: 0, Offset: 1, Timestamp: now.Add(-3 * time.Hour)})
+	idx.add(traceA, Location{PartitionID: 1, Offset: 5, Timestamp: now.Add(-time.Hour)})
+	idx.add(traceB, Location{PartitionID: 0, Offset: 2, Timestamp: now.Add(-2 * time.Hour)})
+	assert.Equal(t, []Location{
+		{PartitionID: 1, Offset: 5, Timestamp: now.Add(-time.Hour)},
+		{PartitionID: 0, Offset: 1, Timestamp: now.Add(-3 * time.Hour)},
+	}, idx.lookup(traceA, 0))
+	assert.Len(t, idx.lookup(traceA, 1), 1)
+
+	// The oldest added entry is evicted if the index is full
+	idx.add(traceB, Location{PartitionID: 0, Offset: 3, Timestamp: now})
+	assert.Equal(t, 3, idx.size())
+	assert.Len(t, idx.lookup(traceA, 0), 1)
+	assert.Equal(t, now.Add(-3*time.Hour), idx.lastEvicted())
+
+	idx.prune(now.Add(-30 * time.Minute))
+	assert.Equal(t, 1, idx.size())
+	assert.Len(t, idx.lookup(traceB, 0), 1)
+	assert.Empty(t, idx.lookup(traceA, 0))
+	assert.NotContains(t, idx.locations, traceA)
+}
+
+func TestIndexerLookup(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 2, 1, nil, false))
+	for i, traceID := range []string{"a", "b", "a"} {
+		_, err := cluster.Produce(kafka.ProduceRecord{
+			TopicName:   "orders",
+			PartitionID: int32(i % 2),
+			Partitioner: kafka.PartitionerManual,
+			Value:       []byte("{}"),
+			Headers: []sarama.RecordHeader{
+				{Key: []byte("trace-id"), Value: []byte(traceID)},
+				{Key: []byte("other"), Value: []byte(traceID)},
+			},
+		}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+
+	cfg := Config{Enabled: true, Topics: []string{"orders"}, HeaderKeys: []string{"trace-id"}}
+	cfg.SetDefaults()
+	require.NoError(t, cfg.Validate())
+	indexer := NewIndexer(cfg, cluster, zap.NewNop())
+	indexer.Start()
+
+	var res *LookupResult
+	require.Eventually(t, func() bool {
+		var err error
+		res, err = indexer.Lookup("orders", "trace-id", "a", 0)
+		require.NoError(t, err)
+		return !res.IsBackfilling && len(res.Locations) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.ElementsMatch(t, []int32{0, 0}, []int32{res.Locations[0].PartitionID, res.Locations[1].PartitionID})
+
+	_, err := indexer.Lookup("orders", "other", "a", 0)
+	assert.True(t, errors.Is(err, ErrHeaderKeyNotIndexed))
+	_, err = indexer.Lookup("payments", "trace-id", "a", 0)
+	assert.True(t, errors.Is(err, ErrTopicNotIndexed))
+}
@@ -0,0 +1,210 @@
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	segmentField segmentKind = iota
+	segmentIndex
+	segmentWildcard
+)
+
+type segment struct {
+	kind  segmentKind
+	field string
+	index int // Negative indices count from the end of the array
+}
+
+// Path is a compiled JSONPath expression. The supported subset consists of the root ($), child members (.name or
+// ['name']), array indices ([0], [-1]) and wildcards (.* or [*]). Filters, slices, unions and recursive descent
+// are not supported.
+type Path struct {
+	expr     string
+	segments []segment
+	isMulti  bool
+}
+
+// Compile parses the given JSONPath expression
+func Compile(expr string) (*Path, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("expression must start with '$'")
+	}
+
+	p := &Path{expr: expr, segments: make([]segment, 0)}
+	i := 1
+	for i < len(expr) {
+		switch expr[i] {
+		case '.':
+			i++
+			if i < len(expr) && expr[i] == '.' {
+				return nil, fmt.Errorf("recursive descent at position %d is not supported", i)
+			}
+			if i < len(expr) && expr[i] == '*' {
+				p.segments = append(p.segments, segment{kind: segmentWildcard})
+				i++
+				continue
+			}
+			end := i
+			for end < len(expr) && expr[end] != '.' && expr[end] != '[' {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("missing member name at position %d", i)
+			}
+			p.segments = append(p.segments, segment{kind: segmentField, field: expr[i:end]})
+			i = end
+		case '[':
+			seg, next, err := parseBracket(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			p.segments = append(p.segments, seg)
+			i = next
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", expr[i], i)
+		}
+	}
+
+	for _, seg := range p.segments {
+		if seg.kind == segmentWildcard {
+			p.isMulti = true
+		}
+	}
+
+	return p, nil
+}
+
+// parseBracket parses the bracket segment which starts at the given position and returns the position after it
+func parseBracket(expr string, start int) (segment, int, error) {
+	i := start + 1
+	if i < len(expr) && (expr[i] == '\'' || expr[i] == '"') {
+		quote := expr[i]
+		end := strings.IndexByte(expr[i+1:], quote)
+		if end == -1 {
+			return segment{}, 0, fmt.Errorf("unterminated member name at position %d", i)
+		}
+		end += i + 1
+		if end+1 >= len(expr) || expr[end+1] != ']' {
+			return segment{}, 0, fmt.Errorf("missing ']' at position %d", end+1)
+		}
+		return segment{kind: segmentField, field: expr[i+1 : end]}, end + 2, nil
+	}
+
+	end := strings.IndexByte(expr[i:], ']')
+	if end == -1 {
+		return segment{}, 0, fmt.Errorf("missing ']' for '[' at position %d", start)
+	}
+	end += i
+	content := strings.TrimSpace(expr[i:end])
+	if content == "*" {
+		return segment{kind: segmentWildcard}, end + 1, nil
+	}
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return segment{}, 0, fmt.Errorf("unsupported selector '[%v]' at position %d", content, start)
+	}
+
+	return segment{kind: segmentIndex, index: index}, end + 1, nil
+}
+
+// String returns the expression the path has been compiled from
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Lookup evaluates the path against a document which has been decoded by encoding/json into interface{}. The second
+// return value is false if nothing matched. Paths containing wildcards return a slice of all matches, object
+// members are matched in key order.
+func (p *Path) Lookup(doc interface{}) (interface{}, bool) {
+	matches := []interface{}{doc}
+	for _, seg := range p.segments {
+		next := make([]interface{}, 0, len(matches))
+		for _, m := range matches {
+			next = appendMatches(next, m, seg)
+		}
+		matches = next
+	}
+
+	if p.isMulti {
+		return matches, len(matches) > 0
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	return matches[0], true
+}
+
+// Location is the concrete position of a value within a document. Each element is either a member name (string) or
+// an array index (int).
+type Location []interface{}
+
+// MatchesLocation returns true if the path selects the value at the given location. This allows evaluating paths
+// while streaming through a document. Negative indices never match, because the length of the array is unknown.
+func (p *Path) MatchesLocation(location Location) bool {
+	if len(location) != len(p.segments) {
+		return false
+	}
+
+	for i, seg := range p.segments {
+		switch seg.kind {
+		case segmentField:
+			if key, ok := location[i].(string); !ok || key != seg.field {
+				return false
+			}
+		case segmentIndex:
+			if index, ok := location[i].(int); !ok || index != seg.index {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// IsRoot returns true if the path selects the whole document
+func (p *Path) IsRoot() bool {
+	return len(p.segments) == 0
+}
+
+func appendMatches(matches []interface{}, value interface{}, seg segment) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch seg.kind {
+		case segmentField:
+			if child, ok := v[seg.field]; ok {
+				matches = append(matches, child)
+			}
+		case segmentWildcard:
+			// Members are matched in key order, so that the result is deterministic
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				matches = append(matches, v[key])
+			}
+		}
+	case []interface{}:
+		switch seg.kind {
+		case segmentIndex:
+			index := seg.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				matches = append(matches, v[index])
+			}
+		case segmentWildcard:
+			matches = append(matches, v...)
+		}
+	}
+
+	return matches
+}
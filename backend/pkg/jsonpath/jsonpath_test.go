@@ -0,0 +1,83 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathLookup(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"id": "order-1",
+		"customer": {"name": "Jane", "address.city": "Berlin"},
+		"items": [{"sku": "a", "qty": 1}, {"sku": "b", "qty": 2}],
+		"tags": {"y": "second", "x": "first"}
+	}`), &doc))
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+		found    bool
+	}{
+		{"$.id", "order-1", true},
+		{"$.customer.name", "Jane", true},
+		{"$['customer']['address.city']", "Berlin", true},
+		{`$.customer["name"]`, "Jane", true},
+		{"$.items[1].sku", "b", true},
+		{"$.items[-1].qty", float64(2), true},
+		{"$.items[*].sku", []interface{}{"a", "b"}, true},
+		{"$.tags.*", []interface{}{"first", "second"}, true},
+		{"$.items[5].sku", nil, false},
+		{"$.missing", nil, false},
+		{"$.id.nested", nil, false},
+		{"$.missing[*]", []interface{}{}, false},
+	}
+
+	for _, test := range tests {
+		path, err := Compile(test.expr)
+		require.NoError(t, err, test.expr)
+		actual, found := path.Lookup(doc)
+		assert.Equal(t, test.found, found, test.expr)
+		assert.Equal(t, test.expected, actual, test.expr)
+	}
+
+	root, err := Compile("$")
+	require.NoError(t, err)
+	actual, found := root.Lookup("text")
+	assert.True(t, found)
+	assert.Equal(t, "text", actual)
+}
+
+func TestCompileInvalid(t *testing.T) {
+	for _, expr := range []string{"", "id", "$.", "$..id", "$[", "$['id'", "$['id'x", "$[?(@.id)]", "$[0:2]", "$id"} {
+		_, err := Compile(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestPathMatchesLocation(t *testing.T) {
+	tests := []struct {
+		expr     string
+		location Location
+		expected bool
+	}{
+		{"$.customer.email", Location{"customer", "email"}, true},
+		{"$.customer.email", Location{"customer"}, false},
+		{"$.customer.email", Location{"customer", "email", "domain"}, false},
+		{"$.items[*].price", Location{"items", 3, "price"}, true},
+		{"$.items[1].price", Location{"items", 2, "price"}, false},
+		{"$.items[-1].price", Location{"items", 0, "price"}, false},
+		{"$.*.email", Location{"billing", "email"}, true},
+		{"$['0']", Location{0}, false},
+		{"$", Location{}, true},
+	}
+
+	for _, test := range tests {
+		path, err := Compile(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.expected, path.MatchesLocation(test.location), test.expr)
+	}
+}
@@ -0,0 +1,130 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Rewriter selects the values of a document which are replaced by Rewrite. Replacements are written as strings.
+type Rewriter interface {
+	// ReplaceValue is called with the location of each value before it's read. If it returns true, the value
+	// including all of its nested values is replaced.
+	ReplaceValue(location Location) (string, bool)
+
+	// ReplaceScalar is called for each string, number (json.Number), bool and null (nil) value which hasn't been
+	// replaced by ReplaceValue
+	ReplaceScalar(location Location, value interface{}) (string, bool)
+}
+
+// Rewrite streams the JSON document and replaces the values which are selected by the rewriter. The order of
+// members is kept and the document is written in compact form. The root value has an empty location.
+func Rewrite(doc []byte, rewriter Rewriter) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := rewriteValue(dec, &buf, Location{}, rewriter); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// rewriteValue writes the next value of the decoder, or its replacement if the rewriter selects it
+func rewriteValue(dec *json.Decoder, buf *bytes.Buffer, location Location, rewriter Rewriter) error {
+	if replacement, ok := rewriter.ReplaceValue(location); ok {
+		if err := skipValue(dec); err != nil {
+			return err
+		}
+		return WriteString(buf, replacement)
+	}
+
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := token.(json.Delim); ok {
+		isObject := delim == '{'
+		if isObject {
+			buf.WriteByte('{')
+		} else {
+			buf.WriteByte('[')
+		}
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			var element interface{} = i
+			if isObject {
+				keyToken, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, ok := keyToken.(string)
+				if !ok {
+					return fmt.Errorf("expected object key but got '%v'", keyToken)
+				}
+				if err := WriteString(buf, key); err != nil {
+					return err
+				}
+				buf.WriteByte(':')
+				element = key
+			}
+			if err := rewriteValue(dec, buf, append(location[:len(location):len(location)], element), rewriter); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // Closing delimiter
+			return err
+		}
+		if isObject {
+			buf.WriteByte('}')
+		} else {
+			buf.WriteByte(']')
+		}
+		return nil
+	}
+
+	if replacement, ok := rewriter.ReplaceScalar(location, token); ok {
+		return WriteString(buf, replacement)
+	}
+
+	switch t := token.(type) {
+	case string:
+		return WriteString(buf, t)
+	case json.Number:
+		buf.WriteString(t.String())
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case nil:
+		buf.WriteString("null")
+	}
+
+	return nil
+}
+
+// skipValue consumes the next value of the decoder including all nested values
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := token.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
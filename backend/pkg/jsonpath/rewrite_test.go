@@ -0,0 +1,40 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRewriter replaces the value of "secret" as a whole and doubles all numbers
+type testRewriter struct{}
+
+func (testRewriter) ReplaceValue(location Location) (string, bool) {
+	if len(location) > 0 && location[len(location)-1] == "secret" {
+		return "***", true
+	}
+	return "", false
+}
+
+func (testRewriter) ReplaceScalar(_ Location, value interface{}) (string, bool) {
+	if n, ok := value.(json.Number); ok {
+		return n.String() + n.String(), true
+	}
+	return "", false
+}
+
+func TestRewrite(t *testing.T) {
+	rewritten, err := Rewrite([]byte(`{
+		"id": 1, "name": "<Jane>", "secret": {"pin": 1234, "tags": ["a"]},
+		"items": [{"secret": null, "qty": 2.50}, true, null]
+	}`), testRewriter{})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"11","name":"<Jane>","secret":"***","items":[{"secret":"***","qty":"2.502.50"},true,null]}`, string(rewritten))
+
+	_, err = Rewrite([]byte(`{"id": 1`), testRewriter{})
+	assert.Error(t, err)
+	_, err = Rewrite([]byte(`{"id": 1} {}`), testRewriter{})
+	assert.Error(t, err)
+}
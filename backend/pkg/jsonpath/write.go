@@ -0,0 +1,18 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// WriteString writes the quoted string without escaping HTML characters
+func WriteString(buf *bytes.Buffer, s string) error {
+	var encoded bytes.Buffer
+	enc := json.NewEncoder(&encoded)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimRight(encoded.Bytes(), "\n"))
+	return nil
+}
@@ -0,0 +1,15 @@
+package jsonpath
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteString(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteString(&buf, `<a href="x">&</a>`+"\n\té"))
+	assert.Equal(t, `"<a href=\"x\">&</a>\n\t`+"é"+`"`, buf.String())
+}
@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// aclRequestVersion returns the highest ACL request version we support. Version 1 is required for pattern types
+// other than literal, which have been introduced in Kafka 2.0.
+func (s *Service) aclRequestVersion() int {
+	if s.Client.Config().Version.IsAtLeast(sarama.V2_0_0_0) {
+		return 1
+	}
+	return 0
+}
+
+// ListACLs returns all ACLs which match the given filter, grouped by resource
+func (s *Service) ListACLs(filter sarama.AclFilter) ([]*sarama.ResourceAcls, error) {
+	b, err := s.Client.Controller()
+	if err != nil {
+		return nil, fmt.Errorf("could not get cluster controller broker: %w", err)
+	}
+
+	res, err := b.DescribeAcls(&sarama.DescribeAclsRequest{Version: s.aclRequestVersion(), AclFilter: filter})
+	if err != nil {
+		return nil, err
+	}
+	if res.Err != sarama.ErrNoError {
+		return nil, aclError(res.Err, res.ErrMsg)
+	}
+
+	return res.ResourceAcls, nil
+}
+
+// CreateACLs creates the given ACLs. Creating an ACL that already exists is not considered an error by Kafka.
+func (s *Service) CreateACLs(creations []*sarama.AclCreation) error {
+	b, err := s.Client.Controller()
+	if err != nil {
+		return fmt.Errorf("could not get cluster controller broker: %w", err)
+	}
+
+	res, err := b.CreateAcls(&sarama.CreateAclsRequest{Version: int16(s.aclRequestVersion()), AclCreations: creations})
+	if err != nil {
+		return err
+	}
+	for _, creation := range res.AclCreationResponses {
+		if creation.Err != sarama.ErrNoError {
+			return aclError(creation.Err, creation.ErrMsg)
+		}
+	}
+
+	return nil
+}
+
+// DeleteACLs deletes all ACLs matching the given filter and returns the deleted ACLs
+func (s *Service) DeleteACLs(filter sarama.AclFilter) ([]*sarama.MatchingAcl, error) {
+	b, err := s.Client.Controller()
+	if err != nil {
+		return nil, fmt.Errorf("could not get cluster controller broker: %w", err)
+	}
+
+	res, err := b.DeleteAcls(&sarama.DeleteAclsRequest{Version: s.aclRequestVersion(), Filters: []*sarama.AclFilter{&filter}})
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]*sarama.MatchingAcl, 0)
+	for _, filterRes := range res.FilterResponses {
+		if filterRes.Err != sarama.ErrNoError {
+			return nil, aclError(filterRes.Err, filterRes.ErrMsg)
+		}
+		for _, match := range filterRes.MatchingAcls {
+			if match.Err != sarama.ErrNoError {
+				return nil, aclError(match.Err, match.ErrMsg)
+			}
+			deleted = append(deleted, match)
+		}
+	}
+
+	return deleted, nil
+}
+
+func aclError(kErr sarama.KError, msg *string) error {
+	if msg != nil && *msg != "" {
+		return fmt.Errorf("%w: %v", kErr, *msg)
+	}
+	return kErr
+}
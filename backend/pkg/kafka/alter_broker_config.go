@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+// AlterBrokerConfig replaces all dynamic per-broker configs of the given broker with the given config entries.
+// Configs which are not part of the entries are reset to their static or default value. Like DescribeBrokerConfigs
+// the request must be sent to the broker itself.
+func (s *Service) AlterBrokerConfig(brokerID int32, entries map[string]*string, validateOnly bool) error {
+	broker, err := s.findBrokerByID(brokerID)
+	if err != nil {
+		return err
+	}
+	err = broker.Open(s.Client.Config())
+	if err != nil && err != sarama.ErrAlreadyConnected {
+		return fmt.Errorf("failed to open connection to broker '%v': %w", brokerID, err)
+	}
+
+	req := &sarama.AlterConfigsRequest{
+		Resources: []*sarama.AlterConfigsResource{
+			{
+				Type:          sarama.BrokerResource,
+				Name:          strconv.Itoa(int(brokerID)),
+				ConfigEntries: entries,
+			},
+		},
+		ValidateOnly: validateOnly,
+	}
+	res, err := broker.AlterConfigs(req)
+	if err != nil {
+		return fmt.Errorf("failed to alter configs of broker '%v': %w", brokerID, err)
+	}
+
+	for _, resource := range res.Resources {
+		if resource.ErrorCode == int16(sarama.ErrNoError) {
+			continue
+		}
+		kErr := sarama.KError(resource.ErrorCode)
+		if resource.ErrorMsg != "" {
+			return fmt.Errorf("%w: %v", kErr, resource.ErrorMsg)
+		}
+		return kErr
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// BinaryEncoding is the representation of keys, values and headers which are neither valid UTF-8 nor deserializable
+type BinaryEncoding string
+
+const (
+	// BinaryEncodingBase64 returns binary payloads base64 encoded, which is the default
+	BinaryEncodingBase64 BinaryEncoding = "base64"
+
+	// BinaryEncodingHexDump returns binary payloads as hex dump with an offset, a hex and an ASCII column per 16
+	// bytes (like `hexdump -C`), so that framing issues can be spotted by eye
+	BinaryEncodingHexDump BinaryEncoding = "hexdump"
+)
+
+// Validate returns an error if the encoding is unknown, an empty encoding falls back to base64
+func (e BinaryEncoding) Validate() error {
+	switch e {
+	case "", BinaryEncodingBase64, BinaryEncodingHexDump:
+		return nil
+	default:
+		return fmt.Errorf("binary encoding must be either '%v' or '%v'", BinaryEncodingBase64, BinaryEncodingHexDump)
+	}
+}
+
+// encodeBinary converts a base64 encoded binary payload, as returned by detectValueType, into the given encoding.
+// All other payloads are returned unchanged.
+func encodeBinary(d DirectEmbedding, encoding BinaryEncoding) DirectEmbedding {
+	if d.ValueType != valueTypeBinary || encoding != BinaryEncodingHexDump {
+		return d
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(string(d.Value))
+	if err != nil {
+		return d
+	}
+
+	return DirectEmbedding{ValueType: valueTypeBinary, Value: []byte(hex.Dump(raw))}
+}
@@ -0,0 +1,34 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeBinary(t *testing.T) {
+	payload := []byte{0x00, 0x00, 0x00, 0x01, 'k', 'o', 'w', 'l', 0xff, 0xfe, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a}
+	_, binary := detectValueType(payload)
+	assert.Equal(t, valueTypeBinary, binary.ValueType)
+
+	// Base64 is the default and the input representation
+	assert.Equal(t, binary, encodeBinary(binary, ""))
+	assert.Equal(t, binary, encodeBinary(binary, BinaryEncodingBase64))
+
+	expected := "00000000  00 00 00 01 6b 6f 77 6c  ff fe 0a 00 00 00 00 00  |....kowl........|\n" +
+		"00000010  2a                                                |*|\n"
+	hexDump := encodeBinary(binary, BinaryEncodingHexDump)
+	assert.Equal(t, valueTypeBinary, hexDump.ValueType)
+	assert.Equal(t, expected, string(hexDump.Value))
+
+	// Payloads which are not binary must not be touched
+	_, text := detectValueType([]byte("kowl"))
+	assert.Equal(t, text, encodeBinary(text, BinaryEncodingHexDump))
+}
+
+func TestBinaryEncodingValidate(t *testing.T) {
+	assert.NoError(t, BinaryEncoding("").Validate())
+	assert.NoError(t, BinaryEncodingBase64.Validate())
+	assert.NoError(t, BinaryEncodingHexDump.Validate())
+	assert.Error(t, BinaryEncoding("hex").Validate())
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+)
+
+// ByteBudget bounds the bytes of consumed messages which are processed (decoded, filtered and rendered) at the same
+// time across all partition consumers, so that large searches can't exhaust the memory. Consumers wait until enough
+// bytes have been released by others. All methods can be called on a nil *ByteBudget, which is unlimited.
+type ByteBudget struct {
+	max int64
+
+	mutex sync.Mutex
+	used  int64
+
+	// released is closed and replaced whenever bytes are released, so that all waiting consumers check again
+	released chan struct{}
+}
+
+// NewByteBudget returns a budget of max bytes, it returns nil (unlimited) if max is not positive
+func NewByteBudget(max int64) *ByteBudget {
+	if max <= 0 {
+		return nil
+	}
+	return &ByteBudget{max: max, released: make(chan struct{})}
+}
+
+// Acquire blocks until n bytes are available or the context is done. Messages larger than the whole budget are
+// admitted once no other bytes are in use, so that they can't block a search forever.
+func (b *ByteBudget) Acquire(ctx context.Context, n int64) error {
+	if b == nil {
+		return nil
+	}
+
+	for {
+		b.mutex.Lock()
+		if b.used == 0 || b.used+n <= b.max {
+			b.used += n
+			b.mutex.Unlock()
+			return nil
+		}
+		released := b.released
+		b.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// Release returns n previously acquired bytes
+func (b *ByteBudget) Release(n int64) {
+	if b == nil || n == 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	b.used -= n
+	close(b.released)
+	b.released = make(chan struct{})
+	b.mutex.Unlock()
+}
+
+// InUse returns the number of currently acquired bytes
+func (b *ByteBudget) InUse() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.used
+}
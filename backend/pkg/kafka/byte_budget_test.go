@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteBudget(t *testing.T) {
+	var unlimited *ByteBudget
+	require.NoError(t, unlimited.Acquire(context.Background(), 1<<40))
+	unlimited.Release(1 << 40)
+	assert.Nil(t, NewByteBudget(0))
+
+	budget := NewByteBudget(100)
+	require.NoError(t, budget.Acquire(context.Background(), 60))
+	assert.Equal(t, int64(60), budget.InUse())
+
+	// Exceeding the budget waits until bytes have been released
+	acquired := make(chan error, 1)
+	go func() { acquired <- budget.Acquire(context.Background(), 50) }()
+	select {
+	case <-acquired:
+		t.Fatal("acquired bytes exceeding the budget")
+	case <-time.After(20 * time.Millisecond):
+	}
+	budget.Release(60)
+	require.NoError(t, <-acquired)
+	assert.Equal(t, int64(50), budget.InUse())
+
+	// Waiting is cancelled with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, budget.Acquire(ctx, 80))
+	assert.Equal(t, int64(50), budget.InUse())
+
+	// Messages larger than the whole budget are admitted once nothing else is in use
+	budget.Release(50)
+	require.NoError(t, budget.Acquire(context.Background(), 500))
+	assert.Equal(t, int64(500), budget.InUse())
+}
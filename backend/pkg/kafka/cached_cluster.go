@@ -0,0 +1,300 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/sync/singleflight"
+)
+
+type cacheKind int
+
+const (
+	cacheKindTopics cacheKind = iota
+	cacheKindConfigs
+	cacheKindWaterMarks
+)
+
+var allCacheKinds = []cacheKind{cacheKindTopics, cacheKindConfigs, cacheKindWaterMarks}
+
+type cacheEntry struct {
+	kind cacheKind
+	// topics the entry depends on, nil if it depends on all topics (e.g. the topic list)
+	topics  []string
+	value   interface{}
+	expires time.Time
+}
+
+// CachedCluster caches topic metadata, topic configs and water marks of the wrapped cluster, so that busy instances
+// don't send the same requests to the brokers again and again. Mutations through the CachedCluster invalidate the
+// affected entries, changes by other clients are visible once the TTL has elapsed. Errors are never cached.
+type CachedCluster struct {
+	Cluster
+	cfg MetadataCacheConfig
+
+	mutex   sync.Mutex
+	entries map[string]*cacheEntry
+	// generation is incremented on every invalidation, so that responses which have been requested before can't be
+	// cached or shared with callers which have to see the mutation
+	generation uint64
+
+	requests singleflight.Group
+}
+
+var _ Cluster = (*CachedCluster)(nil)
+
+// NewCachedCluster wraps the given cluster with a metadata cache
+func NewCachedCluster(cluster Cluster, cfg MetadataCacheConfig) *CachedCluster {
+	return &CachedCluster{
+		Cluster: cluster,
+		cfg:     cfg,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// get returns the cached value of the key or fetches it. Concurrent fetches of the same key are only sent once.
+func (c *CachedCluster) get(key string, kind cacheKind, topics []string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	if ttl <= 0 {
+		return fetch()
+	}
+
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	if ok && time.Now().Before(entry.expires) {
+		c.mutex.Unlock()
+		return entry.value, nil
+	}
+	generation := c.generation
+	c.mutex.Unlock()
+
+	value, err, _ := c.requests.Do(fmt.Sprintf("%v@%d", key, generation), func() (interface{}, error) {
+		value, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		c.mutex.Lock()
+		if c.generation == generation {
+			c.entries[key] = &cacheEntry{kind: kind, topics: topics, value: value, expires: time.Now().Add(ttl)}
+		}
+		c.mutex.Unlock()
+		return value, nil
+	})
+
+	return value, err
+}
+
+// invalidate removes all entries of the given kinds which depend on one of the topics, all topics if topics is nil
+func (c *CachedCluster) invalidate(kinds []cacheKind, topics []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	for key, entry := range c.entries {
+		if !containsCacheKind(kinds, entry.kind) {
+			continue
+		}
+		if topics == nil || entry.topics == nil || containsAnyString(entry.topics, topics) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// ListTopics returns the cached topic list
+func (c *CachedCluster) ListTopics() ([]*sarama.TopicMetadata, error) {
+	value, err := c.get("topics", cacheKindTopics, nil, c.cfg.TopicsTTL, func() (interface{}, error) {
+		return c.Cluster.ListTopics()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers may sort or filter the list
+	topics := value.([]*sarama.TopicMetadata)
+	return append([]*sarama.TopicMetadata(nil), topics...), nil
+}
+
+// DescribeTopicsConfigs returns the cached topic configs
+func (c *CachedCluster) DescribeTopicsConfigs(topicNames []string, configNames []string) (*sarama.DescribeConfigsResponse, error) {
+	key := fmt.Sprintf("configs/%v/%v", sortedKey(topicNames), sortedKey(configNames))
+	value, err := c.get(key, cacheKindConfigs, append([]string(nil), topicNames...), c.cfg.ConfigsTTL, func() (interface{}, error) {
+		return c.Cluster.DescribeTopicsConfigs(topicNames, configNames)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := *value.(*sarama.DescribeConfigsResponse)
+	res.Resources = append([]*sarama.ResourceResponse(nil), res.Resources...)
+	return &res, nil
+}
+
+// WaterMarks returns the cached water marks of the partitions
+func (c *CachedCluster) WaterMarks(topic string, partitionIDs []int32) (map[int32]*WaterMark, error) {
+	key := fmt.Sprintf("waterMarks/%v/%v", topic, sortedPartitionsKey(partitionIDs))
+	value, err := c.get(key, cacheKindWaterMarks, []string{topic}, c.cfg.WaterMarksTTL, func() (interface{}, error) {
+		return c.Cluster.WaterMarks(topic, partitionIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cached := value.(map[int32]*WaterMark)
+	marks := make(map[int32]*WaterMark, len(cached))
+	for partitionID, mark := range cached {
+		m := *mark
+		marks[partitionID] = &m
+	}
+	return marks, nil
+}
+
+// HighWaterMarks returns the cached high water marks of the partitions
+func (c *CachedCluster) HighWaterMarks(topicPartitions map[string][]int32) (map[string]map[int32]int64, error) {
+	topics := make([]string, 0, len(topicPartitions))
+	for topic := range topicPartitions {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	keyParts := make([]string, len(topics))
+	for i, topic := range topics {
+		keyParts[i] = topic + ":" + sortedPartitionsKey(topicPartitions[topic])
+	}
+
+	key := "highWaterMarks/" + strings.Join(keyParts, ",")
+	value, err := c.get(key, cacheKindWaterMarks, topics, c.cfg.WaterMarksTTL, func() (interface{}, error) {
+		return c.Cluster.HighWaterMarks(topicPartitions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cached := value.(map[string]map[int32]int64)
+	marks := make(map[string]map[int32]int64, len(cached))
+	for topic, partitions := range cached {
+		marks[topic] = make(map[int32]int64, len(partitions))
+		for partitionID, offset := range partitions {
+			marks[topic][partitionID] = offset
+		}
+	}
+	return marks, nil
+}
+
+// CreateTopic creates the topic and invalidates all entries which depend on it
+func (c *CachedCluster) CreateTopic(topicName string, partitionCount int32, replicationFactor int16, configs map[string]*string, validateOnly bool) error {
+	err := c.Cluster.CreateTopic(topicName, partitionCount, replicationFactor, configs, validateOnly)
+	if !validateOnly {
+		c.invalidate(allCacheKinds, []string{topicName})
+	}
+	return err
+}
+
+// DeleteTopic deletes the topic and invalidates all of its entries
+func (c *CachedCluster) DeleteTopic(topicName string) error {
+	err := c.Cluster.DeleteTopic(topicName)
+	c.invalidate(allCacheKinds, []string{topicName})
+	return err
+}
+
+// CreatePartitions adds partitions to the topic and invalidates its metadata and water marks
+func (c *CachedCluster) CreatePartitions(topicName string, partitionCount int32, validateOnly bool) error {
+	err := c.Cluster.CreatePartitions(topicName, partitionCount, validateOnly)
+	if !validateOnly {
+		c.invalidate([]cacheKind{cacheKindTopics, cacheKindWaterMarks}, []string{topicName})
+	}
+	return err
+}
+
+// AlterTopicConfig alters the topic config and invalidates the topic's configs
+func (c *CachedCluster) AlterTopicConfig(topicName string, entries map[string]*string, validateOnly bool) error {
+	err := c.Cluster.AlterTopicConfig(topicName, entries, validateOnly)
+	if !validateOnly {
+		c.invalidate([]cacheKind{cacheKindConfigs}, []string{topicName})
+	}
+	return err
+}
+
+// AlterBrokerConfig alters the broker config and invalidates the configs of all topics, which may inherit the
+// altered defaults
+func (c *CachedCluster) AlterBrokerConfig(brokerID int32, entries map[string]*string, validateOnly bool) error {
+	err := c.Cluster.AlterBrokerConfig(brokerID, entries, validateOnly)
+	if !validateOnly {
+		c.invalidate([]cacheKind{cacheKindConfigs}, nil)
+	}
+	return err
+}
+
+// Produce produces the record and invalidates the water marks of its topic
+func (c *CachedCluster) Produce(record ProduceRecord, opts ProduceOptions) (*ProduceResult, error) {
+	res, err := c.Cluster.Produce(record, opts)
+	c.invalidate([]cacheKind{cacheKindWaterMarks}, []string{record.TopicName})
+	return res, err
+}
+
+// ProduceTransaction produces the records and invalidates the water marks of their topics
+func (c *CachedCluster) ProduceTransaction(transactionalID string, records []ProduceRecord) ([]ProduceResult, error) {
+	res, err := c.Cluster.ProduceTransaction(transactionalID, records)
+	topicNames := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, record := range records {
+		if _, ok := seen[record.TopicName]; !ok {
+			seen[record.TopicName] = struct{}{}
+			topicNames = append(topicNames, record.TopicName)
+		}
+	}
+	c.invalidate([]cacheKind{cacheKindWaterMarks}, topicNames)
+	return res, err
+}
+
+// DeleteRecords deletes the records and invalidates the water marks of the topic
+func (c *CachedCluster) DeleteRecords(topic string, offsets map[int32]int64) error {
+	err := c.Cluster.DeleteRecords(topic, offsets)
+	c.invalidate([]cacheKind{cacheKindWaterMarks}, []string{topic})
+	return err
+}
+
+// ElectPreferredLeaders elects the leaders and invalidates the metadata of the topics, which includes the leaders
+func (c *CachedCluster) ElectPreferredLeaders(topicPartitions map[string][]int32) ([]PartitionElectionResult, error) {
+	res, err := c.Cluster.ElectPreferredLeaders(topicPartitions)
+	topics := make([]string, 0, len(topicPartitions))
+	for topic := range topicPartitions {
+		topics = append(topics, topic)
+	}
+	c.invalidate([]cacheKind{cacheKindTopics}, topics)
+	return res, err
+}
+
+func sortedKey(values []string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+func sortedPartitionsKey(partitionIDs []int32) string {
+	sorted := append([]int32(nil), partitionIDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return strings.Trim(fmt.Sprint(sorted), "[]")
+}
+
+func containsCacheKind(kinds []cacheKind, kind cacheKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAnyString(values []string, candidates []string) bool {
+	for _, v := range values {
+		for _, c := range candidates {
+			if v == c {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCluster counts the requests which reach the wrapped cluster
+type countingCluster struct {
+	Cluster
+	waterMarks int
+	topics     int
+}
+
+func (c *countingCluster) WaterMarks(topic string, partitionIDs []int32) (map[int32]*WaterMark, error) {
+	c.waterMarks++
+	return c.Cluster.WaterMarks(topic, partitionIDs)
+}
+
+func (c *countingCluster) ListTopics() ([]*sarama.TopicMetadata, error) {
+	c.topics++
+	return c.Cluster.ListTopics()
+}
+
+func TestCachedCluster(t *testing.T) {
+	cfg := MetadataCacheConfig{}
+	cfg.SetDefaults()
+	counting := &countingCluster{Cluster: newTestFakeCluster()}
+	cached := NewCachedCluster(counting, cfg)
+	require.NoError(t, cached.CreateTopic("test", 2, 1, nil, false))
+
+	marks, err := cached.WaterMarks("test", []int32{1, 0})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), marks[1].High)
+	_, err = cached.WaterMarks("test", []int32{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, counting.waterMarks)
+
+	// Producing through the cache invalidates the water marks of the topic
+	_, err = cached.Produce(ProduceRecord{TopicName: "test", PartitionID: 1, Partitioner: PartitionerManual, Value: []byte("v")}, ProduceOptions{})
+	require.NoError(t, err)
+	marks, err = cached.WaterMarks("test", []int32{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), marks[1].High)
+	assert.Equal(t, 2, counting.waterMarks)
+
+	// Transactions invalidate the water marks of all topics they have produced to
+	_, err = cached.ProduceTransaction("txn", []ProduceRecord{{TopicName: "test", PartitionID: 0, Partitioner: PartitionerManual, Value: []byte("v")}})
+	require.NoError(t, err)
+	marks, err = cached.WaterMarks("test", []int32{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), marks[0].High)
+	assert.Equal(t, 3, counting.waterMarks)
+
+	// Expired entries are fetched again
+	for _, entry := range cached.entries {
+		entry.expires = time.Now().Add(-time.Second)
+	}
+	_, err = cached.WaterMarks("test", []int32{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, 4, counting.waterMarks)
+
+	// Creating and deleting topics invalidates the topic list
+	topics, err := cached.ListTopics()
+	require.NoError(t, err)
+	require.NoError(t, cached.CreateTopic("other", 1, 1, nil, false))
+	withOther, err := cached.ListTopics()
+	require.NoError(t, err)
+	assert.Len(t, withOther, len(topics)+1)
+	_, err = cached.ListTopics()
+	require.NoError(t, err)
+	assert.Equal(t, 2, counting.topics)
+
+	require.NoError(t, cached.DeleteTopic("other"))
+	withoutOther, err := cached.ListTopics()
+	require.NoError(t, err)
+	assert.Len(t, withoutOther, len(topics))
+}
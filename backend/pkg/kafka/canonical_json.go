@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudhut/kowl/backend/pkg/jsonpath"
+)
+
+// CanonicalJSONOptions control how JSON based message keys, values and headers are rendered, so that messages can
+// be compared deterministically across searches and exports
+type CanonicalJSONOptions struct {
+	// SortKeys sorts the keys of all objects
+	SortKeys bool `json:"sortKeys"`
+
+	// NormalizeNumbers formats all numbers the same way regardless of their original representation, e.g. 1.50,
+	// 15e-1 and 1.5 are all rendered as 1.5. Integers are kept exact, other numbers are formatted as the shortest
+	// representation of their float64 value.
+	NormalizeNumbers bool `json:"normalizeNumbers"`
+
+	// StripWhitespace removes all insignificant whitespace. Values which have to be re-encoded to sort keys or to
+	// normalize numbers are indented with two spaces otherwise.
+	StripWhitespace bool `json:"stripWhitespace"`
+}
+
+// IsEnabled returns true if at least one option changes the rendered JSON
+func (o CanonicalJSONOptions) IsEnabled() bool {
+	return o.SortKeys || o.NormalizeNumbers || o.StripWhitespace
+}
+
+// CanonicalizeJSON renders the given JSON document according to the options
+func CanonicalizeJSON(value []byte, opts CanonicalJSONOptions) ([]byte, error) {
+	if !opts.SortKeys && !opts.NormalizeNumbers {
+		if !opts.StripWhitespace {
+			return value, nil
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, value); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(dec, &buf, opts); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+
+	if opts.StripWhitespace {
+		return buf.Bytes(), nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// writeCanonicalJSON writes the next value of the decoder in compact form
+func writeCanonicalJSON(dec *json.Decoder, buf *bytes.Buffer, opts CanonicalJSONOptions) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '[' {
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := writeCanonicalJSON(dec, buf, opts); err != nil {
+					return err
+				}
+			}
+			_, err := dec.Token() // Closing bracket
+			buf.WriteByte(']')
+			return err
+		}
+
+		// Object members are buffered, so that they can be sorted by key. Duplicate keys keep their original order.
+		type member struct {
+			Key   string
+			Value []byte
+		}
+		members := make([]member, 0)
+		for dec.More() {
+			keyToken, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := keyToken.(string)
+			if !ok {
+				return fmt.Errorf("expected object key but got '%v'", keyToken)
+			}
+			var value bytes.Buffer
+			if err := writeCanonicalJSON(dec, &value, opts); err != nil {
+				return err
+			}
+			members = append(members, member{Key: key, Value: value.Bytes()})
+		}
+		if _, err := dec.Token(); err != nil { // Closing brace
+			return err
+		}
+		if opts.SortKeys {
+			sort.SliceStable(members, func(i, j int) bool { return members[i].Key < members[j].Key })
+		}
+
+		buf.WriteByte('{')
+		for i, m := range members {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := jsonpath.WriteString(buf, m.Key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			buf.Write(m.Value)
+		}
+		buf.WriteByte('}')
+	case string:
+		return jsonpath.WriteString(buf, t)
+	case json.Number:
+		if opts.NormalizeNumbers {
+			buf.WriteString(normalizeJSONNumber(t))
+		} else {
+			buf.WriteString(t.String())
+		}
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case nil:
+		buf.WriteString("null")
+	}
+
+	return nil
+}
+
+// normalizeJSONNumber formats integers exactly and all other numbers like JavaScript would (shortest representation,
+// exponent notation outside of [1e-6, 1e21)). Numbers which can't be represented as float64 are kept as they are.
+func normalizeJSONNumber(n json.Number) string {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if i, ok := new(big.Int).SetString(s, 10); ok {
+			return i.String()
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	if f == 0 {
+		return "0"
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	// Go pads the exponent to two digits (1e-07), JavaScript doesn't (1e-7)
+	formatted := strconv.FormatFloat(f, 'e', -1, 64)
+	signEnd := strings.IndexByte(formatted, 'e') + 2
+	return formatted[:signEnd] + strings.TrimLeft(formatted[signEnd:], "0")
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalizeJSON(t *testing.T) {
+	input := []byte(`{ "b": [1.50, 2e2, -0], "a": {"z": "<x>", "y": null}, "c": 12345678901234567890 }`)
+
+	tests := []struct {
+		name     string
+		opts     CanonicalJSONOptions
+		expected string
+	}{
+		{
+			name:     "disabled",
+			opts:     CanonicalJSONOptions{},
+			expected: string(input),
+		},
+		{
+			name:     "strip whitespace only",
+			opts:     CanonicalJSONOptions{StripWhitespace: true},
+			expected: `{"b":[1.50,2e2,-0],"a":{"z":"<x>","y":null},"c":12345678901234567890}`,
+		},
+		{
+			name:     "all options",
+			opts:     CanonicalJSONOptions{SortKeys: true, NormalizeNumbers: true, StripWhitespace: true},
+			expected: `{"a":{"y":null,"z":"<x>"},"b":[1.5,200,0],"c":12345678901234567890}`,
+		},
+		{
+			name:     "sort keys pretty printed",
+			opts:     CanonicalJSONOptions{SortKeys: true},
+			expected: "{\n  \"a\": {\n    \"y\": null,\n    \"z\": \"<x>\"\n  },\n  \"b\": [\n    1.50,\n    2e2,\n    -0\n  ],\n  \"c\": 12345678901234567890\n}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := CanonicalizeJSON(input, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(actual))
+		})
+	}
+}
+
+func TestCanonicalizeJSON_Invalid(t *testing.T) {
+	opts := CanonicalJSONOptions{SortKeys: true, StripWhitespace: true}
+	for _, input := range []string{`{"a":`, `{"a":1} {"b":2}`, `[1,]`} {
+		_, err := CanonicalizeJSON([]byte(input), opts)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestNormalizeJSONNumber(t *testing.T) {
+	tests := map[string]string{
+		"0":        "0",
+		"-0.0":     "0",
+		"1.0":      "1",
+		"15e-1":    "1.5",
+		"1E21":     "1e+21",
+		"0.000001": "0.000001",
+		"1e-7":     "1e-7",
+		"-2.5e-10": "-2.5e-10",
+		"1e400":    "1e400", // Out of float64 range
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, normalizeJSONNumber(json.Number(input)), input)
+	}
+}
@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Cluster contains all operations which are required to serve the REST API. It is implemented by Service, which talks
+// to a real Kafka cluster, and by FakeCluster, which keeps all data in memory.
+type Cluster interface {
+	// Topics
+	ListTopics() ([]*sarama.TopicMetadata, error)
+	ListPartitions(topicName string) ([]int32, error)
+	CreateTopic(topicName string, partitionCount int32, replicationFactor int16, configs map[string]*string, validateOnly bool) error
+	DeleteTopic(topicName string) error
+	CreatePartitions(topicName string, partitionCount int32, validateOnly bool) error
+	DescribeTopicsConfigs(topicNames []string, configNames []string) (*sarama.DescribeConfigsResponse, error)
+	AlterTopicConfig(topicName string, entries map[string]*string, validateOnly bool) error
+
+	// Messages
+	NewConsumer(isolationLevel sarama.IsolationLevel) (sarama.Consumer, error)
+	WaterMarks(topic string, partitionIDs []int32) (map[int32]*WaterMark, error)
+	PartitionLeaders(topic string, partitionIDs []int32) (map[int32]int32, error)
+	HighWaterMarks(topicPartitions map[string][]int32) (map[string]map[int32]int64, error)
+	OffsetsForTimes(topic string, partitionIDs []int32, timestamp int64) (map[int32]int64, error)
+	MessageTimestamps(ctx context.Context, topic string, offsets map[int32]int64) (map[int32]time.Time, error)
+	Produce(record ProduceRecord, opts ProduceOptions) (*ProduceResult, error)
+	ProduceTransaction(transactionalID string, records []ProduceRecord) ([]ProduceResult, error)
+	DeleteRecords(topic string, offsets map[int32]int64) error
+
+	// Consumer groups
+	ListConsumerGroups(ctx context.Context) ([]string, error)
+	DescribeConsumerGroups(ctx context.Context, groups []string) (map[int32]*sarama.DescribeGroupsResponse, error)
+	ListConsumerGroupOffsets(group string) (*sarama.OffsetFetchResponse, error)
+	ListConsumerGroupOffsetsBulk(ctx context.Context, groups []string) (map[string]*sarama.OffsetFetchResponse, error)
+	CommitConsumerGroupOffsets(group string, offsets map[string]map[int32]int64) error
+	CommitConsumerGroupOffsetsWithMetadata(group string, offsets map[string]map[int32]GroupOffset) error
+	DeleteConsumerGroup(group string) error
+
+	// Cluster
+	ClusterID() string
+	DescribeCluster() (*ClusterMetadata, error)
+	DescribeBrokerConfigs(brokerID int32, configNames []string) ([]*sarama.ConfigEntry, error)
+	AlterBrokerConfig(brokerID int32, entries map[string]*string, validateOnly bool) error
+	DescribeLogDirs() map[int32]*LogDirResponse
+	ElectPreferredLeaders(topicPartitions map[string][]int32) ([]PartitionElectionResult, error)
+
+	// ACLs
+	ListACLs(filter sarama.AclFilter) ([]*sarama.ResourceAcls, error)
+	CreateACLs(creations []*sarama.AclCreation) error
+	DeleteACLs(filter sarama.AclFilter) ([]*sarama.MatchingAcl, error)
+
+	// SCRAM credentials
+	DescribeUserScramCredentials(users []string) ([]UserScramCredentials, error)
+	AlterUserScramCredentials(upsertions []ScramCredentialUpsertion, deletions []ScramCredentialDeletion) ([]ScramAlterationResult, error)
+}
+
+var _ Cluster = (*Service)(nil)
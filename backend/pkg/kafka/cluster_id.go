@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/collectors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// ErrClusterIDChanged is returned by the health check once a broker has reported a cluster id which differs from
+// the expected one. This happens if the bootstrap servers point to another cluster or the cluster has been rebuilt.
+var ErrClusterIDChanged = errors.New("kafka cluster id has changed")
+
+// clusterFingerprint remembers the id of the cluster which we have connected to first. Once a different cluster id
+// has been observed, the mismatch is kept until the process is restarted, so that operators have to confirm the
+// change rather than users silently operating on the wrong cluster.
+type clusterFingerprint struct {
+	mutex      sync.RWMutex
+	expectedID string // Configured cluster id, empty if any cluster id is accepted initially
+	clusterID  string // Empty until the first broker has reported a cluster id
+	mismatch   error
+}
+
+// observe records the cluster id reported by the given broker. An error is returned if the cluster id differs from
+// the expected or previously reported one. Empty cluster ids (brokers older than 0.10.1) are ignored.
+func (f *clusterFingerprint) observe(clusterID string, brokerAddr string) error {
+	if clusterID == "" {
+		return nil
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	expected := f.clusterID
+	if expected == "" {
+		expected = f.expectedID
+		f.clusterID = clusterID
+	}
+	if expected == "" || expected == clusterID {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: broker '%v' reported cluster id '%v', but expected '%v'", ErrClusterIDChanged, brokerAddr, clusterID, expected)
+	if f.mismatch == nil {
+		f.mismatch = err
+	}
+	return err
+}
+
+func (f *clusterFingerprint) id() string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.clusterID
+}
+
+func (f *clusterFingerprint) err() error {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.mismatch
+}
+
+// ClusterID returns the id of the Kafka cluster, it's empty if the cluster doesn't report ids (before 0.10.1) or if
+// no broker has been reached yet
+func (s *Service) ClusterID() string {
+	return s.fingerprint.id()
+}
+
+// metadataRequestVersion returns the highest metadata request version which we use. Version 2 is required for the
+// cluster id.
+func (s *Service) metadataRequestVersion() int16 {
+	if s.Client.Config().Version.IsAtLeast(sarama.V0_10_1_0) {
+		return 2
+	}
+	return 1
+}
+
+// checkClusterID compares the cluster id of a metadata response with the previously seen cluster id and logs
+// an error for every broker which reports a different one
+func (s *Service) checkClusterID(res *sarama.MetadataResponse, broker *sarama.Broker) {
+	if res.ClusterID == nil {
+		return
+	}
+	if err := s.fingerprint.observe(*res.ClusterID, broker.Addr()); err != nil {
+		s.clusterIDMismatch.Set(1)
+		s.Logger.Error("KAFKA CLUSTER ID HAS CHANGED, the configured brokers may belong to a different cluster than before. "+
+			"Kowl reports itself unhealthy until it has been restarted. Verify the bootstrap servers before using Kowl.",
+			zap.String("broker", broker.Addr()), zap.Error(err))
+	}
+}
+
+func newClusterIDMismatchGauge(namespace string) prometheus.Gauge {
+	return collectors.Register(prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "cluster_id_mismatch",
+		Help:      "1 if a broker has reported a different cluster id than the expected or first seen one, otherwise 0",
+	})).(prometheus.Gauge)
+}
@@ -0,0 +1,30 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterFingerprint(t *testing.T) {
+	f := &clusterFingerprint{}
+	assert.NoError(t, f.observe("", "broker-0"))
+	assert.Equal(t, "", f.id())
+	assert.NoError(t, f.observe("cluster-a", "broker-0"))
+	assert.NoError(t, f.observe("cluster-a", "broker-1"))
+	assert.NoError(t, f.err())
+
+	err := f.observe("cluster-b", "broker-2")
+	assert.True(t, errors.Is(err, ErrClusterIDChanged))
+	assert.Equal(t, "cluster-a", f.id())
+
+	// The mismatch is kept, even if the brokers report the original cluster id again
+	assert.NoError(t, f.observe("cluster-a", "broker-0"))
+	assert.True(t, errors.Is(f.err(), ErrClusterIDChanged))
+
+	f = &clusterFingerprint{expectedID: "cluster-a"}
+	assert.True(t, errors.Is(f.observe("cluster-b", "broker-0"), ErrClusterIDChanged))
+	assert.Equal(t, "cluster-b", f.id())
+	assert.True(t, errors.Is(f.err(), ErrClusterIDChanged))
+}
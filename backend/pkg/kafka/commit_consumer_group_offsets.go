@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// GroupOffset is a committed offset along with the metadata string the consumer attached to it
+type GroupOffset struct {
+	Offset   int64
+	Metadata string
+}
+
+// CommitConsumerGroupOffsets commits the given offsets (topic -> partitionID -> offset) on behalf of a consumer
+// group. Kafka only accepts these commits if the group has no active members.
+func (s *Service) CommitConsumerGroupOffsets(group string, offsets map[string]map[int32]int64) error {
+	return s.CommitConsumerGroupOffsetsWithMetadata(group, groupOffsetsWithoutMetadata(offsets))
+}
+
+// CommitConsumerGroupOffsetsWithMetadata commits the given offsets and their metadata (topic -> partitionID ->
+// offset) on behalf of a consumer group. Kafka only accepts these commits if the group has no active members.
+func (s *Service) CommitConsumerGroupOffsetsWithMetadata(group string, offsets map[string]map[int32]GroupOffset) error {
+	coordinator, err := s.Client.Coordinator(group)
+	if err != nil {
+		return err
+	}
+
+	// Generation -1 and an empty member id are used to commit offsets for groups without active members
+	req := &sarama.OffsetCommitRequest{
+		Version:                 2,
+		ConsumerGroup:           group,
+		ConsumerGroupGeneration: sarama.GroupGenerationUndefined,
+		ConsumerID:              "",
+		RetentionTime:           -1, // Use the broker's offsets.retention.minutes
+	}
+	for topic, partitions := range offsets {
+		for partitionID, offset := range partitions {
+			req.AddBlock(topic, partitionID, offset.Offset, 0, offset.Metadata)
+		}
+	}
+
+	res, err := coordinator.CommitOffset(req)
+	if err != nil {
+		return err
+	}
+	for topic, partitions := range res.Errors {
+		for partitionID, kErr := range partitions {
+			if kErr != sarama.ErrNoError {
+				return fmt.Errorf("failed to commit offset for topic '%v' partition '%v': %w", topic, partitionID, kErr)
+			}
+		}
+	}
+
+	return nil
+}
+
+func groupOffsetsWithoutMetadata(offsets map[string]map[int32]int64) map[string]map[int32]GroupOffset {
+	res := make(map[string]map[int32]GroupOffset, len(offsets))
+	for topic, partitions := range offsets {
+		res[topic] = make(map[int32]GroupOffset, len(partitions))
+		for partitionID, offset := range partitions {
+			res[topic][partitionID] = GroupOffset{Offset: offset}
+		}
+	}
+	return res
+}
@@ -13,8 +13,16 @@ type Config struct {
 	ClientID       string   `yaml:"clientId"`
 	ClusterVersion string   `yaml:"clusterVersion"`
 
+	// ExpectedClusterID is optional. If set, Kowl refuses to start if the brokers belong to a cluster with another id.
+	ExpectedClusterID string `yaml:"expectedClusterId"`
+
 	TLS  TLSConfig  `yaml:"tls"`
 	SASL SASLConfig `yaml:"sasl"`
+
+	MetadataCache MetadataCacheConfig `yaml:"metadataCache"`
+
+	// Fake replaces the cluster with an in-memory fake, all other settings are ignored if it is enabled
+	Fake FakeConfig `yaml:"fake"`
 }
 
 // RegisterFlags registers all nested config flags.
@@ -25,6 +33,14 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 
 // Validate the Kafka config
 func (c *Config) Validate() error {
+	if err := c.MetadataCache.Validate(); err != nil {
+		return fmt.Errorf("failed to validate metadata cache config: %w", err)
+	}
+
+	if c.Fake.Enabled {
+		return c.Fake.Validate()
+	}
+
 	if len(c.Brokers) == 0 {
 		return fmt.Errorf("you must specify at least one broker to connect to")
 	}
@@ -34,6 +50,17 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("failed to parse the given clusterVersion for Kafka: %w", err)
 	}
 
+	if c.TLS.Enabled {
+		if err := c.TLS.Validate(); err != nil {
+			return fmt.Errorf("failed to validate tls config: %w", err)
+		}
+	}
+	if c.SASL.Enabled {
+		if err := c.SASL.Validate(); err != nil {
+			return fmt.Errorf("failed to validate sasl config: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -42,5 +69,8 @@ func (c *Config) SetDefaults() {
 	c.ClientID = "kowl"
 	c.ClusterVersion = "1.0.0"
 
+	c.TLS.SetDefaults()
 	c.SASL.SetDefaults()
+	c.MetadataCache.SetDefaults()
+	c.Fake.SetDefaults()
 }
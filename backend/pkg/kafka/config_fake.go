@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+)
+
+// FakeConfig replaces the Kafka cluster with an in-memory fake which is seeded with demo topics, messages and
+// consumer groups. It is meant for frontend development, demos and integration tests, all data is lost on restart.
+type FakeConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Seed makes the generated demo data reproducible
+	Seed                 int64 `yaml:"seed"`
+	Brokers              int   `yaml:"brokers"`
+	Topics               int   `yaml:"topics"`
+	PartitionsPerTopic   int32 `yaml:"partitionsPerTopic"`
+	MessagesPerPartition int   `yaml:"messagesPerPartition"`
+
+	// Chaos settings to test how the frontend copes with slow or failing clusters
+	Latency   time.Duration `yaml:"latency"`   // Maximum random delay which is added to every request
+	ErrorRate float64       `yaml:"errorRate"` // Share of requests (0 to 1) which fail with ErrChaos
+}
+
+// SetDefaults for the fake cluster config
+func (c *FakeConfig) SetDefaults() {
+	c.Seed = 1
+	c.Brokers = 3
+	c.Topics = 5
+	c.PartitionsPerTopic = 3
+	c.MessagesPerPartition = 200
+}
+
+// Validate the fake cluster config
+func (c *FakeConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Brokers <= 0 {
+		return fmt.Errorf("fake cluster must have at least one broker")
+	}
+	if c.Topics < 0 || c.Topics > len(fakeTopicTemplates) {
+		return fmt.Errorf("fake cluster topics must be between 0 and %v", len(fakeTopicTemplates))
+	}
+	if c.PartitionsPerTopic <= 0 {
+		return fmt.Errorf("fake cluster partitions per topic must be greater than 0")
+	}
+	if c.MessagesPerPartition < 0 {
+		return fmt.Errorf("fake cluster messages per partition must not be negative")
+	}
+	if c.Latency < 0 {
+		return fmt.Errorf("fake cluster latency must not be negative")
+	}
+	if c.ErrorRate < 0 || c.ErrorRate > 1 {
+		return fmt.Errorf("fake cluster error rate must be between 0 and 1")
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetadataCacheConfig for the cache of topic metadata, topic configs and water marks, which is shared by all requests.
+// A TTL of 0 disables caching of the respective data.
+type MetadataCacheConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	TopicsTTL     time.Duration `yaml:"topicsTtl"`
+	ConfigsTTL    time.Duration `yaml:"configsTtl"`
+	WaterMarksTTL time.Duration `yaml:"waterMarksTtl"`
+}
+
+// SetDefaults for the metadata cache config
+func (c *MetadataCacheConfig) SetDefaults() {
+	c.Enabled = true
+	c.TopicsTTL = 10 * time.Second
+	c.ConfigsTTL = 30 * time.Second
+	c.WaterMarksTTL = 2 * time.Second
+}
+
+// Validate the metadata cache config
+func (c *MetadataCacheConfig) Validate() error {
+	if c.TopicsTTL < 0 || c.ConfigsTTL < 0 || c.WaterMarksTTL < 0 {
+		return fmt.Errorf("ttls must not be negative")
+	}
+
+	return nil
+}
@@ -15,27 +15,37 @@ type SASLConfig struct {
 	Password     string           `yaml:"password"`
 	Mechanism    string           `yaml:"mechanism"`
 	GSSAPIConfig SASLGSSAPIConfig `yaml:"gssapi"`
+	OAuth        SASLOAuthConfig  `yaml:"oauth"`
 }
 
 // RegisterFlags for all sensitive Kafka SASL configs.
 func (c *SASLConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&c.Password, "kafka.sasl.password", "", "SASL password")
 	c.GSSAPIConfig.RegisterFlags(f)
+	c.OAuth.RegisterFlags(f)
 }
 
 // SetDefaults for SASL Config
 func (c *SASLConfig) SetDefaults() {
 	c.UseHandshake = true
 	c.Mechanism = sarama.SASLTypePlaintext
+	c.GSSAPIConfig.SetDefaults()
+	c.OAuth.SetDefaults()
 }
 
 // Validate SASL config input
 func (c *SASLConfig) Validate() error {
 	switch c.Mechanism {
-	case sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512, sarama.SASLTypeGSSAPI:
+	case sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
 		// Valid and supported
+	case sarama.SASLTypeGSSAPI:
+		if err := c.GSSAPIConfig.Validate(c.Username); err != nil {
+			return fmt.Errorf("failed to validate gssapi config: %w", err)
+		}
 	case sarama.SASLTypeOAuth:
-		return fmt.Errorf("sasl mechanism '%v' is valid but not yet supported. Please submit an issue if you need it", c.Mechanism)
+		if err := c.OAuth.Validate(); err != nil {
+			return fmt.Errorf("failed to validate oauth config: %w", err)
+		}
 	default:
 		return fmt.Errorf("given sasl mechanism '%v' is invalid", c.Mechanism)
 	}
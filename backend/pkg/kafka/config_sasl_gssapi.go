@@ -2,20 +2,88 @@ package kafka
 
 import (
 	"flag"
+	"fmt"
+	"strings"
+)
+
+// Kerberos auth types for SASL/GSSAPI
+const (
+	GSSAPIAuthTypeKeyTab = "KEYTAB_AUTH"
+	GSSAPIAuthTypeUser   = "USER_AUTH"
 )
 
 // SASLGSSAPIConfig represents the Kafka Kerberos config
 type SASLGSSAPIConfig struct {
+	// AuthType is either 'KEYTAB_AUTH' (default) or 'USER_AUTH', which authenticates with the password
 	AuthType           string `yaml:"authType"`
 	KeyTabPath         string `yaml:"keyTabPath"`
 	KerberosConfigPath string `yaml:"kerberosConfigPath"`
-	ServiceName        string `yaml:"serviceName"`
-	Username           string `yaml:"username"`
-	Password           string `yaml:"password"`
-	Realm              string `yaml:"realm"`
+
+	// ServiceName is the primary of the brokers' principals, which is 'kafka' unless the brokers use another
+	// sasl.kerberos.service.name
+	ServiceName string `yaml:"serviceName"`
+
+	// Username is the client's principal. It may include the realm (e.g. kowl@EXAMPLE.COM), otherwise Realm must be
+	// set. If empty, the SASL username is used.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Realm    string `yaml:"realm"`
 }
 
 // RegisterFlags registers all sensitive Kerberos settings as flag
 func (c *SASLGSSAPIConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&c.Password, "kafka.sasl.gssapi.password", "", "Kerberos password if auth type user auth is used")
 }
+
+// SetDefaults for the Kerberos config
+func (c *SASLGSSAPIConfig) SetDefaults() {
+	c.AuthType = GSSAPIAuthTypeKeyTab
+	c.KerberosConfigPath = "/etc/krb5.conf"
+	c.ServiceName = "kafka"
+}
+
+// Validate the Kerberos config. The SASL username is used if no Kerberos username has been set. The keytab and the
+// krb5.conf are checked upfront, because sarama only reports them once a broker connection is established.
+func (c *SASLGSSAPIConfig) Validate(saslUsername string) error {
+	switch c.AuthType {
+	case GSSAPIAuthTypeKeyTab:
+		if !canReadFile(c.KeyTabPath) {
+			return fmt.Errorf("keytab '%v' does not exist or is not readable", c.KeyTabPath)
+		}
+	case GSSAPIAuthTypeUser:
+		if c.Password == "" {
+			return fmt.Errorf("password must be set if the auth type is '%v'", GSSAPIAuthTypeUser)
+		}
+	default:
+		return fmt.Errorf("auth type must be either '%v' or '%v'", GSSAPIAuthTypeKeyTab, GSSAPIAuthTypeUser)
+	}
+
+	if !canReadFile(c.KerberosConfigPath) {
+		return fmt.Errorf("kerberos config '%v' does not exist or is not readable", c.KerberosConfigPath)
+	}
+	if c.ServiceName == "" {
+		return fmt.Errorf("service name must be set")
+	}
+
+	username, realm := c.Principal(saslUsername)
+	if username == "" {
+		return fmt.Errorf("username must be set")
+	}
+	if realm == "" {
+		return fmt.Errorf("realm must be set, either separately or as part of the username (e.g. kowl@EXAMPLE.COM)")
+	}
+
+	return nil
+}
+
+// Principal returns the client's username and realm. The realm is taken from the username if it includes one.
+func (c *SASLGSSAPIConfig) Principal(saslUsername string) (string, string) {
+	username := c.Username
+	if username == "" {
+		username = saslUsername
+	}
+	if i := strings.LastIndexByte(username, '@'); i != -1 {
+		return username[:i], username[i+1:]
+	}
+	return username, c.Realm
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSASLGSSAPIConfigValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kowl-kerberos")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyTab := filepath.Join(dir, "kowl.keytab")
+	krb5 := filepath.Join(dir, "krb5.conf")
+	require.NoError(t, ioutil.WriteFile(keyTab, []byte{0x05, 0x02}, 0600))
+	require.NoError(t, ioutil.WriteFile(krb5, []byte("[libdefaults]\n"), 0600))
+
+	valid := func() SASLGSSAPIConfig {
+		cfg := SASLGSSAPIConfig{}
+		cfg.SetDefaults()
+		cfg.KeyTabPath = keyTab
+		cfg.KerberosConfigPath = krb5
+		cfg.Username = "kowl@EXAMPLE.COM"
+		return cfg
+	}
+
+	tt := []struct {
+		modify       func(cfg *SASLGSSAPIConfig)
+		saslUsername string
+		isErr        bool
+	}{
+		{func(cfg *SASLGSSAPIConfig) {}, "", false},
+		{func(cfg *SASLGSSAPIConfig) { cfg.KeyTabPath = filepath.Join(dir, "missing.keytab") }, "", true},
+		{func(cfg *SASLGSSAPIConfig) { cfg.KerberosConfigPath = "" }, "", true},
+		{func(cfg *SASLGSSAPIConfig) { cfg.ServiceName = "" }, "", true},
+		{func(cfg *SASLGSSAPIConfig) { cfg.AuthType = "USER_AUTH:" }, "", true},
+		{func(cfg *SASLGSSAPIConfig) { cfg.AuthType = GSSAPIAuthTypeUser }, "", true},
+		{func(cfg *SASLGSSAPIConfig) {
+			cfg.AuthType, cfg.Password, cfg.KeyTabPath = GSSAPIAuthTypeUser, "secret", ""
+		}, "", false},
+		{func(cfg *SASLGSSAPIConfig) { cfg.Username = "kowl" }, "", true},
+		{func(cfg *SASLGSSAPIConfig) { cfg.Username, cfg.Realm = "kowl", "EXAMPLE.COM" }, "", false},
+		{func(cfg *SASLGSSAPIConfig) { cfg.Username = "" }, "", true},
+		{func(cfg *SASLGSSAPIConfig) { cfg.Username = "" }, "kowl@EXAMPLE.COM", false},
+	}
+
+	for i, test := range tt {
+		cfg := valid()
+		test.modify(&cfg)
+		err := cfg.Validate(test.saslUsername)
+		if test.isErr {
+			assert.Error(t, err, "Case: ", i)
+		} else {
+			assert.NoError(t, err, "Case: ", i)
+		}
+	}
+}
+
+func TestSASLGSSAPIConfigPrincipal(t *testing.T) {
+	cfg := SASLGSSAPIConfig{Username: "kafka/kowl.example.com@EXAMPLE.COM", Realm: "OTHER.COM"}
+	username, realm := cfg.Principal("ignored")
+	assert.Equal(t, "kafka/kowl.example.com", username)
+	assert.Equal(t, "EXAMPLE.COM", realm)
+
+	cfg = SASLGSSAPIConfig{Realm: "EXAMPLE.COM"}
+	username, realm = cfg.Principal("kowl")
+	assert.Equal(t, "kowl", username)
+	assert.Equal(t, "EXAMPLE.COM", realm)
+}
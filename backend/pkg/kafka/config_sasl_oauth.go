@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Token providers for SASL/OAUTHBEARER
+const (
+	OAuthTokenProviderClientCredentials = "clientCredentials"
+	OAuthTokenProviderFile              = "file"
+	OAuthTokenProviderCommand           = "command"
+)
+
+// SASLOAuthConfig configures how access tokens for SASL/OAUTHBEARER are obtained. Tokens are cached until shortly
+// before they expire, they are only requested when a broker connection is established.
+type SASLOAuthConfig struct {
+	// TokenProvider is either 'clientCredentials', 'file' or 'command'
+	TokenProvider string `yaml:"tokenProvider"`
+
+	// TokenEndpoint, ClientID, ClientSecret and Scopes are used by the client credentials flow (RFC 6749)
+	TokenEndpoint string   `yaml:"tokenEndpoint"`
+	ClientID      string   `yaml:"clientId"`
+	ClientSecret  string   `yaml:"clientSecret"`
+	Scopes        []string `yaml:"scopes"`
+
+	// TokenFilepath is a file which contains the token only, e.g. rotated by a sidecar. It's read for each token.
+	TokenFilepath string `yaml:"tokenFilepath"`
+
+	// Command is executed with Args to get a token. It must print either the token only or a JSON token response
+	// with access_token and expires_in to stdout.
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+
+	// Extensions are sent to the broker along with the token (e.g. logicalCluster for some managed offerings)
+	Extensions map[string]string `yaml:"extensions"`
+
+	// Timeout bounds fetching a token, so that broker connections don't hang if the provider is unavailable
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RegisterFlags for sensitive OAuth configs
+func (c *SASLOAuthConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&c.ClientSecret, "kafka.sasl.oauth.client-secret", "", "Client secret for the OAuth client credentials flow")
+}
+
+// SetDefaults for the OAuth config
+func (c *SASLOAuthConfig) SetDefaults() {
+	c.TokenProvider = OAuthTokenProviderClientCredentials
+	c.Timeout = 10 * time.Second
+}
+
+// Validate the OAuth config
+func (c *SASLOAuthConfig) Validate() error {
+	switch c.TokenProvider {
+	case OAuthTokenProviderClientCredentials:
+		if _, err := url.ParseRequestURI(c.TokenEndpoint); err != nil {
+			return fmt.Errorf("token endpoint must be a valid URL: %w", err)
+		}
+		if c.ClientID == "" || c.ClientSecret == "" {
+			return fmt.Errorf("client id and client secret must be set for the client credentials flow")
+		}
+	case OAuthTokenProviderFile:
+		if c.TokenFilepath == "" {
+			return fmt.Errorf("token filepath must be set if the token provider is '%v'", OAuthTokenProviderFile)
+		}
+	case OAuthTokenProviderCommand:
+		if c.Command == "" {
+			return fmt.Errorf("command must be set if the token provider is '%v'", OAuthTokenProviderCommand)
+		}
+	default:
+		return fmt.Errorf("token provider must be one of '%v', '%v' or '%v'", OAuthTokenProviderClientCredentials, OAuthTokenProviderFile, OAuthTokenProviderCommand)
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package kafka
 
-import "flag"
+import (
+	"flag"
+	"fmt"
+	"time"
+)
 
 // TLSConfig to connect to Kafka via TLS
 type TLSConfig struct {
@@ -10,9 +14,26 @@ type TLSConfig struct {
 	KeyFilepath           string `yaml:"keyFilepath"`
 	Passphrase            string `yaml:"passphrase"`
 	InsecureSkipTLSVerify bool   `yaml:"insecureSkipTlsVerify"`
+
+	// ReloadInterval is the interval at which the client certificate and key files are checked for changes, so
+	// that rotated certificates are used without a restart. 0 disables reloading.
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
 }
 
 // RegisterFlags for all sensitive Kafka TLS configs
 func (c *TLSConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&c.Passphrase, "kafka.tls.passphrase", "", "Passphrase to optionally decrypt the private key")
 }
+
+// SetDefaults for the TLS config
+func (c *TLSConfig) SetDefaults() {
+	c.ReloadInterval = 30 * time.Second
+}
+
+// Validate the TLS config
+func (c *TLSConfig) Validate() error {
+	if c.ReloadInterval < 0 {
+		return fmt.Errorf("reload interval must not be negative")
+	}
+	return nil
+}
@@ -3,17 +3,21 @@ package kafka
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"time"
 
 	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/tlsreload"
+	"go.uber.org/zap"
 )
 
-// NewSaramaConfig creates a new sarama config which can be used for the admin client
-func NewSaramaConfig(cfg *Config) (*sarama.Config, error) {
+// NewSaramaConfig creates a new sarama config which can be used for the admin client. All broker connections are
+// established via the request log's dialer, so that Kafka requests can be logged on demand and their durations be
+// exported.
+func NewSaramaConfig(cfg *Config, requestLog *RequestLog, logger *zap.Logger) (*sarama.Config, error) {
 	sConfig := sarama.NewConfig()
 
 	// Configure general Kafka settings
@@ -25,6 +29,10 @@ func NewSaramaConfig(cfg *Config) (*sarama.Config, error) {
 	sConfig.Version = version
 	sConfig.Net.KeepAlive = 30 * time.Second
 
+	// Producer settings for produced messages, the partitioner can be chosen per record
+	sConfig.Producer.Return.Successes = true
+	sConfig.Producer.Partitioner = newRecordPartitioner
+
 	// Configure TLS
 	if cfg.TLS.Enabled {
 		sConfig.Net.TLS.Enable = true
@@ -48,12 +56,12 @@ func NewSaramaConfig(cfg *Config) (*sarama.Config, error) {
 				return nil, err
 			}
 
-			// Load Cert files and if necessary decrypt it too
-			certs, err := parseCerts(cfg.TLS.CertFilepath, cfg.TLS.KeyFilepath, cfg.TLS.Passphrase)
+			// Load Cert files and if necessary decrypt it too. Rotated files are reloaded for new connections.
+			cert, err := tlsreload.NewCertificate(cfg.TLS.CertFilepath, cfg.TLS.KeyFilepath, cfg.TLS.Passphrase, cfg.TLS.ReloadInterval, logger)
 			if err != nil {
 				return nil, err
 			}
-			sConfig.Net.TLS.Config.Certificates = certs
+			sConfig.Net.TLS.Config.GetClientCertificate = cert.GetClientCertificate
 		}
 	}
 
@@ -74,18 +82,48 @@ func NewSaramaConfig(cfg *Config) (*sarama.Config, error) {
 		case sarama.SASLTypeGSSAPI:
 			sConfig.Net.SASL.Mechanism = sarama.SASLTypeGSSAPI
 			switch cfg.SASL.GSSAPIConfig.AuthType {
-			case "USER_AUTH:":
+			case GSSAPIAuthTypeUser:
 				sConfig.Net.SASL.GSSAPI.AuthType = sarama.KRB5_USER_AUTH
-			case "KEYTAB_AUTH":
+				sConfig.Net.SASL.GSSAPI.Password = cfg.SASL.GSSAPIConfig.Password
+			case GSSAPIAuthTypeKeyTab:
 				sConfig.Net.SASL.GSSAPI.AuthType = sarama.KRB5_KEYTAB_AUTH
 				sConfig.Net.SASL.GSSAPI.KeyTabPath = cfg.SASL.GSSAPIConfig.KeyTabPath
 			}
-			sConfig.Net.SASL.GSSAPI.Username = cfg.SASL.Username
-			sConfig.Net.SASL.GSSAPI.Password = cfg.SASL.GSSAPIConfig.Password
+			username, realm := cfg.SASL.GSSAPIConfig.Principal(cfg.SASL.Username)
+			sConfig.Net.SASL.GSSAPI.Username = username
+			sConfig.Net.SASL.GSSAPI.Realm = realm
 			sConfig.Net.SASL.GSSAPI.KerberosConfigPath = cfg.SASL.GSSAPIConfig.KerberosConfigPath
 			sConfig.Net.SASL.GSSAPI.ServiceName = cfg.SASL.GSSAPIConfig.ServiceName
-			sConfig.Net.SASL.GSSAPI.Realm = cfg.SASL.GSSAPIConfig.Realm
+		case sarama.SASLTypeOAuth:
+			sConfig.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+			sConfig.Net.SASL.TokenProvider = newOAuthTokenProvider(cfg.SASL.OAuth)
+		}
+	}
+
+	// The request log requires Kafka's request framing for all requests. GSSAPI and SASL without handshake exchange
+	// the auth bytes without any framing, which we can't tell apart from regular requests.
+	switch {
+	case cfg.SASL.Enabled && cfg.SASL.Mechanism == sarama.SASLTypeGSSAPI:
+		requestLog.UnsupportedReason = "GSSAPI authentication is used"
+	case cfg.SASL.Enabled && !cfg.SASL.UseHandshake:
+		requestLog.UnsupportedReason = "SASL authentication without handshake is used"
+	default:
+		dialer := &requestLogDialer{
+			dialer: net.Dialer{
+				Timeout:   sConfig.Net.DialTimeout,
+				KeepAlive: sConfig.Net.KeepAlive,
+				LocalAddr: sConfig.Net.LocalAddr,
+			},
+			log: requestLog,
 		}
+		// Sarama bypasses the proxy dialer if TLS is enabled, hence the dialer takes care of TLS
+		if sConfig.Net.TLS.Enable {
+			dialer.tlsConfig = sConfig.Net.TLS.Config
+			sConfig.Net.TLS.Enable = false
+			sConfig.Net.TLS.Config = nil
+		}
+		sConfig.Net.Proxy.Enable = true
+		sConfig.Net.Proxy.Dialer = dialer
 	}
 
 	err = sConfig.Validate()
@@ -127,56 +165,3 @@ func canReadFile(path string) bool {
 
 	return true
 }
-
-// parseCert parses a TLS certificate from the CertFile and KeyFile.
-// If the key is encrypted, the passphrase will be used to decrypt it.
-func parseCerts(certFilePath string, keyFilePath string, passphrase string) ([]tls.Certificate, error) {
-	if certFilePath == "" && keyFilePath == "" {
-		return nil, fmt.Errorf("No file path specified for TLS key and certificate in environment variables")
-	}
-
-	errMessage := "Could not load X509 key pair. "
-
-	cert, err := ioutil.ReadFile(certFilePath)
-	if err != nil {
-		return nil, fmt.Errorf(errMessage, err)
-	}
-
-	prKeyBytes, err := ioutil.ReadFile(keyFilePath)
-	if err != nil {
-		return nil, fmt.Errorf(errMessage, err)
-	}
-
-	prKeyBytes, err = decodePrivateKey(prKeyBytes, passphrase)
-	if err != nil {
-		return nil, fmt.Errorf(errMessage, err)
-	}
-
-	tlsCert, err := tls.X509KeyPair(cert, prKeyBytes)
-	if err != nil {
-		return nil, fmt.Errorf(errMessage, err)
-	}
-
-	return []tls.Certificate{tlsCert}, nil
-}
-
-// getPrivateKey returns the private key in 'keyBytes', in a PEM-encoded format.
-// If the private key is encrypted, 'passphrase' is used to decrypted the private key.
-func decodePrivateKey(keyBytes []byte, passphrase string) ([]byte, error) {
-	// this section makes some small changes to code from notary/tuf/utils/x509.go
-	pemBlock, _ := pem.Decode(keyBytes)
-	if pemBlock == nil {
-		return nil, fmt.Errorf("no valid private key found")
-	}
-
-	var err error
-	if x509.IsEncryptedPEMBlock(pemBlock) {
-		keyBytes, err = x509.DecryptPEMBlock(pemBlock, []byte(passphrase))
-		if err != nil {
-			return nil, fmt.Errorf("private key is encrypted, but could not decrypt it: '%s'", err)
-		}
-		keyBytes = pem.EncodeToMemory(&pem.Block{Type: pemBlock.Type, Bytes: keyBytes})
-	}
-
-	return keyBytes, nil
-}
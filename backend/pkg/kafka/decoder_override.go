@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	xj "github.com/basgys/goxml2json"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"github.com/valyala/fastjson"
+)
+
+// DecoderType forces the decoder of keys or values, instead of detecting their type automatically
+type DecoderType string
+
+const (
+	DecoderAuto     DecoderType = ""
+	DecoderText     DecoderType = "text"
+	DecoderJSON     DecoderType = "json"
+	DecoderXML      DecoderType = "xml"
+	DecoderBinary   DecoderType = "binary"
+	DecoderProtobuf DecoderType = "protobuf" // Uses the proto type which has been mapped to the topic
+	DecoderAvro     DecoderType = "avro"     // Uses the schema registry
+)
+
+// DecoderOverride forces the decoder of all keys or values of a message search. Payloads which can't be decoded
+// with the forced decoder fall back to the automatic detection, their deserialization hints report the failure.
+type DecoderOverride struct {
+	Type DecoderType `json:"type"`
+
+	// SchemaID is the schema registry id of the Avro schema the payloads have been written with, in which case the
+	// payloads are expected to be plain Avro binary. If it's 0, the payloads must be framed with the schema
+	// registry's wire format and the schema id is taken from each payload's header.
+	SchemaID int `json:"schemaId"`
+}
+
+// Validate the decoder override
+func (d DecoderOverride) Validate() error {
+	switch d.Type {
+	case DecoderAuto, DecoderText, DecoderJSON, DecoderXML, DecoderBinary, DecoderProtobuf, DecoderAvro:
+	default:
+		return fmt.Errorf("decoder type '%v' is invalid, it must be one of: text, json, xml, binary, protobuf, avro", d.Type)
+	}
+	if d.SchemaID < 0 {
+		return fmt.Errorf("schema id must not be negative")
+	}
+	if d.SchemaID != 0 && d.Type != DecoderAvro {
+		return fmt.Errorf("a schema id can only be set for the avro decoder")
+	}
+
+	return nil
+}
+
+// decode deserializes the payload with the forced decoder, or detects its type if no decoder has been forced. If
+// the forced decoder fails, the payload's type is detected and the failed attempt is returned.
+func (p *PartitionConsumer) decode(ctx context.Context, value []byte, property proto.RecordPropertyType, override DecoderOverride) (valueType, DirectEmbedding, *DecoderAttempt) {
+	if override.Type == DecoderAuto || len(value) == 0 {
+		vType, embedding := p.getValue(value, property)
+		return vType, embedding, nil
+	}
+
+	vType, embedding, err := p.forceDecode(ctx, value, property, override)
+	if err == nil {
+		return vType, embedding, nil
+	}
+	p.Metrics.onDeserializationFailure(valueType(override.Type))
+
+	vType, embedding = p.getValue(value, property)
+	return vType, embedding, &DecoderAttempt{Decoder: string(override.Type), Result: DecoderFailed, Reason: err.Error()}
+}
+
+// forceDecode deserializes a non empty payload with the given decoder
+func (p *PartitionConsumer) forceDecode(ctx context.Context, value []byte, property proto.RecordPropertyType, override DecoderOverride) (valueType, DirectEmbedding, error) {
+	switch override.Type {
+	case DecoderText:
+		if offset := invalidUTF8Offset(value); offset >= 0 {
+			return "", DirectEmbedding{}, fmt.Errorf("invalid UTF-8 sequence at byte %v (0x%02x)", offset, value[offset])
+		}
+		return valueTypeText, DirectEmbedding{ValueType: valueTypeText, Value: value}, nil
+	case DecoderJSON:
+		trimmed := bytes.TrimSpace(value)
+		if err := fastjson.Validate(string(trimmed)); err != nil {
+			return "", DirectEmbedding{}, err
+		}
+		return valueTypeJSON, DirectEmbedding{ValueType: valueTypeJSON, Value: trimmed}, nil
+	case DecoderXML:
+		json, err := xj.Convert(strings.NewReader(string(value)))
+		if err != nil {
+			return "", DirectEmbedding{}, err
+		}
+		return valueTypeXML, DirectEmbedding{ValueType: valueTypeXML, Value: json.Bytes()}, nil
+	case DecoderBinary:
+		b64 := []byte(base64.StdEncoding.EncodeToString(value))
+		return valueTypeBinary, DirectEmbedding{ValueType: valueTypeBinary, Value: b64}, nil
+	case DecoderProtobuf:
+		if p.ProtoSvc == nil {
+			return "", DirectEmbedding{}, fmt.Errorf("proto deserialization is disabled")
+		}
+		json, err := p.ProtoSvc.UnmarshalPayload(value, p.TopicName, property)
+		if err == proto.ErrNoMapping {
+			return "", DirectEmbedding{}, fmt.Errorf("no proto type has been mapped to the topic")
+		}
+		if err != nil {
+			return "", DirectEmbedding{}, err
+		}
+		return valueTypeProtobuf, DirectEmbedding{ValueType: valueTypeProtobuf, Value: json}, nil
+	case DecoderAvro:
+		if p.SchemaSvc == nil {
+			return "", DirectEmbedding{}, fmt.Errorf("schema registry is not configured")
+		}
+		json, err := p.SchemaSvc.DecodeAvro(ctx, value, override.SchemaID)
+		if err != nil {
+			return "", DirectEmbedding{}, err
+		}
+		return valueTypeAvro, DirectEmbedding{ValueType: valueTypeAvro, Value: json}, nil
+	}
+
+	return "", DirectEmbedding{}, fmt.Errorf("unknown decoder type '%v'", override.Type)
+}
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDecoderOverrideValidate(t *testing.T) {
+	assert.NoError(t, DecoderOverride{}.Validate())
+	assert.NoError(t, DecoderOverride{Type: DecoderAvro, SchemaID: 7}.Validate())
+	assert.Error(t, DecoderOverride{Type: "yaml"}.Validate())
+	assert.Error(t, DecoderOverride{Type: DecoderText, SchemaID: 7}.Validate())
+	assert.Error(t, DecoderOverride{Type: DecoderAvro, SchemaID: -1}.Validate())
+}
+
+func TestDecode(t *testing.T) {
+	p := &PartitionConsumer{Logger: zap.NewNop(), TopicName: "orders"}
+	ctx := context.Background()
+
+	// Numeric strings are detected as text, but may be forced to be decoded as JSON
+	vType, d, failed := p.decode(ctx, []byte("42"), proto.RecordValue, DecoderOverride{})
+	assert.Equal(t, valueTypeText, vType)
+	assert.Nil(t, failed)
+	vType, d, failed = p.decode(ctx, []byte(" 42\n"), proto.RecordValue, DecoderOverride{Type: DecoderJSON})
+	assert.Equal(t, valueTypeJSON, vType)
+	assert.Equal(t, "42", string(d.Value))
+	assert.Nil(t, failed)
+
+	// JSON may be forced to be rendered as text
+	vType, _, failed = p.decode(ctx, []byte(`{"id": 1}`), proto.RecordValue, DecoderOverride{Type: DecoderText})
+	assert.Equal(t, valueTypeText, vType)
+	assert.Nil(t, failed)
+
+	// Failed decoders fall back to the automatic detection
+	vType, _, failed = p.decode(ctx, []byte{0xc3, 0x28}, proto.RecordValue, DecoderOverride{Type: DecoderText})
+	assert.Equal(t, valueTypeBinary, vType)
+	require.NotNil(t, failed)
+	assert.Equal(t, "text", failed.Decoder)
+	assert.Equal(t, DecoderFailed, failed.Result)
+
+	vType, _, failed = p.decode(ctx, []byte{0x00, 0x00, 0x00, 0x00, 0x2a}, proto.RecordValue, DecoderOverride{Type: DecoderAvro})
+	assert.Equal(t, valueTypeText, vType)
+	require.NotNil(t, failed)
+	assert.Equal(t, "schema registry is not configured", failed.Reason)
+}
+
+func TestGetDeserializationHintsWithDecoderOverride(t *testing.T) {
+	p := &PartitionConsumer{TopicName: "orders", ValueDecoder: DecoderOverride{Type: DecoderText}}
+	key := payloadDecoding{payload: []byte("order-1"), detected: valueTypeText}
+
+	// Payloads decoded by the forced decoder don't need hints, although they look like a different format
+	value := payloadDecoding{payload: []byte(`{"id": 1`), detected: valueTypeText}
+	assert.Nil(t, p.getDeserializationHints(key, value))
+
+	// The failed forced decoder is reported before the automatic detection's attempts
+	failed := &DecoderAttempt{Decoder: "text", Result: DecoderFailed, Reason: "invalid UTF-8 sequence at byte 0 (0xc3)"}
+	value = payloadDecoding{payload: []byte{0xc3, 0x28}, detected: valueTypeBinary, failed: failed}
+	hints := p.getDeserializationHints(key, value)
+	require.NotNil(t, hints)
+	assert.Nil(t, hints.Key)
+	require.NotNil(t, hints.Value)
+	assert.Equal(t, "binary", hints.Value.DetectedType)
+	assert.Equal(t, *failed, hints.Value.Attempts[0])
+}
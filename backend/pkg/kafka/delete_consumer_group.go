@@ -0,0 +1,7 @@
+package kafka
+
+// DeleteConsumerGroup deletes a consumer group along with its committed offsets. Kafka only deletes groups which
+// have no active members.
+func (s *Service) DeleteConsumerGroup(group string) error {
+	return s.Admin.DeleteConsumerGroup(group)
+}
@@ -0,0 +1,7 @@
+package kafka
+
+// DeleteRecords deletes all records of the given partitions before the given offsets by moving the partitions' log
+// start offsets. An offset of -1 deletes all records up to the high watermark.
+func (s *Service) DeleteRecords(topic string, offsets map[int32]int64) error {
+	return s.Admin.DeleteRecords(topic, offsets)
+}
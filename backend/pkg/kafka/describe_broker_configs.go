@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+// DescribeBrokerConfigs fetches the config entries of a single broker. Use an empty array for configNames to
+// fetch all configs. The request is sent to the described broker itself, as only it knows its dynamic configs.
+func (s *Service) DescribeBrokerConfigs(brokerID int32, configNames []string) ([]*sarama.ConfigEntry, error) {
+	broker, err := s.findBrokerByID(brokerID)
+	if err != nil {
+		return nil, err
+	}
+	err = broker.Open(s.Client.Config())
+	if err != nil && err != sarama.ErrAlreadyConnected {
+		return nil, fmt.Errorf("failed to open connection to broker '%v': %w", brokerID, err)
+	}
+
+	req := &sarama.DescribeConfigsRequest{
+		Version: 1, // Version 1 is required to get the config source
+		Resources: []*sarama.ConfigResource{
+			{
+				Type:        sarama.BrokerResource,
+				Name:        strconv.Itoa(int(brokerID)),
+				ConfigNames: configNames,
+			},
+		},
+	}
+	res, err := broker.DescribeConfigs(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe configs of broker '%v': %w", brokerID, err)
+	}
+
+	for _, resource := range res.Resources {
+		if resource.ErrorCode != 0 {
+			return nil, fmt.Errorf("failed to describe configs of broker '%v': %v", brokerID, resource.ErrorMsg)
+		}
+		return resource.Configs, nil
+	}
+
+	return nil, fmt.Errorf("describe configs response for broker '%v' is empty", brokerID)
+}
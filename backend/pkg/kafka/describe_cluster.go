@@ -6,17 +6,46 @@ import (
 	"github.com/Shopify/sarama"
 )
 
+// ClusterMetadata describes the brokers of a cluster along with the current controller
+type ClusterMetadata struct {
+	ClusterID    string // Empty for clusters before 0.10.1
+	ControllerID int32
+	Brokers      []BrokerMetadata
+}
+
+// BrokerMetadata describes a single broker as advertised in the cluster metadata
+type BrokerMetadata struct {
+	ID      int32
+	Address string
+	Rack    string
+}
+
 // DescribeCluster returns some generic information about the brokers in the given cluster
-func (s *Service) DescribeCluster() (*sarama.MetadataResponse, error) {
+func (s *Service) DescribeCluster() (*ClusterMetadata, error) {
 	controller, err := s.Client.Controller()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster controller from client: %w", err)
 	}
 
 	req := &sarama.MetadataRequest{
-		Version: 1, // Version 1 is required to fetch the ControllerID & RackID
+		Version: s.metadataRequestVersion(), // Version 1 is required to fetch the ControllerID & RackID, 2 for the ClusterID
 		Topics:  []string{},
 	}
+	metadata, err := controller.GetMetadata(req)
+	if err != nil {
+		return nil, err
+	}
+	s.checkClusterID(metadata, controller)
+
+	brokers := make([]BrokerMetadata, len(metadata.Brokers))
+	for i, b := range metadata.Brokers {
+		brokers[i] = BrokerMetadata{ID: b.ID(), Address: b.Addr(), Rack: b.Rack()}
+	}
+
+	clusterID := ""
+	if metadata.ClusterID != nil {
+		clusterID = *metadata.ClusterID
+	}
 
-	return controller.GetMetadata(req)
+	return &ClusterMetadata{ClusterID: clusterID, ControllerID: metadata.ControllerID, Brokers: brokers}, nil
 }
@@ -0,0 +1,112 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// Error codes of leader elections which sarama doesn't know
+const (
+	ErrEligibleLeadersNotAvailable sarama.KError = 83
+	ErrElectionNotNeeded           sarama.KError = 84
+)
+
+// electLeadersTimeout is the time the controller may take to complete the elections
+const electLeadersTimeout = 30000 // ms
+
+// PartitionElectionResult is the outcome of a leader election for a single partition. Err is ErrElectionNotNeeded
+// if the preferred replica already is the leader.
+type PartitionElectionResult struct {
+	TopicName   string
+	PartitionID int32
+	Err         sarama.KError
+	ErrMessage  string
+}
+
+// ElectPreferredLeaders asks the controller to move the leadership of the given partitions to their preferred
+// replica (the first replica), e.g. after a broker restart. All partitions of the cluster are elected if
+// topicPartitions is nil. The ElectLeaders API requires Kafka 2.2 or newer.
+func (s *Service) ElectPreferredLeaders(topicPartitions map[string][]int32) ([]PartitionElectionResult, error) {
+	controller, err := s.Client.Controller()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster controller: %w", err)
+	}
+
+	// Sarama doesn't implement the ElectLeaders API. Version 2 is the first flexible version, which we don't encode.
+	version, isSupported, err := rawRequestVersion(controller, apiKeyElectLeaders, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !isSupported {
+		return nil, fmt.Errorf("%w: the cluster doesn't support electing leaders, Kafka 2.2 or newer is required", sarama.ErrUnsupportedVersion)
+	}
+
+	res, err := s.sendRawRequest(controller.Addr(), apiKeyElectLeaders, version, false, encodeElectLeadersRequest(version, topicPartitions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to elect leaders: %w", err)
+	}
+
+	return decodeElectLeadersResponse(version, res)
+}
+
+// encodeElectLeadersRequest encodes an ElectLeaders request in version 0 or 1 for preferred elections
+func encodeElectLeadersRequest(version int16, topicPartitions map[string][]int32) []byte {
+	e := &rawEncoder{}
+	if version >= 1 {
+		e.putInt8(0) // Election type preferred
+	}
+	if topicPartitions == nil {
+		e.putInt32(-1) // Null array elects all partitions
+	} else {
+		topicNames := make([]string, 0, len(topicPartitions))
+		for topicName := range topicPartitions {
+			topicNames = append(topicNames, topicName)
+		}
+		sort.Strings(topicNames)
+
+		e.putInt32(int32(len(topicNames)))
+		for _, topicName := range topicNames {
+			e.putString(topicName)
+			e.putInt32(int32(len(topicPartitions[topicName])))
+			for _, partitionID := range topicPartitions[topicName] {
+				e.putInt32(partitionID)
+			}
+		}
+	}
+	e.putInt32(electLeadersTimeout)
+
+	return e.bytes()
+}
+
+// decodeElectLeadersResponse decodes an ElectLeaders response in version 0 or 1
+func decodeElectLeadersResponse(version int16, res []byte) ([]PartitionElectionResult, error) {
+	d := &rawDecoder{b: res}
+	d.int32() // Throttle time
+	if version >= 1 {
+		if errCode := sarama.KError(d.int16()); errCode != sarama.ErrNoError && d.err == nil {
+			return nil, fmt.Errorf("failed to elect leaders: %w", errCode)
+		}
+	}
+
+	results := make([]PartitionElectionResult, 0)
+	topicCount := d.arrayLength()
+	for i := 0; i < topicCount; i++ {
+		topicName := d.string()
+		partitionCount := d.arrayLength()
+		for j := 0; j < partitionCount; j++ {
+			results = append(results, PartitionElectionResult{
+				TopicName:   topicName,
+				PartitionID: d.int32(),
+				Err:         sarama.KError(d.int16()),
+				ErrMessage:  d.nullableString(),
+			})
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to decode elect leaders response: %w", d.err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeElectLeadersRequest(t *testing.T) {
+	req := encodeElectLeadersRequest(1, map[string][]int32{"orders": {0, 2}})
+	expected := []byte{
+		0,          // Election type preferred
+		0, 0, 0, 1, // One topic
+		0, 6, 'o', 'r', 'd', 'e', 'r', 's',
+		0, 0, 0, 2, // Two partitions
+		0, 0, 0, 0,
+		0, 0, 0, 2,
+		0, 0, 0x75, 0x30, // Timeout 30000ms
+	}
+	assert.Equal(t, expected, req)
+
+	req = encodeElectLeadersRequest(0, nil)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0x75, 0x30}, req)
+}
+
+func TestDecodeElectLeadersResponse(t *testing.T) {
+	res := []byte{
+		0, 0, 0, 0, // Throttle time
+		0, 0, // Error code
+		0, 0, 0, 1, // One topic
+		0, 6, 'o', 'r', 'd', 'e', 'r', 's',
+		0, 0, 0, 2, // Two partitions
+		0, 0, 0, 0, 0, 0, 0xff, 0xff, // Elected, null message
+		0, 0, 0, 1, 0, 84, 0, 2, 'n', 'o', // Election not needed
+	}
+	results, err := decodeElectLeadersResponse(1, res)
+	require.NoError(t, err)
+	assert.Equal(t, []PartitionElectionResult{
+		{TopicName: "orders", PartitionID: 0, Err: sarama.ErrNoError},
+		{TopicName: "orders", PartitionID: 1, Err: ErrElectionNotNeeded, ErrMessage: "no"},
+	}, results)
+
+	_, err = decodeElectLeadersResponse(1, res[:20])
+	assert.Error(t, err)
+}
@@ -0,0 +1,1195 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// ErrChaos is returned by the fake cluster for requests which have been chosen to fail by the configured error rate
+var ErrChaos = errors.New("fake cluster: injected failure")
+
+const (
+	fakeOffsetsTopicName       = "__consumer_offsets"
+	fakeOffsetsTopicPartitions = 50
+	fakeLogDirPath             = "/var/lib/kafka/data"
+)
+
+var fakeTopicNameRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// fakeTopicConfigDefaults are the topic configs the fake cluster knows. Other config names are rejected.
+var fakeTopicConfigDefaults = map[string]string{
+	"cleanup.policy":         "delete",
+	"compression.type":       "producer",
+	"delete.retention.ms":    "86400000",
+	"max.message.bytes":      "1048588",
+	"message.format.version": "2.4-IV1",
+	"message.timestamp.type": "CreateTime",
+	"min.insync.replicas":    "1",
+	"retention.bytes":        "-1",
+	"retention.ms":           "604800000",
+	"segment.bytes":          "1073741824",
+}
+
+// fakeBrokerConfigs are the static broker configs reported for every broker of the fake cluster
+var fakeBrokerConfigs = map[string]string{
+	"auto.create.topics.enable":     "false",
+	"default.replication.factor":    "1",
+	"delete.topic.enable":           "true",
+	"inter.broker.protocol.version": "2.4-IV1",
+	"log.message.format.version":    "2.4-IV1",
+	"log.retention.hours":           "168",
+	"message.max.bytes":             "1000012",
+	"num.partitions":                "1",
+	"zookeeper.connect":             "fake-zookeeper:2181",
+}
+
+// fakeDynamicBrokerConfigDefaults are the broker configs which can be altered per broker in the fake cluster. Other
+// config names are rejected.
+var fakeDynamicBrokerConfigDefaults = map[string]string{
+	"log.cleaner.threads": "1",
+	"log.retention.ms":    "604800000",
+	"num.io.threads":      "8",
+	"num.network.threads": "3",
+}
+
+type fakeRecord struct {
+	Key       []byte
+	Value     []byte
+	Headers   []*sarama.RecordHeader
+	Timestamp time.Time
+}
+
+func (r fakeRecord) size() int64 {
+	return int64(len(r.Key) + len(r.Value))
+}
+
+type fakeTopic struct {
+	Name              string
+	IsInternal        bool
+	ReplicationFactor int16
+	Partitions        [][]fakeRecord
+	LogStartOffsets   []int64           // Records below the log start offset have been deleted
+	Configs           map[string]string // Dynamic configs only, defaults are taken from fakeTopicConfigDefaults
+	Partitioner       sarama.Partitioner
+}
+
+type fakeGroupMember struct {
+	ClientID    string
+	ClientHost  string
+	Assignments map[string][]int32
+}
+
+type fakeGroup struct {
+	Offsets map[string]map[int32]int64
+	// Metadata of the committed offsets, nil until offsets with metadata have been committed
+	Metadata map[string]map[int32]string
+	Members  map[string]*fakeGroupMember
+}
+
+// FakeCluster is an in-memory Kafka cluster which implements the Cluster interface. Topics, messages and consumer
+// group offsets can be modified like in a real cluster, while other admin operations are rejected. Optionally every
+// request is delayed or fails randomly so that error handling can be tested.
+type FakeCluster struct {
+	cfg    FakeConfig
+	logger *zap.Logger
+
+	mutex         sync.RWMutex
+	topics        map[string]*fakeTopic
+	groups        map[string]*fakeGroup
+	brokerConfigs map[int32]map[string]string         // Dynamic broker configs, defaults are taken from fakeDynamicBrokerConfigDefaults
+	scramUsers    map[string]map[ScramMechanism]int32 // Iterations of each user's credentials
+
+	randMutex sync.Mutex
+	rand      *rand.Rand
+}
+
+var _ Cluster = (*FakeCluster)(nil)
+
+// NewFakeCluster creates a fake cluster which is seeded with demo topics, messages and consumer groups
+func NewFakeCluster(cfg FakeConfig, logger *zap.Logger) *FakeCluster {
+	f := &FakeCluster{
+		cfg:           cfg,
+		logger:        logger,
+		topics:        make(map[string]*fakeTopic),
+		groups:        make(map[string]*fakeGroup),
+		brokerConfigs: make(map[int32]map[string]string),
+		scramUsers:    make(map[string]map[ScramMechanism]int32),
+		rand:          rand.New(rand.NewSource(cfg.Seed)),
+	}
+	f.addTopic(fakeOffsetsTopicName, fakeOffsetsTopicPartitions, f.defaultReplicationFactor(), map[string]string{"cleanup.policy": "compact"})
+	f.topics[fakeOffsetsTopicName].IsInternal = true
+	f.seed(time.Now())
+
+	logger.Info("using fake kafka cluster", zap.Int("topics", len(f.topics)), zap.Int("consumer_groups", len(f.groups)))
+	return f
+}
+
+// chaos delays the request and fails it randomly according to the configured latency and error rate
+func (f *FakeCluster) chaos() error {
+	f.randMutex.Lock()
+	delay := time.Duration(0)
+	if f.cfg.Latency > 0 {
+		delay = time.Duration(f.rand.Int63n(int64(f.cfg.Latency)))
+	}
+	fail := f.rand.Float64() < f.cfg.ErrorRate
+	f.randMutex.Unlock()
+
+	time.Sleep(delay)
+	if fail {
+		return ErrChaos
+	}
+	return nil
+}
+
+func (f *FakeCluster) defaultReplicationFactor() int16 {
+	if f.cfg.Brokers < 3 {
+		return int16(f.cfg.Brokers)
+	}
+	return 3
+}
+
+// addTopic creates an empty topic. Callers must hold the mutex.
+func (f *FakeCluster) addTopic(name string, partitionCount int32, replicationFactor int16, configs map[string]string) *fakeTopic {
+	if configs == nil {
+		configs = make(map[string]string)
+	}
+	topic := &fakeTopic{
+		Name:              name,
+		ReplicationFactor: replicationFactor,
+		Partitions:        make([][]fakeRecord, partitionCount),
+		LogStartOffsets:   make([]int64, partitionCount),
+		Configs:           configs,
+		Partitioner:       newRecordPartitioner(name),
+	}
+	f.topics[name] = topic
+	return topic
+}
+
+// replicas returns the brokers which host the given partition, the first one is the leader
+func (f *FakeCluster) replicas(topic *fakeTopic, partitionID int32) []int32 {
+	offset := (javaHashCode(topic.Name) & 0x7fffffff) % int32(f.cfg.Brokers)
+	replicas := make([]int32, topic.ReplicationFactor)
+	for i := range replicas {
+		replicas[i] = (offset + partitionID + int32(i)) % int32(f.cfg.Brokers)
+	}
+	return replicas
+}
+
+// partition returns the records of a partition. Records are never modified, hence the returned slice can be read
+// without holding the mutex. Callers must hold the mutex.
+func (f *FakeCluster) partition(topicName string, partitionID int32) ([]fakeRecord, error) {
+	topic, ok := f.topics[topicName]
+	if !ok || partitionID < 0 || int(partitionID) >= len(topic.Partitions) {
+		return nil, sarama.ErrUnknownTopicOrPartition
+	}
+	return topic.Partitions[partitionID], nil
+}
+
+// logStartOffset returns the offset of the first record of a partition which has not been deleted. Callers must
+// hold the mutex and must have checked that the partition exists.
+func (f *FakeCluster) logStartOffset(topicName string, partitionID int32) int64 {
+	return f.topics[topicName].LogStartOffsets[partitionID]
+}
+
+// ListTopics returns the metadata of all topics
+func (f *FakeCluster) ListTopics() ([]*sarama.TopicMetadata, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	topics := make([]*sarama.TopicMetadata, 0, len(f.topics))
+	for _, topic := range f.topics {
+		partitions := make([]*sarama.PartitionMetadata, len(topic.Partitions))
+		for i := range topic.Partitions {
+			replicas := f.replicas(topic, int32(i))
+			partitions[i] = &sarama.PartitionMetadata{
+				ID:       int32(i),
+				Leader:   replicas[0],
+				Replicas: replicas,
+				Isr:      replicas,
+			}
+		}
+		topics = append(topics, &sarama.TopicMetadata{Name: topic.Name, IsInternal: topic.IsInternal, Partitions: partitions})
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+
+	return topics, nil
+}
+
+// ListPartitions returns the partitionIDs for a given topic
+func (f *FakeCluster) ListPartitions(topicName string) ([]int32, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	topic, ok := f.topics[topicName]
+	if !ok {
+		return nil, fmt.Errorf("failed to get partitions for topic '%v': %w", topicName, sarama.ErrUnknownTopicOrPartition)
+	}
+	partitionIDs := make([]int32, len(topic.Partitions))
+	for i := range partitionIDs {
+		partitionIDs[i] = int32(i)
+	}
+
+	return partitionIDs, nil
+}
+
+// PartitionLeaders returns the first replica of each partition as its leader
+func (f *FakeCluster) PartitionLeaders(topicName string, partitionIDs []int32) (map[int32]int32, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	topic, ok := f.topics[topicName]
+	if !ok {
+		return nil, sarama.ErrUnknownTopicOrPartition
+	}
+	leaders := make(map[int32]int32, len(partitionIDs))
+	for _, partitionID := range partitionIDs {
+		if partitionID < 0 || int(partitionID) >= len(topic.Partitions) {
+			return nil, sarama.ErrUnknownTopicOrPartition
+		}
+		leaders[partitionID] = f.replicas(topic, partitionID)[0]
+	}
+
+	return leaders, nil
+}
+
+// CreateTopic creates an empty topic. Partition count and replication factor may be -1 to use the defaults.
+func (f *FakeCluster) CreateTopic(topicName string, partitionCount int32, replicationFactor int16, configs map[string]*string, validateOnly bool) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !fakeTopicNameRegex.MatchString(topicName) || topicName == "." || topicName == ".." {
+		return fmt.Errorf("%w: topic name '%v' is illegal", sarama.ErrInvalidTopic, topicName)
+	}
+	if _, exists := f.topics[topicName]; exists {
+		return fmt.Errorf("%w: topic '%v' already exists", sarama.ErrTopicAlreadyExists, topicName)
+	}
+	if partitionCount == -1 {
+		partitionCount = f.cfg.PartitionsPerTopic
+	}
+	if partitionCount <= 0 {
+		return fmt.Errorf("%w: number of partitions must be larger than 0", sarama.ErrInvalidPartitions)
+	}
+	if replicationFactor == -1 {
+		replicationFactor = f.defaultReplicationFactor()
+	}
+	if replicationFactor <= 0 || int(replicationFactor) > f.cfg.Brokers {
+		return fmt.Errorf("%w: replication factor must be between 1 and %v", sarama.ErrInvalidReplicationFactor, f.cfg.Brokers)
+	}
+	dynamicConfigs, err := fakeDynamicConfigs(configs)
+	if err != nil {
+		return err
+	}
+	if validateOnly {
+		return nil
+	}
+
+	f.addTopic(topicName, partitionCount, replicationFactor, dynamicConfigs)
+	return nil
+}
+
+// DeleteTopic deletes the topic along with all committed group offsets for it
+func (f *FakeCluster) DeleteTopic(topicName string) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	topic, ok := f.topics[topicName]
+	if !ok {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	if topic.IsInternal {
+		return fmt.Errorf("%w: internal topics can not be deleted", sarama.ErrInvalidTopic)
+	}
+	delete(f.topics, topicName)
+	for _, group := range f.groups {
+		delete(group.Offsets, topicName)
+	}
+
+	return nil
+}
+
+// CreatePartitions appends empty partitions to the topic until it has the given partition count
+func (f *FakeCluster) CreatePartitions(topicName string, partitionCount int32, validateOnly bool) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	topic, ok := f.topics[topicName]
+	if !ok {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	if int(partitionCount) <= len(topic.Partitions) {
+		return fmt.Errorf("%w: topic currently has %v partitions, which is higher than or equal to the requested %v",
+			sarama.ErrInvalidPartitions, len(topic.Partitions), partitionCount)
+	}
+	if validateOnly {
+		return nil
+	}
+
+	added := int(partitionCount) - len(topic.Partitions)
+	topic.Partitions = append(topic.Partitions, make([][]fakeRecord, added)...)
+	topic.LogStartOffsets = append(topic.LogStartOffsets, make([]int64, added)...)
+	return nil
+}
+
+// DescribeTopicsConfigs returns the config entries of the given topics. Use an empty array for configNames to
+// describe all config entries.
+func (f *FakeCluster) DescribeTopicsConfigs(topicNames []string, configNames []string) (*sarama.DescribeConfigsResponse, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	res := &sarama.DescribeConfigsResponse{Resources: make([]*sarama.ResourceResponse, 0, len(topicNames))}
+	for _, topicName := range topicNames {
+		resource := &sarama.ResourceResponse{Type: sarama.TopicResource, Name: topicName, Configs: make([]*sarama.ConfigEntry, 0)}
+		res.Resources = append(res.Resources, resource)
+
+		topic, ok := f.topics[topicName]
+		if !ok {
+			resource.ErrorCode = int16(sarama.ErrUnknownTopicOrPartition)
+			resource.ErrorMsg = sarama.ErrUnknownTopicOrPartition.Error()
+			continue
+		}
+		for name, defaultValue := range fakeTopicConfigDefaults {
+			if len(configNames) > 0 && !containsString(configNames, name) {
+				continue
+			}
+			entry := &sarama.ConfigEntry{Name: name, Value: defaultValue, Default: true, Source: sarama.SourceDefault}
+			if value, ok := topic.Configs[name]; ok {
+				entry.Value = value
+				entry.Default = false
+				entry.Source = sarama.SourceTopic
+			}
+			resource.Configs = append(resource.Configs, entry)
+		}
+		sort.Slice(resource.Configs, func(i, j int) bool { return resource.Configs[i].Name < resource.Configs[j].Name })
+	}
+
+	return res, nil
+}
+
+// AlterTopicConfig replaces all dynamic configs of a topic with the given config entries
+func (f *FakeCluster) AlterTopicConfig(topicName string, entries map[string]*string, validateOnly bool) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	topic, ok := f.topics[topicName]
+	if !ok {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	dynamicConfigs, err := fakeDynamicConfigs(entries)
+	if err != nil {
+		return err
+	}
+	if !validateOnly {
+		topic.Configs = dynamicConfigs
+	}
+
+	return nil
+}
+
+// fakeDynamicConfigs validates the config names and drops entries without value
+func fakeDynamicConfigs(entries map[string]*string) (map[string]string, error) {
+	configs := make(map[string]string, len(entries))
+	for name, value := range entries {
+		if _, ok := fakeTopicConfigDefaults[name]; !ok {
+			return nil, fmt.Errorf("%w: unknown topic config '%v'", sarama.ErrInvalidConfig, name)
+		}
+		if value != nil {
+			configs[name] = *value
+		}
+	}
+	return configs, nil
+}
+
+// NewConsumer creates a consumer which reads from the in-memory partitions. The fake cluster has no transactions, all
+// records are committed regardless of the isolation level.
+func (f *FakeCluster) NewConsumer(isolationLevel sarama.IsolationLevel) (sarama.Consumer, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	return &fakeConsumer{cluster: f}, nil
+}
+
+// WaterMarks returns a map of: partitionID -> *waterMark
+func (f *FakeCluster) WaterMarks(topic string, partitionIDs []int32) (map[int32]*WaterMark, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	res := make(map[int32]*WaterMark, len(partitionIDs))
+	for _, partitionID := range partitionIDs {
+		records, err := f.partition(topic, partitionID)
+		if err != nil {
+			return nil, err
+		}
+		res[partitionID] = &WaterMark{PartitionID: partitionID, Low: f.logStartOffset(topic, partitionID), High: int64(len(records))}
+	}
+
+	return res, nil
+}
+
+// HighWaterMarks returns a map of: topic -> partitionID -> high water mark
+func (f *FakeCluster) HighWaterMarks(topicPartitions map[string][]int32) (map[string]map[int32]int64, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	res := make(map[string]map[int32]int64, len(topicPartitions))
+	for topic, partitionIDs := range topicPartitions {
+		res[topic] = make(map[int32]int64, len(partitionIDs))
+		for _, partitionID := range partitionIDs {
+			records, err := f.partition(topic, partitionID)
+			if err != nil {
+				return nil, err
+			}
+			res[topic][partitionID] = int64(len(records))
+		}
+	}
+
+	return res, nil
+}
+
+// OffsetsForTimes returns a map of: partitionID -> offset of the first message whose timestamp is greater than or
+// equal to the given timestamp (in unix milliseconds). The offset is -1 if no such message exists in a partition.
+func (f *FakeCluster) OffsetsForTimes(topic string, partitionIDs []int32, timestamp int64) (map[int32]int64, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	ts := time.Unix(0, timestamp*int64(time.Millisecond))
+	res := make(map[int32]int64, len(partitionIDs))
+	for _, partitionID := range partitionIDs {
+		records, err := f.partition(topic, partitionID)
+		if err != nil {
+			return nil, err
+		}
+		res[partitionID] = -1
+		for offset := f.logStartOffset(topic, partitionID); offset < int64(len(records)); offset++ {
+			if !records[offset].Timestamp.Before(ts) {
+				res[partitionID] = offset
+				break
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// MessageTimestamps returns a map of: partitionID -> timestamp of the message at the given offset. Offsets which
+// are out of range are missing in the result.
+func (f *FakeCluster) MessageTimestamps(_ context.Context, topic string, offsets map[int32]int64) (map[int32]time.Time, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	res := make(map[int32]time.Time, len(offsets))
+	for partitionID, offset := range offsets {
+		records, err := f.partition(topic, partitionID)
+		if err != nil || offset < f.logStartOffset(topic, partitionID) || offset >= int64(len(records)) {
+			continue
+		}
+		res[partitionID] = records[offset].Timestamp
+	}
+
+	return res, nil
+}
+
+// Produce appends a single record to the partition chosen by the record's partitioner. Produce options are ignored.
+func (f *FakeCluster) Produce(record ProduceRecord, _ ProduceOptions) (*ProduceResult, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	partitionID, err := f.recordPartition(record)
+	if err != nil {
+		return nil, err
+	}
+	res := f.appendRecord(record, partitionID)
+
+	return &res, nil
+}
+
+// ProduceTransaction produces all records at once. If a partition can't be chosen for any of the records, none of
+// them is written, which is how an aborted transaction appears to read_committed consumers.
+func (f *FakeCluster) ProduceTransaction(_ string, records []ProduceRecord) ([]ProduceResult, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	partitionIDs := make([]int32, len(records))
+	for i, record := range records {
+		partitionID, err := f.recordPartition(record)
+		if err != nil {
+			return nil, err
+		}
+		partitionIDs[i] = partitionID
+	}
+
+	results := make([]ProduceResult, len(records))
+	for i, record := range records {
+		results[i] = f.appendRecord(record, partitionIDs[i])
+	}
+
+	return results, nil
+}
+
+// recordPartition chooses the partition of the record with the record's partitioner. The mutex must be held.
+func (f *FakeCluster) recordPartition(record ProduceRecord) (int32, error) {
+	topic, ok := f.topics[record.TopicName]
+	if !ok {
+		return 0, fmt.Errorf("failed to produce record to topic '%v': %w", record.TopicName, sarama.ErrUnknownTopicOrPartition)
+	}
+	msg := &sarama.ProducerMessage{Topic: record.TopicName, Partition: record.PartitionID, Metadata: record.Partitioner}
+	if record.Key != nil {
+		msg.Key = sarama.ByteEncoder(record.Key)
+	}
+	partitionID, err := topic.Partitioner.Partition(msg, int32(len(topic.Partitions)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to produce record to topic '%v': %w", record.TopicName, err)
+	}
+
+	return partitionID, nil
+}
+
+// appendRecord writes the record to the end of the partition. The mutex must be held.
+func (f *FakeCluster) appendRecord(record ProduceRecord, partitionID int32) ProduceResult {
+	topic := f.topics[record.TopicName]
+	headers := make([]*sarama.RecordHeader, len(record.Headers))
+	for i := range record.Headers {
+		headers[i] = &record.Headers[i]
+	}
+	offset := int64(len(topic.Partitions[partitionID]))
+	topic.Partitions[partitionID] = append(topic.Partitions[partitionID], fakeRecord{
+		Key:       record.Key,
+		Value:     record.Value,
+		Headers:   headers,
+		Timestamp: time.Now(),
+	})
+
+	return ProduceResult{PartitionID: partitionID, Offset: offset}
+}
+
+// DeleteRecords moves the log start offsets of the given partitions. Offsets of -1 delete all records.
+func (f *FakeCluster) DeleteRecords(topicName string, offsets map[int32]int64) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for partitionID, offset := range offsets {
+		records, err := f.partition(topicName, partitionID)
+		if err != nil {
+			return err
+		}
+		if offset == -1 {
+			offset = int64(len(records))
+		}
+		if offset < 0 || offset > int64(len(records)) {
+			return sarama.ErrOffsetOutOfRange
+		}
+	}
+	topic := f.topics[topicName]
+	for partitionID, offset := range offsets {
+		if offset == -1 {
+			offset = int64(len(topic.Partitions[partitionID]))
+		}
+		if offset > topic.LogStartOffsets[partitionID] {
+			topic.LogStartOffsets[partitionID] = offset
+		}
+	}
+
+	return nil
+}
+
+// ListConsumerGroups returns the ids of all consumer groups
+func (f *FakeCluster) ListConsumerGroups(_ context.Context) ([]string, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	groupIDs := make([]string, 0, len(f.groups))
+	for groupID := range f.groups {
+		groupIDs = append(groupIDs, groupID)
+	}
+	sort.Strings(groupIDs)
+
+	return groupIDs, nil
+}
+
+// DescribeConsumerGroups describes the given groups. It returns a map where the coordinator BrokerID is the key.
+// Unknown groups are reported in the state 'Dead' like Kafka does.
+func (f *FakeCluster) DescribeConsumerGroups(_ context.Context, groups []string) (map[int32]*sarama.DescribeGroupsResponse, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	res := make(map[int32]*sarama.DescribeGroupsResponse)
+	for _, groupID := range groups {
+		description := &sarama.GroupDescription{
+			Err:     sarama.ErrNoError,
+			GroupId: groupID,
+			State:   "Dead",
+			Members: make(map[string]*sarama.GroupMemberDescription),
+		}
+		if group, ok := f.groups[groupID]; ok {
+			description.State = "Empty"
+			description.ProtocolType = "consumer"
+			if len(group.Members) > 0 {
+				description.State = "Stable"
+				description.Protocol = "range"
+			}
+			for memberID, m := range group.Members {
+				description.Members[memberID] = &sarama.GroupMemberDescription{
+					ClientId:         m.ClientID,
+					ClientHost:       m.ClientHost,
+					MemberMetadata:   encodeMemberMetadata(m.Assignments),
+					MemberAssignment: encodeMemberAssignment(m.Assignments),
+				}
+			}
+		}
+
+		coordinator := f.coordinator(groupID)
+		if _, ok := res[coordinator]; !ok {
+			res[coordinator] = &sarama.DescribeGroupsResponse{}
+		}
+		res[coordinator].Groups = append(res[coordinator].Groups, description)
+	}
+
+	return res, nil
+}
+
+// coordinator returns the leader of the offsets topic partition the group id hashes to. Callers must hold the mutex.
+func (f *FakeCluster) coordinator(groupID string) int32 {
+	offsetsTopic := f.topics[fakeOffsetsTopicName]
+	partitionID := (javaHashCode(groupID) & 0x7fffffff) % int32(len(offsetsTopic.Partitions))
+	return f.replicas(offsetsTopic, partitionID)[0]
+}
+
+// ListConsumerGroupOffsets returns the committed offsets of a group. Unknown groups have no offsets.
+func (f *FakeCluster) ListConsumerGroupOffsets(group string) (*sarama.OffsetFetchResponse, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.groupOffsets(group), nil
+}
+
+// ListConsumerGroupOffsetsBulk returns the committed offsets of all given groups, the group id is the key
+func (f *FakeCluster) ListConsumerGroupOffsetsBulk(_ context.Context, groups []string) (map[string]*sarama.OffsetFetchResponse, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	res := make(map[string]*sarama.OffsetFetchResponse, len(groups))
+	for _, group := range groups {
+		res[group] = f.groupOffsets(group)
+	}
+
+	return res, nil
+}
+
+// groupOffsets converts the committed offsets of a group to an offset fetch response. Callers must hold the mutex.
+func (f *FakeCluster) groupOffsets(groupID string) *sarama.OffsetFetchResponse {
+	res := &sarama.OffsetFetchResponse{Blocks: make(map[string]map[int32]*sarama.OffsetFetchResponseBlock)}
+	group, ok := f.groups[groupID]
+	if !ok {
+		return res
+	}
+	for topic, partitions := range group.Offsets {
+		for partitionID, offset := range partitions {
+			res.AddBlock(topic, partitionID, &sarama.OffsetFetchResponseBlock{
+				Offset:      offset,
+				LeaderEpoch: -1,
+				Metadata:    group.Metadata[topic][partitionID],
+				Err:         sarama.ErrNoError,
+			})
+		}
+	}
+
+	return res
+}
+
+// CommitConsumerGroupOffsets commits the given offsets (topic -> partitionID -> offset) on behalf of a consumer
+// group. Like Kafka, commits are only accepted if the group has no active members.
+func (f *FakeCluster) CommitConsumerGroupOffsets(groupID string, offsets map[string]map[int32]int64) error {
+	return f.CommitConsumerGroupOffsetsWithMetadata(groupID, groupOffsetsWithoutMetadata(offsets))
+}
+
+// CommitConsumerGroupOffsetsWithMetadata commits the given offsets and their metadata on behalf of a consumer group.
+// Like Kafka, commits are only accepted if the group has no active members.
+func (f *FakeCluster) CommitConsumerGroupOffsetsWithMetadata(groupID string, offsets map[string]map[int32]GroupOffset) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	group, ok := f.groups[groupID]
+	if ok && len(group.Members) > 0 {
+		return fmt.Errorf("failed to commit offsets for group '%v': %w", groupID, sarama.ErrUnknownMemberId)
+	}
+	for topic, partitions := range offsets {
+		for partitionID := range partitions {
+			if _, err := f.partition(topic, partitionID); err != nil {
+				return fmt.Errorf("failed to commit offset for topic '%v' partition '%v': %w", topic, partitionID, err)
+			}
+		}
+	}
+
+	if !ok {
+		group = &fakeGroup{Offsets: make(map[string]map[int32]int64), Members: make(map[string]*fakeGroupMember)}
+		f.groups[groupID] = group
+	}
+	if group.Metadata == nil {
+		group.Metadata = make(map[string]map[int32]string)
+	}
+	for topic, partitions := range offsets {
+		if _, ok := group.Offsets[topic]; !ok {
+			group.Offsets[topic] = make(map[int32]int64)
+		}
+		if _, ok := group.Metadata[topic]; !ok {
+			group.Metadata[topic] = make(map[int32]string)
+		}
+		for partitionID, offset := range partitions {
+			group.Offsets[topic][partitionID] = offset.Offset
+			group.Metadata[topic][partitionID] = offset.Metadata
+		}
+	}
+
+	return nil
+}
+
+// DeleteConsumerGroup deletes the group along with its committed offsets. Like Kafka, only groups without active
+// members can be deleted.
+func (f *FakeCluster) DeleteConsumerGroup(groupID string) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	group, ok := f.groups[groupID]
+	if !ok {
+		return sarama.ErrGroupIDNotFound
+	}
+	if len(group.Members) > 0 {
+		return sarama.ErrNonEmptyGroup
+	}
+	delete(f.groups, groupID)
+
+	return nil
+}
+
+// fakeClusterID is the cluster id of all fake clusters
+const fakeClusterID = "fake-cluster"
+
+// ClusterID returns the id of the fake cluster
+func (f *FakeCluster) ClusterID() string {
+	return fakeClusterID
+}
+
+// DescribeCluster returns the brokers of the fake cluster, the first broker is the controller
+func (f *FakeCluster) DescribeCluster() (*ClusterMetadata, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+
+	brokers := make([]BrokerMetadata, f.cfg.Brokers)
+	for i := range brokers {
+		brokers[i] = BrokerMetadata{
+			ID:      int32(i),
+			Address: fmt.Sprintf("fake-broker-%v:9092", i),
+			Rack:    fmt.Sprintf("rack-%c", 'a'+i%3),
+		}
+	}
+
+	return &ClusterMetadata{ClusterID: fakeClusterID, ControllerID: 0, Brokers: brokers}, nil
+}
+
+// DescribeBrokerConfigs returns the static and dynamic configs of a broker. Use an empty array for configNames to
+// fetch all configs.
+func (f *FakeCluster) DescribeBrokerConfigs(brokerID int32, configNames []string) ([]*sarama.ConfigEntry, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	if brokerID < 0 || int(brokerID) >= f.cfg.Brokers {
+		return nil, fmt.Errorf("broker with id '%v' is not known by the client", brokerID)
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	entries := make([]*sarama.ConfigEntry, 0, len(fakeBrokerConfigs)+len(fakeDynamicBrokerConfigDefaults))
+	for name, value := range fakeBrokerConfigs {
+		if len(configNames) > 0 && !containsString(configNames, name) {
+			continue
+		}
+		entries = append(entries, &sarama.ConfigEntry{Name: name, Value: value, ReadOnly: true, Source: sarama.SourceStaticBroker})
+	}
+	for name, defaultValue := range fakeDynamicBrokerConfigDefaults {
+		if len(configNames) > 0 && !containsString(configNames, name) {
+			continue
+		}
+		entry := &sarama.ConfigEntry{Name: name, Value: defaultValue, Default: true, Source: sarama.SourceDefault}
+		if value, ok := f.brokerConfigs[brokerID][name]; ok {
+			entry.Value = value
+			entry.Default = false
+			entry.Source = sarama.SourceDynamicBroker
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	return entries, nil
+}
+
+// AlterBrokerConfig replaces all dynamic configs of a broker with the given config entries
+func (f *FakeCluster) AlterBrokerConfig(brokerID int32, entries map[string]*string, validateOnly bool) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	if brokerID < 0 || int(brokerID) >= f.cfg.Brokers {
+		return fmt.Errorf("broker with id '%v' is not known by the client", brokerID)
+	}
+
+	configs := make(map[string]string, len(entries))
+	for name, value := range entries {
+		if _, ok := fakeBrokerConfigs[name]; ok {
+			return fmt.Errorf("%w: cannot update read-only broker config '%v'", sarama.ErrInvalidRequest, name)
+		}
+		if _, ok := fakeDynamicBrokerConfigDefaults[name]; !ok {
+			return fmt.Errorf("%w: unknown broker config '%v'", sarama.ErrInvalidConfig, name)
+		}
+		if value != nil {
+			configs[name] = *value
+		}
+	}
+	if validateOnly {
+		return nil
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.brokerConfigs[brokerID] = configs
+
+	return nil
+}
+
+// DescribeLogDirs returns a single log dir per broker which contains all replicas hosted by the broker
+func (f *FakeCluster) DescribeLogDirs() map[int32]*LogDirResponse {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	topicsByBroker := make(map[int32][]sarama.DescribeLogDirsResponseTopic)
+	for _, topic := range f.topics {
+		partitionsByBroker := make(map[int32][]sarama.DescribeLogDirsResponsePartition)
+		for i, records := range topic.Partitions {
+			size := int64(0)
+			for _, record := range records {
+				size += record.size()
+			}
+			for _, brokerID := range f.replicas(topic, int32(i)) {
+				partitionsByBroker[brokerID] = append(partitionsByBroker[brokerID], sarama.DescribeLogDirsResponsePartition{PartitionID: int32(i), Size: size})
+			}
+		}
+		for brokerID, partitions := range partitionsByBroker {
+			topicsByBroker[brokerID] = append(topicsByBroker[brokerID], sarama.DescribeLogDirsResponseTopic{Topic: topic.Name, Partitions: partitions})
+		}
+	}
+
+	res := make(map[int32]*LogDirResponse, f.cfg.Brokers)
+	for i := 0; i < f.cfg.Brokers; i++ {
+		brokerID := int32(i)
+		if err := f.chaos(); err != nil {
+			res[brokerID] = &LogDirResponse{Err: err}
+			continue
+		}
+		res[brokerID] = &LogDirResponse{DescribeLogDirsResponse: &sarama.DescribeLogDirsResponse{
+			LogDirs: []sarama.DescribeLogDirsResponseDirMetadata{
+				{ErrorCode: sarama.ErrNoError, Path: fakeLogDirPath, Topics: topicsByBroker[brokerID]},
+			},
+		}}
+	}
+
+	return res
+}
+
+// ElectPreferredLeaders reports that no election is needed, because the fake cluster's leaders always are the
+// preferred replicas
+func (f *FakeCluster) ElectPreferredLeaders(topicPartitions map[string][]int32) ([]PartitionElectionResult, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	if topicPartitions == nil {
+		topicPartitions = make(map[string][]int32, len(f.topics))
+		for _, topic := range f.topics {
+			for i := range topic.Partitions {
+				topicPartitions[topic.Name] = append(topicPartitions[topic.Name], int32(i))
+			}
+		}
+	}
+
+	res := make([]PartitionElectionResult, 0)
+	for topicName, partitionIDs := range topicPartitions {
+		topic, ok := f.topics[topicName]
+		for _, partitionID := range partitionIDs {
+			result := PartitionElectionResult{TopicName: topicName, PartitionID: partitionID, Err: ErrElectionNotNeeded}
+			if !ok || partitionID < 0 || int(partitionID) >= len(topic.Partitions) {
+				result.Err = sarama.ErrUnknownTopicOrPartition
+			}
+			res = append(res, result)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].TopicName != res[j].TopicName {
+			return res[i].TopicName < res[j].TopicName
+		}
+		return res[i].PartitionID < res[j].PartitionID
+	})
+
+	return res, nil
+}
+
+// ListACLs fails because the fake cluster has no authorizer
+func (f *FakeCluster) ListACLs(_ sarama.AclFilter) ([]*sarama.ResourceAcls, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	return nil, sarama.ErrSecurityDisabled
+}
+
+// CreateACLs fails because the fake cluster has no authorizer
+func (f *FakeCluster) CreateACLs(_ []*sarama.AclCreation) error {
+	if err := f.chaos(); err != nil {
+		return err
+	}
+	return sarama.ErrSecurityDisabled
+}
+
+// DeleteACLs fails because the fake cluster has no authorizer
+func (f *FakeCluster) DeleteACLs(_ sarama.AclFilter) ([]*sarama.MatchingAcl, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	return nil, sarama.ErrSecurityDisabled
+}
+
+// DescribeUserScramCredentials returns the credentials of the given users, or of all users if users is nil
+func (f *FakeCluster) DescribeUserScramCredentials(users []string) ([]UserScramCredentials, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	if users == nil {
+		users = make([]string, 0, len(f.scramUsers))
+		for user := range f.scramUsers {
+			users = append(users, user)
+		}
+		sort.Strings(users)
+	}
+
+	res := make([]UserScramCredentials, len(users))
+	for i, user := range users {
+		res[i] = UserScramCredentials{User: user, Err: sarama.ErrNoError, Credentials: make([]ScramCredentialInfo, 0)}
+		credentials, ok := f.scramUsers[user]
+		if !ok {
+			res[i].Err = ErrResourceNotFound
+			res[i].ErrMessage = "Attempt to describe a user credential that does not exist: " + user
+			continue
+		}
+		for mechanism, iterations := range credentials {
+			res[i].Credentials = append(res[i].Credentials, ScramCredentialInfo{Mechanism: mechanism, Iterations: iterations})
+		}
+		sort.Slice(res[i].Credentials, func(a, b int) bool { return res[i].Credentials[a].Mechanism < res[i].Credentials[b].Mechanism })
+	}
+
+	return res, nil
+}
+
+// AlterUserScramCredentials upserts and deletes credentials. Like Kafka, all alterations of a user fail if one of
+// them is invalid, while the alterations of other users are applied.
+func (f *FakeCluster) AlterUserScramCredentials(upsertions []ScramCredentialUpsertion, deletions []ScramCredentialDeletion) ([]ScramAlterationResult, error) {
+	if err := f.chaos(); err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	users := make([]string, 0)
+	errs := make(map[string]ScramAlterationResult)
+	fail := func(user string, err sarama.KError, msg string) {
+		if _, exists := errs[user]; !exists {
+			errs[user] = ScramAlterationResult{User: user, Err: err, ErrMessage: msg}
+		}
+	}
+	for _, d := range deletions {
+		users = append(users, d.User)
+		if _, exists := f.scramUsers[d.User][d.Mechanism]; !exists {
+			fail(d.User, ErrResourceNotFound, "Attempt to delete a user credential that does not exist")
+		}
+	}
+	for _, u := range upsertions {
+		users = append(users, u.User)
+		if u.Iterations < 4096 || u.Iterations > 16384 {
+			fail(u.User, ErrUnacceptableCredential, "Iterations must be between 4096 and 16384")
+		}
+	}
+
+	res := make([]ScramAlterationResult, 0)
+	done := make(map[string]bool)
+	for _, user := range users {
+		if done[user] {
+			continue
+		}
+		done[user] = true
+		if result, failed := errs[user]; failed {
+			res = append(res, result)
+			continue
+		}
+		res = append(res, ScramAlterationResult{User: user, Err: sarama.ErrNoError})
+	}
+
+	for _, d := range deletions {
+		if _, failed := errs[d.User]; failed {
+			continue
+		}
+		delete(f.scramUsers[d.User], d.Mechanism)
+		if len(f.scramUsers[d.User]) == 0 {
+			delete(f.scramUsers, d.User)
+		}
+	}
+	for _, u := range upsertions {
+		if _, failed := errs[u.User]; failed {
+			continue
+		}
+		if _, exists := f.scramUsers[u.User]; !exists {
+			f.scramUsers[u.User] = make(map[ScramMechanism]int32)
+		}
+		f.scramUsers[u.User][u.Mechanism] = u.Iterations
+	}
+
+	return res, nil
+}
+
+// encodeMemberAssignment encodes the partition assignment of a group member like Kafka's consumer protocol does
+func encodeMemberAssignment(assignments map[string][]int32) []byte {
+	topics := make([]string, 0, len(assignments))
+	for topic := range assignments {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	buf := &bytes.Buffer{}
+	write := func(v interface{}) { _ = binary.Write(buf, binary.BigEndian, v) }
+	write(int16(0)) // Version
+	write(int32(len(topics)))
+	for _, topic := range topics {
+		write(int16(len(topic)))
+		buf.WriteString(topic)
+		write(int32(len(assignments[topic])))
+		write(assignments[topic])
+	}
+	write(int32(-1)) // No user data
+
+	return buf.Bytes()
+}
+
+// encodeMemberMetadata encodes a consumer protocol subscription of all assigned topics
+func encodeMemberMetadata(assignments map[string][]int32) []byte {
+	topics := make([]string, 0, len(assignments))
+	for topic := range assignments {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	buf := &bytes.Buffer{}
+	write := func(v interface{}) { _ = binary.Write(buf, binary.BigEndian, v) }
+	write(int16(0)) // Version
+	write(int32(len(topics)))
+	for _, topic := range topics {
+		write(int16(len(topic)))
+		buf.WriteString(topic)
+	}
+	write(int32(-1)) // No user data
+
+	return buf.Bytes()
+}
+
+// javaHashCode calculates Java's String.hashCode over the UTF-16 code units, which Kafka uses to map group ids to
+// offsets topic partitions
+func javaHashCode(s string) int32 {
+	h := int32(0)
+	for _, c := range utf16.Encode([]rune(s)) {
+		h = 31*h + int32(c)
+	}
+	return h
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestFakeCluster() *FakeCluster {
+	cfg := FakeConfig{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	return NewFakeCluster(cfg, zap.NewNop())
+}
+
+func TestFakeClusterProduceAndConsume(t *testing.T) {
+	f := newTestFakeCluster()
+	require.NoError(t, f.CreateTopic("test", 2, 1, nil, false))
+	assert.True(t, errors.Is(f.CreateTopic("test", 2, 1, nil, false), sarama.ErrTopicAlreadyExists))
+	assert.True(t, errors.Is(f.CreateTopic("invalid", 1, 4, nil, false), sarama.ErrInvalidReplicationFactor))
+
+	res, err := f.Produce(ProduceRecord{TopicName: "test", PartitionID: 1, Partitioner: PartitionerManual, Key: []byte("k"), Value: []byte("v")}, ProduceOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, ProduceResult{PartitionID: 1, Offset: 0}, *res)
+
+	waterMarks, err := f.WaterMarks("test", []int32{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), waterMarks[0].High)
+	assert.Equal(t, int64(1), waterMarks[1].High)
+
+	consumer, err := f.NewConsumer(sarama.ReadUncommitted)
+	require.NoError(t, err)
+	pc, err := consumer.ConsumePartition("test", 1, sarama.OffsetOldest)
+	require.NoError(t, err)
+	defer pc.Close()
+
+	select {
+	case m := <-pc.Messages():
+		assert.Equal(t, "k", string(m.Key))
+		assert.Equal(t, "v", string(m.Value))
+		assert.Equal(t, int64(0), m.Offset)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// Records which are produced later are consumed as well
+	_, err = f.Produce(ProduceRecord{TopicName: "test", PartitionID: 1, Partitioner: PartitionerManual, Value: []byte("v2")}, ProduceOptions{})
+	require.NoError(t, err)
+	select {
+	case m := <-pc.Messages():
+		assert.Equal(t, int64(1), m.Offset)
+		assert.Nil(t, m.Key)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+}
+
+func TestFakeClusterProduceTransaction(t *testing.T) {
+	f := newTestFakeCluster()
+	require.NoError(t, f.CreateTopic("test", 2, 1, nil, false))
+	require.NoError(t, f.CreateTopic("other", 1, 1, nil, false))
+
+	// Committed transactions write all records, which may target multiple topics and partitions
+	results, err := f.ProduceTransaction("txn", []ProduceRecord{
+		{TopicName: "test", PartitionID: 1, Partitioner: PartitionerManual, Value: []byte("a")},
+		{TopicName: "other", PartitionID: 0, Partitioner: PartitionerManual, Value: []byte("b")},
+		{TopicName: "test", PartitionID: 1, Partitioner: PartitionerManual, Value: []byte("c")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []ProduceResult{{PartitionID: 1, Offset: 0}, {PartitionID: 0, Offset: 0}, {PartitionID: 1, Offset: 1}}, results)
+
+	// Aborted transactions don't write any record, even if only the last one fails
+	_, err = f.ProduceTransaction("txn", []ProduceRecord{
+		{TopicName: "test", PartitionID: 0, Partitioner: PartitionerManual, Value: []byte("d")},
+		{TopicName: "test", PartitionID: 2, Partitioner: PartitionerManual, Value: []byte("e")},
+	})
+	assert.True(t, errors.Is(err, sarama.ErrInvalidPartition))
+	_, err = f.ProduceTransaction("txn", []ProduceRecord{
+		{TopicName: "test", PartitionID: 0, Partitioner: PartitionerManual, Value: []byte("d")},
+		{TopicName: "unknown", Partitioner: PartitionerHash, Value: []byte("e")},
+	})
+	assert.True(t, errors.Is(err, sarama.ErrUnknownTopicOrPartition))
+
+	waterMarks, err := f.HighWaterMarks(map[string][]int32{"test": {0, 1}, "other": {0}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[int32]int64{"test": {0: 0, 1: 2}, "other": {0: 1}}, waterMarks)
+
+	results, err = f.ProduceTransaction("txn", nil)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestFakeClusterDeleteRecords(t *testing.T) {
+	f := newTestFakeCluster()
+	require.NoError(t, f.CreateTopic("test", 2, 1, nil, false))
+	for i := 0; i < 3; i++ {
+		_, err := f.Produce(ProduceRecord{TopicName: "test", PartitionID: 0, Partitioner: PartitionerManual, Value: []byte("v")}, ProduceOptions{})
+		require.NoError(t, err)
+	}
+
+	assert.True(t, errors.Is(f.DeleteRecords("test", map[int32]int64{0: 4}), sarama.ErrOffsetOutOfRange))
+	require.NoError(t, f.DeleteRecords("test", map[int32]int64{0: 2, 1: -1}))
+	require.NoError(t, f.DeleteRecords("test", map[int32]int64{0: 1})) // Log start offsets never move backwards
+
+	waterMarks, err := f.WaterMarks("test", []int32{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, WaterMark{PartitionID: 0, Low: 2, High: 3}, *waterMarks[0])
+	assert.Equal(t, WaterMark{PartitionID: 1, Low: 0, High: 0}, *waterMarks[1])
+
+	consumer, err := f.NewConsumer(sarama.ReadUncommitted)
+	require.NoError(t, err)
+	_, err = consumer.ConsumePartition("test", 0, 1)
+	assert.True(t, errors.Is(err, sarama.ErrOffsetOutOfRange))
+	pc, err := consumer.ConsumePartition("test", 0, sarama.OffsetOldest)
+	require.NoError(t, err)
+	defer pc.Close()
+	select {
+	case m := <-pc.Messages():
+		assert.Equal(t, int64(2), m.Offset)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+}
+
+func TestFakeClusterConsumerGroups(t *testing.T) {
+	f := newTestFakeCluster()
+	ctx := context.Background()
+
+	groups, err := f.ListConsumerGroups(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, groups, "orders-processor")
+
+	described, err := f.DescribeConsumerGroups(ctx, []string{"orders-processor"})
+	require.NoError(t, err)
+	require.Len(t, described, 1)
+	for _, res := range described {
+		group := res.Groups[0]
+		assert.Equal(t, "Stable", group.State)
+		assert.Len(t, group.Members, 2)
+
+		assigned := make([]int32, 0)
+		for _, m := range group.Members {
+			assignment, err := m.GetMemberAssignment()
+			require.NoError(t, err)
+			assigned = append(assigned, assignment.Topics["orders"]...)
+		}
+		assert.ElementsMatch(t, []int32{0, 1, 2}, assigned)
+	}
+
+	// Groups with active members don't accept offset commits
+	offsets := map[string]map[int32]int64{"orders": {0: 0}}
+	assert.True(t, errors.Is(f.CommitConsumerGroupOffsets("orders-processor", offsets), sarama.ErrUnknownMemberId))
+	require.NoError(t, f.CommitConsumerGroupOffsets("orders-archiver", offsets))
+	res, err := f.ListConsumerGroupOffsets("orders-archiver")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), res.GetBlock("orders", 0).Offset)
+}
+
+func TestFakeClusterChaos(t *testing.T) {
+	cfg := FakeConfig{}
+	cfg.SetDefaults()
+	cfg.ErrorRate = 1
+	f := NewFakeCluster(cfg, zap.NewNop())
+
+	_, err := f.ListTopics()
+	assert.True(t, errors.Is(err, ErrChaos))
+}
+
+func TestFakeClusterCreatePartitions(t *testing.T) {
+	f := newTestFakeCluster()
+	require.NoError(t, f.CreateTopic("test", 2, 1, nil, false))
+
+	assert.True(t, errors.Is(f.CreatePartitions("test", 2, false), sarama.ErrInvalidPartitions))
+	assert.True(t, errors.Is(f.CreatePartitions("missing", 3, false), sarama.ErrUnknownTopicOrPartition))
+
+	require.NoError(t, f.CreatePartitions("test", 4, true))
+	partitionIDs, err := f.ListPartitions("test")
+	require.NoError(t, err)
+	assert.Len(t, partitionIDs, 2)
+
+	require.NoError(t, f.CreatePartitions("test", 4, false))
+	waterMarks, err := f.WaterMarks("test", []int32{2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), waterMarks[3].High)
+}
@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// fakeConsumerPollInterval is the interval in which fake partition consumers check for newly produced records
+const fakeConsumerPollInterval = 200 * time.Millisecond
+
+// fakeConsumer implements sarama.Consumer on top of the in-memory partitions of a fake cluster
+type fakeConsumer struct {
+	cluster *FakeCluster
+}
+
+func (c *fakeConsumer) Topics() ([]string, error) {
+	c.cluster.mutex.RLock()
+	defer c.cluster.mutex.RUnlock()
+
+	topics := make([]string, 0, len(c.cluster.topics))
+	for name := range c.cluster.topics {
+		topics = append(topics, name)
+	}
+	sort.Strings(topics)
+
+	return topics, nil
+}
+
+func (c *fakeConsumer) Partitions(topic string) ([]int32, error) {
+	return c.cluster.ListPartitions(topic)
+}
+
+// ConsumePartition starts consuming at the given offset, which may also be sarama.OffsetOldest or
+// sarama.OffsetNewest
+func (c *fakeConsumer) ConsumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
+	if err := c.cluster.chaos(); err != nil {
+		return nil, err
+	}
+	c.cluster.mutex.RLock()
+	records, err := c.cluster.partition(topic, partition)
+	logStart := int64(0)
+	if err == nil {
+		logStart = c.cluster.logStartOffset(topic, partition)
+	}
+	c.cluster.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	switch offset {
+	case sarama.OffsetOldest:
+		offset = logStart
+	case sarama.OffsetNewest:
+		offset = int64(len(records))
+	}
+	if offset < logStart || offset > int64(len(records)) {
+		return nil, sarama.ErrOffsetOutOfRange
+	}
+
+	pc := &fakePartitionConsumer{
+		cluster:   c.cluster,
+		topic:     topic,
+		partition: partition,
+		messages:  make(chan *sarama.ConsumerMessage, 256),
+		errors:    make(chan *sarama.ConsumerError),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go pc.run(offset)
+
+	return pc, nil
+}
+
+func (c *fakeConsumer) HighWaterMarks() map[string]map[int32]int64 {
+	c.cluster.mutex.RLock()
+	defer c.cluster.mutex.RUnlock()
+
+	res := make(map[string]map[int32]int64, len(c.cluster.topics))
+	for name, topic := range c.cluster.topics {
+		res[name] = make(map[int32]int64, len(topic.Partitions))
+		for i, records := range topic.Partitions {
+			res[name][int32(i)] = int64(len(records))
+		}
+	}
+
+	return res
+}
+
+func (c *fakeConsumer) Close() error {
+	return nil
+}
+
+// fakePartitionConsumer sends all records starting at the requested offset and afterwards polls for new records
+// until it is closed
+type fakePartitionConsumer struct {
+	cluster   *FakeCluster
+	topic     string
+	partition int32
+
+	messages      chan *sarama.ConsumerMessage
+	errors        chan *sarama.ConsumerError
+	highWaterMark int64 // Accessed atomically
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+func (pc *fakePartitionConsumer) run(offset int64) {
+	defer close(pc.done)
+	defer close(pc.messages)
+	defer close(pc.errors)
+
+	for {
+		pc.cluster.mutex.RLock()
+		records, err := pc.cluster.partition(pc.topic, pc.partition)
+		if err == nil && offset < pc.cluster.logStartOffset(pc.topic, pc.partition) {
+			// Records have been deleted while consuming, continue with the first remaining record
+			offset = pc.cluster.logStartOffset(pc.topic, pc.partition)
+		}
+		pc.cluster.mutex.RUnlock()
+		if err != nil {
+			// The topic has been deleted
+			select {
+			case pc.errors <- &sarama.ConsumerError{Topic: pc.topic, Partition: pc.partition, Err: err}:
+			case <-pc.closing:
+			}
+			return
+		}
+		atomic.StoreInt64(&pc.highWaterMark, int64(len(records)))
+
+		if offset >= int64(len(records)) {
+			select {
+			case <-time.After(fakeConsumerPollInterval):
+				continue
+			case <-pc.closing:
+				return
+			}
+		}
+
+		for _, record := range records[offset:] {
+			msg := &sarama.ConsumerMessage{
+				Headers:   record.Headers,
+				Timestamp: record.Timestamp,
+				Key:       record.Key,
+				Value:     record.Value,
+				Topic:     pc.topic,
+				Partition: pc.partition,
+				Offset:    offset,
+			}
+			select {
+			case pc.messages <- msg:
+				offset++
+			case <-pc.closing:
+				return
+			}
+		}
+	}
+}
+
+func (pc *fakePartitionConsumer) AsyncClose() {
+	pc.closeOnce.Do(func() { close(pc.closing) })
+}
+
+func (pc *fakePartitionConsumer) Close() error {
+	pc.AsyncClose()
+	<-pc.done
+	return nil
+}
+
+func (pc *fakePartitionConsumer) Messages() <-chan *sarama.ConsumerMessage {
+	return pc.messages
+}
+
+func (pc *fakePartitionConsumer) Errors() <-chan *sarama.ConsumerError {
+	return pc.errors
+}
+
+func (pc *fakePartitionConsumer) HighWaterMarkOffset() int64 {
+	return atomic.LoadInt64(&pc.highWaterMark)
+}
@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// fakeDataTimeRange is the time range over which the timestamps of the generated messages are spread
+const fakeDataTimeRange = 7 * 24 * time.Hour
+
+// fakeTopicTemplate generates the demo messages of a single topic. The templates cover all message formats which
+// the frontend can display (JSON, text, binary and tombstones).
+type fakeTopicTemplate struct {
+	Name     string
+	Configs  map[string]string
+	Generate func(r *rand.Rand, i int) (key []byte, value []byte, headers []*sarama.RecordHeader)
+}
+
+var (
+	fakeFirstNames = []string{"Alice", "Bob", "Carol", "Dave", "Eve", "Frank", "Grace", "Heidi", "Ivan", "Judy"}
+	fakeCountries  = []string{"DE", "US", "GB", "FR", "NL", "SE", "JP", "BR"}
+	fakeStatuses   = []string{"created", "paid", "shipped", "delivered", "cancelled"}
+	fakeURLs       = []string{"/", "/products", "/products/42", "/cart", "/checkout", "/account"}
+	fakeActions    = []string{"created topic", "deleted topic", "reset offsets of group", "changed config of topic"}
+)
+
+var fakeTopicTemplates = []fakeTopicTemplate{
+	{
+		Name: "orders",
+		Generate: func(r *rand.Rand, i int) ([]byte, []byte, []*sarama.RecordHeader) {
+			orderID := fmt.Sprintf("order-%06d", i)
+			quantity := r.Intn(5) + 1
+			price := float64(r.Intn(10000)) / 100
+			value := fakeJSON(map[string]interface{}{
+				"orderId":    orderID,
+				"customerId": fmt.Sprintf("customer-%04d", r.Intn(100)),
+				"items":      []map[string]interface{}{{"sku": fmt.Sprintf("SKU-%03d", r.Intn(500)), "quantity": quantity, "price": price}},
+				"total":      float64(quantity) * price,
+				"currency":   "EUR",
+				"status":     fakeStatuses[r.Intn(len(fakeStatuses))],
+			})
+			headers := []*sarama.RecordHeader{
+				{Key: []byte("content-type"), Value: []byte("application/json")},
+				{Key: []byte("trace-id"), Value: []byte(fmt.Sprintf("%016x", r.Uint64()))},
+			}
+			return []byte(orderID), value, headers
+		},
+	},
+	{
+		Name:    "customers",
+		Configs: map[string]string{"cleanup.policy": "compact"},
+		Generate: func(r *rand.Rand, i int) ([]byte, []byte, []*sarama.RecordHeader) {
+			customerID := fmt.Sprintf("customer-%04d", r.Intn(100))
+			if r.Intn(20) == 0 {
+				return []byte(customerID), nil, nil // Tombstone
+			}
+			name := fakeFirstNames[r.Intn(len(fakeFirstNames))]
+			value := fakeJSON(map[string]interface{}{
+				"customerId": customerID,
+				"name":       name,
+				"email":      fmt.Sprintf("%v.%v@example.com", name, r.Intn(1000)),
+				"country":    fakeCountries[r.Intn(len(fakeCountries))],
+			})
+			return []byte(customerID), value, nil
+		},
+	},
+	{
+		Name: "payments",
+		Generate: func(r *rand.Rand, i int) ([]byte, []byte, []*sarama.RecordHeader) {
+			orderID := fmt.Sprintf("order-%06d", i)
+			value := fakeJSON(map[string]interface{}{
+				"paymentId": fmt.Sprintf("payment-%08x", r.Uint32()),
+				"orderId":   orderID,
+				"amount":    float64(r.Intn(50000)) / 100,
+				"method":    []string{"card", "paypal", "invoice"}[r.Intn(3)],
+				"succeeded": r.Intn(10) > 0,
+			})
+			return []byte(orderID), value, []*sarama.RecordHeader{{Key: []byte("content-type"), Value: []byte("application/json")}}
+		},
+	},
+	{
+		Name:    "page-views",
+		Configs: map[string]string{"retention.ms": "86400000"},
+		Generate: func(r *rand.Rand, i int) ([]byte, []byte, []*sarama.RecordHeader) {
+			value := fakeJSON(map[string]interface{}{
+				"url":        fakeURLs[r.Intn(len(fakeURLs))],
+				"sessionId":  fmt.Sprintf("%08x", r.Uint32()),
+				"durationMs": r.Intn(30000),
+			})
+			return nil, value, nil
+		},
+	},
+	{
+		Name: "audit-log",
+		Generate: func(r *rand.Rand, i int) ([]byte, []byte, []*sarama.RecordHeader) {
+			value := fmt.Sprintf("user %v %v '%v'", fakeFirstNames[r.Intn(len(fakeFirstNames))],
+				fakeActions[r.Intn(len(fakeActions))], []string{"orders", "customers", "payments"}[r.Intn(3)])
+			return nil, []byte(value), nil
+		},
+	},
+	{
+		Name: "sensor-readings",
+		Generate: func(r *rand.Rand, i int) ([]byte, []byte, []*sarama.RecordHeader) {
+			value := make([]byte, 8)
+			binary.BigEndian.PutUint64(value, math.Float64bits(15+r.Float64()*10))
+			return []byte(fmt.Sprintf("sensor-%02d", r.Intn(10))), value, nil
+		},
+	},
+}
+
+// seed creates the configured number of demo topics with messages whose timestamps are spread over the last days.
+// Each topic is consumed by an active group which is almost caught up and by an empty group which lags behind.
+func (f *FakeCluster) seed(now time.Time) {
+	replicationFactor := f.defaultReplicationFactor()
+	for _, template := range fakeTopicTemplates[:f.cfg.Topics] {
+		configs := make(map[string]string, len(template.Configs))
+		for name, value := range template.Configs {
+			configs[name] = value
+		}
+		topic := f.addTopic(template.Name, f.cfg.PartitionsPerTopic, replicationFactor, configs)
+
+		count := f.cfg.MessagesPerPartition
+		for p := range topic.Partitions {
+			records := make([]fakeRecord, count)
+			for i := range records {
+				key, value, headers := template.Generate(f.rand, p*count+i)
+				age := fakeDataTimeRange - fakeDataTimeRange*time.Duration(i)/time.Duration(count)
+				records[i] = fakeRecord{Key: key, Value: value, Headers: headers, Timestamp: now.Add(-age).Truncate(time.Millisecond)}
+			}
+			topic.Partitions[p] = records
+		}
+
+		active := &fakeGroup{Offsets: map[string]map[int32]int64{topic.Name: {}}, Members: make(map[string]*fakeGroupMember)}
+		empty := &fakeGroup{Offsets: map[string]map[int32]int64{topic.Name: {}}, Members: make(map[string]*fakeGroupMember)}
+		for p := range topic.Partitions {
+			high := int64(len(topic.Partitions[p]))
+			active.Offsets[topic.Name][int32(p)] = high - int64(f.rand.Intn(count/10+1))
+			empty.Offsets[topic.Name][int32(p)] = high / 2
+		}
+		groupID := topic.Name + "-processor"
+		for m := 0; m < 2; m++ {
+			member := &fakeGroupMember{
+				ClientID:    fmt.Sprintf("%v-%v", groupID, m),
+				ClientHost:  fmt.Sprintf("/10.0.0.%v", f.rand.Intn(250)+1),
+				Assignments: map[string][]int32{topic.Name: {}},
+			}
+			for p := m; p < len(topic.Partitions); p += 2 {
+				member.Assignments[topic.Name] = append(member.Assignments[topic.Name], int32(p))
+			}
+			active.Members[fmt.Sprintf("%v-%08x", member.ClientID, f.rand.Uint32())] = member
+		}
+		f.groups[groupID] = active
+		f.groups[topic.Name+"-archiver"] = empty
+	}
+}
+
+func fakeJSON(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
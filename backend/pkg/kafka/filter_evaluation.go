@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"go.uber.org/zap"
+)
+
+// FilterEvaluation is the outcome of running filter code against a single message
+type FilterEvaluation struct {
+	PartitionID int32  `json:"partitionId"`
+	Offset      int64  `json:"offset"`
+	IsMatch     bool   `json:"isMatch"`
+	Error       string `json:"error,omitempty"`
+}
+
+// EvaluateFilter runs the given filter code against each message and reports whether it passed the filter or
+// whether an error has been thrown. Unlike the partition consumer it does not stop at the first error, so that
+// users can see all failing messages at once. It stops, however, once the context is done or the budget (which may be
+// nil) has been exceeded.
+func EvaluateFilter(ctx context.Context, code string, limits filter.Limits, budget *filter.Budget, messages []*TopicMessage, logger *zap.Logger) ([]FilterEvaluation, error) {
+	p := &PartitionConsumer{Logger: logger, FilterInterpreterCode: code, FilterLimits: limits, FilterBudget: budget}
+	isMessageOK, err := p.SetupInterpreter()
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup interpreter: %w", err)
+	}
+
+	evaluations := make([]FilterEvaluation, len(messages))
+	for i, msg := range messages {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("filter evaluation has been aborted: %w", ctx.Err())
+		}
+		args := interpreterArguments{
+			PartitionID: msg.PartitionID,
+			Offset:      msg.Offset,
+			Timestamp:   time.Unix(msg.Timestamp, 0),
+			Key:         msg.Key,
+			Value:       msg.Value,
+			Headers:     msg.Headers,
+		}
+
+		evaluations[i] = FilterEvaluation{PartitionID: msg.PartitionID, Offset: msg.Offset}
+		isOK, err := isMessageOK(args)
+		if errors.Is(err, filter.ErrBudgetExceeded) {
+			return nil, err
+		}
+		if err != nil {
+			evaluations[i].Error = err.Error()
+			continue
+		}
+		evaluations[i].IsMatch = isOK
+	}
+
+	return evaluations, nil
+}
@@ -0,0 +1,148 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestEvaluateFilter_Limits(t *testing.T) {
+	messages := []*TopicMessage{{Value: DirectEmbedding{ValueType: valueTypeJSON, Value: []byte(`{"n": 3}`)}}}
+	limits := filter.Limits{Timeout: 5 * time.Second, MaxIterations: 1000}
+
+	// Runaway loops are stopped by the iteration limit long before the timeout, even if they catch errors
+	code := "var i = 0\nwhile (i >= 0) { try { i++ } catch (e) {} }\nreturn true"
+	evaluations, err := EvaluateFilter(context.Background(), code, limits, nil, messages, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, evaluations, 1)
+	assert.Contains(t, evaluations[0].Error, "loop iterations")
+
+	// Overwriting the hook must not disable the limit
+	code = "this.__kowlIteration = function() {}\nfor (var i = 0; i < 2000; i++) {}\nreturn true"
+	evaluations, err = EvaluateFilter(context.Background(), code, limits, nil, messages, zap.NewNop())
+	require.NoError(t, err)
+	assert.Contains(t, evaluations[0].Error, "loop iterations")
+
+	code = "var sum = 0\nfor (var i = 0; i < value.n; i++) sum += i\nreturn sum == 3"
+	evaluations, err = EvaluateFilter(context.Background(), code, limits, nil, messages, zap.NewNop())
+	require.NoError(t, err)
+	assert.Empty(t, evaluations[0].Error)
+	assert.True(t, evaluations[0].IsMatch)
+}
+
+func TestEvaluateFilter_Timeout(t *testing.T) {
+	// Parsing the large value takes longer than the timeout, but only the execution of the filter code counts
+	largeValue := "[" + strings.Repeat("1,", 1000000) + "1]"
+	messages := []*TopicMessage{
+		{Offset: 0, Value: DirectEmbedding{ValueType: valueTypeJSON, Value: []byte(`{"slow": true}`)}},
+		{Offset: 1, Value: DirectEmbedding{ValueType: valueTypeJSON, Value: []byte(`{}`)}},
+		{Offset: 2, Value: DirectEmbedding{ValueType: valueTypeJSON, Value: []byte(largeValue)}},
+	}
+	code := "if (value.slow) { var until = Date.now() + 200\nwhile (Date.now() < until) {} }\nreturn true"
+
+	// The interrupt of a timed out message must not affect the following messages
+	evaluations, err := EvaluateFilter(context.Background(), code, filter.Limits{Timeout: 20 * time.Millisecond}, nil, messages, zap.NewNop())
+	require.NoError(t, err)
+	assert.Contains(t, evaluations[0].Error, "taken too long")
+	for _, e := range evaluations[1:] {
+		assert.Empty(t, e.Error, "Offset: ", e.Offset)
+		assert.True(t, e.IsMatch, "Offset: ", e.Offset)
+	}
+}
+
+func TestEvaluateFilter_Helpers(t *testing.T) {
+	value := `{"order": {"items": [{"sku": "ord-42"}], "token": "aGVsbG8"}, "createdAt": "2021-03-04T10:00:00Z"}`
+	messages := []*TopicMessage{{
+		Timestamp: time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC).Unix(),
+		Value:     DirectEmbedding{ValueType: valueTypeJSON, Value: []byte(value)},
+	}}
+
+	tests := map[string]string{
+		`return find(value, "order.items[0].sku") == "ord-42"`:                "",
+		`return find(value, "order.items[1].sku") === undefined`:              "",
+		`return find(value, 'order["token"]') == "aGVsbG8"`:                   "",
+		`return matches(find(value, "order.items[0].sku"), "^ord-\\d+$")`:     "",
+		`return !matches(find(value, "missing"), ".*")`:                       "",
+		`return base64decode(value.order.token) == "hello"`:                   "",
+		`return dateBetween(timestamp, "2021-03-04", new Date(2021, 11, 31))`: "",
+		`return dateBetween(value.createdAt, 1614852000000, 1614852000001)`:   "",
+		`return !dateBetween(timestamp, "2021-03-05", "2021-03-06")`:          "",
+		`return matches(value, "(")`:                                          "invalid regex",
+		`return dateBetween(timestamp, "yesterday", "2021-03-06")`:            "argument 2",
+	}
+	for code, expectedErr := range tests {
+		evaluations, err := EvaluateFilter(context.Background(), code, filter.Limits{}, nil, messages, zap.NewNop())
+		require.NoError(t, err, code)
+		if expectedErr != "" {
+			assert.Contains(t, evaluations[0].Error, expectedErr, code)
+			continue
+		}
+		assert.Empty(t, evaluations[0].Error, code)
+		assert.True(t, evaluations[0].IsMatch, code)
+	}
+}
+
+func TestEvaluateFilter_Budget(t *testing.T) {
+	messages := make([]*TopicMessage, 5)
+	for i := range messages {
+		messages[i] = &TopicMessage{Offset: int64(i), Value: DirectEmbedding{ValueType: valueTypeJSON, Value: []byte(`{}`)}}
+	}
+	code := "var until = Date.now() + 5\nwhile (Date.now() < until) {}\nreturn true"
+	limits := filter.Limits{Timeout: time.Second, MaxIterations: 1000000}
+
+	// The budget is exhausted after the first message, the remaining messages must not be evaluated
+	budget := filter.NewBudget("filter test", time.Millisecond, nil)
+	_, err := EvaluateFilter(context.Background(), code, limits, budget, messages, zap.NewNop())
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, filter.ErrBudgetExceeded))
+	assert.True(t, budget.Used() < 50*time.Millisecond, "evaluation must stop once the budget is exceeded")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = EvaluateFilter(ctx, code, limits, nil, messages, zap.NewNop())
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestEvaluateFilter_Headers(t *testing.T) {
+	p := &PartitionConsumer{}
+	headers := p.getHeaders([]*sarama.RecordHeader{
+		{Key: []byte("tenant"), Value: []byte("acme")},
+		nil,
+		{Key: []byte("trace"), Value: []byte(`{"sampled": true, "id": "t-1"}`)},
+		{Key: []byte("empty"), Value: nil},
+	})
+	require.Len(t, headers, 3, "nil headers are skipped")
+	assert.Equal(t, "tenant", headers[0].Key)
+	assert.Equal(t, string(valueTypeText), headers[0].ValueType)
+	assert.Equal(t, string(valueTypeJSON), headers[1].ValueType)
+	assert.Equal(t, "", headers[2].ValueType)
+
+	messages := []*TopicMessage{
+		{Offset: 0, Headers: headers, Value: DirectEmbedding{ValueType: valueTypeJSON, Value: []byte(`{}`)}},
+		{Offset: 1, Headers: p.getHeaders([]*sarama.RecordHeader{{Key: []byte("tenant"), Value: []byte("globex")}})},
+		{Offset: 2},
+	}
+
+	tests := map[string][]bool{
+		`return headers["tenant"] == "acme"`:                          {true, false, false},
+		`return headers.trace !== undefined && headers.trace.sampled`: {true, false, false},
+		`return find(headers, "trace.id") == "t-1"`:                   {true, false, false},
+		`return headers["tenant"] === undefined`:                      {false, false, true},
+	}
+	for code, expected := range tests {
+		evaluations, err := EvaluateFilter(context.Background(), code, filter.Limits{}, nil, messages, zap.NewNop())
+		require.NoError(t, err, code)
+		for i, e := range evaluations {
+			assert.Empty(t, e.Error, code)
+			assert.Equal(t, expected[i], e.IsMatch, "Case: ", code, i)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package kafka
 
-// IsHealthy checks whether it can communicate with the Kafka cluster or not
+// IsHealthy checks whether it can communicate with the Kafka cluster or not. It's unhealthy as well once the cluster
+// id has changed.
 func (s *Service) IsHealthy() error {
+	if err := s.fingerprint.err(); err != nil {
+		return err
+	}
+
 	_, err := s.Client.Controller()
 	if err != nil {
 		return err
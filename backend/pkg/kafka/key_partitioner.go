@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// Key partitioners which derive the partition of a record from its key
+const (
+	KeyPartitionerMurmur2 = "murmur2" // Default partitioner of the Java client and of most clients based on librdkafka
+	KeyPartitionerFNV1a   = "fnv1a"   // Hash partitioner of sarama, which is used by Kowl to produce messages
+)
+
+// KeyPartition returns the partition which the given partitioner assigns the key to
+func KeyPartition(partitioner string, key []byte, partitionCount int32) (int32, error) {
+	if partitionCount <= 0 {
+		return -1, fmt.Errorf("partition count must be positive")
+	}
+
+	switch partitioner {
+	case KeyPartitionerMurmur2:
+		return int32(murmur2(key)&0x7fffffff) % partitionCount, nil
+	case KeyPartitionerFNV1a:
+		msg := &sarama.ProducerMessage{Key: sarama.ByteEncoder(key)}
+		return sarama.NewHashPartitioner("").Partition(msg, partitionCount)
+	}
+	return -1, fmt.Errorf("unknown key partitioner '%v'", partitioner)
+}
+
+// murmur2 is a port of the murmur2 implementation of the Java client (org.apache.kafka.common.utils.Utils)
+func murmur2(data []byte) uint32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
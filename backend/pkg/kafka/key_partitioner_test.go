@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMurmur2(t *testing.T) {
+	// Expected values are taken from the Java client's tests
+	tt := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for input, expected := range tt {
+		assert.Equal(t, expected, int32(murmur2([]byte(input))), "input '%v'", input)
+	}
+}
+
+func TestKeyPartition(t *testing.T) {
+	partitionID, err := KeyPartition(KeyPartitionerMurmur2, []byte("foobar"), 6)
+	require.NoError(t, err)
+	assert.Equal(t, int32((-790332482&0x7fffffff)%6), partitionID)
+
+	// Must match the partitioner which is used for producing messages
+	partitionID, err = KeyPartition(KeyPartitionerFNV1a, []byte("foobar"), 6)
+	require.NoError(t, err)
+	expected, err := newRecordPartitioner("").Partition(&sarama.ProducerMessage{Key: sarama.StringEncoder("foobar")}, 6)
+	require.NoError(t, err)
+	assert.Equal(t, expected, partitionID)
+
+	_, err = KeyPartition("unknown", []byte("foobar"), 6)
+	assert.Error(t, err)
+	_, err = KeyPartition(KeyPartitionerMurmur2, []byte("foobar"), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MessageTimestamps returns a map of: partitionID -> timestamp of the first message at or after the given offset. A
+// single message is consumed from each partition concurrently. Partitions whose message could not be consumed before
+// the context is done (e.g. because the offset is out of range) are missing in the result.
+func (s *Service) MessageTimestamps(ctx context.Context, topic string, offsets map[int32]int64) (map[int32]time.Time, error) {
+	consumer, err := s.NewConsumer(s.Client.Config().Consumer.IsolationLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			s.Logger.Warn("failed to close consumer", zap.Error(err))
+		}
+	}()
+
+	type result struct {
+		PartitionID int32
+		Timestamp   time.Time
+		Err         error
+	}
+	ch := make(chan result, len(offsets))
+	for partitionID, offset := range offsets {
+		go func(partitionID int32, offset int64) {
+			pConsumer, err := consumer.ConsumePartition(topic, partitionID, offset)
+			if err != nil {
+				ch <- result{PartitionID: partitionID, Err: err}
+				return
+			}
+			defer pConsumer.AsyncClose()
+
+			select {
+			case m := <-pConsumer.Messages():
+				ch <- result{PartitionID: partitionID, Timestamp: m.Timestamp}
+			case <-ctx.Done():
+				ch <- result{PartitionID: partitionID, Err: ctx.Err()}
+			}
+		}(partitionID, offset)
+	}
+
+	timestamps := make(map[int32]time.Time, len(offsets))
+	for i := 0; i < len(offsets); i++ {
+		r := <-ch
+		if r.Err != nil {
+			s.Logger.Debug("failed to consume message for its timestamp",
+				zap.String("topic", topic), zap.Int32("partition_id", r.PartitionID), zap.Error(r.Err))
+			continue
+		}
+		timestamps[r.PartitionID] = r.Timestamp
+	}
+
+	return timestamps, nil
+}
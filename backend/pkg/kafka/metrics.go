@@ -3,6 +3,7 @@ package kafka
 import (
 	"time"
 
+	"github.com/cloudhut/kowl/backend/pkg/collectors"
 	prometheusmetrics "github.com/deathowl/go-metrics-prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -18,3 +19,96 @@ func (s *Service) RegisterMetrics() {
 		5*time.Second)
 	go pClient.UpdatePrometheusMetrics()
 }
+
+// MessageMetrics are the prometheus metrics of message searches. All methods can be called on a nil *MessageMetrics,
+// which discards the observations.
+type MessageMetrics struct {
+	listMessagesRequests    *prometheus.CounterVec
+	messagesScanned         prometheus.Counter
+	bytesConsumed           prometheus.Counter
+	filterDuration          prometheus.Histogram
+	deserializationFailures *prometheus.CounterVec
+}
+
+// NewMessageMetrics registers the message search metrics on the default prometheus registry. Metrics which have
+// been registered with the same namespace before are reused.
+func NewMessageMetrics(namespace string) *MessageMetrics {
+	return &MessageMetrics{
+		listMessagesRequests: collectors.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "messages",
+			Name:      "list_requests_total",
+			Help:      "Number of started message searches by mode (search or live_tail)",
+		}, []string{"mode"})).(*prometheus.CounterVec),
+		messagesScanned: collectors.Register(prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "messages",
+			Name:      "scanned_total",
+			Help:      "Number of messages consumed by message searches, regardless of whether they passed the filter",
+		})).(prometheus.Counter),
+		bytesConsumed: collectors.Register(prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "messages",
+			Name:      "consumed_bytes_total",
+			Help:      "Key and value bytes consumed by message searches",
+		})).(prometheus.Counter),
+		filterDuration: collectors.Register(prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "messages",
+			Name:      "filter_duration_seconds",
+			Help:      "Time the filter code took to evaluate a single message",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		})).(prometheus.Histogram),
+		deserializationFailures: collectors.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "messages",
+			Name:      "deserialization_failures_total",
+			Help:      "Keys and values which looked like the given type (json, xml or protobuf) but couldn't be deserialized",
+		}, []string{"type"})).(*prometheus.CounterVec),
+	}
+}
+
+// OnListMessagesRequest counts a started message search
+func (m *MessageMetrics) OnListMessagesRequest(isLiveTail bool) {
+	if m == nil {
+		return
+	}
+	mode := "search"
+	if isLiveTail {
+		mode = "live_tail"
+	}
+	m.listMessagesRequests.WithLabelValues(mode).Inc()
+}
+
+func (m *MessageMetrics) onMessageConsumed(size int) {
+	if m == nil {
+		return
+	}
+	m.messagesScanned.Inc()
+	m.bytesConsumed.Add(float64(size))
+}
+
+func (m *MessageMetrics) onFilterEvaluated(duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.filterDuration.Observe(duration.Seconds())
+}
+
+func (m *MessageMetrics) onDeserializationFailure(vType valueType) {
+	if m == nil {
+		return
+	}
+	m.deserializationFailures.WithLabelValues(string(vType)).Inc()
+}
+
+// newRequestDurationHistogram creates the histogram of Kafka request durations by request name
+func newRequestDurationHistogram(namespace string) *prometheus.HistogramVec {
+	return collectors.Register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "request_duration_seconds",
+		Help:      "Time until the response of a Kafka request has been read completely, by request name",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"api"})).(*prometheus.HistogramVec)
+}
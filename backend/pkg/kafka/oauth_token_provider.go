@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// maxOAuthTokenRefreshMargin is the maximum time before the expiry at which a cached token is refreshed. Tokens
+// with a short lifetime are refreshed after 80% of it.
+const maxOAuthTokenRefreshMargin = 5 * time.Minute
+
+// oauthTokenResponse is the response of a token endpoint (RFC 6749, section 5), commands may print it as well
+type oauthTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"` // Seconds
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// oauthTokenProvider implements sarama.AccessTokenProvider. Tokens are cached until they're about to expire. Tokens
+// with an unknown lifetime aren't cached, they are fetched again for each broker connection.
+type oauthTokenProvider struct {
+	cfg        SASLOAuthConfig
+	httpClient *http.Client
+
+	mutex     sync.Mutex
+	token     string
+	refreshAt time.Time
+	expiresAt time.Time
+}
+
+func newOAuthTokenProvider(cfg SASLOAuthConfig) *oauthTokenProvider {
+	return &oauthTokenProvider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Token returns the cached token or fetches a new one. If fetching fails, the cached token is returned as long as it
+// hasn't expired.
+func (p *oauthTokenProvider) Token() (*sarama.AccessToken, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	if p.token != "" && now.Before(p.refreshAt) {
+		return p.accessToken(), nil
+	}
+
+	token, lifetime, err := p.fetch()
+	if err != nil {
+		if p.token != "" && now.Before(p.expiresAt) {
+			return p.accessToken(), nil
+		}
+		return nil, fmt.Errorf("failed to get oauth token: %w", err)
+	}
+
+	p.token = token
+	p.expiresAt = now.Add(lifetime)
+	margin := lifetime / 5
+	if margin > maxOAuthTokenRefreshMargin {
+		margin = maxOAuthTokenRefreshMargin
+	}
+	p.refreshAt = p.expiresAt.Add(-margin)
+
+	return p.accessToken(), nil
+}
+
+func (p *oauthTokenProvider) accessToken() *sarama.AccessToken {
+	return &sarama.AccessToken{Token: p.token, Extensions: p.cfg.Extensions}
+}
+
+// fetch returns a new token and its lifetime, which is 0 if unknown
+func (p *oauthTokenProvider) fetch() (string, time.Duration, error) {
+	switch p.cfg.TokenProvider {
+	case OAuthTokenProviderClientCredentials:
+		return p.fetchClientCredentials()
+	case OAuthTokenProviderFile:
+		content, err := ioutil.ReadFile(p.cfg.TokenFilepath)
+		if err != nil {
+			return "", 0, err
+		}
+		return parseOAuthToken(content)
+	case OAuthTokenProviderCommand:
+		return p.runCommand()
+	}
+	return "", 0, fmt.Errorf("unknown token provider '%v'", p.cfg.TokenProvider)
+}
+
+// fetchClientCredentials requests a token from the token endpoint, the client authenticates via basic auth
+func (p *oauthTokenProvider) fetchClientCredentials() (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(p.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, p.cfg.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var tokenRes oauthTokenResponse
+	if err := json.Unmarshal(body, &tokenRes); err != nil {
+		return "", 0, fmt.Errorf("token endpoint responded with status %v and an invalid body", res.StatusCode)
+	}
+	if res.StatusCode != http.StatusOK || tokenRes.Error != "" {
+		return "", 0, fmt.Errorf("token endpoint responded with status %v: %v %v", res.StatusCode, tokenRes.Error, tokenRes.ErrorDescription)
+	}
+	if tokenRes.AccessToken == "" {
+		return "", 0, errors.New("token response has no access token")
+	}
+
+	return tokenRes.AccessToken, time.Duration(tokenRes.ExpiresIn) * time.Second, nil
+}
+
+// runCommand runs the configured command, which prints a token or a token response
+func (p *oauthTokenProvider) runCommand() (string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.cfg.Command, p.cfg.Args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", 0, fmt.Errorf("token command failed: %w: %v", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseOAuthToken(output)
+}
+
+// parseOAuthToken parses either a token response or a plain token. The lifetime of plain tokens is taken from the
+// exp claim if the token is a JWT.
+func parseOAuthToken(content []byte) (string, time.Duration, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return "", 0, errors.New("token is empty")
+	}
+	if content[0] == '{' {
+		var tokenRes oauthTokenResponse
+		if err := json.Unmarshal(content, &tokenRes); err != nil {
+			return "", 0, fmt.Errorf("invalid token response: %w", err)
+		}
+		if tokenRes.AccessToken == "" {
+			return "", 0, errors.New("token response has no access token")
+		}
+		return tokenRes.AccessToken, time.Duration(tokenRes.ExpiresIn) * time.Second, nil
+	}
+
+	token := string(content)
+	expiresAt, ok := jwtExpiry(token)
+	if !ok {
+		return token, 0, nil
+	}
+	lifetime := time.Until(expiresAt)
+	if lifetime <= 0 {
+		return "", 0, fmt.Errorf("token has expired at %v", expiresAt.UTC().Format(time.RFC3339))
+	}
+	return token, lifetime, nil
+}
+
+// jwtExpiry returns the exp claim of a JWT without verifying it, the broker verifies the token
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,139 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthTokenProviderClientCredentials(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		user, password, _ := r.BasicAuth()
+		if user != "kowl" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"bad credentials"}`)
+			return
+		}
+		assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		assert.Equal(t, "kafka read", r.FormValue("scope"))
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, requests)
+	}))
+	defer server.Close()
+
+	cfg := SASLOAuthConfig{}
+	cfg.SetDefaults()
+	cfg.TokenEndpoint = server.URL
+	cfg.ClientID = "kowl"
+	cfg.ClientSecret = "secret"
+	cfg.Scopes = []string{"kafka", "read"}
+	cfg.Extensions = map[string]string{"logicalCluster": "lkc-1"}
+	require.NoError(t, cfg.Validate())
+
+	// The token is cached until it's about to expire
+	provider := newOAuthTokenProvider(cfg)
+	for i := 0; i < 2; i++ {
+		token, err := provider.Token()
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token.Token)
+		assert.Equal(t, cfg.Extensions, token.Extensions)
+	}
+	assert.Equal(t, 1, requests)
+
+	provider.refreshAt = time.Now().Add(-time.Second)
+	token, err := provider.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token.Token)
+
+	cfg.ClientSecret = "wrong"
+	_, err = newOAuthTokenProvider(cfg).Token()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_client")
+}
+
+func TestOAuthTokenProviderFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kowl-oauth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+
+	cfg := SASLOAuthConfig{}
+	cfg.SetDefaults()
+	cfg.TokenProvider = OAuthTokenProviderFile
+	cfg.TokenFilepath = path
+	require.NoError(t, cfg.Validate())
+	provider := newOAuthTokenProvider(cfg)
+
+	// Opaque tokens have an unknown lifetime, hence the file is read again for each token
+	require.NoError(t, ioutil.WriteFile(path, []byte("opaque-1\n"), 0600))
+	token, err := provider.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "opaque-1", token.Token)
+	require.NoError(t, ioutil.WriteFile(path, []byte("opaque-2"), 0600))
+	token, err = provider.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "opaque-2", token.Token)
+
+	// JWTs are cached until shortly before they expire
+	jwt := testJWT(time.Now().Add(time.Hour))
+	require.NoError(t, ioutil.WriteFile(path, []byte(jwt), 0600))
+	token, err = provider.Token()
+	require.NoError(t, err)
+	assert.Equal(t, jwt, token.Token)
+	require.NoError(t, ioutil.WriteFile(path, []byte("opaque-3"), 0600))
+	token, err = provider.Token()
+	require.NoError(t, err)
+	assert.Equal(t, jwt, token.Token)
+
+	// The cached token is used as long as it's valid, even if a new one can't be read
+	require.NoError(t, os.Remove(path))
+	provider.refreshAt = time.Now().Add(-time.Second)
+	token, err = provider.Token()
+	require.NoError(t, err)
+	assert.Equal(t, jwt, token.Token)
+}
+
+func TestParseOAuthToken(t *testing.T) {
+	valid := testJWT(time.Now().Add(time.Hour))
+	tt := []struct {
+		content     string
+		token       string
+		hasLifetime bool
+		isErr       bool
+	}{
+		{"opaque", "opaque", false, false},
+		{`{"access_token":"abc","expires_in":60}`, "abc", true, false},
+		{valid + "\n", valid, true, false},
+		{testJWT(time.Now().Add(-time.Hour)), "", false, true},
+		{`{"expires_in":60}`, "", false, true},
+		{`{"access_token":`, "", false, true},
+		{" \n", "", false, true},
+	}
+
+	for i, test := range tt {
+		token, lifetime, err := parseOAuthToken([]byte(test.content))
+		if test.isErr {
+			assert.Error(t, err, "Case: ", i)
+			continue
+		}
+		assert.NoError(t, err, "Case: ", i)
+		assert.Equal(t, test.token, token, "Case: ", i)
+		assert.Equal(t, test.hasLifetime, lifetime > 0, "Case: ", i)
+	}
+}
+
+func testJWT(expiresAt time.Time) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	payload := fmt.Sprintf(`{"sub":"kowl","exp":%d}`, expiresAt.Unix())
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(payload)) + ".sig"
+}
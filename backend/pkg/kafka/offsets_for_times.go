@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// OffsetsForTimes returns a map of: partitionID -> offset of the first message whose timestamp is greater than or
+// equal to the given timestamp (in unix milliseconds). The offset is -1 if no such message exists in a partition.
+func (s *Service) OffsetsForTimes(topic string, partitionIDs []int32, timestamp int64) (map[int32]int64, error) {
+	// 1. Generate an OffsetRequest for each topic:partition and bucket it to the leader broker
+	brokers := make(map[int32]*sarama.Broker)
+	reqs := make(map[int32]*sarama.OffsetRequest)
+	for _, partitionID := range partitionIDs {
+		broker, err := s.Client.Leader(topic, partitionID)
+		if err != nil {
+			return nil, err
+		}
+		id := broker.ID()
+		brokers[id] = broker
+
+		if _, ok := reqs[id]; !ok {
+			// Version 1 is required to look up offsets by timestamp
+			reqs[id] = &sarama.OffsetRequest{Version: 1}
+		}
+		reqs[id].AddBlock(topic, partitionID, timestamp, 1)
+	}
+
+	// 2. Fetch offsets in parallel (for each broker one go routine)
+	type response struct {
+		Error   error
+		Offsets *sarama.OffsetResponse
+	}
+	ch := make(chan response, len(reqs))
+	for brokerID, req := range reqs {
+		go func(b *sarama.Broker, req *sarama.OffsetRequest) {
+			res, err := b.GetAvailableOffsets(req)
+			if err != nil {
+				ch <- response{Error: err}
+				return
+			}
+			ch <- response{Offsets: res}
+		}(brokers[brokerID], req)
+	}
+
+	// 3. Process results
+	res := make(map[int32]int64, len(partitionIDs))
+	for i := 0; i < len(reqs); i++ {
+		r := <-ch
+		if r.Error != nil {
+			return nil, r.Error
+		}
+
+		for partitionID, block := range r.Offsets.Blocks[topic] {
+			if block.Err != sarama.ErrNoError {
+				return nil, fmt.Errorf("failed to get offset for partition '%v': %w", partitionID, block.Err)
+			}
+			res[partitionID] = block.Offset
+		}
+	}
+
+	return res, nil
+}
@@ -4,9 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"github.com/robertkrimen/otto"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"github.com/cloudhut/kowl/backend/pkg/rendering"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+	"github.com/dop251/goja"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -16,24 +24,62 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// defaultFilterTimeout is the time the filter code may run for a single message if no limits have been set
+	defaultFilterTimeout = 400 * time.Millisecond
+
+	// filterMaxCallStackSize limits the recursion depth of filter code
+	filterMaxCallStackSize = 256
+)
+
 type valueType string
 
 const (
-	valueTypeJSON   valueType = "json"
-	valueTypeXML    valueType = "xml"
-	valueTypeText   valueType = "text"
-	valueTypeBinary valueType = "binary"
+	valueTypeJSON     valueType = "json"
+	valueTypeXML      valueType = "xml"
+	valueTypeProtobuf valueType = "protobuf"
+	valueTypeAvro     valueType = "avro"
+	valueTypeText     valueType = "text"
+	valueTypeBinary   valueType = "binary"
 )
 
+// isJSONBased returns true for all types whose payloads are rendered as JSON
+func (v valueType) isJSONBased() bool {
+	return v == valueTypeJSON || v == valueTypeXML || v == valueTypeProtobuf || v == valueTypeAvro
+}
+
 // IListMessagesProgress specifies the methods 'ListMessages' will call on your progress-object.
 type IListMessagesProgress interface {
 	OnPhase(name string) // todo(?): eventually we might want to convert this into an enum
+	OnConsumeRequests(requests map[int32]*PartitionConsumeRequest)
 	OnMessage(message *TopicMessage)
-	OnMessageConsumed(size int64)
+	OnMessageConsumed(partitionID int32, offset int64, size int64)
+	OnMessageMatched(partitionID int32)                   // Message has passed the filter, it may still be dropped due to limits
+	OnMessagesDropped(count int64)                        // Matching messages which have not been forwarded due to throttling
+	OnInconsistency(inconsistency PartitionInconsistency) // Reported before OnComplete
+	OnWaterMarks(marks map[int32]*WaterMark)              // Refreshed periodically while the partitions are consumed
 	OnComplete(elapsedMs int64, isCancelled bool)
 	OnError(msg string)
 }
 
+// Reasons why the messages consumed from a partition may be inconsistent with the partition's log
+const (
+	// InconsistencyLeaderChanged means that the partition's leader has changed during the scan. If the new leader
+	// has been elected uncleanly, it may not have had all messages which have been consumed before.
+	InconsistencyLeaderChanged = "leaderChanged"
+
+	// InconsistencyTruncated means that the partition's log has been truncated during the scan, so that some of the
+	// consumed messages don't exist anymore and may be replaced by different messages at the same offsets.
+	InconsistencyTruncated = "truncated"
+)
+
+// PartitionInconsistency annotates the results of a partition whose leader or log has changed while it was consumed
+type PartitionInconsistency struct {
+	PartitionID int32  `json:"partitionId"`
+	Reason      string `json:"reason"`
+	Message     string `json:"message"`
+}
+
 // TopicMessage represents a single message from a given Kafka topic/partition
 type TopicMessage struct {
 	PartitionID int32 `json:"partitionID"`
@@ -45,8 +91,37 @@ type TopicMessage struct {
 	Value     DirectEmbedding `json:"value"`
 	ValueType string          `json:"valueType"`
 
+	Headers []MessageHeader `json:"headers"`
+
 	Size        int  `json:"size"`
 	IsValueNull bool `json:"isValueNull"`
+
+	// SkippedOffsets is the number of offsets between the previously consumed message of the partition and this
+	// one, which belong to records that are never returned to consumers: transaction markers, messages of aborted
+	// transactions (when reading committed messages only) or records which have been removed by compaction.
+	SkippedOffsets int64 `json:"skippedOffsets,omitempty"`
+
+	// IsTruncated is true if the value has been cut after the search's maximum value size. Truncated values are
+	// always returned as string, while ValueType is the type of the full value, which must be fetched separately.
+	IsTruncated bool `json:"isTruncated,omitempty"`
+
+	// DeserializationHints describe why the key or value has been rendered as binary or text, if it couldn't be
+	// decoded in the format it looks like. They are omitted if masking rules apply.
+	DeserializationHints *DeserializationHints `json:"deserializationHints,omitempty"`
+
+	// DecryptionError is set if the key or value of an encrypted topic couldn't be decrypted, in which case it's
+	// returned as it has been consumed
+	DecryptionError string `json:"decryptionError,omitempty"`
+
+	// Raw is the record as it has been consumed, it's only set if the search keeps raw messages
+	Raw *sarama.ConsumerMessage `json:"-"`
+}
+
+// MessageHeader is a Kafka record header whose value has been decoded like a message value
+type MessageHeader struct {
+	Key       string          `json:"key"`
+	Value     DirectEmbedding `json:"value"`
+	ValueType string          `json:"valueType"`
 }
 
 // PartitionConsumeRequest is a partitionID along with it's calculated start and end offset.
@@ -59,6 +134,14 @@ type PartitionConsumeRequest struct {
 	StartOffset     int64
 	EndOffset       int64
 	MaxMessageCount int64 // If either EndOffset or MaxMessageCount is reached the Consumer will stop.
+
+	// EndTimestamp stops the consumer once a message with a newer timestamp has been consumed. Zero means unbounded.
+	EndTimestamp time.Time
+
+	// TailWindow reads the partition backwards from EndOffset down to StartOffset, in windows which start with this
+	// many offsets and grow until MaxMessageCount messages have matched. The newest matching messages are sent in
+	// offset order. Zero reads the partition forwards.
+	TailWindow int64
 }
 
 type interpreterArguments struct {
@@ -67,8 +150,13 @@ type interpreterArguments struct {
 	Timestamp   time.Time
 	Key         DirectEmbedding
 	Value       DirectEmbedding
+	Headers     []MessageHeader
 }
 
+// MessagePredicate decides natively whether a message matches a search, e.g. a compiled search query. The timestamp
+// has the precision of the record, unlike the message's timestamp which is in seconds.
+type MessagePredicate func(msg *TopicMessage, timestamp time.Time) bool
+
 type PartitionConsumer struct {
 	Logger *zap.Logger // WithFields (topic, partitionId)
 
@@ -81,9 +169,52 @@ type PartitionConsumer struct {
 	Consumer  sarama.Consumer
 	TopicName string
 	Req       *PartitionConsumeRequest
+	ProtoSvc  *proto.Service  // May be nil if proto deserialization is disabled
+	SchemaSvc *schema.Service // May be nil if the schema registry is not configured
+
+	// KeyDecoder and ValueDecoder force the decoder of keys and values, zero values detect the type automatically
+	KeyDecoder   DecoderOverride
+	ValueDecoder DecoderOverride
 
-	VM                    *otto.Otto
 	FilterInterpreterCode string
+	FilterLimits          filter.Limits  // Zero values fall back to the default timeout without iteration limit
+	FilterBudget          *filter.Budget // Shared across all partition consumers of a search, may be nil
+
+	// InFlightBytes bounds the bytes of messages which are processed at the same time, it's shared across the
+	// partition consumers of all searches and may be nil
+	InFlightBytes *ByteBudget
+
+	// Predicate is evaluated before the filter code, messages which don't match are skipped. Nil matches all.
+	Predicate MessagePredicate
+
+	// KeyFilter skips all messages whose raw key differs before they are deserialized, nil disables the filter
+	KeyFilter []byte
+
+	// KeepRawMessages attaches the consumed records to the matching messages, e.g. so that they can be produced again
+	KeepRawMessages bool
+
+	// CanonicalJSON is applied to all keys, values and headers which are rendered as JSON
+	CanonicalJSON CanonicalJSONOptions
+
+	// BinaryEncoding is applied to binary keys, values and headers after the filter has been applied, filter code
+	// always sees base64 encoded binary payloads
+	BinaryEncoding BinaryEncoding
+
+	// MaxValueBytes truncates rendered values after the filter has been applied, 0 returns values in full
+	MaxValueBytes int
+
+	// Decrypter decrypts keys and values before their type is detected, it's nil if the topic isn't encrypted or
+	// the requester may not see decrypted messages
+	Decrypter *decryption.TopicDecrypter
+
+	// Masker replaces sensitive fields of keys, values and headers, it's nil if nothing must be masked
+	Masker *masking.Masker
+
+	// Renderer transforms fields of keys and values after the filter has been applied, so that filter code sees
+	// the raw values. It's nil if nothing must be rendered.
+	Renderer *rendering.Renderer
+
+	Metrics *MessageMetrics // May be nil
 }
 
 func (p *PartitionConsumer) Run(ctx context.Context) {
@@ -91,19 +222,6 @@ func (p *PartitionConsumer) Run(ctx context.Context) {
 		p.DoneCh <- struct{}{}
 	}()
 
-	// Create PartitionConsumer
-	pConsumer, err := p.Consumer.ConsumePartition(p.TopicName, p.Req.PartitionID, p.Req.StartOffset)
-	if err != nil {
-		p.Logger.Error("couldn't consume partition", zap.Error(err))
-		p.Progress.OnError(fmt.Sprintf("couldn't consume partition %v: %v", p.Req.PartitionID, err.Error()))
-		return
-	}
-	defer func() {
-		if errC := pConsumer.Close(); errC != nil {
-			p.Logger.Error("failed to close partition consumer", zap.Error(errC))
-		}
-	}()
-
 	// Setup JS interpreter
 	isMessageOK, err := p.SetupInterpreter()
 	if err != nil {
@@ -112,6 +230,65 @@ func (p *PartitionConsumer) Run(ctx context.Context) {
 		return
 	}
 
+	if p.Req.TailWindow > 0 {
+		p.runBackwards(ctx, isMessageOK)
+		return
+	}
+
+	pConsumer, ok := p.consumePartition(p.Req.StartOffset)
+	if !ok {
+		return
+	}
+	defer p.closePartitionConsumer(pConsumer)
+
+	p.consume(ctx, pConsumer, isMessageOK, p.Req.StartOffset, p.Req.EndOffset, p.Req.MaxMessageCount, p.send)
+}
+
+// consumePartition creates the sarama partition consumer, errors are reported to the progress
+func (p *PartitionConsumer) consumePartition(startOffset int64) (sarama.PartitionConsumer, bool) {
+	pConsumer, err := p.Consumer.ConsumePartition(p.TopicName, p.Req.PartitionID, startOffset)
+	if err != nil {
+		p.Logger.Error("couldn't consume partition", zap.Error(err))
+		p.Progress.OnError(fmt.Sprintf("couldn't consume partition %v: %v", p.Req.PartitionID, err.Error()))
+		return nil, false
+	}
+
+	return pConsumer, true
+}
+
+func (p *PartitionConsumer) closePartitionConsumer(pConsumer sarama.PartitionConsumer) {
+	if err := pConsumer.Close(); err != nil {
+		p.Logger.Error("failed to close partition consumer", zap.Error(err))
+	}
+}
+
+// send forwards a matching message, it returns false if the search has been aborted
+func (p *PartitionConsumer) send(ctx context.Context, msg *TopicMessage) bool {
+	// This is necessary because receiver might have quit before we processed the ctx.Done() and therefore
+	// the channel might be blocked which would eventually mean a goroutine leak.
+	select {
+	case <-ctx.Done():
+		return false
+	case p.MessageCh <- msg:
+		// Message successfully sent via channel
+		return true
+	}
+}
+
+// consume processes the messages of the partition consumer until a message at or after the end offset has been
+// consumed or maxMessageCount messages have matched. Matching messages are passed to emit. Messages after the end
+// offset are skipped. It returns true if the consumer stopped because it has reached either limit and false if the
+// search has been aborted or has failed. nextOffset is the first expected offset, it's unknown (negative) if
+// consuming starts at the newest offset.
+func (p *PartitionConsumer) consume(ctx context.Context, pConsumer sarama.PartitionConsumer, isMessageOK func(args interpreterArguments) (bool, error),
+	nextOffset int64, endOffset int64, maxMessageCount int64, emit func(ctx context.Context, msg *TopicMessage) bool) bool {
+	// The bytes of the message which is being processed are held until it has been sent or skipped. They are
+	// released before sending, so that consumers which wait for a slow receiver don't hold any bytes.
+	heldBytes := int64(0)
+	defer func() {
+		p.InFlightBytes.Release(heldBytes)
+	}()
+
 	messageCount := int64(0)
 	for {
 		select {
@@ -119,14 +296,61 @@ func (p *PartitionConsumer) Run(ctx context.Context) {
 			if !ok {
 				p.Logger.Error("partition Consumer message channel has unexpectedly closed")
 				p.Progress.OnError(fmt.Sprintf("partition Consumer (partitionId=%v) failed to get the next message (see server log)", p.Req.PartitionID))
-				return
+				return false
+			}
+			if m.Offset > endOffset {
+				return true // the end offset has been skipped, e.g. because it's a transaction marker
 			}
 			messageSize := len(m.Key) + len(m.Value)
-			p.Progress.OnMessageConsumed(int64(messageSize))
+			p.Progress.OnMessageConsumed(m.Partition, m.Offset, int64(messageSize))
+			p.Metrics.onMessageConsumed(messageSize)
+
+			if !p.Req.EndTimestamp.IsZero() && m.Timestamp.After(p.Req.EndTimestamp) {
+				return true // reached end timestamp
+			}
+			if p.KeyFilter != nil && !bytes.Equal(m.Key, p.KeyFilter) {
+				nextOffset = m.Offset + 1
+				if m.Offset >= endOffset {
+					return true // reached end offset
+				}
+				continue
+			}
+			if err := p.InFlightBytes.Acquire(ctx, int64(messageSize)); err != nil {
+				return false // search request aborted
+			}
+			heldBytes = int64(messageSize)
+
+			// Decryption must be applied before the type detection, so that decrypted payloads are decoded like any
+			// other payload
+			rawKey, rawValue, decryptionErr := p.decrypt(ctx, m)
 
 			// Run Interpreter filter and check if message passes the filter
-			vType, value := p.getValue(m.Value)
-			kType, key := p.getValue(m.Key)
+			vType, value, vFailed := p.decode(ctx, rawValue, proto.RecordValue, p.ValueDecoder)
+			kType, key, kFailed := p.decode(ctx, rawKey, proto.RecordKey, p.KeyDecoder)
+			hints := p.getDeserializationHints(
+				payloadDecoding{payload: rawKey, detected: kType, failed: kFailed},
+				payloadDecoding{payload: rawValue, detected: vType, failed: vFailed},
+			)
+			headers := p.getHeaders(m.Headers)
+			if p.Masker != nil {
+				// Masking must be applied before the filter, so that filter code can't probe masked values
+				key, value = p.mask(key), p.mask(value)
+				kType, vType = key.ValueType, value.ValueType
+				for i := range headers {
+					if p.Masker.MasksHeader(headers[i].Key) {
+						headers[i].Value = p.maskPlaceholder()
+					} else {
+						headers[i].Value = p.mask(headers[i].Value)
+					}
+					headers[i].ValueType = string(headers[i].Value.ValueType)
+				}
+			}
+			if p.CanonicalJSON.IsEnabled() {
+				key, value = p.canonicalize(key), p.canonicalize(value)
+				for i := range headers {
+					headers[i].Value = p.canonicalize(headers[i].Value)
+				}
+			}
 
 			topicMessage := &TopicMessage{
 				PartitionID: m.Partition,
@@ -136,9 +360,20 @@ func (p *PartitionConsumer) Run(ctx context.Context) {
 				KeyType:     string(kType),
 				Value:       value,
 				ValueType:   string(vType),
+				Headers:     headers,
 				Size:        len(m.Value),
 				IsValueNull: m.Value == nil,
+
+				DeserializationHints: hints,
+				DecryptionError:      decryptionErr,
 			}
+			if p.KeepRawMessages {
+				topicMessage.Raw = m
+			}
+			if nextOffset >= 0 && m.Offset > nextOffset {
+				topicMessage.SkippedOffsets = m.Offset - nextOffset
+			}
+			nextOffset = m.Offset + 1
 
 			// Check if message passes filter code
 			args := interpreterArguments{
@@ -147,42 +382,254 @@ func (p *PartitionConsumer) Run(ctx context.Context) {
 				Timestamp:   m.Timestamp,
 				Key:         key,
 				Value:       value,
+				Headers:     headers,
 			}
 
-			isOK, err := isMessageOK(args)
+			// The native predicate is cheap compared to the filter code, hence it's evaluated first
+			isOK, err := true, error(nil)
+			if p.Predicate != nil {
+				isOK = p.Predicate(topicMessage, m.Timestamp)
+			}
+			if isOK {
+				filterStart := time.Now()
+				isOK, err = isMessageOK(args)
+				if p.FilterInterpreterCode != "" {
+					p.Metrics.onFilterEvaluated(time.Since(filterStart))
+				}
+			}
+			if errors.Is(err, filter.ErrBudgetExceeded) {
+				p.Logger.Debug("stopping partition consumer because filter budget has been exceeded", zap.Error(err))
+				p.Progress.OnError(err.Error())
+				return false
+			}
 			if err != nil {
 				// TODO: This might be changed to debug level, because operators probably do not care about user failures?
 				p.Logger.Info("failed to check if message is ok", zap.Error(err))
 				p.Progress.OnError(fmt.Sprintf("failed to check if message is ok (partition: '%v', offset: '%v')", m.Partition, m.Offset))
-				return
+				return false
 			}
 			if isOK {
 				messageCount++
-
-				// This is necessary because receiver might have quit before we processed the ctx.Done() and therefore
-				// the channel might be blocked which would eventually mean a goroutine leak.
-				select {
-				case <-ctx.Done():
-					return
-				case p.MessageCh <- topicMessage:
-					// Message successfully sent via channel
+				p.Progress.OnMessageMatched(m.Partition)
+				if p.Renderer != nil {
+					topicMessage.Key, topicMessage.Value = p.render(topicMessage.Key), p.render(topicMessage.Value)
+				}
+				if p.BinaryEncoding == BinaryEncodingHexDump {
+					topicMessage.Key = encodeBinary(topicMessage.Key, p.BinaryEncoding)
+					topicMessage.Value = encodeBinary(topicMessage.Value, p.BinaryEncoding)
+					for i := range topicMessage.Headers {
+						topicMessage.Headers[i].Value = encodeBinary(topicMessage.Headers[i].Value, p.BinaryEncoding)
+					}
+				}
+				topicMessage.Value, topicMessage.IsTruncated = truncatePayload(topicMessage.Value, p.MaxValueBytes)
+				p.InFlightBytes.Release(heldBytes)
+				heldBytes = 0
+				if !emit(ctx, topicMessage) {
+					return false
 				}
 			}
+			p.InFlightBytes.Release(heldBytes)
+			heldBytes = 0
 
-			if m.Offset >= p.Req.EndOffset || messageCount == p.Req.MaxMessageCount {
-				return // reached end offset
+			if m.Offset >= endOffset || messageCount == maxMessageCount {
+				return true // reached end offset
 			}
 		case <-ctx.Done():
 			p.Logger.Debug("consume request aborted because context has been cancelled")
-			return // search request aborted
+			return false // search request aborted
 		}
 	}
 }
 
+// decrypt returns the decrypted key and value of the message. Payloads which can't be decrypted are returned as
+// they have been consumed, along with the reason why decryption has failed.
+func (p *PartitionConsumer) decrypt(ctx context.Context, m *sarama.ConsumerMessage) ([]byte, []byte, string) {
+	key, value, keyErr, valueErr := DecryptMessage(ctx, p.Decrypter, m)
+
+	var errs []string
+	if keyErr != nil {
+		p.Logger.Debug("failed to decrypt key", zap.Int64("offset", m.Offset), zap.Error(keyErr))
+		errs = append(errs, fmt.Sprintf("key: %v", keyErr))
+	}
+	if valueErr != nil {
+		p.Logger.Debug("failed to decrypt value", zap.Int64("offset", m.Offset), zap.Error(valueErr))
+		errs = append(errs, fmt.Sprintf("value: %v", valueErr))
+	}
+
+	return key, value, strings.Join(errs, "; ")
+}
+
+// DecryptMessage returns the decrypted key and value of the message. Payloads which can't be decrypted are returned
+// as they have been consumed, along with the error of their decryption. The decrypter may be nil, in which case the
+// message isn't decrypted.
+func DecryptMessage(ctx context.Context, decrypter *decryption.TopicDecrypter, m *sarama.ConsumerMessage) (key []byte, value []byte, keyErr error, valueErr error) {
+	if decrypter == nil {
+		return m.Key, m.Value, nil, nil
+	}
+
+	headers := make(map[string][]byte, len(m.Headers))
+	for _, h := range m.Headers {
+		if h != nil {
+			headers[string(h.Key)] = h.Value
+		}
+	}
+
+	key, keyErr = decrypter.DecryptKey(ctx, m.Key, headers)
+	if keyErr != nil {
+		key = m.Key
+	}
+	value, valueErr = decrypter.DecryptValue(ctx, m.Value, headers)
+	if valueErr != nil {
+		value = m.Value
+	}
+
+	return key, value, keyErr, valueErr
+}
+
 // getValue returns the valueType along with it's DirectEmbedding which implements a custom Marshaller,
-// so that it can return a string in the desired representation, regardless whether it's binary, text, xml
-// or JSON data.
-func (p *PartitionConsumer) getValue(value []byte) (valueType, DirectEmbedding) {
+// so that it can return a string in the desired representation, regardless whether it's binary, text, xml,
+// protobuf or JSON data.
+func (p *PartitionConsumer) getValue(value []byte, property proto.RecordPropertyType) (valueType, DirectEmbedding) {
+	if len(value) == 0 {
+		return "", DirectEmbedding{ValueType: "", Value: value}
+	}
+
+	// 0. Try to deserialize with the proto type which has been mapped to this topic. This must be checked first,
+	// because Protobuf payloads may accidentally pass the checks below (e.g. as valid UTF-8).
+	if p.ProtoSvc != nil {
+		json, err := p.ProtoSvc.UnmarshalPayload(value, p.TopicName, property)
+		if err == nil {
+			return valueTypeProtobuf, DirectEmbedding{ValueType: valueTypeProtobuf, Value: json}
+		}
+		if err != proto.ErrNoMapping {
+			p.Logger.Debug("failed to deserialize payload with mapped proto type", zap.Error(err))
+			p.Metrics.onDeserializationFailure(valueTypeProtobuf)
+		}
+	}
+
+	vType, embedding := detectValueType(value)
+	if failedType := failedValueType(value, vType); failedType != "" {
+		p.Metrics.onDeserializationFailure(failedType)
+	}
+	return vType, embedding
+}
+
+// payloadDecoding is the outcome of decoding a key or value. Failed is the forced decoder's attempt if it has failed.
+type payloadDecoding struct {
+	payload  []byte
+	detected valueType
+	failed   *DecoderAttempt
+}
+
+// getDeserializationHints diagnoses keys and values which have not been decoded in the format they look like, or
+// whose forced decoder has failed. Payloads decoded by their forced decoder don't need hints. The reasons of failed
+// decoders may quote parts of the payload, hence no hints are returned if masking rules apply.
+func (p *PartitionConsumer) getDeserializationHints(key payloadDecoding, value payloadDecoding) *DeserializationHints {
+	if p.Masker != nil {
+		return nil
+	}
+
+	keyDiagnosis := p.diagnoseDecoding(key, proto.RecordKey, p.KeyDecoder)
+	valueDiagnosis := p.diagnoseDecoding(value, proto.RecordValue, p.ValueDecoder)
+	if keyDiagnosis == nil && valueDiagnosis == nil {
+		return nil
+	}
+
+	return &DeserializationHints{Key: keyDiagnosis, Value: valueDiagnosis}
+}
+
+// diagnoseDecoding returns the diagnosis of a key or value, or nil if it doesn't need hints
+func (p *PartitionConsumer) diagnoseDecoding(dec payloadDecoding, property proto.RecordPropertyType, override DecoderOverride) *PayloadDiagnosis {
+	if dec.failed == nil && (override.Type != DecoderAuto || !needsDeserializationHints(dec.payload, dec.detected)) {
+		return nil
+	}
+
+	d := diagnosePayload(dec.payload, p.TopicName, property, p.ProtoSvc)
+	if dec.failed != nil {
+		// The automatic detection has determined the rendered type after the forced decoder has failed
+		d.Attempts = append([]DecoderAttempt{*dec.failed}, d.Attempts...)
+	}
+	return &d
+}
+
+// mask replaces the masked fields of JSON based payloads. Payloads which can't be parsed are replaced entirely, so
+// that sensitive data never leaves the backend.
+func (p *PartitionConsumer) mask(d DirectEmbedding) DirectEmbedding {
+	if len(d.Value) == 0 {
+		return d
+	}
+	if p.Masker.MasksWholePayload() {
+		return p.maskPlaceholder()
+	}
+	if !d.ValueType.isJSONBased() {
+		return d
+	}
+
+	masked, err := p.Masker.MaskJSON(d.Value)
+	if err != nil {
+		p.Logger.Debug("failed to mask json payload, masking the whole payload", zap.Error(err))
+		return p.maskPlaceholder()
+	}
+
+	return DirectEmbedding{ValueType: d.ValueType, Value: masked}
+}
+
+func (p *PartitionConsumer) maskPlaceholder() DirectEmbedding {
+	return DirectEmbedding{ValueType: valueTypeText, Value: []byte(p.Masker.Placeholder())}
+}
+
+// render transforms the fields of JSON based payloads which are selected by the renderer. Payloads which can't be
+// parsed are returned unchanged.
+func (p *PartitionConsumer) render(d DirectEmbedding) DirectEmbedding {
+	if !d.ValueType.isJSONBased() {
+		return d
+	}
+
+	rendered, err := p.Renderer.RenderJSON(d.Value)
+	if err != nil {
+		p.Logger.Debug("failed to render json payload", zap.Error(err))
+		return d
+	}
+
+	return DirectEmbedding{ValueType: d.ValueType, Value: rendered}
+}
+
+// canonicalize renders JSON based payloads according to the requested canonical JSON options. Payloads which
+// can't be parsed are returned unchanged.
+func (p *PartitionConsumer) canonicalize(d DirectEmbedding) DirectEmbedding {
+	if !d.ValueType.isJSONBased() {
+		return d
+	}
+
+	canonical, err := CanonicalizeJSON(d.Value, p.CanonicalJSON)
+	if err != nil {
+		p.Logger.Debug("failed to canonicalize json payload", zap.Error(err))
+		return d
+	}
+
+	return DirectEmbedding{ValueType: d.ValueType, Value: canonical}
+}
+
+// getHeaders decodes all record header values with the same type detection that is used for keys and values
+func (p *PartitionConsumer) getHeaders(recordHeaders []*sarama.RecordHeader) []MessageHeader {
+	headers := make([]MessageHeader, 0, len(recordHeaders))
+	for _, h := range recordHeaders {
+		if h == nil {
+			continue
+		}
+		vType, value := detectValueType(h.Value)
+		headers = append(headers, MessageHeader{
+			Key:       string(h.Key),
+			Value:     value,
+			ValueType: string(vType),
+		})
+	}
+
+	return headers
+}
+
+// detectValueType guesses the type of the given payload (JSON, XML, text or binary) by trying to parse it
+func detectValueType(value []byte) (valueType, DirectEmbedding) {
 	if len(value) == 0 {
 		return "", DirectEmbedding{ValueType: "", Value: value}
 	}
@@ -221,6 +668,38 @@ func (p *PartitionConsumer) getValue(value []byte) (valueType, DirectEmbedding)
 	return valueTypeBinary, DirectEmbedding{ValueType: valueTypeBinary, Value: b64}
 }
 
+// PayloadText returns the payload as text like it's shown in the frontend, XML is converted to JSON. IsJSON is true
+// for JSON and XML payloads. Nil is returned for binary payloads.
+func PayloadText(value []byte) (text []byte, isJSON bool) {
+	vType, embedding := detectValueType(value)
+	switch vType {
+	case valueTypeJSON, valueTypeXML:
+		return embedding.Value, true
+	case valueTypeText:
+		return embedding.Value, false
+	}
+	return nil, false
+}
+
+// failedValueType returns the type a payload looks like if it has been detected as text or binary, e.g. json for
+// payloads which start with a curly bracket. It's empty if the payload doesn't look like json or xml.
+func failedValueType(value []byte, detected valueType) valueType {
+	if detected != valueTypeText && detected != valueTypeBinary {
+		return ""
+	}
+	trimmed := bytes.TrimLeft(value, " \t\r\n")
+	if len(trimmed) == 0 {
+		return ""
+	}
+	switch trimmed[0] {
+	case '{', '[':
+		return valueTypeJSON
+	case '<':
+		return valueTypeXML
+	}
+	return ""
+}
+
 // SetupInterpreter initializes the JavaScript interpreter along with the given JS code. It returns a wrapper function
 // which accepts all Kafka message properties (offset, key, value, ...) and returns true (message shall be returned) or false
 // (message shall be filtered).
@@ -230,55 +709,60 @@ func (p *PartitionConsumer) SetupInterpreter() (func(args interpreterArguments)
 		return func(args interpreterArguments) (bool, error) { return true, nil }, nil
 	}
 
-	vm := otto.New()
-	vm.Interrupt = make(chan func(), 1)
-
-	code := fmt.Sprintf(`interpreter = {isMessageOk: function(partitionId, offset, timestamp, key, value) {%s}}`, p.FilterInterpreterCode)
-	_, err := vm.Run(code)
-	if err != nil {
-		return nil, fmt.Errorf("failed to compile given interpreter code: %w", err)
+	timeout := p.FilterLimits.Timeout
+	if timeout <= 0 {
+		timeout = defaultFilterTimeout
 	}
+	errTimeout := fmt.Errorf("interpreter execution has taken too long")
+	errIterations := fmt.Errorf("interpreter execution has exceeded the limit of %v loop iterations and function calls", p.FilterLimits.MaxIterations)
 
-	interpreter, err := vm.Object("interpreter")
-	if err != nil {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(filterMaxCallStackSize)
+	if err := filter.RegisterHelpers(vm); err != nil {
 		return nil, err
 	}
-
-	// We use named return parameter here because this way we can return a error message in recover().
-	// Returning a proper error is important because we want to stop the consumer for this partition
-	// if we exceed the execution timeout.
-	isMessageOk := func(args interpreterArguments) (isOk bool, err error) {
-		// 1. Setup timeout check. If execution takes longer than 400ms the VM will be killed
-		// Ctx is used to notify the below go routine once we are done
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-
-		errTimeout := "interpreter execution has taken too long"
-		defer func() {
-			if caught := recover(); caught != nil {
-				if caught == errTimeout {
-					err = fmt.Errorf(errTimeout)
-					return
-				}
-				panic(caught) // Something else happened, repanic!
+	interpreterCode := p.FilterInterpreterCode
+	iterations := 0
+	if p.FilterLimits.MaxIterations > 0 {
+		instrumented, err := filter.Instrument(interpreterCode)
+		if err != nil {
+			// The code is still bound by the timeout, hence we don't reject code which can't be instrumented
+			p.Logger.Info("failed to instrument filter code, only the timeout applies", zap.Error(err))
+		} else {
+			interpreterCode = instrumented
+		}
+		// The hook interrupts the VM, so that filter code can't catch the error. It's read only, so that it can't be
+		// overwritten by the filter code.
+		hook := func() {
+			iterations++
+			if iterations > p.FilterLimits.MaxIterations {
+				vm.Interrupt(errIterations)
 			}
-		}()
+		}
+		err = vm.GlobalObject().DefineDataProperty(filter.IterationHook, vm.ToValue(hook), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+		if err != nil {
+			return nil, fmt.Errorf("failed to define iteration hook: %w", err)
+		}
+	}
 
-		// Send interrupt signal to VM if execution has taken too long
-		go func() {
-			timer := time.NewTimer(400 * time.Millisecond)
+	code := fmt.Sprintf(`var isMessageOk = function(%s) {%s}`, filter.FunctionParameters, interpreterCode)
+	_, err := vm.RunString(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile given interpreter code: %w", err)
+	}
 
-			select {
-			case <-timer.C:
-				vm.Interrupt <- func() { panic(errTimeout) }
-				return
-			case <-ctx.Done():
-				return
-			}
-		}()
+	isMessageOkFunc, ok := goja.AssertFunction(vm.Get("isMessageOk"))
+	if !ok {
+		return nil, fmt.Errorf("failed to get isMessageOk function from interpreter")
+	}
+
+	// invocation identifies the running call of the filter function. Timers of completed calls may still fire, they
+	// must not interrupt the VM once it has moved on to the next message.
+	var invocationMutex sync.Mutex
+	invocation := uint64(0)
 
-		// 2. Run JavaScript code inside of VM
-		// Parse kafka key and message to a Go type (interface{} for JSON, string for text, etc)
+	isMessageOk := func(args interpreterArguments) (bool, error) {
+		// 1. Parse kafka key and message to a Go type (interface{} for JSON, string for text, etc)
 		key, err := args.Key.Parse()
 		if err != nil {
 			return false, fmt.Errorf("failed to parse key (partition '%v', offset '%v')", args.PartitionID, args.Offset)
@@ -287,18 +771,62 @@ func (p *PartitionConsumer) SetupInterpreter() (func(args interpreterArguments)
 		if err != nil {
 			return false, fmt.Errorf("failed to parse value (partition '%v', offset '%v')", args.PartitionID, args.Offset)
 		}
+		headers := make(map[string]interface{}, len(args.Headers))
+		for _, h := range args.Headers {
+			headerValue, err := h.Value.Parse()
+			if err != nil {
+				return false, fmt.Errorf("failed to parse header '%v' (partition '%v', offset '%v')", h.Key, args.PartitionID, args.Offset)
+			}
+			headers[h.Key] = headerValue
+		}
 
-		// Call Javascript function and check if it could be evaluated and whether it returned true or false
-		val, err := interpreter.Call("isMessageOk", args.PartitionID, args.Offset, args.Timestamp, key, value)
-		if err != nil {
-			return false, fmt.Errorf("failed to evaluate javascript code: %w", err)
+		// 2. Setup timeout check, parsing doesn't count against the timeout. If execution takes longer than the
+		// timeout the VM will be interrupted. The interrupt must be cleared afterwards, otherwise it may interrupt the
+		// next invocation.
+		iterations = 0
+		invocationMutex.Lock()
+		invocation++
+		current := invocation
+		invocationMutex.Unlock()
+		timer := time.AfterFunc(timeout, func() {
+			invocationMutex.Lock()
+			defer invocationMutex.Unlock()
+			if invocation == current {
+				vm.Interrupt(errTimeout)
+			}
+		})
+		defer func() {
+			timer.Stop()
+			invocationMutex.Lock()
+			defer invocationMutex.Unlock()
+			invocation++
+			vm.ClearInterrupt()
+		}()
+
+		// 3. Call Javascript function and check if it could be evaluated and whether it returned true or false
+		executionTimer := filter.StartExecutionTimer()
+		val, err := isMessageOkFunc(goja.Undefined(),
+			vm.ToValue(args.PartitionID),
+			vm.ToValue(args.Offset),
+			vm.ToValue(args.Timestamp),
+			vm.ToValue(key),
+			vm.ToValue(value),
+			vm.ToValue(headers),
+		)
+		if budgetErr := p.FilterBudget.Consume(executionTimer.Stop()); budgetErr != nil {
+			return false, budgetErr
 		}
-		isOk, err = val.ToBoolean()
 		if err != nil {
-			return false, fmt.Errorf("failed to cast return type to boolean: %w", err)
+			if interrupted, isInterrupted := err.(*goja.InterruptedError); isInterrupted {
+				if interrupted.Value() == errIterations {
+					return false, errIterations
+				}
+				return false, errTimeout
+			}
+			return false, fmt.Errorf("failed to evaluate javascript code: %w", err)
 		}
 
-		return isOk, nil
+		return val.ToBoolean(), nil
 	}
 
 	return isMessageOk, nil
@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"context"
+	"math"
+)
+
+// maxTailWindow limits the growth of the windows in which partitions are read backwards
+const maxTailWindow = 100000
+
+// runBackwards reads the partition in windows from the end offset towards the start offset, until MaxMessageCount
+// messages have matched or the start offset has been reached. Only the newest matches are kept, they are sent once
+// all windows have been consumed.
+func (p *PartitionConsumer) runBackwards(ctx context.Context, isMessageOK func(args interpreterArguments) (bool, error)) {
+	// matches are in offset order, the matches of each (older) window are prepended
+	var matches []*TopicMessage
+	window := p.Req.TailWindow
+	endOffset := p.Req.EndOffset
+	for endOffset >= p.Req.StartOffset && int64(len(matches)) < p.Req.MaxMessageCount {
+		startOffset := endOffset - window + 1
+		if startOffset < p.Req.StartOffset {
+			startOffset = p.Req.StartOffset
+		}
+
+		// Only the newest of the window's matches are needed
+		remaining := p.Req.MaxMessageCount - int64(len(matches))
+		windowMatches := make([]*TopicMessage, 0)
+		collect := func(_ context.Context, msg *TopicMessage) bool {
+			windowMatches = append(windowMatches, msg)
+			if int64(len(windowMatches)) > remaining {
+				windowMatches = windowMatches[1:]
+			}
+			return true
+		}
+
+		pConsumer, ok := p.consumePartition(startOffset)
+		if !ok {
+			return
+		}
+		completed := p.consume(ctx, pConsumer, isMessageOK, startOffset, endOffset, math.MaxInt64, collect)
+		p.closePartitionConsumer(pConsumer)
+		if !completed {
+			return
+		}
+
+		matches = append(windowMatches, matches...)
+		endOffset = startOffset - 1
+		window *= 2
+		if window > maxTailWindow {
+			window = maxTailWindow
+		}
+	}
+
+	for _, msg := range matches {
+		if !p.send(ctx, msg) {
+			return
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package kafka
+
+import (
+	"fmt"
+)
+
+// PartitionLeaders returns a map of: partitionID -> brokerID of the partition's leader. The topic's metadata is
+// refreshed first, so that leader changes since the last metadata request are taken into account.
+func (s *Service) PartitionLeaders(topic string, partitionIDs []int32) (map[int32]int32, error) {
+	if err := s.Client.RefreshMetadata(topic); err != nil {
+		return nil, fmt.Errorf("failed to refresh metadata of topic '%v': %w", topic, err)
+	}
+
+	leaders := make(map[int32]int32, len(partitionIDs))
+	for _, partitionID := range partitionIDs {
+		broker, err := s.Client.Leader(topic, partitionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get leader of partition '%v': %w", partitionID, err)
+		}
+		leaders[partitionID] = broker.ID()
+	}
+
+	return leaders, nil
+}
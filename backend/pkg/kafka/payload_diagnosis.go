@@ -0,0 +1,172 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	xj "github.com/basgys/goxml2json"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"github.com/valyala/fastjson"
+)
+
+// payloadPreviewBytes is the number of leading bytes of a payload which are included in its diagnosis
+const payloadPreviewBytes = 64
+
+// Results of a single decoder attempt
+const (
+	DecoderSucceeded = "succeeded"
+	DecoderFailed    = "failed"
+	DecoderSkipped   = "skipped" // The payload doesn't look like the decoder's format, or the decoder is not configured
+)
+
+// DecoderAttempt describes why a payload could or could not be decoded by a single decoder
+type DecoderAttempt struct {
+	Decoder string `json:"decoder"` // protobuf, json, xml or text
+	Result  string `json:"result"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// PayloadDiagnosis explains the value type which has been detected for a key or value. The attempts are listed in
+// the order the decoders are tried in, up to the first one which has succeeded.
+type PayloadDiagnosis struct {
+	Size         int              `json:"size"`
+	IsNull       bool             `json:"isNull"`
+	DetectedType string           `json:"detectedType"`
+	Attempts     []DecoderAttempt `json:"attempts"`
+
+	// Hints name well-known formats a binary payload looks like, e.g. the schema registry's wire format
+	Hints []string `json:"hints,omitempty"`
+
+	// Preview is a hex dump of the payload's leading bytes
+	Preview string `json:"preview,omitempty"`
+}
+
+// DeserializationHints explain why a message's key or value has been rendered as binary or text, although it looks
+// like a different format. Keys and values which have been decoded as expected are nil.
+type DeserializationHints struct {
+	Key   *PayloadDiagnosis `json:"key,omitempty"`
+	Value *PayloadDiagnosis `json:"value,omitempty"`
+}
+
+// DiagnosePayload decodes the payload like it's decoded when messages are consumed, but records the result and the
+// error of every decoder. The proto service may be nil if proto deserialization is disabled.
+func DiagnosePayload(value []byte, topicName string, property proto.RecordPropertyType, protoSvc *proto.Service) PayloadDiagnosis {
+	d := diagnosePayload(value, topicName, property, protoSvc)
+	if len(value) > 0 {
+		preview := value
+		if len(preview) > payloadPreviewBytes {
+			preview = preview[:payloadPreviewBytes]
+		}
+		d.Preview = hex.Dump(preview)
+	}
+
+	return d
+}
+
+// diagnosePayload returns the diagnosis without preview
+func diagnosePayload(value []byte, topicName string, property proto.RecordPropertyType, protoSvc *proto.Service) PayloadDiagnosis {
+	d := PayloadDiagnosis{
+		Size:     len(value),
+		IsNull:   value == nil,
+		Attempts: make([]DecoderAttempt, 0, 4),
+	}
+	if len(value) == 0 {
+		return d
+	}
+
+	succeeded := func(decoder string, vType valueType) PayloadDiagnosis {
+		d.Attempts = append(d.Attempts, DecoderAttempt{Decoder: decoder, Result: DecoderSucceeded})
+		d.DetectedType = string(vType)
+		return d
+	}
+	attempt := func(decoder string, result string, reason string) {
+		d.Attempts = append(d.Attempts, DecoderAttempt{Decoder: decoder, Result: result, Reason: reason})
+	}
+
+	// 1. Protobuf, if a proto type has been mapped to the topic
+	if protoSvc == nil {
+		attempt(string(valueTypeProtobuf), DecoderSkipped, "proto deserialization is disabled")
+	} else {
+		_, err := protoSvc.UnmarshalPayload(value, topicName, property)
+		if err == nil {
+			return succeeded(string(valueTypeProtobuf), valueTypeProtobuf)
+		}
+		if err == proto.ErrNoMapping {
+			attempt(string(valueTypeProtobuf), DecoderSkipped, "no proto type has been mapped to the topic")
+		} else {
+			attempt(string(valueTypeProtobuf), DecoderFailed, err.Error())
+		}
+	}
+
+	trimmed := bytes.TrimLeft(value, " \t\r\n")
+	if len(trimmed) == 0 {
+		return succeeded(string(valueTypeText), valueTypeText)
+	}
+
+	// 2. JSON, if the payload starts with an object or array
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		err := fastjson.Validate(string(trimmed))
+		if err == nil {
+			return succeeded(string(valueTypeJSON), valueTypeJSON)
+		}
+		attempt(string(valueTypeJSON), DecoderFailed, err.Error())
+	} else {
+		attempt(string(valueTypeJSON), DecoderSkipped, fmt.Sprintf("first byte 0x%02x is neither '{' nor '['", trimmed[0]))
+	}
+
+	// 3. XML, if the payload starts with a tag
+	if trimmed[0] == '<' {
+		_, err := xj.Convert(strings.NewReader(string(trimmed)))
+		if err == nil {
+			return succeeded(string(valueTypeXML), valueTypeXML)
+		}
+		attempt(string(valueTypeXML), DecoderFailed, err.Error())
+	} else {
+		attempt(string(valueTypeXML), DecoderSkipped, fmt.Sprintf("first byte 0x%02x is not '<'", trimmed[0]))
+	}
+
+	// 4. UTF-8 text, the payload is returned base64 encoded as binary otherwise
+	if offset := invalidUTF8Offset(value); offset >= 0 {
+		attempt(string(valueTypeText), DecoderFailed, fmt.Sprintf("invalid UTF-8 sequence at byte %v (0x%02x)", offset, value[offset]))
+		d.DetectedType = string(valueTypeBinary)
+		d.Hints = binaryFormatHints(value)
+		return d
+	}
+	return succeeded(string(valueTypeText), valueTypeText)
+}
+
+// invalidUTF8Offset returns the offset of the first byte which is not part of a valid UTF-8 sequence, or -1
+func invalidUTF8Offset(value []byte) int {
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRune(value[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// binaryFormatHints returns the well-known binary formats the payload looks like, judging by its leading bytes
+func binaryFormatHints(value []byte) []string {
+	var hints []string
+	if len(value) >= 5 && value[0] == 0x00 {
+		schemaID := binary.BigEndian.Uint32(value[1:5])
+		hints = append(hints, fmt.Sprintf("starts like the schema registry wire format (magic byte 0, schema id %v), "+
+			"e.g. Avro or Protobuf serialized by a schema registry aware serializer", schemaID))
+	}
+	if len(value) >= 2 && value[0] == 0x1f && value[1] == 0x8b {
+		hints = append(hints, "starts with the gzip magic bytes, the payload has been compressed by the producing application")
+	}
+	return hints
+}
+
+// needsDeserializationHints returns true if the payload has been detected as binary, or as text although it looks
+// like JSON or XML
+func needsDeserializationHints(value []byte, detected valueType) bool {
+	return detected == valueTypeBinary || failedValueType(value, detected) != ""
+}
@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosePayload(t *testing.T) {
+	results := func(d PayloadDiagnosis) []string {
+		res := make([]string, len(d.Attempts))
+		for i, a := range d.Attempts {
+			res[i] = a.Decoder + ":" + a.Result
+		}
+		return res
+	}
+
+	d := DiagnosePayload(nil, "orders", proto.RecordValue, nil)
+	assert.True(t, d.IsNull)
+	assert.Empty(t, d.Attempts)
+
+	d = DiagnosePayload([]byte(`{"id": 1}`), "orders", proto.RecordValue, nil)
+	assert.Equal(t, "json", d.DetectedType)
+	assert.Equal(t, []string{"protobuf:skipped", "json:succeeded"}, results(d))
+
+	// Broken JSON falls back to text, the JSON decoder's error is reported
+	d = DiagnosePayload([]byte(`{"id": 1`), "orders", proto.RecordValue, nil)
+	assert.Equal(t, "text", d.DetectedType)
+	assert.Equal(t, []string{"protobuf:skipped", "json:failed", "xml:skipped", "text:succeeded"}, results(d))
+	assert.NotEmpty(t, d.Attempts[1].Reason)
+
+	// Schema registry framed payloads are binary
+	payload := []byte{0x00, 0x00, 0x00, 0x00, 0x2a, 0x02, 0xc3, 0x28}
+	d = DiagnosePayload(payload, "orders", proto.RecordValue, nil)
+	assert.Equal(t, "binary", d.DetectedType)
+	assert.Equal(t, []string{"protobuf:skipped", "json:skipped", "xml:skipped", "text:failed"}, results(d))
+	assert.Equal(t, "invalid UTF-8 sequence at byte 6 (0xc3)", d.Attempts[3].Reason)
+	require.Len(t, d.Hints, 1)
+	assert.Contains(t, d.Hints[0], "schema id 42")
+	assert.Contains(t, d.Preview, "00 00 00 00 2a 02 c3 28")
+}
+
+func TestGetDeserializationHints(t *testing.T) {
+	p := &PartitionConsumer{TopicName: "orders"}
+	key, value := []byte("order-1"), []byte(`{"id": 1`)
+	kType, _ := detectValueType(key)
+	vType, _ := detectValueType(value)
+
+	// Only the value looks like JSON but has been rendered as text
+	hints := p.getDeserializationHints(payloadDecoding{payload: key, detected: kType}, payloadDecoding{payload: value, detected: vType})
+	require.NotNil(t, hints)
+	assert.Nil(t, hints.Key)
+	require.NotNil(t, hints.Value)
+	assert.Equal(t, "text", hints.Value.DetectedType)
+	assert.Empty(t, hints.Value.Preview)
+
+	// Decoded payloads don't need hints
+	vType, _ = detectValueType([]byte(`{"id": 1}`))
+	assert.Nil(t, p.getDeserializationHints(payloadDecoding{payload: key, detected: kType}, payloadDecoding{payload: []byte(`{"id": 1}`), detected: vType}))
+
+	// Failure reasons may quote masked fields
+	p.Masker = &masking.Masker{}
+	assert.Nil(t, p.getDeserializationHints(payloadDecoding{payload: key, detected: kType}, payloadDecoding{payload: value, detected: valueTypeText}))
+}
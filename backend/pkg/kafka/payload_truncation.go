@@ -0,0 +1,25 @@
+package kafka
+
+import (
+	"unicode/utf8"
+)
+
+// truncatePayload cuts the rendered payload after maxBytes, at the preceding UTF-8 character boundary. Truncated
+// JSON based payloads are no longer valid JSON, hence they are returned as text. The second return value is false
+// if the payload has not been truncated, maxBytes <= 0 disables the truncation.
+func truncatePayload(d DirectEmbedding, maxBytes int) (DirectEmbedding, bool) {
+	if maxBytes <= 0 || len(d.Value) <= maxBytes {
+		return d, false
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(d.Value[cut]) {
+		cut--
+	}
+	vType := d.ValueType
+	if vType.isJSONBased() {
+		vType = valueTypeText
+	}
+
+	return DirectEmbedding{ValueType: vType, Value: d.Value[:cut]}, true
+}
@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncatePayload(t *testing.T) {
+	d := DirectEmbedding{ValueType: valueTypeText, Value: []byte("hello world")}
+	truncated, isTruncated := truncatePayload(d, 0)
+	assert.False(t, isTruncated)
+	assert.Equal(t, d, truncated)
+
+	truncated, isTruncated = truncatePayload(d, 11)
+	assert.False(t, isTruncated)
+	assert.Equal(t, d, truncated)
+
+	truncated, isTruncated = truncatePayload(d, 5)
+	assert.True(t, isTruncated)
+	assert.Equal(t, "hello", string(truncated.Value))
+
+	// Multi byte characters are never split
+	truncated, _ = truncatePayload(DirectEmbedding{ValueType: valueTypeText, Value: []byte("aä")}, 2)
+	assert.Equal(t, "a", string(truncated.Value))
+
+	// Truncated JSON is returned as text, because it's no longer valid JSON
+	truncated, isTruncated = truncatePayload(DirectEmbedding{ValueType: valueTypeJSON, Value: []byte(`{"id": 1}`)}, 4)
+	assert.True(t, isTruncated)
+	assert.Equal(t, valueTypeText, truncated.ValueType)
+	assert.Equal(t, `{"id`, string(truncated.Value))
+}
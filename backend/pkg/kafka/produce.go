@@ -0,0 +1,199 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// Partitioners which can be chosen per produced record
+const (
+	PartitionerManual     = "manual" // Uses the record's partition id
+	PartitionerHash       = "hash"   // Hashes the key, falls back to random for records without key
+	PartitionerRandom     = "random"
+	PartitionerRoundRobin = "roundRobin"
+)
+
+// ProduceRecord is a single record which shall be produced. Key and Value have already been serialized.
+type ProduceRecord struct {
+	TopicName   string
+	PartitionID int32 // Only considered if Partitioner is PartitionerManual
+	Partitioner string
+	Key         []byte
+	Value       []byte
+	Headers     []sarama.RecordHeader
+}
+
+// ProduceOptions allow to match the durability characteristics of real producers. Zero values use the settings of
+// the shared producer (acks=1, no compression, not idempotent, no linger).
+type ProduceOptions struct {
+	Acks        string        `json:"acks"`        // all, 1 or 0
+	Idempotent  bool          `json:"idempotent"`  // Requires acks=all
+	Compression string        `json:"compression"` // none, gzip, snappy, lz4 or zstd
+	Linger      time.Duration `json:"-"`
+}
+
+// IsDefault returns true if the options do not deviate from the shared producer's settings
+func (o ProduceOptions) IsDefault() bool {
+	return o == ProduceOptions{}
+}
+
+// Validate the produce options
+func (o ProduceOptions) Validate() error {
+	if _, err := parseAcks(o.Acks); err != nil {
+		return err
+	}
+	if _, err := parseCompressionCodec(o.Compression); err != nil {
+		return err
+	}
+	if o.Idempotent && o.Acks != "all" {
+		return fmt.Errorf("idempotent producing requires acks 'all'")
+	}
+	if o.Linger < 0 || o.Linger > 5*time.Second {
+		return fmt.Errorf("linger must be between 0 and 5s")
+	}
+
+	return nil
+}
+
+func parseAcks(acks string) (sarama.RequiredAcks, error) {
+	switch acks {
+	case "":
+		return sarama.WaitForLocal, nil
+	case "all", "-1":
+		return sarama.WaitForAll, nil
+	case "1":
+		return sarama.WaitForLocal, nil
+	case "0":
+		return sarama.NoResponse, nil
+	}
+	return 0, fmt.Errorf("unknown acks '%v', must be one of 'all', '1' or '0'", acks)
+}
+
+func parseCompressionCodec(codec string) (sarama.CompressionCodec, error) {
+	switch codec {
+	case "", "none":
+		return sarama.CompressionNone, nil
+	case "gzip":
+		return sarama.CompressionGZIP, nil
+	case "snappy":
+		return sarama.CompressionSnappy, nil
+	case "lz4":
+		return sarama.CompressionLZ4, nil
+	case "zstd":
+		return sarama.CompressionZSTD, nil
+	}
+	return 0, fmt.Errorf("unknown compression codec '%v'", codec)
+}
+
+// ProduceResult describes where a record has been written to
+type ProduceResult struct {
+	PartitionID int32 `json:"partitionId"`
+	Offset      int64 `json:"offset"`
+}
+
+// Produce sends a single record to Kafka and waits until it has been acknowledged. If the options deviate from the
+// defaults, a dedicated producer is created for this record.
+func (s *Service) Produce(record ProduceRecord, opts ProduceOptions) (*ProduceResult, error) {
+	producer := s.Producer
+	if !opts.IsDefault() {
+		var err error
+		producer, err = s.newProducer(opts)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := producer.Close(); err != nil {
+				s.Logger.Warn("failed to close producer", zap.Error(err))
+			}
+		}()
+	}
+	if producer == nil {
+		return nil, fmt.Errorf("producer has not been initialized")
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic:     record.TopicName,
+		Partition: record.PartitionID,
+		Headers:   record.Headers,
+		Metadata:  record.Partitioner,
+	}
+	// Nil keys and values must be passed as nil encoders, otherwise they would be produced as empty byte arrays
+	if record.Key != nil {
+		msg.Key = sarama.ByteEncoder(record.Key)
+	}
+	if record.Value != nil {
+		msg.Value = sarama.ByteEncoder(record.Value)
+	}
+
+	partitionID, offset, err := producer.SendMessage(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to produce record to topic '%v': %w", record.TopicName, err)
+	}
+
+	return &ProduceResult{PartitionID: partitionID, Offset: offset}, nil
+}
+
+// newProducer creates a sync producer with its own connections using the shared client config and the given options
+func (s *Service) newProducer(opts ProduceOptions) (sarama.SyncProducer, error) {
+	cfg := *s.Client.Config()
+	cfg.Producer.RequiredAcks, _ = parseAcks(opts.Acks)
+	cfg.Producer.Compression, _ = parseCompressionCodec(opts.Compression)
+	cfg.Producer.Flush.Frequency = opts.Linger
+	if opts.Idempotent {
+		cfg.Producer.Idempotent = true
+		cfg.Net.MaxOpenRequests = 1
+	}
+
+	brokers := s.Client.Brokers()
+	addrs := make([]string, len(brokers))
+	for i, b := range brokers {
+		addrs[i] = b.Addr()
+	}
+
+	producer, err := sarama.NewSyncProducer(addrs, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer: %w", err)
+	}
+
+	return producer, nil
+}
+
+// recordPartitioner dispatches to the partitioner which has been chosen for each record (passed as message metadata)
+type recordPartitioner struct {
+	hash       sarama.Partitioner
+	random     sarama.Partitioner
+	roundRobin sarama.Partitioner
+}
+
+func newRecordPartitioner(topic string) sarama.Partitioner {
+	return &recordPartitioner{
+		hash:       sarama.NewHashPartitioner(topic),
+		random:     sarama.NewRandomPartitioner(topic),
+		roundRobin: sarama.NewRoundRobinPartitioner(topic),
+	}
+}
+
+func (p *recordPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	partitioner, _ := msg.Metadata.(string)
+	switch partitioner {
+	case PartitionerManual:
+		if msg.Partition < 0 || msg.Partition >= numPartitions {
+			return -1, sarama.ErrInvalidPartition
+		}
+		return msg.Partition, nil
+	case PartitionerRandom:
+		return p.random.Partition(msg, numPartitions)
+	case PartitionerRoundRobin:
+		return p.roundRobin.Partition(msg, numPartitions)
+	default:
+		return p.hash.Partition(msg, numPartitions)
+	}
+}
+
+// RequiresConsistency is true so that records are not moved to other partitions if their partition is unavailable
+func (p *recordPartitioner) RequiresConsistency() bool {
+	return true
+}
@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordPartitioner(t *testing.T) {
+	hashed, err := sarama.NewHashPartitioner("").Partition(&sarama.ProducerMessage{Key: sarama.StringEncoder("foobar")}, 6)
+	assert.NoError(t, err)
+
+	tt := []struct {
+		msg      *sarama.ProducerMessage
+		expected int32
+		isValid  bool
+	}{
+		{&sarama.ProducerMessage{Metadata: PartitionerManual, Partition: 3}, 3, true},
+		{&sarama.ProducerMessage{Metadata: PartitionerManual, Partition: 6}, -1, false},
+		{&sarama.ProducerMessage{Metadata: PartitionerManual, Partition: -1}, -1, false},
+		{&sarama.ProducerMessage{Metadata: PartitionerHash, Key: sarama.StringEncoder("foobar")}, hashed, true},
+		// Records without a chosen partitioner are hashed as well
+		{&sarama.ProducerMessage{Key: sarama.StringEncoder("foobar")}, hashed, true},
+	}
+
+	for i, test := range tt {
+		partitionID, err := newRecordPartitioner("").Partition(test.msg, 6)
+		if !test.isValid {
+			assert.Equal(t, sarama.ErrInvalidPartition, err, "Case: ", i)
+			continue
+		}
+		assert.NoError(t, err, "Case: ", i)
+		assert.Equal(t, test.expected, partitionID, "Case: ", i)
+	}
+
+	p := newRecordPartitioner("")
+	for i := int32(0); i < 12; i++ {
+		partitionID, err := p.Partition(&sarama.ProducerMessage{Metadata: PartitionerRoundRobin}, 6)
+		assert.NoError(t, err)
+		assert.Equal(t, i%6, partitionID)
+
+		partitionID, err = p.Partition(&sarama.ProducerMessage{Metadata: PartitionerRandom}, 6)
+		assert.NoError(t, err)
+		assert.True(t, partitionID >= 0 && partitionID < 6)
+	}
+	assert.True(t, p.RequiresConsistency())
+}
+
+func TestProduceOptionsValidate(t *testing.T) {
+	tt := []struct {
+		opts    ProduceOptions
+		isValid bool
+	}{
+		{ProduceOptions{}, true},
+		{ProduceOptions{Acks: "all", Idempotent: true, Compression: "zstd", Linger: 5 * time.Second}, true},
+		{ProduceOptions{Acks: "-1", Compression: "none"}, true},
+		{ProduceOptions{Acks: "0", Compression: "snappy"}, true},
+		{ProduceOptions{Acks: "2"}, false},
+		{ProduceOptions{Compression: "brotli"}, false},
+		{ProduceOptions{Acks: "1", Idempotent: true}, false},
+		{ProduceOptions{Acks: "-1", Idempotent: true}, false}, // Idempotence requires the explicit 'all'
+		{ProduceOptions{Linger: -time.Millisecond}, false},
+		{ProduceOptions{Linger: 6 * time.Second}, false},
+	}
+
+	for i, test := range tt {
+		err := test.opts.Validate()
+		if test.isValid {
+			assert.NoError(t, err, "Case: ", i)
+		} else {
+			assert.Error(t, err, "Case: ", i)
+		}
+	}
+}
+
+func TestParseProduceOptions(t *testing.T) {
+	acks := map[string]sarama.RequiredAcks{"": sarama.WaitForLocal, "all": sarama.WaitForAll, "-1": sarama.WaitForAll, "1": sarama.WaitForLocal, "0": sarama.NoResponse}
+	for input, expected := range acks {
+		parsed, err := parseAcks(input)
+		assert.NoError(t, err, "acks '%v'", input)
+		assert.Equal(t, expected, parsed, "acks '%v'", input)
+	}
+
+	codecs := map[string]sarama.CompressionCodec{
+		"":       sarama.CompressionNone,
+		"none":   sarama.CompressionNone,
+		"gzip":   sarama.CompressionGZIP,
+		"snappy": sarama.CompressionSnappy,
+		"lz4":    sarama.CompressionLZ4,
+		"zstd":   sarama.CompressionZSTD,
+	}
+	for input, expected := range codecs {
+		parsed, err := parseCompressionCodec(input)
+		assert.NoError(t, err, "codec '%v'", input)
+		assert.Equal(t, expected, parsed, "codec '%v'", input)
+	}
+	_, err := parseCompressionCodec("GZIP")
+	assert.Error(t, err)
+}
@@ -0,0 +1,310 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+const (
+	// transactionTimeout is the time after which the coordinator aborts a transaction that has not been completed
+	transactionTimeout = time.Minute
+
+	// addPartitionsMaxAttempts is the number of times partitions are added to a transaction while the coordinator
+	// is still completing a previous transaction of the same transactional id
+	addPartitionsMaxAttempts  = 10
+	addPartitionsRetryBackoff = 20 * time.Millisecond
+)
+
+// ProduceTransaction produces all records within a single Kafka transaction, so that consumers using the
+// read_committed isolation level either see all of them or none. This is used to replay chunks of messages without
+// leaving half-delivered duplicates behind if a replay fails. Records may target multiple topics and partitions.
+//
+// The transactional id must be unique per replay job, starting a transaction with the same id fences all previous
+// producers using it. If producing any of the records fails, the transaction is aborted and an error is returned.
+func (s *Service) ProduceTransaction(transactionalID string, records []ProduceRecord) ([]ProduceResult, error) {
+	if !s.Client.Config().Version.IsAtLeast(sarama.V0_11_0_0) {
+		return nil, fmt.Errorf("transactions require at least Kafka version 0.11, but configured version is %v", s.Client.Config().Version)
+	}
+	if len(records) == 0 {
+		return []ProduceResult{}, nil
+	}
+
+	// 1. Resolve the partitions of all records, so that we know which partitions must be added to the transaction
+	partitionIDs, err := s.resolveRecordPartitions(records)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Init producer id and epoch at the transaction coordinator
+	coordinator, err := s.transactionCoordinator(transactionalID)
+	if err != nil {
+		return nil, err
+	}
+	initRes, err := coordinator.InitProducerID(&sarama.InitProducerIDRequest{
+		TransactionalID:    &transactionalID,
+		TransactionTimeout: transactionTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to init producer id: %w", err)
+	}
+	if initRes.Err != sarama.ErrNoError {
+		return nil, fmt.Errorf("failed to init producer id: %w", initRes.Err)
+	}
+	txn := &transaction{
+		ID:            transactionalID,
+		ProducerID:    initRes.ProducerID,
+		ProducerEpoch: initRes.ProducerEpoch,
+		Coordinator:   coordinator,
+	}
+
+	// 3. Produce records and commit or abort the transaction
+	results, err := s.produceInTransaction(txn, records, partitionIDs)
+	if err != nil {
+		if abortErr := txn.End(false); abortErr != nil {
+			s.Logger.Warn("failed to abort transaction", zap.String("transactional_id", transactionalID), zap.Error(abortErr))
+		}
+		return nil, err
+	}
+	if err := txn.End(true); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return results, nil
+}
+
+// transaction holds the producer identity which has been assigned by the transaction coordinator
+type transaction struct {
+	ID            string
+	ProducerID    int64
+	ProducerEpoch int16
+	Coordinator   *sarama.Broker
+}
+
+// End commits (commit = true) or aborts the transaction
+func (t *transaction) End(commit bool) error {
+	res, err := t.Coordinator.EndTxn(&sarama.EndTxnRequest{
+		TransactionalID:   t.ID,
+		ProducerID:        t.ProducerID,
+		ProducerEpoch:     t.ProducerEpoch,
+		TransactionResult: commit,
+	})
+	if err != nil {
+		return err
+	}
+	if res.Err != sarama.ErrNoError {
+		return res.Err
+	}
+
+	return nil
+}
+
+// AddPartitions adds the partitions to the transaction. Partitions are retried with a backoff as long as the
+// coordinator reports concurrent transactions, which happens while the previous transaction of the same transactional
+// id is still being committed or aborted.
+func (t *transaction) AddPartitions(topicPartitions map[string][]int32) error {
+	for attempt := 1; ; attempt++ {
+		res, err := t.Coordinator.AddPartitionsToTxn(&sarama.AddPartitionsToTxnRequest{
+			TransactionalID: t.ID,
+			ProducerID:      t.ProducerID,
+			ProducerEpoch:   t.ProducerEpoch,
+			TopicPartitions: topicPartitions,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add partitions to transaction: %w", err)
+		}
+
+		retryPartitions := make(map[string][]int32)
+		for topic, partitionErrs := range res.Errors {
+			for _, pErr := range partitionErrs {
+				switch pErr.Err {
+				case sarama.ErrNoError:
+				case sarama.ErrConcurrentTransactions:
+					retryPartitions[topic] = append(retryPartitions[topic], pErr.Partition)
+				default:
+					return fmt.Errorf("failed to add partition '%v' of topic '%v' to transaction: %w", pErr.Partition, topic, pErr.Err)
+				}
+			}
+		}
+		if len(retryPartitions) == 0 {
+			return nil
+		}
+		if attempt == addPartitionsMaxAttempts {
+			return fmt.Errorf("failed to add partitions to transaction after %v attempts: %w", attempt, sarama.ErrConcurrentTransactions)
+		}
+
+		topicPartitions = retryPartitions
+		time.Sleep(addPartitionsRetryBackoff)
+	}
+}
+
+func (s *Service) produceInTransaction(txn *transaction, records []ProduceRecord, partitionIDs []int32) ([]ProduceResult, error) {
+	// Add all partitions to the transaction before producing to them
+	topicPartitions := make(map[string][]int32)
+	batches := make(map[string]map[int32]*sarama.RecordBatch)
+	now := time.Now()
+	for i, record := range records {
+		partitionID := partitionIDs[i]
+		if _, ok := batches[record.TopicName]; !ok {
+			batches[record.TopicName] = make(map[int32]*sarama.RecordBatch)
+		}
+		batch, ok := batches[record.TopicName][partitionID]
+		if !ok {
+			topicPartitions[record.TopicName] = append(topicPartitions[record.TopicName], partitionID)
+			batch = &sarama.RecordBatch{
+				Version:         2,
+				FirstTimestamp:  now,
+				MaxTimestamp:    now,
+				ProducerID:      txn.ProducerID,
+				ProducerEpoch:   txn.ProducerEpoch,
+				FirstSequence:   0, // Sequences start at 0 for each newly initialized producer epoch
+				IsTransactional: true,
+			}
+			batches[record.TopicName][partitionID] = batch
+		}
+
+		headers := make([]*sarama.RecordHeader, len(record.Headers))
+		for j := range record.Headers {
+			headers[j] = &record.Headers[j]
+		}
+		batch.Records = append(batch.Records, &sarama.Record{
+			Headers:     headers,
+			OffsetDelta: int64(len(batch.Records)),
+			Key:         record.Key,
+			Value:       record.Value,
+		})
+		batch.LastOffsetDelta = int32(len(batch.Records) - 1)
+	}
+
+	if err := txn.AddPartitions(topicPartitions); err != nil {
+		return nil, err
+	}
+
+	// Bucket the batches by partition leader so that we send one produce request per broker
+	brokers := make(map[int32]*sarama.Broker)
+	reqs := make(map[int32]*sarama.ProduceRequest)
+	for topic, partitions := range batches {
+		for partitionID, batch := range partitions {
+			leader, err := s.Client.Leader(topic, partitionID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get leader of partition '%v' of topic '%v': %w", partitionID, topic, err)
+			}
+			id := leader.ID()
+			brokers[id] = leader
+			if _, ok := reqs[id]; !ok {
+				reqs[id] = &sarama.ProduceRequest{
+					TransactionalID: &txn.ID,
+					RequiredAcks:    sarama.WaitForAll,
+					Timeout:         int32(s.Client.Config().Producer.Timeout / time.Millisecond),
+					Version:         3, // Version 3 is the first one which supports transactions
+				}
+			}
+			reqs[id].AddBatch(topic, partitionID, batch)
+		}
+	}
+
+	baseOffsets := make(map[string]map[int32]int64)
+	for brokerID, req := range reqs {
+		res, err := brokers[brokerID].Produce(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to produce records: %w", err)
+		}
+		for topic, partitions := range res.Blocks {
+			if _, ok := baseOffsets[topic]; !ok {
+				baseOffsets[topic] = make(map[int32]int64)
+			}
+			for partitionID, block := range partitions {
+				if block.Err != sarama.ErrNoError {
+					return nil, fmt.Errorf("failed to produce records to partition '%v' of topic '%v': %w", partitionID, topic, block.Err)
+				}
+				baseOffsets[topic][partitionID] = block.Offset
+			}
+		}
+	}
+
+	// Each record's offset is the base offset of its batch plus its position within the batch
+	results := make([]ProduceResult, len(records))
+	nextOffsetDelta := make(map[string]map[int32]int64)
+	for i, record := range records {
+		partitionID := partitionIDs[i]
+		if _, ok := nextOffsetDelta[record.TopicName]; !ok {
+			nextOffsetDelta[record.TopicName] = make(map[int32]int64)
+		}
+		results[i] = ProduceResult{
+			PartitionID: partitionID,
+			Offset:      baseOffsets[record.TopicName][partitionID] + nextOffsetDelta[record.TopicName][partitionID],
+		}
+		nextOffsetDelta[record.TopicName][partitionID]++
+	}
+
+	return results, nil
+}
+
+// resolveRecordPartitions returns the partition id of each record using the record's partitioner
+func (s *Service) resolveRecordPartitions(records []ProduceRecord) ([]int32, error) {
+	partitioners := make(map[string]sarama.Partitioner)
+	partitionCounts := make(map[string]int32)
+	partitionIDs := make([]int32, len(records))
+	for i, record := range records {
+		partitioner, ok := partitioners[record.TopicName]
+		if !ok {
+			partitions, err := s.Client.Partitions(record.TopicName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get partitions of topic '%v': %w", record.TopicName, err)
+			}
+			partitioner = newRecordPartitioner(record.TopicName)
+			partitioners[record.TopicName] = partitioner
+			partitionCounts[record.TopicName] = int32(len(partitions))
+		}
+
+		msg := &sarama.ProducerMessage{
+			Topic:     record.TopicName,
+			Partition: record.PartitionID,
+			Metadata:  record.Partitioner,
+		}
+		if record.Key != nil {
+			msg.Key = sarama.ByteEncoder(record.Key)
+		}
+		partitionID, err := partitioner.Partition(msg, partitionCounts[record.TopicName])
+		if err != nil {
+			return nil, fmt.Errorf("failed to choose partition for record at index '%v': %w", i, err)
+		}
+		partitionIDs[i] = partitionID
+	}
+
+	return partitionIDs, nil
+}
+
+// transactionCoordinator returns the broker which coordinates the transactions of the given transactional id
+func (s *Service) transactionCoordinator(transactionalID string) (*sarama.Broker, error) {
+	broker, err := s.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+
+	// Version 1 is required to look up transaction coordinators
+	res, err := broker.FindCoordinator(&sarama.FindCoordinatorRequest{
+		Version:         1,
+		CoordinatorKey:  transactionalID,
+		CoordinatorType: sarama.CoordinatorTransaction,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find transaction coordinator: %w", err)
+	}
+	if res.Err != sarama.ErrNoError {
+		return nil, fmt.Errorf("failed to find transaction coordinator: %w", res.Err)
+	}
+
+	coordinator, err := s.findBrokerByID(res.Coordinator.ID())
+	if err != nil {
+		return nil, err
+	}
+	err = coordinator.Open(s.Client.Config())
+	if err != nil && err != sarama.ErrAlreadyConnected {
+		return nil, fmt.Errorf("failed to open connection to transaction coordinator: %w", err)
+	}
+
+	return coordinator, nil
+}
@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTransactionTestService returns a service whose only broker leads both partitions of the topic "orders" and
+// coordinates all transactions. The handlers for the transaction requests must be set by the caller.
+func newTransactionTestService(t *testing.T) (*Service, *sarama.MockBroker) {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": ordersMetadataResponse(t, broker.Addr(), broker.BrokerID())})
+
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V0_11_0_0
+	client, err := sarama.NewClient([]string{broker.Addr()}, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	for _, b := range client.Brokers() {
+		require.NoError(t, b.Open(cfg))
+	}
+
+	return &Service{Client: client, Logger: zap.NewNop()}, broker
+}
+
+func ordersMetadataResponse(t *testing.T, addr string, brokerID int32) *sarama.MockMetadataResponse {
+	return sarama.NewMockMetadataResponse(t).
+		SetBroker(addr, brokerID).
+		SetLeader("orders", 0, brokerID).
+		SetLeader("orders", 1, brokerID)
+}
+
+// transactionHandlers returns the handlers for a transaction whose partitions are added with the given responses
+func transactionHandlers(t *testing.T, svc *Service, produceRes *sarama.MockProduceResponse, addPartitionsRes ...interface{}) map[string]sarama.MockResponse {
+	broker := svc.Client.Brokers()[0]
+	return map[string]sarama.MockResponse{
+		"MetadataRequest":           ordersMetadataResponse(t, broker.Addr(), broker.ID()),
+		"FindCoordinatorRequest":    sarama.NewMockWrapper(&sarama.FindCoordinatorResponse{Version: 1, Coordinator: broker}),
+		"InitProducerIDRequest":     sarama.NewMockWrapper(&sarama.InitProducerIDResponse{ProducerID: 1000, ProducerEpoch: 1}),
+		"AddPartitionsToTxnRequest": sarama.NewMockSequence(addPartitionsRes...),
+		"ProduceRequest":            produceRes.SetVersion(3),
+		"EndTxnRequest":             sarama.NewMockWrapper(&sarama.EndTxnResponse{}),
+	}
+}
+
+func addPartitionsResponse(err sarama.KError) *sarama.AddPartitionsToTxnResponse {
+	return &sarama.AddPartitionsToTxnResponse{Errors: map[string][]*sarama.PartitionError{
+		"orders": {{Partition: 0, Err: err}, {Partition: 1, Err: err}},
+	}}
+}
+
+// transactionRequests returns the AddPartitionsToTxn and EndTxn requests which have been received by the broker
+func transactionRequests(broker *sarama.MockBroker) (addPartitions []*sarama.AddPartitionsToTxnRequest, endTxn []*sarama.EndTxnRequest) {
+	for _, rr := range broker.History() {
+		switch req := rr.Request.(type) {
+		case *sarama.AddPartitionsToTxnRequest:
+			addPartitions = append(addPartitions, req)
+		case *sarama.EndTxnRequest:
+			endTxn = append(endTxn, req)
+		}
+	}
+	return addPartitions, endTxn
+}
+
+var transactionTestRecords = []ProduceRecord{
+	{TopicName: "orders", Partitioner: PartitionerManual, PartitionID: 0, Value: []byte("a")},
+	{TopicName: "orders", Partitioner: PartitionerManual, PartitionID: 1, Value: []byte("b")},
+	{TopicName: "orders", Partitioner: PartitionerManual, PartitionID: 0, Value: []byte("c")},
+}
+
+func TestProduceTransactionCommit(t *testing.T) {
+	svc, broker := newTransactionTestService(t)
+	// The coordinator is still completing a previous transaction of the same id when the partitions are added first
+	broker.SetHandlerByMap(transactionHandlers(t, svc, sarama.NewMockProduceResponse(t),
+		addPartitionsResponse(sarama.ErrConcurrentTransactions), addPartitionsResponse(sarama.ErrNoError)))
+
+	results, err := svc.ProduceTransaction("kowl-replay", transactionTestRecords)
+	require.NoError(t, err)
+	assert.Equal(t, []ProduceResult{{PartitionID: 0, Offset: 0}, {PartitionID: 1, Offset: 0}, {PartitionID: 0, Offset: 1}}, results)
+
+	addPartitions, endTxn := transactionRequests(broker)
+	require.Len(t, addPartitions, 2)
+	for _, req := range addPartitions {
+		assert.ElementsMatch(t, []int32{0, 1}, req.TopicPartitions["orders"])
+		assert.Equal(t, int64(1000), req.ProducerID)
+	}
+	require.Len(t, endTxn, 1)
+	assert.True(t, endTxn[0].TransactionResult)
+}
+
+func TestProduceTransactionAbort(t *testing.T) {
+	tt := []struct {
+		produceRes       *sarama.MockProduceResponse
+		addPartitionsRes []interface{}
+		expectedErr      error
+	}{
+		{
+			sarama.NewMockProduceResponse(t).SetError("orders", 1, sarama.ErrNotEnoughReplicas),
+			[]interface{}{addPartitionsResponse(sarama.ErrNoError)},
+			sarama.ErrNotEnoughReplicas,
+		},
+		{
+			sarama.NewMockProduceResponse(t),
+			[]interface{}{addPartitionsResponse(sarama.ErrInvalidProducerEpoch)},
+			sarama.ErrInvalidProducerEpoch,
+		},
+	}
+
+	for i, test := range tt {
+		svc, broker := newTransactionTestService(t)
+		broker.SetHandlerByMap(transactionHandlers(t, svc, test.produceRes, test.addPartitionsRes...))
+
+		_, err := svc.ProduceTransaction("kowl-replay", transactionTestRecords)
+		assert.True(t, errors.Is(err, test.expectedErr), "Case: ", i)
+
+		_, endTxn := transactionRequests(broker)
+		require.Len(t, endTxn, 1, "Case: ", i)
+		assert.False(t, endTxn[0].TransactionResult, "Case: ", i)
+	}
+}
@@ -0,0 +1,389 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Api keys of requests which are sent without sarama
+const (
+	apiKeyElectLeaders                 = 43
+	apiKeySaslAuthenticate             = 36
+	apiKeyDescribeUserScramCredentials = 50
+	apiKeyAlterUserScramCredentials    = 51
+)
+
+// errRawRequestUnsupported is returned if the connection setup doesn't allow to send requests without sarama
+var errRawRequestUnsupported = errors.New("request is not supported with the configured authentication")
+
+// rawRequestTimeout bounds the time for connecting, authenticating and waiting for the response of a raw request
+const rawRequestTimeout = 60 * time.Second
+
+// sendRawRequest sends a request which sarama doesn't implement to the broker at addr over a dedicated connection.
+// The body must be encoded in the given version, the returned response body starts right after the response header.
+// Flexible versions must be flagged, because they use different request and response headers.
+func (s *Service) sendRawRequest(addr string, apiKey int16, apiVersion int16, flexible bool, body []byte) ([]byte, error) {
+	conn, err := s.dialRawConnection(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker '%v': %w", addr, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(rawRequestTimeout))
+
+	rc := &rawConnection{conn: conn, clientID: s.Client.Config().ClientID}
+	if err := rc.authenticate(s.Client.Config()); err != nil {
+		return nil, fmt.Errorf("failed to authenticate with broker '%v': %w", addr, err)
+	}
+
+	return rc.roundTrip(apiKey, apiVersion, flexible, body)
+}
+
+// rawRequestVersion returns the highest version of the api which is supported by the broker and by us, which is
+// maxVersion at most. The returned bool is false if the broker doesn't support the api at all.
+func rawRequestVersion(broker *sarama.Broker, apiKey int16, maxVersion int16) (int16, bool, error) {
+	versions, err := broker.ApiVersions(&sarama.ApiVersionsRequest{})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get supported api versions: %w", err)
+	}
+	for _, block := range versions.ApiVersions {
+		if block.ApiKey != apiKey {
+			continue
+		}
+		if block.MaxVersion < maxVersion {
+			return block.MaxVersion, true, nil
+		}
+		return maxVersion, true, nil
+	}
+
+	return 0, false, nil
+}
+
+// dialRawConnection connects to the broker the same way sarama does
+func (s *Service) dialRawConnection(addr string) (net.Conn, error) {
+	cfg := s.Client.Config()
+	if cfg.Net.Proxy.Enable {
+		return cfg.Net.Proxy.Dialer.Dial("tcp", addr)
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Net.DialTimeout, KeepAlive: cfg.Net.KeepAlive, LocalAddr: cfg.Net.LocalAddr}
+	if cfg.Net.TLS.Enable {
+		return tls.DialWithDialer(dialer, "tcp", addr, cfg.Net.TLS.Config)
+	}
+	return dialer.Dial("tcp", addr)
+}
+
+// rawConnection frames requests and responses of a single broker connection
+type rawConnection struct {
+	conn          net.Conn
+	clientID      string
+	correlationID int32
+}
+
+// authenticate performs the SASL handshake if SASL is enabled. Only PLAIN and SCRAM with handshake are supported.
+func (c *rawConnection) authenticate(cfg *sarama.Config) error {
+	if !cfg.Net.SASL.Enable {
+		return nil
+	}
+	if !cfg.Net.SASL.Handshake {
+		return fmt.Errorf("%w: SASL without handshake", errRawRequestUnsupported)
+	}
+
+	mechanism := string(cfg.Net.SASL.Mechanism)
+	var scramClient sarama.SCRAMClient
+	switch cfg.Net.SASL.Mechanism {
+	case sarama.SASLTypePlaintext:
+	case sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+		scramClient = cfg.Net.SASL.SCRAMClientGeneratorFunc()
+	default:
+		return fmt.Errorf("%w: SASL mechanism '%v'", errRawRequestUnsupported, mechanism)
+	}
+
+	// SaslHandshake v1 announces that the auth bytes are sent in SaslAuthenticate requests
+	handshake := &rawEncoder{}
+	handshake.putString(mechanism)
+	res, err := c.roundTrip(apiKeySaslHandshake, 1, false, handshake.bytes())
+	if err != nil {
+		return err
+	}
+	dec := &rawDecoder{b: res}
+	if errCode := sarama.KError(dec.int16()); errCode != sarama.ErrNoError {
+		return fmt.Errorf("sasl handshake failed: %w", errCode)
+	}
+
+	if scramClient == nil {
+		_, err := c.saslAuthenticate([]byte("\x00" + cfg.Net.SASL.User + "\x00" + cfg.Net.SASL.Password))
+		return err
+	}
+
+	if err := scramClient.Begin(cfg.Net.SASL.User, cfg.Net.SASL.Password, cfg.Net.SASL.SCRAMAuthzID); err != nil {
+		return fmt.Errorf("failed to start scram conversation: %w", err)
+	}
+	msg, err := scramClient.Step("")
+	if err != nil {
+		return fmt.Errorf("failed to create scram message: %w", err)
+	}
+	for !scramClient.Done() {
+		challenge, err := c.saslAuthenticate([]byte(msg))
+		if err != nil {
+			return err
+		}
+		msg, err = scramClient.Step(string(challenge))
+		if err != nil {
+			return fmt.Errorf("failed to answer scram challenge: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// saslAuthenticate sends the auth bytes in a SaslAuthenticate v0 request and returns the server's auth bytes
+func (c *rawConnection) saslAuthenticate(authBytes []byte) ([]byte, error) {
+	req := &rawEncoder{}
+	req.putBytes(authBytes)
+	res, err := c.roundTrip(apiKeySaslAuthenticate, 0, false, req.bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	dec := &rawDecoder{b: res}
+	errCode := sarama.KError(dec.int16())
+	errMsg := dec.nullableString()
+	serverBytes := dec.bytes()
+	if dec.err != nil {
+		return nil, fmt.Errorf("failed to decode sasl authenticate response: %w", dec.err)
+	}
+	if errCode != sarama.ErrNoError {
+		return nil, fmt.Errorf("sasl authentication failed: %w: %v", errCode, errMsg)
+	}
+
+	return serverBytes, nil
+}
+
+// roundTrip sends the request with header v1 (v2 if flexible) and returns the response body which follows the
+// response header
+func (c *rawConnection) roundTrip(apiKey int16, apiVersion int16, flexible bool, body []byte) ([]byte, error) {
+	c.correlationID++
+	header := &rawEncoder{}
+	header.putInt16(apiKey)
+	header.putInt16(apiVersion)
+	header.putInt32(c.correlationID)
+	header.putString(c.clientID)
+	if flexible {
+		header.putEmptyTaggedFields()
+	}
+
+	req := make([]byte, 4, 4+len(header.b)+len(body))
+	binary.BigEndian.PutUint32(req, uint32(len(header.b)+len(body)))
+	req = append(req, header.b...)
+	req = append(req, body...)
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	sizeBytes := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, sizeBytes); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	size := binary.BigEndian.Uint32(sizeBytes)
+	if size < 4 || size > 100*1024*1024 {
+		return nil, fmt.Errorf("invalid response size %v", size)
+	}
+	res := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, res); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if cid := int32(binary.BigEndian.Uint32(res[0:4])); cid != c.correlationID {
+		return nil, fmt.Errorf("response has correlation id %v, expected %v", cid, c.correlationID)
+	}
+	if !flexible {
+		return res[4:], nil
+	}
+
+	// The response header v1 has tagged fields after the correlation id
+	dec := &rawDecoder{b: res[4:]}
+	dec.skipTaggedFields()
+	if dec.err != nil {
+		return nil, fmt.Errorf("failed to decode response header: %w", dec.err)
+	}
+	return dec.b, nil
+}
+
+// rawEncoder encodes the primitive types of the Kafka protocol. The compact types are used by flexible versions.
+type rawEncoder struct {
+	b []byte
+}
+
+func (e *rawEncoder) bytes() []byte { return e.b }
+
+func (e *rawEncoder) putInt8(v int8) { e.b = append(e.b, byte(v)) }
+
+func (e *rawEncoder) putInt16(v int16) {
+	e.b = append(e.b, 0, 0)
+	binary.BigEndian.PutUint16(e.b[len(e.b)-2:], uint16(v))
+}
+
+func (e *rawEncoder) putInt32(v int32) {
+	e.b = append(e.b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(e.b[len(e.b)-4:], uint32(v))
+}
+
+func (e *rawEncoder) putString(v string) {
+	e.putInt16(int16(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *rawEncoder) putBytes(v []byte) {
+	e.putInt32(int32(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *rawEncoder) putUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	e.b = append(e.b, buf[:n]...)
+}
+
+// putCompactArrayLength encodes the length of a compact array, -1 encodes a null array
+func (e *rawEncoder) putCompactArrayLength(n int) {
+	e.putUvarint(uint64(n + 1))
+}
+
+func (e *rawEncoder) putCompactString(v string) {
+	e.putUvarint(uint64(len(v) + 1))
+	e.b = append(e.b, v...)
+}
+
+func (e *rawEncoder) putCompactBytes(v []byte) {
+	e.putUvarint(uint64(len(v) + 1))
+	e.b = append(e.b, v...)
+}
+
+// putEmptyTaggedFields encodes the tagged fields of a flexible structure, we never send any
+func (e *rawEncoder) putEmptyTaggedFields() {
+	e.putUvarint(0)
+}
+
+// rawDecoder decodes the primitive types of the Kafka protocol. Once the input is exhausted all
+// further values are zero and err is set.
+type rawDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *rawDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *rawDecoder) int16() int16 {
+	if v := d.next(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (d *rawDecoder) int32() int32 {
+	if v := d.next(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (d *rawDecoder) string() string {
+	return string(d.next(int(d.int16())))
+}
+
+func (d *rawDecoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *rawDecoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// arrayLength returns the length of an array, it's 0 for null arrays
+func (d *rawDecoder) arrayLength() int {
+	n := int(d.int32())
+	if n < 0 {
+		return 0
+	}
+	if n > len(d.b) {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return n
+}
+
+func (d *rawDecoder) int8() int8 {
+	if v := d.next(1); v != nil {
+		return int8(v[0])
+	}
+	return 0
+}
+
+func (d *rawDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// compactString decodes a compact string, null strings are returned as empty string
+func (d *rawDecoder) compactString() string {
+	n := int(d.uvarint()) - 1
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(n))
+}
+
+// compactArrayLength returns the length of a compact array, it's 0 for null arrays
+func (d *rawDecoder) compactArrayLength() int {
+	n := int(d.uvarint()) - 1
+	if n < 0 {
+		return 0
+	}
+	if n > len(d.b) {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return n
+}
+
+// skipTaggedFields skips the tagged fields of a flexible structure, because we don't know any of them
+func (d *rawDecoder) skipTaggedFields() {
+	count := int(d.uvarint())
+	for i := 0; i < count && d.err == nil; i++ {
+		d.uvarint() // Tag
+		d.next(int(d.uvarint()))
+	}
+}
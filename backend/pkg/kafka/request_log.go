@@ -0,0 +1,339 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// requestLogCapacity is the max number of entries kept in memory, older entries are dropped first
+const requestLogCapacity = 10000
+
+// apiKeySaslHandshake is the api key of SaslHandshake requests, which need special treatment because the SASL auth
+// bytes are exchanged without Kafka's request framing in version 0.
+const apiKeySaslHandshake = 17
+
+// apiNames maps the Kafka api keys to the request names as used in the protocol documentation
+var apiNames = map[int16]string{
+	0: "Produce", 1: "Fetch", 2: "ListOffsets", 3: "Metadata", 4: "LeaderAndIsr", 5: "StopReplica",
+	6: "UpdateMetadata", 7: "ControlledShutdown", 8: "OffsetCommit", 9: "OffsetFetch", 10: "FindCoordinator",
+	11: "JoinGroup", 12: "Heartbeat", 13: "LeaveGroup", 14: "SyncGroup", 15: "DescribeGroups", 16: "ListGroups",
+	17: "SaslHandshake", 18: "ApiVersions", 19: "CreateTopics", 20: "DeleteTopics", 21: "DeleteRecords",
+	22: "InitProducerId", 23: "OffsetForLeaderEpoch", 24: "AddPartitionsToTxn", 25: "AddOffsetsToTxn",
+	26: "EndTxn", 27: "WriteTxnMarkers", 28: "TxnOffsetCommit", 29: "DescribeAcls", 30: "CreateAcls",
+	31: "DeleteAcls", 32: "DescribeConfigs", 33: "AlterConfigs", 34: "AlterReplicaLogDirs", 35: "DescribeLogDirs",
+	36: "SaslAuthenticate", 37: "CreatePartitions", 38: "CreateDelegationToken", 39: "RenewDelegationToken",
+	40: "ExpireDelegationToken", 41: "DescribeDelegationToken", 42: "DeleteGroups", 43: "ElectLeaders",
+	44: "IncrementalAlterConfigs", 45: "AlterPartitionReassignments", 46: "ListPartitionReassignments",
+	47: "OffsetDelete",
+}
+
+// RequestLogEntry is a single Kafka request along with the outcome of its response
+type RequestLogEntry struct {
+	Timestamp     time.Time `json:"timestamp"`
+	BrokerAddress string    `json:"brokerAddress"`
+	APIKey        int16     `json:"apiKey"`
+	APIName       string    `json:"apiName"`
+	APIVersion    int16     `json:"apiVersion"`
+	CorrelationID int32     `json:"correlationId"`
+	RequestBytes  int       `json:"requestBytes"`
+	ResponseBytes int       `json:"responseBytes"`   // 0 if no response has been sent (e.g. produce with acks=0)
+	DurationMs    float64   `json:"durationMs"`      // Time until the whole response has been read
+	Error         string    `json:"error,omitempty"` // Network errors only, Kafka error codes are part of the response
+}
+
+// RequestLog records all Kafka requests while it's enabled. It's enabled for a bounded time window only, so that
+// forgetting to disable it doesn't fill up the memory or slow down kowl permanently.
+type RequestLog struct {
+	// UnsupportedReason is set if the connection setup does not allow to inspect the Kafka requests
+	UnsupportedReason string
+
+	mutex        sync.RWMutex
+	enabledUntil time.Time
+	entries      []RequestLogEntry
+	next         int // Position of the next entry in the ring buffer
+
+	// durations exports the duration of all requests regardless of whether the log is enabled, it's nil if request
+	// durations are not exported
+	durations *prometheus.HistogramVec
+}
+
+// NewRequestLog creates a disabled request log
+func NewRequestLog() *RequestLog {
+	return &RequestLog{entries: make([]RequestLogEntry, 0)}
+}
+
+// Enable starts recording requests for the given duration. Previously recorded entries are dropped.
+func (l *RequestLog) Enable(duration time.Duration) error {
+	if l.UnsupportedReason != "" {
+		return fmt.Errorf("request logging is not supported: %v", l.UnsupportedReason)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.enabledUntil = time.Now().Add(duration)
+	l.entries = make([]RequestLogEntry, 0)
+	l.next = 0
+
+	return nil
+}
+
+// Disable stops recording requests. Recorded entries are kept until the log is enabled again.
+func (l *RequestLog) Disable() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.enabledUntil = time.Time{}
+}
+
+// EnabledUntil returns the time until requests are recorded, which is in the past if the log is disabled
+func (l *RequestLog) EnabledUntil() time.Time {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.enabledUntil
+}
+
+// IsEnabled returns true if requests are currently recorded
+func (l *RequestLog) IsEnabled() bool {
+	return time.Now().Before(l.EnabledUntil())
+}
+
+// Entries returns all recorded entries, oldest first
+func (l *RequestLog) Entries() []RequestLogEntry {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	res := make([]RequestLogEntry, 0, len(l.entries))
+	if len(l.entries) == requestLogCapacity {
+		res = append(res, l.entries[l.next:]...)
+		res = append(res, l.entries[:l.next]...)
+		return res
+	}
+	return append(res, l.entries...)
+}
+
+func (l *RequestLog) add(entry RequestLogEntry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !time.Now().Before(l.enabledUntil) {
+		return
+	}
+
+	if len(l.entries) < requestLogCapacity {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % requestLogCapacity
+}
+
+// observeDuration exports the duration of a request which has received a response
+func (l *RequestLog) observeDuration(entry RequestLogEntry) {
+	if l.durations == nil {
+		return
+	}
+	apiName := entry.APIName
+	if apiName == "" {
+		apiName = "Unknown"
+	}
+	l.durations.WithLabelValues(apiName).Observe(entry.DurationMs / 1000)
+}
+
+// requestLogDialer is used as sarama's proxy dialer, so that we can wrap all broker connections. Because sarama
+// bypasses the proxy dialer if TLS is enabled, the dialer establishes the TLS connection itself.
+type requestLogDialer struct {
+	dialer    net.Dialer
+	tlsConfig *tls.Config // nil if TLS is disabled
+	log       *RequestLog
+}
+
+func (d *requestLogDialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.tlsConfig != nil {
+		// Same as tls.DialWithDialer does it
+		cfg := d.tlsConfig.Clone()
+		if cfg.ServerName == "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			cfg.ServerName = host
+		}
+
+		tlsConn := tls.Client(conn, cfg)
+		if d.dialer.Timeout > 0 {
+			_ = conn.SetDeadline(time.Now().Add(d.dialer.Timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		_ = conn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+
+	return &requestLogConn{Conn: conn, log: d.log, addr: addr}, nil
+}
+
+// pendingRequest is a request which has been sent, but whose response has not been read completely yet
+type pendingRequest struct {
+	Entry   RequestLogEntry
+	Started time.Time
+}
+
+// requestLogConn inspects the request and response headers which are sent over the connection. Sarama writes each
+// request with a single write call. Responses are read in arbitrary chunks and are therefore parsed as a stream.
+// Kafka responds to the requests of a connection in the order they have been sent.
+type requestLogConn struct {
+	net.Conn
+	log  *RequestLog
+	addr string
+
+	mutex   sync.Mutex
+	pending []pendingRequest
+
+	// Response parser state
+	header      [8]byte // Size and correlation id
+	headerLen   int
+	headerSize  int // 4 for raw SASL responses which have no correlation id, 8 otherwise
+	remaining   int
+	isRawSASL   bool // The next written request is a raw SASL token
+	responseLen int
+	responseCID int32
+}
+
+func (c *requestLogConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.isRawSASL {
+		// The raw SASL token has no request header, its response has no correlation id
+		c.isRawSASL = false
+		c.headerSize = 4
+		return n, err
+	}
+	if len(b) < 12 || int(binary.BigEndian.Uint32(b[0:4])) != len(b)-4 {
+		return n, err
+	}
+
+	apiKey := int16(binary.BigEndian.Uint16(b[4:6]))
+	apiVersion := int16(binary.BigEndian.Uint16(b[6:8]))
+	if apiKey == apiKeySaslHandshake && apiVersion == 0 {
+		c.isRawSASL = true
+	}
+	if !c.log.IsEnabled() && c.log.durations == nil {
+		return n, err
+	}
+
+	entry := RequestLogEntry{
+		Timestamp:     time.Now(),
+		BrokerAddress: c.addr,
+		APIKey:        apiKey,
+		APIName:       apiNames[apiKey],
+		APIVersion:    apiVersion,
+		CorrelationID: int32(binary.BigEndian.Uint32(b[8:12])),
+		RequestBytes:  len(b),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		c.log.add(entry)
+		return n, err
+	}
+	c.pending = append(c.pending, pendingRequest{Entry: entry, Started: entry.Timestamp})
+
+	return n, err
+}
+
+func (c *requestLogConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.observeResponses(b[:n])
+	if err != nil {
+		// All pending requests won't receive a response anymore
+		for _, p := range c.pending {
+			p.Entry.Error = err.Error()
+			p.Entry.DurationMs = float64(time.Since(p.Started)) / float64(time.Millisecond)
+			c.log.add(p.Entry)
+		}
+		c.pending = nil
+	}
+
+	return n, err
+}
+
+func (c *requestLogConn) observeResponses(b []byte) {
+	for len(b) > 0 {
+		if c.remaining > 0 {
+			k := c.remaining
+			if k > len(b) {
+				k = len(b)
+			}
+			c.remaining -= k
+			b = b[k:]
+			if c.remaining == 0 {
+				c.completeResponse()
+			}
+			continue
+		}
+
+		headerSize := c.headerSize
+		if headerSize == 0 {
+			headerSize = 8
+		}
+		k := copy(c.header[c.headerLen:headerSize], b)
+		c.headerLen += k
+		b = b[k:]
+		if c.headerLen < headerSize {
+			continue
+		}
+
+		// Header is complete, the size field includes the correlation id but not itself
+		size := int(binary.BigEndian.Uint32(c.header[0:4]))
+		c.responseLen = size + 4
+		c.remaining = size - (headerSize - 4)
+		c.responseCID = -1
+		if headerSize == 8 {
+			c.responseCID = int32(binary.BigEndian.Uint32(c.header[4:8]))
+		}
+		c.headerLen = 0
+		c.headerSize = 0
+		if c.remaining <= 0 {
+			c.remaining = 0
+			c.completeResponse()
+		}
+	}
+}
+
+// completeResponse logs the request of the response which has been read completely. Pending requests which have
+// been sent before that request did not expect a response.
+func (c *requestLogConn) completeResponse() {
+	if c.responseCID < 0 {
+		return // Raw SASL response
+	}
+
+	for i, p := range c.pending {
+		if p.Entry.CorrelationID != c.responseCID {
+			continue
+		}
+		for _, withoutResponse := range c.pending[:i] {
+			c.log.add(withoutResponse.Entry)
+		}
+		p.Entry.ResponseBytes = c.responseLen
+		p.Entry.DurationMs = float64(time.Since(p.Started)) / float64(time.Millisecond)
+		c.log.add(p.Entry)
+		c.log.observeDuration(p.Entry)
+		c.pending = c.pending[i+1:]
+		return
+	}
+}
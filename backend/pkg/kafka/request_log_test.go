@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkedConn writes into nothing and returns the given response bytes in chunks of the given size
+type chunkedConn struct {
+	net.Conn
+	responses *bytes.Reader
+	chunkSize int
+}
+
+func (c *chunkedConn) Write(b []byte) (int, error) { return len(b), nil }
+func (c *chunkedConn) Read(b []byte) (int, error) {
+	if len(b) > c.chunkSize {
+		b = b[:c.chunkSize]
+	}
+	return c.responses.Read(b)
+}
+
+func request(apiKey int16, version int16, correlationID int32) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint32(b[0:4], 12)
+	binary.BigEndian.PutUint16(b[4:6], uint16(apiKey))
+	binary.BigEndian.PutUint16(b[6:8], uint16(version))
+	binary.BigEndian.PutUint32(b[8:12], uint32(correlationID))
+	return b
+}
+
+func response(correlationID int32, bodySize int) []byte {
+	b := make([]byte, 8+bodySize)
+	binary.BigEndian.PutUint32(b[0:4], uint32(4+bodySize))
+	binary.BigEndian.PutUint32(b[4:8], uint32(correlationID))
+	return b
+}
+
+func TestRequestLogConn(t *testing.T) {
+	log := NewRequestLog()
+	assert.NoError(t, log.Enable(time.Minute))
+
+	// Produce with acks=0 (correlation id 2) doesn't get a response
+	responses := append(response(1, 100), response(3, 5)...)
+	conn := &requestLogConn{
+		Conn: &chunkedConn{responses: bytes.NewReader(responses), chunkSize: 7},
+		log:  log,
+		addr: "broker-0:9092",
+	}
+	_, _ = conn.Write(request(3, 1, 1))
+	_, _ = conn.Write(request(0, 3, 2))
+	_, _ = conn.Write(request(1, 4, 3))
+
+	buf := make([]byte, 64)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			break
+		}
+	}
+
+	entries := log.Entries()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "Metadata", entries[0].APIName)
+	assert.Equal(t, 108, entries[0].ResponseBytes)
+	assert.Equal(t, "Produce", entries[1].APIName)
+	assert.Equal(t, 0, entries[1].ResponseBytes)
+	assert.Equal(t, "Fetch", entries[2].APIName)
+	assert.Equal(t, int32(3), entries[2].CorrelationID)
+	assert.Equal(t, 13, entries[2].ResponseBytes)
+}
+
+func TestRequestLogConn_RawSASL(t *testing.T) {
+	log := NewRequestLog()
+	assert.NoError(t, log.Enable(time.Minute))
+
+	// SASL handshake v0 is followed by the raw auth token and a raw response without correlation id
+	rawSASLResponse := []byte{0, 0, 0, 0}
+	responses := append(response(1, 10), rawSASLResponse...)
+	responses = append(responses, response(2, 3)...)
+	conn := &requestLogConn{
+		Conn: &chunkedConn{responses: bytes.NewReader(responses), chunkSize: 6},
+		log:  log,
+		addr: "broker-0:9092",
+	}
+	_, _ = conn.Write(request(apiKeySaslHandshake, 0, 1))
+	buf := make([]byte, 64)
+	for i := 0; i < 3; i++ {
+		_, _ = conn.Read(buf) // Handshake response
+	}
+	_, _ = conn.Write([]byte{0, 0, 0, 8, 0, 'u', 's', 'r', 0, 'p', 'w', 'd'})
+	_, _ = conn.Write(request(3, 1, 2))
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			break
+		}
+	}
+
+	entries := log.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "SaslHandshake", entries[0].APIName)
+	assert.Equal(t, "Metadata", entries[1].APIName)
+	assert.Equal(t, 11, entries[1].ResponseBytes)
+}
+
+func TestRequestLogConn_DurationsWhileDisabled(t *testing.T) {
+	log := NewRequestLog()
+	log.durations = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_request_duration_seconds"}, []string{"api"})
+
+	conn := &requestLogConn{
+		Conn: &chunkedConn{responses: bytes.NewReader(response(1, 10)), chunkSize: 64},
+		log:  log,
+		addr: "broker-0:9092",
+	}
+	_, _ = conn.Write(request(3, 1, 1))
+	buf := make([]byte, 64)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			break
+		}
+	}
+
+	assert.Empty(t, log.Entries())
+	assert.Equal(t, 1, testutil.CollectAndCount(log.durations))
+}
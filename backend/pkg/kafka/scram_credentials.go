@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// Error codes of SCRAM credential requests which sarama doesn't know
+const (
+	ErrResourceNotFound       sarama.KError = 91
+	ErrDuplicateResource      sarama.KError = 92
+	ErrUnacceptableCredential sarama.KError = 93
+)
+
+// scramSaltLength is the number of random bytes the password of an upserted credential is salted with
+const scramSaltLength = 32
+
+// ScramMechanism is the hash function of a SCRAM credential as it's encoded in the protocol
+type ScramMechanism int8
+
+const (
+	ScramMechanismUnknown ScramMechanism = 0
+	ScramMechanismSHA256  ScramMechanism = 1
+	ScramMechanismSHA512  ScramMechanism = 2
+)
+
+// String returns the SASL name of the mechanism
+func (m ScramMechanism) String() string {
+	switch m {
+	case ScramMechanismSHA256:
+		return sarama.SASLTypeSCRAMSHA256
+	case ScramMechanismSHA512:
+		return sarama.SASLTypeSCRAMSHA512
+	}
+	return "UNKNOWN"
+}
+
+// ParseScramMechanism parses the SASL name of a mechanism, e.g. SCRAM-SHA-256
+func ParseScramMechanism(name string) (ScramMechanism, error) {
+	switch strings.ToUpper(name) {
+	case sarama.SASLTypeSCRAMSHA256:
+		return ScramMechanismSHA256, nil
+	case sarama.SASLTypeSCRAMSHA512:
+		return ScramMechanismSHA512, nil
+	}
+	return ScramMechanismUnknown, fmt.Errorf("unknown scram mechanism '%v', expected %v or %v", name, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512)
+}
+
+func (m ScramMechanism) hashGenerator() func() hash.Hash {
+	if m == ScramMechanismSHA512 {
+		return scramSha512
+	}
+	return scramSha256
+}
+
+// ScramCredentialInfo describes a credential of a user, the salted password is never returned by Kafka
+type ScramCredentialInfo struct {
+	Mechanism  ScramMechanism
+	Iterations int32
+}
+
+// UserScramCredentials are the credentials of a single user. Err is ErrResourceNotFound if the user has been
+// requested explicitly but has no credentials.
+type UserScramCredentials struct {
+	User        string
+	Err         sarama.KError
+	ErrMessage  string
+	Credentials []ScramCredentialInfo
+}
+
+// ScramCredentialUpsertion creates or replaces the user's credential of the given mechanism. The password is salted
+// and hashed before it's sent to Kafka.
+type ScramCredentialUpsertion struct {
+	User       string
+	Mechanism  ScramMechanism
+	Iterations int32
+	Password   string
+}
+
+// ScramCredentialDeletion deletes the user's credential of the given mechanism
+type ScramCredentialDeletion struct {
+	User      string
+	Mechanism ScramMechanism
+}
+
+// ScramAlterationResult is the outcome of all alterations of a single user
+type ScramAlterationResult struct {
+	User       string
+	Err        sarama.KError
+	ErrMessage string
+}
+
+// DescribeUserScramCredentials returns the SCRAM credentials of the given users, or of all users if users is nil.
+// The DescribeUserScramCredentials API requires Kafka 2.7 or newer.
+func (s *Service) DescribeUserScramCredentials(users []string) ([]UserScramCredentials, error) {
+	controller, err := s.scramController()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.sendRawRequest(controller.Addr(), apiKeyDescribeUserScramCredentials, 0, true, encodeDescribeUserScramCredentialsRequest(users))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe scram credentials: %w", err)
+	}
+
+	return decodeDescribeUserScramCredentialsResponse(res)
+}
+
+// AlterUserScramCredentials creates, replaces and deletes SCRAM credentials. Kafka applies the alterations of each
+// user independently, hence the result of each user must be checked. The AlterUserScramCredentials API requires
+// Kafka 2.7 or newer.
+func (s *Service) AlterUserScramCredentials(upsertions []ScramCredentialUpsertion, deletions []ScramCredentialDeletion) ([]ScramAlterationResult, error) {
+	controller, err := s.scramController()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := encodeAlterUserScramCredentialsRequest(upsertions, deletions)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.sendRawRequest(controller.Addr(), apiKeyAlterUserScramCredentials, 0, true, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to alter scram credentials: %w", err)
+	}
+
+	return decodeAlterUserScramCredentialsResponse(res)
+}
+
+// scramController returns the controller, which the alterations must be sent to, if it supports the SCRAM APIs.
+// Sarama doesn't implement them and all of their versions are flexible.
+func (s *Service) scramController() (*sarama.Broker, error) {
+	controller, err := s.Client.Controller()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster controller: %w", err)
+	}
+	_, isSupported, err := rawRequestVersion(controller, apiKeyAlterUserScramCredentials, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !isSupported {
+		return nil, fmt.Errorf("%w: the cluster doesn't support managing scram credentials, Kafka 2.7 or newer is required", sarama.ErrUnsupportedVersion)
+	}
+
+	return controller, nil
+}
+
+// encodeDescribeUserScramCredentialsRequest encodes a DescribeUserScramCredentials request in version 0
+func encodeDescribeUserScramCredentialsRequest(users []string) []byte {
+	e := &rawEncoder{}
+	if users == nil {
+		e.putCompactArrayLength(-1) // Null array describes all users
+	} else {
+		e.putCompactArrayLength(len(users))
+		for _, user := range users {
+			e.putCompactString(user)
+			e.putEmptyTaggedFields()
+		}
+	}
+	e.putEmptyTaggedFields()
+
+	return e.bytes()
+}
+
+// decodeDescribeUserScramCredentialsResponse decodes a DescribeUserScramCredentials response in version 0
+func decodeDescribeUserScramCredentialsResponse(res []byte) ([]UserScramCredentials, error) {
+	d := &rawDecoder{b: res}
+	d.int32() // Throttle time
+	errCode := sarama.KError(d.int16())
+	errMessage := d.compactString()
+	if errCode != sarama.ErrNoError && d.err == nil {
+		return nil, fmt.Errorf("failed to describe scram credentials: %w: %v", errCode, errMessage)
+	}
+
+	results := make([]UserScramCredentials, d.compactArrayLength())
+	for i := range results {
+		results[i] = UserScramCredentials{
+			User:       d.compactString(),
+			Err:        sarama.KError(d.int16()),
+			ErrMessage: d.compactString(),
+		}
+		results[i].Credentials = make([]ScramCredentialInfo, d.compactArrayLength())
+		for j := range results[i].Credentials {
+			results[i].Credentials[j] = ScramCredentialInfo{
+				Mechanism:  ScramMechanism(d.int8()),
+				Iterations: d.int32(),
+			}
+			d.skipTaggedFields()
+		}
+		d.skipTaggedFields()
+	}
+	d.skipTaggedFields()
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to decode describe scram credentials response: %w", d.err)
+	}
+
+	return results, nil
+}
+
+// encodeAlterUserScramCredentialsRequest encodes an AlterUserScramCredentials request in version 0. The passwords of
+// the upsertions are salted with random bytes.
+func encodeAlterUserScramCredentialsRequest(upsertions []ScramCredentialUpsertion, deletions []ScramCredentialDeletion) ([]byte, error) {
+	e := &rawEncoder{}
+	e.putCompactArrayLength(len(deletions))
+	for _, deletion := range deletions {
+		e.putCompactString(deletion.User)
+		e.putInt8(int8(deletion.Mechanism))
+		e.putEmptyTaggedFields()
+	}
+
+	e.putCompactArrayLength(len(upsertions))
+	for _, upsertion := range upsertions {
+		salt := make([]byte, scramSaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		e.putCompactString(upsertion.User)
+		e.putInt8(int8(upsertion.Mechanism))
+		e.putInt32(upsertion.Iterations)
+		e.putCompactBytes(salt)
+		e.putCompactBytes(scramSaltedPassword(upsertion.Mechanism, []byte(upsertion.Password), salt, int(upsertion.Iterations)))
+		e.putEmptyTaggedFields()
+	}
+	e.putEmptyTaggedFields()
+
+	return e.bytes(), nil
+}
+
+// decodeAlterUserScramCredentialsResponse decodes an AlterUserScramCredentials response in version 0
+func decodeAlterUserScramCredentialsResponse(res []byte) ([]ScramAlterationResult, error) {
+	d := &rawDecoder{b: res}
+	d.int32() // Throttle time
+
+	results := make([]ScramAlterationResult, d.compactArrayLength())
+	for i := range results {
+		results[i] = ScramAlterationResult{
+			User:       d.compactString(),
+			Err:        sarama.KError(d.int16()),
+			ErrMessage: d.compactString(),
+		}
+		d.skipTaggedFields()
+	}
+	d.skipTaggedFields()
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to decode alter scram credentials response: %w", d.err)
+	}
+
+	return results, nil
+}
+
+// scramSaltedPassword computes Hi(password, salt, iterations) as defined in RFC 5802, which is PBKDF2 with the
+// mechanism's HMAC and a single output block. Like Kafka, the password is used as UTF-8 bytes without SASLprep.
+func scramSaltedPassword(mechanism ScramMechanism, password []byte, salt []byte, iterations int) []byte {
+	mac := hmac.New(mechanism.hashGenerator(), password)
+	mac.Write(salt)
+	blockIndex := make([]byte, 4)
+	binary.BigEndian.PutUint32(blockIndex, 1)
+	mac.Write(blockIndex)
+	u := mac.Sum(nil)
+
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+
+	return result
+}
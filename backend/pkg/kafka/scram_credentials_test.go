@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDescribeUserScramCredentialsRequest(t *testing.T) {
+	assert.Equal(t, []byte{0, 0}, encodeDescribeUserScramCredentialsRequest(nil))
+
+	expected := []byte{
+		2,                             // One user
+		6, 'a', 'l', 'i', 'c', 'e', 0, // User with empty tagged fields
+		0, // Tagged fields
+	}
+	assert.Equal(t, expected, encodeDescribeUserScramCredentialsRequest([]string{"alice"}))
+}
+
+func TestDecodeDescribeUserScramCredentialsResponse(t *testing.T) {
+	res := []byte{
+		0, 0, 0, 0, // Throttle time
+		0, 0, // Error code
+		0, // Null error message
+		3, // Two results
+		6, 'a', 'l', 'i', 'c', 'e', 0, 0, 0,
+		3,                   // Two credentials
+		1, 0, 0, 0x10, 0, 0, // SCRAM-SHA-256 with 4096 iterations
+		2, 0, 0, 0x20, 0, 1, 1, 0, // SCRAM-SHA-512 with 8192 iterations and a tagged field
+		0,
+		4, 'b', 'o', 'b', 0, 91, 3, 'n', 'o', 1, 0,
+		0,
+	}
+	results, err := decodeDescribeUserScramCredentialsResponse(res)
+	require.NoError(t, err)
+	assert.Equal(t, []UserScramCredentials{
+		{User: "alice", Err: sarama.ErrNoError, Credentials: []ScramCredentialInfo{
+			{Mechanism: ScramMechanismSHA256, Iterations: 4096},
+			{Mechanism: ScramMechanismSHA512, Iterations: 8192},
+		}},
+		{User: "bob", Err: ErrResourceNotFound, ErrMessage: "no", Credentials: []ScramCredentialInfo{}},
+	}, results)
+
+	_, err = decodeDescribeUserScramCredentialsResponse(res[:20])
+	assert.Error(t, err)
+}
+
+func TestEncodeAlterUserScramCredentialsRequest(t *testing.T) {
+	req, err := encodeAlterUserScramCredentialsRequest(
+		[]ScramCredentialUpsertion{{User: "bob", Mechanism: ScramMechanismSHA512, Iterations: 4096, Password: "secret"}},
+		[]ScramCredentialDeletion{{User: "alice", Mechanism: ScramMechanismSHA256}},
+	)
+	require.NoError(t, err)
+
+	d := &rawDecoder{b: req}
+	require.Equal(t, 1, d.compactArrayLength())
+	assert.Equal(t, "alice", d.compactString())
+	assert.Equal(t, int8(ScramMechanismSHA256), d.int8())
+	d.skipTaggedFields()
+
+	require.Equal(t, 1, d.compactArrayLength())
+	assert.Equal(t, "bob", d.compactString())
+	assert.Equal(t, int8(ScramMechanismSHA512), d.int8())
+	assert.Equal(t, int32(4096), d.int32())
+	salt := d.next(int(d.uvarint()) - 1)
+	saltedPassword := d.next(int(d.uvarint()) - 1)
+	d.skipTaggedFields()
+	d.skipTaggedFields()
+	require.NoError(t, d.err)
+	assert.Empty(t, d.b)
+	assert.Len(t, salt, scramSaltLength)
+	assert.Equal(t, scramSaltedPassword(ScramMechanismSHA512, []byte("secret"), salt, 4096), saltedPassword)
+}
+
+func TestScramSaltedPassword(t *testing.T) {
+	// Expected values have been computed with PBKDF2, the first one uses the parameters of the RFC 7677 example
+	salt, err := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	require.NoError(t, err)
+	tt := []struct {
+		mechanism ScramMechanism
+		salt      []byte
+		expected  string
+	}{
+		{ScramMechanismSHA256, salt, "c4a49510323ab4f952cac1fa99441939e78ea74d6be81ddf7096e87513dc615d"},
+		{ScramMechanismSHA512, []byte("salt"), "2cfe3a1c151662b1ea49d13f595674a1c666add70df15d3d02254e9905993878261da7407fd11c2fee4b0a30df5154b1a752f86a13380ddd4bdd9a7c958ec769"},
+	}
+
+	for i, test := range tt {
+		actual := scramSaltedPassword(test.mechanism, []byte("pencil"), test.salt, 4096)
+		assert.Equal(t, test.expected, hex.EncodeToString(actual), "Case: ", i)
+	}
+}
+
+func TestParseScramMechanism(t *testing.T) {
+	m, err := ParseScramMechanism("scram-sha-512")
+	require.NoError(t, err)
+	assert.Equal(t, ScramMechanismSHA512, m)
+	assert.Equal(t, "SCRAM-SHA-512", m.String())
+
+	_, err = ParseScramMechanism("PLAIN")
+	assert.Error(t, err)
+}
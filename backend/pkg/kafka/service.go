@@ -1,9 +1,11 @@
 package kafka
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
 )
@@ -12,7 +14,71 @@ import (
 type Service struct {
 	MetricsNamespace string
 	Client           sarama.Client
+	Producer         sarama.SyncProducer
+	Admin            sarama.ClusterAdmin
+	RequestLog       *RequestLog
 	Logger           *zap.Logger
+
+	fingerprint       *clusterFingerprint
+	clusterIDMismatch prometheus.Gauge
+}
+
+// NewService creates all clients which are needed to talk to the Kafka cluster
+func NewService(cfg *Config, metricsNamespace string, logger *zap.Logger) (*Service, error) {
+	// Sarama Config
+	requestLog := NewRequestLog()
+	requestLog.durations = newRequestDurationHistogram(metricsNamespace)
+	saramaConfig, err := NewSaramaConfig(cfg, requestLog, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a valid sarama config: %w", err)
+	}
+
+	// Sarama Client
+	client, err := sarama.NewClient(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka cluster admin: %w", err)
+	}
+
+	svc := &Service{
+		MetricsNamespace:  metricsNamespace,
+		Client:            client,
+		Producer:          producer,
+		Admin:             admin,
+		RequestLog:        requestLog,
+		Logger:            logger,
+		fingerprint:       &clusterFingerprint{expectedID: cfg.ExpectedClusterID},
+		clusterIDMismatch: newClusterIDMismatchGauge(metricsNamespace),
+	}
+
+	// Refuse to start if we have connected to another cluster than the configured one
+	controller, err := client.Controller()
+	if err != nil {
+		logger.Warn("failed to get cluster controller, the cluster id will be checked once brokers are reachable", zap.Error(err))
+		return svc, nil
+	}
+	res, err := controller.GetMetadata(&sarama.MetadataRequest{Version: svc.metadataRequestVersion(), Topics: []string{}})
+	if err != nil {
+		logger.Warn("failed to get cluster metadata, the cluster id will be checked once brokers are reachable", zap.Error(err))
+		return svc, nil
+	}
+	if res.ClusterID != nil {
+		if err := svc.fingerprint.observe(*res.ClusterID, controller.Addr()); err != nil {
+			return nil, err
+		}
+		logger.Info("connected to Kafka cluster", zap.String("cluster_id", *res.ClusterID))
+	}
+
+	return svc, nil
 }
 
 // Start initializes the Kafka Service and takes care of stuff like KeepAlive
@@ -55,7 +121,7 @@ func (s *Service) keepAlive() {
 			}
 
 			// Verify existing connection
-			_, err := broker.GetMetadata(&sarama.MetadataRequest{})
+			res, err := broker.GetMetadata(&sarama.MetadataRequest{Version: s.metadataRequestVersion()})
 			if err != nil {
 				log.Warn("heartbeat: lost connection to broker", zap.Error(err), zap.String("broker", broker.Addr()), zap.Int32("id", broker.ID()))
 				_ = broker.Close()
@@ -64,6 +130,7 @@ func (s *Service) keepAlive() {
 			}
 
 			// Broker connection is healthy
+			s.checkClusterID(res, broker)
 			connectedCount++
 		}
 
@@ -80,3 +147,27 @@ func (s *Service) keepAlive() {
 		}
 	}
 }
+
+// NewConsumer creates a consumer which shares the connections of the client. A new consumer is required for each
+// request, because each consumer can consume a topic partition only once at the same time. The isolation level is
+// part of the client's config, consumers with a different isolation level therefore use a dedicated client which
+// is closed along with the consumer.
+func (s *Service) NewConsumer(isolationLevel sarama.IsolationLevel) (sarama.Consumer, error) {
+	if isolationLevel == s.Client.Config().Consumer.IsolationLevel {
+		return sarama.NewConsumerFromClient(s.Client)
+	}
+
+	cfg := *s.Client.Config()
+	if isolationLevel == sarama.ReadCommitted && !cfg.Version.IsAtLeast(sarama.V0_11_0_0) {
+		return nil, fmt.Errorf("consuming committed messages only requires the cluster version to be at least 0.11.0.0")
+	}
+	cfg.Consumer.IsolationLevel = isolationLevel
+
+	brokers := s.Client.Brokers()
+	addrs := make([]string, len(brokers))
+	for i, broker := range brokers {
+		addrs[i] = broker.Addr()
+	}
+
+	return sarama.NewConsumer(addrs, &cfg)
+}
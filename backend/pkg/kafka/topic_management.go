@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// CreateTopic creates a topic with the given configs. If validateOnly is true the request is only validated by the
+// controller, but the topic won't be created.
+func (s *Service) CreateTopic(topicName string, partitionCount int32, replicationFactor int16, configs map[string]*string, validateOnly bool) error {
+	detail := &sarama.TopicDetail{
+		NumPartitions:     partitionCount,
+		ReplicationFactor: replicationFactor,
+		ConfigEntries:     configs,
+	}
+	err := s.Admin.CreateTopic(topicName, detail, validateOnly)
+
+	// Unwrap the topic error so that callers can check the returned kafka error code
+	var topicErr *sarama.TopicError
+	if errors.As(err, &topicErr) {
+		if topicErr.ErrMsg != nil && *topicErr.ErrMsg != "" {
+			return fmt.Errorf("%w: %v", topicErr.Err, *topicErr.ErrMsg)
+		}
+		return topicErr.Err
+	}
+
+	return err
+}
+
+// DeleteTopic deletes the given topic. Brokers only mark the topic for deletion if delete.topic.enable is false.
+func (s *Service) DeleteTopic(topicName string) error {
+	return s.Admin.DeleteTopic(topicName)
+}
+
+// CreatePartitions increases the topic's partition count to the given count. We don't use the cluster admin here,
+// because it doesn't pass validateOnly along with the request.
+func (s *Service) CreatePartitions(topicName string, partitionCount int32, validateOnly bool) error {
+	req := &sarama.CreatePartitionsRequest{
+		TopicPartitions: map[string]*sarama.TopicPartition{topicName: {Count: partitionCount}},
+		Timeout:         s.Client.Config().Admin.Timeout,
+		ValidateOnly:    validateOnly,
+	}
+
+	b, err := s.Client.Controller()
+	if err != nil {
+		return fmt.Errorf("could not get cluster controller broker: %w", err)
+	}
+	res, err := b.CreatePartitions(req)
+	if err != nil {
+		return err
+	}
+
+	topicErr, ok := res.TopicPartitionErrors[topicName]
+	if !ok {
+		return sarama.ErrIncompleteResponse
+	}
+	if topicErr.Err == sarama.ErrNoError {
+		return nil
+	}
+	if topicErr.ErrMsg != nil && *topicErr.ErrMsg != "" {
+		return fmt.Errorf("%w: %v", topicErr.Err, *topicErr.ErrMsg)
+	}
+	return topicErr.Err
+}
+
+// AlterTopicConfig replaces all dynamic configs of a topic with the given config entries. Configs which are not
+// part of the entries are reset to their defaults. We don't use the cluster admin here, because it drops the error
+// code of the response which is required to tell validation errors apart.
+func (s *Service) AlterTopicConfig(topicName string, entries map[string]*string, validateOnly bool) error {
+	req := &sarama.AlterConfigsRequest{
+		Resources: []*sarama.AlterConfigsResource{
+			{
+				Type:          sarama.TopicResource,
+				Name:          topicName,
+				ConfigEntries: entries,
+			},
+		},
+		ValidateOnly: validateOnly,
+	}
+
+	b, err := s.Client.Controller()
+	if err != nil {
+		return fmt.Errorf("could not get cluster controller broker: %w", err)
+	}
+	res, err := b.AlterConfigs(req)
+	if err != nil {
+		return err
+	}
+
+	for _, resource := range res.Resources {
+		if resource.ErrorCode == int16(sarama.ErrNoError) {
+			continue
+		}
+		kErr := sarama.KError(resource.ErrorCode)
+		if resource.ErrorMsg != "" {
+			return fmt.Errorf("%w: %v", kErr, resource.ErrorMsg)
+		}
+		return kErr
+	}
+
+	return nil
+}
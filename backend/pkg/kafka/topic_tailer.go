@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// TopicTailer consumes all records of a topic which have been produced within the retention and keeps following
+// new records. The consumer is restarted after errors, e.g. if a broker is not reachable, and continues where it
+// stopped, so that every record is handled once.
+type TopicTailer struct {
+	cluster       Cluster
+	topicName     string
+	retention     time.Duration
+	retryInterval time.Duration
+	handle        func(msg *sarama.ConsumerMessage)
+	logger        *zap.Logger
+	warningLimit  *rate.Limiter
+
+	// nextOffsets are only accessed by the tailing goroutine
+	nextOffsets map[int32]int64
+
+	mutex         sync.RWMutex
+	isBackfilling bool
+}
+
+// NewTopicTailer creates a tailer which passes each record of the topic to the handle function. Records are handed
+// over one at a time.
+func NewTopicTailer(cluster Cluster, topicName string, retention time.Duration, retryInterval time.Duration, handle func(msg *sarama.ConsumerMessage), logger *zap.Logger) *TopicTailer {
+	return &TopicTailer{
+		cluster:       cluster,
+		topicName:     topicName,
+		retention:     retention,
+		retryInterval: retryInterval,
+		handle:        handle,
+		logger:        logger.With(zap.String("topic", topicName)),
+		warningLimit:  rate.NewLimiter(rate.Every(5*time.Minute), 1),
+		nextOffsets:   make(map[int32]int64),
+		isBackfilling: true,
+	}
+}
+
+// Start tails the topic until the process exits
+func (t *TopicTailer) Start() {
+	go func() {
+		for {
+			err := t.consume()
+			if t.warningLimit.Allow() {
+				t.logger.Warn("failed to tail topic, retrying", zap.Error(err))
+			}
+			time.Sleep(t.retryInterval)
+		}
+	}()
+}
+
+// IsBackfilling returns true until all records which have been produced before the tailer started were handled
+func (t *TopicTailer) IsBackfilling() bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.isBackfilling
+}
+
+func (t *TopicTailer) setBackfilling(isBackfilling bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.isBackfilling = isBackfilling
+}
+
+// consume consumes all partitions of the topic, starting at the records within the retention or where the previous
+// consumer stopped. It blocks until a partition consumer fails.
+func (t *TopicTailer) consume() error {
+	partitionIDs, err := t.cluster.ListPartitions(t.topicName)
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+	startOffsets, err := t.startOffsets(partitionIDs)
+	if err != nil {
+		return err
+	}
+	waterMarks, err := t.cluster.WaterMarks(t.topicName, partitionIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get water marks: %w", err)
+	}
+
+	consumer, err := t.cluster.NewConsumer(sarama.ReadUncommitted)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	// Backfilling is done once all partitions reached the high water mark they had when the consumer started
+	backfilling := make(map[int32]struct{})
+	partitionConsumers := make([]sarama.PartitionConsumer, 0, len(partitionIDs))
+	defer func() {
+		for _, pc := range partitionConsumers {
+			pc.AsyncClose()
+		}
+	}()
+	for _, partitionID := range partitionIDs {
+		pc, err := consumer.ConsumePartition(t.topicName, partitionID, startOffsets[partitionID])
+		if err != nil {
+			return fmt.Errorf("failed to consume partition '%v': %w", partitionID, err)
+		}
+		partitionConsumers = append(partitionConsumers, pc)
+		if wm, ok := waterMarks[partitionID]; ok && startOffsets[partitionID] >= 0 && startOffsets[partitionID] < wm.High {
+			backfilling[partitionID] = struct{}{}
+		}
+	}
+	t.setBackfilling(len(backfilling) > 0)
+
+	type result struct {
+		msg *sarama.ConsumerMessage
+		err error
+	}
+	results := make(chan result)
+	done := make(chan struct{})
+	defer close(done)
+	for _, pc := range partitionConsumers {
+		go func(pc sarama.PartitionConsumer) {
+			for {
+				var res result
+				select {
+				case msg, ok := <-pc.Messages():
+					if !ok {
+						res.err = fmt.Errorf("partition consumer has been closed")
+					}
+					res.msg = msg
+				case consumerErr, ok := <-pc.Errors():
+					if !ok {
+						res.err = fmt.Errorf("partition consumer has been closed")
+					} else {
+						res.err = consumerErr
+					}
+				}
+				select {
+				case results <- res:
+				case <-done:
+					return
+				}
+				if res.err != nil {
+					return
+				}
+			}
+		}(pc)
+	}
+
+	for res := range results {
+		if res.err != nil {
+			return res.err
+		}
+		t.handle(res.msg)
+		t.nextOffsets[res.msg.Partition] = res.msg.Offset + 1
+
+		if _, ok := backfilling[res.msg.Partition]; ok && res.msg.Offset+1 >= waterMarks[res.msg.Partition].High {
+			delete(backfilling, res.msg.Partition)
+			if len(backfilling) == 0 {
+				t.setBackfilling(false)
+				t.logger.Info("consumed all records within the retention")
+			}
+		}
+	}
+
+	return nil
+}
+
+// startOffsets returns the offsets where the previous consumer stopped. Partitions which have not been consumed yet
+// start at the first record within the retention.
+func (t *TopicTailer) startOffsets(partitionIDs []int32) (map[int32]int64, error) {
+	startOffsets := make(map[int32]int64, len(partitionIDs))
+	unknownPartitionIDs := make([]int32, 0)
+	for _, partitionID := range partitionIDs {
+		if offset, ok := t.nextOffsets[partitionID]; ok {
+			startOffsets[partitionID] = offset
+			continue
+		}
+		unknownPartitionIDs = append(unknownPartitionIDs, partitionID)
+	}
+	if len(unknownPartitionIDs) == 0 {
+		return startOffsets, nil
+	}
+
+	since := time.Now().Add(-t.retention)
+	offsets, err := t.cluster.OffsetsForTimes(t.topicName, unknownPartitionIDs, since.UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offsets for the retention: %w", err)
+	}
+	for _, partitionID := range unknownPartitionIDs {
+		offset, ok := offsets[partitionID]
+		if !ok || offset < 0 {
+			// No record has been produced within the retention
+			offset = sarama.OffsetNewest
+		}
+		startOffsets[partitionID] = offset
+	}
+
+	return startOffsets, nil
+}
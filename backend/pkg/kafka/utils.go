@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -50,7 +51,7 @@ func (d *DirectEmbedding) Parse() (interface{}, error) {
 	var parsed interface{}
 	parsed = d.Value
 	// Parse as actual Go type so that it will be passed as Object into JS VM
-	if d.ValueType == valueTypeJSON || d.ValueType == valueTypeXML {
+	if d.ValueType.isJSONBased() {
 		err := json.Unmarshal(d.Value, &parsed)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse byte array as json even though type has been recognized as XML/JSON: %w", err)
@@ -63,3 +64,34 @@ func (d *DirectEmbedding) Parse() (interface{}, error) {
 
 	return parsed, nil
 }
+
+// ParseDocument parses JSON based values (JSON, XML, Protobuf and Avro) into a document whose numbers are kept as
+// json.Number, so that large integers don't lose precision. Text values are returned as string. The second return
+// value is false if the value is empty, binary or can't be parsed.
+func (d *DirectEmbedding) ParseDocument() (interface{}, bool) {
+	switch d.ValueType {
+	case valueTypeText:
+		return string(d.Value), true
+	case valueTypeJSON, valueTypeXML, valueTypeProtobuf, valueTypeAvro:
+		dec := json.NewDecoder(bytes.NewReader(d.Value))
+		dec.UseNumber()
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return nil, false
+		}
+		return doc, true
+	default:
+		return nil, false
+	}
+}
+
+// findBrokerByID returns the broker with the given id from the client's cluster metadata
+func (s *Service) findBrokerByID(brokerID int32) (*sarama.Broker, error) {
+	for _, b := range s.Client.Brokers() {
+		if b.ID() == brokerID {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("broker with id '%v' is not known by the client", brokerID)
+}
@@ -0,0 +1,255 @@
+package ksql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+)
+
+// contentType is the versioned JSON format of ksqlDB's REST API
+const contentType = "application/vnd.ksql.v1+json"
+
+// Client talks to the REST API of a single ksqlDB server. Statements go through the dependency, which applies the
+// request timeout, retries and the circuit breaker. Queries are streamed and are bounded by the caller's context.
+type Client struct {
+	cfg        ConfigServer
+	httpClient *http.Client
+	dependency *resilience.Dependency
+}
+
+// RestError is the error body returned by ksqlDB
+type RestError struct {
+	StatusCode int    `json:"-"`
+	Type       string `json:"@type"`
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *RestError) Error() string {
+	return fmt.Sprintf("ksqlDB responded with status code %v: %v", e.StatusCode, e.Message)
+}
+
+// Stream is a stream or table which has been registered in ksqlDB
+type Stream struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Topic       string `json:"topic"`
+	KeyFormat   string `json:"keyFormat"`
+	ValueFormat string `json:"valueFormat"`
+	IsWindowed  bool   `json:"isWindowed"`
+}
+
+// Query is a persistent or transient query which runs in ksqlDB
+type Query struct {
+	ID              string         `json:"id"`
+	QueryString     string         `json:"queryString"`
+	QueryType       string         `json:"queryType"`
+	State           string         `json:"state"`
+	Sinks           []string       `json:"sinks"`
+	SinkKafkaTopics []string       `json:"sinkKafkaTopics"`
+	StatusCount     map[string]int `json:"statusCount"`
+}
+
+// statementResponse is a single entity of the response to a statement, only the fields of the statements which are
+// sent by the client are decoded
+type statementResponse struct {
+	Type    string   `json:"@type"`
+	Streams []Stream `json:"streams"`
+	Tables  []Stream `json:"tables"`
+	Queries []Query  `json:"queries"`
+}
+
+// ListStreams returns all streams
+func (c *Client) ListStreams(ctx context.Context) ([]Stream, error) {
+	res, err := c.statement(ctx, "LIST STREAMS;", "streams")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list streams: %w", err)
+	}
+	return res.Streams, nil
+}
+
+// ListTables returns all tables
+func (c *Client) ListTables(ctx context.Context) ([]Stream, error) {
+	res, err := c.statement(ctx, "LIST TABLES;", "tables")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	return res.Tables, nil
+}
+
+// ListQueries returns all running queries
+func (c *Client) ListQueries(ctx context.Context) ([]Query, error) {
+	res, err := c.statement(ctx, "LIST QUERIES;", "queries")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queries: %w", err)
+	}
+	return res.Queries, nil
+}
+
+// statement runs a read-only statement and returns the entity of the expected type
+func (c *Client) statement(ctx context.Context, ksql string, expectedType string) (*statementResponse, error) {
+	var entities []statementResponse
+	body := map[string]interface{}{"ksql": ksql, "streamsProperties": map[string]string{}}
+	err := c.dependency.Do(ctx, func(ctx context.Context) error {
+		res, err := c.send(ctx, "/ksql", body)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		return json.NewDecoder(res.Body).Decode(&entities)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range entities {
+		if entity.Type == expectedType {
+			return &entity, nil
+		}
+	}
+	return nil, fmt.Errorf("response does not contain '%v'", expectedType)
+}
+
+// QueryRequest is a pull or push query along with its streams properties (e.g. 'ksql.streams.auto.offset.reset')
+type QueryRequest struct {
+	KSQL       string
+	Properties map[string]string
+}
+
+// QueryHeader is sent before the first row of a query
+type QueryHeader struct {
+	QueryID string `json:"queryId"`
+
+	// Schema is the schema of the rows, e.g. "`ID` STRING, `COUNT` BIGINT". Use ParseSchema to get its columns.
+	Schema string `json:"schema"`
+}
+
+// QueryRow is a single row of a query's result. The columns are in the order of the header's schema.
+type QueryRow struct {
+	Columns   []json.RawMessage `json:"columns"`
+	Tombstone bool              `json:"tombstone,omitempty"`
+}
+
+// QueryResult describes how a query has ended
+type QueryResult struct {
+	Rows int `json:"rows"`
+
+	// FinalMessage is sent by ksqlDB once a query has completed, e.g. 'Limit Reached' or 'Query Completed'
+	FinalMessage string `json:"finalMessage,omitempty"`
+
+	// IsStopped is true if the query has been stopped by the row handler
+	IsStopped bool `json:"isStopped"`
+}
+
+// queryResponse is a single element of the streamed query response
+type queryResponse struct {
+	Header       *QueryHeader `json:"header"`
+	Row          *QueryRow    `json:"row"`
+	FinalMessage string       `json:"finalMessage"`
+	ErrorMessage *RestError   `json:"errorMessage"`
+}
+
+// Query runs a pull or push query and calls onHeader and onRow as the results are streamed by ksqlDB. Push queries
+// run until the context is cancelled or onRow returns false. Queries are neither retried nor bounded by the request
+// timeout, hence they don't go through the dependency.
+func (c *Client) Query(ctx context.Context, req QueryRequest, onHeader func(QueryHeader), onRow func(QueryRow) bool) (*QueryResult, error) {
+	properties := req.Properties
+	if properties == nil {
+		properties = map[string]string{}
+	}
+	ksql := strings.TrimSpace(req.KSQL)
+	if !strings.HasSuffix(ksql, ";") {
+		ksql += ";"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	res, err := c.send(ctx, "/query", map[string]interface{}{"ksql": ksql, "streamsProperties": properties})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	result := &QueryResult{}
+	decoder := json.NewDecoder(res.Body)
+	if _, err := decoder.Token(); err != nil { // Opening bracket of the response array
+		return nil, fmt.Errorf("failed to decode query response: %w", err)
+	}
+	for decoder.More() {
+		var element queryResponse
+		if err := decoder.Decode(&element); err != nil {
+			if ctx.Err() != nil {
+				return result, nil
+			}
+			return result, fmt.Errorf("failed to decode query response: %w", err)
+		}
+
+		switch {
+		case element.ErrorMessage != nil:
+			element.ErrorMessage.StatusCode = res.StatusCode
+			return result, element.ErrorMessage
+		case element.Header != nil:
+			onHeader(*element.Header)
+		case element.Row != nil:
+			result.Rows++
+			if !onRow(*element.Row) {
+				result.IsStopped = true
+				return result, nil
+			}
+		case element.FinalMessage != "":
+			result.FinalMessage = element.FinalMessage
+		}
+	}
+
+	return result, nil
+}
+
+// IsPushQuery returns true if the query streams changes until it's stopped, rather than returning the current state
+func IsPushQuery(ksql string) bool {
+	return strings.Contains(strings.ToUpper(strings.Join(strings.Fields(ksql), " ")), "EMIT CHANGES")
+}
+
+// isFailure returns false for errors which have been returned by a reachable server, e.g. for invalid statements
+func isFailure(err error) bool {
+	var restErr *RestError
+	return !errors.As(err, &restErr) || restErr.StatusCode >= http.StatusInternalServerError
+}
+
+// send posts the JSON body to the path. The caller must close the response body, unless an error is returned.
+func (c *Client) send(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request body: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.cfg.URL, "/")+path, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", contentType)
+	req.Header.Set("Content-Type", contentType)
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		restErr := &RestError{StatusCode: res.StatusCode}
+		_ = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(restErr)
+		return nil, restErr
+	}
+
+	return res, nil
+}
@@ -0,0 +1,115 @@
+package ksql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.Servers = []ConfigServer{{Name: "default", URL: server.URL}}
+	require.NoError(t, cfg.Validate())
+	client, err := NewService(cfg, resilience.NewRegistry(nil), zap.NewNop()).Client("default")
+	require.NoError(t, err)
+	return client
+}
+
+func TestClientListStreams(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "/ksql", r.URL.Path)
+		assert.Equal(t, "LIST STREAMS;", body["ksql"])
+		w.Write([]byte(`[{"@type":"streams","statementText":"LIST STREAMS;","streams":[
+			{"type":"STREAM","name":"PAGEVIEWS","topic":"pageviews","keyFormat":"KAFKA","valueFormat":"JSON","isWindowed":false}
+		],"warnings":[]}]`))
+	})
+
+	streams, err := client.ListStreams(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Stream{{Type: "STREAM", Name: "PAGEVIEWS", Topic: "pageviews", KeyFormat: "KAFKA", ValueFormat: "JSON"}}, streams)
+}
+
+func TestClientQuery(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/query", r.URL.Path)
+		w.Write([]byte(`[{"header":{"queryId":"query_1","schema":"` + "`ID` STRING, `VIEWS` BIGINT" + `"}},
+{"row":{"columns":["a",1]}},
+{"row":{"columns":["b",2]}},
+{"row":{"columns":["c",3]}},
+{"finalMessage":"Limit Reached"}]`))
+	})
+
+	var header QueryHeader
+	rows := make([]QueryRow, 0)
+	onRow := func(row QueryRow) bool {
+		rows = append(rows, row)
+		return true
+	}
+	res, err := client.Query(context.Background(), QueryRequest{KSQL: "SELECT * FROM PAGEVIEWS LIMIT 3"}, func(h QueryHeader) { header = h }, onRow)
+	require.NoError(t, err)
+	assert.Equal(t, &QueryResult{Rows: 3, FinalMessage: "Limit Reached"}, res)
+	assert.Equal(t, "query_1", header.QueryID)
+	require.Len(t, rows, 3)
+	assert.Equal(t, `"b"`, string(rows[1].Columns[0]))
+
+	// Returning false from the row handler stops the query
+	rows = rows[:0]
+	res, err = client.Query(context.Background(), QueryRequest{KSQL: "SELECT * FROM PAGEVIEWS EMIT CHANGES;"}, func(QueryHeader) {}, func(row QueryRow) bool {
+		rows = append(rows, row)
+		return len(rows) < 2
+	})
+	require.NoError(t, err)
+	assert.True(t, res.IsStopped)
+	assert.Equal(t, 2, res.Rows)
+}
+
+func TestClientQueryError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"@type":"statement_error","error_code":40001,"message":"PAGEVIEWS does not exist."}`))
+	})
+
+	_, err := client.Query(context.Background(), QueryRequest{KSQL: "SELECT * FROM PAGEVIEWS;"}, func(QueryHeader) {}, func(QueryRow) bool { return true })
+	var restErr *RestError
+	require.True(t, errors.As(err, &restErr))
+	assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
+	assert.Equal(t, "PAGEVIEWS does not exist.", restErr.Message)
+}
+
+func TestParseSchema(t *testing.T) {
+	tt := []struct {
+		schema   string
+		expected []Column
+	}{
+		{"`ID` STRING KEY, `VIEWS` BIGINT", []Column{{"ID", "STRING KEY"}, {"VIEWS", "BIGINT"}}},
+		{"`ADDRESS` STRUCT<`CITY` STRING, `ZIP` INTEGER>, `TAGS` MAP<STRING, ARRAY<STRING>>", []Column{
+			{"ADDRESS", "STRUCT<`CITY` STRING, `ZIP` INTEGER>"},
+			{"TAGS", "MAP<STRING, ARRAY<STRING>>"},
+		}},
+		{"`PRICE` DECIMAL(10, 2), `A,B` STRING", []Column{{"PRICE", "DECIMAL(10, 2)"}, {"A,B", "STRING"}}},
+		{"", []Column{}},
+	}
+	for i, test := range tt {
+		assert.Equal(t, test.expected, ParseSchema(test.schema), "Case: ", i)
+	}
+}
+
+func TestIsPushQuery(t *testing.T) {
+	assert.True(t, IsPushQuery("SELECT * FROM PAGEVIEWS\n  emit   changes;"))
+	assert.False(t, IsPushQuery("SELECT * FROM VIEWS_BY_USER WHERE ID = 'a';"))
+}
@@ -0,0 +1,86 @@
+package ksql
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+)
+
+// Config for connecting to one or more ksqlDB servers
+type Config struct {
+	Enabled bool           `yaml:"enabled"`
+	Servers []ConfigServer `yaml:"servers"`
+
+	// RequestTimeout bounds each statement (e.g. listing streams). Retries and circuit breaking apply to each server
+	// separately. Queries are bounded by the query limits instead.
+	RequestTimeout time.Duration     `yaml:"requestTimeout"`
+	Resilience     resilience.Config `yaml:"resilience"`
+
+	// MaxQueryDuration stops push queries, which never complete on their own, and slow pull queries
+	MaxQueryDuration time.Duration `yaml:"maxQueryDuration"`
+
+	// MaxRows stops a query once this many rows have been streamed to the requester
+	MaxRows int `yaml:"maxRows"`
+}
+
+// ConfigServer is a single ksqlDB server (or cluster behind a load balancer) which is reachable via its REST API
+type ConfigServer struct {
+	// Name is used to refer to the server in the API and must be unique
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+
+	// Basic auth credentials, leave empty if the server does not require authentication
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// SetDefaults for the ksql config
+func (c *Config) SetDefaults() {
+	c.RequestTimeout = 10 * time.Second
+	c.Resilience.SetDefaults()
+	c.MaxQueryDuration = 5 * time.Minute
+	c.MaxRows = 10000
+}
+
+// Validate the ksql config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Servers) == 0 {
+		return fmt.Errorf("at least one ksqlDB server must be configured if ksqlDB is enabled")
+	}
+
+	names := make(map[string]struct{}, len(c.Servers))
+	for i, server := range c.Servers {
+		if server.Name == "" {
+			return fmt.Errorf("name of ksqlDB server at index '%v' must be set", i)
+		}
+		if _, exists := names[server.Name]; exists {
+			return fmt.Errorf("ksqlDB server name '%v' is used more than once", server.Name)
+		}
+		names[server.Name] = struct{}{}
+
+		if _, err := url.ParseRequestURI(server.URL); err != nil {
+			return fmt.Errorf("failed to parse url of ksqlDB server '%v': %w", server.Name, err)
+		}
+	}
+
+	if c.RequestTimeout <= 0 {
+		return fmt.Errorf("ksqlDB request timeout must be greater than 0")
+	}
+	if c.MaxQueryDuration <= 0 {
+		return fmt.Errorf("ksqlDB max query duration must be greater than 0")
+	}
+	if c.MaxRows <= 0 {
+		return fmt.Errorf("ksqlDB max rows must be greater than 0")
+	}
+	if err := c.Resilience.Validate(); err != nil {
+		return fmt.Errorf("failed to validate resilience config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package ksql
+
+import (
+	"strings"
+)
+
+// Column is a single column of a query's schema
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ParseSchema splits the schema of a query header, e.g. "`ID` STRING KEY, `ADDRESS` STRUCT<`CITY` STRING>", into
+// its top level columns. Types keep nested fields and modifiers such as KEY.
+func ParseSchema(schema string) []Column {
+	columns := make([]Column, 0)
+	depth := 0
+	isQuoted := false
+	start := 0
+	for i, c := range schema {
+		switch {
+		case c == '`':
+			isQuoted = !isQuoted
+		case isQuoted:
+		case c == '<' || c == '(':
+			depth++
+		case c == '>' || c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			columns = appendColumn(columns, schema[start:i])
+			start = i + 1
+		}
+	}
+	return appendColumn(columns, schema[start:])
+}
+
+func appendColumn(columns []Column, definition string) []Column {
+	definition = strings.TrimSpace(definition)
+	if definition == "" {
+		return columns
+	}
+
+	var name, columnType string
+	if strings.HasPrefix(definition, "`") {
+		end := strings.Index(definition[1:], "`")
+		if end < 0 {
+			return append(columns, Column{Name: definition[1:]})
+		}
+		name = definition[1 : end+1]
+		columnType = definition[end+2:]
+	} else {
+		parts := strings.SplitN(definition, " ", 2)
+		name = parts[0]
+		if len(parts) > 1 {
+			columnType = parts[1]
+		}
+	}
+
+	return append(columns, Column{Name: name, Type: strings.TrimSpace(columnType)})
+}
@@ -0,0 +1,57 @@
+package ksql
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"go.uber.org/zap"
+)
+
+// ErrServerNotFound is returned if no ksqlDB server with the requested name has been configured
+var ErrServerNotFound = errors.New("ksqlDB server not found")
+
+// Service provides clients for all configured ksqlDB servers
+type Service struct {
+	cfg     Config
+	logger  *zap.Logger
+	clients map[string]*Client
+}
+
+// NewService creates a client for each configured ksqlDB server. Each server is registered as dependency named
+// ksql_<serverName>.
+func NewService(cfg Config, dependencies *resilience.Registry, logger *zap.Logger) *Service {
+	clients := make(map[string]*Client, len(cfg.Servers))
+	for _, server := range cfg.Servers {
+		clients[server.Name] = &Client{
+			cfg:        server,
+			httpClient: &http.Client{},
+			dependency: dependencies.NewDependency("ksql_"+server.Name, cfg.RequestTimeout, cfg.Resilience, isFailure),
+		}
+	}
+
+	return &Service{
+		cfg:     cfg,
+		logger:  logger,
+		clients: clients,
+	}
+}
+
+// ServerNames returns the names of all configured servers in the configured order
+func (s *Service) ServerNames() []string {
+	names := make([]string, len(s.cfg.Servers))
+	for i, server := range s.cfg.Servers {
+		names[i] = server.Name
+	}
+	return names
+}
+
+// Client returns the client for the server with the given name
+func (s *Service) Client(serverName string) (*Client, error) {
+	client, exists := s.clients[serverName]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%v'", ErrServerNotFound, serverName)
+	}
+	return client, nil
+}
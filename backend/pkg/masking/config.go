@@ -0,0 +1,92 @@
+package masking
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/cloudhut/kowl/backend/pkg/jsonpath"
+	"github.com/cloudhut/kowl/backend/pkg/topicpattern"
+)
+
+// Config for masking sensitive fields of consumed messages before they leave the backend
+type Config struct {
+	// Placeholder replaces all masked values
+	Placeholder string `yaml:"placeholder"`
+
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// Profile is a named set of masking rules which is applied to the messages that the assigned roles consume
+type Profile struct {
+	Name string `yaml:"name"`
+
+	// Roles the profile is applied to. If empty the profile is applied to everyone.
+	Roles []string `yaml:"roles"`
+
+	// ExemptRoles see unmasked messages, even if they have been assigned one of the roles above
+	ExemptRoles []string `yaml:"exemptRoles"`
+
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule masks fields of the keys, values and headers of all topics which match the topic pattern
+type Rule struct {
+	// TopicPattern is a regex which must match the whole topic name. An empty pattern matches all topics.
+	TopicPattern string `yaml:"topicPattern"`
+
+	// JSONPaths select the fields which shall be masked, e.g. "$.customer.email" or "$.items[*].cardNumber". The
+	// root path "$" masks the whole payload regardless of its type.
+	JSONPaths []string `yaml:"jsonPaths"`
+
+	// FieldNames are glob patterns (e.g. "*password*") which are matched case-insensitively against the member
+	// names at any depth and against header keys
+	FieldNames []string `yaml:"fieldNames"`
+}
+
+// SetDefaults for the masking config
+func (c *Config) SetDefaults() {
+	c.Placeholder = "***"
+}
+
+// Validate the masking config
+func (c *Config) Validate() error {
+	names := make(map[string]struct{}, len(c.Profiles))
+	for i, p := range c.Profiles {
+		if p.Name == "" {
+			return fmt.Errorf("name of masking profile at index '%v' must be set", i)
+		}
+		if _, exists := names[p.Name]; exists {
+			return fmt.Errorf("masking profile name '%v' is used more than once", p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		for j, rule := range p.Rules {
+			if err := rule.validate(); err != nil {
+				return fmt.Errorf("rule at index '%v' of masking profile '%v' is invalid: %w", j, p.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) validate() error {
+	if len(r.JSONPaths) == 0 && len(r.FieldNames) == 0 {
+		return fmt.Errorf("at least one json path or field name must be set")
+	}
+	if _, err := topicpattern.Compile(r.TopicPattern); err != nil {
+		return fmt.Errorf("invalid topic pattern: %w", err)
+	}
+	for _, expr := range r.JSONPaths {
+		if _, err := jsonpath.Compile(expr); err != nil {
+			return fmt.Errorf("invalid json path '%v': %w", expr, err)
+		}
+	}
+	for _, pattern := range r.FieldNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid field name pattern '%v': %w", pattern, err)
+		}
+	}
+
+	return nil
+}
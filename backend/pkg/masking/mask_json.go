@@ -0,0 +1,34 @@
+package masking
+
+import (
+	"encoding/json"
+
+	"github.com/cloudhut/kowl/backend/pkg/jsonpath"
+)
+
+// MaskJSON replaces all selected values of the given JSON document with the placeholder. The document is streamed,
+// so that the order of members is kept, and written in compact form. An error is returned if the document can't be
+// parsed, callers must not return the unmasked document in that case.
+func (m *Masker) MaskJSON(doc []byte) ([]byte, error) {
+	if m.masksWholePayload {
+		return json.Marshal(m.placeholder)
+	}
+
+	return jsonpath.Rewrite(doc, jsonRewriter{m})
+}
+
+// jsonRewriter replaces masked values including all of their nested values with the placeholder
+type jsonRewriter struct {
+	masker *Masker
+}
+
+func (r jsonRewriter) ReplaceValue(location jsonpath.Location) (string, bool) {
+	if len(location) > 0 && r.masker.matchesLocation(location) {
+		return r.masker.placeholder, true
+	}
+	return "", false
+}
+
+func (r jsonRewriter) ReplaceScalar(_ jsonpath.Location, _ interface{}) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,177 @@
+package masking
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/cloudhut/kowl/backend/pkg/jsonpath"
+	"github.com/cloudhut/kowl/backend/pkg/topicpattern"
+)
+
+// RolesAll is the wildcard role which is assigned to all roles. It applies all profiles but doesn't exempt from any,
+// so that masking is in effect unless the requester's roles are actually known.
+const RolesAll = "all"
+
+// Service compiles the configured masking profiles and creates maskers for consume requests
+type Service struct {
+	placeholder string
+	profiles    []*profile
+	byName      map[string]*profile
+}
+
+type profile struct {
+	Profile
+	rules []*rule
+}
+
+type rule struct {
+	topicRegex *regexp.Regexp
+	paths      []*jsonpath.Path
+	fieldNames []string // Lower case
+}
+
+// NewService compiles all profiles. The config is expected to be validated.
+func NewService(cfg Config) (*Service, error) {
+	svc := &Service{
+		placeholder: cfg.Placeholder,
+		profiles:    make([]*profile, len(cfg.Profiles)),
+		byName:      make(map[string]*profile, len(cfg.Profiles)),
+	}
+	for i, p := range cfg.Profiles {
+		compiled := &profile{Profile: p, rules: make([]*rule, len(p.Rules))}
+		for j, r := range p.Rules {
+			topicRegex, err := topicpattern.Compile(r.TopicPattern)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile topic pattern of masking profile '%v': %w", p.Name, err)
+			}
+			paths := make([]*jsonpath.Path, len(r.JSONPaths))
+			for k, expr := range r.JSONPaths {
+				paths[k], err = jsonpath.Compile(expr)
+				if err != nil {
+					return nil, fmt.Errorf("failed to compile json path of masking profile '%v': %w", p.Name, err)
+				}
+			}
+			fieldNames := make([]string, len(r.FieldNames))
+			for k, pattern := range r.FieldNames {
+				fieldNames[k] = strings.ToLower(pattern)
+			}
+			compiled.rules[j] = &rule{topicRegex: topicRegex, paths: paths, fieldNames: fieldNames}
+		}
+		svc.profiles[i] = compiled
+		svc.byName[p.Name] = compiled
+	}
+
+	return svc, nil
+}
+
+// HasProfile returns true if a profile with the given name has been configured
+func (s *Service) HasProfile(name string) bool {
+	_, exists := s.byName[name]
+	return exists
+}
+
+// Masker returns the masker for messages of the given topic which are consumed by a requester with the given roles.
+// The additional profiles (e.g. of a consume template) are applied regardless of the roles. Nil is returned if
+// no rule applies.
+func (s *Service) Masker(topicName string, roles []string, additionalProfiles ...string) *Masker {
+	m := &Masker{placeholder: s.placeholder}
+	for _, p := range s.profiles {
+		if p.appliesTo(roles) || contains(additionalProfiles, p.Name) {
+			m.addRules(p.rules, topicName)
+		}
+	}
+
+	if !m.masksWholePayload && len(m.paths) == 0 && len(m.fieldNames) == 0 {
+		return nil
+	}
+	return m
+}
+
+// appliesTo returns true if the profile is assigned to at least one of the roles and none of them is exempt
+func (p *profile) appliesTo(roles []string) bool {
+	isAssigned := len(p.Roles) == 0
+	for _, role := range roles {
+		if role != RolesAll && contains(p.ExemptRoles, role) {
+			return false
+		}
+		if role == RolesAll || contains(p.Roles, role) {
+			isAssigned = true
+		}
+	}
+
+	return isAssigned
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Masker replaces the values of all fields which are selected by its rules with a placeholder
+type Masker struct {
+	placeholder       string
+	paths             []*jsonpath.Path
+	fieldNames        []string // Lower case glob patterns
+	masksWholePayload bool
+}
+
+func (m *Masker) addRules(rules []*rule, topicName string) {
+	for _, r := range rules {
+		if !r.topicRegex.MatchString(topicName) {
+			continue
+		}
+		for _, p := range r.paths {
+			if p.IsRoot() {
+				m.masksWholePayload = true
+				continue
+			}
+			m.paths = append(m.paths, p)
+		}
+		m.fieldNames = append(m.fieldNames, r.fieldNames...)
+	}
+}
+
+// Placeholder returns the value which replaces masked values
+func (m *Masker) Placeholder() string {
+	return m.placeholder
+}
+
+// MasksWholePayload returns true if all keys, values and headers must be replaced by the placeholder
+func (m *Masker) MasksWholePayload() bool {
+	return m.masksWholePayload
+}
+
+// MasksHeader returns true if the value of the header with the given key must be replaced by the placeholder
+func (m *Masker) MasksHeader(key string) bool {
+	return m.masksWholePayload || m.matchesFieldName(key)
+}
+
+func (m *Masker) matchesFieldName(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range m.fieldNames {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Masker) matchesLocation(location jsonpath.Location) bool {
+	if len(location) > 0 {
+		if name, ok := location[len(location)-1].(string); ok && m.matchesFieldName(name) {
+			return true
+		}
+	}
+	for _, p := range m.paths {
+		if p.MatchesLocation(location) {
+			return true
+		}
+	}
+	return false
+}
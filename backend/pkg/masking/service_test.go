@@ -0,0 +1,84 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T) *Service {
+	cfg := Config{Profiles: []Profile{
+		{
+			Name:        "pii",
+			ExemptRoles: []string{"privacy-officer"},
+			Rules: []Rule{
+				{TopicPattern: "customers|orders-.*", JSONPaths: []string{"$.customer.email", "$.items[*].card"}},
+				{FieldNames: []string{"*password*"}},
+			},
+		},
+		{
+			Name:  "support",
+			Roles: []string{"support"},
+			Rules: []Rule{{TopicPattern: "audit", JSONPaths: []string{"$"}}},
+		},
+	}}
+	cfg.SetDefaults()
+	require.NoError(t, cfg.Validate())
+
+	svc, err := NewService(cfg)
+	require.NoError(t, err)
+	return svc
+}
+
+func TestService_Masker(t *testing.T) {
+	svc := newTestService(t)
+
+	assert.Nil(t, svc.Masker("payments", []string{"privacy-officer"}), "exempt role sees everything")
+	assert.Nil(t, svc.Masker("audit", []string{"dev", "privacy-officer"}), "support profile is not assigned")
+	assert.NotNil(t, svc.Masker("audit", []string{"dev", "privacy-officer"}, "support"), "template profile applies regardless of roles")
+	assert.NotNil(t, svc.Masker("payments", []string{RolesAll}), "wildcard role must not exempt")
+
+	m := svc.Masker("audit", []string{"support"})
+	require.NotNil(t, m)
+	assert.True(t, m.MasksWholePayload())
+	assert.True(t, m.MasksHeader("trace-id"))
+
+	m = svc.Masker("payments", nil)
+	require.NotNil(t, m)
+	assert.False(t, m.MasksWholePayload())
+	assert.True(t, m.MasksHeader("X-Password-Hash"))
+	assert.False(t, m.MasksHeader("trace-id"))
+}
+
+func TestMasker_MaskJSON(t *testing.T) {
+	svc := newTestService(t)
+	m := svc.Masker("orders-eu", []string{"dev"})
+	require.NotNil(t, m)
+
+	masked, err := m.MaskJSON([]byte(`{
+		"id": 1, "customer": {"name": "<Jane>", "email": "jane@example.com"},
+		"items": [{"card": {"number": "4111"}, "qty": 2.50}, {"card": null}],
+		"auth": {"Password": "secret", "user": "jane"}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1,"customer":{"name":"<Jane>","email":"***"},`+
+		`"items":[{"card":"***","qty":2.50},{"card":"***"}],"auth":{"Password":"***","user":"jane"}}`, string(masked))
+
+	_, err = m.MaskJSON([]byte(`{"customer": {"email": "jane@example.com"`))
+	assert.Error(t, err)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	invalid := []Config{
+		{Profiles: []Profile{{Name: ""}}},
+		{Profiles: []Profile{{Name: "a"}, {Name: "a"}}},
+		{Profiles: []Profile{{Name: "a", Rules: []Rule{{TopicPattern: "orders"}}}}},
+		{Profiles: []Profile{{Name: "a", Rules: []Rule{{TopicPattern: "(", FieldNames: []string{"x"}}}}}},
+		{Profiles: []Profile{{Name: "a", Rules: []Rule{{JSONPaths: []string{"$..x"}}}}}},
+		{Profiles: []Profile{{Name: "a", Rules: []Rule{{FieldNames: []string{"[x"}}}}}},
+	}
+	for i, cfg := range invalid {
+		assert.Error(t, cfg.Validate(), "case %d", i)
+	}
+}
@@ -0,0 +1,333 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/principals"
+)
+
+var (
+	// ErrInvalidACL is returned if an ACL or ACL filter contains unknown enum values or has been rejected by Kafka
+	ErrInvalidACL = errors.New("invalid acl")
+	// ErrAuthorizerNotConfigured is returned if the brokers have no authorizer configured and hence ACLs can't be used
+	ErrAuthorizerNotConfigured = errors.New("no authorizer is configured on the brokers")
+)
+
+// aclEnum maps the names we use in the API to the numeric values of one of sarama's ACL enums
+type aclEnum struct {
+	Kind   string
+	Values map[string]int
+}
+
+var aclResourceTypes = aclEnum{Kind: "resource type", Values: map[string]int{
+	"any":             int(sarama.AclResourceAny),
+	"topic":           int(sarama.AclResourceTopic),
+	"group":           int(sarama.AclResourceGroup),
+	"cluster":         int(sarama.AclResourceCluster),
+	"transactionalId": int(sarama.AclResourceTransactionalID),
+}}
+
+var aclPatternTypes = aclEnum{Kind: "pattern type", Values: map[string]int{
+	"any":      int(sarama.AclPatternAny),
+	"match":    int(sarama.AclPatternMatch),
+	"literal":  int(sarama.AclPatternLiteral),
+	"prefixed": int(sarama.AclPatternPrefixed),
+}}
+
+var aclOperations = aclEnum{Kind: "operation", Values: map[string]int{
+	"any":             int(sarama.AclOperationAny),
+	"all":             int(sarama.AclOperationAll),
+	"read":            int(sarama.AclOperationRead),
+	"write":           int(sarama.AclOperationWrite),
+	"create":          int(sarama.AclOperationCreate),
+	"delete":          int(sarama.AclOperationDelete),
+	"alter":           int(sarama.AclOperationAlter),
+	"describe":        int(sarama.AclOperationDescribe),
+	"clusterAction":   int(sarama.AclOperationClusterAction),
+	"describeConfigs": int(sarama.AclOperationDescribeConfigs),
+	"alterConfigs":    int(sarama.AclOperationAlterConfigs),
+	"idempotentWrite": int(sarama.AclOperationIdempotentWrite),
+}}
+
+var aclPermissionTypes = aclEnum{Kind: "permission type", Values: map[string]int{
+	"any":   int(sarama.AclPermissionAny),
+	"allow": int(sarama.AclPermissionAllow),
+	"deny":  int(sarama.AclPermissionDeny),
+}}
+
+// Parse returns the numeric value of the given name
+func (e aclEnum) Parse(name string) (int, error) {
+	value, ok := e.Values[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown %v '%v'", ErrInvalidACL, e.Kind, name)
+	}
+	return value, nil
+}
+
+// Name returns the name of the given numeric value or "unknown" if it's not known to us
+func (e aclEnum) Name(value int) string {
+	for name, v := range e.Values {
+		if v == value {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// ACLResource is a resource along with all ACLs bound to it
+type ACLResource struct {
+	ResourceType string     `json:"resourceType"`
+	ResourceName string     `json:"resourceName"`
+	PatternType  string     `json:"patternType"`
+	ACLs         []ACLEntry `json:"acls"`
+}
+
+// ACLEntry grants or denies an operation on a resource to a principal
+type ACLEntry struct {
+	Principal      string `json:"principal"`
+	Host           string `json:"host"`
+	Operation      string `json:"operation"`
+	PermissionType string `json:"permissionType"`
+
+	// Identity is the friendly name of the principal, nil if it's not known
+	Identity *principals.Identity `json:"identity,omitempty"`
+}
+
+// ACLBinding is a single ACL including its resource. It's used to create ACLs and to describe deleted ACLs.
+type ACLBinding struct {
+	ResourceType   string `json:"resourceType"`
+	ResourceName   string `json:"resourceName"`
+	PatternType    string `json:"patternType"`
+	Principal      string `json:"principal"`
+	Host           string `json:"host"`
+	Operation      string `json:"operation"`
+	PermissionType string `json:"permissionType"`
+}
+
+// ACLFilter selects ACLs. Empty strings match any value.
+type ACLFilter struct {
+	ResourceType   string `json:"resourceType"`
+	ResourceName   string `json:"resourceName"`
+	PatternType    string `json:"patternType"`
+	Principal      string `json:"principal"`
+	Host           string `json:"host"`
+	Operation      string `json:"operation"`
+	PermissionType string `json:"permissionType"`
+}
+
+// DeleteACLsResponse lists the ACLs which have been deleted or would be deleted in dry-run mode
+type DeleteACLsResponse struct {
+	DryRun      bool         `json:"dryRun"`
+	DeletedACLs []ACLBinding `json:"deletedAcls"`
+}
+
+// ListACLs returns all ACLs matching the filter grouped by resource
+func (s *Service) ListACLs(ctx context.Context, filter ACLFilter) ([]ACLResource, error) {
+	saramaFilter, err := filter.toSarama()
+	if err != nil {
+		return nil, err
+	}
+	resourceACLs, err := s.kafkaSvc.ListACLs(saramaFilter)
+	if err != nil {
+		return nil, aclAdminError(err)
+	}
+
+	resources := make([]ACLResource, len(resourceACLs))
+	for i, r := range resourceACLs {
+		entries := make([]ACLEntry, len(r.Acls))
+		for j, acl := range r.Acls {
+			entries[j] = ACLEntry{
+				Principal:      acl.Principal,
+				Host:           acl.Host,
+				Operation:      aclOperations.Name(int(acl.Operation)),
+				PermissionType: aclPermissionTypes.Name(int(acl.PermissionType)),
+			}
+		}
+		resources[i] = ACLResource{
+			ResourceType: aclResourceTypes.Name(int(r.ResourceType)),
+			ResourceName: r.ResourceName,
+			PatternType:  aclPatternTypes.Name(int(r.ResourcePatternType)),
+			ACLs:         entries,
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].ResourceType != resources[j].ResourceType {
+			return resources[i].ResourceType < resources[j].ResourceType
+		}
+		return resources[i].ResourceName < resources[j].ResourceName
+	})
+
+	return resources, nil
+}
+
+// CreateACLs creates all given ACL bindings
+func (s *Service) CreateACLs(ctx context.Context, bindings []ACLBinding) error {
+	creations := make([]*sarama.AclCreation, len(bindings))
+	for i, b := range bindings {
+		creation, err := b.toSarama()
+		if err != nil {
+			return fmt.Errorf("acl at index '%v': %w", i, err)
+		}
+		creations[i] = creation
+	}
+
+	err := s.kafkaSvc.CreateACLs(creations)
+	if err != nil {
+		return aclAdminError(err)
+	}
+
+	return nil
+}
+
+// DeleteACLs deletes all ACLs matching the filter. In dry-run mode the matching ACLs are only listed.
+func (s *Service) DeleteACLs(ctx context.Context, filter ACLFilter, dryRun bool) (*DeleteACLsResponse, error) {
+	if dryRun {
+		resources, err := s.ListACLs(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		bindings := make([]ACLBinding, 0)
+		for _, r := range resources {
+			for _, acl := range r.ACLs {
+				bindings = append(bindings, ACLBinding{
+					ResourceType:   r.ResourceType,
+					ResourceName:   r.ResourceName,
+					PatternType:    r.PatternType,
+					Principal:      acl.Principal,
+					Host:           acl.Host,
+					Operation:      acl.Operation,
+					PermissionType: acl.PermissionType,
+				})
+			}
+		}
+		return &DeleteACLsResponse{DryRun: true, DeletedACLs: bindings}, nil
+	}
+
+	saramaFilter, err := filter.toSarama()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.kafkaSvc.DeleteACLs(saramaFilter)
+	if err != nil {
+		return nil, aclAdminError(err)
+	}
+
+	bindings := make([]ACLBinding, len(matches))
+	for i, m := range matches {
+		bindings[i] = ACLBinding{
+			ResourceType:   aclResourceTypes.Name(int(m.ResourceType)),
+			ResourceName:   m.ResourceName,
+			PatternType:    aclPatternTypes.Name(int(m.ResourcePatternType)),
+			Principal:      m.Principal,
+			Host:           m.Host,
+			Operation:      aclOperations.Name(int(m.Operation)),
+			PermissionType: aclPermissionTypes.Name(int(m.PermissionType)),
+		}
+	}
+
+	return &DeleteACLsResponse{DryRun: false, DeletedACLs: bindings}, nil
+}
+
+func (b ACLBinding) toSarama() (*sarama.AclCreation, error) {
+	resourceType, err := aclResourceTypes.Parse(b.ResourceType)
+	if err != nil {
+		return nil, err
+	}
+	patternType, err := aclPatternTypes.Parse(b.PatternType)
+	if err != nil {
+		return nil, err
+	}
+	operation, err := aclOperations.Parse(b.Operation)
+	if err != nil {
+		return nil, err
+	}
+	permissionType, err := aclPermissionTypes.Parse(b.PermissionType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wildcards are only allowed for filters, an ACL must be specific
+	if b.ResourceType == "any" || b.PatternType == "any" || b.PatternType == "match" || b.Operation == "any" || b.PermissionType == "any" {
+		return nil, fmt.Errorf("%w: 'any' and 'match' can only be used to filter acls", ErrInvalidACL)
+	}
+	if b.ResourceName == "" || b.Principal == "" || b.Host == "" {
+		return nil, fmt.Errorf("%w: resource name, principal and host must be set", ErrInvalidACL)
+	}
+
+	return &sarama.AclCreation{
+		Resource: sarama.Resource{
+			ResourceType:        sarama.AclResourceType(resourceType),
+			ResourceName:        b.ResourceName,
+			ResourcePatternType: sarama.AclResourcePatternType(patternType),
+		},
+		Acl: sarama.Acl{
+			Principal:      b.Principal,
+			Host:           b.Host,
+			Operation:      sarama.AclOperation(operation),
+			PermissionType: sarama.AclPermissionType(permissionType),
+		},
+	}, nil
+}
+
+func (f ACLFilter) toSarama() (sarama.AclFilter, error) {
+	defaultAny := func(v string) string {
+		if v == "" {
+			return "any"
+		}
+		return v
+	}
+	optional := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+
+	resourceType, err := aclResourceTypes.Parse(defaultAny(f.ResourceType))
+	if err != nil {
+		return sarama.AclFilter{}, err
+	}
+	patternType, err := aclPatternTypes.Parse(defaultAny(f.PatternType))
+	if err != nil {
+		return sarama.AclFilter{}, err
+	}
+	operation, err := aclOperations.Parse(defaultAny(f.Operation))
+	if err != nil {
+		return sarama.AclFilter{}, err
+	}
+	permissionType, err := aclPermissionTypes.Parse(defaultAny(f.PermissionType))
+	if err != nil {
+		return sarama.AclFilter{}, err
+	}
+
+	return sarama.AclFilter{
+		ResourceType:              sarama.AclResourceType(resourceType),
+		ResourceName:              optional(f.ResourceName),
+		ResourcePatternTypeFilter: sarama.AclResourcePatternType(patternType),
+		Principal:                 optional(f.Principal),
+		Host:                      optional(f.Host),
+		Operation:                 sarama.AclOperation(operation),
+		PermissionType:            sarama.AclPermissionType(permissionType),
+	}, nil
+}
+
+// aclAdminError wraps kafka errors into our sentinel errors, so that the API can respond with a proper status
+func aclAdminError(err error) error {
+	var kErr sarama.KError
+	if !errors.As(err, &kErr) {
+		return err
+	}
+
+	switch kErr {
+	case sarama.ErrSecurityDisabled:
+		return fmt.Errorf("%w: %v", ErrAuthorizerNotConfigured, err)
+	case sarama.ErrInvalidRequest, sarama.ErrUnsupportedVersion, sarama.ErrPolicyViolation:
+		return fmt.Errorf("%w: %v", ErrInvalidACL, err)
+	}
+
+	return err
+}
@@ -0,0 +1,50 @@
+package owl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACLBindingToSarama(t *testing.T) {
+	binding := ACLBinding{
+		ResourceType:   "topic",
+		ResourceName:   "orders-",
+		PatternType:    "prefixed",
+		Principal:      "User:alice",
+		Host:           "*",
+		Operation:      "read",
+		PermissionType: "allow",
+	}
+	creation, err := binding.toSarama()
+	assert.NoError(t, err)
+	assert.Equal(t, &sarama.AclCreation{
+		Resource: sarama.Resource{ResourceType: sarama.AclResourceTopic, ResourceName: "orders-", ResourcePatternType: sarama.AclPatternPrefixed},
+		Acl:      sarama.Acl{Principal: "User:alice", Host: "*", Operation: sarama.AclOperationRead, PermissionType: sarama.AclPermissionAllow},
+	}, creation)
+
+	// Wildcards are only valid in filters
+	binding.Operation = "any"
+	_, err = binding.toSarama()
+	assert.True(t, errors.Is(err, ErrInvalidACL))
+
+	binding.Operation = "fly"
+	_, err = binding.toSarama()
+	assert.True(t, errors.Is(err, ErrInvalidACL))
+}
+
+func TestACLFilterToSarama(t *testing.T) {
+	filter, err := ACLFilter{Principal: "User:alice"}.toSarama()
+	assert.NoError(t, err)
+
+	principal := "User:alice"
+	assert.Equal(t, sarama.AclFilter{
+		ResourceType:              sarama.AclResourceAny,
+		ResourcePatternTypeFilter: sarama.AclPatternAny,
+		Principal:                 &principal,
+		Operation:                 sarama.AclOperationAny,
+		PermissionType:            sarama.AclPermissionAny,
+	}, filter)
+}
@@ -0,0 +1,133 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// ErrInvalidBrokerConfigRequest is returned if the config change has been rejected by our or the broker's validation
+var ErrInvalidBrokerConfigRequest = errors.New("invalid broker config request")
+
+// BrokerConfig is a broker id along with all its config entries
+type BrokerConfig struct {
+	BrokerID      int32                `json:"brokerId"`
+	ConfigEntries []*BrokerConfigEntry `json:"configEntries"`
+}
+
+// BrokerConfigEntry is a broker config along with the information where its value comes from. Only configs which
+// are not read-only can be altered at runtime. Values of sensitive configs are never returned by the brokers.
+type BrokerConfigEntry struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Source      string `json:"source"`
+	IsDefault   bool   `json:"isDefault"`
+	IsReadOnly  bool   `json:"isReadOnly"`
+	IsSensitive bool   `json:"isSensitive"`
+}
+
+// AlterBrokerConfigRequest sets or resets dynamic per-broker configs. A nil value resets the config to its static or
+// default value.
+type AlterBrokerConfigRequest struct {
+	BrokerID int32
+	Configs  map[string]*string
+	DryRun   bool
+}
+
+// AlterBrokerConfigResponse lists the config keys which have changed or would change in dry-run mode
+type AlterBrokerConfigResponse struct {
+	BrokerID int32          `json:"brokerId"`
+	DryRun   bool           `json:"dryRun"`
+	Changes  []ConfigChange `json:"changes"`
+}
+
+// GetBrokerConfig describes all configs of the given broker
+func (s *Service) GetBrokerConfig(ctx context.Context, brokerID int32) (*BrokerConfig, error) {
+	err := s.checkBrokerExists(brokerID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.kafkaSvc.DescribeBrokerConfigs(brokerID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make([]*BrokerConfigEntry, len(entries))
+	for i, entry := range entries {
+		converted[i] = &BrokerConfigEntry{
+			Name:        entry.Name,
+			Value:       entry.Value,
+			Source:      entry.Source.String(),
+			IsDefault:   entry.Default,
+			IsReadOnly:  entry.ReadOnly,
+			IsSensitive: entry.Sensitive,
+		}
+	}
+	sort.Slice(converted, func(i, j int) bool { return converted[i].Name < converted[j].Name })
+
+	return &BrokerConfig{
+		BrokerID:      brokerID,
+		ConfigEntries: converted,
+	}, nil
+}
+
+// AlterBrokerConfig applies the requested config changes on top of the broker's current dynamic configs and returns
+// the config keys which change. Like for topics Kafka replaces all dynamic configs of a broker at once. Sensitive
+// configs can't be carried along because brokers don't return their values, hence they must be part of the request.
+func (s *Service) AlterBrokerConfig(ctx context.Context, req AlterBrokerConfigRequest) (*AlterBrokerConfigResponse, error) {
+	err := s.checkBrokerExists(req.BrokerID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.kafkaSvc.DescribeBrokerConfigs(req.BrokerID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	currentConfigs := make(map[string]*string)
+	for _, entry := range entries {
+		if _, isRequested := req.Configs[entry.Name]; isRequested && entry.ReadOnly {
+			return nil, fmt.Errorf("%w: config '%v' is read-only and can't be altered at runtime", ErrInvalidBrokerConfigRequest, entry.Name)
+		}
+		if entry.Source != sarama.SourceDynamicBroker {
+			continue
+		}
+		if _, isRequested := req.Configs[entry.Name]; entry.Sensitive && !isRequested {
+			return nil, fmt.Errorf("%w: sensitive config '%v' is set on the broker and must be part of the request", ErrInvalidBrokerConfigRequest, entry.Name)
+		}
+		v := entry.Value
+		currentConfigs[entry.Name] = &v
+	}
+
+	desiredConfigs, changes := mergeConfigs(currentConfigs, req.Configs)
+	err = s.kafkaSvc.AlterBrokerConfig(req.BrokerID, desiredConfigs, req.DryRun)
+	if err != nil {
+		return nil, brokerConfigError(err)
+	}
+
+	return &AlterBrokerConfigResponse{
+		BrokerID: req.BrokerID,
+		DryRun:   req.DryRun,
+		Changes:  changes,
+	}, nil
+}
+
+// brokerConfigError wraps kafka validation errors into ErrInvalidBrokerConfigRequest
+func brokerConfigError(err error) error {
+	var kErr sarama.KError
+	if !errors.As(err, &kErr) {
+		return err
+	}
+
+	switch kErr {
+	case sarama.ErrInvalidConfig, sarama.ErrInvalidRequest, sarama.ErrPolicyViolation:
+		return fmt.Errorf("%w: %v", ErrInvalidBrokerConfigRequest, err)
+	}
+
+	return err
+}
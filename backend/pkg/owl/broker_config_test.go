@@ -0,0 +1,49 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAlterBrokerConfig(t *testing.T) {
+	str := func(s string) *string { return &s }
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	svc := NewService(kafka.NewFakeCluster(fakeCfg, zap.NewNop()), nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	ctx := context.Background()
+
+	res, err := svc.AlterBrokerConfig(ctx, AlterBrokerConfigRequest{BrokerID: 0, Configs: map[string]*string{"log.retention.ms": str("1000")}})
+	require.NoError(t, err)
+	assert.Equal(t, []ConfigChange{{Name: "log.retention.ms", OldValue: nil, NewValue: str("1000")}}, res.Changes)
+
+	// Previously set dynamic configs are carried along
+	_, err = svc.AlterBrokerConfig(ctx, AlterBrokerConfigRequest{BrokerID: 0, Configs: map[string]*string{"num.io.threads": str("16")}})
+	require.NoError(t, err)
+	cfg, err := svc.GetBrokerConfig(ctx, 0)
+	require.NoError(t, err)
+	for _, entry := range cfg.ConfigEntries {
+		switch entry.Name {
+		case "log.retention.ms":
+			assert.Equal(t, "1000", entry.Value)
+			assert.Equal(t, "DynamicBroker", entry.Source)
+		case "num.io.threads":
+			assert.Equal(t, "16", entry.Value)
+		case "zookeeper.connect":
+			assert.True(t, entry.IsReadOnly)
+		}
+	}
+
+	_, err = svc.AlterBrokerConfig(ctx, AlterBrokerConfigRequest{BrokerID: 0, Configs: map[string]*string{"zookeeper.connect": str("zk:2181")}})
+	assert.True(t, errors.Is(err, ErrInvalidBrokerConfigRequest))
+	_, err = svc.AlterBrokerConfig(ctx, AlterBrokerConfigRequest{BrokerID: 0, Configs: map[string]*string{"unknown.config": str("1")}})
+	assert.True(t, errors.Is(err, ErrInvalidBrokerConfigRequest))
+	_, err = svc.GetBrokerConfig(ctx, 42)
+	assert.True(t, errors.Is(err, ErrBrokerNotFound))
+}
@@ -0,0 +1,152 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// ErrBrokerNotFound is returned if the requested broker id is not part of the cluster
+var ErrBrokerNotFound = errors.New("broker not found")
+
+// BrokerRestartSafety describes whether a broker can be restarted without making partitions unavailable
+type BrokerRestartSafety struct {
+	BrokerID         int32                  `json:"brokerId"`
+	IsSafe           bool                   `json:"isSafe"`
+	AtRiskPartitions []PartitionRestartRisk `json:"atRiskPartitions"`
+}
+
+// PartitionRestartRisk is a partition which would be affected by a broker restart
+type PartitionRestartRisk struct {
+	TopicName         string  `json:"topicName"`
+	PartitionID       int32   `json:"partitionId"`
+	Replicas          []int32 `json:"replicas"`
+	InSyncReplicas    []int32 `json:"inSyncReplicas"`
+	MinInSyncReplicas int     `json:"minInSyncReplicas"`
+	Reason            string  `json:"reason"`
+}
+
+// checkBrokerExists returns ErrBrokerNotFound if the given broker id is not part of the cluster
+func (s *Service) checkBrokerExists(brokerID int32) error {
+	metadata, err := s.kafkaSvc.DescribeCluster()
+	if err != nil {
+		return fmt.Errorf("failed to describe cluster: %w", err)
+	}
+	for _, b := range metadata.Brokers {
+		if b.ID == brokerID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: broker id '%v' is not part of the cluster", ErrBrokerNotFound, brokerID)
+}
+
+// CheckBrokerRestartSafety checks whether any partition would lose its last in-sync replica or would go below
+// min.insync.replicas if the given broker is shut down.
+func (s *Service) CheckBrokerRestartSafety(ctx context.Context, brokerID int32) (*BrokerRestartSafety, error) {
+	err := s.checkBrokerExists(brokerID)
+	if err != nil {
+		return nil, err
+	}
+
+	topics, err := s.kafkaSvc.ListTopics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+
+	// Only topics with an in-sync replica on the given broker are relevant, hence we only describe their configs
+	affectedTopicNames := make([]string, 0)
+	for _, topic := range topics {
+		for _, partition := range topic.Partitions {
+			if containsBrokerID(partition.Isr, brokerID) {
+				affectedTopicNames = append(affectedTopicNames, topic.Name)
+				break
+			}
+		}
+	}
+
+	minISRByTopic := make(map[string]int, len(affectedTopicNames))
+	if len(affectedTopicNames) > 0 {
+		configs, err := s.GetTopicsConfigs(affectedTopicNames, []string{"min.insync.replicas"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe topic configs: %w", err)
+		}
+		for topicName, cfg := range configs {
+			entry := cfg.GetConfigEntryByName("min.insync.replicas")
+			if entry == nil {
+				continue
+			}
+			minISR, err := strconv.Atoi(entry.Value)
+			if err != nil {
+				s.logger.Warn("failed to parse min.insync.replicas", zap.String("topic_name", topicName), zap.String("value", entry.Value))
+				continue
+			}
+			minISRByTopic[topicName] = minISR
+		}
+	}
+
+	atRisk := make([]PartitionRestartRisk, 0)
+	for _, topic := range topics {
+		minISR, exists := minISRByTopic[topic.Name]
+		if !exists {
+			minISR = 1 // Kafka's default
+		}
+		for _, partition := range topic.Partitions {
+			reason := partitionRestartRisk(brokerID, partition.Isr, minISR)
+			if reason == "" {
+				continue
+			}
+			atRisk = append(atRisk, PartitionRestartRisk{
+				TopicName:         topic.Name,
+				PartitionID:       partition.ID,
+				Replicas:          partition.Replicas,
+				InSyncReplicas:    partition.Isr,
+				MinInSyncReplicas: minISR,
+				Reason:            reason,
+			})
+		}
+	}
+	sort.Slice(atRisk, func(i, j int) bool {
+		if atRisk[i].TopicName != atRisk[j].TopicName {
+			return atRisk[i].TopicName < atRisk[j].TopicName
+		}
+		return atRisk[i].PartitionID < atRisk[j].PartitionID
+	})
+
+	return &BrokerRestartSafety{
+		BrokerID:         brokerID,
+		IsSafe:           len(atRisk) == 0,
+		AtRiskPartitions: atRisk,
+	}, nil
+}
+
+// partitionRestartRisk returns the reason why the partition would be affected by shutting down the given broker.
+// An empty string is returned if the partition is not at risk.
+func partitionRestartRisk(brokerID int32, isr []int32, minISR int) string {
+	if !containsBrokerID(isr, brokerID) {
+		return ""
+	}
+
+	remaining := len(isr) - 1
+	if remaining == 0 {
+		return "broker is the last in-sync replica, the partition would go offline"
+	}
+	if remaining < minISR {
+		return fmt.Sprintf("in-sync replicas would drop to %v which is below min.insync.replicas (%v), producers using acks=all would be rejected", remaining, minISR)
+	}
+
+	return ""
+}
+
+func containsBrokerID(brokerIDs []int32, brokerID int32) bool {
+	for _, id := range brokerIDs {
+		if id == brokerID {
+			return true
+		}
+	}
+	return false
+}
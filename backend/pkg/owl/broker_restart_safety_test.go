@@ -0,0 +1,26 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionRestartRisk(t *testing.T) {
+	tt := []struct {
+		isr    []int32
+		minISR int
+		atRisk bool
+	}{
+		{[]int32{1, 2, 3}, 2, false},
+		{[]int32{2, 3}, 2, false}, // Broker is not in ISR
+		{[]int32{1, 2}, 2, true},  // Would drop below min.insync.replicas
+		{[]int32{1}, 1, true},     // Last in-sync replica
+		{[]int32{1, 2}, 1, false},
+	}
+
+	for i, table := range tt {
+		reason := partitionRestartRisk(1, table.isr, table.minISR)
+		assert.Equal(t, table.atRisk, reason != "", "expected other risk assessment. Case: ", i)
+	}
+}
@@ -0,0 +1,117 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// MetadataMode describes how the cluster manages its metadata
+type MetadataMode string
+
+const (
+	MetadataModeZooKeeper MetadataMode = "zookeeper"
+	MetadataModeKRaft     MetadataMode = "kraft"
+	MetadataModeMigration MetadataMode = "migration" // ZooKeeper to KRaft migration in progress
+	MetadataModeUnknown   MetadataMode = "unknown"   // Broker configs could not be described
+)
+
+// ClusterCapabilities lists which features are available for the cluster's metadata mode, so that the frontend
+// can explain why a feature is not available rather than showing generic errors.
+type ClusterCapabilities struct {
+	MetadataMode MetadataMode `json:"metadataMode"`
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// Capability is a feature which may not be available depending on the cluster setup
+type Capability struct {
+	Name        string `json:"name"`
+	IsSupported bool   `json:"isSupported"`
+	Reason      string `json:"reason,omitempty"` // Why the capability is not supported
+}
+
+// Capability names
+const (
+	CapabilityControllerView   = "controllerView"
+	CapabilityZooKeeperConfigs = "zookeeperConfigs"
+)
+
+// GetMetadataMode detects whether the cluster runs in KRaft or ZooKeeper mode by inspecting the broker configs
+// 'process.roles' and 'zookeeper.connect' of any broker.
+func (s *Service) GetMetadataMode(ctx context.Context) (MetadataMode, error) {
+	metadata, err := s.kafkaSvc.DescribeCluster()
+	if err != nil {
+		return MetadataModeUnknown, fmt.Errorf("failed to describe cluster: %w", err)
+	}
+	if len(metadata.Brokers) == 0 {
+		return MetadataModeUnknown, fmt.Errorf("cluster metadata does not contain any brokers")
+	}
+
+	brokerID := metadata.Brokers[0].ID
+	configNames := []string{"process.roles", "zookeeper.connect", "zookeeper.metadata.migration.enable"}
+	entries, err := s.kafkaSvc.DescribeBrokerConfigs(brokerID, configNames)
+	if err != nil {
+		s.logger.Warn("failed to describe broker configs to detect the metadata mode", zap.Int32("broker_id", brokerID), zap.Error(err))
+		return MetadataModeUnknown, nil
+	}
+
+	values := make(map[string]string, len(entries))
+	for _, e := range entries {
+		values[e.Name] = e.Value
+	}
+
+	return detectMetadataMode(values), nil
+}
+
+// detectMetadataMode derives the metadata mode from the given broker config values. Brokers older than KRaft don't
+// know 'process.roles' at all, so a missing value is treated like an empty one.
+func detectMetadataMode(configs map[string]string) MetadataMode {
+	isKRaft := strings.TrimSpace(configs["process.roles"]) != ""
+	hasZooKeeper := strings.TrimSpace(configs["zookeeper.connect"]) != ""
+	isMigrating := configs["zookeeper.metadata.migration.enable"] == "true"
+
+	switch {
+	case hasZooKeeper && isMigrating:
+		return MetadataModeMigration
+	case isKRaft:
+		return MetadataModeKRaft
+	case hasZooKeeper:
+		return MetadataModeZooKeeper
+	}
+	return MetadataModeUnknown
+}
+
+// GetClusterCapabilities detects the cluster's metadata mode and reports which features depend on it
+func (s *Service) GetClusterCapabilities(ctx context.Context) (*ClusterCapabilities, error) {
+	mode, err := s.GetMetadataMode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClusterCapabilities{
+		MetadataMode: mode,
+		Capabilities: capabilitiesForMode(mode),
+	}, nil
+}
+
+func capabilitiesForMode(mode MetadataMode) []Capability {
+	controllerView := Capability{Name: CapabilityControllerView, IsSupported: true}
+	zkConfigs := Capability{Name: CapabilityZooKeeperConfigs, IsSupported: true}
+
+	switch mode {
+	case MetadataModeKRaft:
+		controllerView.IsSupported = false
+		controllerView.Reason = "KRaft controllers are not exposed to clients, the reported controller is a random broker"
+		zkConfigs.IsSupported = false
+		zkConfigs.Reason = "cluster runs in KRaft mode without ZooKeeper"
+	case MetadataModeUnknown:
+		controllerView.IsSupported = false
+		controllerView.Reason = "metadata mode could not be detected, the reported controller may be a random broker"
+		zkConfigs.IsSupported = false
+		zkConfigs.Reason = "metadata mode could not be detected"
+	}
+
+	return []Capability{controllerView, zkConfigs}
+}
@@ -0,0 +1,23 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectMetadataMode(t *testing.T) {
+	tt := []struct {
+		configs  map[string]string
+		expected MetadataMode
+	}{
+		{map[string]string{"zookeeper.connect": "zk:2181"}, MetadataModeZooKeeper},
+		{map[string]string{"process.roles": "broker", "zookeeper.connect": ""}, MetadataModeKRaft},
+		{map[string]string{"process.roles": "", "zookeeper.connect": "zk:2181", "zookeeper.metadata.migration.enable": "true"}, MetadataModeMigration},
+		{map[string]string{}, MetadataModeUnknown},
+	}
+
+	for i, table := range tt {
+		assert.Equal(t, table.expected, detectMetadataMode(table.configs), "expected other metadata mode. Case: ", i)
+	}
+}
@@ -0,0 +1,127 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// ClusterStatus summarizes the health of a cluster
+type ClusterStatus string
+
+const (
+	// ClusterStatusHealthy means that all partitions are fully replicated and have a leader
+	ClusterStatusHealthy ClusterStatus = "healthy"
+
+	// ClusterStatusDegraded means that all partitions are available, but some replicas are out of sync or brokers
+	// which host replicas are not reachable
+	ClusterStatusDegraded ClusterStatus = "degraded"
+
+	// ClusterStatusUnhealthy means that partitions are offline or no controller is known
+	ClusterStatusUnhealthy ClusterStatus = "unhealthy"
+)
+
+// ClusterHealth is a summary of the cluster's replication state which is computed from its metadata. It's cheap
+// enough to be polled, e.g. for a dashboard header.
+type ClusterHealth struct {
+	Status ClusterStatus `json:"status"`
+
+	// Reasons explain why the cluster is not healthy, it's empty for healthy clusters
+	Reasons []string `json:"reasons"`
+
+	ControllerID int32 `json:"controllerId"` // -1 if no controller is known
+	BrokerCount  int   `json:"brokerCount"`
+
+	// OfflineBrokerIDs are brokers which are assigned as replicas, but are not part of the cluster metadata
+	OfflineBrokerIDs []int32 `json:"offlineBrokerIds"`
+
+	TopicCount                int `json:"topicCount"`
+	PartitionCount            int `json:"partitionCount"`
+	UnderReplicatedPartitions int `json:"underReplicatedPartitions"`
+	OfflinePartitions         int `json:"offlinePartitions"`
+	OutOfSyncReplicas         int `json:"outOfSyncReplicas"`
+}
+
+// GetClusterHealth computes the cluster's health from the broker and topic metadata
+func (s *Service) GetClusterHealth(ctx context.Context) (*ClusterHealth, error) {
+	metadata, err := s.kafkaSvc.DescribeCluster()
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster: %w", err)
+	}
+	topics, err := s.kafkaSvc.ListTopics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+
+	health := &ClusterHealth{
+		ControllerID: metadata.ControllerID,
+		BrokerCount:  len(metadata.Brokers),
+		TopicCount:   len(topics),
+	}
+	brokerIDs := make(map[int32]struct{}, len(metadata.Brokers))
+	for _, broker := range metadata.Brokers {
+		brokerIDs[broker.ID] = struct{}{}
+	}
+	health.addPartitions(topics, brokerIDs)
+	health.Status, health.Reasons = health.status()
+
+	return health, nil
+}
+
+// addPartitions counts the partitions and replicas of all topics which are not in sync or offline
+func (h *ClusterHealth) addPartitions(topics []*sarama.TopicMetadata, brokerIDs map[int32]struct{}) {
+	offlineBrokers := make(map[int32]struct{})
+	for _, topic := range topics {
+		for _, partition := range topic.Partitions {
+			h.PartitionCount++
+			if partition.Leader < 0 {
+				h.OfflinePartitions++
+			}
+			if len(partition.Isr) < len(partition.Replicas) {
+				h.UnderReplicatedPartitions++
+				h.OutOfSyncReplicas += len(partition.Replicas) - len(partition.Isr)
+			}
+			for _, replica := range partition.Replicas {
+				if _, exists := brokerIDs[replica]; !exists {
+					offlineBrokers[replica] = struct{}{}
+				}
+			}
+		}
+	}
+
+	h.OfflineBrokerIDs = make([]int32, 0, len(offlineBrokers))
+	for brokerID := range offlineBrokers {
+		h.OfflineBrokerIDs = append(h.OfflineBrokerIDs, brokerID)
+	}
+	sort.Slice(h.OfflineBrokerIDs, func(i, j int) bool { return h.OfflineBrokerIDs[i] < h.OfflineBrokerIDs[j] })
+}
+
+// status derives the overall status from the counted problems. The most severe problem determines the status.
+func (h *ClusterHealth) status() (ClusterStatus, []string) {
+	status := ClusterStatusHealthy
+	reasons := make([]string, 0)
+	if h.ControllerID < 0 {
+		status = ClusterStatusUnhealthy
+		reasons = append(reasons, "no active controller")
+	}
+	if h.OfflinePartitions > 0 {
+		status = ClusterStatusUnhealthy
+		reasons = append(reasons, fmt.Sprintf("%v partitions are offline", h.OfflinePartitions))
+	}
+	if h.UnderReplicatedPartitions > 0 {
+		if status == ClusterStatusHealthy {
+			status = ClusterStatusDegraded
+		}
+		reasons = append(reasons, fmt.Sprintf("%v partitions are under-replicated", h.UnderReplicatedPartitions))
+	}
+	if len(h.OfflineBrokerIDs) > 0 {
+		if status == ClusterStatusHealthy {
+			status = ClusterStatusDegraded
+		}
+		reasons = append(reasons, fmt.Sprintf("brokers %v host replicas but are not part of the cluster", h.OfflineBrokerIDs))
+	}
+
+	return status, reasons
+}
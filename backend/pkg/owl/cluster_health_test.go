@@ -0,0 +1,49 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterHealth(t *testing.T) {
+	brokerIDs := map[int32]struct{}{1: {}, 2: {}, 3: {}}
+	healthy := &sarama.TopicMetadata{Name: "orders", Partitions: []*sarama.PartitionMetadata{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, Isr: []int32{1, 2, 3}},
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3, 1}, Isr: []int32{2, 3, 1}},
+	}}
+	underReplicated := &sarama.TopicMetadata{Name: "payments", Partitions: []*sarama.PartitionMetadata{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 4}, Isr: []int32{1}},
+	}}
+	offline := &sarama.TopicMetadata{Name: "invoices", Partitions: []*sarama.PartitionMetadata{
+		{ID: 0, Leader: -1, Replicas: []int32{4, 5}, Isr: []int32{}},
+	}}
+
+	tt := []struct {
+		topics            []*sarama.TopicMetadata
+		controllerID      int32
+		status            ClusterStatus
+		underReplicated   int
+		offline           int
+		outOfSyncReplicas int
+		offlineBrokerIDs  []int32
+	}{
+		{[]*sarama.TopicMetadata{healthy}, 1, ClusterStatusHealthy, 0, 0, 0, []int32{}},
+		{[]*sarama.TopicMetadata{healthy}, -1, ClusterStatusUnhealthy, 0, 0, 0, []int32{}},
+		{[]*sarama.TopicMetadata{healthy, underReplicated}, 1, ClusterStatusDegraded, 1, 0, 1, []int32{4}},
+		{[]*sarama.TopicMetadata{healthy, underReplicated, offline}, 1, ClusterStatusUnhealthy, 2, 1, 3, []int32{4, 5}},
+	}
+
+	for i, table := range tt {
+		health := &ClusterHealth{ControllerID: table.controllerID}
+		health.addPartitions(table.topics, brokerIDs)
+		status, reasons := health.status()
+		assert.Equal(t, table.status, status, "unexpected status. Case: ", i)
+		assert.Equal(t, table.status == ClusterStatusHealthy, len(reasons) == 0, "unexpected reasons. Case: ", i)
+		assert.Equal(t, table.underReplicated, health.UnderReplicatedPartitions, "unexpected under-replicated partitions. Case: ", i)
+		assert.Equal(t, table.offline, health.OfflinePartitions, "unexpected offline partitions. Case: ", i)
+		assert.Equal(t, table.outOfSyncReplicas, health.OutOfSyncReplicas, "unexpected out-of-sync replicas. Case: ", i)
+		assert.Equal(t, table.offlineBrokerIDs, health.OfflineBrokerIDs, "unexpected offline brokers. Case: ", i)
+	}
+}
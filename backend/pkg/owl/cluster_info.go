@@ -4,14 +4,16 @@ import (
 	"context"
 	"sort"
 
-	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
 	"golang.org/x/sync/errgroup"
 )
 
 // ClusterInfo describes the brokers in a cluster
 type ClusterInfo struct {
-	ControllerID int32     `json:"controllerId"`
-	Brokers      []*Broker `json:"brokers"`
+	ClusterID    string       `json:"clusterId"`    // Empty for clusters before 0.10.1
+	ControllerID int32        `json:"controllerId"` // -1 if the controller is not known (e.g. in KRaft mode)
+	MetadataMode MetadataMode `json:"metadataMode"`
+	Brokers      []*Broker    `json:"brokers"`
 }
 
 // Broker described by some basic broker properties
@@ -22,12 +24,19 @@ type Broker struct {
 	Rack       string `json:"rack"`
 }
 
+// ClusterID returns the id of the Kafka cluster. It's empty if the cluster doesn't report ids or if it hasn't been
+// reached yet.
+func (s *Service) ClusterID() string {
+	return s.kafkaSvc.ClusterID()
+}
+
 // GetClusterInfo returns generic information about all brokers in a Kafka cluster and returns them
 func (s *Service) GetClusterInfo(ctx context.Context) (*ClusterInfo, error) {
 	eg, _ := errgroup.WithContext(ctx)
 
 	var sizeByBroker map[int32]int64
-	var metadata *sarama.MetadataResponse
+	var metadata *kafka.ClusterMetadata
+	var metadataMode MetadataMode
 
 	eg.Go(func() error {
 		var err error
@@ -46,6 +55,15 @@ func (s *Service) GetClusterInfo(ctx context.Context) (*ClusterInfo, error) {
 		}
 		return nil
 	})
+
+	eg.Go(func() error {
+		var err error
+		metadataMode, err = s.GetMetadataMode(ctx)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
@@ -53,23 +71,31 @@ func (s *Service) GetClusterInfo(ctx context.Context) (*ClusterInfo, error) {
 	brokers := make([]*Broker, len(metadata.Brokers))
 	for i, broker := range metadata.Brokers {
 		size := int64(-1)
-		if value, ok := sizeByBroker[broker.ID()]; ok {
+		if value, ok := sizeByBroker[broker.ID]; ok {
 			size = value
 		}
 
 		brokers[i] = &Broker{
-			BrokerID:   broker.ID(),
+			BrokerID:   broker.ID,
 			LogDirSize: size,
-			Address:    broker.Addr(),
-			Rack:       broker.Rack(),
+			Address:    broker.Address,
+			Rack:       broker.Rack,
 		}
 	}
 	sort.Slice(brokers, func(i, j int) bool {
 		return brokers[i].BrokerID < brokers[j].BrokerID
 	})
 
+	// KRaft clusters report a random broker as controller, which would be misleading
+	controllerID := metadata.ControllerID
+	if metadataMode == MetadataModeKRaft {
+		controllerID = -1
+	}
+
 	return &ClusterInfo{
-		ControllerID: metadata.ControllerID,
+		ClusterID:    metadata.ClusterID,
+		ControllerID: controllerID,
+		MetadataMode: metadataMode,
 		Brokers:      brokers,
 	}, nil
 }
@@ -39,6 +39,7 @@ type TopicLag struct {
 // PartitionLag describes the kafka lag for a partition for a single consumer group
 type PartitionLag struct {
 	PartitionID int32 `json:"partitionId"`
+	GroupOffset int64 `json:"groupOffset"`
 	Lag         int64 `json:"lag"`
 }
 
@@ -82,7 +83,7 @@ func (s *Service) getConsumerGroupLags(ctx context.Context, groups []string) (ma
 
 	topicPartitions := make(map[string][]int32, len(topics))
 	for _, topic := range topics {
-		partitions, err := s.kafkaSvc.Client.Partitions(topic)
+		partitions, err := s.kafkaSvc.ListPartitions(topic)
 		if err != nil {
 			s.logger.Error("failed to fetch partition list for calculating the group lags", zap.String("topic", topic), zap.Error(err))
 			return nil, fmt.Errorf("failed to fetch partition list for calculating the group lags")
@@ -131,7 +132,7 @@ func (s *Service) getConsumerGroupLags(ctx context.Context, groups []string) (ma
 					lag = 0
 				}
 				t.SummedLag += lag
-				t.PartitionLags = append(t.PartitionLags, PartitionLag{PartitionID: pID, Lag: lag})
+				t.PartitionLags = append(t.PartitionLags, PartitionLag{PartitionID: pID, GroupOffset: groupOffset, Lag: lag})
 			}
 			topicLags = append(topicLags, &t)
 		}
@@ -144,3 +145,16 @@ func (s *Service) getConsumerGroupLags(ctx context.Context, groups []string) (ma
 
 	return res, nil
 }
+
+// GetConsumerGroupLags returns the lags of all consumer groups, the key is the group id
+func (s *Service) GetConsumerGroupLags(ctx context.Context) (map[string]*ConsumerGroupLag, error) {
+	groups, err := s.kafkaSvc.ListConsumerGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer groups: %w", err)
+	}
+	if len(groups) == 0 {
+		return make(map[string]*ConsumerGroupLag), nil
+	}
+
+	return s.getConsumerGroupLags(ctx, groups)
+}
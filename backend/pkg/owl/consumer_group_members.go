@@ -0,0 +1,194 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// reverseLookupTimeout bounds the reverse DNS lookup of a single member IP
+const reverseLookupTimeout = 2 * time.Second
+
+// ConsumerGroupMembers is a drill-down into the members of a single consumer group and its coordinator, so that
+// operators can find the deployment a member belongs to
+type ConsumerGroupMembers struct {
+	GroupID     string             `json:"groupId"`
+	State       string             `json:"state"`
+	Coordinator GroupCoordinator   `json:"coordinator"`
+	Members     []GroupMemberHosts `json:"members"`
+}
+
+// GroupCoordinator is the broker which manages the group's membership and committed offsets
+type GroupCoordinator struct {
+	BrokerID int32  `json:"brokerId"`
+	Address  string `json:"address"` // Empty if the broker isn't part of the cluster metadata
+	Rack     string `json:"rack"`
+}
+
+// GroupMemberHosts is a group member along with the host names its IP resolves to
+type GroupMemberHosts struct {
+	*GroupMemberDescription
+
+	// Hostnames are the results of a reverse DNS lookup of the client IP, empty if it couldn't be resolved
+	Hostnames []string `json:"hostnames"`
+}
+
+// ClientSoftware is the Kafka client library a member uses. Kafka doesn't expose the software name and version
+// that clients report to the brokers, hence it's inferred from the library's default client id. Members with a
+// custom client id are unknown.
+type ClientSoftware struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"` // Only known if the default client id contains it
+}
+
+// clientSoftwarePatterns map default client ids of popular libraries to their name. If a pattern has a capture group,
+// it's the library's version.
+var clientSoftwarePatterns = []struct {
+	pattern *regexp.Regexp
+	name    string
+}{
+	{regexp.MustCompile(`^connector-consumer-.+-\d+$`), "Kafka Connect"},
+	{regexp.MustCompile(`^.+-StreamThread-\d+-(?:restore-)?consumer$`), "Kafka Streams"},
+	{regexp.MustCompile(`^consumer-(?:.+-)?\d+$`), "Apache Kafka Java client"},
+	{regexp.MustCompile(`^kafka-python-(\d+\.\d+\.\d+.*)$`), "kafka-python"},
+	{regexp.MustCompile(`^rdkafka$`), "librdkafka"},
+	{regexp.MustCompile(`^sarama$`), "Sarama"},
+	{regexp.MustCompile(`^kafkajs$`), "KafkaJS"},
+	{regexp.MustCompile(`^kgo$`), "franz-go"},
+}
+
+// memberIDPattern matches the member ids generated by the group coordinator: "<group.instance.id>-<uuid>" for static
+// members and "<client id>-<uuid>" for dynamic members
+var memberIDPattern = regexp.MustCompile(`^(.*)-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// GetConsumerGroupMembers describes the members of the given group including their reverse resolved host names
+func (s *Service) GetConsumerGroupMembers(ctx context.Context, groupID string) (*ConsumerGroupMembers, error) {
+	described, err := s.kafkaSvc.DescribeConsumerGroups(ctx, []string{groupID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe consumer group: %w", err)
+	}
+	var overview *ConsumerGroupOverview
+	for coordinatorID, res := range described {
+		converted, err := s.convertSaramaGroupDescriptions(res.Groups, nil, coordinatorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe consumer group: %w", err)
+		}
+		for _, group := range converted {
+			if group.GroupID == groupID && group.State != "Dead" {
+				overview = group
+			}
+		}
+	}
+	if overview == nil {
+		return nil, fmt.Errorf("%w: '%v'", ErrConsumerGroupNotFound, groupID)
+	}
+
+	coordinator := GroupCoordinator{BrokerID: overview.CoordinatorID}
+	metadata, err := s.kafkaSvc.DescribeCluster()
+	if err != nil {
+		s.logger.Warn("failed to describe cluster for the group coordinator's address", zap.Error(err))
+	} else {
+		for _, b := range metadata.Brokers {
+			if b.ID == coordinator.BrokerID {
+				coordinator.Address = b.Address
+				coordinator.Rack = b.Rack
+			}
+		}
+	}
+
+	hostnames := s.lookupHostnames(ctx, overview.Members)
+	members := make([]GroupMemberHosts, len(overview.Members))
+	for i, m := range overview.Members {
+		members[i] = GroupMemberHosts{GroupMemberDescription: m, Hostnames: hostnames[m.ClientIP]}
+		if members[i].Hostnames == nil {
+			members[i].Hostnames = []string{}
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+
+	return &ConsumerGroupMembers{
+		GroupID:     groupID,
+		State:       overview.State,
+		Coordinator: coordinator,
+		Members:     members,
+	}, nil
+}
+
+// lookupHostnames resolves the IPs of all members concurrently. IPs which can't be resolved are missing.
+func (s *Service) lookupHostnames(ctx context.Context, members []*GroupMemberDescription) map[string][]string {
+	ips := make(map[string]struct{})
+	for _, m := range members {
+		if m.ClientIP != "" {
+			ips[m.ClientIP] = struct{}{}
+		}
+	}
+
+	res := make(map[string][]string, len(ips))
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for ip := range ips {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			lookupCtx, cancel := context.WithTimeout(ctx, reverseLookupTimeout)
+			defer cancel()
+			names, err := net.DefaultResolver.LookupAddr(lookupCtx, ip)
+			if err != nil {
+				s.logger.Debug("failed to reverse lookup group member ip", zap.String("ip", ip), zap.Error(err))
+				return
+			}
+			for i, name := range names {
+				names[i] = strings.TrimSuffix(name, ".")
+			}
+			mutex.Lock()
+			res[ip] = names
+			mutex.Unlock()
+		}(ip)
+	}
+	wg.Wait()
+
+	return res
+}
+
+// parseClientIP returns the IP of a member's client host, which Kafka reports as "/<ip>"
+func parseClientIP(clientHost string) string {
+	ip := net.ParseIP(strings.TrimPrefix(clientHost, "/"))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// inferClientSoftware returns the client library whose default client id matches, nil if the client id is unknown
+func inferClientSoftware(clientID string) *ClientSoftware {
+	for _, p := range clientSoftwarePatterns {
+		match := p.pattern.FindStringSubmatch(clientID)
+		if match == nil {
+			continue
+		}
+		software := &ClientSoftware{Name: p.name}
+		if len(match) > 1 {
+			software.Version = match[1]
+		}
+		return software
+	}
+	return nil
+}
+
+// inferGroupInstanceID returns the group.instance.id of a static member. The DescribeGroups version we use doesn't
+// return the group instance id, hence it's taken from the member id if its prefix differs from the client id. Static
+// members whose group.instance.id equals their client id can't be told apart from dynamic members.
+func inferGroupInstanceID(memberID string, clientID string) string {
+	match := memberIDPattern.FindStringSubmatch(memberID)
+	if match == nil || match[1] == clientID {
+		return ""
+	}
+	return match[1]
+}
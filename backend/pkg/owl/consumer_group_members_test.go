@@ -0,0 +1,37 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClientIP(t *testing.T) {
+	assert.Equal(t, "10.0.0.5", parseClientIP("/10.0.0.5"))
+	assert.Equal(t, "2001:db8::1", parseClientIP("/2001:db8:0::1"))
+	assert.Equal(t, "", parseClientIP("consumer.example.com"))
+	assert.Equal(t, "", parseClientIP(""))
+}
+
+func TestInferClientSoftware(t *testing.T) {
+	tests := map[string]*ClientSoftware{
+		"consumer-orders-processor-1":          {Name: "Apache Kafka Java client"},
+		"consumer-3":                           {Name: "Apache Kafka Java client"},
+		"app-5b7e1c52-StreamThread-1-consumer": {Name: "Kafka Streams"},
+		"connector-consumer-s3-sink-0":         {Name: "Kafka Connect"},
+		"kafka-python-2.0.2":                   {Name: "kafka-python", Version: "2.0.2"},
+		"rdkafka":                              {Name: "librdkafka"},
+		"sarama":                               {Name: "Sarama"},
+		"orders-service":                       nil,
+	}
+	for clientID, expected := range tests {
+		assert.Equal(t, expected, inferClientSoftware(clientID), clientID)
+	}
+}
+
+func TestInferGroupInstanceID(t *testing.T) {
+	const uuid = "3f2c1a7e-9b4d-4e21-8c55-0d6f1e2a3b4c"
+	assert.Equal(t, "orders-0", inferGroupInstanceID("orders-0-"+uuid, "consumer-orders-1"))
+	assert.Equal(t, "", inferGroupInstanceID("consumer-orders-1-"+uuid, "consumer-orders-1"))
+	assert.Equal(t, "", inferGroupInstanceID("custom-member-id", "consumer-orders-1"))
+}
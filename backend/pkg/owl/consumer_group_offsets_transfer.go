@@ -0,0 +1,254 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// GroupOffsetsDocumentVersion is the version of the offsets documents which are exported by Kowl
+const GroupOffsetsDocumentVersion = 1
+
+// ErrInvalidOffsetsDocument is returned if an offsets document can't be imported, e.g. because it references
+// partitions which do not exist in the target cluster
+var ErrInvalidOffsetsDocument = errors.New("invalid consumer group offsets document")
+
+// GroupOffsetsDocument contains the committed offsets of a consumer group. It is exported as JSON so that the offsets
+// can be backed up or imported for another group or into another cluster.
+type GroupOffsetsDocument struct {
+	Version    int                   `json:"version"`
+	GroupID    string                `json:"groupId"`
+	ExportedAt time.Time             `json:"exportedAt"`
+	Offsets    []ExportedGroupOffset `json:"offsets"`
+}
+
+// ExportedGroupOffset is the committed offset of a single partition
+type ExportedGroupOffset struct {
+	TopicName   string `json:"topicName"`
+	PartitionID int32  `json:"partitionId"`
+	Offset      int64  `json:"offset"`
+	Metadata    string `json:"metadata,omitempty"`
+}
+
+// Validate returns an error if the document has an unknown version, contains no offsets or invalid offsets
+func (d *GroupOffsetsDocument) Validate() error {
+	if d.Version != GroupOffsetsDocumentVersion {
+		return fmt.Errorf("unsupported document version '%v', expected version '%v'", d.Version, GroupOffsetsDocumentVersion)
+	}
+	if len(d.Offsets) == 0 {
+		return fmt.Errorf("document must contain at least one offset")
+	}
+
+	seen := make(map[string]map[int32]bool)
+	for _, o := range d.Offsets {
+		if o.TopicName == "" {
+			return fmt.Errorf("topic name must be set for all offsets")
+		}
+		if o.PartitionID < 0 {
+			return fmt.Errorf("partition id '%v' of topic '%v' must not be negative", o.PartitionID, o.TopicName)
+		}
+		if o.Offset < 0 {
+			return fmt.Errorf("offset of topic '%v' partition '%v' must not be negative", o.TopicName, o.PartitionID)
+		}
+		if _, exists := seen[o.TopicName]; !exists {
+			seen[o.TopicName] = make(map[int32]bool)
+		}
+		if seen[o.TopicName][o.PartitionID] {
+			return fmt.Errorf("topic '%v' partition '%v' is contained more than once", o.TopicName, o.PartitionID)
+		}
+		seen[o.TopicName][o.PartitionID] = true
+	}
+
+	return nil
+}
+
+// ExportConsumerGroupOffsets returns a document with all committed offsets of the group. If topic names are given,
+// only the offsets of these topics are exported.
+func (s *Service) ExportConsumerGroupOffsets(groupID string, topicNames []string) (*GroupOffsetsDocument, error) {
+	committed, err := s.kafkaSvc.ListConsumerGroupOffsets(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer group offsets: %w", err)
+	}
+
+	includeTopic := make(map[string]bool, len(topicNames))
+	for _, topicName := range topicNames {
+		includeTopic[topicName] = true
+	}
+
+	offsets := make([]ExportedGroupOffset, 0)
+	for topicName, blocks := range committed.Blocks {
+		if len(includeTopic) > 0 && !includeTopic[topicName] {
+			continue
+		}
+		for partitionID, block := range blocks {
+			// Partitions without a committed offset are reported with offset -1
+			if block.Err != sarama.ErrNoError || block.Offset < 0 {
+				continue
+			}
+			offsets = append(offsets, ExportedGroupOffset{
+				TopicName:   topicName,
+				PartitionID: partitionID,
+				Offset:      block.Offset,
+				Metadata:    block.Metadata,
+			})
+		}
+	}
+	if len(offsets) == 0 {
+		return nil, fmt.Errorf("%w: '%v'", ErrConsumerGroupNotFound, groupID)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		if offsets[i].TopicName != offsets[j].TopicName {
+			return offsets[i].TopicName < offsets[j].TopicName
+		}
+		return offsets[i].PartitionID < offsets[j].PartitionID
+	})
+
+	return &GroupOffsetsDocument{
+		Version:    GroupOffsetsDocumentVersion,
+		GroupID:    groupID,
+		ExportedAt: time.Now().UTC(),
+		Offsets:    offsets,
+	}, nil
+}
+
+// ImportConsumerGroupOffsetsRequest describes which group the offsets of an exported document shall be committed for
+type ImportConsumerGroupOffsetsRequest struct {
+	// GroupID is the group the offsets are committed for, it may differ from the document's group id
+	GroupID  string
+	Document GroupOffsetsDocument
+	DryRun   bool
+
+	// SkipMissingPartitions skips offsets of partitions which do not exist in the cluster instead of rejecting the
+	// whole document
+	SkipMissingPartitions bool
+}
+
+// ImportConsumerGroupOffsetsResponse contains the old and new offset of each partition in the document. If the request
+// has been a dry run, the new offsets have not been committed.
+type ImportConsumerGroupOffsetsResponse struct {
+	GroupID    string                  `json:"groupId"`
+	GroupState string                  `json:"groupState"`
+	DryRun     bool                    `json:"dryRun"`
+	Partitions []PartitionOffsetImport `json:"partitions"`
+}
+
+// PartitionOffsetImport is the result of the import for a single partition
+type PartitionOffsetImport struct {
+	TopicName     string `json:"topicName"`
+	PartitionID   int32  `json:"partitionId"`
+	CurrentOffset int64  `json:"currentOffset"` // -1 if the group has not committed an offset yet
+	NewOffset     int64  `json:"newOffset"`
+	Metadata      string `json:"metadata,omitempty"`
+	LowWaterMark  int64  `json:"lowWaterMark"`
+	HighWaterMark int64  `json:"highWaterMark"`
+	Skipped       bool   `json:"skipped"`
+	Warning       string `json:"warning,omitempty"`
+}
+
+// ImportConsumerGroupOffsets commits the offsets of an exported document for the requested group, unless the request
+// is a dry run. Offsets which are out of the partition's range (e.g. because they have been exported from another
+// cluster) are clamped to the water marks. Like resets, offsets can only be imported if the group has no active
+// members.
+func (s *Service) ImportConsumerGroupOffsets(ctx context.Context, req ImportConsumerGroupOffsetsRequest) (*ImportConsumerGroupOffsetsResponse, error) {
+	if err := req.Document.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOffsetsDocument, err)
+	}
+
+	// 1. Check group state
+	groupState, err := s.committableGroupState(ctx, req.GroupID, req.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Check which of the document's partitions exist in the cluster
+	topics, err := s.kafkaSvc.ListTopics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+	existingPartitions := make(map[string]map[int32]bool, len(topics))
+	for _, topic := range topics {
+		existingPartitions[topic.Name] = make(map[int32]bool, len(topic.Partitions))
+		for _, p := range topic.Partitions {
+			existingPartitions[topic.Name][p.ID] = true
+		}
+	}
+	partitionIDsByTopic := make(map[string][]int32)
+	for _, o := range req.Document.Offsets {
+		if !existingPartitions[o.TopicName][o.PartitionID] {
+			if req.SkipMissingPartitions {
+				continue
+			}
+			return nil, fmt.Errorf("%w: topic '%v' partition '%v' does not exist", ErrInvalidOffsetsDocument, o.TopicName, o.PartitionID)
+		}
+		partitionIDsByTopic[o.TopicName] = append(partitionIDsByTopic[o.TopicName], o.PartitionID)
+	}
+
+	// 3. Get currently committed offsets and the water marks the new offsets must be within
+	committed, err := s.kafkaSvc.ListConsumerGroupOffsets(req.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer group offsets: %w", err)
+	}
+	waterMarks := make(map[string]map[int32]*kafka.WaterMark, len(partitionIDsByTopic))
+	for topicName, partitionIDs := range partitionIDsByTopic {
+		waterMarks[topicName], err = s.kafkaSvc.WaterMarks(topicName, partitionIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get water marks for topic '%v': %w", topicName, err)
+		}
+	}
+
+	// 4. Resolve new offsets
+	partitions := make([]PartitionOffsetImport, 0, len(req.Document.Offsets))
+	newOffsets := make(map[string]map[int32]kafka.GroupOffset)
+	for _, o := range req.Document.Offsets {
+		imported := PartitionOffsetImport{
+			TopicName:     o.TopicName,
+			PartitionID:   o.PartitionID,
+			CurrentOffset: -1,
+			NewOffset:     o.Offset,
+			Metadata:      o.Metadata,
+		}
+		if block := committed.GetBlock(o.TopicName, o.PartitionID); block != nil && block.Err == sarama.ErrNoError {
+			imported.CurrentOffset = block.Offset
+		}
+
+		mark, exists := waterMarks[o.TopicName][o.PartitionID]
+		if !exists {
+			imported.Skipped = true
+			imported.Warning = "partition does not exist"
+			partitions = append(partitions, imported)
+			continue
+		}
+		imported.LowWaterMark = mark.Low
+		imported.HighWaterMark = mark.High
+		imported.NewOffset, imported.Warning = clampOffset(o.Offset, mark)
+
+		if _, exists := newOffsets[o.TopicName]; !exists {
+			newOffsets[o.TopicName] = make(map[int32]kafka.GroupOffset)
+		}
+		newOffsets[o.TopicName][o.PartitionID] = kafka.GroupOffset{Offset: imported.NewOffset, Metadata: o.Metadata}
+		partitions = append(partitions, imported)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].TopicName != partitions[j].TopicName {
+			return partitions[i].TopicName < partitions[j].TopicName
+		}
+		return partitions[i].PartitionID < partitions[j].PartitionID
+	})
+
+	// 5. Commit offsets
+	if err := s.commitGroupOffsets(req.GroupID, newOffsets, req.DryRun); err != nil {
+		return nil, err
+	}
+
+	return &ImportConsumerGroupOffsetsResponse{
+		GroupID:    req.GroupID,
+		GroupState: groupState,
+		DryRun:     req.DryRun,
+		Partitions: partitions,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestExportImportConsumerGroupOffsets(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 2, 1, nil, false))
+	for i := 0; i < 5; i++ {
+		_, err := cluster.Produce(kafka.ProduceRecord{TopicName: "orders", Partitioner: kafka.PartitionerManual, PartitionID: 1, Value: []byte("{}")}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+	require.NoError(t, cluster.CommitConsumerGroupOffsetsWithMetadata("billing", map[string]map[int32]kafka.GroupOffset{
+		"orders": {0: {Offset: 0, Metadata: "m0"}, 1: {Offset: 3, Metadata: "m1"}},
+	}))
+	svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+	doc, err := svc.ExportConsumerGroupOffsets("billing", nil)
+	require.NoError(t, err)
+	assert.Equal(t, GroupOffsetsDocumentVersion, doc.Version)
+	assert.Equal(t, "billing", doc.GroupID)
+	assert.Equal(t, []ExportedGroupOffset{
+		{TopicName: "orders", PartitionID: 0, Offset: 0, Metadata: "m0"},
+		{TopicName: "orders", PartitionID: 1, Offset: 3, Metadata: "m1"},
+	}, doc.Offsets)
+
+	_, err = svc.ExportConsumerGroupOffsets("unknown", nil)
+	assert.True(t, errors.Is(err, ErrConsumerGroupNotFound))
+
+	// Import the offsets for another group, the offset of partition 1 is out of range and the topic payments does not
+	// exist
+	doc.Offsets[1].Offset = 10
+	doc.Offsets = append(doc.Offsets, ExportedGroupOffset{TopicName: "payments", PartitionID: 0, Offset: 1})
+	req := ImportConsumerGroupOffsetsRequest{GroupID: "billing-copy", Document: *doc}
+	_, err = svc.ImportConsumerGroupOffsets(context.Background(), req)
+	assert.True(t, errors.Is(err, ErrInvalidOffsetsDocument))
+
+	req.SkipMissingPartitions = true
+	res, err := svc.ImportConsumerGroupOffsets(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, res.Partitions, 3)
+	assert.Equal(t, int64(-1), res.Partitions[1].CurrentOffset)
+	assert.Equal(t, int64(5), res.Partitions[1].NewOffset)
+	assert.NotEmpty(t, res.Partitions[1].Warning)
+	assert.True(t, res.Partitions[2].Skipped)
+
+	imported, err := svc.ExportConsumerGroupOffsets("billing-copy", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []ExportedGroupOffset{
+		{TopicName: "orders", PartitionID: 0, Offset: 0, Metadata: "m0"},
+		{TopicName: "orders", PartitionID: 1, Offset: 5, Metadata: "m1"},
+	}, imported.Offsets)
+}
+
+func TestGroupOffsetsDocumentValidate(t *testing.T) {
+	tt := []struct {
+		offsets []ExportedGroupOffset
+		valid   bool
+	}{
+		{[]ExportedGroupOffset{{TopicName: "orders", PartitionID: 0, Offset: 1}}, true},
+		{[]ExportedGroupOffset{}, false},
+		{[]ExportedGroupOffset{{TopicName: "", PartitionID: 0, Offset: 1}}, false},
+		{[]ExportedGroupOffset{{TopicName: "orders", PartitionID: -1, Offset: 1}}, false},
+		{[]ExportedGroupOffset{{TopicName: "orders", PartitionID: 0, Offset: -1}}, false},
+		{[]ExportedGroupOffset{{TopicName: "orders", PartitionID: 0, Offset: 1}, {TopicName: "orders", PartitionID: 0, Offset: 2}}, false},
+	}
+
+	for i, test := range tt {
+		doc := GroupOffsetsDocument{Version: GroupOffsetsDocumentVersion, Offsets: test.offsets}
+		assert.Equal(t, test.valid, doc.Validate() == nil, "Case: ", i)
+	}
+}
+
+func TestImportConsumerGroupOffsetsRefusesNonEmptyGroup(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 1 // Creates the group "orders-processor" which has active members
+	svc := NewService(kafka.NewFakeCluster(fakeCfg, zap.NewNop()), nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+	doc, err := svc.ExportConsumerGroupOffsets("orders-processor", nil)
+	require.NoError(t, err)
+	req := ImportConsumerGroupOffsetsRequest{GroupID: "orders-processor", Document: *doc}
+	_, err = svc.ImportConsumerGroupOffsets(context.Background(), req)
+	assert.True(t, errors.Is(err, ErrGroupNotEmpty))
+
+	req.DryRun = true
+	res, err := svc.ImportConsumerGroupOffsets(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "Stable", res.GroupState)
+}
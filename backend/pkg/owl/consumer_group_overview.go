@@ -5,6 +5,7 @@ import (
 	"sort"
 
 	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/principals"
 	"go.uber.org/zap"
 )
 
@@ -25,7 +26,21 @@ type GroupMemberDescription struct {
 	ID          string                   `json:"id"`
 	ClientID    string                   `json:"clientId"`
 	ClientHost  string                   `json:"clientHost"`
+	ClientIP    string                   `json:"clientIp"` // Empty if the client host is no IP address
 	Assignments []*GroupMemberAssignment `json:"assignments"`
+
+	// ClientSoftware is inferred from the client id, nil if it isn't a known default client id
+	ClientSoftware *ClientSoftware `json:"clientSoftware"`
+
+	// SubscribedTopics is the member's subscription, which may include topics that haven't been assigned to it
+	SubscribedTopics []string `json:"subscribedTopics"`
+
+	// GroupInstanceID is the group.instance.id of static members, empty for dynamic members. It's inferred from the
+	// member id, see inferGroupInstanceID.
+	GroupInstanceID string `json:"groupInstanceId,omitempty"`
+
+	// Identity is the friendly name of the member's client, nil if it's not known
+	Identity *principals.Identity `json:"identity,omitempty"`
 }
 
 // GroupMemberAssignment represents a partition assignment for a group member
@@ -64,6 +79,32 @@ func (s *Service) GetConsumerGroupsOverview(ctx context.Context) ([]*ConsumerGro
 	return res, nil
 }
 
+// DescribeAllConsumerGroups returns the state and members of all consumer groups. Unlike GetConsumerGroupsOverview
+// it doesn't fetch the groups' offsets, hence the overviews have no lags.
+func (s *Service) DescribeAllConsumerGroups(ctx context.Context) ([]*ConsumerGroupOverview, error) {
+	groups, err := s.kafkaSvc.ListConsumerGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	describedGroups, err := s.kafkaSvc.DescribeConsumerGroups(ctx, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*ConsumerGroupOverview, 0, len(groups))
+	for id, group := range describedGroups {
+		converted, err := s.convertSaramaGroupDescriptions(group.Groups, nil, id)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, converted...)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].GroupID < res[j].GroupID })
+
+	return res, nil
+}
+
 func (s *Service) convertSaramaGroupDescriptions(descriptions []*sarama.GroupDescription, lags map[string]*ConsumerGroupLag, coordinator int32) ([]*ConsumerGroupOverview, error) {
 	response := make([]*ConsumerGroupOverview, len(descriptions))
 	for i, d := range descriptions {
@@ -103,12 +144,21 @@ func (s *Service) convertGroupMembers(members map[string]*sarama.GroupMemberDesc
 		// see: https://cwiki.apache.org/confluence/display/KAFKA/A+Guide+To+The+Kafka+Protocol
 
 		resultAssignments := make([]*GroupMemberAssignment, 0)
+		subscribedTopics := make([]string, 0)
 		if protocolType == "consumer" {
 			assignments, err := m.GetMemberAssignment()
 			if err != nil {
 				s.logger.Warn("failed to decode member assignments", zap.String("client_id", m.ClientId), zap.Error(err))
 			}
 
+			metadata, err := m.GetMemberMetadata()
+			if err != nil {
+				s.logger.Debug("failed to decode member metadata", zap.String("client_id", m.ClientId), zap.Error(err))
+			} else if metadata != nil {
+				subscribedTopics = append(subscribedTopics, metadata.Topics...)
+				sort.Strings(subscribedTopics)
+			}
+
 			for topic, partitionIDs := range assignments.Topics {
 				sort.Slice(partitionIDs, func(i, j int) bool { return partitionIDs[i] < partitionIDs[j] })
 
@@ -126,10 +176,14 @@ func (s *Service) convertGroupMembers(members map[string]*sarama.GroupMemberDesc
 		})
 
 		response[counter] = &GroupMemberDescription{
-			ID:          id,
-			ClientID:    m.ClientId,
-			ClientHost:  m.ClientHost,
-			Assignments: resultAssignments,
+			ID:               id,
+			ClientID:         m.ClientId,
+			ClientHost:       m.ClientHost,
+			ClientIP:         parseClientIP(m.ClientHost),
+			Assignments:      resultAssignments,
+			ClientSoftware:   inferClientSoftware(m.ClientId),
+			SubscribedTopics: subscribedTopics,
+			GroupInstanceID:  inferGroupInstanceID(id, m.ClientId),
 		}
 		counter++
 	}
@@ -0,0 +1,113 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrConsumerGroupNotFound is returned if the consumer group has no committed offsets
+var ErrConsumerGroupNotFound = errors.New("consumer group not found")
+
+// ConsumerGroupTimeLag estimates how far a consumer group is behind in time. The time lag of a partition is the age
+// of the oldest message which has not been consumed yet, which is the message at the committed group offset.
+type ConsumerGroupTimeLag struct {
+	GroupID      string         `json:"groupId"`
+	MaxTimeLagMs *int64         `json:"maxTimeLagMs"` // nil if the time lag of no partition could be estimated
+	Topics       []TopicTimeLag `json:"topics"`
+}
+
+// TopicTimeLag is the time lag of a group on a single topic
+type TopicTimeLag struct {
+	Topic        string             `json:"topic"`
+	MaxTimeLagMs *int64             `json:"maxTimeLagMs"`
+	Partitions   []PartitionTimeLag `json:"partitions"`
+}
+
+// PartitionTimeLag is the time lag of a group on a single partition. TimeLagMs is nil if the message at the group
+// offset could not be consumed, e.g. because it has been deleted already.
+type PartitionTimeLag struct {
+	PartitionID int32  `json:"partitionId"`
+	GroupOffset int64  `json:"groupOffset"`
+	Lag         int64  `json:"lag"`
+	TimeLagMs   *int64 `json:"timeLagMs"`
+
+	// OldestUnconsumedTimestamp is the timestamp (unix ms) of the message at the group offset, nil if there is none
+	OldestUnconsumedTimestamp *int64 `json:"oldestUnconsumedTimestamp"`
+}
+
+// GetConsumerGroupTimeLag estimates the time lag of a group for all partitions it has committed offsets for
+func (s *Service) GetConsumerGroupTimeLag(ctx context.Context, groupID string) (*ConsumerGroupTimeLag, error) {
+	lags, err := s.getConsumerGroupLags(ctx, []string{groupID})
+	if err != nil {
+		return nil, err
+	}
+	groupLag, ok := lags[groupID]
+	if !ok || len(groupLag.TopicLags) == 0 {
+		return nil, fmt.Errorf("%w: '%v'", ErrConsumerGroupNotFound, groupID)
+	}
+
+	now := time.Now()
+	res := &ConsumerGroupTimeLag{GroupID: groupID, Topics: make([]TopicTimeLag, 0, len(groupLag.TopicLags))}
+	for _, topicLag := range groupLag.TopicLags {
+		// Only partitions with an offset lag have unconsumed messages whose timestamp we need
+		offsets := make(map[int32]int64)
+		for _, p := range topicLag.PartitionLags {
+			if p.Lag > 0 {
+				offsets[p.PartitionID] = p.GroupOffset
+			}
+		}
+		timestamps, err := s.kafkaSvc.MessageTimestamps(ctx, topicLag.Topic, offsets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message timestamps of topic '%v': %w", topicLag.Topic, err)
+		}
+
+		topicTimeLag := calculateTopicTimeLag(topicLag, timestamps, now)
+		res.Topics = append(res.Topics, topicTimeLag)
+		res.MaxTimeLagMs = maxTimeLag(res.MaxTimeLagMs, topicTimeLag.MaxTimeLagMs)
+	}
+	sort.Slice(res.Topics, func(i, j int) bool { return res.Topics[i].Topic < res.Topics[j].Topic })
+
+	return res, nil
+}
+
+// calculateTopicTimeLag derives the time lag of each partition from the timestamp of the message at its group offset.
+// Partitions without offset lag have no time lag.
+func calculateTopicTimeLag(topicLag *TopicLag, timestamps map[int32]time.Time, now time.Time) TopicTimeLag {
+	res := TopicTimeLag{Topic: topicLag.Topic, Partitions: make([]PartitionTimeLag, 0, len(topicLag.PartitionLags))}
+	for _, p := range topicLag.PartitionLags {
+		partition := PartitionTimeLag{PartitionID: p.PartitionID, GroupOffset: p.GroupOffset, Lag: p.Lag}
+		if p.Lag == 0 {
+			timeLag := int64(0)
+			partition.TimeLagMs = &timeLag
+		} else if ts, ok := timestamps[p.PartitionID]; ok {
+			timestamp := ts.UnixNano() / int64(time.Millisecond)
+			timeLag := now.Sub(ts).Milliseconds()
+			if timeLag < 0 {
+				// Producer clocks may be ahead of ours
+				timeLag = 0
+			}
+			partition.OldestUnconsumedTimestamp = &timestamp
+			partition.TimeLagMs = &timeLag
+		}
+
+		res.Partitions = append(res.Partitions, partition)
+		res.MaxTimeLagMs = maxTimeLag(res.MaxTimeLagMs, partition.TimeLagMs)
+	}
+	sort.Slice(res.Partitions, func(i, j int) bool { return res.Partitions[i].PartitionID < res.Partitions[j].PartitionID })
+
+	return res
+}
+
+// maxTimeLag returns the greater time lag, where nil means unknown
+func maxTimeLag(a *int64, b *int64) *int64 {
+	if a == nil {
+		return b
+	}
+	if b == nil || *a >= *b {
+		return a
+	}
+	return b
+}
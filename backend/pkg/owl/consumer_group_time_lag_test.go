@@ -0,0 +1,45 @@
+package owl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateTopicTimeLag(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	topicLag := &TopicLag{
+		Topic: "orders",
+		PartitionLags: []PartitionLag{
+			{PartitionID: 2, GroupOffset: 10, Lag: 5},
+			{PartitionID: 0, GroupOffset: 100, Lag: 0},
+			{PartitionID: 1, GroupOffset: 50, Lag: 3},
+			{PartitionID: 3, GroupOffset: 7, Lag: 1},
+		},
+	}
+	timestamps := map[int32]time.Time{
+		1: now.Add(-4 * time.Minute),
+		2: now.Add(-90 * time.Second),
+		3: now.Add(time.Second), // Clock skew of the producer
+	}
+
+	res := calculateTopicTimeLag(topicLag, timestamps, now)
+	require.Len(t, res.Partitions, 4)
+	require.NotNil(t, res.MaxTimeLagMs)
+	assert.Equal(t, int64(4*60*1000), *res.MaxTimeLagMs)
+
+	assert.Equal(t, int32(0), res.Partitions[0].PartitionID)
+	assert.Equal(t, int64(0), *res.Partitions[0].TimeLagMs, "partitions without offset lag have no time lag")
+	assert.Nil(t, res.Partitions[0].OldestUnconsumedTimestamp)
+
+	assert.Equal(t, int64(90*1000), *res.Partitions[2].TimeLagMs)
+	assert.Equal(t, now.Add(-90*time.Second).Unix()*1000, *res.Partitions[2].OldestUnconsumedTimestamp)
+	assert.Equal(t, int64(0), *res.Partitions[3].TimeLagMs, "negative time lags are clamped")
+
+	// Time lag is unknown if no message timestamp could be fetched
+	res = calculateTopicTimeLag(&TopicLag{Topic: "orders", PartitionLags: []PartitionLag{{PartitionID: 0, Lag: 3}}}, nil, now)
+	assert.Nil(t, res.Partitions[0].TimeLagMs)
+	assert.Nil(t, res.MaxTimeLagMs)
+}
@@ -0,0 +1,30 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// DeleteConsumerGroup deletes a consumer group along with its committed offsets. Only groups without active members
+// can be deleted.
+func (s *Service) DeleteConsumerGroup(ctx context.Context, groupID string) error {
+	err := s.kafkaSvc.DeleteConsumerGroup(groupID)
+	if err == nil {
+		return nil
+	}
+
+	var kErr sarama.KError
+	if errors.As(err, &kErr) {
+		switch kErr {
+		case sarama.ErrGroupIDNotFound:
+			return fmt.Errorf("%w: '%v'", ErrConsumerGroupNotFound, groupID)
+		case sarama.ErrNonEmptyGroup:
+			return fmt.Errorf("%w: all consumers must be stopped before the group can be deleted", ErrGroupNotEmpty)
+		}
+	}
+
+	return fmt.Errorf("failed to delete consumer group: %w", err)
+}
@@ -0,0 +1,168 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// DeleteRecordsStrategy describes up to which offset the records of a topic's partitions shall be deleted
+type DeleteRecordsStrategy string
+
+const (
+	DeleteRecordsOffset    DeleteRecordsStrategy = "offset"    // A specific offset per partition
+	DeleteRecordsTimestamp DeleteRecordsStrategy = "timestamp" // First offset at or after the given unix milliseconds
+)
+
+// ErrDeleteRecordsPreviewOutdated is returned if the number of records which would be deleted differs from the
+// number the requester has confirmed after the dry run
+var ErrDeleteRecordsPreviewOutdated = errors.New("records to delete differ from the confirmed preview")
+
+// DeleteRecordsRequest describes which records of a topic shall be deleted. All records before the resolved offset
+// of each partition are deleted.
+type DeleteRecordsRequest struct {
+	TopicName string
+	Strategy  DeleteRecordsStrategy
+
+	// Offsets maps partition ids to the offset of the first record which shall be kept, -1 deletes all records of
+	// the partition. Only used for the offset strategy.
+	Offsets map[int32]int64
+
+	// Timestamp (unix milliseconds) and PartitionIDs are only used for the timestamp strategy. All records written
+	// before the timestamp are deleted in the given partitions, or in all partitions if no partition ids are given.
+	Timestamp    int64
+	PartitionIDs []int32
+
+	// DryRun only resolves the records which would be deleted. Otherwise ConfirmedRecordCount must match the number
+	// of records to delete, so that records are only deleted after the requester has seen an up to date preview.
+	DryRun               bool
+	ConfirmedRecordCount int64
+}
+
+// DeleteRecordsResponse contains the resolved deletion of each partition. If the request has been a dry run, no
+// records have been deleted.
+type DeleteRecordsResponse struct {
+	TopicName       string                   `json:"topicName"`
+	DryRun          bool                     `json:"dryRun"`
+	RecordsToDelete int64                    `json:"recordsToDelete"`
+	Partitions      []PartitionRecordsDelete `json:"partitions"`
+}
+
+// PartitionRecordsDelete is the deletion of a single partition. The number of records is the offset difference,
+// which is an upper bound for compacted or transactional topics.
+type PartitionRecordsDelete struct {
+	PartitionID     int32  `json:"partitionId"`
+	LowWaterMark    int64  `json:"lowWaterMark"`
+	HighWaterMark   int64  `json:"highWaterMark"`
+	NewLowWaterMark int64  `json:"newLowWaterMark"`
+	RecordsToDelete int64  `json:"recordsToDelete"`
+	Warning         string `json:"warning,omitempty"`
+}
+
+// DeleteRecords resolves the offsets up to which records shall be deleted and deletes the records, unless the
+// request is a dry run. Deleted records can't be restored.
+func (s *Service) DeleteRecords(_ context.Context, req DeleteRecordsRequest) (*DeleteRecordsResponse, error) {
+	targetOffsets, err := s.resolveDeleteRecordsOffsets(req)
+	if err != nil {
+		return nil, err
+	}
+	partitionIDs := make([]int32, 0, len(targetOffsets))
+	for partitionID := range targetOffsets {
+		partitionIDs = append(partitionIDs, partitionID)
+	}
+	waterMarks, err := s.kafkaSvc.WaterMarks(req.TopicName, partitionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get water marks for topic '%v': %w", req.TopicName, topicAdminError(err))
+	}
+
+	partitions, err := resolveRecordsDeletes(targetOffsets, waterMarks)
+	if err != nil {
+		return nil, err
+	}
+	res := &DeleteRecordsResponse{
+		TopicName:  req.TopicName,
+		DryRun:     req.DryRun,
+		Partitions: partitions,
+	}
+	offsets := make(map[int32]int64)
+	for _, partition := range partitions {
+		res.RecordsToDelete += partition.RecordsToDelete
+		if partition.RecordsToDelete > 0 {
+			offsets[partition.PartitionID] = partition.NewLowWaterMark
+		}
+	}
+	if req.DryRun {
+		return res, nil
+	}
+
+	if res.RecordsToDelete != req.ConfirmedRecordCount {
+		return nil, fmt.Errorf("%w: %v records have been confirmed, but %v records would be deleted now",
+			ErrDeleteRecordsPreviewOutdated, req.ConfirmedRecordCount, res.RecordsToDelete)
+	}
+	if len(offsets) > 0 {
+		err = s.kafkaSvc.DeleteRecords(req.TopicName, offsets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete records: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// resolveDeleteRecordsOffsets returns a map of: partitionID -> requested offset before which all records shall be
+// deleted, -1 for all records
+func (s *Service) resolveDeleteRecordsOffsets(req DeleteRecordsRequest) (map[int32]int64, error) {
+	switch req.Strategy {
+	case DeleteRecordsOffset:
+		return req.Offsets, nil
+	case DeleteRecordsTimestamp:
+		partitionIDs := req.PartitionIDs
+		if len(partitionIDs) == 0 {
+			var err error
+			partitionIDs, err = s.kafkaSvc.ListPartitions(req.TopicName)
+			if err != nil {
+				return nil, topicAdminError(err)
+			}
+		}
+		offsets, err := s.kafkaSvc.OffsetsForTimes(req.TopicName, partitionIDs, req.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get offsets for timestamp in topic '%v': %w", req.TopicName, topicAdminError(err))
+		}
+		// OffsetsForTimes returns -1 if no message has been written at or after the timestamp, which means that all
+		// records shall be deleted
+		return offsets, nil
+	default:
+		return nil, fmt.Errorf("unknown delete records strategy '%v'", req.Strategy)
+	}
+}
+
+// resolveRecordsDeletes clamps the requested offsets to the partitions' water marks and counts the records which
+// would be deleted. The result is ordered by partition id.
+func resolveRecordsDeletes(targetOffsets map[int32]int64, waterMarks map[int32]*kafka.WaterMark) ([]PartitionRecordsDelete, error) {
+	partitions := make([]PartitionRecordsDelete, 0, len(targetOffsets))
+	for partitionID, offset := range targetOffsets {
+		mark, exists := waterMarks[partitionID]
+		if !exists {
+			return nil, fmt.Errorf("%w: partition '%v' does not exist", ErrInvalidTopicRequest, partitionID)
+		}
+
+		partition := PartitionRecordsDelete{
+			PartitionID:   partitionID,
+			LowWaterMark:  mark.Low,
+			HighWaterMark: mark.High,
+		}
+		if offset == -1 {
+			partition.NewLowWaterMark = mark.High
+		} else {
+			partition.NewLowWaterMark, partition.Warning = clampOffset(offset, mark)
+		}
+		partition.RecordsToDelete = partition.NewLowWaterMark - mark.Low
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].PartitionID < partitions[j].PartitionID })
+
+	return partitions, nil
+}
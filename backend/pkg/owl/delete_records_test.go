@@ -0,0 +1,36 @@
+package owl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRecordsDeletes(t *testing.T) {
+	waterMarks := map[int32]*kafka.WaterMark{
+		0: {PartitionID: 0, Low: 10, High: 100},
+		1: {PartitionID: 1, Low: 0, High: 50},
+		2: {PartitionID: 2, Low: 20, High: 20},
+		3: {PartitionID: 3, Low: 5, High: 30},
+	}
+	targetOffsets := map[int32]int64{3: 2, 2: -1, 1: 80, 0: 40}
+
+	partitions, err := resolveRecordsDeletes(targetOffsets, waterMarks)
+	require.NoError(t, err)
+	require.Len(t, partitions, 4)
+
+	assert.Equal(t, PartitionRecordsDelete{PartitionID: 0, LowWaterMark: 10, HighWaterMark: 100, NewLowWaterMark: 40, RecordsToDelete: 30}, partitions[0])
+	assert.Equal(t, int64(50), partitions[1].NewLowWaterMark)
+	assert.Equal(t, int64(50), partitions[1].RecordsToDelete)
+	assert.NotEmpty(t, partitions[1].Warning) // Offset above the high watermark
+	assert.Equal(t, int64(0), partitions[2].RecordsToDelete)
+	assert.Equal(t, int64(5), partitions[3].NewLowWaterMark)
+	assert.Equal(t, int64(0), partitions[3].RecordsToDelete)
+	assert.NotEmpty(t, partitions[3].Warning) // Records have been deleted already
+
+	_, err = resolveRecordsDeletes(map[int32]int64{4: 0}, waterMarks)
+	assert.True(t, errors.Is(err, ErrInvalidTopicRequest))
+}
@@ -0,0 +1,209 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+)
+
+// DeserializationReportRequest describes how many of a topic's most recent messages shall be diagnosed
+type DeserializationReportRequest struct {
+	TopicName  string
+	SampleSize int64
+
+	// RedactPreviews removes the byte level previews of all payloads, e.g. because masking rules apply to the
+	// requester
+	RedactPreviews bool
+
+	// Decrypter decrypts keys and values before they are diagnosed, like they are decrypted when messages are
+	// listed. It's nil if the topic isn't encrypted or the requester may not see decrypted messages.
+	Decrypter *decryption.TopicDecrypter
+}
+
+// DeserializationReport explains why the sampled keys and values of a topic have been detected as the type they are
+// rendered as
+type DeserializationReport struct {
+	TopicName       string `json:"topicName"`
+	SampledMessages int    `json:"sampledMessages"`
+
+	// IsIncomplete is true if fewer messages than requested have been sampled before the request timed out
+	IsIncomplete     bool `json:"isIncomplete"`
+	PreviewsRedacted bool `json:"previewsRedacted"`
+
+	Key      DeserializationSummary    `json:"key"`
+	Value    DeserializationSummary    `json:"value"`
+	Messages []SampledMessageDiagnosis `json:"messages"`
+}
+
+// DeserializationSummary aggregates the diagnoses of all sampled keys or values
+type DeserializationSummary struct {
+	// DetectedTypes is a map of: detected type -> number of payloads. Null and empty payloads are counted as null
+	// and empty.
+	DetectedTypes map[string]int `json:"detectedTypes"`
+
+	// Hints are the distinct hints of all payloads
+	Hints []string `json:"hints"`
+}
+
+// SampledMessageDiagnosis is the diagnosis of a single sampled message
+type SampledMessageDiagnosis struct {
+	PartitionID int32                  `json:"partitionId"`
+	Offset      int64                  `json:"offset"`
+	Key         kafka.PayloadDiagnosis `json:"key"`
+	Value       kafka.PayloadDiagnosis `json:"value"`
+}
+
+// GetDeserializationReport samples the most recent messages of the topic and diagnoses the deserialization of their
+// keys and values. If the context is cancelled while sampling, the report is based on the messages consumed so far.
+func (s *Service) GetDeserializationReport(ctx context.Context, req DeserializationReportRequest) (*DeserializationReport, error) {
+	messages, isIncomplete, err := s.sampleRawMessages(ctx, req.TopicName, req.SampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeserializationReport{
+		TopicName:        req.TopicName,
+		SampledMessages:  len(messages),
+		IsIncomplete:     isIncomplete,
+		PreviewsRedacted: req.RedactPreviews,
+		Messages:         make([]SampledMessageDiagnosis, len(messages)),
+	}
+	for i, msg := range messages {
+		key, value, keyErr, valueErr := kafka.DecryptMessage(ctx, req.Decrypter, msg)
+		diagnosis := SampledMessageDiagnosis{
+			PartitionID: msg.Partition,
+			Offset:      msg.Offset,
+			Key:         kafka.DiagnosePayload(key, req.TopicName, proto.RecordKey, s.protoSvc),
+			Value:       kafka.DiagnosePayload(value, req.TopicName, proto.RecordValue, s.protoSvc),
+		}
+		if keyErr != nil {
+			diagnosis.Key.Hints = append(diagnosis.Key.Hints, decryptionFailedHint(keyErr))
+		}
+		if valueErr != nil {
+			diagnosis.Value.Hints = append(diagnosis.Value.Hints, decryptionFailedHint(valueErr))
+		}
+		if req.RedactPreviews {
+			diagnosis.Key.Preview, diagnosis.Value.Preview = "", ""
+		}
+		report.Messages[i] = diagnosis
+	}
+	report.Key = summarizeDiagnoses(report.Messages, func(m SampledMessageDiagnosis) kafka.PayloadDiagnosis { return m.Key })
+	report.Value = summarizeDiagnoses(report.Messages, func(m SampledMessageDiagnosis) kafka.PayloadDiagnosis { return m.Value })
+
+	return report, nil
+}
+
+func decryptionFailedHint(err error) string {
+	return fmt.Sprintf("could not be decrypted and has been diagnosed as consumed: %v", err)
+}
+
+// sampleRawMessages consumes up to sampleSize of the most recent messages, spread across all partitions like the
+// messages of a search for recent messages. The result is ordered by partition id and offset.
+func (s *Service) sampleRawMessages(ctx context.Context, topicName string, sampleSize int64) ([]*sarama.ConsumerMessage, bool, error) {
+	partitionIDs, err := s.kafkaSvc.ListPartitions(topicName)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get partitions: %w", topicAdminError(err))
+	}
+	marks, err := s.kafkaSvc.WaterMarks(topicName, partitionIDs)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get watermarks: %w", topicAdminError(err))
+	}
+	listReq := &ListMessageRequest{
+		TopicName:    topicName,
+		PartitionID:  partitionsAll,
+		StartOffset:  StartOffsetRecent,
+		MessageCount: sampleSize,
+	}
+	requests := calculateConsumeRequests(listReq, marks, nil)
+
+	consumer, err := s.kafkaSvc.NewConsumer(sarama.ReadUncommitted)
+	if err != nil {
+		return nil, false, fmt.Errorf("couldn't create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	messages := make([]*sarama.ConsumerMessage, 0, sampleSize)
+	for _, req := range orderByPriority(requests, nil) {
+		sampled, err := consumeRawMessages(ctx, consumer, topicName, req)
+		messages = append(messages, sampled...)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, false, err
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Partition != messages[j].Partition {
+			return messages[i].Partition < messages[j].Partition
+		}
+		return messages[i].Offset < messages[j].Offset
+	})
+
+	return messages, ctx.Err() != nil, nil
+}
+
+// consumeRawMessages returns the messages of a partition consume request. The messages consumed so far are returned
+// along with the error if the context is cancelled.
+func consumeRawMessages(ctx context.Context, consumer sarama.Consumer, topicName string, req *kafka.PartitionConsumeRequest) ([]*sarama.ConsumerMessage, error) {
+	pc, err := consumer.ConsumePartition(topicName, req.PartitionID, req.StartOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume partition '%v': %w", req.PartitionID, err)
+	}
+	defer pc.Close()
+
+	messages := make([]*sarama.ConsumerMessage, 0, req.MaxMessageCount)
+	for int64(len(messages)) < req.MaxMessageCount {
+		select {
+		case msg := <-pc.Messages():
+			if msg == nil {
+				return messages, nil
+			}
+			messages = append(messages, msg)
+			if msg.Offset >= req.EndOffset {
+				return messages, nil
+			}
+		case consumerErr := <-pc.Errors():
+			if consumerErr == nil {
+				return messages, nil
+			}
+			return messages, fmt.Errorf("failed to consume partition '%v': %w", req.PartitionID, consumerErr.Err)
+		case <-ctx.Done():
+			return messages, ctx.Err()
+		}
+	}
+
+	return messages, nil
+}
+
+func summarizeDiagnoses(messages []SampledMessageDiagnosis, payload func(SampledMessageDiagnosis) kafka.PayloadDiagnosis) DeserializationSummary {
+	summary := DeserializationSummary{
+		DetectedTypes: make(map[string]int),
+		Hints:         make([]string, 0),
+	}
+	seenHints := make(map[string]bool)
+	for _, m := range messages {
+		d := payload(m)
+		switch {
+		case d.IsNull:
+			summary.DetectedTypes["null"]++
+		case d.Size == 0:
+			summary.DetectedTypes["empty"]++
+		default:
+			summary.DetectedTypes[d.DetectedType]++
+		}
+		for _, hint := range d.Hints {
+			if !seenHints[hint] {
+				seenHints[hint] = true
+				summary.Hints = append(summary.Hints, hint)
+			}
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,35 @@
+package owl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDeserializationReportDecryptsMessages(t *testing.T) {
+	svc, decrypter := newEncryptedTopicService(t)
+
+	report, err := svc.GetDeserializationReport(context.Background(), DeserializationReportRequest{TopicName: "payments", SampleSize: 10, Decrypter: decrypter})
+	require.NoError(t, err)
+	require.Equal(t, 1, report.SampledMessages)
+	assert.Equal(t, map[string]int{"json": 1}, report.Value.DetectedTypes)
+
+	// Without the decrypter the ciphertext is diagnosed
+	report, err = svc.GetDeserializationReport(context.Background(), DeserializationReportRequest{TopicName: "payments", SampleSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"binary": 1}, report.Value.DetectedTypes)
+
+	// Payloads which can't be decrypted are diagnosed as consumed and explain why
+	_, err = svc.kafkaSvc.Produce(kafka.ProduceRecord{TopicName: "payments", Partitioner: kafka.PartitionerManual, Value: []byte(`{"amount": 1}`)}, kafka.ProduceOptions{})
+	require.NoError(t, err)
+	report, err = svc.GetDeserializationReport(context.Background(), DeserializationReportRequest{TopicName: "payments", SampleSize: 10, Decrypter: decrypter})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.SampledMessages)
+	assert.Equal(t, map[string]int{"json": 2}, report.Value.DetectedTypes)
+	assert.Empty(t, report.Messages[0].Value.Hints)
+	require.Len(t, report.Messages[1].Value.Hints, 1)
+	assert.Contains(t, report.Messages[1].Value.Hints[0], "could not be decrypted")
+}
@@ -0,0 +1,272 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// BrokerDiskUsage is the summed size of all replicas stored on a broker. Error is set if the broker didn't describe
+// its log dirs, in which case the sizes are 0.
+type BrokerDiskUsage struct {
+	BrokerID     int32         `json:"brokerId"`
+	TotalBytes   int64         `json:"totalBytes"`
+	ReplicaCount int           `json:"replicaCount"`
+	LogDirs      []LogDirUsage `json:"logDirs"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// LogDirUsage is the summed size of all replicas stored in a single log dir of a broker
+type LogDirUsage struct {
+	Path         string `json:"path"`
+	TotalBytes   int64  `json:"totalBytes"`
+	ReplicaCount int    `json:"replicaCount"`
+	Error        string `json:"error,omitempty"`
+}
+
+// TopicDiskUsage is the summed size of all replicas of a topic, which is roughly its size times the replication
+// factor
+type TopicDiskUsage struct {
+	TopicName     string          `json:"topicName"`
+	TotalBytes    int64           `json:"totalBytes"`
+	ReplicaCount  int             `json:"replicaCount"`
+	BytesByBroker map[int32]int64 `json:"bytesByBroker"`
+}
+
+// TopicSizes lists the disk usage of all topics, the largest first. The sizes are incomplete if brokers failed to
+// describe their log dirs.
+type TopicSizes struct {
+	Topics        []TopicDiskUsage `json:"topics"`
+	FailedBrokers []int32          `json:"failedBrokers"`
+}
+
+// PartitionDiskUsage shows where the replicas of a partition are placed and how much space they take
+type PartitionDiskUsage struct {
+	PartitionID int32              `json:"partitionId"`
+	Replicas    []ReplicaDiskUsage `json:"replicas"`
+}
+
+// ReplicaDiskUsage is a single replica in a broker's log dir. Future replicas are being moved into the log dir and
+// will replace the current replica once they have caught up.
+type ReplicaDiskUsage struct {
+	BrokerID  int32  `json:"brokerId"`
+	LogDir    string `json:"logDir"`
+	SizeBytes int64  `json:"sizeBytes"`
+	OffsetLag int64  `json:"offsetLag"`
+	IsFuture  bool   `json:"isFuture"`
+}
+
+// TopicDiskUsageDetails is the replica placement and size of each partition of a topic
+type TopicDiskUsageDetails struct {
+	TopicName     string               `json:"topicName"`
+	TotalBytes    int64                `json:"totalBytes"`
+	Partitions    []PartitionDiskUsage `json:"partitions"`
+	FailedBrokers []int32              `json:"failedBrokers"`
+}
+
+// replicaLogDir is a single replica as reported by a broker's log dir description
+type replicaLogDir struct {
+	BrokerID    int32
+	Path        string
+	TopicName   string
+	PartitionID int32
+	sarama.DescribeLogDirsResponsePartition
+}
+
+// logDirsDescription holds all described replicas along with the errors of brokers and their log dirs
+type logDirsDescription struct {
+	BrokerIDs    []int32
+	Replicas     []replicaLogDir
+	BrokerErrors map[int32]string
+	LogDirErrors map[int32]map[string]string // By broker id and path
+}
+
+// failedBrokers returns the sorted ids of all brokers which failed to describe their log dirs
+func (d *logDirsDescription) failedBrokers() []int32 {
+	failed := make([]int32, 0, len(d.BrokerErrors))
+	for brokerID := range d.BrokerErrors {
+		failed = append(failed, brokerID)
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i] < failed[j] })
+	return failed
+}
+
+// describeLogDirs describes the log dirs of all brokers. Brokers which are part of the cluster but did not respond
+// are reported as failed, as the Kafka service drops their responses.
+func (s *Service) describeLogDirs() (*logDirsDescription, error) {
+	metadata, err := s.kafkaSvc.DescribeCluster()
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster: %w", err)
+	}
+	responses := s.kafkaSvc.DescribeLogDirs()
+
+	desc := &logDirsDescription{
+		BrokerIDs:    make([]int32, 0, len(metadata.Brokers)),
+		Replicas:     make([]replicaLogDir, 0),
+		BrokerErrors: make(map[int32]string),
+		LogDirErrors: make(map[int32]map[string]string),
+	}
+	for _, broker := range metadata.Brokers {
+		desc.BrokerIDs = append(desc.BrokerIDs, broker.ID)
+		response, ok := responses[broker.ID]
+		if !ok {
+			desc.BrokerErrors[broker.ID] = "broker did not describe its log dirs"
+			continue
+		}
+		if response.Err != nil {
+			desc.BrokerErrors[broker.ID] = response.Err.Error()
+			continue
+		}
+
+		for _, dir := range response.LogDirs {
+			if dir.ErrorCode != sarama.ErrNoError {
+				if desc.LogDirErrors[broker.ID] == nil {
+					desc.LogDirErrors[broker.ID] = make(map[string]string)
+				}
+				desc.LogDirErrors[broker.ID][dir.Path] = dir.ErrorCode.Error()
+				continue
+			}
+			for _, topic := range dir.Topics {
+				for _, partition := range topic.Partitions {
+					desc.Replicas = append(desc.Replicas, replicaLogDir{
+						BrokerID:                         broker.ID,
+						Path:                             dir.Path,
+						TopicName:                        topic.Topic,
+						PartitionID:                      partition.PartitionID,
+						DescribeLogDirsResponsePartition: partition,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(desc.BrokerIDs, func(i, j int) bool { return desc.BrokerIDs[i] < desc.BrokerIDs[j] })
+
+	return desc, nil
+}
+
+// GetBrokerDiskUsage returns the summed size of all replicas on each broker and each of its log dirs
+func (s *Service) GetBrokerDiskUsage(ctx context.Context) ([]BrokerDiskUsage, error) {
+	desc, err := s.describeLogDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	usageByBroker := make(map[int32]*BrokerDiskUsage, len(desc.BrokerIDs))
+	logDirsByBroker := make(map[int32]map[string]*LogDirUsage, len(desc.BrokerIDs))
+	for _, brokerID := range desc.BrokerIDs {
+		usageByBroker[brokerID] = &BrokerDiskUsage{BrokerID: brokerID, Error: desc.BrokerErrors[brokerID]}
+		logDirsByBroker[brokerID] = make(map[string]*LogDirUsage)
+		for path, dirErr := range desc.LogDirErrors[brokerID] {
+			logDirsByBroker[brokerID][path] = &LogDirUsage{Path: path, Error: dirErr}
+		}
+	}
+	for _, replica := range desc.Replicas {
+		dir, ok := logDirsByBroker[replica.BrokerID][replica.Path]
+		if !ok {
+			dir = &LogDirUsage{Path: replica.Path}
+			logDirsByBroker[replica.BrokerID][replica.Path] = dir
+		}
+		dir.TotalBytes += replica.Size
+		dir.ReplicaCount++
+		usageByBroker[replica.BrokerID].TotalBytes += replica.Size
+		usageByBroker[replica.BrokerID].ReplicaCount++
+	}
+
+	res := make([]BrokerDiskUsage, len(desc.BrokerIDs))
+	for i, brokerID := range desc.BrokerIDs {
+		usage := usageByBroker[brokerID]
+		usage.LogDirs = make([]LogDirUsage, 0, len(logDirsByBroker[brokerID]))
+		for _, dir := range logDirsByBroker[brokerID] {
+			usage.LogDirs = append(usage.LogDirs, *dir)
+		}
+		sort.Slice(usage.LogDirs, func(i, j int) bool { return usage.LogDirs[i].Path < usage.LogDirs[j].Path })
+		res[i] = *usage
+	}
+
+	return res, nil
+}
+
+// GetTopicSizes returns the summed size of all replicas of each topic, the largest topic first
+func (s *Service) GetTopicSizes(ctx context.Context) (*TopicSizes, error) {
+	desc, err := s.describeLogDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	usageByTopic := make(map[string]*TopicDiskUsage)
+	for _, replica := range desc.Replicas {
+		usage, ok := usageByTopic[replica.TopicName]
+		if !ok {
+			usage = &TopicDiskUsage{TopicName: replica.TopicName, BytesByBroker: make(map[int32]int64)}
+			usageByTopic[replica.TopicName] = usage
+		}
+		usage.TotalBytes += replica.Size
+		usage.ReplicaCount++
+		usage.BytesByBroker[replica.BrokerID] += replica.Size
+	}
+
+	topics := make([]TopicDiskUsage, 0, len(usageByTopic))
+	for _, usage := range usageByTopic {
+		topics = append(topics, *usage)
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		if topics[i].TotalBytes != topics[j].TotalBytes {
+			return topics[i].TotalBytes > topics[j].TotalBytes
+		}
+		return topics[i].TopicName < topics[j].TopicName
+	})
+
+	return &TopicSizes{Topics: topics, FailedBrokers: desc.failedBrokers()}, nil
+}
+
+// GetTopicDiskUsage returns the placement and size of all replicas of the given topic's partitions
+func (s *Service) GetTopicDiskUsage(ctx context.Context, topicName string) (*TopicDiskUsageDetails, error) {
+	partitionIDs, err := s.kafkaSvc.ListPartitions(topicName)
+	if err != nil {
+		return nil, topicAdminError(err)
+	}
+	desc, err := s.describeLogDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	replicasByPartition := make(map[int32][]ReplicaDiskUsage, len(partitionIDs))
+	for _, partitionID := range partitionIDs {
+		replicasByPartition[partitionID] = make([]ReplicaDiskUsage, 0)
+	}
+	totalBytes := int64(0)
+	for _, replica := range desc.Replicas {
+		if replica.TopicName != topicName {
+			continue
+		}
+		replicasByPartition[replica.PartitionID] = append(replicasByPartition[replica.PartitionID], ReplicaDiskUsage{
+			BrokerID:  replica.BrokerID,
+			LogDir:    replica.Path,
+			SizeBytes: replica.Size,
+			OffsetLag: replica.OffsetLag,
+			IsFuture:  replica.IsTemporary,
+		})
+		totalBytes += replica.Size
+	}
+
+	partitions := make([]PartitionDiskUsage, 0, len(replicasByPartition))
+	for partitionID, replicas := range replicasByPartition {
+		sort.Slice(replicas, func(i, j int) bool {
+			if replicas[i].BrokerID != replicas[j].BrokerID {
+				return replicas[i].BrokerID < replicas[j].BrokerID
+			}
+			return replicas[i].LogDir < replicas[j].LogDir
+		})
+		partitions = append(partitions, PartitionDiskUsage{PartitionID: partitionID, Replicas: replicas})
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].PartitionID < partitions[j].PartitionID })
+
+	return &TopicDiskUsageDetails{
+		TopicName:     topicName,
+		TotalBytes:    totalBytes,
+		Partitions:    partitions,
+		FailedBrokers: desc.failedBrokers(),
+	}, nil
+}
@@ -0,0 +1,56 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDiskUsage(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	fakeCfg.Brokers = 3
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 2, 2, nil, false))
+	for i := 0; i < 3; i++ {
+		_, err := cluster.Produce(kafka.ProduceRecord{TopicName: "orders", PartitionID: 1, Partitioner: kafka.PartitionerManual, Value: []byte("value")}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+	svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	ctx := context.Background()
+
+	details, err := svc.GetTopicDiskUsage(ctx, "orders")
+	require.NoError(t, err)
+	require.Len(t, details.Partitions, 2)
+	assert.Len(t, details.Partitions[0].Replicas, 2)
+	assert.Equal(t, int64(0), details.Partitions[0].Replicas[0].SizeBytes)
+	assert.Equal(t, int64(15), details.Partitions[1].Replicas[0].SizeBytes)
+	assert.Equal(t, int64(30), details.TotalBytes)
+	assert.Empty(t, details.FailedBrokers)
+
+	sizes, err := svc.GetTopicSizes(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, sizes.Topics)
+	assert.Equal(t, "orders", sizes.Topics[0].TopicName)
+	assert.Equal(t, 4, sizes.Topics[0].ReplicaCount)
+
+	brokers, err := svc.GetBrokerDiskUsage(ctx)
+	require.NoError(t, err)
+	require.Len(t, brokers, 3)
+	total := int64(0)
+	for _, broker := range brokers {
+		require.Len(t, broker.LogDirs, 1)
+		assert.Equal(t, broker.TotalBytes, broker.LogDirs[0].TotalBytes)
+		total += broker.TotalBytes
+	}
+	assert.Equal(t, int64(30), total)
+
+	_, err = svc.GetTopicDiskUsage(ctx, "missing")
+	assert.True(t, errors.Is(err, ErrTopicNotFound))
+}
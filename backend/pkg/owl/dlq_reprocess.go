@@ -0,0 +1,195 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/dlq"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// ErrReprocessPreviewOutdated is returned if the source topic to which DLQ messages would be reprocessed differs
+// from the source topic the requester has confirmed after the dry run
+var ErrReprocessPreviewOutdated = errors.New("source topic differs from the confirmed preview")
+
+// MessagePosition is the partition and offset of a single message
+type MessagePosition struct {
+	PartitionID int32 `json:"partitionId"`
+	Offset      int64 `json:"offset"`
+}
+
+// ReprocessRequest produces selected messages of a dead letter queue (DLQ) to the topic they originate from, so that
+// they are consumed again. All messages must originate from the same topic.
+type ReprocessRequest struct {
+	DLQTopic       string
+	Messages       []MessagePosition
+	Resolver       *dlq.Resolver
+	IsolationLevel sarama.IsolationLevel
+
+	// PreservePartitions produces each message to the partition it originates from, otherwise the partition is
+	// chosen by the hash of its key
+	PreservePartitions bool
+
+	// Transactional produces the messages in chunks of ChunkSize messages, each within a single transaction, so that
+	// a failed chunk doesn't leave partially reprocessed messages behind. Otherwise messages are produced one at a
+	// time. The chunk size defaults to DefaultReplayChunkSize.
+	Transactional bool
+	ChunkSize     int
+
+	// DryRun only resolves the origins of the messages. Otherwise ConfirmedSourceTopic must match the resolved source
+	// topic, so that messages are only produced to the topic the requester has seen in the preview.
+	DryRun               bool
+	ConfirmedSourceTopic string
+}
+
+// ReprocessResponse describes the origins of the selected messages and whether they have been reprocessed
+type ReprocessResponse struct {
+	DLQTopic    string `json:"dlqTopic"`
+	SourceTopic string `json:"sourceTopic"`
+	DryRun      bool   `json:"dryRun"`
+
+	Messages            []ReprocessedMessage `json:"messages"`
+	ReprocessedMessages int64                `json:"reprocessedMessages"`
+
+	// Errors are set if a message or a transactional chunk couldn't be produced, which stops reprocessing. The
+	// messages which have been reprocessed until then are not removed.
+	Errors []string `json:"errors"`
+}
+
+// ReprocessedMessage is a selected DLQ message along with its origin and the message it has been reprocessed as
+type ReprocessedMessage struct {
+	PartitionID int32       `json:"partitionId"`
+	Offset      int64       `json:"offset"`
+	Origin      *dlq.Origin `json:"origin"`
+
+	// Reprocessed is the partition and offset of the produced message, nil if it hasn't been produced
+	Reprocessed *kafka.ProduceResult `json:"reprocessed"`
+}
+
+// ReprocessDLQMessages resolves the origin of each selected DLQ message and produces the messages with their original
+// keys, values and headers to the source topic, unless the request is a dry run. Provenance headers refer to the
+// DLQ message. Messages are produced in the given order, either one at a time or in transactional chunks. The first
+// failure stops reprocessing.
+func (s *Service) ReprocessDLQMessages(ctx context.Context, req ReprocessRequest) (*ReprocessResponse, error) {
+	if len(req.Messages) == 0 {
+		return nil, fmt.Errorf("%w: no messages have been selected", ErrInvalidTopicRequest)
+	}
+
+	rawMessages := make([]*sarama.ConsumerMessage, len(req.Messages))
+	res := &ReprocessResponse{
+		DLQTopic: req.DLQTopic,
+		DryRun:   req.DryRun,
+		Messages: make([]ReprocessedMessage, len(req.Messages)),
+		Errors:   make([]string, 0),
+	}
+	for i, position := range req.Messages {
+		raw, err := s.GetRawMessage(ctx, GetMessageRequest{
+			TopicName:      req.DLQTopic,
+			PartitionID:    position.PartitionID,
+			Offset:         position.Offset,
+			IsolationLevel: req.IsolationLevel,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message (partition: '%v', offset: '%v'): %w", position.PartitionID, position.Offset, err)
+		}
+		origin := req.Resolver.Origin(req.DLQTopic, raw.Headers)
+		if origin == nil {
+			return nil, fmt.Errorf("%w: origin of message (partition: '%v', offset: '%v') is unknown", ErrInvalidTopicRequest, position.PartitionID, position.Offset)
+		}
+		if res.SourceTopic == "" {
+			res.SourceTopic = origin.Topic
+		}
+		if origin.Topic != res.SourceTopic {
+			return nil, fmt.Errorf("%w: selected messages originate from different topics ('%v' and '%v')", ErrInvalidTopicRequest, res.SourceTopic, origin.Topic)
+		}
+
+		rawMessages[i] = raw
+		res.Messages[i] = ReprocessedMessage{PartitionID: position.PartitionID, Offset: position.Offset, Origin: origin}
+	}
+	if res.SourceTopic == req.DLQTopic {
+		return nil, fmt.Errorf("%w: messages originate from the DLQ itself", ErrInvalidTopicRequest)
+	}
+
+	// Partitions are checked in dry runs as well, so that the preview fails rather than the confirmed request
+	partitionIDs, err := s.kafkaSvc.ListPartitions(res.SourceTopic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions of source topic '%v': %w", res.SourceTopic, topicAdminError(err))
+	}
+	if req.PreservePartitions {
+		partitions := make(map[int32]struct{}, len(partitionIDs))
+		for _, partitionID := range partitionIDs {
+			partitions[partitionID] = struct{}{}
+		}
+		for _, msg := range res.Messages {
+			if _, exists := partitions[msg.Origin.PartitionID]; !exists {
+				return nil, fmt.Errorf("%w: original partition of message (partition: '%v', offset: '%v') is unknown or doesn't exist, partitions can't be preserved",
+					ErrInvalidTopicRequest, msg.PartitionID, msg.Offset)
+			}
+		}
+	}
+	if req.DryRun {
+		return res, nil
+	}
+
+	if req.ConfirmedSourceTopic != res.SourceTopic {
+		return nil, fmt.Errorf("%w: messages would be reprocessed to '%v', but '%v' has been confirmed",
+			ErrReprocessPreviewOutdated, res.SourceTopic, req.ConfirmedSourceTopic)
+	}
+	reprocessedAt := time.Now()
+	records := make([]kafka.ProduceRecord, len(rawMessages))
+	for i, raw := range rawMessages {
+		records[i] = replayRecord(raw, req.DLQTopic, res.SourceTopic, reprocessedAt)
+		if req.PreservePartitions {
+			records[i].Partitioner = kafka.PartitionerManual
+			records[i].PartitionID = res.Messages[i].Origin.PartitionID
+		}
+	}
+
+	chunkSize := 1
+	if req.Transactional {
+		chunkSize = req.ChunkSize
+		if chunkSize <= 0 {
+			chunkSize = DefaultReplayChunkSize
+		}
+	}
+	transactionalID := fmt.Sprintf("kowl-reprocess-%v-%v", req.DLQTopic, reprocessedAt.UnixNano())
+	for start := 0; start < len(records); start += chunkSize {
+		if ctx.Err() != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("reprocessing has been cancelled: %v", ctx.Err()))
+			break
+		}
+		end := start + chunkSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		first := rawMessages[start]
+		var produced []kafka.ProduceResult
+		if req.Transactional {
+			var err error
+			produced, err = s.kafkaSvc.ProduceTransaction(transactionalID, records[start:end])
+			if err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("failed to reprocess chunk of %v messages (first: partition '%v', offset '%v'), the chunk has been aborted: %v",
+					end-start, first.Partition, first.Offset, err))
+				break
+			}
+		} else {
+			result, err := s.kafkaSvc.Produce(records[start], kafka.ProduceOptions{})
+			if err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("failed to reprocess message (partition: '%v', offset: '%v'): %v", first.Partition, first.Offset, err))
+				break
+			}
+			produced = []kafka.ProduceResult{*result}
+		}
+
+		for i := range produced {
+			res.Messages[start+i].Reprocessed = &produced[i]
+			res.ReprocessedMessages++
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,161 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/dlq"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReprocessDLQMessages(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 2, 1, nil, false))
+	require.NoError(t, cluster.CreateTopic("payments", 1, 1, nil, false))
+	require.NoError(t, cluster.CreateTopic("orders.dlq", 1, 1, nil, false))
+	svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+	dlqCfg := dlq.Config{}
+	dlqCfg.SetDefaults()
+	resolver := dlq.NewResolver(dlqCfg)
+
+	// Offsets 0 and 1 originate from partition 1 of orders, offset 2 has been moved from payments by Kafka Connect
+	origins := []sarama.RecordHeader{
+		{Key: []byte("__connect.errors.topic"), Value: []byte("orders")},
+		{Key: []byte("__connect.errors.topic"), Value: []byte("orders")},
+		{Key: []byte("__connect.errors.topic"), Value: []byte("payments")},
+	}
+	for i, origin := range origins {
+		_, err := cluster.Produce(kafka.ProduceRecord{
+			TopicName:   "orders.dlq",
+			Partitioner: kafka.PartitionerManual,
+			Key:         []byte("order-" + strconv.Itoa(i)),
+			Value:       []byte(`{"id": ` + strconv.Itoa(i) + `}`),
+			Headers: []sarama.RecordHeader{
+				origin,
+				{Key: []byte("__connect.errors.partition"), Value: []byte("1")},
+				{Key: []byte("__connect.errors.offset"), Value: []byte(strconv.Itoa(100 + i))},
+			},
+		}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+
+	req := ReprocessRequest{
+		DLQTopic:           "orders.dlq",
+		Messages:           []MessagePosition{{PartitionID: 0, Offset: 1}, {PartitionID: 0, Offset: 0}},
+		Resolver:           resolver,
+		PreservePartitions: true,
+		DryRun:             true,
+	}
+
+	// A dry run only resolves the origins
+	res, err := svc.ReprocessDLQMessages(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "orders", res.SourceTopic)
+	assert.Equal(t, int64(0), res.ReprocessedMessages)
+	require.Len(t, res.Messages, 2)
+	assert.Equal(t, &dlq.Origin{Topic: "orders", PartitionID: 1, Offset: 101, Source: "connect"}, res.Messages[0].Origin)
+	assert.Nil(t, res.Messages[0].Reprocessed)
+
+	// Messages are only reprocessed to the confirmed source topic
+	req.DryRun = false
+	req.ConfirmedSourceTopic = "payments"
+	_, err = svc.ReprocessDLQMessages(context.Background(), req)
+	assert.True(t, errors.Is(err, ErrReprocessPreviewOutdated))
+
+	req.ConfirmedSourceTopic = "orders"
+	res, err = svc.ReprocessDLQMessages(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, int64(2), res.ReprocessedMessages)
+	assert.Equal(t, &kafka.ProduceResult{PartitionID: 1, Offset: 0}, res.Messages[0].Reprocessed)
+	assert.Equal(t, &kafka.ProduceResult{PartitionID: 1, Offset: 1}, res.Messages[1].Reprocessed)
+
+	reprocessed, err := svc.CollectMessages(context.Background(), ListMessageRequest{
+		TopicName:       "orders",
+		PartitionID:     1,
+		StartOffset:     StartOffsetOldest,
+		MessageCount:    100,
+		Predicate:       func(_ *kafka.TopicMessage, _ time.Time) bool { return true },
+		KeepRawMessages: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, reprocessed.Messages, 2)
+	raw := reprocessed.Messages[0].Raw
+	assert.Equal(t, "order-1", string(raw.Key))
+	headers := make(map[string]string)
+	for _, h := range raw.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	assert.Equal(t, "orders.dlq", headers[ReplayHeaderSourceTopic])
+	assert.Equal(t, "1", headers[ReplayHeaderSourceOffset])
+
+	// All selected messages must originate from the same topic
+	req.Messages = []MessagePosition{{PartitionID: 0, Offset: 0}, {PartitionID: 0, Offset: 2}}
+	_, err = svc.ReprocessDLQMessages(context.Background(), req)
+	assert.True(t, errors.Is(err, ErrInvalidTopicRequest))
+}
+
+func TestReprocessDLQMessagesTransactional(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 1, 1, nil, false))
+	require.NoError(t, cluster.CreateTopic("orders.dlq", 1, 1, nil, false))
+	dlqCfg := dlq.Config{}
+	dlqCfg.SetDefaults()
+
+	positions := make([]MessagePosition, 5)
+	for i := range positions {
+		_, err := cluster.Produce(kafka.ProduceRecord{
+			TopicName:   "orders.dlq",
+			Partitioner: kafka.PartitionerManual,
+			Value:       []byte(`{"id": ` + strconv.Itoa(i) + `}`),
+			Headers:     []sarama.RecordHeader{{Key: []byte("__connect.errors.topic"), Value: []byte("orders")}},
+		}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+		positions[i] = MessagePosition{PartitionID: 0, Offset: int64(i)}
+	}
+	req := ReprocessRequest{
+		DLQTopic:             "orders.dlq",
+		Messages:             positions,
+		Resolver:             dlq.NewResolver(dlqCfg),
+		Transactional:        true,
+		ChunkSize:            2,
+		ConfirmedSourceTopic: "orders",
+	}
+
+	// The second of three chunks fails, so that only the messages of the first chunk have been reprocessed
+	failing := &failingTransactionCluster{Cluster: cluster, failAt: 2}
+	svc := NewService(failing, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	res, err := svc.ReprocessDLQMessages(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, res.Errors, 1)
+	assert.Contains(t, res.Errors[0], "the chunk has been aborted")
+	assert.Equal(t, int64(2), res.ReprocessedMessages)
+	assert.Equal(t, &kafka.ProduceResult{PartitionID: 0, Offset: 1}, res.Messages[1].Reprocessed)
+	assert.Nil(t, res.Messages[2].Reprocessed)
+	marks, err := cluster.WaterMarks("orders", []int32{0})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), marks[0].High)
+
+	failing = &failingTransactionCluster{Cluster: cluster}
+	svc = NewService(failing, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	res, err = svc.ReprocessDLQMessages(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, int64(5), res.ReprocessedMessages)
+	assert.Equal(t, 3, failing.transactions)
+	assert.Equal(t, &kafka.ProduceResult{PartitionID: 0, Offset: 6}, res.Messages[4].Reprocessed)
+}
@@ -0,0 +1,64 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+)
+
+// FilterTypings is a TypeScript declaration of the filter function arguments for a specific topic
+type FilterTypings struct {
+	TopicName  string `json:"topicName"`
+	SampleSize int    `json:"sampleSize"`
+	Typings    string `json:"typings"`
+}
+
+// GetFilterTypings infers the types of keys, values and headers from the most recent messages of a topic and
+// returns a TypeScript declaration of the filter function arguments, so that the code editor can offer autocompletion.
+// Messages are decrypted and masked like they are when searching. The decrypter and the masker may be nil.
+func (s *Service) GetFilterTypings(ctx context.Context, topicName string, sampleSize uint16, decrypter *decryption.TopicDecrypter, masker *masking.Masker) (*FilterTypings, error) {
+	collector := &messageCollector{mutex: &sync.Mutex{}}
+	listReq := ListMessageRequest{
+		TopicName:    topicName,
+		PartitionID:  partitionsAll,
+		StartOffset:  StartOffsetRecent,
+		MessageCount: int64(sampleSize),
+		Decrypter:    decrypter,
+		Masker:       masker,
+	}
+	err := s.ListMessages(ctx, listReq, collector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sample messages: %w", err)
+	}
+	if len(collector.errors) > 0 {
+		return nil, fmt.Errorf("failed to fetch sample messages: %v", collector.errors[0])
+	}
+
+	// Messages which can not be parsed are skipped, as they would fail in the filter function as well
+	samples := filter.TypingSamples{}
+	for _, msg := range collector.messages {
+		if key, err := msg.Key.Parse(); err == nil {
+			samples.Keys = append(samples.Keys, key)
+		}
+		if value, err := msg.Value.Parse(); err == nil {
+			samples.Values = append(samples.Values, value)
+		}
+		headers := make(map[string]interface{}, len(msg.Headers))
+		for _, h := range msg.Headers {
+			if headerValue, err := h.Value.Parse(); err == nil {
+				headers[h.Key] = headerValue
+			}
+		}
+		samples.Headers = append(samples.Headers, headers)
+	}
+
+	return &FilterTypings{
+		TopicName:  topicName,
+		SampleSize: len(collector.messages),
+		Typings:    filter.GenerateTypings(samples),
+	}, nil
+}
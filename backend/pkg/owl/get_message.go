@@ -0,0 +1,127 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+	"github.com/cloudhut/kowl/backend/pkg/rendering"
+)
+
+// ErrMessageNotFound is returned if no message exists at the requested offset, e.g. because it has been deleted by
+// retention or compaction, or because the offset has not been written yet
+var ErrMessageNotFound = errors.New("message not found")
+
+// GetMessageRequest identifies a single message by its partition and offset
+type GetMessageRequest struct {
+	TopicName   string
+	PartitionID int32
+	Offset      int64
+
+	IsolationLevel sarama.IsolationLevel
+	Decrypter      *decryption.TopicDecrypter
+	Masker         *masking.Masker
+	Renderer       *rendering.Renderer
+	BinaryEncoding kafka.BinaryEncoding
+}
+
+// GetMessage returns the deserialized message at the requested offset
+func (s *Service) GetMessage(ctx context.Context, req GetMessageRequest) (*kafka.TopicMessage, error) {
+	if err := s.checkMessageOffset(req.TopicName, req.PartitionID, req.Offset); err != nil {
+		return nil, err
+	}
+
+	collector := &messageCollector{mutex: &sync.Mutex{}}
+	listReq := ListMessageRequest{
+		TopicName:      req.TopicName,
+		PartitionID:    req.PartitionID,
+		StartOffset:    req.Offset,
+		MessageCount:   1,
+		IsolationLevel: req.IsolationLevel,
+		Decrypter:      req.Decrypter,
+		Masker:         req.Masker,
+		Renderer:       req.Renderer,
+		BinaryEncoding: req.BinaryEncoding,
+	}
+	err := s.ListMessages(ctx, listReq, collector)
+	if err != nil {
+		return nil, err
+	}
+	if len(collector.errors) > 0 {
+		return nil, fmt.Errorf("failed to consume message: %v", collector.errors[0])
+	}
+
+	// The consumer continues with the next message if the requested offset has been compacted or belongs to a
+	// transaction marker or an aborted transaction
+	if len(collector.messages) == 0 || collector.messages[0].Offset != req.Offset {
+		return nil, errNoMessageAtOffset(req.Offset)
+	}
+
+	return collector.messages[0], nil
+}
+
+// GetRawMessage returns the message at the requested offset as it has been produced, without deserializing,
+// decrypting, masking or rendering it. Decrypter, Masker, Renderer and BinaryEncoding of the request are ignored.
+func (s *Service) GetRawMessage(ctx context.Context, req GetMessageRequest) (*sarama.ConsumerMessage, error) {
+	if err := s.checkMessageOffset(req.TopicName, req.PartitionID, req.Offset); err != nil {
+		return nil, err
+	}
+
+	consumer, err := s.kafkaSvc.NewConsumer(req.IsolationLevel)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create consumer: %w", err)
+	}
+	defer consumer.Close()
+	pc, err := consumer.ConsumePartition(req.TopicName, req.PartitionID, req.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume partition: %w", err)
+	}
+	defer pc.Close()
+
+	select {
+	case msg := <-pc.Messages():
+		if msg == nil || msg.Offset != req.Offset {
+			return nil, errNoMessageAtOffset(req.Offset)
+		}
+		return msg, nil
+	case consumerErr := <-pc.Errors():
+		if consumerErr == nil {
+			return nil, errNoMessageAtOffset(req.Offset)
+		}
+		return nil, fmt.Errorf("failed to consume message: %w", consumerErr.Err)
+	case <-ctx.Done():
+		// The consumer waits for the next message if the offset is the last one of the partition and belongs to a
+		// transaction marker or an aborted transaction
+		return nil, fmt.Errorf("no message has been consumed before the request timed out: %w", ctx.Err())
+	}
+}
+
+// checkMessageOffset returns ErrMessageNotFound if the offset is outside of the partition's water marks
+func (s *Service) checkMessageOffset(topicName string, partitionID int32, offset int64) error {
+	waterMarks, err := s.kafkaSvc.WaterMarks(topicName, []int32{partitionID})
+	if err != nil {
+		return fmt.Errorf("failed to get water marks: %w", topicAdminError(err))
+	}
+	mark, exists := waterMarks[partitionID]
+	if !exists {
+		return fmt.Errorf("%w: partition '%v' does not exist", ErrTopicNotFound, partitionID)
+	}
+	if offset < mark.Low {
+		return fmt.Errorf("%w: offset '%v' is below the low water mark '%v', the message has been deleted", ErrMessageNotFound, offset, mark.Low)
+	}
+	if offset >= mark.High {
+		return fmt.Errorf("%w: offset '%v' is not below the high water mark '%v'", ErrMessageNotFound, offset, mark.High)
+	}
+	return nil
+}
+
+// errNoMessageAtOffset is returned if the consumer has skipped the requested offset, because it has been compacted
+// or belongs to a transaction marker or an aborted transaction
+func errNoMessageAtOffset(offset int64) error {
+	return fmt.Errorf("%w: no message exists at offset '%v', it may have been removed by compaction", ErrMessageNotFound, offset)
+}
@@ -0,0 +1,153 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+const offsetsTopicName = "__consumer_offsets"
+
+// GroupCoordinatorOverview shows how consumer groups are distributed across the group coordinators and how healthy
+// the partitions of the offsets topic are. All groups whose offsets topic partition is unhealthy or whose
+// coordinator is overloaded are affected together.
+type GroupCoordinatorOverview struct {
+	OffsetsTopicPartitionCount int                      `json:"offsetsTopicPartitionCount"`
+	Brokers                    []BrokerCoordination     `json:"brokers"`
+	Partitions                 []OffsetsTopicPartition  `json:"partitions"`
+	Groups                     []GroupCoordinatorLookup `json:"groups"`
+}
+
+// BrokerCoordination is the number of groups and offsets topic partitions a broker is responsible for
+type BrokerCoordination struct {
+	BrokerID       int32 `json:"brokerId"`
+	GroupCount     int   `json:"groupCount"`
+	PartitionCount int   `json:"partitionCount"` // Number of offsets topic partitions the broker is leader for
+}
+
+// OffsetsTopicPartition describes the health of a single __consumer_offsets partition
+type OffsetsTopicPartition struct {
+	PartitionID       int32   `json:"partitionId"`
+	LeaderID          int32   `json:"leaderId"` // -1 if the partition has no leader
+	Replicas          []int32 `json:"replicas"`
+	InSyncReplicas    []int32 `json:"inSyncReplicas"`
+	IsUnderReplicated bool    `json:"isUnderReplicated"`
+	IsOffline         bool    `json:"isOffline"`
+	GroupCount        int     `json:"groupCount"`
+}
+
+// GroupCoordinatorLookup is the offsets topic partition and coordinator of a single consumer group
+type GroupCoordinatorLookup struct {
+	GroupID       string `json:"groupId"`
+	PartitionID   int32  `json:"partitionId"`
+	CoordinatorID int32  `json:"coordinatorId"` // -1 if the partition has no leader
+}
+
+// GetGroupCoordinatorOverview returns the group coordinator distribution along with the health of the offsets topic
+// partitions. The coordinator of a group is the leader of the offsets topic partition the group id hashes to.
+func (s *Service) GetGroupCoordinatorOverview(ctx context.Context) (*GroupCoordinatorOverview, error) {
+	metadata, err := s.kafkaSvc.DescribeCluster()
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster: %w", err)
+	}
+	topics, err := s.kafkaSvc.ListTopics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+	groupIDs, err := s.kafkaSvc.ListConsumerGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer groups: %w", err)
+	}
+
+	brokers := make(map[int32]*BrokerCoordination, len(metadata.Brokers))
+	for _, b := range metadata.Brokers {
+		brokers[b.ID] = &BrokerCoordination{BrokerID: b.ID}
+	}
+
+	partitions := make([]OffsetsTopicPartition, 0)
+	for _, topic := range topics {
+		if topic.Name != offsetsTopicName {
+			continue
+		}
+		for _, p := range topic.Partitions {
+			partitions = append(partitions, OffsetsTopicPartition{
+				PartitionID:       p.ID,
+				LeaderID:          p.Leader,
+				Replicas:          p.Replicas,
+				InSyncReplicas:    p.Isr,
+				IsUnderReplicated: len(p.Isr) < len(p.Replicas),
+				IsOffline:         p.Leader < 0,
+			})
+			if b, ok := brokers[p.Leader]; ok {
+				b.PartitionCount++
+			}
+		}
+	}
+	if len(partitions) == 0 {
+		// The offsets topic is created lazily once the first group commits offsets
+		return &GroupCoordinatorOverview{
+			Brokers:    sortedBrokerCoordinations(brokers),
+			Partitions: partitions,
+			Groups:     []GroupCoordinatorLookup{},
+		}, nil
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].PartitionID < partitions[j].PartitionID })
+
+	groups := make([]GroupCoordinatorLookup, len(groupIDs))
+	for i, groupID := range groupIDs {
+		partitionID := offsetsTopicPartitionFor(groupID, len(partitions))
+		partition := &partitions[partitionID]
+		partition.GroupCount++
+		if b, ok := brokers[partition.LeaderID]; ok {
+			b.GroupCount++
+		}
+		groups[i] = GroupCoordinatorLookup{
+			GroupID:       groupID,
+			PartitionID:   partitionID,
+			CoordinatorID: partition.LeaderID,
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].GroupID < groups[j].GroupID })
+
+	return &GroupCoordinatorOverview{
+		OffsetsTopicPartitionCount: len(partitions),
+		Brokers:                    sortedBrokerCoordinations(brokers),
+		Partitions:                 partitions,
+		Groups:                     groups,
+	}, nil
+}
+
+func sortedBrokerCoordinations(brokers map[int32]*BrokerCoordination) []BrokerCoordination {
+	res := make([]BrokerCoordination, 0, len(brokers))
+	for _, b := range brokers {
+		res = append(res, *b)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].BrokerID < res[j].BrokerID })
+
+	return res
+}
+
+// offsetsTopicPartitionFor returns the offsets topic partition of a group the same way the brokers compute it
+// (abs(groupId.hashCode) % partitionCount), using Java's String.hashCode over the UTF-16 code units.
+func offsetsTopicPartitionFor(groupID string, partitionCount int) int32 {
+	hash := int32(0)
+	for _, r := range groupID {
+		if r >= 0x10000 {
+			// Characters outside the basic multilingual plane are encoded as surrogate pair in UTF-16
+			r -= 0x10000
+			hash = 31*hash + (0xD800 + (r >> 10))
+			hash = 31*hash + (0xDC00 + (r & 0x3FF))
+			continue
+		}
+		hash = 31*hash + r
+	}
+
+	// Kafka's Utils.abs maps MinInt32 to 0 instead of overflowing
+	if hash == -2147483648 {
+		hash = 0
+	} else if hash < 0 {
+		hash = -hash
+	}
+
+	return hash % int32(partitionCount)
+}
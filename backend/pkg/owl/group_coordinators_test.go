@@ -0,0 +1,23 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetsTopicPartitionFor(t *testing.T) {
+	tt := []struct {
+		groupID  string
+		expected int32
+	}{
+		{"hello", 22},             // hashCode 99162322
+		{"polygenelubricants", 0}, // hashCode is MinInt32
+		{"my-consumer-group", 13}, // hashCode 1513705513
+		{"grüße-😀", 27},           // Contains a surrogate pair in UTF-16
+	}
+
+	for _, table := range tt {
+		assert.Equal(t, table.expected, offsetsTopicPartitionFor(table.groupID, 50), "unexpected partition for group '%v'", table.groupID)
+	}
+}
@@ -0,0 +1,154 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// ErrInvalidLeaderElectionRequest is returned if the selected topics or partitions don't exist
+var ErrInvalidLeaderElectionRequest = errors.New("invalid leader election request")
+
+// Outcomes of a preferred leader election for a single partition
+const (
+	LeaderElectionElected   = "elected"
+	LeaderElectionNotNeeded = "notNeeded"
+	LeaderElectionFailed    = "failed"
+)
+
+// LeaderElectionRequest selects the partitions whose leadership is moved to their preferred replica. All partitions
+// of a topic are selected if its partition list is empty, all partitions of the cluster if no topic is selected.
+type LeaderElectionRequest struct {
+	Topics map[string][]int32
+}
+
+// PartitionLeaderElection is the outcome of the election for a single partition. The preferred leader is the first
+// replica, it becomes the leader if the election succeeds.
+type PartitionLeaderElection struct {
+	TopicName       string `json:"topicName"`
+	PartitionID     int32  `json:"partitionId"`
+	PreviousLeader  int32  `json:"previousLeader"`
+	CurrentLeader   int32  `json:"currentLeader"`
+	PreferredLeader int32  `json:"preferredLeader"`
+	Status          string `json:"status"`
+	Error           string `json:"error,omitempty"`
+}
+
+// LeaderElectionReport lists all partitions whose leader changed or whose election failed. Partitions which already
+// had their preferred leader are only counted.
+type LeaderElectionReport struct {
+	ElectedCount   int                       `json:"electedCount"`
+	NotNeededCount int                       `json:"notNeededCount"`
+	FailedCount    int                       `json:"failedCount"`
+	Partitions     []PartitionLeaderElection `json:"partitions"`
+}
+
+// ElectPreferredLeaders moves the leadership of the selected partitions back to their preferred replicas, which
+// rebalances the load after brokers have been restarted, and reports which partitions changed their leader.
+func (s *Service) ElectPreferredLeaders(ctx context.Context, req LeaderElectionRequest) (*LeaderElectionReport, error) {
+	topics, err := s.kafkaSvc.ListTopics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+	partitionsByTopic := make(map[string]map[int32]*sarama.PartitionMetadata, len(topics))
+	for _, topic := range topics {
+		partitionsByTopic[topic.Name] = make(map[int32]*sarama.PartitionMetadata, len(topic.Partitions))
+		for _, partition := range topic.Partitions {
+			partitionsByTopic[topic.Name][partition.ID] = partition
+		}
+	}
+
+	topicPartitions, err := electionTopicPartitions(req, partitionsByTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.kafkaSvc.ElectPreferredLeaders(topicPartitions)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLeaderElectionReport(results, partitionsByTopic), nil
+}
+
+// electionTopicPartitions resolves the requested selection into the partitions to elect. It returns nil if the whole
+// cluster has been selected.
+func electionTopicPartitions(req LeaderElectionRequest, partitionsByTopic map[string]map[int32]*sarama.PartitionMetadata) (map[string][]int32, error) {
+	if len(req.Topics) == 0 {
+		return nil, nil
+	}
+
+	topicPartitions := make(map[string][]int32, len(req.Topics))
+	for topicName, partitionIDs := range req.Topics {
+		partitions, ok := partitionsByTopic[topicName]
+		if !ok {
+			return nil, fmt.Errorf("%w: topic '%v' does not exist", ErrInvalidLeaderElectionRequest, topicName)
+		}
+		if len(partitionIDs) == 0 {
+			for partitionID := range partitions {
+				partitionIDs = append(partitionIDs, partitionID)
+			}
+			sort.Slice(partitionIDs, func(i, j int) bool { return partitionIDs[i] < partitionIDs[j] })
+		}
+		for _, partitionID := range partitionIDs {
+			if _, ok := partitions[partitionID]; !ok {
+				return nil, fmt.Errorf("%w: partition '%v' of topic '%v' does not exist", ErrInvalidLeaderElectionRequest, partitionID, topicName)
+			}
+		}
+		topicPartitions[topicName] = partitionIDs
+	}
+
+	return topicPartitions, nil
+}
+
+// newLeaderElectionReport combines the election results with the leaders before the election
+func newLeaderElectionReport(results []kafka.PartitionElectionResult, partitionsByTopic map[string]map[int32]*sarama.PartitionMetadata) *LeaderElectionReport {
+	report := &LeaderElectionReport{Partitions: make([]PartitionLeaderElection, 0)}
+	for _, result := range results {
+		election := PartitionLeaderElection{
+			TopicName:       result.TopicName,
+			PartitionID:     result.PartitionID,
+			PreviousLeader:  -1,
+			CurrentLeader:   -1,
+			PreferredLeader: -1,
+		}
+		if partition, ok := partitionsByTopic[result.TopicName][result.PartitionID]; ok {
+			election.PreviousLeader = partition.Leader
+			election.CurrentLeader = partition.Leader
+			if len(partition.Replicas) > 0 {
+				election.PreferredLeader = partition.Replicas[0]
+			}
+		}
+
+		switch result.Err {
+		case sarama.ErrNoError:
+			election.Status = LeaderElectionElected
+			election.CurrentLeader = election.PreferredLeader
+			report.ElectedCount++
+		case kafka.ErrElectionNotNeeded:
+			report.NotNeededCount++
+			continue
+		default:
+			election.Status = LeaderElectionFailed
+			election.Error = result.Err.Error()
+			if result.ErrMessage != "" {
+				election.Error = result.ErrMessage
+			}
+			report.FailedCount++
+		}
+		report.Partitions = append(report.Partitions, election)
+	}
+	sort.Slice(report.Partitions, func(i, j int) bool {
+		a, b := report.Partitions[i], report.Partitions[j]
+		if a.TopicName != b.TopicName {
+			return a.TopicName < b.TopicName
+		}
+		return a.PartitionID < b.PartitionID
+	})
+
+	return report
+}
@@ -0,0 +1,57 @@
+package owl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElectionTopicPartitions(t *testing.T) {
+	partitionsByTopic := map[string]map[int32]*sarama.PartitionMetadata{
+		"orders": {0: {ID: 0}, 1: {ID: 1}, 2: {ID: 2}},
+	}
+
+	topicPartitions, err := electionTopicPartitions(LeaderElectionRequest{}, partitionsByTopic)
+	require.NoError(t, err)
+	assert.Nil(t, topicPartitions)
+
+	topicPartitions, err = electionTopicPartitions(LeaderElectionRequest{Topics: map[string][]int32{"orders": nil}}, partitionsByTopic)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int32{"orders": {0, 1, 2}}, topicPartitions)
+
+	_, err = electionTopicPartitions(LeaderElectionRequest{Topics: map[string][]int32{"orders": {3}}}, partitionsByTopic)
+	assert.True(t, errors.Is(err, ErrInvalidLeaderElectionRequest))
+	_, err = electionTopicPartitions(LeaderElectionRequest{Topics: map[string][]int32{"payments": nil}}, partitionsByTopic)
+	assert.True(t, errors.Is(err, ErrInvalidLeaderElectionRequest))
+}
+
+func TestNewLeaderElectionReport(t *testing.T) {
+	partitionsByTopic := map[string]map[int32]*sarama.PartitionMetadata{
+		"orders": {
+			0: {ID: 0, Leader: 2, Replicas: []int32{1, 2}},
+			1: {ID: 1, Leader: 2, Replicas: []int32{2, 1}},
+			2: {ID: 2, Leader: 1, Replicas: []int32{3, 1}},
+		},
+	}
+	results := []kafka.PartitionElectionResult{
+		{TopicName: "orders", PartitionID: 2, Err: kafka.ErrEligibleLeadersNotAvailable},
+		{TopicName: "orders", PartitionID: 1, Err: kafka.ErrElectionNotNeeded},
+		{TopicName: "orders", PartitionID: 0, Err: sarama.ErrNoError},
+	}
+
+	report := newLeaderElectionReport(results, partitionsByTopic)
+	assert.Equal(t, 1, report.ElectedCount)
+	assert.Equal(t, 1, report.NotNeededCount)
+	assert.Equal(t, 1, report.FailedCount)
+	require.Len(t, report.Partitions, 2)
+	assert.Equal(t, PartitionLeaderElection{
+		TopicName: "orders", PartitionID: 0, PreviousLeader: 2, CurrentLeader: 1, PreferredLeader: 1, Status: LeaderElectionElected,
+	}, report.Partitions[0])
+	assert.Equal(t, LeaderElectionFailed, report.Partitions[1].Status)
+	assert.Equal(t, int32(1), report.Partitions[1].CurrentLeader)
+	assert.NotEmpty(t, report.Partitions[1].Error)
+}
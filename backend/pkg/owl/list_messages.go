@@ -3,12 +3,18 @@ package owl
 import (
 	"context"
 	"fmt"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
 	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+	"github.com/cloudhut/kowl/backend/pkg/rendering"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -16,21 +22,92 @@ const (
 )
 
 const (
-	// Recent = High water mark - number of results
+	// Recent = High water mark - number of results. Filtered searches read the partitions backwards instead and
+	// return the newest matching messages first.
 	StartOffsetRecent int64 = -1
 	// Oldest = Low water mark / oldest offset
 	StartOffsetOldest int64 = -2
 	// Newest = High water mark / Live tail
 	StartOffsetNewest int64 = -3
+	// Timestamp = First offset whose timestamp is at or after the request's StartTimestamp
+	StartOffsetTimestamp int64 = -4
 )
 
 // ListMessageRequest carries all filter, sort and cancellation options for fetching messages from Kafka
 type ListMessageRequest struct {
 	TopicName             string
 	PartitionID           int32 // -1 for all partitions
-	StartOffset           int64 // -1 for recent (high - n), -2 for oldest offset, -3 for newest offset
-	MessageCount          uint16
+	StartOffset           int64 // -1 for recent (high - n), -2 for oldest offset, -3 for newest offset, -4 for timestamp
+	MessageCount          int64
 	FilterInterpreterCode string
+	FilterLimits          filter.Limits
+	FilterBudget          *filter.Budget
+
+	// Predicate is the native part of a search query, which is evaluated before the filter code. Nil matches all.
+	Predicate kafka.MessagePredicate
+
+	// StartTimestamp and EndTimestamp (unix milliseconds) bound a time-range search, they are only considered if
+	// StartOffset is StartOffsetTimestamp. An EndTimestamp of 0 means the search is not bounded by time.
+	StartTimestamp int64
+	EndTimestamp   int64
+
+	// LiveTail keeps consuming from the newest offsets until the context is cancelled. MessageCount and StartOffset
+	// are ignored in this mode. Matching messages exceeding MaxMessagesPerSecond are dropped (0 means no limit).
+	LiveTail             bool
+	MaxMessagesPerSecond int
+
+	// OrderByTimestamp merges the messages of all partitions into a single stream which is ordered by timestamp,
+	// instead of forwarding them in the order they arrive. It's ignored in live tail mode.
+	OrderByTimestamp bool
+
+	// SortKeys return the first MessageCount messages of the scanned range in this order, rather than the first
+	// messages which have been found. Messages are kept in a bounded heap until the scan has completed. SortKeys take
+	// precedence over OrderByTimestamp and are ignored in live tail mode.
+	SortKeys []SortKey
+
+	// IsolationLevel ReadCommitted excludes messages of aborted transactions and of transactions which are still open.
+	// Transaction markers are never returned, regardless of the isolation level.
+	IsolationLevel sarama.IsolationLevel
+
+	// CanonicalJSON controls how JSON based keys, values and headers are rendered
+	CanonicalJSON kafka.CanonicalJSONOptions
+
+	// BinaryEncoding controls how binary keys, values and headers are returned, base64 if empty
+	BinaryEncoding kafka.BinaryEncoding
+
+	// KeyDecoder and ValueDecoder force the decoder of keys and values, zero values detect their types automatically
+	KeyDecoder   kafka.DecoderOverride
+	ValueDecoder kafka.DecoderOverride
+
+	// MaxValueBytes truncates the rendered values of returned messages, 0 returns values in full
+	MaxValueBytes int
+
+	// Decrypter decrypts keys and values of encrypted topics before their type is detected, nil if messages must
+	// be returned as they have been consumed
+	Decrypter *decryption.TopicDecrypter
+
+	// Masker replaces sensitive fields before messages are filtered and returned, nil if nothing must be masked
+	Masker *masking.Masker
+
+	// Renderer transforms fields of messages which have passed the filter into a human readable form, nil if no
+	// rendering rule applies to the topic
+	Renderer *rendering.Renderer
+
+	// KeyFilter only returns messages with exactly this (serialized) key, nil returns messages with any key
+	KeyFilter []byte
+
+	// EndOffset is the last offset which is consumed from each partition, nil consumes up to the high watermarks.
+	// It's ignored in live tail mode.
+	EndOffset *int64
+
+	// KeepRawMessages attaches the consumed records to the returned messages
+	KeepRawMessages bool
+}
+
+// IsFiltered reports whether messages are filtered by code or a query predicate, in which case an unknown number of
+// messages has to be consumed to find the requested number of messages
+func (l *ListMessageRequest) IsFiltered() bool {
+	return l.FilterInterpreterCode != "" || l.Predicate != nil
 }
 
 // ListMessageResponse returns the requested kafka messages along with some metadata about the operation
@@ -47,12 +124,13 @@ type ListMessageResponse struct {
 func (s *Service) ListMessages(ctx context.Context, listReq ListMessageRequest, progress kafka.IListMessagesProgress) error {
 	start := time.Now()
 	logger := s.logger.With(zap.String("topic", listReq.TopicName))
+	s.metrics.OnListMessagesRequest(listReq.LiveTail)
 
 	progress.OnPhase("Create Topic Consumer")
 	// We must create a new Consumer for every request,
 	// because each consumer can only consume every topic+partition once at the same time
 	// which means that concurrent requests will not work with one shared Consumer
-	consumer, err := sarama.NewConsumerFromClient(s.kafkaSvc.Client)
+	consumer, err := s.kafkaSvc.NewConsumer(listReq.IsolationLevel)
 	if err != nil {
 		return fmt.Errorf("couldn't create consumer: %w", err)
 	}
@@ -83,10 +161,22 @@ func (s *Service) ListMessages(ctx context.Context, listReq ListMessageRequest,
 	}
 
 	progress.OnPhase("Get Watermarks")
+	// The leaders are compared once the scan has completed, so that we can annotate results which may be
+	// inconsistent. This is best effort, the scan is not affected if the leaders can't be fetched.
+	var leaders map[int32]int32
+	if !listReq.LiveTail {
+		leaders, err = s.kafkaSvc.PartitionLeaders(listReq.TopicName, partitionIDs)
+		if err != nil {
+			logger.Debug("failed to get partition leaders, inconsistencies can't be detected", zap.Error(err))
+		}
+	}
 	marks, err := s.kafkaSvc.WaterMarks(listReq.TopicName, partitionIDs)
 	if err != nil {
 		return fmt.Errorf("failed to get watermarks: %w", err)
 	}
+	tracker := newConsistencyTracker(progress)
+	progress = tracker
+	activity := s.activity.observe(listReq.TopicName, marks)
 
 	progress.OnPhase("Setup consumer agents")
 
@@ -96,10 +186,47 @@ func (s *Service) ListMessages(ctx context.Context, listReq ListMessageRequest,
 	startedWorkers := 0
 
 	// Get partition consume request by calculating start and end offsets for each partition
-	consumeRequests := calculateConsumeRequests(&listReq, marks)
+	var consumeRequests map[int32]*kafka.PartitionConsumeRequest
+	consumeMarks := marks
+	if listReq.EndOffset != nil && !listReq.LiveTail {
+		consumeMarks = limitWaterMarks(marks, *listReq.EndOffset)
+	}
+	if listReq.StartOffset == StartOffsetTimestamp {
+		progress.OnPhase("Resolve timestamps to offsets")
+		startOffsets, err := s.kafkaSvc.OffsetsForTimes(listReq.TopicName, partitionIDs, listReq.StartTimestamp)
+		if err != nil {
+			return fmt.Errorf("failed to get offsets for start timestamp: %w", err)
+		}
+		consumeRequests = calculateTimeRangeConsumeRequests(&listReq, consumeMarks, startOffsets)
+	} else {
+		consumeRequests = calculateConsumeRequests(&listReq, consumeMarks, activity)
+	}
+	progress.OnConsumeRequests(consumeRequests)
 	childCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	for _, req := range consumeRequests {
+	go s.refreshWaterMarks(childCtx, listReq.TopicName, consumeRequests, progress, logger)
+	if isTailRead(&listReq) && len(listReq.SortKeys) == 0 {
+		// Tail reads return the newest messages of all partitions, newest first
+		listReq.SortKeys = tailReadSortKeys
+	}
+	isSorted := len(listReq.SortKeys) > 0 && !listReq.LiveTail
+	isOrdered := listReq.OrderByTimestamp && !listReq.LiveTail && !isSorted
+	partitionChs := make([]<-chan *kafka.TopicMessage, 0, len(consumeRequests))
+
+	// Partition consumers are started by priority. If far fewer messages are requested than could be consumed, only
+	// a few consumers run at the same time and the remaining partitions are skipped once enough messages have been
+	// found. The ordered merge requires all partitions to be consumed at the same time, sorted searches never skip
+	// partitions. Otherwise at most the configured number of consumers run at the same time.
+	pendingRequests := orderByPriority(consumeRequests, activity)
+	maxRunningWorkers := len(pendingRequests)
+	if !isOrdered && !isSorted && isPrioritizedScan(&listReq, consumeRequests) {
+		maxRunningWorkers = prioritizedScanConcurrency
+		logger.Debug("scheduling partition consumers by priority", zap.Int("partitions", len(pendingRequests)))
+	}
+	if limit := s.limits.MaxConcurrentPartitions; limit > 0 && limit < maxRunningWorkers && !isOrdered && !listReq.LiveTail {
+		maxRunningWorkers = limit
+	}
+	startConsumer := func(req *kafka.PartitionConsumeRequest) {
 		pConsumer := kafka.PartitionConsumer{
 			Logger: logger.With(zap.Int32("partition_id", req.PartitionID)),
 
@@ -110,10 +237,43 @@ func (s *Service) ListMessages(ctx context.Context, listReq ListMessageRequest,
 			Consumer:              consumer,
 			TopicName:             listReq.TopicName,
 			Req:                   req,
+			ProtoSvc:              s.protoSvc,
+			SchemaSvc:             s.schemaSvc,
+			KeyDecoder:            listReq.KeyDecoder,
+			ValueDecoder:          listReq.ValueDecoder,
 			FilterInterpreterCode: listReq.FilterInterpreterCode,
+			FilterLimits:          listReq.FilterLimits,
+			FilterBudget:          listReq.FilterBudget,
+			InFlightBytes:         s.limits.InFlightBytes,
+			Predicate:             listReq.Predicate,
+			CanonicalJSON:         listReq.CanonicalJSON,
+			BinaryEncoding:        listReq.BinaryEncoding,
+			MaxValueBytes:         listReq.MaxValueBytes,
+			Decrypter:             listReq.Decrypter,
+			Masker:                listReq.Masker,
+			Renderer:              listReq.Renderer,
+			KeyFilter:             listReq.KeyFilter,
+			KeepRawMessages:       listReq.KeepRawMessages,
+			Metrics:               s.metrics,
 		}
 		startedWorkers++
-		go pConsumer.Run(childCtx)
+		if !isOrdered {
+			go pConsumer.Run(childCtx)
+			return
+		}
+
+		// Each partition gets its own channel, so that the merge knows which partitions have completed
+		partitionCh := make(chan *kafka.TopicMessage, orderedMergeBufferSize)
+		pConsumer.MessageCh = partitionCh
+		partitionChs = append(partitionChs, partitionCh)
+		go func(pConsumer kafka.PartitionConsumer) {
+			defer close(partitionCh)
+			pConsumer.Run(childCtx)
+		}(pConsumer)
+	}
+	for len(pendingRequests) > 0 && startedWorkers < maxRunningWorkers {
+		startConsumer(pendingRequests[0])
+		pendingRequests = pendingRequests[1:]
 	}
 
 	completedWorkers := 0
@@ -122,24 +282,56 @@ func (s *Service) ListMessages(ctx context.Context, listReq ListMessageRequest,
 
 	progress.OnPhase("Consuming messages")
 
-	go func(ch <-chan *kafka.TopicMessage, req ListMessageRequest) {
-		messagesToFetch := req.MessageCount
-		for {
-			select {
-			case msg := <-ch:
+	// The collector must have forwarded all messages before we report completion
+	collectorDone := make(chan struct{})
+	if listReq.LiveTail {
+		go func() {
+			defer close(collectorDone)
+			forwardLiveTailMessages(childCtx, messageCh, listReq.MaxMessagesPerSecond, progress)
+		}()
+	} else if isSorted {
+		// The sorted messages are forwarded once all partition consumers are done or the request is cancelled
+		go func() {
+			defer close(collectorDone)
+			collectSorted(childCtx, messageCh, listReq.SortKeys, listReq.MessageCount, progress)
+		}()
+	} else if isOrdered {
+		// The merge must only stop if the request is cancelled, buffered messages are still forwarded after all
+		// partition consumers are done
+		go func() {
+			defer close(collectorDone)
+			messagesToFetch := listReq.MessageCount
+			mergeByTimestamp(ctx, partitionChs, func(msg *kafka.TopicMessage) bool {
 				messagesToFetch--
 				progress.OnMessage(msg)
-
-				// When we are done quit routine and cancel context so that all partition consumers will stop as well
 				if messagesToFetch == 0 {
 					cancel()
+					return false
+				}
+				return true
+			})
+		}()
+	} else {
+		go func(ch <-chan *kafka.TopicMessage, req ListMessageRequest) {
+			defer close(collectorDone)
+			messagesToFetch := req.MessageCount
+			for {
+				select {
+				case msg := <-ch:
+					messagesToFetch--
+					progress.OnMessage(msg)
+
+					// When we are done quit routine and cancel context so that all partition consumers will stop as well
+					if messagesToFetch == 0 {
+						cancel()
+						return
+					}
+				case <-childCtx.Done():
 					return
 				}
-			case <-ctx.Done():
-				return
 			}
-		}
-	}(messageCh, listReq)
+		}(messageCh, listReq)
+	}
 
 	// Priority list of actions
 	// since we need to process cases by their priority, we must check them individually and
@@ -172,7 +364,14 @@ Loop:
 			}
 		}
 
-		if completedWorkers == startedWorkers {
+		// 4. Start pending consumers unless enough messages have been found already
+		isCollectorDone := childCtx.Err() != nil
+		for !isCollectorDone && len(pendingRequests) > 0 && startedWorkers-completedWorkers < maxRunningWorkers {
+			startConsumer(pendingRequests[0])
+			pendingRequests = pendingRequests[1:]
+		}
+
+		if completedWorkers == startedWorkers && (len(pendingRequests) == 0 || isCollectorDone) {
 			allWorkersDone = true
 		}
 
@@ -180,6 +379,12 @@ Loop:
 		<-time.After(50 * time.Millisecond)
 	}
 
+	cancel()
+	<-collectorDone
+	if !listReq.LiveTail && !requestCancelled && leaders != nil {
+		progress.OnPhase("Verify partitions")
+		s.reportInconsistencies(listReq.TopicName, partitionState{leaders, marks}, consumeRequests, tracker.consumedOffsets(), progress)
+	}
 	progress.OnComplete(time.Since(start).Milliseconds(), requestCancelled)
 
 	if requestCancelled {
@@ -189,13 +394,111 @@ Loop:
 	return nil
 }
 
+// CollectMessages runs the search like ListMessages, but returns all found messages at once. If the context expires
+// the messages which have been found until then are returned.
+func (s *Service) CollectMessages(ctx context.Context, listReq ListMessageRequest) (*ListMessageResponse, error) {
+	start := time.Now()
+	collector := &messageCollector{mutex: &sync.Mutex{}, messages: make([]*kafka.TopicMessage, 0)}
+	err := s.ListMessages(ctx, listReq, collector)
+	isCancelled := ctx.Err() != nil
+	if err != nil && !isCancelled {
+		return nil, err
+	}
+	if len(collector.errors) > 0 {
+		return nil, fmt.Errorf("failed to consume messages: %v", collector.errors[0])
+	}
+
+	return &ListMessageResponse{
+		ElapsedMs:       float64(time.Since(start).Milliseconds()),
+		FetchedMessages: len(collector.messages),
+		IsCancelled:     isCancelled,
+		Messages:        collector.messages,
+	}, nil
+}
+
+// reportInconsistencies fetches the leaders and watermarks of the consumed partitions again and reports all partitions
+// whose leader or log has changed during the scan
+func (s *Service) reportInconsistencies(topicName string, before partitionState, consumeRequests map[int32]*kafka.PartitionConsumeRequest, consumedOffsets map[int32]int64, progress kafka.IListMessagesProgress) {
+	partitionIDs := make([]int32, 0, len(consumeRequests))
+	for partitionID := range consumeRequests {
+		partitionIDs = append(partitionIDs, partitionID)
+	}
+	if len(partitionIDs) == 0 {
+		return
+	}
+
+	logger := s.logger.With(zap.String("topic", topicName))
+	leaders, err := s.kafkaSvc.PartitionLeaders(topicName, partitionIDs)
+	if err != nil {
+		logger.Debug("failed to get partition leaders after scan", zap.Error(err))
+		return
+	}
+	marks, err := s.kafkaSvc.WaterMarks(topicName, partitionIDs)
+	if err != nil {
+		logger.Debug("failed to get watermarks after scan", zap.Error(err))
+		return
+	}
+
+	consumedMarks := make(map[int32]*kafka.WaterMark, len(partitionIDs))
+	for _, partitionID := range partitionIDs {
+		if mark, ok := before.marks[partitionID]; ok {
+			consumedMarks[partitionID] = mark
+		}
+	}
+	before.marks = consumedMarks
+	for _, inconsistency := range detectInconsistencies(before, partitionState{leaders, marks}, consumedOffsets) {
+		logger.Info("partition has changed during message scan",
+			zap.Int32("partition_id", inconsistency.PartitionID),
+			zap.String("reason", inconsistency.Reason),
+			zap.String("message", inconsistency.Message))
+		progress.OnInconsistency(inconsistency)
+	}
+}
+
+// forwardLiveTailMessages forwards messages until the context is cancelled. Messages exceeding the rate limit are
+// dropped rather than buffered, so that the consumers keep up with the newest messages. The number of dropped
+// messages is reported once per second.
+func forwardLiveTailMessages(ctx context.Context, ch <-chan *kafka.TopicMessage, maxMessagesPerSecond int, progress kafka.IListMessagesProgress) {
+	limit := rate.Inf
+	if maxMessagesPerSecond > 0 {
+		limit = rate.Limit(maxMessagesPerSecond)
+	}
+	limiter := rate.NewLimiter(limit, maxMessagesPerSecond)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	dropped := int64(0)
+	for {
+		select {
+		case msg := <-ch:
+			if !limiter.Allow() {
+				dropped++
+				continue
+			}
+			progress.OnMessage(msg)
+		case <-ticker.C:
+			if dropped > 0 {
+				progress.OnMessagesDropped(dropped)
+				dropped = 0
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // calculateConsumeRequests is supposed to calculate the start and end offsets for each partition consumer, so that
 // we'll end up with ${messageCount} messages in total. To do so we'll take the known low and high watermarks into
-// account. Gaps between low and high watermarks (caused by compactions) will be neglected for now.
-func calculateConsumeRequests(listReq *ListMessageRequest, marks map[int32]*kafka.WaterMark) map[int32]*kafka.PartitionConsumeRequest {
+// account. Gaps between low and high watermarks (caused by compactions) will be neglected for now. If fewer messages
+// than partitions are requested, the messages are assigned to the partitions with the most recent activity.
+func calculateConsumeRequests(listReq *ListMessageRequest, marks map[int32]*kafka.WaterMark, activity map[int32]int64) map[int32]*kafka.PartitionConsumeRequest {
 	requests := make(map[int32]*kafka.PartitionConsumeRequest, len(marks))
 
-	predictableResults := listReq.StartOffset != StartOffsetNewest && listReq.FilterInterpreterCode == ""
+	if listReq.LiveTail {
+		return calculateLiveTailConsumeRequests(marks)
+	}
+
+	predictableResults := listReq.StartOffset != StartOffsetNewest && !listReq.IsFiltered() && listReq.KeyFilter == nil
 	// Init result map
 	notInitialized := int64(-1)
 	for _, mark := range marks {
@@ -237,11 +540,11 @@ func calculateConsumeRequests(listReq *ListMessageRequest, marks map[int32]*kafk
 			if listReq.StartOffset == StartOffsetNewest {
 				p.EndOffset = math.MaxInt64
 			}
-			if listReq.StartOffset == StartOffsetRecent {
-				p.StartOffset = p.HighWaterMark - 1 - int64(listReq.MessageCount)
-				if p.StartOffset < 0 {
-					p.StartOffset = 0
-				}
+			if isTailRead(listReq) {
+				// The partition is read backwards from the end offset, which is faster and more accurate than
+				// guessing the offset from which the newest matching messages can be read forwards
+				p.StartOffset = p.LowWaterMark
+				p.TailWindow = tailWindow(listReq.MessageCount)
 			}
 		}
 
@@ -258,6 +561,7 @@ func calculateConsumeRequests(listReq *ListMessageRequest, marks map[int32]*kafk
 	// Round robin through partitions until either listReq.MaxMessageCount is reached or all partitions are drained
 	remainingMessages := listReq.MessageCount
 	yieldingPartitions := len(requests)
+	orderedRequests := orderByPriority(requests, activity)
 
 	for remainingMessages > 0 {
 		// Check if there is at least one partition which can still return more messages
@@ -265,7 +569,7 @@ func calculateConsumeRequests(listReq *ListMessageRequest, marks map[int32]*kafk
 			break
 		}
 
-		for _, req := range requests {
+		for _, req := range orderedRequests {
 			// If partition is already drained we must ignore it
 			if req.IsDrained || remainingMessages == 0 {
 				continue
@@ -313,3 +617,75 @@ func calculateConsumeRequests(listReq *ListMessageRequest, marks map[int32]*kafk
 
 	return filteredRequests
 }
+
+// limitWaterMarks returns copies of the water marks whose high watermarks don't exceed the end offset, so that no
+// consume request reaches beyond it. Partitions which have no messages up to the end offset are omitted.
+func limitWaterMarks(marks map[int32]*kafka.WaterMark, endOffset int64) map[int32]*kafka.WaterMark {
+	limited := make(map[int32]*kafka.WaterMark, len(marks))
+	for partitionID, mark := range marks {
+		high := mark.High
+		if endOffset+1 < high {
+			high = endOffset + 1
+		}
+		if high <= mark.Low {
+			continue
+		}
+		limited[partitionID] = &kafka.WaterMark{PartitionID: mark.PartitionID, Low: mark.Low, High: high}
+	}
+
+	return limited
+}
+
+// calculateLiveTailConsumeRequests returns consume requests which start at the newest offset of each partition and
+// never end on their own.
+func calculateLiveTailConsumeRequests(marks map[int32]*kafka.WaterMark) map[int32]*kafka.PartitionConsumeRequest {
+	requests := make(map[int32]*kafka.PartitionConsumeRequest, len(marks))
+	for _, mark := range marks {
+		requests[mark.PartitionID] = &kafka.PartitionConsumeRequest{
+			PartitionID:     mark.PartitionID,
+			IsDrained:       false,
+			LowWaterMark:    mark.Low,
+			HighWaterMark:   mark.High,
+			StartOffset:     sarama.OffsetNewest,
+			EndOffset:       math.MaxInt64,
+			MaxMessageCount: math.MaxInt64,
+		}
+	}
+
+	return requests
+}
+
+// calculateTimeRangeConsumeRequests returns consume requests which start at the given (timestamp based) offsets and
+// end at the high watermark or once a message newer than the request's EndTimestamp has been consumed. Partitions
+// without any message at or after the start timestamp are skipped. Because we can't know how many messages each
+// partition holds in the given time range, the results are not balanced across partitions.
+func calculateTimeRangeConsumeRequests(listReq *ListMessageRequest, marks map[int32]*kafka.WaterMark, startOffsets map[int32]int64) map[int32]*kafka.PartitionConsumeRequest {
+	var endTimestamp time.Time
+	if listReq.EndTimestamp > 0 {
+		endTimestamp = time.Unix(0, listReq.EndTimestamp*int64(time.Millisecond))
+	}
+
+	requests := make(map[int32]*kafka.PartitionConsumeRequest, len(marks))
+	for _, mark := range marks {
+		startOffset, ok := startOffsets[mark.PartitionID]
+		if !ok || startOffset < 0 || startOffset >= mark.High {
+			continue
+		}
+		if startOffset < mark.Low {
+			startOffset = mark.Low
+		}
+
+		requests[mark.PartitionID] = &kafka.PartitionConsumeRequest{
+			PartitionID:     mark.PartitionID,
+			IsDrained:       false,
+			LowWaterMark:    mark.Low,
+			HighWaterMark:   mark.High,
+			StartOffset:     startOffset,
+			EndOffset:       mark.High - 1,
+			EndTimestamp:    endTimestamp,
+			MaxMessageCount: int64(listReq.MessageCount),
+		}
+	}
+
+	return requests
+}
@@ -0,0 +1,223 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+	"github.com/cloudhut/kowl/backend/pkg/rendering"
+)
+
+// FindMessagesByKeyRequest describes a lookup of all messages with a given key
+type FindMessagesByKeyRequest struct {
+	TopicName string
+	Key       ProducePayload // Serialized the same way as keys of produced messages
+
+	// Partitioner derives the single partition which has to be scanned from the key, either
+	// kafka.KeyPartitionerMurmur2 or kafka.KeyPartitionerFNV1a. All partitions are scanned if it's empty.
+	Partitioner string
+
+	// MaxResults limits the number of returned messages, the oldest messages (by timestamp) are returned
+	MaxResults int64
+
+	// AllVersions returns all messages with the key even if the topic is compacted. By default only the latest
+	// message of the key is returned for compacted topics, because older messages may be removed at any time.
+	AllVersions bool
+
+	IsolationLevel sarama.IsolationLevel
+	Decrypter      *decryption.TopicDecrypter
+	Masker         *masking.Masker
+	Renderer       *rendering.Renderer
+}
+
+// FindMessagesByKeyResponse contains the messages with the requested key
+type FindMessagesByKeyResponse struct {
+	// LatestOnly is true if the topic is compacted and only the latest message of the key has been returned
+	LatestOnly bool `json:"latestOnly"`
+
+	// PartitionIDs are the partitions which have been scanned
+	PartitionIDs []int32 `json:"partitionIds"`
+
+	Messages        []*kafka.TopicMessage          `json:"messages"`
+	IsTruncated     bool                           `json:"isTruncated"` // More than MaxResults messages have the key
+	Errors          []string                       `json:"errors"`
+	Inconsistencies []kafka.PartitionInconsistency `json:"inconsistencies"`
+}
+
+// FindMessagesByKey scans the topic (or the partition the key is assigned to) for all messages with the requested
+// key. The keys are compared before messages are deserialized, which is much cheaper than a search with filter code.
+func (s *Service) FindMessagesByKey(ctx context.Context, req FindMessagesByKeyRequest) (*FindMessagesByKeyResponse, error) {
+	key, err := s.encodePayload(ctx, req.Key, req.TopicName+"-key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	partitionID := partitionsAll
+	partitionIDs, err := s.kafkaSvc.ListPartitions(req.TopicName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions: %w", err)
+	}
+	if req.Partitioner != "" {
+		partitionID, err = kafka.KeyPartition(req.Partitioner, key, int32(len(partitionIDs)))
+		if err != nil {
+			return nil, err
+		}
+		partitionIDs = []int32{partitionID}
+	}
+
+	latestOnly := false
+	if !req.AllVersions {
+		latestOnly, err = s.isCompactedTopic(req.TopicName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The messages of compacted topics must be consumed up to the high watermark, as the latest one is the one we
+	// are looking for. Only the latest message of each partition is kept while consuming.
+	messageCount := req.MaxResults + 1 // Consume one more message to tell whether the results are truncated
+	if latestOnly {
+		messageCount = math.MaxInt64
+	}
+	listReq := ListMessageRequest{
+		TopicName:        req.TopicName,
+		PartitionID:      partitionID,
+		StartOffset:      StartOffsetOldest,
+		MessageCount:     messageCount,
+		OrderByTimestamp: !latestOnly,
+		IsolationLevel:   req.IsolationLevel,
+		Decrypter:        req.Decrypter,
+		Masker:           req.Masker,
+		Renderer:         req.Renderer,
+		KeyFilter:        key,
+	}
+	collector := &keyLookupCollector{
+		latestOnly:      latestOnly,
+		latest:          make(map[int32]*kafka.TopicMessage),
+		errors:          make([]string, 0),
+		inconsistencies: make([]kafka.PartitionInconsistency, 0),
+	}
+	if err := s.ListMessages(ctx, listReq, collector); err != nil {
+		return nil, err
+	}
+
+	res := &FindMessagesByKeyResponse{
+		LatestOnly:      latestOnly,
+		PartitionIDs:    partitionIDs,
+		Messages:        collector.results(),
+		Errors:          collector.errors,
+		Inconsistencies: collector.inconsistencies,
+	}
+	if !latestOnly && int64(len(res.Messages)) > req.MaxResults {
+		res.Messages = res.Messages[:req.MaxResults]
+		res.IsTruncated = true
+	}
+
+	return res, nil
+}
+
+// isCompactedTopic returns true if compaction is enabled for the topic, regardless of whether old segments are
+// deleted as well
+func (s *Service) isCompactedTopic(topicName string) (bool, error) {
+	configs, err := s.GetTopicConfigs(topicName, []string{"cleanup.policy"})
+	if err != nil {
+		return false, fmt.Errorf("failed to get cleanup policy: %w", err)
+	}
+	if configs == nil {
+		return false, nil
+	}
+	entry := configs.GetConfigEntryByName("cleanup.policy")
+	if entry == nil {
+		return false, nil
+	}
+	for _, policy := range strings.Split(entry.Value, ",") {
+		if strings.TrimSpace(policy) == "compact" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// keyLookupCollector implements kafka.IListMessagesProgress. It either collects all messages or just the latest
+// message of each partition.
+type keyLookupCollector struct {
+	latestOnly bool
+
+	mutex           sync.Mutex
+	messages        []*kafka.TopicMessage
+	latest          map[int32]*kafka.TopicMessage
+	errors          []string
+	inconsistencies []kafka.PartitionInconsistency
+}
+
+func (c *keyLookupCollector) OnPhase(_ string)                                             {}
+func (c *keyLookupCollector) OnConsumeRequests(_ map[int32]*kafka.PartitionConsumeRequest) {}
+func (c *keyLookupCollector) OnMessageConsumed(_ int32, _ int64, _ int64)                  {}
+func (c *keyLookupCollector) OnMessageMatched(_ int32)                                     {}
+func (c *keyLookupCollector) OnMessagesDropped(_ int64)                                    {}
+func (c *keyLookupCollector) OnWaterMarks(_ map[int32]*kafka.WaterMark)                    {}
+func (c *keyLookupCollector) OnComplete(_ int64, _ bool)                                   {}
+
+func (c *keyLookupCollector) OnMessage(msg *kafka.TopicMessage) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.latestOnly {
+		c.messages = append(c.messages, msg)
+		return
+	}
+	// Messages of a partition arrive in offset order
+	c.latest[msg.PartitionID] = msg
+}
+
+func (c *keyLookupCollector) OnInconsistency(inconsistency kafka.PartitionInconsistency) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.inconsistencies = append(c.inconsistencies, inconsistency)
+}
+
+func (c *keyLookupCollector) OnError(msg string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.errors = append(c.errors, msg)
+}
+
+// results returns the collected messages ordered by timestamp, partition and offset. If only the latest messages
+// have been kept, the newest of all partitions is returned. The key is usually found in a single partition only,
+// unless the partitioner or the partition count has changed.
+func (c *keyLookupCollector) results() []*kafka.TopicMessage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	messages := c.messages
+	if c.latestOnly {
+		messages = make([]*kafka.TopicMessage, 0, len(c.latest))
+		for _, msg := range c.latest {
+			messages = append(messages, msg)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		if messages[i].Timestamp != messages[j].Timestamp {
+			return messages[i].Timestamp < messages[j].Timestamp
+		}
+		if messages[i].PartitionID != messages[j].PartitionID {
+			return messages[i].PartitionID < messages[j].PartitionID
+		}
+		return messages[i].Offset < messages[j].Offset
+	})
+	if c.latestOnly && len(messages) > 1 {
+		messages = messages[len(messages)-1:]
+	}
+	if messages == nil {
+		messages = make([]*kafka.TopicMessage, 0)
+	}
+
+	return messages
+}
@@ -0,0 +1,37 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyLookupCollector_Results(t *testing.T) {
+	messages := []*kafka.TopicMessage{
+		{PartitionID: 1, Offset: 3, Timestamp: 20},
+		{PartitionID: 0, Offset: 5, Timestamp: 10},
+		{PartitionID: 1, Offset: 9, Timestamp: 30},
+		{PartitionID: 0, Offset: 7, Timestamp: 30},
+	}
+
+	all := &keyLookupCollector{latest: make(map[int32]*kafka.TopicMessage)}
+	latest := &keyLookupCollector{latestOnly: true, latest: make(map[int32]*kafka.TopicMessage)}
+	for _, msg := range messages {
+		all.OnMessage(msg)
+		latest.OnMessage(msg)
+	}
+
+	results := all.results()
+	require.Len(t, results, 4)
+	assert.Equal(t, []int64{5, 3, 7, 9}, []int64{results[0].Offset, results[1].Offset, results[2].Offset, results[3].Offset})
+
+	// Latest message across partitions by timestamp, ties are broken by the partition id
+	results = latest.results()
+	require.Len(t, results, 1)
+	assert.Equal(t, int32(1), results[0].PartitionID)
+	assert.Equal(t, int64(9), results[0].Offset)
+
+	assert.NotNil(t, (&keyLookupCollector{latest: make(map[int32]*kafka.TopicMessage)}).results())
+}
@@ -0,0 +1,104 @@
+package owl
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// partitionState is a snapshot of the leaders and watermarks of the consumed partitions
+type partitionState struct {
+	leaders map[int32]int32
+	marks   map[int32]*kafka.WaterMark
+}
+
+// consistencyTracker forwards all progress calls and keeps track of the highest consumed offset of each partition,
+// so that we can tell whether consumed messages have been truncated afterwards
+type consistencyTracker struct {
+	kafka.IListMessagesProgress
+
+	mutex       sync.Mutex
+	lastOffsets map[int32]int64
+}
+
+func newConsistencyTracker(progress kafka.IListMessagesProgress) *consistencyTracker {
+	return &consistencyTracker{
+		IListMessagesProgress: progress,
+		lastOffsets:           make(map[int32]int64),
+	}
+}
+
+func (t *consistencyTracker) OnMessageConsumed(partitionID int32, offset int64, size int64) {
+	t.mutex.Lock()
+	if last, ok := t.lastOffsets[partitionID]; !ok || offset > last {
+		t.lastOffsets[partitionID] = offset
+	}
+	t.mutex.Unlock()
+
+	t.IListMessagesProgress.OnMessageConsumed(partitionID, offset, size)
+}
+
+// consumedOffsets returns a copy of the highest consumed offset of each partition
+func (t *consistencyTracker) consumedOffsets() map[int32]int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	offsets := make(map[int32]int64, len(t.lastOffsets))
+	for partitionID, offset := range t.lastOffsets {
+		offsets[partitionID] = offset
+	}
+	return offsets
+}
+
+// detectInconsistencies compares the partition states before and after a scan. Sarama doesn't expose leader epochs,
+// hence fenced reads can't be detected directly. Instead a scan is considered inconsistent if a partition's leader
+// has changed or if the high watermark has dropped below its previous value or below a consumed offset, which
+// happens if the log has been truncated after an unclean leader election.
+func detectInconsistencies(before partitionState, after partitionState, consumedOffsets map[int32]int64) []kafka.PartitionInconsistency {
+	partitionIDs := make([]int32, 0, len(before.marks))
+	for partitionID := range before.marks {
+		partitionIDs = append(partitionIDs, partitionID)
+	}
+	sort.Slice(partitionIDs, func(i, j int) bool { return partitionIDs[i] < partitionIDs[j] })
+
+	inconsistencies := make([]kafka.PartitionInconsistency, 0)
+	for _, partitionID := range partitionIDs {
+		leaderBefore, hasLeaderBefore := before.leaders[partitionID]
+		leaderAfter, hasLeaderAfter := after.leaders[partitionID]
+		if hasLeaderBefore && hasLeaderAfter && leaderBefore != leaderAfter {
+			inconsistencies = append(inconsistencies, kafka.PartitionInconsistency{
+				PartitionID: partitionID,
+				Reason:      kafka.InconsistencyLeaderChanged,
+				Message: fmt.Sprintf("partition leader changed from broker '%v' to broker '%v' during the scan, "+
+					"messages may be missing or outdated if the new leader has been elected uncleanly", leaderBefore, leaderAfter),
+			})
+		}
+
+		markBefore := before.marks[partitionID]
+		markAfter, ok := after.marks[partitionID]
+		if !ok {
+			continue
+		}
+		if markAfter.High < markBefore.High {
+			inconsistencies = append(inconsistencies, kafka.PartitionInconsistency{
+				PartitionID: partitionID,
+				Reason:      kafka.InconsistencyTruncated,
+				Message: fmt.Sprintf("high watermark dropped from '%v' to '%v' during the scan, "+
+					"messages at or after offset '%v' may not exist anymore", markBefore.High, markAfter.High, markAfter.High),
+			})
+			continue
+		}
+		if consumed, ok := consumedOffsets[partitionID]; ok && consumed >= markAfter.High {
+			inconsistencies = append(inconsistencies, kafka.PartitionInconsistency{
+				PartitionID: partitionID,
+				Reason:      kafka.InconsistencyTruncated,
+				Message: fmt.Sprintf("offset '%v' has been consumed but the high watermark is '%v' now, "+
+					"messages at or after offset '%v' may not exist anymore", consumed, markAfter.High, markAfter.High),
+			})
+		}
+	}
+
+	return inconsistencies
+}
@@ -0,0 +1,42 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectInconsistencies(t *testing.T) {
+	before := partitionState{
+		leaders: map[int32]int32{0: 1, 1: 2, 2: 3, 3: 1},
+		marks: map[int32]*kafka.WaterMark{
+			0: {PartitionID: 0, Low: 0, High: 100},
+			1: {PartitionID: 1, Low: 0, High: 100},
+			2: {PartitionID: 2, Low: 0, High: 100},
+			3: {PartitionID: 3, Low: 0, High: 100},
+		},
+	}
+	after := partitionState{
+		leaders: map[int32]int32{0: 1, 1: 3, 2: 3, 3: 1},
+		marks: map[int32]*kafka.WaterMark{
+			0: {PartitionID: 0, Low: 0, High: 120}, // Produced during the scan
+			1: {PartitionID: 1, Low: 0, High: 90},  // Unclean election
+			2: {PartitionID: 2, Low: 0, High: 100},
+			3: {PartitionID: 3, Low: 0, High: 105},
+		},
+	}
+	consumed := map[int32]int64{0: 99, 1: 99, 2: 99, 3: 110} // Partition 3 was consumed from the newest offset
+
+	inconsistencies := detectInconsistencies(before, after, consumed)
+	reasons := make(map[int32][]string)
+	for _, inconsistency := range inconsistencies {
+		reasons[inconsistency.PartitionID] = append(reasons[inconsistency.PartitionID], inconsistency.Reason)
+	}
+	assert.Equal(t, map[int32][]string{
+		1: {kafka.InconsistencyLeaderChanged, kafka.InconsistencyTruncated},
+		3: {kafka.InconsistencyTruncated},
+	}, reasons)
+
+	assert.Empty(t, detectInconsistencies(before, before, nil))
+}
@@ -0,0 +1,70 @@
+package owl
+
+import (
+	"context"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// orderedMergeBufferSize is the number of messages each partition consumer can buffer ahead of the merge. It bounds
+// the memory of an ordered search to partitions * buffer size messages.
+const orderedMergeBufferSize = 64
+
+// mergeByTimestamp forwards the messages of all partition channels ordered by their timestamp. The smallest
+// buffered message is only forwarded once every partition which hasn't completed yet has buffered a message, as
+// this partition could still deliver an older one otherwise. Partitions complete by closing their channel. Messages
+// of the same partition keep their offset order, ties are resolved by the lower partition id.
+//
+// The merge stops if emit returns false, if the context is cancelled or after all partitions have completed.
+func mergeByTimestamp(ctx context.Context, partitionChs []<-chan *kafka.TopicMessage, emit func(msg *kafka.TopicMessage) bool) {
+	heads := make([]*kafka.TopicMessage, len(partitionChs))
+	open := make([]bool, len(partitionChs))
+	for i := range open {
+		open[i] = true
+	}
+
+	for {
+		// Wait until each open partition has buffered a message (or has completed)
+		for i, ch := range partitionChs {
+			if !open[i] || heads[i] != nil {
+				continue
+			}
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					open[i] = false
+					continue
+				}
+				heads[i] = msg
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		oldest := -1
+		for i, msg := range heads {
+			if msg == nil {
+				continue
+			}
+			if oldest == -1 || isOlder(msg, heads[oldest]) {
+				oldest = i
+			}
+		}
+		if oldest == -1 {
+			return // All partitions completed
+		}
+
+		msg := heads[oldest]
+		heads[oldest] = nil
+		if !emit(msg) {
+			return
+		}
+	}
+}
+
+func isOlder(a *kafka.TopicMessage, b *kafka.TopicMessage) bool {
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp < b.Timestamp
+	}
+	return a.PartitionID < b.PartitionID
+}
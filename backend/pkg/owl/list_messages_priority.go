@@ -0,0 +1,101 @@
+package owl
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+const (
+	// prioritizedScanRatio enables scheduling partition consumers by priority if less than 1/ratio of the messages
+	// which could be consumed have been requested
+	prioritizedScanRatio = 10
+
+	// prioritizedScanConcurrency is the number of partition consumers which run at the same time if they are
+	// scheduled by priority
+	prioritizedScanConcurrency = 8
+)
+
+// partitionActivity remembers the high watermarks of each topic's last scan, so that the recent activity of a
+// partition can be estimated by the watermark delta between two scans
+type partitionActivity struct {
+	mutex          sync.Mutex
+	highWaterMarks map[string]map[int32]int64
+}
+
+func newPartitionActivity() *partitionActivity {
+	return &partitionActivity{highWaterMarks: make(map[string]map[int32]int64)}
+}
+
+// observe records the high watermarks and returns a map of: partitionID -> number of messages which have been
+// produced since the previous scan. Partitions which have not been scanned before are missing in the result.
+func (a *partitionActivity) observe(topicName string, marks map[int32]*kafka.WaterMark) map[int32]int64 {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	previous, exists := a.highWaterMarks[topicName]
+	if !exists {
+		previous = make(map[int32]int64, len(marks))
+		a.highWaterMarks[topicName] = previous
+	}
+	deltas := make(map[int32]int64, len(marks))
+	for partitionID, mark := range marks {
+		if high, ok := previous[partitionID]; ok && mark.High >= high {
+			// The high watermark drops if the topic has been recreated, its activity is unknown in that case
+			deltas[partitionID] = mark.High - high
+		}
+		previous[partitionID] = mark.High
+	}
+
+	return deltas
+}
+
+// orderByPriority returns the consume requests ordered by the partitions' recent activity, partitions without known
+// activity come last. Ties are broken by the number of messages in the partition and by the partition id.
+func orderByPriority(requests map[int32]*kafka.PartitionConsumeRequest, activity map[int32]int64) []*kafka.PartitionConsumeRequest {
+	ordered := make([]*kafka.PartitionConsumeRequest, 0, len(requests))
+	for _, req := range requests {
+		ordered = append(ordered, req)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		deltaA, hasDeltaA := activity[a.PartitionID]
+		deltaB, hasDeltaB := activity[b.PartitionID]
+		if hasDeltaA != hasDeltaB {
+			return hasDeltaA
+		}
+		if deltaA != deltaB {
+			return deltaA > deltaB
+		}
+		sizeA, sizeB := a.HighWaterMark-a.LowWaterMark, b.HighWaterMark-b.LowWaterMark
+		if sizeA != sizeB {
+			return sizeA > sizeB
+		}
+		return a.PartitionID < b.PartitionID
+	})
+
+	return ordered
+}
+
+// isPrioritizedScan returns true if the partition consumers shall be started by priority, so that the remaining
+// partitions don't need to be consumed once enough messages have been found. This is only the case if the number
+// of messages per partition is not known upfront (e.g. because a filter is used) and if far fewer messages are
+// requested than could be consumed.
+func isPrioritizedScan(listReq *ListMessageRequest, requests map[int32]*kafka.PartitionConsumeRequest) bool {
+	if listReq.LiveTail || listReq.StartOffset == StartOffsetNewest || len(requests) <= prioritizedScanConcurrency {
+		return false
+	}
+
+	consumable := int64(0)
+	for _, req := range requests {
+		messages := req.EndOffset - req.StartOffset + 1
+		if req.MaxMessageCount < messages {
+			messages = req.MaxMessageCount
+		}
+		if messages > 0 {
+			consumable += messages
+		}
+	}
+	return listReq.MessageCount < consumable/prioritizedScanRatio
+}
@@ -0,0 +1,70 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionActivity_Observe(t *testing.T) {
+	activity := newPartitionActivity()
+	marks := map[int32]*kafka.WaterMark{
+		0: {PartitionID: 0, Low: 0, High: 100},
+		1: {PartitionID: 1, Low: 0, High: 50},
+	}
+	assert.Empty(t, activity.observe("test", marks))
+
+	marks = map[int32]*kafka.WaterMark{
+		0: {PartitionID: 0, Low: 0, High: 110},
+		1: {PartitionID: 1, Low: 0, High: 10}, // Recreated topic
+		2: {PartitionID: 2, Low: 0, High: 5},  // New partition
+	}
+	assert.Equal(t, map[int32]int64{0: 10}, activity.observe("test", marks))
+	assert.Equal(t, map[int32]int64{0: 0, 1: 0, 2: 0}, activity.observe("test", marks))
+	assert.Empty(t, activity.observe("other", marks))
+}
+
+func TestCalculateConsumeRequests_Prioritized(t *testing.T) {
+	marks := map[int32]*kafka.WaterMark{
+		0: {PartitionID: 0, Low: 0, High: 100},
+		1: {PartitionID: 1, Low: 0, High: 100},
+		2: {PartitionID: 2, Low: 0, High: 500},
+		3: {PartitionID: 3, Low: 0, High: 100},
+	}
+	req := &ListMessageRequest{TopicName: "test", PartitionID: partitionsAll, StartOffset: StartOffsetRecent, MessageCount: 2}
+
+	// Partition 3 is the most active one, partition 2 is the largest one without known activity
+	actual := calculateConsumeRequests(req, marks, map[int32]int64{0: 1, 1: 0, 3: 7})
+	require.Len(t, actual, 2)
+	assert.Contains(t, actual, int32(3))
+	assert.Contains(t, actual, int32(0))
+
+	actual = calculateConsumeRequests(req, marks, nil)
+	require.Len(t, actual, 2)
+	assert.Contains(t, actual, int32(2))
+	assert.Contains(t, actual, int32(0))
+}
+
+func TestIsPrioritizedScan(t *testing.T) {
+	requests := make(map[int32]*kafka.PartitionConsumeRequest)
+	for i := int32(0); i < 20; i++ {
+		requests[i] = &kafka.PartitionConsumeRequest{PartitionID: i, StartOffset: 0, EndOffset: 999, MaxMessageCount: 50}
+	}
+
+	req := &ListMessageRequest{StartOffset: StartOffsetOldest, MessageCount: 50}
+	assert.True(t, isPrioritizedScan(req, requests))
+	req.MessageCount = 500
+	assert.False(t, isPrioritizedScan(req, requests))
+
+	req = &ListMessageRequest{StartOffset: StartOffsetNewest, MessageCount: 50}
+	assert.False(t, isPrioritizedScan(req, requests))
+
+	// Requests with predictable results are never prioritized, their consumers stop after the assigned messages
+	for _, r := range requests {
+		r.MaxMessageCount = 1
+	}
+	req = &ListMessageRequest{StartOffset: StartOffsetOldest, MessageCount: 20}
+	assert.False(t, isPrioritizedScan(req, requests))
+}
@@ -0,0 +1,137 @@
+package owl
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// Fields by which search results can be sorted
+const (
+	SortFieldOffset    = "offset"
+	SortFieldTimestamp = "timestamp"
+	SortFieldSize      = "size"
+)
+
+// SortKey is a field the results of a search are sorted by. Subsequent keys break the ties of previous keys.
+type SortKey struct {
+	Field      string `json:"field"`
+	Descending bool   `json:"descending"`
+}
+
+// ValidateSortKeys checks that all keys refer to known fields and that no field is used twice
+func ValidateSortKeys(keys []SortKey) error {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		switch key.Field {
+		case SortFieldOffset, SortFieldTimestamp, SortFieldSize:
+		default:
+			return fmt.Errorf("unknown sort field '%v', must be one of %v, %v or %v", key.Field, SortFieldOffset, SortFieldTimestamp, SortFieldSize)
+		}
+		if seen[key.Field] {
+			return fmt.Errorf("sort field '%v' must not be given more than once", key.Field)
+		}
+		seen[key.Field] = true
+	}
+
+	return nil
+}
+
+// isSortedBefore reports whether a comes before b. Messages which are equal in all keys are ordered by partition id
+// and offset, so that the order is deterministic.
+func isSortedBefore(keys []SortKey, a *kafka.TopicMessage, b *kafka.TopicMessage) bool {
+	for _, key := range keys {
+		var valueA, valueB int64
+		switch key.Field {
+		case SortFieldOffset:
+			valueA, valueB = a.Offset, b.Offset
+		case SortFieldTimestamp:
+			valueA, valueB = a.Timestamp, b.Timestamp
+		case SortFieldSize:
+			valueA, valueB = int64(a.Size), int64(b.Size)
+		}
+		if valueA == valueB {
+			continue
+		}
+		if key.Descending {
+			return valueA > valueB
+		}
+		return valueA < valueB
+	}
+
+	if a.PartitionID != b.PartitionID {
+		return a.PartitionID < b.PartitionID
+	}
+	return a.Offset < b.Offset
+}
+
+// topMessages keeps the first messages in sort order up to a limit. It's a heap whose root is the last kept
+// message, which is replaced once a message comes before it.
+type topMessages struct {
+	keys     []SortKey
+	limit    int
+	messages []*kafka.TopicMessage
+}
+
+func newTopMessages(keys []SortKey, limit int64) *topMessages {
+	return &topMessages{keys: keys, limit: int(limit), messages: make([]*kafka.TopicMessage, 0)}
+}
+
+func (t *topMessages) Len() int { return len(t.messages) }
+func (t *topMessages) Less(i, j int) bool {
+	return isSortedBefore(t.keys, t.messages[j], t.messages[i])
+}
+func (t *topMessages) Swap(i, j int) { t.messages[i], t.messages[j] = t.messages[j], t.messages[i] }
+func (t *topMessages) Push(x interface{}) {
+	t.messages = append(t.messages, x.(*kafka.TopicMessage))
+}
+func (t *topMessages) Pop() interface{} {
+	last := t.messages[len(t.messages)-1]
+	t.messages = t.messages[:len(t.messages)-1]
+	return last
+}
+
+// add keeps the message if it's among the first messages seen so far
+func (t *topMessages) add(msg *kafka.TopicMessage) {
+	if len(t.messages) < t.limit {
+		heap.Push(t, msg)
+		return
+	}
+	if t.limit > 0 && isSortedBefore(t.keys, msg, t.messages[0]) {
+		t.messages[0] = msg
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted returns the kept messages in sort order
+func (t *topMessages) sorted() []*kafka.TopicMessage {
+	sorted := make([]*kafka.TopicMessage, len(t.messages))
+	copy(sorted, t.messages)
+	sort.Slice(sorted, func(i, j int) bool {
+		return isSortedBefore(t.keys, sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+// collectSorted receives messages until the context is done and forwards the first messageCount messages in sort
+// order afterwards. Unlike unsorted searches, the scan doesn't stop once enough messages have been found, because
+// any message which is yet to come could be sorted before them.
+func collectSorted(ctx context.Context, messageCh <-chan *kafka.TopicMessage, keys []SortKey, messageCount int64, progress kafka.IListMessagesProgress) {
+	top := newTopMessages(keys, messageCount)
+Loop:
+	for {
+		select {
+		case msg := <-messageCh:
+			top.add(msg)
+		case <-ctx.Done():
+			break Loop
+		}
+	}
+
+	for _, msg := range top.sorted() {
+		progress.OnMessage(msg)
+	}
+}
@@ -0,0 +1,26 @@
+package owl
+
+// minTailWindow is the number of offsets which are read in the first window of a tail read, subsequent windows grow
+// until enough messages have matched
+const minTailWindow = 500
+
+// tailReadSortKeys return the newest messages of all partitions first
+var tailReadSortKeys = []SortKey{
+	{Field: SortFieldTimestamp, Descending: true},
+	{Field: SortFieldOffset, Descending: true},
+}
+
+// isTailRead returns true if the newest messages of a filtered search are requested. Because the number of matching
+// messages per partition is unknown, the partitions are read backwards from the high watermark until enough messages
+// have matched, instead of reading forwards from an estimated start offset.
+func isTailRead(listReq *ListMessageRequest) bool {
+	return listReq.StartOffset == StartOffsetRecent && !listReq.LiveTail && (listReq.IsFiltered() || listReq.KeyFilter != nil)
+}
+
+// tailWindow returns the size of the first window in which the partitions are read backwards
+func tailWindow(messageCount int64) int64 {
+	if messageCount > minTailWindow {
+		return messageCount
+	}
+	return minTailWindow
+}
@@ -0,0 +1,58 @@
+package owl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestListMessages_TailRead(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 2, 1, nil, false))
+	svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+	// Every 100th message of partition 0 has the key we are looking for, partition 1 has no such message
+	for i := 0; i < 1200; i++ {
+		key := "other"
+		if i%100 == 0 {
+			key = "match"
+		}
+		_, err := cluster.Produce(kafka.ProduceRecord{TopicName: "orders", PartitionID: 0, Partitioner: kafka.PartitionerManual, Key: []byte(key), Value: []byte("v")}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+	_, err := cluster.Produce(kafka.ProduceRecord{TopicName: "orders", PartitionID: 1, Partitioner: kafka.PartitionerManual, Key: []byte("other"), Value: []byte("v")}, kafka.ProduceOptions{})
+	require.NoError(t, err)
+
+	// The newest matches span two windows, they are returned newest first
+	res, err := svc.CollectMessages(context.Background(), ListMessageRequest{
+		TopicName:    "orders",
+		PartitionID:  partitionsAll,
+		StartOffset:  StartOffsetRecent,
+		MessageCount: 8,
+		KeyFilter:    []byte("match"),
+	})
+	require.NoError(t, err)
+	offsets := make([]int64, len(res.Messages))
+	for i, msg := range res.Messages {
+		offsets[i] = msg.Offset
+	}
+	assert.Equal(t, []int64{1100, 1000, 900, 800, 700, 600, 500, 400}, offsets)
+
+	// All matches are returned if fewer messages match than requested
+	res, err = svc.CollectMessages(context.Background(), ListMessageRequest{
+		TopicName:    "orders",
+		PartitionID:  partitionsAll,
+		StartOffset:  StartOffsetRecent,
+		MessageCount: 50,
+		KeyFilter:    []byte("match"),
+	})
+	require.NoError(t, err)
+	assert.Len(t, res.Messages, 12)
+}
@@ -1,10 +1,13 @@
 package owl
 
 import (
+	"context"
 	"github.com/Shopify/sarama"
 	"github.com/cloudhut/kowl/backend/pkg/kafka"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestCalculateConsumeRequests_AllPartitions_FewNewestMessages(t *testing.T) {
@@ -28,7 +31,7 @@ func TestCalculateConsumeRequests_AllPartitions_FewNewestMessages(t *testing.T)
 		1: {PartitionID: 1, IsDrained: false, StartOffset: marks[1].High - 1, EndOffset: marks[1].High - 1, MaxMessageCount: 1, LowWaterMark: marks[1].Low, HighWaterMark: marks[1].High},
 		2: {PartitionID: 2, IsDrained: false, StartOffset: marks[2].High - 1, EndOffset: marks[2].High - 1, MaxMessageCount: 1, LowWaterMark: marks[2].Low, HighWaterMark: marks[2].High},
 	}
-	actual := calculateConsumeRequests(req, marks)
+	actual := calculateConsumeRequests(req, marks, nil)
 
 	assert.Equal(t, expected, actual, "expected other result for unbalanced message distribution - all partition IDs")
 }
@@ -54,7 +57,7 @@ func TestCalculateConsumeRequests_AllPartitions_Unbalanced(t *testing.T) {
 		1: {PartitionID: 1, IsDrained: true, LowWaterMark: marks[1].Low, HighWaterMark: marks[1].High, StartOffset: 0, EndOffset: marks[1].High - 1, MaxMessageCount: 10},
 		2: {PartitionID: 2, IsDrained: true, LowWaterMark: marks[2].Low, HighWaterMark: marks[2].High, StartOffset: 10, EndOffset: marks[2].High - 1, MaxMessageCount: 20},
 	}
-	actual := calculateConsumeRequests(req, marks)
+	actual := calculateConsumeRequests(req, marks, nil)
 
 	assert.Equal(t, expected, actual, "expected other result for unbalanced message distribution - all partition IDs")
 }
@@ -120,7 +123,7 @@ func TestCalculateConsumeRequests_SinglePartition(t *testing.T) {
 	}
 
 	for i, table := range tt {
-		actual := calculateConsumeRequests(table.req, marks)
+		actual := calculateConsumeRequests(table.req, marks, nil)
 		assert.Equal(t, table.expected, actual, "expected other result for single partition test. Case: ", i)
 	}
 }
@@ -162,15 +165,163 @@ func TestCalculateConsumeRequests_AllPartitions_WithFilter(t *testing.T) {
 				FilterInterpreterCode: "random string that simulates some javascript code",
 			},
 			map[int32]*kafka.PartitionConsumeRequest{
-				0: {PartitionID: 0, IsDrained: false, StartOffset: 249, EndOffset: 299, MaxMessageCount: 50, LowWaterMark: 0, HighWaterMark: 300},
-				1: {PartitionID: 1, IsDrained: false, StartOffset: 249, EndOffset: 299, MaxMessageCount: 50, LowWaterMark: 0, HighWaterMark: 300},
-				2: {PartitionID: 2, IsDrained: false, StartOffset: 249, EndOffset: 299, MaxMessageCount: 50, LowWaterMark: 0, HighWaterMark: 300},
+				0: {PartitionID: 0, IsDrained: false, StartOffset: 0, EndOffset: 299, MaxMessageCount: 50, LowWaterMark: 0, HighWaterMark: 300, TailWindow: minTailWindow},
+				1: {PartitionID: 1, IsDrained: false, StartOffset: 0, EndOffset: 299, MaxMessageCount: 50, LowWaterMark: 0, HighWaterMark: 300, TailWindow: minTailWindow},
+				2: {PartitionID: 2, IsDrained: false, StartOffset: 0, EndOffset: 299, MaxMessageCount: 50, LowWaterMark: 0, HighWaterMark: 300, TailWindow: minTailWindow},
 			},
 		},
 	}
 
 	for i, table := range tt {
-		actual := calculateConsumeRequests(table.req, marks)
+		actual := calculateConsumeRequests(table.req, marks, nil)
 		assert.Equal(t, table.expected, actual, "expected other result for all partitions with filter enable. Case: ", i)
 	}
 }
+
+func TestCalculateConsumeRequests_LiveTail(t *testing.T) {
+	marks := map[int32]*kafka.WaterMark{
+		0: {PartitionID: 0, Low: 0, High: 300},
+		1: {PartitionID: 1, Low: 10, High: 20},
+	}
+
+	req := &ListMessageRequest{
+		TopicName:    "test",
+		PartitionID:  partitionsAll,
+		StartOffset:  StartOffsetOldest, // Must be ignored in live tail mode
+		MessageCount: 5,
+		LiveTail:     true,
+	}
+
+	expected := map[int32]*kafka.PartitionConsumeRequest{
+		0: {PartitionID: 0, IsDrained: false, StartOffset: sarama.OffsetNewest, EndOffset: math.MaxInt64, MaxMessageCount: math.MaxInt64, LowWaterMark: 0, HighWaterMark: 300},
+		1: {PartitionID: 1, IsDrained: false, StartOffset: sarama.OffsetNewest, EndOffset: math.MaxInt64, MaxMessageCount: math.MaxInt64, LowWaterMark: 10, HighWaterMark: 20},
+	}
+	actual := calculateConsumeRequests(req, marks, nil)
+
+	assert.Equal(t, expected, actual, "expected consume requests to start at newest offset without an end in live tail mode")
+}
+
+func TestCalculateTimeRangeConsumeRequests(t *testing.T) {
+	marks := map[int32]*kafka.WaterMark{
+		0: {PartitionID: 0, Low: 0, High: 300},
+		1: {PartitionID: 1, Low: 10, High: 20},
+		2: {PartitionID: 2, Low: 50, High: 100},
+	}
+	// Partition 1 has no message at or after the start timestamp, partition 2's offset has been deleted meanwhile
+	startOffsets := map[int32]int64{0: 120, 1: -1, 2: 40}
+
+	req := &ListMessageRequest{
+		TopicName:      "test",
+		PartitionID:    partitionsAll,
+		StartOffset:    StartOffsetTimestamp,
+		MessageCount:   50,
+		StartTimestamp: 1600000000000,
+		EndTimestamp:   1600000060000,
+	}
+
+	endTimestamp := time.Unix(1600000060, 0)
+	expected := map[int32]*kafka.PartitionConsumeRequest{
+		0: {PartitionID: 0, IsDrained: false, StartOffset: 120, EndOffset: 299, EndTimestamp: endTimestamp, MaxMessageCount: 50, LowWaterMark: 0, HighWaterMark: 300},
+		2: {PartitionID: 2, IsDrained: false, StartOffset: 50, EndOffset: 99, EndTimestamp: endTimestamp, MaxMessageCount: 50, LowWaterMark: 50, HighWaterMark: 100},
+	}
+	actual := calculateTimeRangeConsumeRequests(req, marks, startOffsets)
+
+	assert.Equal(t, expected, actual, "expected consume requests to start at the resolved offsets")
+}
+
+func TestMergeByTimestamp(t *testing.T) {
+	partitionMessages := [][]int64{
+		{1, 4, 7},
+		{2, 3, 9, 10},
+		{},
+		{4, 5},
+	}
+	partitionChs := make([]<-chan *kafka.TopicMessage, len(partitionMessages))
+	for i, timestamps := range partitionMessages {
+		ch := make(chan *kafka.TopicMessage, len(timestamps))
+		for offset, ts := range timestamps {
+			ch <- &kafka.TopicMessage{PartitionID: int32(i), Offset: int64(offset), Timestamp: ts}
+		}
+		close(ch)
+		partitionChs[i] = ch
+	}
+
+	type position struct {
+		PartitionID int32
+		Timestamp   int64
+	}
+	merged := make([]position, 0)
+	mergeByTimestamp(context.Background(), partitionChs, func(msg *kafka.TopicMessage) bool {
+		merged = append(merged, position{msg.PartitionID, msg.Timestamp})
+		return len(merged) < 8
+	})
+
+	// Ties are resolved by the lower partition id, the merge stops once emit returns false
+	expected := []position{{0, 1}, {1, 2}, {1, 3}, {0, 4}, {3, 4}, {3, 5}, {0, 7}, {1, 9}}
+	assert.Equal(t, expected, merged)
+}
+
+func TestMergeByTimestamp_WaitsForAllPartitions(t *testing.T) {
+	slow := make(chan *kafka.TopicMessage)
+	fast := make(chan *kafka.TopicMessage, 1)
+	fast <- &kafka.TopicMessage{PartitionID: 0, Timestamp: 5}
+	close(fast)
+
+	merged := make(chan *kafka.TopicMessage, 2)
+	go mergeByTimestamp(context.Background(), []<-chan *kafka.TopicMessage{fast, slow}, func(msg *kafka.TopicMessage) bool {
+		merged <- msg
+		return true
+	})
+
+	// The older message of the slow partition must be emitted first
+	select {
+	case msg := <-merged:
+		t.Fatalf("message of partition %v has been emitted before all partitions delivered a message", msg.PartitionID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	slow <- &kafka.TopicMessage{PartitionID: 1, Timestamp: 3}
+	close(slow)
+
+	assert.Equal(t, int32(1), (<-merged).PartitionID)
+	assert.Equal(t, int32(0), (<-merged).PartitionID)
+}
+
+func TestTopMessages(t *testing.T) {
+	type message struct {
+		PartitionID int32
+		Offset      int64
+		Size        int
+	}
+	messages := []message{{0, 0, 30}, {1, 0, 10}, {0, 1, 50}, {1, 1, 30}, {2, 0, 20}, {0, 2, 50}, {2, 1, 40}}
+
+	tt := []struct {
+		keys     []SortKey
+		limit    int64
+		expected []message
+	}{
+		{[]SortKey{{Field: SortFieldSize}}, 3, []message{{1, 0, 10}, {2, 0, 20}, {0, 0, 30}}},
+		{[]SortKey{{Field: SortFieldSize, Descending: true}}, 3, []message{{0, 1, 50}, {0, 2, 50}, {2, 1, 40}}},
+		{[]SortKey{{Field: SortFieldSize, Descending: true}, {Field: SortFieldOffset, Descending: true}}, 2, []message{{0, 2, 50}, {0, 1, 50}}},
+		{[]SortKey{{Field: SortFieldOffset, Descending: true}}, 2, []message{{0, 2, 50}, {0, 1, 50}}},
+		{[]SortKey{{Field: SortFieldOffset}}, 10, []message{{0, 0, 30}, {1, 0, 10}, {2, 0, 20}, {0, 1, 50}, {1, 1, 30}, {2, 1, 40}, {0, 2, 50}}},
+	}
+
+	for i, test := range tt {
+		top := newTopMessages(test.keys, test.limit)
+		for _, m := range messages {
+			top.add(&kafka.TopicMessage{PartitionID: m.PartitionID, Offset: m.Offset, Size: m.Size})
+		}
+		sorted := make([]message, 0)
+		for _, msg := range top.sorted() {
+			sorted = append(sorted, message{msg.PartitionID, msg.Offset, msg.Size})
+		}
+		assert.Equal(t, test.expected, sorted, "Case: ", i)
+	}
+}
+
+func TestValidateSortKeys(t *testing.T) {
+	assert.NoError(t, ValidateSortKeys(nil))
+	assert.NoError(t, ValidateSortKeys([]SortKey{{Field: SortFieldSize, Descending: true}, {Field: SortFieldTimestamp}}))
+	assert.Error(t, ValidateSortKeys([]SortKey{{Field: "key"}}))
+	assert.Error(t, ValidateSortKeys([]SortKey{{Field: SortFieldSize}, {Field: SortFieldSize, Descending: true}}))
+}
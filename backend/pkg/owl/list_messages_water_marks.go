@@ -0,0 +1,46 @@
+package owl
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"go.uber.org/zap"
+)
+
+// waterMarkRefreshInterval is the interval at which the watermarks of the consumed partitions are fetched again, so
+// that long scans and live tails can report how far they are behind the partitions' ends
+const waterMarkRefreshInterval = 5 * time.Second
+
+// refreshWaterMarks reports the current watermarks of the consumed partitions periodically until the context is
+// done. Failed refreshes are skipped, the progress keeps the previous watermarks then.
+func (s *Service) refreshWaterMarks(ctx context.Context, topicName string, consumeRequests map[int32]*kafka.PartitionConsumeRequest, progress kafka.IListMessagesProgress, logger *zap.Logger) {
+	partitionIDs := make([]int32, 0, len(consumeRequests))
+	for partitionID := range consumeRequests {
+		partitionIDs = append(partitionIDs, partitionID)
+	}
+	if len(partitionIDs) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(waterMarkRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		marks, err := s.kafkaSvc.WaterMarks(topicName, partitionIDs)
+		if err != nil {
+			logger.Debug("failed to refresh watermarks", zap.Error(err))
+			continue
+		}
+		// The request may have completed while the watermarks have been fetched
+		if ctx.Err() != nil {
+			return
+		}
+		progress.OnWaterMarks(marks)
+	}
+}
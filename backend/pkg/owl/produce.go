@@ -0,0 +1,117 @@
+package owl
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// PayloadEncoding describes how a payload submitted by the user shall be serialized before it's produced
+type PayloadEncoding string
+
+const (
+	PayloadEncodingText   PayloadEncoding = "text"
+	PayloadEncodingJSON   PayloadEncoding = "json"
+	PayloadEncodingBase64 PayloadEncoding = "base64" // Arbitrary binary data
+	PayloadEncodingAvro   PayloadEncoding = "avro"   // Avro JSON, serialized with the latest schema of the subject
+)
+
+// ErrInvalidPayload is returned if a submitted payload can not be serialized with the chosen encoding
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// ProducePayload is a key, value or header value as submitted by the user
+type ProducePayload struct {
+	Encoding PayloadEncoding `json:"encoding"`
+	Data     string          `json:"data"`
+}
+
+// ProduceHeader is a record header as submitted by the user
+type ProduceHeader struct {
+	Key   string         `json:"key"`
+	Value ProducePayload `json:"value"`
+}
+
+// ProduceRequest describes a single record which shall be produced
+type ProduceRequest struct {
+	TopicName   string
+	PartitionID int32
+	Partitioner string
+	Key         *ProducePayload // Nil for records without a key
+	Value       *ProducePayload // Nil for tombstones
+	Headers     []ProduceHeader
+	Options     kafka.ProduceOptions
+}
+
+// ProduceMessage serializes the submitted key, value and headers and produces them as a single record
+func (s *Service) ProduceMessage(ctx context.Context, req ProduceRequest) (*kafka.ProduceResult, error) {
+	record := kafka.ProduceRecord{
+		TopicName:   req.TopicName,
+		PartitionID: req.PartitionID,
+		Partitioner: req.Partitioner,
+		Headers:     make([]sarama.RecordHeader, len(req.Headers)),
+	}
+
+	var err error
+	if req.Key != nil {
+		record.Key, err = s.encodePayload(ctx, *req.Key, req.TopicName+"-key")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode key: %w", err)
+		}
+	}
+	if req.Value != nil {
+		record.Value, err = s.encodePayload(ctx, *req.Value, req.TopicName+"-value")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value: %w", err)
+		}
+	}
+	for i, h := range req.Headers {
+		if h.Value.Encoding == PayloadEncodingAvro {
+			return nil, fmt.Errorf("%w: header '%v' can not be avro encoded", ErrInvalidPayload, h.Key)
+		}
+		value, err := s.encodePayload(ctx, h.Value, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode header '%v': %w", h.Key, err)
+		}
+		record.Headers[i] = sarama.RecordHeader{Key: []byte(h.Key), Value: value}
+	}
+
+	return s.kafkaSvc.Produce(record, req.Options)
+}
+
+// encodePayload serializes the payload. The subject is the schema registry subject which is used for avro payloads.
+func (s *Service) encodePayload(ctx context.Context, payload ProducePayload, subject string) ([]byte, error) {
+	switch payload.Encoding {
+	case PayloadEncodingText:
+		return []byte(payload.Data), nil
+	case PayloadEncodingJSON:
+		if !json.Valid([]byte(payload.Data)) {
+			return nil, fmt.Errorf("%w: data is not valid JSON", ErrInvalidPayload)
+		}
+		compacted := &bytes.Buffer{}
+		_ = json.Compact(compacted, []byte(payload.Data)) // Error can be ignored, the JSON has been validated already
+		return compacted.Bytes(), nil
+	case PayloadEncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(payload.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: data is not valid base64: %v", ErrInvalidPayload, err)
+		}
+		return decoded, nil
+	case PayloadEncodingAvro:
+		if s.schemaSvc == nil {
+			return nil, fmt.Errorf("%w: avro encoding requires the schema registry to be configured", ErrInvalidPayload)
+		}
+		encoded, err := s.schemaSvc.EncodeAvro(ctx, subject, []byte(payload.Data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		return encoded, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown encoding '%v'", ErrInvalidPayload, payload.Encoding)
+	}
+}
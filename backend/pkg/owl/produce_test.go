@@ -0,0 +1,94 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newFakeSchemaService returns a schema service whose registry serves a single avro schema for the subject
+// "orders-value" with the schema id 7
+func newFakeSchemaService(t *testing.T) *schema.Service {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subjects/orders-value/versions/latest" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code": 40401, "message": "Subject not found."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"subject": "orders-value", "version": 1, "id": 7, "schema": "{\"type\": \"record\", \"name\": \"Order\", \"fields\": [{\"name\": \"n\", \"type\": \"int\"}]}"}`))
+	}))
+	t.Cleanup(registry.Close)
+
+	cfg := schema.Config{Enabled: true, URLs: []string{registry.URL}}
+	cfg.SetDefaults()
+	return schema.NewService(cfg, resilience.NewRegistry(nil), "schema_registry", zap.NewNop())
+}
+
+func TestEncodePayload(t *testing.T) {
+	withSchema := &Service{schemaSvc: newFakeSchemaService(t), logger: zap.NewNop()}
+	withoutSchema := &Service{logger: zap.NewNop()}
+
+	tt := []struct {
+		svc      *Service
+		payload  ProducePayload
+		subject  string
+		expected []byte
+		isValid  bool
+	}{
+		{withoutSchema, ProducePayload{Encoding: PayloadEncodingText, Data: "hello"}, "", []byte("hello"), true},
+		{withoutSchema, ProducePayload{Encoding: PayloadEncodingJSON, Data: "{\n  \"id\": 1\n}"}, "", []byte(`{"id":1}`), true},
+		{withoutSchema, ProducePayload{Encoding: PayloadEncodingJSON, Data: `{"id": `}, "", nil, false},
+		{withoutSchema, ProducePayload{Encoding: PayloadEncodingBase64, Data: "AAEC"}, "", []byte{0, 1, 2}, true},
+		{withoutSchema, ProducePayload{Encoding: PayloadEncodingBase64, Data: "not base64!"}, "", nil, false},
+		{withoutSchema, ProducePayload{Encoding: PayloadEncodingAvro, Data: `{"n": 42}`}, "orders-value", nil, false},
+		{withSchema, ProducePayload{Encoding: PayloadEncodingAvro, Data: `{"n": 42}`}, "orders-value", []byte{0, 0, 0, 0, 7, 84}, true},
+		{withSchema, ProducePayload{Encoding: PayloadEncodingAvro, Data: `{"n": "text"}`}, "orders-value", nil, false},
+		{withSchema, ProducePayload{Encoding: PayloadEncodingAvro, Data: `{"n": 42}`}, "unknown-value", nil, false},
+		{withoutSchema, ProducePayload{Encoding: "xml", Data: "<a/>"}, "", nil, false},
+	}
+
+	for i, test := range tt {
+		encoded, err := test.svc.encodePayload(context.Background(), test.payload, test.subject)
+		if !test.isValid {
+			assert.True(t, errors.Is(err, ErrInvalidPayload), "Case: ", i)
+			continue
+		}
+		require.NoError(t, err, "Case: ", i)
+		assert.Equal(t, test.expected, encoded, "Case: ", i)
+	}
+}
+
+func TestProduceMessageRejectsAvroHeaders(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 1, 1, nil, false))
+	svc := NewService(cluster, nil, newFakeSchemaService(t), nil, ConsumeLimits{}, zap.NewNop())
+
+	_, err := svc.ProduceMessage(context.Background(), ProduceRequest{
+		TopicName:   "orders",
+		Partitioner: kafka.PartitionerManual,
+		Value:       &ProducePayload{Encoding: PayloadEncodingAvro, Data: `{"n": 42}`},
+		Headers:     []ProduceHeader{{Key: "trace", Value: ProducePayload{Encoding: PayloadEncodingAvro, Data: `{"n": 1}`}}},
+	})
+	assert.True(t, errors.Is(err, ErrInvalidPayload))
+
+	res, err := svc.ProduceMessage(context.Background(), ProduceRequest{
+		TopicName:   "orders",
+		Partitioner: kafka.PartitionerManual,
+		Value:       &ProducePayload{Encoding: PayloadEncodingAvro, Data: `{"n": 42}`},
+		Headers:     []ProduceHeader{{Key: "trace", Value: ProducePayload{Encoding: PayloadEncodingText, Data: "abc"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), res.Offset)
+}
@@ -0,0 +1,310 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// Provenance headers which are added to every replayed message. Headers with these keys are replaced, so that
+// messages which are replayed more than once refer to the message they have been copied from.
+const (
+	ReplayHeaderSourceTopic     = "kowl-replay-source-topic"
+	ReplayHeaderSourcePartition = "kowl-replay-source-partition"
+	ReplayHeaderSourceOffset    = "kowl-replay-source-offset"
+	ReplayHeaderSourceTimestamp = "kowl-replay-source-timestamp" // Unix milliseconds
+	ReplayHeaderReplayedAt      = "kowl-replay-replayed-at"      // Unix milliseconds
+)
+
+// DefaultReplayChunkSize is the number of messages which are produced within a single transaction, if a transactional
+// replay doesn't set the chunk size
+const DefaultReplayChunkSize = 100
+
+// ReplayRequest copies the messages which are found by a search to another topic. The search selects the source
+// topic, the range and the filter, it must not be a live tail.
+type ReplayRequest struct {
+	Search           ListMessageRequest
+	DestinationTopic string
+
+	// PreservePartitions produces each message to the partition it has been consumed from, otherwise the partition
+	// is chosen by the hash of its key
+	PreservePartitions bool
+
+	// Transactional produces the messages in chunks of ChunkSize messages, each within a single transaction. If a
+	// chunk fails, none of its messages are visible to read_committed consumers, rather than leaving a partially
+	// replayed chunk behind. Otherwise messages are produced one at a time.
+	Transactional bool
+	ChunkSize     int
+
+	// DryRun only counts the messages which would be replayed
+	DryRun bool
+}
+
+// ReplayResponse describes which messages have been replayed
+type ReplayResponse struct {
+	SourceTopic      string `json:"sourceTopic"`
+	DestinationTopic string `json:"destinationTopic"`
+	DryRun           bool   `json:"dryRun"`
+
+	// ReplayedMessages is the number of messages which have been produced, or would have been produced in a dry run
+	ReplayedMessages int64 `json:"replayedMessages"`
+
+	// Partitions are the offset ranges of the replayed messages of each source partition
+	Partitions []ReplayedPartition `json:"partitions"`
+
+	// IsCancelled is true if the replay has been stopped early, e.g. because a message couldn't be produced or the
+	// request has timed out. The messages which have been replayed until then are not removed. In transactional
+	// replays only the chunks which have been committed are counted as replayed.
+	IsCancelled bool     `json:"isCancelled"`
+	Errors      []string `json:"errors"`
+}
+
+// ReplayedPartition are the first and last offsets of a source partition which have been replayed
+type ReplayedPartition struct {
+	PartitionID int32 `json:"partitionId"`
+	FirstOffset int64 `json:"firstOffset"`
+	LastOffset  int64 `json:"lastOffset"`
+	Messages    int64 `json:"messages"`
+}
+
+// ReplayMessages consumes the messages of the search and produces them with their original keys, values and headers
+// to the destination topic. Messages are replayed in the order they are found, the first failure stops the replay.
+func (s *Service) ReplayMessages(ctx context.Context, req ReplayRequest) (*ReplayResponse, error) {
+	if req.Search.LiveTail {
+		return nil, fmt.Errorf("live tails can't be replayed")
+	}
+
+	if !req.DryRun {
+		partitions, err := s.kafkaSvc.ListPartitions(req.DestinationTopic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get partitions of destination topic: %w", err)
+		}
+		if req.PreservePartitions {
+			sourcePartitions, err := s.kafkaSvc.ListPartitions(req.Search.TopicName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get partitions of source topic: %w", err)
+			}
+			if len(partitions) < len(sourcePartitions) {
+				return nil, fmt.Errorf("%w: destination topic has fewer partitions (%v) than the source topic (%v), partitions can't be preserved", ErrInvalidTopicRequest, len(partitions), len(sourcePartitions))
+			}
+		}
+	}
+
+	if req.ChunkSize <= 0 {
+		req.ChunkSize = DefaultReplayChunkSize
+	}
+
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	replayedAt := time.Now()
+	replayer := &messageReplayer{
+		svc:             s,
+		req:             req,
+		cancel:          cancel,
+		replayedAt:      replayedAt,
+		transactionalID: fmt.Sprintf("kowl-replay-%v-%v", req.DestinationTopic, replayedAt.UnixNano()),
+		pending:         make([]*kafka.TopicMessage, 0, req.ChunkSize),
+		partitions:      make(map[int32]*ReplayedPartition),
+		errors:          make([]string, 0),
+	}
+	search := req.Search
+	search.KeepRawMessages = true
+	if search.Predicate == nil {
+		// Each partition must be consumed up to the end of the range, rather than balancing the number of messages
+		// across partitions, which only applies to searches without any filter
+		search.Predicate = func(_ *kafka.TopicMessage, _ time.Time) bool { return true }
+	}
+	err := s.ListMessages(childCtx, search, replayer)
+	isCancelled := ctx.Err() != nil || replayer.failed()
+	if err != nil && !isCancelled {
+		return nil, err
+	}
+	if !isCancelled {
+		// Produce the last chunk, which hasn't been filled up
+		replayer.flush()
+		isCancelled = replayer.failed()
+	}
+
+	return replayer.response(isCancelled), nil
+}
+
+// messageReplayer produces all messages of a search as they are found, or chunk by chunk in transactional replays.
+// It's the search's progress, which is only called from a single goroutine for messages.
+type messageReplayer struct {
+	svc             *Service
+	req             ReplayRequest
+	cancel          context.CancelFunc
+	replayedAt      time.Time
+	transactionalID string
+
+	// pending are the messages of the current chunk, which have not been produced yet
+	pending []*kafka.TopicMessage
+
+	mutex      sync.Mutex
+	replayed   int64
+	partitions map[int32]*ReplayedPartition
+	errors     []string
+	hasFailed  bool
+}
+
+func (r *messageReplayer) OnPhase(_ string)                                             {}
+func (r *messageReplayer) OnConsumeRequests(_ map[int32]*kafka.PartitionConsumeRequest) {}
+func (r *messageReplayer) OnMessageConsumed(_ int32, _ int64, _ int64)                  {}
+func (r *messageReplayer) OnMessageMatched(_ int32)                                     {}
+func (r *messageReplayer) OnMessagesDropped(_ int64)                                    {}
+func (r *messageReplayer) OnInconsistency(_ kafka.PartitionInconsistency)               {}
+func (r *messageReplayer) OnWaterMarks(_ map[int32]*kafka.WaterMark)                    {}
+func (r *messageReplayer) OnComplete(_ int64, _ bool)                                   {}
+
+func (r *messageReplayer) OnMessage(msg *kafka.TopicMessage) {
+	if r.failed() {
+		return
+	}
+
+	switch {
+	case r.req.DryRun:
+		// Messages are only counted
+	case r.req.Transactional:
+		r.pending = append(r.pending, msg)
+		if len(r.pending) >= r.req.ChunkSize {
+			r.flush()
+		}
+		return
+	default:
+		_, err := r.svc.kafkaSvc.Produce(r.record(msg), kafka.ProduceOptions{})
+		if err != nil {
+			r.fail(fmt.Sprintf("failed to replay message (partition: '%v', offset: '%v'): %v", msg.PartitionID, msg.Offset, err))
+			return
+		}
+	}
+
+	r.count(msg)
+}
+
+// flush produces the pending messages within a single transaction and counts them once it has been committed
+func (r *messageReplayer) flush() {
+	if len(r.pending) == 0 || r.failed() {
+		return
+	}
+	chunk := r.pending
+	r.pending = make([]*kafka.TopicMessage, 0, r.req.ChunkSize)
+
+	records := make([]kafka.ProduceRecord, len(chunk))
+	for i, msg := range chunk {
+		records[i] = r.record(msg)
+	}
+	_, err := r.svc.kafkaSvc.ProduceTransaction(r.transactionalID, records)
+	if err != nil {
+		first, last := chunk[0], chunk[len(chunk)-1]
+		r.fail(fmt.Sprintf("failed to replay chunk of %v messages (first: partition '%v', offset '%v', last: partition '%v', offset '%v'), the chunk has been aborted: %v",
+			len(chunk), first.PartitionID, first.Offset, last.PartitionID, last.Offset, err))
+		return
+	}
+
+	for _, msg := range chunk {
+		r.count(msg)
+	}
+}
+
+// count adds the message to the replayed messages of its partition
+func (r *messageReplayer) count(msg *kafka.TopicMessage) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.replayed++
+	partition, ok := r.partitions[msg.PartitionID]
+	if !ok {
+		partition = &ReplayedPartition{PartitionID: msg.PartitionID, FirstOffset: msg.Offset}
+		r.partitions[msg.PartitionID] = partition
+	}
+	partition.LastOffset = msg.Offset
+	partition.Messages++
+}
+
+func (r *messageReplayer) OnError(msg string) {
+	r.fail(msg)
+}
+
+func (r *messageReplayer) fail(msg string) {
+	r.mutex.Lock()
+	r.errors = append(r.errors, msg)
+	r.hasFailed = true
+	r.mutex.Unlock()
+
+	r.cancel()
+}
+
+func (r *messageReplayer) failed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.hasFailed
+}
+
+// record returns the consumed record with its original key, value and headers along with the provenance headers
+func (r *messageReplayer) record(msg *kafka.TopicMessage) kafka.ProduceRecord {
+	record := replayRecord(msg.Raw, r.req.Search.TopicName, r.req.DestinationTopic, r.replayedAt)
+	if r.req.PreservePartitions {
+		record.Partitioner = kafka.PartitionerManual
+		record.PartitionID = msg.Raw.Partition
+	}
+	return record
+}
+
+// replayRecord returns a record with the key, value and headers of the raw message, which is produced to the
+// destination topic by the hash of its key. Provenance headers refer to the raw message in the source topic.
+func replayRecord(raw *sarama.ConsumerMessage, sourceTopic string, destinationTopic string, replayedAt time.Time) kafka.ProduceRecord {
+	provenance := map[string]string{
+		ReplayHeaderSourceTopic:     sourceTopic,
+		ReplayHeaderSourcePartition: strconv.FormatInt(int64(raw.Partition), 10),
+		ReplayHeaderSourceOffset:    strconv.FormatInt(raw.Offset, 10),
+		ReplayHeaderSourceTimestamp: strconv.FormatInt(raw.Timestamp.UnixNano()/int64(time.Millisecond), 10),
+		ReplayHeaderReplayedAt:      strconv.FormatInt(replayedAt.UnixNano()/int64(time.Millisecond), 10),
+	}
+	headers := make([]sarama.RecordHeader, 0, len(raw.Headers)+len(provenance))
+	for _, h := range raw.Headers {
+		if h == nil {
+			continue
+		}
+		if _, isProvenance := provenance[string(h.Key)]; isProvenance {
+			continue
+		}
+		headers = append(headers, *h)
+	}
+	for _, key := range []string{ReplayHeaderSourceTopic, ReplayHeaderSourcePartition, ReplayHeaderSourceOffset, ReplayHeaderSourceTimestamp, ReplayHeaderReplayedAt} {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(provenance[key])})
+	}
+
+	return kafka.ProduceRecord{
+		TopicName:   destinationTopic,
+		Partitioner: kafka.PartitionerHash,
+		Key:         raw.Key,
+		Value:       raw.Value,
+		Headers:     headers,
+	}
+}
+
+func (r *messageReplayer) response(isCancelled bool) *ReplayResponse {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	partitions := make([]ReplayedPartition, 0, len(r.partitions))
+	for _, partition := range r.partitions {
+		partitions = append(partitions, *partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].PartitionID < partitions[j].PartitionID })
+
+	return &ReplayResponse{
+		SourceTopic:      r.req.Search.TopicName,
+		DestinationTopic: r.req.DestinationTopic,
+		DryRun:           r.req.DryRun,
+		ReplayedMessages: r.replayed,
+		Partitions:       partitions,
+		IsCancelled:      isCancelled,
+		Errors:           r.errors,
+	}
+}
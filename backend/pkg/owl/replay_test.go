@@ -0,0 +1,166 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReplayMessages(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 1, 1, nil, false))
+	require.NoError(t, cluster.CreateTopic("orders-dlq", 1, 1, nil, false))
+	svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+	for i := 0; i < 10; i++ {
+		_, err := cluster.Produce(kafka.ProduceRecord{
+			TopicName:   "orders-dlq",
+			Partitioner: kafka.PartitionerManual,
+			Key:         []byte("order-" + strconv.Itoa(i)),
+			Value:       []byte(`{"id": ` + strconv.Itoa(i) + `}`),
+			Headers:     []sarama.RecordHeader{{Key: []byte("trace-id"), Value: []byte("abc")}},
+		}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+
+	endOffset := int64(5)
+	req := ReplayRequest{
+		Search: ListMessageRequest{
+			TopicName:    "orders-dlq",
+			PartitionID:  partitionsAll,
+			StartOffset:  2,
+			EndOffset:    &endOffset,
+			MessageCount: 100,
+		},
+		DestinationTopic:   "orders",
+		PreservePartitions: true,
+		DryRun:             true,
+	}
+
+	// A dry run only counts the messages of the range
+	res, err := svc.ReplayMessages(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), res.ReplayedMessages)
+	assert.Equal(t, []ReplayedPartition{{PartitionID: 0, FirstOffset: 2, LastOffset: 5, Messages: 4}}, res.Partitions)
+	marks, err := cluster.WaterMarks("orders", []int32{0})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), marks[0].High)
+
+	req.DryRun = false
+	res, err = svc.ReplayMessages(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, res.IsCancelled)
+	assert.Equal(t, int64(4), res.ReplayedMessages)
+
+	// The replayed messages keep their keys, values and headers and refer to the source message
+	replayed, err := svc.CollectMessages(context.Background(), ListMessageRequest{
+		TopicName:       "orders",
+		PartitionID:     partitionsAll,
+		StartOffset:     StartOffsetOldest,
+		MessageCount:    100,
+		Predicate:       func(_ *kafka.TopicMessage, _ time.Time) bool { return true },
+		KeepRawMessages: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, replayed.Messages, 4)
+	raw := replayed.Messages[0].Raw
+	assert.Equal(t, "order-2", string(raw.Key))
+	assert.Equal(t, `{"id": 2}`, string(raw.Value))
+	headers := make(map[string]string)
+	for _, h := range raw.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	assert.Equal(t, "abc", headers["trace-id"])
+	assert.Equal(t, "orders-dlq", headers[ReplayHeaderSourceTopic])
+	assert.Equal(t, "0", headers[ReplayHeaderSourcePartition])
+	assert.Equal(t, "2", headers[ReplayHeaderSourceOffset])
+
+	// Live tails can't be replayed
+	req.Search.LiveTail = true
+	_, err = svc.ReplayMessages(context.Background(), req)
+	assert.Error(t, err)
+}
+
+// failingTransactionCluster fails the transaction with the given number (starting at 1), all others are committed
+type failingTransactionCluster struct {
+	kafka.Cluster
+	failAt       int
+	transactions int
+}
+
+func (c *failingTransactionCluster) ProduceTransaction(transactionalID string, records []kafka.ProduceRecord) ([]kafka.ProduceResult, error) {
+	c.transactions++
+	if c.transactions == c.failAt {
+		return nil, errors.New("transaction has been aborted")
+	}
+	return c.Cluster.ProduceTransaction(transactionalID, records)
+}
+
+func TestReplayMessagesTransactional(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("orders", 2, 1, nil, false))
+	require.NoError(t, cluster.CreateTopic("orders-copy", 2, 1, nil, false))
+	for i := 0; i < 8; i++ {
+		_, err := cluster.Produce(kafka.ProduceRecord{
+			TopicName:   "orders",
+			Partitioner: kafka.PartitionerManual,
+			PartitionID: int32(i % 2),
+			Key:         []byte("order-" + strconv.Itoa(i)),
+			Value:       []byte(`{"id": ` + strconv.Itoa(i) + `}`),
+		}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+	req := ReplayRequest{
+		Search: ListMessageRequest{
+			TopicName:    "orders",
+			PartitionID:  partitionsAll,
+			StartOffset:  StartOffsetOldest,
+			MessageCount: 100,
+		},
+		DestinationTopic:   "orders-copy",
+		PreservePartitions: true,
+		Transactional:      true,
+		ChunkSize:          3,
+	}
+	copied := func() int64 {
+		marks, err := cluster.HighWaterMarks(map[string][]int32{"orders-copy": {0, 1}})
+		require.NoError(t, err)
+		return marks["orders-copy"][0] + marks["orders-copy"][1]
+	}
+
+	// 8 messages are replayed in 3 transactions, the last one isn't filled up
+	failing := &failingTransactionCluster{Cluster: cluster}
+	svc := NewService(failing, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	res, err := svc.ReplayMessages(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, res.IsCancelled)
+	assert.Equal(t, int64(8), res.ReplayedMessages)
+	assert.Equal(t, 3, failing.transactions)
+	assert.Equal(t, int64(8), copied())
+
+	// If the second chunk fails, only the first one has been replayed and the replay is stopped
+	failing = &failingTransactionCluster{Cluster: cluster, failAt: 2}
+	svc = NewService(failing, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	res, err = svc.ReplayMessages(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, res.IsCancelled)
+	assert.Equal(t, int64(3), res.ReplayedMessages)
+	assert.Equal(t, 2, failing.transactions)
+	require.Len(t, res.Errors, 1)
+	assert.Contains(t, res.Errors[0], "the chunk has been aborted")
+	assert.Equal(t, int64(11), copied())
+}
@@ -0,0 +1,237 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// OffsetResetStrategy describes to which offset a consumer group's partitions shall be reset
+type OffsetResetStrategy string
+
+const (
+	OffsetResetEarliest  OffsetResetStrategy = "earliest"
+	OffsetResetLatest    OffsetResetStrategy = "latest"
+	OffsetResetOffset    OffsetResetStrategy = "offset"    // A specific offset
+	OffsetResetTimestamp OffsetResetStrategy = "timestamp" // First offset at or after the given unix milliseconds
+)
+
+// ErrGroupNotEmpty is returned if offsets shall be reset for a consumer group which still has active members
+var ErrGroupNotEmpty = errors.New("consumer group has active members")
+
+// ResetConsumerGroupOffsetsRequest describes which offsets shall be reset for a consumer group
+type ResetConsumerGroupOffsetsRequest struct {
+	GroupID string
+	Topics  []ResetOffsetsTopic
+	DryRun  bool
+}
+
+// ResetOffsetsTopic describes the reset for either all or a subset of partitions of a topic
+type ResetOffsetsTopic struct {
+	TopicName    string              `json:"topicName"`
+	PartitionIDs []int32             `json:"partitionIds"` // Empty for all partitions
+	Strategy     OffsetResetStrategy `json:"strategy"`
+	Offset       int64               `json:"offset"`    // Only used for the offset strategy
+	Timestamp    int64               `json:"timestamp"` // Only used for the timestamp strategy
+}
+
+// ResetConsumerGroupOffsetsResponse contains the old and new offset of each partition which has been reset. If the
+// request has been a dry run, the new offsets have not been committed.
+type ResetConsumerGroupOffsetsResponse struct {
+	GroupID    string                 `json:"groupId"`
+	GroupState string                 `json:"groupState"`
+	DryRun     bool                   `json:"dryRun"`
+	Partitions []PartitionOffsetReset `json:"partitions"`
+}
+
+// PartitionOffsetReset is the result of a reset for a single partition
+type PartitionOffsetReset struct {
+	TopicName     string `json:"topicName"`
+	PartitionID   int32  `json:"partitionId"`
+	CurrentOffset int64  `json:"currentOffset"` // -1 if the group has not committed an offset yet
+	NewOffset     int64  `json:"newOffset"`
+	LowWaterMark  int64  `json:"lowWaterMark"`
+	HighWaterMark int64  `json:"highWaterMark"`
+	Warning       string `json:"warning,omitempty"`
+}
+
+// ResetConsumerGroupOffsets resolves the new offsets for all requested partitions and commits them, unless the
+// request is a dry run. Offsets can only be committed if the group has no active members.
+func (s *Service) ResetConsumerGroupOffsets(ctx context.Context, req ResetConsumerGroupOffsetsRequest) (*ResetConsumerGroupOffsetsResponse, error) {
+	// 1. Check group state
+	groupState, err := s.committableGroupState(ctx, req.GroupID, req.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Get currently committed offsets
+	committed, err := s.kafkaSvc.ListConsumerGroupOffsets(req.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer group offsets: %w", err)
+	}
+
+	// 3. Resolve new offsets topic by topic
+	partitions := make([]PartitionOffsetReset, 0)
+	newOffsets := make(map[string]map[int32]kafka.GroupOffset)
+	for _, topic := range req.Topics {
+		resets, err := s.resolveOffsetResets(topic, committed)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := newOffsets[topic.TopicName]; !exists {
+			newOffsets[topic.TopicName] = make(map[int32]kafka.GroupOffset)
+		}
+		for _, reset := range resets {
+			newOffsets[topic.TopicName][reset.PartitionID] = kafka.GroupOffset{Offset: reset.NewOffset}
+		}
+		partitions = append(partitions, resets...)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].TopicName != partitions[j].TopicName {
+			return partitions[i].TopicName < partitions[j].TopicName
+		}
+		return partitions[i].PartitionID < partitions[j].PartitionID
+	})
+
+	// 4. Commit offsets
+	if err := s.commitGroupOffsets(req.GroupID, newOffsets, req.DryRun); err != nil {
+		return nil, err
+	}
+
+	return &ResetConsumerGroupOffsetsResponse{
+		GroupID:    req.GroupID,
+		GroupState: groupState,
+		DryRun:     req.DryRun,
+		Partitions: partitions,
+	}, nil
+}
+
+// committableGroupState returns the state of the group. Kafka rejects commits for groups with active members, so
+// ErrGroupNotEmpty is returned for these groups unless the offsets are only previewed in a dry run.
+func (s *Service) committableGroupState(ctx context.Context, groupID string, dryRun bool) (string, error) {
+	groupState, err := s.consumerGroupState(ctx, groupID)
+	if err != nil {
+		return "", err
+	}
+	if !dryRun && !isInactiveGroupState(groupState) {
+		return "", fmt.Errorf("%w: group state is '%v', all consumers must be stopped before offsets can be committed", ErrGroupNotEmpty, groupState)
+	}
+
+	return groupState, nil
+}
+
+// commitGroupOffsets commits the new offsets of the group, unless they are only previewed in a dry run
+func (s *Service) commitGroupOffsets(groupID string, offsets map[string]map[int32]kafka.GroupOffset, dryRun bool) error {
+	if dryRun || len(offsets) == 0 {
+		return nil
+	}
+	err := s.kafkaSvc.CommitConsumerGroupOffsetsWithMetadata(groupID, offsets)
+	if err != nil {
+		return fmt.Errorf("failed to commit consumer group offsets: %w", err)
+	}
+
+	return nil
+}
+
+// consumerGroupState returns the state of the group, which is empty if the coordinator doesn't know the group
+func (s *Service) consumerGroupState(ctx context.Context, groupID string) (string, error) {
+	describedGroups, err := s.kafkaSvc.DescribeConsumerGroups(ctx, []string{groupID})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe consumer group: %w", err)
+	}
+	groupState := ""
+	for _, res := range describedGroups {
+		for _, g := range res.Groups {
+			if g.GroupId == groupID {
+				groupState = g.State
+			}
+		}
+	}
+
+	return groupState, nil
+}
+
+// isInactiveGroupState returns true if the group has no active members, which is required to commit its offsets
+func isInactiveGroupState(state string) bool {
+	return state == "Empty" || state == "Dead"
+}
+
+func (s *Service) resolveOffsetResets(topic ResetOffsetsTopic, committed *sarama.OffsetFetchResponse) ([]PartitionOffsetReset, error) {
+	partitionIDs := topic.PartitionIDs
+	if len(partitionIDs) == 0 {
+		var err error
+		partitionIDs, err = s.kafkaSvc.ListPartitions(topic.TopicName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	waterMarks, err := s.kafkaSvc.WaterMarks(topic.TopicName, partitionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get water marks for topic '%v': %w", topic.TopicName, err)
+	}
+
+	var offsetsByTime map[int32]int64
+	if topic.Strategy == OffsetResetTimestamp {
+		offsetsByTime, err = s.kafkaSvc.OffsetsForTimes(topic.TopicName, partitionIDs, topic.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get offsets for timestamp in topic '%v': %w", topic.TopicName, err)
+		}
+	}
+
+	resets := make([]PartitionOffsetReset, len(partitionIDs))
+	for i, partitionID := range partitionIDs {
+		mark, exists := waterMarks[partitionID]
+		if !exists {
+			return nil, fmt.Errorf("partition '%v' does not exist in topic '%v'", partitionID, topic.TopicName)
+		}
+
+		currentOffset := int64(-1)
+		if block := committed.GetBlock(topic.TopicName, partitionID); block != nil && block.Err == sarama.ErrNoError {
+			currentOffset = block.Offset
+		}
+
+		reset := PartitionOffsetReset{
+			TopicName:     topic.TopicName,
+			PartitionID:   partitionID,
+			CurrentOffset: currentOffset,
+			LowWaterMark:  mark.Low,
+			HighWaterMark: mark.High,
+		}
+		switch topic.Strategy {
+		case OffsetResetEarliest:
+			reset.NewOffset = mark.Low
+		case OffsetResetLatest:
+			reset.NewOffset = mark.High
+		case OffsetResetOffset:
+			reset.NewOffset, reset.Warning = clampOffset(topic.Offset, mark)
+		case OffsetResetTimestamp:
+			offset, exists := offsetsByTime[partitionID]
+			if !exists || offset < 0 {
+				// No message has been written at or after the timestamp
+				offset = mark.High
+			}
+			reset.NewOffset = offset
+		default:
+			return nil, fmt.Errorf("unknown offset reset strategy '%v'", topic.Strategy)
+		}
+		resets[i] = reset
+	}
+
+	return resets, nil
+}
+
+// clampOffset ensures that the offset is within the partition's water marks and returns a warning if it was not
+func clampOffset(offset int64, mark *kafka.WaterMark) (int64, string) {
+	if offset < mark.Low {
+		return mark.Low, fmt.Sprintf("offset %v is below the low water mark, using %v instead", offset, mark.Low)
+	}
+	if offset > mark.High {
+		return mark.High, fmt.Sprintf("offset %v is above the high water mark, using %v instead", offset, mark.High)
+	}
+	return offset, ""
+}
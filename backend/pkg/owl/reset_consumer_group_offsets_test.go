@@ -0,0 +1,166 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newResetTestCluster creates the topic "shipments" with 2 partitions, each containing 5 records of which the first 2
+// have been deleted. The group "billing" has committed offset 3 for both partitions.
+func newResetTestCluster(t *testing.T) *kafka.FakeCluster {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 1 // Creates the group "orders-processor" which has active members
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("shipments", 2, 1, nil, false))
+	for p := int32(0); p < 2; p++ {
+		for i := 0; i < 5; i++ {
+			_, err := cluster.Produce(kafka.ProduceRecord{TopicName: "shipments", Partitioner: kafka.PartitionerManual, PartitionID: p, Value: []byte("{}")}, kafka.ProduceOptions{})
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, cluster.DeleteRecords("shipments", map[int32]int64{0: 2, 1: 2}))
+	require.NoError(t, cluster.CommitConsumerGroupOffsets("billing", map[string]map[int32]int64{"shipments": {0: 3, 1: 3}}))
+
+	return cluster
+}
+
+func TestResetConsumerGroupOffsetsStrategies(t *testing.T) {
+	future := time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+
+	tt := []struct {
+		topic    ResetOffsetsTopic
+		expected []int64 // New offset of partition 0 and 1
+		warning  bool
+	}{
+		{ResetOffsetsTopic{TopicName: "shipments", Strategy: OffsetResetEarliest}, []int64{2, 2}, false},
+		{ResetOffsetsTopic{TopicName: "shipments", Strategy: OffsetResetLatest}, []int64{5, 5}, false},
+		{ResetOffsetsTopic{TopicName: "shipments", Strategy: OffsetResetOffset, Offset: 4}, []int64{4, 4}, false},
+		{ResetOffsetsTopic{TopicName: "shipments", Strategy: OffsetResetOffset, Offset: 0}, []int64{2, 2}, true},
+		{ResetOffsetsTopic{TopicName: "shipments", Strategy: OffsetResetOffset, Offset: 100}, []int64{5, 5}, true},
+		{ResetOffsetsTopic{TopicName: "shipments", Strategy: OffsetResetTimestamp, Timestamp: 0}, []int64{2, 2}, false},
+		// No message has been written after the timestamp, the partitions are reset to the high water mark
+		{ResetOffsetsTopic{TopicName: "shipments", Strategy: OffsetResetTimestamp, Timestamp: future}, []int64{5, 5}, false},
+	}
+
+	for i, test := range tt {
+		cluster := newResetTestCluster(t)
+		svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+		req := ResetConsumerGroupOffsetsRequest{GroupID: "billing", Topics: []ResetOffsetsTopic{test.topic}}
+		res, err := svc.ResetConsumerGroupOffsets(context.Background(), req)
+		require.NoError(t, err, "Case: ", i)
+		require.Len(t, res.Partitions, 2, "Case: ", i)
+		for p, reset := range res.Partitions {
+			assert.Equal(t, int32(p), reset.PartitionID, "Case: ", i)
+			assert.Equal(t, int64(3), reset.CurrentOffset, "Case: ", i)
+			assert.Equal(t, test.expected[p], reset.NewOffset, "Case: ", i)
+			assert.Equal(t, int64(2), reset.LowWaterMark, "Case: ", i)
+			assert.Equal(t, int64(5), reset.HighWaterMark, "Case: ", i)
+			assert.Equal(t, test.warning, reset.Warning != "", "Case: ", i)
+		}
+
+		committed, err := cluster.ListConsumerGroupOffsets("billing")
+		require.NoError(t, err, "Case: ", i)
+		for p := int32(0); p < 2; p++ {
+			assert.Equal(t, test.expected[p], committed.GetBlock("shipments", p).Offset, "Case: ", i)
+		}
+	}
+}
+
+func TestResetConsumerGroupOffsetsDryRun(t *testing.T) {
+	cluster := newResetTestCluster(t)
+	svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+	req := ResetConsumerGroupOffsetsRequest{
+		GroupID: "billing",
+		Topics:  []ResetOffsetsTopic{{TopicName: "shipments", PartitionIDs: []int32{1}, Strategy: OffsetResetLatest}},
+		DryRun:  true,
+	}
+	res, err := svc.ResetConsumerGroupOffsets(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, res.DryRun)
+	require.Len(t, res.Partitions, 1)
+	assert.Equal(t, int64(5), res.Partitions[0].NewOffset)
+
+	committed, err := cluster.ListConsumerGroupOffsets("billing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), committed.GetBlock("shipments", 1).Offset)
+}
+
+func TestResetConsumerGroupOffsetsRefusesNonEmptyGroup(t *testing.T) {
+	cluster := newResetTestCluster(t)
+	svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	before, err := cluster.ListConsumerGroupOffsets("orders-processor")
+	require.NoError(t, err)
+
+	req := ResetConsumerGroupOffsetsRequest{
+		GroupID: "orders-processor",
+		Topics:  []ResetOffsetsTopic{{TopicName: "orders", Strategy: OffsetResetEarliest}},
+	}
+	_, err = svc.ResetConsumerGroupOffsets(context.Background(), req)
+	assert.True(t, errors.Is(err, ErrGroupNotEmpty))
+
+	// Dry runs are allowed while the group is active so that the reset can be previewed
+	req.DryRun = true
+	res, err := svc.ResetConsumerGroupOffsets(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "Stable", res.GroupState)
+
+	after, err := cluster.ListConsumerGroupOffsets("orders-processor")
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+// missingOffsetsForTimesCluster returns no offset for any partition, like brokers do for partitions whose offsets
+// could not be resolved
+type missingOffsetsForTimesCluster struct {
+	kafka.Cluster
+}
+
+func (c missingOffsetsForTimesCluster) OffsetsForTimes(_ string, _ []int32, _ int64) (map[int32]int64, error) {
+	return map[int32]int64{}, nil
+}
+
+func TestResolveOffsetResetsMissingOffsetForTime(t *testing.T) {
+	cluster := newResetTestCluster(t)
+	svc := NewService(missingOffsetsForTimesCluster{cluster}, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	committed, err := cluster.ListConsumerGroupOffsets("billing")
+	require.NoError(t, err)
+
+	resets, err := svc.resolveOffsetResets(ResetOffsetsTopic{TopicName: "shipments", Strategy: OffsetResetTimestamp}, committed)
+	require.NoError(t, err)
+	require.Len(t, resets, 2)
+	for _, reset := range resets {
+		assert.Equal(t, int64(5), reset.NewOffset)
+	}
+}
+
+func TestClampOffset(t *testing.T) {
+	mark := &kafka.WaterMark{Low: 2, High: 5}
+
+	tt := []struct {
+		offset   int64
+		expected int64
+		warning  bool
+	}{
+		{1, 2, true},
+		{2, 2, false},
+		{4, 4, false},
+		{5, 5, false},
+		{6, 5, true},
+	}
+
+	for i, test := range tt {
+		offset, warning := clampOffset(test.offset, mark)
+		assert.Equal(t, test.expected, offset, "Case: ", i)
+		assert.Equal(t, test.warning, warning != "", "Case: ", i)
+	}
+}
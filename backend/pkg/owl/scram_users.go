@@ -0,0 +1,140 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// Iteration bounds which are accepted by Kafka for SCRAM credentials
+const (
+	ScramMinIterations = 4096
+	ScramMaxIterations = 16384
+)
+
+var (
+	// ErrInvalidScramCredential is returned if a credential has invalid values or has been rejected by Kafka
+	ErrInvalidScramCredential = errors.New("invalid scram credential")
+	// ErrScramCredentialNotFound is returned if a credential which shall be deleted doesn't exist
+	ErrScramCredentialNotFound = errors.New("scram credential not found")
+)
+
+// ScramUser is a user which has at least one SCRAM credential
+type ScramUser struct {
+	Name        string            `json:"name"`
+	Credentials []ScramCredential `json:"credentials"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// ScramCredential describes a credential without its salted password, which Kafka never returns
+type ScramCredential struct {
+	Mechanism  string `json:"mechanism"`
+	Iterations int32  `json:"iterations"`
+}
+
+// UpsertScramCredentialRequest creates a credential or replaces the user's credential of the same mechanism. The
+// iterations default to ScramMinIterations.
+type UpsertScramCredentialRequest struct {
+	Username   string `json:"username"`
+	Mechanism  string `json:"mechanism"`
+	Iterations int32  `json:"iterations"`
+	Password   string `json:"password"`
+}
+
+// ListScramUsers returns all users with SCRAM credentials
+func (s *Service) ListScramUsers(_ context.Context) ([]ScramUser, error) {
+	described, err := s.kafkaSvc.DescribeUserScramCredentials(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]ScramUser, len(described))
+	for i, d := range described {
+		users[i] = ScramUser{Name: d.User, Credentials: make([]ScramCredential, len(d.Credentials))}
+		if d.Err != sarama.ErrNoError {
+			users[i].Error = scramErrorMessage(d.Err, d.ErrMessage)
+		}
+		for j, c := range d.Credentials {
+			users[i].Credentials[j] = ScramCredential{Mechanism: c.Mechanism.String(), Iterations: c.Iterations}
+		}
+	}
+
+	return users, nil
+}
+
+// UpsertScramCredential creates or replaces a SCRAM credential of a user and returns the stored credential
+func (s *Service) UpsertScramCredential(_ context.Context, req UpsertScramCredentialRequest) (*ScramCredential, error) {
+	if req.Username == "" {
+		return nil, fmt.Errorf("%w: username must be set", ErrInvalidScramCredential)
+	}
+	if req.Password == "" {
+		return nil, fmt.Errorf("%w: password must be set", ErrInvalidScramCredential)
+	}
+	mechanism, err := kafka.ParseScramMechanism(req.Mechanism)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScramCredential, err)
+	}
+	iterations := req.Iterations
+	if iterations == 0 {
+		iterations = ScramMinIterations
+	}
+	if iterations < ScramMinIterations || iterations > ScramMaxIterations {
+		return nil, fmt.Errorf("%w: iterations must be between %v and %v", ErrInvalidScramCredential, ScramMinIterations, ScramMaxIterations)
+	}
+
+	upsertion := kafka.ScramCredentialUpsertion{User: req.Username, Mechanism: mechanism, Iterations: iterations, Password: req.Password}
+	results, err := s.kafkaSvc.AlterUserScramCredentials([]kafka.ScramCredentialUpsertion{upsertion}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := scramAlterationError(results); err != nil {
+		return nil, err
+	}
+
+	return &ScramCredential{Mechanism: mechanism.String(), Iterations: iterations}, nil
+}
+
+// DeleteScramCredential deletes the user's SCRAM credential of the given mechanism
+func (s *Service) DeleteScramCredential(_ context.Context, username string, mechanismName string) error {
+	mechanism, err := kafka.ParseScramMechanism(mechanismName)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidScramCredential, err)
+	}
+
+	deletion := kafka.ScramCredentialDeletion{User: username, Mechanism: mechanism}
+	results, err := s.kafkaSvc.AlterUserScramCredentials(nil, []kafka.ScramCredentialDeletion{deletion})
+	if err != nil {
+		return err
+	}
+
+	return scramAlterationError(results)
+}
+
+// scramAlterationError returns the first error of the alteration results, mapped to our errors where possible
+func scramAlterationError(results []kafka.ScramAlterationResult) error {
+	for _, r := range results {
+		switch r.Err {
+		case sarama.ErrNoError:
+			continue
+		case kafka.ErrResourceNotFound:
+			return fmt.Errorf("%w: %v", ErrScramCredentialNotFound, scramErrorMessage(r.Err, r.ErrMessage))
+		case kafka.ErrUnacceptableCredential, kafka.ErrDuplicateResource:
+			return fmt.Errorf("%w: %v", ErrInvalidScramCredential, scramErrorMessage(r.Err, r.ErrMessage))
+		default:
+			return fmt.Errorf("failed to alter scram credentials of user '%v': %w: %v", r.User, r.Err, r.ErrMessage)
+		}
+	}
+
+	return nil
+}
+
+// scramErrorMessage prefers Kafka's error message, because sarama has no description of the SCRAM error codes
+func scramErrorMessage(err sarama.KError, msg string) string {
+	if msg != "" {
+		return msg
+	}
+	return err.Error()
+}
@@ -0,0 +1,64 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestScramCredentials(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	svc := NewService(kafka.NewFakeCluster(fakeCfg, zap.NewNop()), nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+	ctx := context.Background()
+
+	credential, err := svc.UpsertScramCredential(ctx, UpsertScramCredentialRequest{Username: "alice", Mechanism: "SCRAM-SHA-256", Password: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, &ScramCredential{Mechanism: "SCRAM-SHA-256", Iterations: ScramMinIterations}, credential)
+	_, err = svc.UpsertScramCredential(ctx, UpsertScramCredentialRequest{Username: "alice", Mechanism: "scram-sha-512", Iterations: 8192, Password: "secret"})
+	require.NoError(t, err)
+	_, err = svc.UpsertScramCredential(ctx, UpsertScramCredentialRequest{Username: "bob", Mechanism: "SCRAM-SHA-512", Password: "secret"})
+	require.NoError(t, err)
+
+	users, err := svc.ListScramUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []ScramUser{
+		{Name: "alice", Credentials: []ScramCredential{{Mechanism: "SCRAM-SHA-256", Iterations: 4096}, {Mechanism: "SCRAM-SHA-512", Iterations: 8192}}},
+		{Name: "bob", Credentials: []ScramCredential{{Mechanism: "SCRAM-SHA-512", Iterations: 4096}}},
+	}, users)
+
+	require.NoError(t, svc.DeleteScramCredential(ctx, "bob", "SCRAM-SHA-512"))
+	err = svc.DeleteScramCredential(ctx, "bob", "SCRAM-SHA-512")
+	assert.True(t, errors.Is(err, ErrScramCredentialNotFound))
+
+	users, err = svc.ListScramUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "alice", users[0].Name)
+}
+
+func TestUpsertScramCredentialValidation(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	svc := NewService(kafka.NewFakeCluster(fakeCfg, zap.NewNop()), nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+	tt := []UpsertScramCredentialRequest{
+		{Username: "", Mechanism: "SCRAM-SHA-256", Password: "secret"},
+		{Username: "alice", Mechanism: "SCRAM-SHA-256", Password: ""},
+		{Username: "alice", Mechanism: "PLAIN", Password: "secret"},
+		{Username: "alice", Mechanism: "SCRAM-SHA-256", Iterations: 1000, Password: "secret"},
+		{Username: "alice", Mechanism: "SCRAM-SHA-256", Iterations: 20000, Password: "secret"},
+	}
+
+	for i, req := range tt {
+		_, err := svc.UpsertScramCredential(context.Background(), req)
+		assert.True(t, errors.Is(err, ErrInvalidScramCredential), "Case: ", i)
+	}
+}
@@ -2,20 +2,43 @@ package owl
 
 import (
 	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/proto"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
 	"go.uber.org/zap"
 )
 
 // Service offers all methods to serve the responses for the REST API. This usually only involves fetching
 // serveral responses from Kafka concurrently and constructing them so, that they are
 type Service struct {
-	kafkaSvc *kafka.Service
-	logger   *zap.Logger
+	kafkaSvc  kafka.Cluster
+	protoSvc  *proto.Service
+	schemaSvc *schema.Service
+	metrics   *kafka.MessageMetrics
+	limits    ConsumeLimits
+	activity  *partitionActivity
+	logger    *zap.Logger
 }
 
-// NewService for the Owl package
-func NewService(kafkaSvc *kafka.Service, logger *zap.Logger) *Service {
+// ConsumeLimits bound the resources of message searches, zero values are unlimited
+type ConsumeLimits struct {
+	// MaxConcurrentPartitions is the number of partitions a search consumes at the same time. Live tails and
+	// searches which are ordered by timestamp consume all partitions at the same time regardless.
+	MaxConcurrentPartitions int
+
+	// InFlightBytes may be shared with the services of other clusters, so that it bounds all searches
+	InFlightBytes *kafka.ByteBudget
+}
+
+// NewService for the Owl package. The proto and schema services may be nil if proto deserialization or the
+// schema registry is disabled, the metrics may be nil if message searches shall not be instrumented.
+func NewService(kafkaSvc kafka.Cluster, protoSvc *proto.Service, schemaSvc *schema.Service, metrics *kafka.MessageMetrics, limits ConsumeLimits, logger *zap.Logger) *Service {
 	return &Service{
-		kafkaSvc: kafkaSvc,
-		logger:   logger,
+		kafkaSvc:  kafkaSvc,
+		protoSvc:  protoSvc,
+		schemaSvc: schemaSvc,
+		metrics:   metrics,
+		limits:    limits,
+		activity:  newPartitionActivity(),
+		logger:    logger,
 	}
 }
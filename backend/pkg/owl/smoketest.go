@@ -0,0 +1,172 @@
+package owl
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+const (
+	// smokeTestMarkerKey is the key of all records which are produced by smoke tests, so that consumers of the test
+	// topic can ignore them
+	smokeTestMarkerKey = "kowl-smoke-test"
+
+	// smokeTestHeader carries the id of the smoke test which has produced the record
+	smokeTestHeader = "kowl-smoke-test-id"
+)
+
+// Steps of a smoke test, reported as the failed step if the cluster didn't pass the test
+const (
+	SmokeTestStepProduce = "produce"
+	SmokeTestStepConsume = "consume"
+	SmokeTestStepVerify  = "verify"
+)
+
+// SmokeTestRequest describes the topic a smoke test produces its marker record to
+type SmokeTestRequest struct {
+	TopicName string
+
+	// Timeout bounds the whole test. The marker must have been produced and consumed back before it expires.
+	Timeout time.Duration
+}
+
+// SmokeTestResult describes whether the marker record could be produced and consumed back. Latencies of the steps
+// which haven't been completed are zero.
+type SmokeTestResult struct {
+	TopicName   string `json:"topicName"`
+	MarkerID    string `json:"markerId"`
+	PartitionID int32  `json:"partitionId"`
+	Offset      int64  `json:"offset"`
+
+	Success    bool   `json:"success"`
+	FailedStep string `json:"failedStep,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	ProduceLatencyMs   int64 `json:"produceLatencyMs"`  // Until the marker has been acknowledged
+	ConsumeLatencyMs   int64 `json:"consumeLatencyMs"`  // From the acknowledgement until the marker has been consumed
+	EndToEndLatencyMs  int64 `json:"endToEndLatencyMs"` // From producing until the marker has been consumed
+	TimeoutMs          int64 `json:"timeoutMs"`
+	StartedAtTimestamp int64 `json:"startedAtTimestamp"` // Unix milliseconds
+}
+
+// RunSmokeTest produces a marker record to the test topic and consumes it back, so that it can be confirmed that the
+// cluster accepts and serves records end to end. Failures of the cluster are reported in the result, an error is
+// only returned if the test could not be run at all.
+func (s *Service) RunSmokeTest(ctx context.Context, req SmokeTestRequest) (*SmokeTestResult, error) {
+	markerID, err := newSmokeTestMarkerID()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, req.Timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	res := &SmokeTestResult{
+		TopicName:          req.TopicName,
+		MarkerID:           markerID,
+		PartitionID:        -1,
+		Offset:             -1,
+		TimeoutMs:          req.Timeout.Milliseconds(),
+		StartedAtTimestamp: startedAt.UnixNano() / int64(time.Millisecond),
+	}
+	fail := func(step string, err error) (*SmokeTestResult, error) {
+		res.FailedStep = step
+		res.Error = err.Error()
+		return res, nil
+	}
+
+	// 1. Produce the marker, the random partitioner spreads the markers of subsequent tests across all partitions
+	record := newSmokeTestRecord(req.TopicName, markerID)
+	produced, err := s.kafkaSvc.Produce(record, kafka.ProduceOptions{})
+	if err != nil {
+		return fail(SmokeTestStepProduce, err)
+	}
+	acknowledgedAt := time.Now()
+	res.PartitionID, res.Offset = produced.PartitionID, produced.Offset
+	res.ProduceLatencyMs = acknowledgedAt.Sub(startedAt).Milliseconds()
+
+	// 2. Consume the marker back from the offset it has been written to
+	msg, err := s.consumeSmokeTestRecord(ctx, req.TopicName, produced.PartitionID, produced.Offset)
+	if err != nil {
+		return fail(SmokeTestStepConsume, err)
+	}
+	consumedAt := time.Now()
+	res.ConsumeLatencyMs = consumedAt.Sub(acknowledgedAt).Milliseconds()
+	res.EndToEndLatencyMs = consumedAt.Sub(startedAt).Milliseconds()
+
+	// 3. Verify that the consumed record is the marker which has been produced
+	if err := verifySmokeTestRecord(msg, markerID); err != nil {
+		return fail(SmokeTestStepVerify, err)
+	}
+	res.Success = true
+
+	return res, nil
+}
+
+// consumeSmokeTestRecord returns the first record at or after the offset
+func (s *Service) consumeSmokeTestRecord(ctx context.Context, topicName string, partitionID int32, offset int64) (*sarama.ConsumerMessage, error) {
+	consumer, err := s.kafkaSvc.NewConsumer(sarama.ReadUncommitted)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create consumer: %w", err)
+	}
+	defer consumer.Close()
+	pc, err := consumer.ConsumePartition(topicName, partitionID, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume partition: %w", err)
+	}
+	defer pc.Close()
+
+	select {
+	case msg := <-pc.Messages():
+		if msg == nil {
+			return nil, fmt.Errorf("partition consumer has been closed before the marker has been consumed")
+		}
+		return msg, nil
+	case consumerErr := <-pc.Errors():
+		if consumerErr == nil {
+			return nil, fmt.Errorf("partition consumer has been closed before the marker has been consumed")
+		}
+		return nil, fmt.Errorf("failed to consume marker: %w", consumerErr.Err)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("marker has not been consumed before the test timed out: %w", ctx.Err())
+	}
+}
+
+func newSmokeTestMarkerID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate marker id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newSmokeTestRecord returns the marker record. The marker id is used as value, so that the key stays the same for
+// all markers and compacted test topics only retain the latest one.
+func newSmokeTestRecord(topicName string, markerID string) kafka.ProduceRecord {
+	return kafka.ProduceRecord{
+		TopicName:   topicName,
+		Partitioner: kafka.PartitionerRandom,
+		Key:         []byte(smokeTestMarkerKey),
+		Value:       []byte(markerID),
+		Headers:     []sarama.RecordHeader{{Key: []byte(smokeTestHeader), Value: []byte(markerID)}},
+	}
+}
+
+// verifySmokeTestRecord returns an error if the consumed record is not the marker with the given id
+func verifySmokeTestRecord(msg *sarama.ConsumerMessage, markerID string) error {
+	if !bytes.Equal(msg.Key, []byte(smokeTestMarkerKey)) || !bytes.Equal(msg.Value, []byte(markerID)) {
+		return fmt.Errorf("record at offset '%v' is not the produced marker, the partition may have been truncated", msg.Offset)
+	}
+	for _, h := range msg.Headers {
+		if h != nil && bytes.Equal(h.Key, []byte(smokeTestHeader)) && bytes.Equal(h.Value, []byte(markerID)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("record at offset '%v' lacks the marker's '%v' header", msg.Offset, smokeTestHeader)
+}
@@ -0,0 +1,51 @@
+package owl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRunSmokeTest(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("smoke-test", 3, 1, nil, false))
+	svc := NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop())
+
+	res, err := svc.RunSmokeTest(context.Background(), SmokeTestRequest{TopicName: "smoke-test", Timeout: 5 * time.Second})
+	require.NoError(t, err)
+	assert.True(t, res.Success, res.Error)
+	assert.Empty(t, res.FailedStep)
+	assert.Len(t, res.MarkerID, 16)
+	assert.Equal(t, int64(0), res.Offset)
+	assert.True(t, res.EndToEndLatencyMs >= res.ProduceLatencyMs)
+
+	// Cluster failures are reported in the result
+	res, err = svc.RunSmokeTest(context.Background(), SmokeTestRequest{TopicName: "missing", Timeout: 5 * time.Second})
+	require.NoError(t, err)
+	assert.False(t, res.Success)
+	assert.Equal(t, SmokeTestStepProduce, res.FailedStep)
+	assert.Equal(t, int32(-1), res.PartitionID)
+}
+
+func TestVerifySmokeTestRecord(t *testing.T) {
+	record := newSmokeTestRecord("smoke-test", "abc")
+	msg := &sarama.ConsumerMessage{
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: []*sarama.RecordHeader{&record.Headers[0]},
+	}
+	assert.NoError(t, verifySmokeTestRecord(msg, "abc"))
+	assert.Error(t, verifySmokeTestRecord(msg, "def"))
+
+	msg.Headers = nil
+	assert.Error(t, verifySmokeTestRecord(msg, "abc"))
+}
@@ -0,0 +1,103 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+)
+
+// FilterTestResult is the outcome of running filter code against a sample of recent messages
+type FilterTestResult struct {
+	SampleSize   int                      `json:"sampleSize"`
+	MatchedCount int                      `json:"matchedCount"`
+	ErrorCount   int                      `json:"errorCount"`
+	Evaluations  []kafka.FilterEvaluation `json:"evaluations"`
+}
+
+// TestFilter fetches the most recent messages of a topic (without any filter) and evaluates the given filter code
+// against each of them, so that users can debug their code before starting a full scan. The used CPU time is
+// accounted on the budget, requests are rejected right away if it has been exhausted already. Messages are decrypted
+// and masked like they are when searching, so that the code is tested against the same payloads. The budget, the
+// decrypter and the masker may be nil.
+func (s *Service) TestFilter(ctx context.Context, topicName string, partitionID int32, sampleSize uint16, code string, limits filter.Limits, budget *filter.Budget, decrypter *decryption.TopicDecrypter, masker *masking.Masker) (*FilterTestResult, error) {
+	if err := budget.Consume(0); err != nil {
+		return nil, err
+	}
+
+	collector := &messageCollector{mutex: &sync.Mutex{}}
+	listReq := ListMessageRequest{
+		TopicName:    topicName,
+		PartitionID:  partitionID,
+		StartOffset:  StartOffsetRecent,
+		MessageCount: int64(sampleSize),
+		Decrypter:    decrypter,
+		Masker:       masker,
+	}
+	err := s.ListMessages(ctx, listReq, collector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sample messages: %w", err)
+	}
+	if len(collector.errors) > 0 {
+		return nil, fmt.Errorf("failed to fetch sample messages: %v", collector.errors[0])
+	}
+
+	evaluations, err := kafka.EvaluateFilter(ctx, code, limits, budget, collector.messages, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &FilterTestResult{
+		SampleSize:  len(evaluations),
+		Evaluations: evaluations,
+	}
+	for _, e := range evaluations {
+		if e.Error != "" {
+			res.ErrorCount++
+		}
+		if e.IsMatch {
+			res.MatchedCount++
+		}
+	}
+
+	return res, nil
+}
+
+// messageCollector implements kafka.IListMessagesProgress and collects all messages instead of streaming them
+type messageCollector struct {
+	mutex    *sync.Mutex
+	messages []*kafka.TopicMessage
+	errors   []string
+}
+
+func (m *messageCollector) OnPhase(_ string) {}
+
+func (m *messageCollector) OnMessage(message *kafka.TopicMessage) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, message)
+}
+
+func (m *messageCollector) OnConsumeRequests(_ map[int32]*kafka.PartitionConsumeRequest) {}
+
+func (m *messageCollector) OnMessageConsumed(_ int32, _ int64, _ int64) {}
+
+func (m *messageCollector) OnMessageMatched(_ int32) {}
+
+func (m *messageCollector) OnMessagesDropped(_ int64) {}
+
+func (m *messageCollector) OnWaterMarks(_ map[int32]*kafka.WaterMark) {}
+
+func (m *messageCollector) OnInconsistency(_ kafka.PartitionInconsistency) {}
+
+func (m *messageCollector) OnComplete(_ int64, _ bool) {}
+
+func (m *messageCollector) OnError(msg string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.errors = append(m.errors, msg)
+}
@@ -0,0 +1,88 @@
+package owl
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newEncryptedTopicService creates the topic "payments" whose only message has the envelope encrypted value
+// {"amount": 42}, and returns the decrypter of the topic. The KMS unwraps every data key to the same key.
+func newEncryptedTopicService(t *testing.T) (*Service, *decryption.TopicDecrypter) {
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	kms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"plaintext": "` + base64.StdEncoding.EncodeToString(dataKey) + `"}`))
+	}))
+	t.Cleanup(kms.Close)
+
+	block, err := aes.NewCipher(dataKey)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	encrypted := gcm.Seal(nonce, nonce, []byte(`{"amount": 42}`), nil)
+
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+	require.NoError(t, cluster.CreateTopic("payments", 1, 1, nil, false))
+	_, err = cluster.Produce(kafka.ProduceRecord{
+		TopicName:   "payments",
+		Partitioner: kafka.PartitionerManual,
+		Value:       encrypted,
+		Headers:     []sarama.RecordHeader{{Key: []byte("encryption-data-key"), Value: []byte("wrapped")}},
+	}, kafka.ProduceOptions{})
+	require.NoError(t, err)
+
+	cfg := decryption.Config{Enabled: true}
+	cfg.SetDefaults()
+	cfg.Envelope.KMS.URL = kms.URL
+	cfg.Rules = []decryption.Rule{{TopicPattern: "payments", Values: true}}
+	decryptionSvc, err := decryption.NewService(cfg)
+	require.NoError(t, err)
+
+	return NewService(cluster, nil, nil, nil, ConsumeLimits{}, zap.NewNop()), decryptionSvc.TopicDecrypter("payments")
+}
+
+func TestTestFilterDecryptsMessages(t *testing.T) {
+	svc, decrypter := newEncryptedTopicService(t)
+	code := "return value.amount == 42"
+	limits := filter.Limits{Timeout: time.Second}
+
+	// The code is tested against the same payloads it sees when searching
+	res, err := svc.TestFilter(context.Background(), "payments", 0, 10, code, limits, nil, decrypter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.SampleSize)
+	assert.Equal(t, 1, res.MatchedCount)
+
+	res, err = svc.TestFilter(context.Background(), "payments", 0, 10, code, limits, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.MatchedCount)
+}
+
+func TestGetFilterTypingsDecryptsMessages(t *testing.T) {
+	svc, decrypter := newEncryptedTopicService(t)
+
+	typings, err := svc.GetFilterTypings(context.Background(), "payments", 10, decrypter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, typings.SampleSize)
+	assert.Contains(t, typings.Typings, "amount")
+
+	typings, err = svc.GetFilterTypings(context.Background(), "payments", 10, nil, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, typings.Typings, "amount")
+}
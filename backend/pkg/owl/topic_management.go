@@ -0,0 +1,230 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	// ErrTopicNotFound is returned if the topic which shall be deleted or edited does not exist
+	ErrTopicNotFound = errors.New("topic not found")
+	// ErrTopicAlreadyExists is returned if a topic with the same name already exists
+	ErrTopicAlreadyExists = errors.New("topic already exists")
+	// ErrInvalidTopicRequest is returned if the request has been rejected by our or the broker's validation
+	ErrInvalidTopicRequest = errors.New("invalid topic request")
+)
+
+// topicNamePattern matches the legal characters and length of topic names as enforced by Kafka
+var topicNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// CreateTopicRequest carries the settings of a topic which shall be created
+type CreateTopicRequest struct {
+	TopicName         string            `json:"topicName"`
+	PartitionCount    int32             `json:"partitionCount"`
+	ReplicationFactor int16             `json:"replicationFactor"`
+	Configs           map[string]string `json:"configs"`
+	DryRun            bool              `json:"dryRun"`
+}
+
+// CreateTopicResponse confirms the created (or in dry-run mode validated) topic settings
+type CreateTopicResponse struct {
+	TopicName         string            `json:"topicName"`
+	PartitionCount    int32             `json:"partitionCount"`
+	ReplicationFactor int16             `json:"replicationFactor"`
+	Configs           map[string]string `json:"configs"`
+	DryRun            bool              `json:"dryRun"`
+}
+
+// AddPartitionsRequest increases the partition count of a topic. Partitions can't be removed.
+type AddPartitionsRequest struct {
+	TopicName      string `json:"topicName"`
+	PartitionCount int32  `json:"partitionCount"` // Total partition count after the change
+	DryRun         bool   `json:"dryRun"`
+}
+
+// AddPartitionsResponse confirms the new (or in dry-run mode validated) partition count
+type AddPartitionsResponse struct {
+	TopicName         string `json:"topicName"`
+	OldPartitionCount int32  `json:"oldPartitionCount"`
+	PartitionCount    int32  `json:"partitionCount"`
+	DryRun            bool   `json:"dryRun"`
+}
+
+// AlterTopicConfigRequest sets or resets topic configs. A nil value resets the config to its default.
+type AlterTopicConfigRequest struct {
+	TopicName string
+	Configs   map[string]*string
+	DryRun    bool
+}
+
+// ConfigChange describes how a single topic or broker config changes. OldValue and NewValue are nil if the config is
+// not explicitly set on the resource (before or after the change) and hence the default applies.
+type ConfigChange struct {
+	Name     string  `json:"name"`
+	OldValue *string `json:"oldValue"`
+	NewValue *string `json:"newValue"`
+}
+
+// AlterTopicConfigResponse lists the config keys which have changed or would change in dry-run mode
+type AlterTopicConfigResponse struct {
+	TopicName string         `json:"topicName"`
+	DryRun    bool           `json:"dryRun"`
+	Changes   []ConfigChange `json:"changes"`
+}
+
+// ValidateTopicName checks whether the given name is a legal Kafka topic name
+func ValidateTopicName(topicName string) error {
+	if topicName == "." || topicName == ".." {
+		return fmt.Errorf("topic name must not be '.' or '..'")
+	}
+	if !topicNamePattern.MatchString(topicName) {
+		return fmt.Errorf("topic name must consist of 1 to 249 alphanumeric characters, '.', '_' or '-'")
+	}
+
+	return nil
+}
+
+// CreateTopic creates a new topic. In dry-run mode the request is validated by the controller, but no topic is
+// created.
+func (s *Service) CreateTopic(ctx context.Context, req CreateTopicRequest) (*CreateTopicResponse, error) {
+	configs := make(map[string]*string, len(req.Configs))
+	for name, value := range req.Configs {
+		v := value
+		configs[name] = &v
+	}
+
+	err := s.kafkaSvc.CreateTopic(req.TopicName, req.PartitionCount, req.ReplicationFactor, configs, req.DryRun)
+	if err != nil {
+		return nil, topicAdminError(err)
+	}
+
+	return &CreateTopicResponse{
+		TopicName:         req.TopicName,
+		PartitionCount:    req.PartitionCount,
+		ReplicationFactor: req.ReplicationFactor,
+		Configs:           req.Configs,
+		DryRun:            req.DryRun,
+	}, nil
+}
+
+// DeleteTopic deletes the given topic
+func (s *Service) DeleteTopic(ctx context.Context, topicName string) error {
+	err := s.kafkaSvc.DeleteTopic(topicName)
+	if err != nil {
+		return topicAdminError(err)
+	}
+
+	return nil
+}
+
+// AddPartitions increases the topic's partition count. In dry-run mode the request is validated by the controller,
+// but no partitions are created.
+func (s *Service) AddPartitions(ctx context.Context, req AddPartitionsRequest) (*AddPartitionsResponse, error) {
+	partitionIDs, err := s.kafkaSvc.ListPartitions(req.TopicName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions: %w", topicAdminError(err))
+	}
+	oldCount := int32(len(partitionIDs))
+	if req.PartitionCount <= oldCount {
+		return nil, fmt.Errorf("%w: the partition count can only be increased, the topic has %v partitions", ErrInvalidTopicRequest, oldCount)
+	}
+
+	err = s.kafkaSvc.CreatePartitions(req.TopicName, req.PartitionCount, req.DryRun)
+	if err != nil {
+		return nil, topicAdminError(err)
+	}
+
+	return &AddPartitionsResponse{
+		TopicName:         req.TopicName,
+		OldPartitionCount: oldCount,
+		PartitionCount:    req.PartitionCount,
+		DryRun:            req.DryRun,
+	}, nil
+}
+
+// AlterTopicConfig applies the requested config changes on top of the topic's current configs and returns the
+// config keys which change. Kafka replaces all dynamic configs of a topic at once, therefore the currently set
+// configs must be sent along with the changes.
+func (s *Service) AlterTopicConfig(ctx context.Context, req AlterTopicConfigRequest) (*AlterTopicConfigResponse, error) {
+	current, err := s.GetTopicConfigs(req.TopicName, nil)
+	if err != nil {
+		return nil, topicAdminError(err)
+	}
+	if current == nil {
+		return nil, fmt.Errorf("%w: '%v'", ErrTopicNotFound, req.TopicName)
+	}
+
+	currentConfigs := make(map[string]*string)
+	for _, entry := range current.ConfigEntries {
+		if entry.IsDefault {
+			continue
+		}
+		v := entry.Value
+		currentConfigs[entry.Name] = &v
+	}
+
+	desiredConfigs, changes := mergeConfigs(currentConfigs, req.Configs)
+	err = s.kafkaSvc.AlterTopicConfig(req.TopicName, desiredConfigs, req.DryRun)
+	if err != nil {
+		return nil, topicAdminError(err)
+	}
+
+	return &AlterTopicConfigResponse{
+		TopicName: req.TopicName,
+		DryRun:    req.DryRun,
+		Changes:   changes,
+	}, nil
+}
+
+// mergeConfigs applies the requested changes (nil values reset a config) to the currently set configs. It
+// returns the resulting configs along with the configs which actually change, sorted by name.
+func mergeConfigs(current map[string]*string, requested map[string]*string) (map[string]*string, []ConfigChange) {
+	desired := make(map[string]*string, len(current)+len(requested))
+	for name, value := range current {
+		desired[name] = value
+	}
+
+	changes := make([]ConfigChange, 0)
+	for name, newValue := range requested {
+		oldValue := current[name]
+		if newValue == nil {
+			delete(desired, name)
+		} else {
+			desired[name] = newValue
+		}
+
+		isUnchanged := (oldValue == nil && newValue == nil) || (oldValue != nil && newValue != nil && *oldValue == *newValue)
+		if isUnchanged {
+			continue
+		}
+		changes = append(changes, ConfigChange{Name: name, OldValue: oldValue, NewValue: newValue})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+
+	return desired, changes
+}
+
+// topicAdminError wraps kafka errors into our sentinel errors, so that the API can respond with a proper status
+func topicAdminError(err error) error {
+	var kErr sarama.KError
+	if !errors.As(err, &kErr) {
+		return err
+	}
+
+	switch kErr {
+	case sarama.ErrUnknownTopicOrPartition:
+		return fmt.Errorf("%w: %v", ErrTopicNotFound, err)
+	case sarama.ErrTopicAlreadyExists:
+		return fmt.Errorf("%w: %v", ErrTopicAlreadyExists, err)
+	case sarama.ErrInvalidTopic, sarama.ErrInvalidPartitions, sarama.ErrInvalidReplicationFactor,
+		sarama.ErrInvalidReplicaAssignment, sarama.ErrInvalidConfig, sarama.ErrInvalidRequest, sarama.ErrPolicyViolation:
+		return fmt.Errorf("%w: %v", ErrInvalidTopicRequest, err)
+	}
+
+	return err
+}
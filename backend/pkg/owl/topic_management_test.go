@@ -0,0 +1,59 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeTopicConfigs(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	current := map[string]*string{
+		"retention.ms":   str("3600000"),
+		"cleanup.policy": str("compact"),
+	}
+	requested := map[string]*string{
+		"retention.ms":        str("7200000"), // changed
+		"cleanup.policy":      str("compact"), // unchanged
+		"max.message.bytes":   str("2097152"), // newly set
+		"min.insync.replicas": nil,            // reset, but has not been set before
+	}
+
+	desired, changes := mergeConfigs(current, requested)
+
+	assert.Equal(t, map[string]*string{
+		"retention.ms":      str("7200000"),
+		"cleanup.policy":    str("compact"),
+		"max.message.bytes": str("2097152"),
+	}, desired)
+	assert.Equal(t, []ConfigChange{
+		{Name: "max.message.bytes", OldValue: nil, NewValue: str("2097152")},
+		{Name: "retention.ms", OldValue: str("3600000"), NewValue: str("7200000")},
+	}, changes)
+
+	// Resetting a set config removes it from the desired configs
+	desired, changes = mergeConfigs(current, map[string]*string{"cleanup.policy": nil})
+	assert.Equal(t, map[string]*string{"retention.ms": str("3600000")}, desired)
+	assert.Equal(t, []ConfigChange{{Name: "cleanup.policy", OldValue: str("compact"), NewValue: nil}}, changes)
+}
+
+func TestValidateTopicName(t *testing.T) {
+	tt := []struct {
+		topicName string
+		isValid   bool
+	}{
+		{"orders", true},
+		{"orders.v1_eu-west", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"orders/v1", false},
+		{string(make([]byte, 250)), false},
+	}
+
+	for _, table := range tt {
+		err := ValidateTopicName(table.topicName)
+		assert.Equal(t, table.isValid, err == nil, "unexpected result for topic name '%v'", table.topicName)
+	}
+}
@@ -1,6 +1,12 @@
 package owl
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
 
 // TopicPartition consists of some (not all) information about a partition of a topic.
 // Only data relevant to the 'partition table' in the frontend is included.
@@ -8,10 +14,16 @@ type TopicPartition struct {
 	ID            int32 `json:"id"`
 	WaterMarkLow  int64 `json:"waterMarkLow"`
 	WaterMarkHigh int64 `json:"waterMarkHigh"`
+
+	// FirstTimestamp and LastTimestamp (unix milliseconds) are the timestamps of the earliest retained and the latest
+	// record. They are only set if requested and if the records could be fetched in time.
+	FirstTimestamp *int64 `json:"firstTimestamp,omitempty"`
+	LastTimestamp  *int64 `json:"lastTimestamp,omitempty"`
 }
 
-// ListTopicPartitions returns the partition in the topic along with their watermarks
-func (s *Service) ListTopicPartitions(topicName string) ([]TopicPartition, error) {
+// ListTopicPartitions returns the partition in the topic along with their watermarks. If includeTimestamps is set,
+// the records at both watermarks are fetched to determine the time range that each partition covers.
+func (s *Service) ListTopicPartitions(ctx context.Context, topicName string, includeTimestamps bool) ([]TopicPartition, error) {
 	partitions, err := s.kafkaSvc.ListPartitions(topicName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get partitions for topic '%v': %v", topicName, err)
@@ -30,5 +42,63 @@ func (s *Service) ListTopicPartitions(topicName string) ([]TopicPartition, error
 		topicPartitions[i] = TopicPartition{ID: p.PartitionID, WaterMarkLow: w.Low, WaterMarkHigh: w.High}
 	}
 
+	if includeTimestamps {
+		s.addPartitionTimestamps(ctx, topicName, topicPartitions)
+	}
+
 	return topicPartitions, nil
 }
+
+// addPartitionTimestamps fetches the first and the last record of each non empty partition. Timestamps which can't
+// be fetched before the context is done are left empty, e.g. if the last offset is a transaction marker or the
+// partition is being compacted.
+func (s *Service) addPartitionTimestamps(ctx context.Context, topicName string, partitions []TopicPartition) {
+	firstOffsets, lastOffsets := partitionBoundaryOffsets(partitions)
+	if len(firstOffsets) == 0 {
+		return
+	}
+
+	type result struct {
+		timestamps map[int32]time.Time
+		err        error
+	}
+	firstCh := make(chan result, 1)
+	go func() {
+		timestamps, err := s.kafkaSvc.MessageTimestamps(ctx, topicName, firstOffsets)
+		firstCh <- result{timestamps, err}
+	}()
+	last, lastErr := s.kafkaSvc.MessageTimestamps(ctx, topicName, lastOffsets)
+	first := <-firstCh
+
+	if first.err != nil || lastErr != nil {
+		s.logger.Warn("failed to get partition timestamps", zap.String("topic", topicName),
+			zap.NamedError("first_error", first.err), zap.NamedError("last_error", lastErr))
+	}
+	for i, p := range partitions {
+		if ts, ok := first.timestamps[p.ID]; ok {
+			partitions[i].FirstTimestamp = unixMilli(ts)
+		}
+		if ts, ok := last[p.ID]; ok {
+			partitions[i].LastTimestamp = unixMilli(ts)
+		}
+	}
+}
+
+// partitionBoundaryOffsets returns the offsets of the first and the last record of all partitions which have records
+func partitionBoundaryOffsets(partitions []TopicPartition) (first map[int32]int64, last map[int32]int64) {
+	first = make(map[int32]int64)
+	last = make(map[int32]int64)
+	for _, p := range partitions {
+		if p.WaterMarkHigh <= p.WaterMarkLow {
+			continue
+		}
+		first[p.ID] = p.WaterMarkLow
+		last[p.ID] = p.WaterMarkHigh - 1
+	}
+	return first, last
+}
+
+func unixMilli(t time.Time) *int64 {
+	ms := t.UnixNano() / int64(time.Millisecond)
+	return &ms
+}
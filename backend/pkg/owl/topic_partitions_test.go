@@ -0,0 +1,19 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionBoundaryOffsets(t *testing.T) {
+	partitions := []TopicPartition{
+		{ID: 0, WaterMarkLow: 0, WaterMarkHigh: 10},
+		{ID: 1, WaterMarkLow: 5, WaterMarkHigh: 5}, // Empty, e.g. all records have been deleted by retention
+		{ID: 2, WaterMarkLow: 7, WaterMarkHigh: 8},
+	}
+
+	first, last := partitionBoundaryOffsets(partitions)
+	assert.Equal(t, map[int32]int64{0: 0, 2: 7}, first)
+	assert.Equal(t, map[int32]int64{0: 9, 2: 7}, last)
+}
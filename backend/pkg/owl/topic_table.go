@@ -0,0 +1,195 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/decryption"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/masking"
+	"github.com/cloudhut/kowl/backend/pkg/rendering"
+)
+
+// ErrTopicNotCompacted is returned if a table view is requested for a topic without compaction
+var ErrTopicNotCompacted = errors.New("topic is not compacted")
+
+// TopicTableRequest describes a table view of a compacted topic
+type TopicTableRequest struct {
+	TopicName string
+
+	// MaxKeys and MaxBytes bound the memory which is used to build the table. Consuming stops and the table is
+	// incomplete once either limit is exceeded. Keys whose latest message is a tombstone count towards the limits.
+	MaxKeys  int
+	MaxBytes int64
+
+	IsolationLevel sarama.IsolationLevel
+	Decrypter      *decryption.TopicDecrypter
+	Masker         *masking.Masker
+	Renderer       *rendering.Renderer
+}
+
+// TopicTable is a snapshot of the latest message of each key, similar to a KTable. Keys whose latest message is
+// a tombstone and messages without key are not part of the table.
+type TopicTable struct {
+	Messages []*kafka.TopicMessage `json:"messages"` // Ordered by key
+
+	// IsComplete is false if the limits have been exceeded before the whole topic has been consumed. The values of
+	// an incomplete table may be outdated and keys may be missing.
+	IsComplete       bool     `json:"isComplete"`
+	MessagesConsumed int64    `json:"messagesConsumed"`
+	Errors           []string `json:"errors"`
+}
+
+// GetTopicTable consumes the whole compacted topic and returns the latest message of each key
+func (s *Service) GetTopicTable(ctx context.Context, req TopicTableRequest) (*TopicTable, error) {
+	isCompacted, err := s.isCompactedTopic(req.TopicName)
+	if err != nil {
+		return nil, err
+	}
+	if !isCompacted {
+		return nil, ErrTopicNotCompacted
+	}
+
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	table := newTableCollector(req.MaxKeys, req.MaxBytes, cancel)
+	listReq := ListMessageRequest{
+		TopicName:      req.TopicName,
+		PartitionID:    partitionsAll,
+		StartOffset:    StartOffsetOldest,
+		MessageCount:   math.MaxInt64,
+		IsolationLevel: req.IsolationLevel,
+		Decrypter:      req.Decrypter,
+		Masker:         req.Masker,
+		Renderer:       req.Renderer,
+	}
+	err = s.ListMessages(childCtx, listReq, table)
+	if err != nil && !table.isTruncated() {
+		return nil, err
+	}
+
+	return table.snapshot(), nil
+}
+
+// tableCollector implements kafka.IListMessagesProgress and keeps the latest message of each key. It cancels the
+// search once a limit has been exceeded.
+type tableCollector struct {
+	maxKeys  int
+	maxBytes int64
+	cancel   context.CancelFunc
+
+	mutex     sync.Mutex
+	entries   map[string]*kafka.TopicMessage
+	bytes     int64
+	consumed  int64
+	truncated bool
+	errors    []string
+}
+
+func newTableCollector(maxKeys int, maxBytes int64, cancel context.CancelFunc) *tableCollector {
+	return &tableCollector{
+		maxKeys:  maxKeys,
+		maxBytes: maxBytes,
+		cancel:   cancel,
+		entries:  make(map[string]*kafka.TopicMessage),
+		errors:   make([]string, 0),
+	}
+}
+
+func (c *tableCollector) OnPhase(_ string)                                             {}
+func (c *tableCollector) OnConsumeRequests(_ map[int32]*kafka.PartitionConsumeRequest) {}
+func (c *tableCollector) OnMessageMatched(_ int32)                                     {}
+func (c *tableCollector) OnMessagesDropped(_ int64)                                    {}
+func (c *tableCollector) OnWaterMarks(_ map[int32]*kafka.WaterMark)                    {}
+func (c *tableCollector) OnInconsistency(_ kafka.PartitionInconsistency)               {}
+func (c *tableCollector) OnComplete(_ int64, _ bool)                                   {}
+
+func (c *tableCollector) OnMessageConsumed(_ int32, _ int64, _ int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.consumed++
+}
+
+func (c *tableCollector) OnError(msg string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.errors = append(c.errors, msg)
+}
+
+// OnMessage replaces the key's previous message. Messages of the same partition arrive in offset order. If a key
+// has been written to multiple partitions (e.g. because the partition count has changed), the newer message wins.
+// Tombstones are kept until the snapshot is taken, so that they can't be overruled by older messages of other
+// partitions which arrive later.
+func (c *tableCollector) OnMessage(msg *kafka.TopicMessage) {
+	if len(msg.Key.Value) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.truncated {
+		return
+	}
+	key := msg.KeyType + ":" + string(msg.Key.Value)
+	if previous, exists := c.entries[key]; exists {
+		if previous.PartitionID != msg.PartitionID && !isNewerMessage(msg, previous) {
+			return
+		}
+		c.bytes -= tableEntrySize(previous)
+	}
+
+	c.entries[key] = msg
+	c.bytes += tableEntrySize(msg)
+	if len(c.entries) > c.maxKeys || c.bytes > c.maxBytes {
+		c.truncated = true
+		c.cancel()
+	}
+}
+
+// isNewerMessage compares messages of different partitions by timestamp, ties are broken by the partition id
+func isNewerMessage(msg *kafka.TopicMessage, other *kafka.TopicMessage) bool {
+	if msg.Timestamp != other.Timestamp {
+		return msg.Timestamp > other.Timestamp
+	}
+	return msg.PartitionID > other.PartitionID
+}
+
+func (c *tableCollector) isTruncated() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.truncated
+}
+
+// snapshot returns the table ordered by key
+func (c *tableCollector) snapshot() *TopicTable {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys := make([]string, 0, len(c.entries))
+	for key, msg := range c.entries {
+		if !msg.IsValueNull {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	messages := make([]*kafka.TopicMessage, len(keys))
+	for i, key := range keys {
+		messages[i] = c.entries[key]
+	}
+
+	return &TopicTable{
+		Messages:         messages,
+		IsComplete:       !c.truncated,
+		MessagesConsumed: c.consumed,
+		Errors:           c.errors,
+	}
+}
+
+func tableEntrySize(msg *kafka.TopicMessage) int64 {
+	return int64(len(msg.Key.Value) + len(msg.Value.Value))
+}
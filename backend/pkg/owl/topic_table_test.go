@@ -0,0 +1,60 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTableTestMessage(key string, value string, partitionID int32, offset int64, timestamp int64) *kafka.TopicMessage {
+	return &kafka.TopicMessage{
+		PartitionID: partitionID,
+		Offset:      offset,
+		Timestamp:   timestamp,
+		Key:         kafka.DirectEmbedding{Value: []byte(key)},
+		KeyType:     "text",
+		Value:       kafka.DirectEmbedding{Value: []byte(value)},
+		IsValueNull: value == "",
+	}
+}
+
+func TestTableCollector(t *testing.T) {
+	cancelled := false
+	c := newTableCollector(10, 1000, func() { cancelled = true })
+	c.OnMessage(newTableTestMessage("b", "b1", 0, 0, 10))
+	c.OnMessage(newTableTestMessage("a", "a1", 0, 1, 10))
+	c.OnMessage(newTableTestMessage("b", "b2", 0, 2, 5)) // Same partition, offset order wins
+	c.OnMessage(newTableTestMessage("c", "c1", 0, 3, 20))
+	c.OnMessage(newTableTestMessage("c", "", 0, 4, 21))   // Tombstone
+	c.OnMessage(newTableTestMessage("c", "c0", 1, 0, 15)) // Older message of another partition
+	c.OnMessage(newTableTestMessage("a", "a2", 1, 1, 10)) // Same timestamp, higher partition wins
+	c.OnMessage(newTableTestMessage("", "no key", 1, 2, 30))
+
+	table := c.snapshot()
+	assert.True(t, table.IsComplete)
+	assert.False(t, cancelled)
+	require.Len(t, table.Messages, 2)
+	assert.Equal(t, "a2", string(table.Messages[0].Value.Value))
+	assert.Equal(t, "b2", string(table.Messages[1].Value.Value))
+}
+
+func TestTableCollector_Limits(t *testing.T) {
+	cancelled := false
+	c := newTableCollector(2, 1000, func() { cancelled = true })
+	c.OnMessage(newTableTestMessage("a", "1", 0, 0, 1))
+	c.OnMessage(newTableTestMessage("b", "1", 0, 1, 1))
+	assert.False(t, cancelled)
+	c.OnMessage(newTableTestMessage("c", "1", 0, 2, 1))
+	assert.True(t, cancelled)
+	c.OnMessage(newTableTestMessage("d", "1", 0, 3, 1))
+	assert.False(t, c.snapshot().IsComplete)
+
+	cancelled = false
+	c = newTableCollector(10, 5, func() { cancelled = true })
+	c.OnMessage(newTableTestMessage("a", "1234", 0, 0, 1))
+	assert.False(t, cancelled)
+	c.OnMessage(newTableTestMessage("a", "12345", 0, 1, 1))
+	assert.True(t, cancelled)
+}
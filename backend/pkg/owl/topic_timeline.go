@@ -0,0 +1,125 @@
+package owl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// timelineConcurrency is the number of bucket boundaries whose offsets are looked up at the same time
+const timelineConcurrency = 8
+
+// TopicTimeline is the number of messages which have been produced to a topic per time bucket. The counts are
+// derived from the offsets at the bucket boundaries without consuming any messages, so they are estimates: offsets
+// of transaction markers and compacted records are counted as well, and messages with timestamps out of order are
+// attributed to the bucket of their neighbours.
+type TopicTimeline struct {
+	TopicName    string           `json:"topicName"`
+	Start        int64            `json:"start"` // Unix milliseconds
+	End          int64            `json:"end"`   // Unix milliseconds
+	BucketSizeMs int64            `json:"bucketSizeMs"`
+	Buckets      []TimelineBucket `json:"buckets"`
+}
+
+// TimelineBucket is the number of messages whose timestamp is within [Start, End)
+type TimelineBucket struct {
+	Start        int64 `json:"start"`
+	End          int64 `json:"end"`
+	MessageCount int64 `json:"messageCount"`
+}
+
+// GetTopicTimeline splits the window [start, end) (unix milliseconds) into the given number of buckets and returns
+// the number of messages per bucket
+func (s *Service) GetTopicTimeline(ctx context.Context, topicName string, start int64, end int64, bucketCount int) (*TopicTimeline, error) {
+	if bucketCount <= 0 || end <= start {
+		return nil, fmt.Errorf("the window must not be empty and have at least one bucket")
+	}
+
+	partitionIDs, err := s.kafkaSvc.ListPartitions(topicName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions: %w", err)
+	}
+	marks, err := s.kafkaSvc.WaterMarks(topicName, partitionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watermarks: %w", err)
+	}
+	highWaterMarks := make(map[int32]int64, len(marks))
+	for partitionID, mark := range marks {
+		highWaterMarks[partitionID] = mark.High
+	}
+
+	boundaries := timelineBoundaries(start, end, bucketCount)
+	offsets := make([]map[int32]int64, len(boundaries))
+	mutex := sync.Mutex{}
+	sem := make(chan struct{}, timelineConcurrency)
+	grp, grpCtx := errgroup.WithContext(ctx)
+	for i, boundary := range boundaries {
+		i, boundary := i, boundary
+		grp.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-grpCtx.Done():
+				return grpCtx.Err()
+			}
+
+			res, err := s.kafkaSvc.OffsetsForTimes(topicName, partitionIDs, boundary)
+			if err != nil {
+				return fmt.Errorf("failed to get offsets for timestamp '%v': %w", boundary, err)
+			}
+			mutex.Lock()
+			offsets[i] = res
+			mutex.Unlock()
+			return nil
+		})
+	}
+	if err := grp.Wait(); err != nil {
+		return nil, err
+	}
+
+	return &TopicTimeline{
+		TopicName:    topicName,
+		Start:        start,
+		End:          boundaries[len(boundaries)-1],
+		BucketSizeMs: boundaries[1] - boundaries[0],
+		Buckets:      calculateTimelineBuckets(boundaries, offsets, highWaterMarks),
+	}, nil
+}
+
+// timelineBoundaries returns bucketCount+1 boundaries starting at start. The bucket size is rounded up to whole
+// milliseconds, hence the last boundary may be after end.
+func timelineBoundaries(start int64, end int64, bucketCount int) []int64 {
+	bucketSize := (end - start + int64(bucketCount) - 1) / int64(bucketCount)
+	boundaries := make([]int64, bucketCount+1)
+	for i := range boundaries {
+		boundaries[i] = start + int64(i)*bucketSize
+	}
+	return boundaries
+}
+
+// calculateTimelineBuckets counts the offsets between each two consecutive boundaries. An offset of -1 means that
+// there is no message at or after the boundary, the high water mark is used instead.
+func calculateTimelineBuckets(boundaries []int64, offsets []map[int32]int64, highWaterMarks map[int32]int64) []TimelineBucket {
+	offsetAt := func(i int, partitionID int32) int64 {
+		offset, ok := offsets[i][partitionID]
+		if !ok || offset < 0 {
+			return highWaterMarks[partitionID]
+		}
+		return offset
+	}
+
+	buckets := make([]TimelineBucket, len(boundaries)-1)
+	for i := range buckets {
+		buckets[i] = TimelineBucket{Start: boundaries[i], End: boundaries[i+1]}
+		for partitionID := range highWaterMarks {
+			// Timestamps are not necessarily increasing, offsets at later boundaries may hence be smaller
+			if count := offsetAt(i+1, partitionID) - offsetAt(i, partitionID); count > 0 {
+				buckets[i].MessageCount += count
+			}
+		}
+	}
+
+	return buckets
+}
@@ -0,0 +1,31 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimelineBoundaries(t *testing.T) {
+	assert.Equal(t, []int64{1000, 1250, 1500, 1750, 2000}, timelineBoundaries(1000, 2000, 4))
+	// Bucket size is rounded up, so that the window is covered entirely
+	assert.Equal(t, []int64{0, 4, 8, 12}, timelineBoundaries(0, 10, 3))
+}
+
+func TestCalculateTimelineBuckets(t *testing.T) {
+	boundaries := []int64{0, 10, 20, 30}
+	offsets := []map[int32]int64{
+		{0: 5, 1: 0},
+		{0: 12, 1: 3},
+		{0: 11, 1: -1}, // Partition 0 has an out of order timestamp, partition 1 no messages after 20
+		{0: -1, 1: -1},
+	}
+	highWaterMarks := map[int32]int64{0: 20, 1: 8}
+
+	buckets := calculateTimelineBuckets(boundaries, offsets, highWaterMarks)
+	assert.Equal(t, []TimelineBucket{
+		{Start: 0, End: 10, MessageCount: 7 + 3},
+		{Start: 10, End: 20, MessageCount: 0 + 5},
+		{Start: 20, End: 30, MessageCount: 9 + 0},
+	}, buckets)
+}
@@ -0,0 +1,280 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// UpgradeFindingSeverity describes how severe an upgrade readiness finding is
+type UpgradeFindingSeverity string
+
+const (
+	UpgradeFindingBlocker UpgradeFindingSeverity = "blocker" // Must be resolved before the upgrade
+	UpgradeFindingWarning UpgradeFindingSeverity = "warning"
+	UpgradeFindingInfo    UpgradeFindingSeverity = "info"
+)
+
+// ErrInvalidKafkaVersion is returned if a Kafka version can not be parsed
+var ErrInvalidKafkaVersion = errors.New("invalid kafka version")
+
+// UpgradeReadinessReport lists everything that should be checked before upgrading the cluster to TargetVersion
+type UpgradeReadinessReport struct {
+	TargetVersion string           `json:"targetVersion"`
+	IsReady       bool             `json:"isReady"` // True if there are no blockers
+	Findings      []UpgradeFinding `json:"findings"`
+}
+
+// UpgradeFinding is a single issue which has been found while analyzing the cluster
+type UpgradeFinding struct {
+	Severity UpgradeFindingSeverity `json:"severity"`
+	Category string                 `json:"category"` // brokerConfig, topicConfig or clients
+	Resource string                 `json:"resource"` // Broker id or topic name, empty for cluster wide findings
+	Message  string                 `json:"message"`
+}
+
+var upgradeRelevantBrokerConfigs = []string{
+	"inter.broker.protocol.version",
+	"log.message.format.version",
+	"zookeeper.connect",
+}
+
+// GetUpgradeReadinessReport analyzes inter broker protocol settings as well as broker and topic message format
+// versions and reports what would block or complicate an upgrade to the given Kafka version.
+func (s *Service) GetUpgradeReadinessReport(ctx context.Context, targetVersion string) (*UpgradeReadinessReport, error) {
+	target, err := parseKafkaVersion(targetVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Fetch relevant broker configs of all brokers
+	metadata, err := s.kafkaSvc.DescribeCluster()
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster: %w", err)
+	}
+	type brokerConfigs struct {
+		BrokerID int32
+		Values   map[string]string
+		Defaults map[string]bool
+	}
+	configsByBroker := make([]brokerConfigs, len(metadata.Brokers))
+	eg, _ := errgroup.WithContext(ctx)
+	for i, b := range metadata.Brokers {
+		i, brokerID := i, b.ID
+		eg.Go(func() error {
+			entries, err := s.kafkaSvc.DescribeBrokerConfigs(brokerID, upgradeRelevantBrokerConfigs)
+			if err != nil {
+				return err
+			}
+			cfg := brokerConfigs{BrokerID: brokerID, Values: make(map[string]string), Defaults: make(map[string]bool)}
+			for _, e := range entries {
+				cfg.Values[e.Name] = e.Value
+				cfg.Defaults[e.Name] = e.Default
+			}
+			configsByBroker[i] = cfg
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to describe broker configs: %w", err)
+	}
+	sort.Slice(configsByBroker, func(i, j int) bool { return configsByBroker[i].BrokerID < configsByBroker[j].BrokerID })
+
+	// 2. Analyze broker configs
+	findings := make([]UpgradeFinding, 0)
+	interBrokerVersions := make(map[string][]int32)
+	explicitIBPCount := 0
+	for _, cfg := range configsByBroker {
+		resource := strconv.Itoa(int(cfg.BrokerID))
+
+		ibp := cfg.Values["inter.broker.protocol.version"]
+		if ibp != "" {
+			interBrokerVersions[ibp] = append(interBrokerVersions[ibp], cfg.BrokerID)
+			if !cfg.Defaults["inter.broker.protocol.version"] {
+				explicitIBPCount++
+			}
+			ibpVersion, err := parseKafkaVersion(ibp)
+			if err == nil && compareKafkaVersions(ibpVersion, target) > 0 {
+				findings = append(findings, UpgradeFinding{
+					Severity: UpgradeFindingBlocker,
+					Category: "brokerConfig",
+					Resource: resource,
+					Message:  fmt.Sprintf("inter.broker.protocol.version %v is newer than the target version %v, downgrades are not supported", ibp, targetVersion),
+				})
+			}
+			if err == nil && target.IsAtLeast(4, 0) && !ibpVersion.IsAtLeast(3, 3) {
+				findings = append(findings, UpgradeFinding{
+					Severity: UpgradeFindingBlocker,
+					Category: "brokerConfig",
+					Resource: resource,
+					Message:  fmt.Sprintf("upgrades to %v are only supported from Kafka 3.3 or newer, but the inter.broker.protocol.version is %v", targetVersion, ibp),
+				})
+			}
+		}
+
+		if target.IsAtLeast(4, 0) && cfg.Values["zookeeper.connect"] != "" {
+			findings = append(findings, UpgradeFinding{
+				Severity: UpgradeFindingBlocker,
+				Category: "brokerConfig",
+				Resource: resource,
+				Message:  "broker runs in ZooKeeper mode which has been removed in Kafka 4.0, the cluster must be migrated to KRaft first",
+			})
+		}
+
+		if !cfg.Defaults["log.message.format.version"] {
+			if f := messageFormatFinding(cfg.Values["log.message.format.version"], target, "log.message.format.version"); f != nil {
+				f.Category, f.Resource = "brokerConfig", resource
+				findings = append(findings, *f)
+			}
+		}
+	}
+
+	if len(interBrokerVersions) > 1 {
+		versions := make([]string, 0, len(interBrokerVersions))
+		for v, brokerIDs := range interBrokerVersions {
+			versions = append(versions, fmt.Sprintf("%v (brokers %v)", v, brokerIDs))
+		}
+		sort.Strings(versions)
+		findings = append(findings, UpgradeFinding{
+			Severity: UpgradeFindingWarning,
+			Category: "brokerConfig",
+			Message:  fmt.Sprintf("brokers use different inter.broker.protocol.versions: %v. Finish the previous upgrade first.", strings.Join(versions, ", ")),
+		})
+	}
+	if len(configsByBroker) > 0 && explicitIBPCount < len(configsByBroker) && !target.IsAtLeast(4, 0) {
+		findings = append(findings, UpgradeFinding{
+			Severity: UpgradeFindingWarning,
+			Category: "brokerConfig",
+			Message:  "inter.broker.protocol.version is not set explicitly on all brokers. Pin it to the currently running version before rolling out new binaries and bump it once all brokers run the target version.",
+		})
+	}
+
+	// 3. Analyze topic message format versions
+	topics, err := s.kafkaSvc.ListTopics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+	topicNames := make([]string, len(topics))
+	for i, t := range topics {
+		topicNames[i] = t.Name
+	}
+	if len(topicNames) > 0 {
+		topicConfigs, err := s.GetTopicsConfigs(topicNames, []string{"message.format.version"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe topic configs: %w", err)
+		}
+		for _, topicName := range topicNames {
+			cfg, exists := topicConfigs[topicName]
+			if !exists {
+				continue
+			}
+			entry := cfg.GetConfigEntryByName("message.format.version")
+			if entry == nil || entry.IsDefault {
+				continue
+			}
+			if f := messageFormatFinding(entry.Value, target, "message.format.version"); f != nil {
+				f.Category, f.Resource = "topicConfig", topicName
+				findings = append(findings, *f)
+			}
+		}
+	}
+
+	// 4. Client versions can't be observed via the Kafka protocol
+	findings = append(findings, UpgradeFinding{
+		Severity: UpgradeFindingInfo,
+		Category: "clients",
+		Message: "Client API versions can not be observed via the Kafka protocol. Check the brokers' request metrics " +
+			"(kafka.network:type=RequestMetrics,name=RequestsPerSec,request=*,version=*) for clients using old API versions.",
+	})
+
+	report := &UpgradeReadinessReport{
+		TargetVersion: targetVersion,
+		IsReady:       true,
+		Findings:      findings,
+	}
+	for _, f := range findings {
+		if f.Severity == UpgradeFindingBlocker {
+			report.IsReady = false
+			break
+		}
+	}
+
+	return report, nil
+}
+
+// messageFormatFinding reports message format versions older than 0.11 (message format v0 and v1), which have been
+// deprecated with Kafka 3.0 and removed with Kafka 4.0. Category and resource must be set by the caller.
+func messageFormatFinding(formatVersion string, target kafkaVersion, configName string) *UpgradeFinding {
+	version, err := parseKafkaVersion(formatVersion)
+	if err != nil || version.IsAtLeast(0, 11) {
+		return nil
+	}
+
+	switch {
+	case target.IsAtLeast(4, 0):
+		return &UpgradeFinding{
+			Severity: UpgradeFindingBlocker,
+			Message:  fmt.Sprintf("%v %v uses message format v0/v1 which is not supported anymore in Kafka 4.0", configName, formatVersion),
+		}
+	case target.IsAtLeast(3, 0):
+		return &UpgradeFinding{
+			Severity: UpgradeFindingWarning,
+			Message:  fmt.Sprintf("%v %v uses message format v0/v1 which is deprecated since Kafka 3.0", configName, formatVersion),
+		}
+	}
+	return nil
+}
+
+// kafkaVersion is a parsed Kafka version such as [2, 4] for "2.4-IV1" or [0, 10, 2] for "0.10.2"
+type kafkaVersion []int
+
+// parseKafkaVersion parses Kafka versions as they are used for inter.broker.protocol.version and message format
+// configs, including internal versions such as "2.4-IV1".
+func parseKafkaVersion(version string) (kafkaVersion, error) {
+	version = strings.SplitN(strings.TrimSpace(version), "-", 2)[0]
+	if version == "" {
+		return nil, fmt.Errorf("%w: version must not be empty", ErrInvalidKafkaVersion)
+	}
+
+	parts := strings.Split(version, ".")
+	parsed := make(kafkaVersion, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: failed to parse '%v'", ErrInvalidKafkaVersion, version)
+		}
+		parsed[i] = n
+	}
+
+	return parsed, nil
+}
+
+// IsAtLeast returns true if the version is greater than or equal to major.minor
+func (v kafkaVersion) IsAtLeast(major, minor int) bool {
+	return compareKafkaVersions(v, kafkaVersion{major, minor}) >= 0
+}
+
+// compareKafkaVersions returns -1 if a < b, 0 if a == b and 1 if a > b. Missing parts are treated as 0.
+func compareKafkaVersions(a, b kafkaVersion) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package owl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareKafkaVersions(t *testing.T) {
+	tt := []struct {
+		a, b     string
+		expected int
+	}{
+		{"2.4-IV1", "2.4.0", 0},
+		{"0.10.2-IV0", "0.11", -1},
+		{"3.0.0", "2.8", 1},
+		{"3.3", "3.3.1", -1},
+	}
+
+	for i, table := range tt {
+		a, err := parseKafkaVersion(table.a)
+		require.NoError(t, err)
+		b, err := parseKafkaVersion(table.b)
+		require.NoError(t, err)
+		assert.Equal(t, table.expected, compareKafkaVersions(a, b), "expected other comparison result. Case: ", i)
+	}
+
+	_, err := parseKafkaVersion("latest")
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package principals
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Config for resolving Kafka principals (e.g. 'User:CN=orders,OU=payments' or SASL users) to human-friendly names
+// and the teams which own them
+type Config struct {
+	Enabled  bool      `yaml:"enabled"`
+	Mappings []Mapping `yaml:"mappings"`
+
+	// MappingsFile is a YAML file with a 'mappings' list in the same format as Mappings. It's reloaded periodically,
+	// so that it can be synced from a service catalog or identity provider. Configured mappings take precedence.
+	MappingsFile    string        `yaml:"mappingsFile"`
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+}
+
+// Mapping assigns a name and team to all principals matching the principal or principal pattern. Name and team may
+// reference capture groups of the principal pattern, e.g. '$1'.
+type Mapping struct {
+	// Principal must match exactly, including the principal type (e.g. 'User:alice')
+	Principal string `yaml:"principal"`
+
+	// PrincipalPattern is a regex which must match the whole principal
+	PrincipalPattern string `yaml:"principalPattern"`
+
+	// ClientIDPattern is a regex which must match the whole client id of consumer group members. Kafka doesn't
+	// report the principal of group members, hence they can only be resolved by their client id.
+	ClientIDPattern string `yaml:"clientIdPattern"`
+
+	Name string `yaml:"name"`
+	Team string `yaml:"team"`
+}
+
+// SetDefaults for the principals config
+func (c *Config) SetDefaults() {
+	c.RefreshInterval = time.Minute
+}
+
+// Validate the principals config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Mappings) == 0 && c.MappingsFile == "" {
+		return fmt.Errorf("either mappings or a mappings file must be set")
+	}
+	if c.MappingsFile != "" && c.RefreshInterval < time.Second {
+		return fmt.Errorf("refresh interval must be at least 1s")
+	}
+	for i, m := range c.Mappings {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("mapping at index '%v': %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Mapping) validate() error {
+	if m.Principal == "" && m.PrincipalPattern == "" && m.ClientIDPattern == "" {
+		return fmt.Errorf("either principal, principal pattern or client id pattern must be set")
+	}
+	if m.Principal != "" && m.PrincipalPattern != "" {
+		return fmt.Errorf("principal and principal pattern are mutually exclusive")
+	}
+	if m.Name == "" {
+		return fmt.Errorf("name must be set")
+	}
+	if _, err := compilePattern(m.PrincipalPattern); err != nil {
+		return fmt.Errorf("invalid principal pattern: %w", err)
+	}
+	if _, err := compilePattern(m.ClientIDPattern); err != nil {
+		return fmt.Errorf("invalid client id pattern: %w", err)
+	}
+
+	return nil
+}
+
+// compilePattern compiles a regex which must match the whole input. Nil is returned for empty patterns.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
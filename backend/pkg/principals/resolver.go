@@ -0,0 +1,177 @@
+package principals
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// Identity is the human-friendly name of a principal along with the team which owns it
+type Identity struct {
+	Name string `json:"name"`
+	Team string `json:"team,omitempty"`
+}
+
+// Resolver resolves principals and client ids to identities. The first matching mapping applies.
+type Resolver struct {
+	cfg    Config
+	logger *zap.Logger
+
+	configured []*mapping
+
+	mutex    sync.RWMutex
+	fromFile []*mapping
+}
+
+type mapping struct {
+	Mapping
+	principalRegex *regexp.Regexp
+	clientIDRegex  *regexp.Regexp
+}
+
+// mappingsFile is the format of the synced mappings file
+type mappingsFile struct {
+	Mappings []Mapping `yaml:"mappings"`
+}
+
+// NewResolver compiles the configured mappings and loads the mappings file. The config is expected to be validated.
+func NewResolver(cfg Config, logger *zap.Logger) (*Resolver, error) {
+	configured, err := compileMappings(cfg.Mappings)
+	if err != nil {
+		return nil, err
+	}
+	r := &Resolver{
+		cfg:        cfg,
+		logger:     logger.With(zap.String("source", "principals")),
+		configured: configured,
+	}
+	if cfg.MappingsFile != "" {
+		if err := r.loadFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Start reloading the mappings file periodically. If the file can't be loaded the previous mappings are kept.
+func (r *Resolver) Start() {
+	if r == nil || r.cfg.MappingsFile == "" {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := r.loadFile(); err != nil {
+				r.logger.Warn("failed to reload principal mappings, keeping the previous mappings", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// ResolvePrincipal returns the identity of the given Kafka principal. Nil is returned if the resolver is nil, which
+// is the case if principal resolution is disabled, or if no mapping matches.
+func (r *Resolver) ResolvePrincipal(principal string) *Identity {
+	if r == nil || principal == "" {
+		return nil
+	}
+	return r.resolve(principal, func(m *mapping) (*regexp.Regexp, bool) {
+		if m.Principal != "" {
+			return nil, m.Principal == principal
+		}
+		return m.principalRegex, m.principalRegex != nil
+	})
+}
+
+// ResolveClientID returns the identity of a consumer group member by its client id. Nil is returned if the resolver
+// is nil or if no mapping matches.
+func (r *Resolver) ResolveClientID(clientID string) *Identity {
+	if r == nil || clientID == "" {
+		return nil
+	}
+	return r.resolve(clientID, func(m *mapping) (*regexp.Regexp, bool) {
+		return m.clientIDRegex, m.clientIDRegex != nil
+	})
+}
+
+// resolve returns the identity of the first mapping which matches the input. Candidate returns whether the mapping
+// applies at all, and the regex the input must match if it's not an exact match. Capture group references in name
+// and team are expanded with the regex's submatches.
+func (r *Resolver) resolve(input string, candidate func(m *mapping) (*regexp.Regexp, bool)) *Identity {
+	r.mutex.RLock()
+	fromFile := r.fromFile
+	r.mutex.RUnlock()
+
+	for _, mappings := range [][]*mapping{r.configured, fromFile} {
+		for _, m := range mappings {
+			regex, ok := candidate(m)
+			if !ok {
+				continue
+			}
+			if regex == nil {
+				return &Identity{Name: m.Name, Team: m.Team}
+			}
+			submatches := regex.FindStringSubmatchIndex(input)
+			if submatches == nil {
+				continue
+			}
+			return &Identity{
+				Name: string(regex.ExpandString(nil, m.Name, input, submatches)),
+				Team: string(regex.ExpandString(nil, m.Team, input, submatches)),
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadFile replaces the mappings from the mappings file
+func (r *Resolver) loadFile() error {
+	content, err := ioutil.ReadFile(r.cfg.MappingsFile)
+	if err != nil {
+		return fmt.Errorf("failed to read principal mappings file: %w", err)
+	}
+	var file mappingsFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("failed to parse principal mappings file: %w", err)
+	}
+	for i, m := range file.Mappings {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("principal mappings file has an invalid mapping at index '%v': %w", i, err)
+		}
+	}
+	mappings, err := compileMappings(file.Mappings)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	r.fromFile = mappings
+	r.mutex.Unlock()
+
+	return nil
+}
+
+func compileMappings(mappings []Mapping) ([]*mapping, error) {
+	compiled := make([]*mapping, len(mappings))
+	for i, m := range mappings {
+		principalRegex, err := compilePattern(m.PrincipalPattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile principal pattern of mapping '%v': %w", m.Name, err)
+		}
+		clientIDRegex, err := compilePattern(m.ClientIDPattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile client id pattern of mapping '%v': %w", m.Name, err)
+		}
+		compiled[i] = &mapping{Mapping: m, principalRegex: principalRegex, clientIDRegex: clientIDRegex}
+	}
+
+	return compiled, nil
+}
@@ -0,0 +1,57 @@
+package principals
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "principals")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	mappingsFile := filepath.Join(dir, "mappings.yaml")
+	require.NoError(t, ioutil.WriteFile(mappingsFile, []byte(`
+mappings:
+  - principal: "User:alice"
+    name: Shadowed by the configured mapping
+  - principal: "User:svc-42"
+    name: Billing Service
+    team: Billing
+`), 0644))
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.MappingsFile = mappingsFile
+	cfg.Mappings = []Mapping{
+		{Principal: "User:alice", Name: "Alice Smith", Team: "Platform"},
+		{PrincipalPattern: `User:CN=([^,]+),OU=([^,]+).*`, Name: "$1", Team: "$2"},
+		{ClientIDPattern: `orders-consumer-\d+`, Name: "Orders Service", Team: "Checkout"},
+	}
+	require.NoError(t, cfg.Validate())
+	r, err := NewResolver(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, &Identity{Name: "Alice Smith", Team: "Platform"}, r.ResolvePrincipal("User:alice"))
+	assert.Equal(t, &Identity{Name: "Billing Service", Team: "Billing"}, r.ResolvePrincipal("User:svc-42"))
+	assert.Equal(t, &Identity{Name: "orders", Team: "payments"}, r.ResolvePrincipal("User:CN=orders,OU=payments,O=ACME"))
+	assert.Nil(t, r.ResolvePrincipal("User:bob"))
+	assert.Nil(t, r.ResolvePrincipal("User:alice2"), "exact principals must not match prefixes")
+
+	assert.Equal(t, &Identity{Name: "Orders Service", Team: "Checkout"}, r.ResolveClientID("orders-consumer-1"))
+	assert.Nil(t, r.ResolveClientID("User:alice"), "principal mappings must not apply to client ids")
+
+	// Invalid files keep the previous mappings
+	require.NoError(t, ioutil.WriteFile(mappingsFile, []byte(`mappings: [{principal: "User:svc-42"}]`), 0644))
+	assert.Error(t, r.loadFile())
+	assert.Equal(t, "Billing Service", r.ResolvePrincipal("User:svc-42").Name)
+
+	var nilResolver *Resolver
+	assert.Nil(t, nilResolver.ResolvePrincipal("User:alice"))
+}
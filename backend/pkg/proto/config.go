@@ -0,0 +1,48 @@
+package proto
+
+import "fmt"
+
+// Config for deserializing Protobuf encoded messages. Proto types can either be provided as .proto source files or
+// as compiled descriptor sets (protoc --include_imports --descriptor_set_out=...).
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// ImportPaths are the directories in which the given .proto files and their imports are looked up
+	ImportPaths []string `yaml:"importPaths"`
+	// Files are .proto files (relative to one of the import paths) which shall be parsed
+	Files []string `yaml:"files"`
+	// DescriptorSetFiles are paths to compiled FileDescriptorSets
+	DescriptorSetFiles []string `yaml:"descriptorSetFiles"`
+
+	// Mappings define which proto type shall be used to deserialize the key and/or value of a topic's messages
+	Mappings []ConfigTopicMapping `yaml:"mappings"`
+}
+
+// ConfigTopicMapping maps a topic to the fully qualified proto types of its keys and values
+type ConfigTopicMapping struct {
+	TopicName      string `yaml:"topicName"`
+	KeyProtoType   string `yaml:"keyProtoType"`
+	ValueProtoType string `yaml:"valueProtoType"`
+}
+
+// Validate the proto config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Files) == 0 && len(c.DescriptorSetFiles) == 0 {
+		return fmt.Errorf("at least one proto file or descriptor set file must be configured if proto is enabled")
+	}
+
+	for i, mapping := range c.Mappings {
+		if mapping.TopicName == "" {
+			return fmt.Errorf("topic name of mapping at index '%v' must be set", i)
+		}
+		if mapping.KeyProtoType == "" && mapping.ValueProtoType == "" {
+			return fmt.Errorf("mapping for topic '%v' must at least set a key or value proto type", mapping.TopicName)
+		}
+	}
+
+	return nil
+}
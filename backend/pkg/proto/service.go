@@ -0,0 +1,158 @@
+package proto
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"go.uber.org/zap"
+)
+
+// RecordPropertyType determines whether the key or the value of a record shall be deserialized
+type RecordPropertyType int
+
+const (
+	RecordKey RecordPropertyType = iota
+	RecordValue
+)
+
+// ErrNoMapping is returned if there is no proto type configured for the requested topic and record property
+var ErrNoMapping = errors.New("no proto type mapping configured")
+
+// Service resolves the configured proto types and deserializes Protobuf encoded payloads into JSON
+type Service struct {
+	logger *zap.Logger
+
+	// Fully qualified proto type name -> message descriptor
+	descriptorsByType map[string]*desc.MessageDescriptor
+	mappingsByTopic   map[string]ConfigTopicMapping
+
+	// anyResolver resolves the types of google.protobuf.Any fields against all configured files, so that envelope
+	// messages can carry payloads whose types are not imported by the envelope's file
+	anyResolver jsonpb.AnyResolver
+}
+
+// NewService parses all configured proto files and descriptor sets and ensures that all mapped types can be resolved
+func NewService(cfg Config, logger *zap.Logger) (*Service, error) {
+	files := make([]*desc.FileDescriptor, 0)
+
+	if len(cfg.Files) > 0 {
+		parser := protoparse.Parser{ImportPaths: cfg.ImportPaths}
+		parsed, err := parser.ParseFiles(cfg.Files...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proto files: %w", err)
+		}
+		files = append(files, parsed...)
+	}
+
+	for _, path := range cfg.DescriptorSetFiles {
+		set, err := readDescriptorSet(path)
+		if err != nil {
+			return nil, err
+		}
+		descriptors, err := desc.CreateFileDescriptorsFromSet(set)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file descriptors from set '%v': %w", path, err)
+		}
+		for _, fd := range descriptors {
+			files = append(files, fd)
+		}
+	}
+
+	descriptorsByType := make(map[string]*desc.MessageDescriptor)
+	for _, fd := range files {
+		registerMessageTypes(descriptorsByType, fd.GetMessageTypes())
+	}
+
+	mappingsByTopic := make(map[string]ConfigTopicMapping, len(cfg.Mappings))
+	for _, mapping := range cfg.Mappings {
+		for _, typeName := range []string{mapping.KeyProtoType, mapping.ValueProtoType} {
+			if typeName == "" {
+				continue
+			}
+			if _, exists := descriptorsByType[typeName]; !exists {
+				return nil, fmt.Errorf("proto type '%v' which is mapped to topic '%v' could not be found", typeName, mapping.TopicName)
+			}
+		}
+		mappingsByTopic[mapping.TopicName] = mapping
+	}
+	logger.Info("loaded proto types", zap.Int("types", len(descriptorsByType)), zap.Int("topic_mappings", len(mappingsByTopic)))
+
+	return &Service{
+		logger:            logger,
+		descriptorsByType: descriptorsByType,
+		mappingsByTopic:   mappingsByTopic,
+		anyResolver:       dynamic.AnyResolver(nil, files...),
+	}, nil
+}
+
+// UnmarshalPayload deserializes the given Protobuf payload with the proto type that has been mapped to the topic
+// and returns its JSON representation. ErrNoMapping is returned if no type has been configured. Well-known types
+// (e.g. Timestamp, Duration and Struct) are rendered in their canonical JSON form and Any fields are rendered as the
+// resolved message along with its '@type'.
+func (s *Service) UnmarshalPayload(payload []byte, topicName string, property RecordPropertyType) ([]byte, error) {
+	md, err := s.getMessageDescriptor(topicName, property)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamic.NewMessage(md)
+	err = msg.Unmarshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload into '%v': %w", md.GetFullyQualifiedName(), err)
+	}
+
+	marshaler := &jsonpb.Marshaler{EmitDefaults: true, AnyResolver: s.anyResolver}
+	jsonBytes, err := msg.MarshalJSONPB(marshaler)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize '%v' to json: %w", md.GetFullyQualifiedName(), err)
+	}
+
+	return jsonBytes, nil
+}
+
+func (s *Service) getMessageDescriptor(topicName string, property RecordPropertyType) (*desc.MessageDescriptor, error) {
+	mapping, exists := s.mappingsByTopic[topicName]
+	if !exists {
+		return nil, ErrNoMapping
+	}
+
+	typeName := mapping.ValueProtoType
+	if property == RecordKey {
+		typeName = mapping.KeyProtoType
+	}
+	if typeName == "" {
+		return nil, ErrNoMapping
+	}
+
+	return s.descriptorsByType[typeName], nil
+}
+
+// registerMessageTypes adds all given message types including their nested types to the given map
+func registerMessageTypes(descriptorsByType map[string]*desc.MessageDescriptor, types []*desc.MessageDescriptor) {
+	for _, md := range types {
+		descriptorsByType[md.GetFullyQualifiedName()] = md
+		registerMessageTypes(descriptorsByType, md.GetNestedMessageTypes())
+	}
+}
+
+func readDescriptorSet(path string) (*dpb.FileDescriptorSet, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set file '%v': %w", path, err)
+	}
+
+	var set dpb.FileDescriptorSet
+	err = proto.Unmarshal(b, &set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal descriptor set file '%v': %w", path, err)
+	}
+
+	return &set, nil
+}
@@ -0,0 +1,165 @@
+package proto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const envelopeProto = `syntax = "proto3";
+package events;
+
+import "google/protobuf/any.proto";
+import "google/protobuf/duration.proto";
+import "google/protobuf/timestamp.proto";
+
+message Envelope {
+  string id = 1;
+  google.protobuf.Timestamp created_at = 2;
+  google.protobuf.Duration ttl = 3;
+  google.protobuf.Any payload = 4;
+}
+`
+
+// The payload type is not imported by the envelope, it can only be resolved via the configured files
+const orderProto = `syntax = "proto3";
+package shop;
+
+message Order {
+  string order_id = 1;
+  int32 quantity = 2;
+}
+`
+
+func TestUnmarshalPayloadResolvesAny(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kowl-proto")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "envelope.proto"), []byte(envelopeProto), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "order.proto"), []byte(orderProto), 0644))
+
+	svc, err := NewService(Config{
+		Enabled:     true,
+		ImportPaths: []string{dir},
+		Files:       []string{"envelope.proto", "order.proto"},
+		Mappings:    []ConfigTopicMapping{{TopicName: "events", ValueProtoType: "events.Envelope"}},
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	order := dynamic.NewMessage(svc.descriptorsByType["shop.Order"])
+	order.SetFieldByName("order_id", "o-1")
+	order.SetFieldByName("quantity", int32(3))
+	orderBytes, err := order.Marshal()
+	require.NoError(t, err)
+
+	envelope := dynamic.NewMessage(svc.descriptorsByType["events.Envelope"])
+	envelope.SetFieldByName("id", "e-1")
+	envelope.SetFieldByName("created_at", &timestamp.Timestamp{Seconds: 1600000000})
+	envelope.SetFieldByName("ttl", &duration.Duration{Seconds: 90})
+	envelope.SetFieldByName("payload", &any.Any{TypeUrl: "type.googleapis.com/shop.Order", Value: orderBytes})
+	payload, err := envelope.Marshal()
+	require.NoError(t, err)
+
+	json, err := svc.UnmarshalPayload(payload, "events", RecordValue)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "e-1",
+		"createdAt": "2020-09-13T12:26:40Z",
+		"ttl": "90s",
+		"payload": {"@type": "type.googleapis.com/shop.Order", "orderId": "o-1", "quantity": 3}
+	}`, string(json))
+
+	_, err = svc.UnmarshalPayload(payload, "other-topic", RecordValue)
+	assert.Equal(t, ErrNoMapping, err)
+}
+
+const customerProto = `syntax = "proto3";
+package crm;
+
+message Customer {
+  message Address {
+    string city = 1;
+  }
+  string name = 1;
+  Address address = 2;
+  repeated string tags = 3;
+}
+
+message CustomerKey {
+  int64 id = 1;
+}
+`
+
+func TestUnmarshalPayloadFromDescriptorSet(t *testing.T) {
+	// Compile the proto file into a descriptor set, like protoc --descriptor_set_out does
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{"customer.proto": customerProto})}
+	fds, err := parser.ParseFiles("customer.proto")
+	require.NoError(t, err)
+	setBytes, err := proto.Marshal(&dpb.FileDescriptorSet{File: []*dpb.FileDescriptorProto{fds[0].AsFileDescriptorProto()}})
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "kowl-proto")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	setPath := filepath.Join(dir, "customer.desc")
+	require.NoError(t, ioutil.WriteFile(setPath, setBytes, 0644))
+
+	svc, err := NewService(Config{
+		Enabled:            true,
+		DescriptorSetFiles: []string{setPath},
+		Mappings:           []ConfigTopicMapping{{TopicName: "customers", KeyProtoType: "crm.CustomerKey", ValueProtoType: "crm.Customer"}},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.Contains(t, svc.descriptorsByType, "crm.Customer.Address", "nested types are registered")
+
+	address := dynamic.NewMessage(svc.descriptorsByType["crm.Customer.Address"])
+	address.SetFieldByName("city", "Berlin")
+	customer := dynamic.NewMessage(svc.descriptorsByType["crm.Customer"])
+	customer.SetFieldByName("name", "Jane")
+	customer.SetFieldByName("address", address)
+	customer.SetFieldByName("tags", []string{"vip"})
+	value, err := customer.Marshal()
+	require.NoError(t, err)
+	key := dynamic.NewMessage(svc.descriptorsByType["crm.CustomerKey"])
+	key.SetFieldByName("id", int64(42))
+	keyBytes, err := key.Marshal()
+	require.NoError(t, err)
+
+	json, err := svc.UnmarshalPayload(value, "customers", RecordValue)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "Jane", "address": {"city": "Berlin"}, "tags": ["vip"]}`, string(json))
+
+	json, err = svc.UnmarshalPayload(keyBytes, "customers", RecordKey)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "42"}`, string(json))
+
+	_, err = svc.UnmarshalPayload([]byte{0xff, 0xff}, "customers", RecordValue)
+	assert.Error(t, err, "invalid payloads can't be unmarshalled")
+}
+
+func TestNewServiceRejectsUnknownMappedType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kowl-proto")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "order.proto"), []byte(orderProto), 0644))
+
+	_, err = NewService(Config{
+		Enabled:     true,
+		ImportPaths: []string{dir},
+		Files:       []string{"order.proto"},
+		Mappings:    []ConfigTopicMapping{{TopicName: "orders", ValueProtoType: "shop.Unknown"}},
+	}, zap.NewNop())
+	assert.Error(t, err)
+}
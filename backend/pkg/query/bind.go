@@ -0,0 +1,52 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bind replaces ${name} placeholders with the values as literals, strings are quoted and escaped. Placeholders
+// within strings (e.g. in js() code) are not replaced, so that values can't change the structure of the query.
+// Values must be strings or float64.
+func Bind(query string, values map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	i := 0
+	for i < len(query) {
+		switch {
+		case query[i] == '"':
+			end, err := stringEnd(query, i)
+			if err != nil {
+				// The unterminated string is reported when the bound query is parsed
+				sb.WriteString(query[i:])
+				return sb.String(), nil
+			}
+			sb.WriteString(query[i:end])
+			i = end
+		case strings.HasPrefix(query[i:], "${"):
+			end := strings.IndexByte(query[i:], '}')
+			if end == -1 {
+				return "", &SyntaxError{Position: i, Message: "placeholder is not terminated"}
+			}
+			name := query[i+2 : i+end]
+			value, exists := values[name]
+			if !exists {
+				return "", &SyntaxError{Position: i, Message: fmt.Sprintf("no value for placeholder '%v'", name)}
+			}
+			switch v := value.(type) {
+			case string:
+				sb.WriteString(strconv.Quote(v))
+			case float64:
+				sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				return "", fmt.Errorf("value of placeholder '%v' must be a string or number", name)
+			}
+			i += end + 1
+		default:
+			sb.WriteByte(query[i])
+			i++
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,289 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// Comparison operators
+const (
+	opEqual          = "="
+	opNotEqual       = "!="
+	opLess           = "<"
+	opLessOrEqual    = "<="
+	opGreater        = ">"
+	opGreaterOrEqual = ">="
+	opContains       = "~"
+	opMatches        = "=~"
+)
+
+// expr is a node of a parsed query which can be evaluated natively
+type expr interface {
+	eval(r *record) bool
+}
+
+type andExpr struct {
+	terms []expr
+}
+
+func (e *andExpr) eval(r *record) bool {
+	for _, term := range e.terms {
+		if !term.eval(r) {
+			return false
+		}
+	}
+	return true
+}
+
+type orExpr struct {
+	terms []expr
+}
+
+func (e *orExpr) eval(r *record) bool {
+	for _, term := range e.terms {
+		if term.eval(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type notExpr struct {
+	inner expr
+}
+
+func (e *notExpr) eval(r *record) bool {
+	return !e.inner.eval(r)
+}
+
+// jsExpr is a part of the query which is evaluated by the filter interpreter. It's taken out of the native predicate
+// when the query is compiled, hence it always matches.
+type jsExpr struct {
+	code     string
+	position int
+}
+
+func (e *jsExpr) eval(*record) bool {
+	return true
+}
+
+// field is the part of a record a comparison refers to. Path selects a nested field of JSON keys and values.
+type field struct {
+	name      string
+	path      []string
+	headerKey string
+}
+
+// comparison compares a field with a literal. Missing fields only equal null.
+type comparison struct {
+	field   field
+	op      string
+	literal interface{} // string, float64, bool or nil
+	regex   *regexp.Regexp
+}
+
+func (c *comparison) eval(r *record) bool {
+	value, exists := r.lookup(c.field)
+	if !exists || value == nil {
+		switch c.op {
+		case opEqual:
+			return c.literal == nil
+		case opNotEqual:
+			return c.literal != nil
+		}
+		return false
+	}
+
+	switch c.op {
+	case opContains:
+		return strings.Contains(stringify(value), c.literal.(string))
+	case opMatches:
+		return c.regex.MatchString(stringify(value))
+	case opEqual:
+		return compare(value, c.literal) == 0
+	case opNotEqual:
+		return compare(value, c.literal) != 0
+	}
+
+	order := compare(value, c.literal)
+	if order == incomparable {
+		return false
+	}
+	switch c.op {
+	case opLess:
+		return order < 0
+	case opLessOrEqual:
+		return order <= 0
+	case opGreater:
+		return order > 0
+	case opGreaterOrEqual:
+		return order >= 0
+	}
+	return false
+}
+
+// incomparable is returned by compare for values of different types, it's neither equal, less nor greater
+const incomparable = 2
+
+// compare orders a field value and a literal. Numbers are compared numerically, also if one of them is a numeric
+// string, everything else by its string representation.
+func compare(value interface{}, lit interface{}) int {
+	if lit == nil {
+		return incomparable
+	}
+	if b, ok := lit.(bool); ok {
+		v, ok := value.(bool)
+		if !ok {
+			return incomparable
+		}
+		if v == b {
+			return 0
+		}
+		return incomparable
+	}
+
+	if n, ok := lit.(float64); ok {
+		v, ok := toNumber(value)
+		if !ok {
+			return incomparable
+		}
+		switch {
+		case v < n:
+			return -1
+		case v > n:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(stringify(value), lit.(string))
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// stringify returns strings as they are and all other values in their JSON representation
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// record is a message under evaluation. JSON keys and values are parsed at most once, when a comparison refers to
+// one of their fields.
+type record struct {
+	msg       *kafka.TopicMessage
+	timestamp time.Time
+
+	key, value                 interface{}
+	isKeyParsed, isValueParsed bool
+}
+
+// lookup returns the value of the field, or false if it doesn't exist in the record
+func (r *record) lookup(f field) (interface{}, bool) {
+	switch f.name {
+	case fieldPartition:
+		return float64(r.msg.PartitionID), true
+	case fieldOffset:
+		return float64(r.msg.Offset), true
+	case fieldTimestamp:
+		return float64(r.timestamp.UnixNano() / int64(time.Millisecond)), true
+	case fieldSize:
+		return float64(r.msg.Size), true
+	case fieldHeader:
+		for _, h := range r.msg.Headers {
+			if h.Key == f.headerKey {
+				return string(h.Value.Value), true
+			}
+		}
+		return nil, false
+	case fieldKey:
+		if len(f.path) == 0 {
+			return payloadText(r.msg.Key), r.msg.Key.Value != nil
+		}
+		if !r.isKeyParsed {
+			r.key, r.isKeyParsed = parseJSON(r.msg.Key), true
+		}
+		return lookupPath(r.key, f.path)
+	case fieldValue:
+		if len(f.path) == 0 {
+			return payloadText(r.msg.Value), !r.msg.IsValueNull
+		}
+		if !r.isValueParsed {
+			r.value, r.isValueParsed = parseJSON(r.msg.Value), true
+		}
+		return lookupPath(r.value, f.path)
+	}
+	return nil, false
+}
+
+func payloadText(payload kafka.DirectEmbedding) string {
+	return string(payload.Value)
+}
+
+// parseJSON returns the parsed payload, or nil if it's not a JSON object or array
+func parseJSON(payload kafka.DirectEmbedding) interface{} {
+	trimmed := bytes.TrimSpace(payload.Value)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil
+	}
+	return parsed
+}
+
+// lookupPath walks down objects by their keys and arrays by their indexes
+func lookupPath(parsed interface{}, path []string) (interface{}, bool) {
+	current := parsed
+	for _, segment := range path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, exists := v[segment]
+			if !exists {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
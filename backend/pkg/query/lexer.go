@@ -0,0 +1,138 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of query"
+	case tokenIdent:
+		return "identifier"
+	case tokenString:
+		return "string"
+	case tokenNumber:
+		return "number"
+	case tokenOperator:
+		return "operator"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	}
+	return "token"
+}
+
+// token is a lexical unit of a query. Text is the unquoted value of strings and the literal text of all other kinds.
+type token struct {
+	kind     tokenKind
+	text     string
+	position int // Byte offset in the query, reported in syntax errors
+}
+
+// operators in the order they are matched, longer operators first
+var operators = []string{"!=", ">=", "<=", "=~", "=", ">", "<", "~"}
+
+// tokenize splits the query into tokens. Identifiers may contain dots, so that field paths like value.order.id are a
+// single token.
+func tokenize(query string) ([]token, error) {
+	tokens := make([]token, 0)
+	i := 0
+	for i < len(query) {
+		c := rune(query[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", position: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", position: i})
+			i++
+		case c == '"':
+			end, err := stringEnd(query, i)
+			if err != nil {
+				return nil, err
+			}
+			text, err := strconv.Unquote(query[i:end])
+			if err != nil {
+				return nil, &SyntaxError{Position: i, Message: fmt.Sprintf("invalid string: %v", err)}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, position: i})
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(query) && (isDigit(query[end]) || query[end] == '.') {
+				end++
+			}
+			if _, err := strconv.ParseFloat(query[i:end], 64); err != nil {
+				return nil, &SyntaxError{Position: i, Message: fmt.Sprintf("invalid number '%v'", query[i:end])}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: query[i:end], position: i})
+			i = end
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(query) && isIdentPart(rune(query[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: query[i:end], position: i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(query[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{Position: i, Message: fmt.Sprintf("unexpected character '%c'", c)}
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, position: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, position: len(query)}), nil
+}
+
+// stringEnd returns the offset after the closing quote of the string starting at start
+func stringEnd(query string, start int) (int, error) {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, &SyntaxError{Position: start, Message: "string is not terminated"}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || (c < unicode.MaxASCII && unicode.IsLetter(c))
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '.' || c == '-'
+}
@@ -0,0 +1,302 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyntaxError is returned for queries which can't be parsed. Position is the byte offset in the query.
+type SyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %v: %v", e.Position, e.Message)
+}
+
+// Fields which can be compared without a path
+const (
+	fieldPartition = "partition"
+	fieldOffset    = "offset"
+	fieldTimestamp = "timestamp"
+	fieldSize      = "size"
+	fieldKey       = "key"
+	fieldValue     = "value"
+	fieldHeader    = "header"
+)
+
+// Functions which are called with a single string argument
+const (
+	functionHeader = "header"
+	functionJS     = "js"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, unexpected(t, kind.String())
+	}
+	return t, nil
+}
+
+func unexpected(t token, expected string) error {
+	found := t.kind.String()
+	if t.kind != tokenEOF {
+		found = fmt.Sprintf("'%v'", t.text)
+	}
+	return &SyntaxError{Position: t.position, Message: fmt.Sprintf("expected %v but found %v", expected, found)}
+}
+
+// parseOr parses: and (OR and)*
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isKeyword("OR") {
+		return left, nil
+	}
+
+	terms := []expr{left}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+	return &orExpr{terms: terms}, nil
+}
+
+// parseAnd parses: unary (AND unary)*
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isKeyword("AND") {
+		return left, nil
+	}
+
+	terms := []expr{left}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+	return &andExpr{terms: terms}, nil
+}
+
+// parseUnary parses: NOT unary | '(' or ')' | term
+func (p *parser) parseUnary() (expr, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{inner: inner}, nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	return p.parseTerm()
+}
+
+// parseTerm parses a comparison or a js() call
+func (p *parser) parseTerm() (expr, error) {
+	t, err := p.expect(tokenIdent)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(t.text)
+	var f field
+	switch {
+	case name == functionJS && p.peek().kind == tokenLParen:
+		code, err := p.parseFunctionArgument()
+		if err != nil {
+			return nil, err
+		}
+		return &jsExpr{code: code, position: t.position}, nil
+	case name == functionHeader && p.peek().kind == tokenLParen:
+		headerKey, err := p.parseFunctionArgument()
+		if err != nil {
+			return nil, err
+		}
+		f = field{name: fieldHeader, headerKey: headerKey}
+	default:
+		f, err = parseField(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opToken, err := p.expect(tokenOperator)
+	if err != nil {
+		return nil, err
+	}
+	lit, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+
+	return newComparison(f, opToken, lit)
+}
+
+func (p *parser) parseFunctionArgument() (string, error) {
+	if _, err := p.expect(tokenLParen); err != nil {
+		return "", err
+	}
+	arg, err := p.expect(tokenString)
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.expect(tokenRParen); err != nil {
+		return "", err
+	}
+	return arg.text, nil
+}
+
+func (p *parser) parseLiteral() (literal, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return literal{value: t.text, position: t.position}, nil
+	case tokenNumber:
+		number, _ := strconv.ParseFloat(t.text, 64) // Checked by the lexer
+		return literal{value: number, position: t.position}, nil
+	case tokenIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literal{value: true, position: t.position}, nil
+		case "false":
+			return literal{value: false, position: t.position}, nil
+		case "null":
+			return literal{value: nil, position: t.position}, nil
+		}
+	}
+	return literal{}, unexpected(t, "string, number, true, false or null")
+}
+
+// parseField parses field names like offset or value.order.id
+func parseField(t token) (field, error) {
+	segments := strings.Split(t.text, ".")
+	name := strings.ToLower(segments[0])
+	switch name {
+	case fieldPartition, fieldOffset, fieldTimestamp, fieldSize:
+		if len(segments) > 1 {
+			return field{}, &SyntaxError{Position: t.position, Message: fmt.Sprintf("field '%v' has no subfields", name)}
+		}
+	case fieldKey, fieldValue:
+		for _, segment := range segments[1:] {
+			if segment == "" {
+				return field{}, &SyntaxError{Position: t.position, Message: fmt.Sprintf("invalid field path '%v'", t.text)}
+			}
+		}
+	default:
+		return field{}, &SyntaxError{
+			Position: t.position,
+			Message:  fmt.Sprintf("unknown field '%v', must be partition, offset, timestamp, size, key, value or header(\"name\")", t.text),
+		}
+	}
+
+	return field{name: name, path: segments[1:]}, nil
+}
+
+// newComparison validates the operator and literal for the field and precompiles regexes and timestamps
+func newComparison(f field, opToken token, lit literal) (expr, error) {
+	c := &comparison{field: f, op: opToken.text, literal: lit.value}
+
+	switch c.op {
+	case opMatches:
+		pattern, ok := lit.value.(string)
+		if !ok {
+			return nil, &SyntaxError{Position: lit.position, Message: "operator '=~' requires a string with a regular expression"}
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, &SyntaxError{Position: lit.position, Message: fmt.Sprintf("invalid regular expression: %v", err)}
+		}
+		c.regex = re
+	case opContains:
+		if _, ok := lit.value.(string); !ok {
+			return nil, &SyntaxError{Position: lit.position, Message: "operator '~' requires a string"}
+		}
+	case opLess, opLessOrEqual, opGreater, opGreaterOrEqual:
+		if lit.value == nil {
+			return nil, &SyntaxError{Position: opToken.position, Message: fmt.Sprintf("null can't be compared with '%v'", c.op)}
+		}
+	}
+
+	switch f.name {
+	case fieldPartition, fieldOffset, fieldSize:
+		if _, ok := lit.value.(float64); !ok {
+			return nil, &SyntaxError{Position: lit.position, Message: fmt.Sprintf("field '%v' must be compared with a number", f.name)}
+		}
+	case fieldTimestamp:
+		if c.regex != nil || c.op == opContains {
+			return nil, &SyntaxError{Position: opToken.position, Message: fmt.Sprintf("timestamp can't be compared with '%v'", c.op)}
+		}
+		// Timestamps are compared as unix milliseconds, RFC 3339 strings are converted at parse time
+		switch v := lit.value.(type) {
+		case float64:
+		case string:
+			ts, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, &SyntaxError{Position: lit.position, Message: "timestamp must be compared with unix milliseconds or an RFC 3339 time"}
+			}
+			c.literal = float64(ts.UnixNano() / int64(time.Millisecond))
+		default:
+			return nil, &SyntaxError{Position: lit.position, Message: "timestamp must be compared with unix milliseconds or an RFC 3339 time"}
+		}
+	}
+
+	return c, nil
+}
+
+// literal is a constant of a comparison, its value is a string, float64, bool or nil
+type literal struct {
+	value    interface{}
+	position int
+}
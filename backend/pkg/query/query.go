@@ -0,0 +1,152 @@
+// Package query implements a small search language for messages, e.g.
+//
+//	partition = 3 AND offset > 1000 AND value.status = "FAILED" AND header("source") = "billing"
+//
+// Comparisons of metadata, payloads and headers are compiled to a native predicate. Parts which can't be expressed
+// with comparisons are written as js("...") and evaluated by the filter interpreter, like filter code.
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+)
+
+// Query is a parsed search query
+type Query struct {
+	native expr // nil if the query consists of js() terms only
+	jsCode []string
+
+	partitionID int32 // -1 if the query doesn't restrict the partition
+}
+
+// Parse compiles the query. Syntax errors are reported with *SyntaxError. js() terms must be combined with the rest
+// of the query by AND on the top level, as they are evaluated separately.
+func Parse(query string) (*Query, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, &SyntaxError{Position: 0, Message: "query is empty"}
+	}
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, unexpected(t, "AND, OR or end of query")
+	}
+
+	q := &Query{partitionID: -1}
+	native := make([]expr, 0)
+	for _, term := range conjuncts(root) {
+		switch t := term.(type) {
+		case *jsExpr:
+			q.jsCode = append(q.jsCode, t.code)
+			continue
+		case *comparison:
+			if n, ok := t.literal.(float64); ok && t.field.name == fieldPartition && t.op == opEqual && n == float64(int32(n)) {
+				q.partitionID = int32(n)
+			}
+		}
+		if js := findJS(term); js != nil {
+			return nil, &SyntaxError{Position: js.position, Message: "js() can only be combined with the rest of the query by AND"}
+		}
+		native = append(native, term)
+	}
+	switch len(native) {
+	case 0:
+	case 1:
+		q.native = native[0]
+	default:
+		q.native = &andExpr{terms: native}
+	}
+
+	return q, nil
+}
+
+// Predicate returns the native part of the query, or nil if the query consists of js() terms only. The timestamp
+// is passed separately, because the message's timestamp only has a precision of seconds.
+func (q *Query) Predicate() kafka.MessagePredicate {
+	if q.native == nil {
+		return nil
+	}
+	return func(msg *kafka.TopicMessage, timestamp time.Time) bool {
+		return q.native.eval(&record{msg: msg, timestamp: timestamp})
+	}
+}
+
+// FilterCode returns the js() terms as the body of filter code, which is empty if the query has no js() terms. Each
+// term is a function body of its own, so that the terms can return early.
+func (q *Query) FilterCode() string {
+	return CombineFilterCode(q.jsCode...)
+}
+
+// PartitionID returns the partition the query is restricted to by a top level 'partition = n', or -1
+func (q *Query) PartitionID() int32 {
+	return q.partitionID
+}
+
+// CombineFilterCode joins the bodies of filter code, so that messages must pass all of them. Empty bodies are
+// skipped. The parameters of the filter function are visible to each body via closure.
+func CombineFilterCode(bodies ...string) string {
+	nonEmpty := make([]string, 0, len(bodies))
+	for _, body := range bodies {
+		if strings.TrimSpace(body) != "" {
+			nonEmpty = append(nonEmpty, body)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return ""
+	case 1:
+		return nonEmpty[0]
+	}
+
+	var sb strings.Builder
+	for _, body := range nonEmpty {
+		sb.WriteString(fmt.Sprintf("if (!(function() {\n%v\n})()) { return false; }\n", body))
+	}
+	sb.WriteString("return true;")
+	return sb.String()
+}
+
+// conjuncts flattens nested ANDs into the list of their terms
+func conjuncts(e expr) []expr {
+	and, ok := e.(*andExpr)
+	if !ok {
+		return []expr{e}
+	}
+	terms := make([]expr, 0, len(and.terms))
+	for _, term := range and.terms {
+		terms = append(terms, conjuncts(term)...)
+	}
+	return terms
+}
+
+// findJS returns the first js() term within the expression
+func findJS(e expr) *jsExpr {
+	switch t := e.(type) {
+	case *jsExpr:
+		return t
+	case *andExpr:
+		for _, term := range t.terms {
+			if js := findJS(term); js != nil {
+				return js
+			}
+		}
+	case *orExpr:
+		for _, term := range t.terms {
+			if js := findJS(term); js != nil {
+				return js
+			}
+		}
+	case *notExpr:
+		return findJS(t.inner)
+	}
+	return nil
+}
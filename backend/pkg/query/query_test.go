@@ -0,0 +1,121 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_Predicate(t *testing.T) {
+	timestamp := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	msg := &kafka.TopicMessage{
+		PartitionID: 3,
+		Offset:      1500,
+		Size:        120,
+		Key:         kafka.DirectEmbedding{Value: []byte("order-42")},
+		Value:       kafka.DirectEmbedding{Value: []byte(`{"status": "FAILED", "order": {"id": 42, "items": ["a", "b"]}, "retry": true}`)},
+		Headers:     []kafka.MessageHeader{{Key: "source", Value: kafka.DirectEmbedding{Value: []byte("billing")}}},
+	}
+
+	tt := []struct {
+		query     string
+		isMatched bool
+	}{
+		{`partition = 3 AND offset > 1000 AND value.status = "FAILED" AND header("source") = "billing"`, true},
+		{`partition = 2`, false},
+		{`offset >= 1500 AND offset <= 1500 AND size < 200`, true},
+		{`offset != 1500`, false},
+		{`value.order.id = 42 AND value.order.id = "42"`, true},
+		{`value.order.items.1 = "b"`, true},
+		{`value.order.items.2 = "c"`, false},
+		{`value.retry = true`, true},
+		{`value.missing = null AND NOT value.status = null`, true},
+		{`value.missing != "x"`, true},
+		{`value.missing = "x"`, false},
+		{`key = "order-42" AND key ~ "42" AND key =~ "^order-\\d+$"`, true},
+		{`header("source") = "billing" AND header("trace") = null`, true},
+		{`value.status = "OK" OR (partition = 3 AND NOT size > 200)`, true},
+		{`timestamp >= "2020-06-01T12:00:00Z" AND timestamp < 1591013400000`, true},
+		{`timestamp > "2020-06-01T12:00:00Z"`, false},
+	}
+
+	for i, test := range tt {
+		q, err := Parse(test.query)
+		require.NoError(t, err, "Case: ", i)
+		predicate := q.Predicate()
+		require.NotNil(t, predicate, "Case: ", i)
+		assert.Equal(t, test.isMatched, predicate(msg, timestamp), "Case: ", i)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tt := []struct {
+		query    string
+		position int
+	}{
+		{``, 0},
+		{`offset >`, 8},
+		{`offset > "10"`, 9},
+		{`timestamp > "yesterday"`, 12},
+		{`timestamp ~ "2020"`, 10},
+		{`topic = "orders"`, 0},
+		{`partition.id = 1`, 0},
+		{`value = "unterminated`, 8},
+		{`value =~ "("`, 9},
+		{`value > null`, 6},
+		{`(offset = 1`, 11},
+		{`offset = 1 offset = 2`, 11},
+		{`offset = 1 OR js("return true")`, 14},
+		{`NOT js("return true")`, 4},
+	}
+
+	for i, test := range tt {
+		_, err := Parse(test.query)
+		require.Error(t, err, "Case: ", i)
+		syntaxErr, ok := err.(*SyntaxError)
+		require.True(t, ok, "Case: ", i)
+		assert.Equal(t, test.position, syntaxErr.Position, "Case: ", i)
+	}
+}
+
+func TestQuery_FilterCode(t *testing.T) {
+	q, err := Parse(`partition = 1 AND js("return value.items.length > 2") AND offset > 10`)
+	require.NoError(t, err)
+	assert.NotNil(t, q.Predicate())
+	assert.Equal(t, "return value.items.length > 2", q.FilterCode())
+	assert.Equal(t, int32(1), q.PartitionID())
+
+	q, err = Parse(`js("return true")`)
+	require.NoError(t, err)
+	assert.Nil(t, q.Predicate())
+	assert.Equal(t, int32(-1), q.PartitionID())
+
+	// The partition is only a hint if it's required by the whole query
+	q, err = Parse(`partition = 1 OR partition = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, int32(-1), q.PartitionID())
+	assert.Equal(t, "", q.FilterCode())
+}
+
+func TestCombineFilterCode(t *testing.T) {
+	assert.Equal(t, "", CombineFilterCode("", " "))
+	assert.Equal(t, "return true", CombineFilterCode("", "return true"))
+	assert.Equal(t,
+		"if (!(function() {\nreturn a\n})()) { return false; }\nif (!(function() {\nreturn b\n})()) { return false; }\nreturn true;",
+		CombineFilterCode("return a", "", "return b"),
+	)
+}
+
+func TestBind(t *testing.T) {
+	bound, err := Bind(`key = ${key} AND offset > ${offset} AND value ~ "${key}"`, map[string]interface{}{"key": `a" OR "b`, "offset": float64(10)})
+	require.NoError(t, err)
+	assert.Equal(t, `key = "a\" OR \"b" AND offset > 10 AND value ~ "${key}"`, bound)
+
+	_, err = Bind(`key = ${missing}`, map[string]interface{}{})
+	assert.Error(t, err)
+	_, err = Bind(`key = ${key`, map[string]interface{}{"key": "a"})
+	assert.Error(t, err)
+}
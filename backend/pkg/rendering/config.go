@@ -0,0 +1,125 @@
+package rendering
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/jsonpath"
+	"github.com/cloudhut/kowl/backend/pkg/topicpattern"
+)
+
+// Renderer types
+const (
+	TypeTimestamp = "timestamp"
+	TypeDecimal   = "decimal"
+	TypeEnum      = "enum"
+)
+
+// Timestamp units
+const (
+	UnitSeconds      = "s"
+	UnitMilliseconds = "ms"
+	UnitMicroseconds = "us"
+	UnitNanoseconds  = "ns"
+)
+
+// Config for rendering fields of consumed JSON based keys and values into a human readable form before they are
+// returned
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule renders the selected fields of the keys and values of all topics which match the topic pattern
+type Rule struct {
+	// TopicPattern is a regex which must match the whole topic name. An empty pattern matches all topics.
+	TopicPattern string `yaml:"topicPattern"`
+
+	// JSONPaths select the fields which shall be rendered, e.g. "$.createdAt" or "$.items[*].priceCents"
+	JSONPaths []string `yaml:"jsonPaths"`
+
+	// FieldNames are glob patterns (e.g. "*_millis") which are matched case-insensitively against the member names
+	// at any depth
+	FieldNames []string `yaml:"fieldNames"`
+
+	// Type is either 'timestamp', 'decimal' or 'enum'
+	Type string `yaml:"type"`
+
+	// Unit of numeric timestamps, either 's', 'ms' (default), 'us' or 'ns'
+	Unit string `yaml:"unit"`
+
+	// Layout of rendered timestamps as Go time layout, defaults to RFC 3339 (ISO 8601) with fractional seconds
+	Layout string `yaml:"layout"`
+
+	// Timezone of rendered timestamps, e.g. 'Europe/Berlin'. Defaults to UTC.
+	Timezone string `yaml:"timezone"`
+
+	// Scale is the number of decimal places of decimals, e.g. 2 renders the cents 1999 as 19.99
+	Scale int `yaml:"scale"`
+
+	// Prefix and Suffix are added to rendered decimals, e.g. "$" or " EUR"
+	Prefix string `yaml:"prefix"`
+	Suffix string `yaml:"suffix"`
+
+	// Values maps the raw values of enums (numbers or strings) to the rendered values, e.g. 1: ACTIVE. Values which
+	// are not mapped are returned unchanged.
+	Values map[string]string `yaml:"values"`
+}
+
+// Validate the rendering config
+func (c *Config) Validate() error {
+	for i, r := range c.Rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rendering rule at index '%v' is invalid: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) validate() error {
+	if len(r.JSONPaths) == 0 && len(r.FieldNames) == 0 {
+		return fmt.Errorf("at least one json path or field name must be set")
+	}
+	if _, err := topicpattern.Compile(r.TopicPattern); err != nil {
+		return fmt.Errorf("invalid topic pattern: %w", err)
+	}
+	for _, expr := range r.JSONPaths {
+		p, err := jsonpath.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid json path '%v': %w", expr, err)
+		}
+		if p.IsRoot() {
+			return fmt.Errorf("json path must select a field rather than the whole payload")
+		}
+	}
+	for _, pattern := range r.FieldNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid field name pattern '%v': %w", pattern, err)
+		}
+	}
+
+	switch r.Type {
+	case TypeTimestamp:
+		switch r.Unit {
+		case "", UnitSeconds, UnitMilliseconds, UnitMicroseconds, UnitNanoseconds:
+		default:
+			return fmt.Errorf("unit must be one of '%v', '%v', '%v' or '%v'", UnitSeconds, UnitMilliseconds, UnitMicroseconds, UnitNanoseconds)
+		}
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	case TypeDecimal:
+		if r.Scale < 0 || r.Scale > 18 {
+			return fmt.Errorf("scale must be between 0 and 18")
+		}
+	case TypeEnum:
+		if len(r.Values) == 0 {
+			return fmt.Errorf("values must be set for enums")
+		}
+	default:
+		return fmt.Errorf("type must be one of '%v', '%v' or '%v'", TypeTimestamp, TypeDecimal, TypeEnum)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package rendering
+
+import "github.com/cloudhut/kowl/backend/pkg/jsonpath"
+
+// RenderJSON replaces all selected scalar values of the given JSON document with their rendered form, which is
+// always a string. Values which can't be rendered (e.g. a timestamp field which contains text) are kept. The
+// document is streamed, so that the order of members is kept, and written in compact form.
+func (r *Renderer) RenderJSON(doc []byte) ([]byte, error) {
+	return jsonpath.Rewrite(doc, jsonRewriter{r})
+}
+
+// jsonRewriter replaces scalar values which are selected by a rule with their rendered form
+type jsonRewriter struct {
+	renderer *Renderer
+}
+
+func (w jsonRewriter) ReplaceValue(_ jsonpath.Location) (string, bool) {
+	return "", false
+}
+
+func (w jsonRewriter) ReplaceScalar(location jsonpath.Location, value interface{}) (string, bool) {
+	if len(location) == 0 || value == nil {
+		return "", false
+	}
+	rule := w.renderer.ruleAt(location)
+	if rule == nil {
+		return "", false
+	}
+	return rule.render(value)
+}
@@ -0,0 +1,210 @@
+package rendering
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/jsonpath"
+	"github.com/cloudhut/kowl/backend/pkg/topicpattern"
+)
+
+// Service compiles the configured rendering rules and creates renderers for consume requests
+type Service struct {
+	rules []*rule
+}
+
+type rule struct {
+	topicRegex *regexp.Regexp
+	paths      []*jsonpath.Path
+	fieldNames []string // Lower case
+
+	// render returns the rendered form of a scalar JSON value (string, json.Number or bool) or false if the value
+	// can't be rendered
+	render func(value interface{}) (string, bool)
+}
+
+// NewService compiles all rules. The config is expected to be validated.
+func NewService(cfg Config) (*Service, error) {
+	svc := &Service{rules: make([]*rule, len(cfg.Rules))}
+	for i, r := range cfg.Rules {
+		topicRegex, err := topicpattern.Compile(r.TopicPattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile topic pattern of rendering rule at index '%v': %w", i, err)
+		}
+		paths := make([]*jsonpath.Path, len(r.JSONPaths))
+		for j, expr := range r.JSONPaths {
+			paths[j], err = jsonpath.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile json path of rendering rule at index '%v': %w", i, err)
+			}
+		}
+		fieldNames := make([]string, len(r.FieldNames))
+		for j, pattern := range r.FieldNames {
+			fieldNames[j] = strings.ToLower(pattern)
+		}
+		render, err := newRenderFunc(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rendering rule at index '%v': %w", i, err)
+		}
+		svc.rules[i] = &rule{topicRegex: topicRegex, paths: paths, fieldNames: fieldNames, render: render}
+	}
+
+	return svc, nil
+}
+
+// Renderer returns the renderer for messages of the given topic. Nil is returned if no rule applies.
+func (s *Service) Renderer(topicName string) *Renderer {
+	r := &Renderer{}
+	for _, rule := range s.rules {
+		if rule.topicRegex.MatchString(topicName) {
+			r.rules = append(r.rules, rule)
+		}
+	}
+
+	if len(r.rules) == 0 {
+		return nil
+	}
+	return r
+}
+
+// Renderer replaces the values of all fields which are selected by its rules with their rendered form. If multiple
+// rules select a field, the first configured rule is applied.
+type Renderer struct {
+	rules []*rule
+}
+
+// ruleAt returns the first rule which selects the given location, nil if no rule does
+func (r *Renderer) ruleAt(location jsonpath.Location) *rule {
+	name, hasName := location[len(location)-1].(string)
+	if hasName {
+		name = strings.ToLower(name)
+	}
+	for _, rule := range r.rules {
+		if hasName {
+			for _, pattern := range rule.fieldNames {
+				if matched, _ := path.Match(pattern, name); matched {
+					return rule
+				}
+			}
+		}
+		for _, p := range rule.paths {
+			if p.MatchesLocation(location) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+func newRenderFunc(r Rule) (func(value interface{}) (string, bool), error) {
+	switch r.Type {
+	case TypeTimestamp:
+		location, err := time.LoadLocation(r.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		layout := r.Layout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		unit := r.Unit
+		if unit == "" {
+			unit = UnitMilliseconds
+		}
+		return func(value interface{}) (string, bool) {
+			ts, ok := toTime(value, unit)
+			if !ok {
+				return "", false
+			}
+			return ts.In(location).Format(layout), true
+		}, nil
+	case TypeDecimal:
+		divisor := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(r.Scale)), nil))
+		return func(value interface{}) (string, bool) {
+			number, ok := toRat(value)
+			if !ok {
+				return "", false
+			}
+			formatted := number.Quo(number, divisor).FloatString(r.Scale)
+			if strings.HasPrefix(formatted, "-") {
+				return "-" + r.Prefix + formatted[1:] + r.Suffix, true
+			}
+			return r.Prefix + formatted + r.Suffix, true
+		}, nil
+	case TypeEnum:
+		return func(value interface{}) (string, bool) {
+			var key string
+			switch v := value.(type) {
+			case string:
+				key = v
+			case json.Number:
+				key = v.String()
+			case bool:
+				key = strconv.FormatBool(v)
+			}
+			rendered, ok := r.Values[key]
+			return rendered, ok
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown renderer type '%v'", r.Type)
+}
+
+// numberText returns the text of numbers and of strings which contain a number. Protobuf's JSON mapping encodes
+// 64-bit integers as strings.
+func numberText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String(), true
+	case string:
+		trimmed := strings.TrimSpace(v)
+		if _, err := strconv.ParseFloat(trimmed, 64); err != nil {
+			return "", false
+		}
+		return trimmed, true
+	}
+	return "", false
+}
+
+func toTime(value interface{}, unit string) (time.Time, bool) {
+	text, ok := numberText(value)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	var nanosPerUnit int64
+	switch unit {
+	case UnitSeconds:
+		nanosPerUnit = int64(time.Second)
+	case UnitMilliseconds:
+		nanosPerUnit = int64(time.Millisecond)
+	case UnitMicroseconds:
+		nanosPerUnit = int64(time.Microsecond)
+	default:
+		nanosPerUnit = 1
+	}
+
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		unitsPerSecond := int64(time.Second) / nanosPerUnit
+		return time.Unix(n/unitsPerSecond, (n%unitsPerSecond)*nanosPerUnit), true
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(f*float64(nanosPerUnit))), true
+}
+
+func toRat(value interface{}) (*big.Rat, bool) {
+	text, ok := numberText(value)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(text)
+}
@@ -0,0 +1,67 @@
+package rendering
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderer_RenderJSON(t *testing.T) {
+	cfg := Config{Rules: []Rule{
+		{TopicPattern: "orders-.*", FieldNames: []string{"*_millis"}, Type: TypeTimestamp},
+		{TopicPattern: "orders-.*", JSONPaths: []string{"$.items[*].amount_cents"}, Type: TypeDecimal, Scale: 2, Prefix: "$"},
+		{TopicPattern: "orders-.*", FieldNames: []string{"status"}, Type: TypeEnum, Values: map[string]string{"1": "ACTIVE", "2": "CLOSED"}},
+		{TopicPattern: "payments", FieldNames: []string{"ts"}, Type: TypeTimestamp, Unit: UnitSeconds, Timezone: "Europe/Berlin"},
+	}}
+	require.NoError(t, cfg.Validate())
+	svc, err := NewService(cfg)
+	require.NoError(t, err)
+
+	assert.Nil(t, svc.Renderer("payments-eu"))
+
+	r := svc.Renderer("orders-eu")
+	require.NotNil(t, r)
+	rendered, err := r.RenderJSON([]byte(`{
+		"created_millis": 1600000000123, "updated_millis": "1600000000000", "deleted_millis": "never",
+		"items": [{"amount_cents": 1999}, {"amount_cents": "-5"}, {"amount_cents": null}],
+		"amount_cents": 100, "status": 2, "nested": {"status": "3"}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"created_millis":"2020-09-13T12:26:40.123Z","updated_millis":"2020-09-13T12:26:40Z",`+
+		`"deleted_millis":"never","items":[{"amount_cents":"$19.99"},{"amount_cents":"-$0.05"},{"amount_cents":null}],`+
+		`"amount_cents":100,"status":"CLOSED","nested":{"status":"3"}}`, string(rendered))
+
+	rendered, err = svc.Renderer("payments").RenderJSON([]byte(`{"ts": 1600000000}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"ts":"2020-09-13T14:26:40+02:00"}`, string(rendered))
+
+	_, err = r.RenderJSON([]byte(`{"status": 1`))
+	assert.Error(t, err)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tt := []struct {
+		rule    Rule
+		isValid bool
+	}{
+		{Rule{FieldNames: []string{"ts"}, Type: TypeTimestamp}, true},
+		{Rule{FieldNames: []string{"ts"}}, false},
+		{Rule{Type: TypeTimestamp}, false},
+		{Rule{JSONPaths: []string{"$"}, Type: TypeTimestamp}, false},
+		{Rule{FieldNames: []string{"ts"}, Type: TypeTimestamp, Unit: "h"}, false},
+		{Rule{FieldNames: []string{"ts"}, Type: TypeTimestamp, Timezone: "Mars/Olympus"}, false},
+		{Rule{FieldNames: []string{"amount"}, Type: TypeDecimal, Scale: -1}, false},
+		{Rule{FieldNames: []string{"status"}, Type: TypeEnum}, false},
+	}
+
+	for i, test := range tt {
+		cfg := Config{Rules: []Rule{test.rule}}
+		err := cfg.Validate()
+		if test.isValid {
+			assert.NoError(t, err, "test case %v", i)
+		} else {
+			assert.Error(t, err, "test case %v", i)
+		}
+	}
+}
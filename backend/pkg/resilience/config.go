@@ -0,0 +1,46 @@
+package resilience
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config for retries and circuit breaking of calls to an external dependency
+type Config struct {
+	// MaxRetries is the number of retries of idempotent calls which failed, e.g. due to a timeout or a 5xx response.
+	// Retries are delayed by RetryBackoff, which doubles with each retry and is jittered by +/- 50%.
+	MaxRetries   int           `yaml:"maxRetries"`
+	RetryBackoff time.Duration `yaml:"retryBackoff"`
+
+	// FailureThreshold is the number of consecutive failed calls after which the circuit opens. While it's open, all
+	// calls fail immediately. After OpenDuration a single trial call is let through, which closes the circuit again
+	// if it succeeds. A FailureThreshold of 0 disables the circuit breaker.
+	FailureThreshold int           `yaml:"failureThreshold"`
+	OpenDuration     time.Duration `yaml:"openDuration"`
+}
+
+// SetDefaults for the resilience config
+func (c *Config) SetDefaults() {
+	c.MaxRetries = 2
+	c.RetryBackoff = 100 * time.Millisecond
+	c.FailureThreshold = 5
+	c.OpenDuration = 30 * time.Second
+}
+
+// Validate the resilience config
+func (c *Config) Validate() error {
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	if c.MaxRetries > 0 && c.RetryBackoff <= 0 {
+		return fmt.Errorf("retry backoff must be greater than 0 if retries are enabled")
+	}
+	if c.FailureThreshold < 0 {
+		return fmt.Errorf("failure threshold must not be negative")
+	}
+	if c.FailureThreshold > 0 && c.OpenDuration <= 0 {
+		return fmt.Errorf("open duration must be greater than 0 if the circuit breaker is enabled")
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the dependency while its circuit is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State of a circuit breaker
+type State string
+
+// States of a circuit breaker
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "halfOpen"
+)
+
+// Status is a snapshot of a dependency's circuit breaker
+type Status struct {
+	Name                string     `json:"name"`
+	State               State      `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+}
+
+// Dependency wraps calls to an external service, e.g. a schema registry, with a per-call timeout, retries and a
+// circuit breaker. All calls of a service should go through the same Dependency, so that a slow or unavailable
+// service fails fast instead of stalling every request which needs it.
+type Dependency struct {
+	name    string
+	timeout time.Duration
+	cfg     Config
+	metrics *Metrics
+
+	// isFailure classifies errors of calls. Errors which are not failures, e.g. a 404 response, prove that the
+	// service is available and are neither retried nor counted by the circuit breaker.
+	isFailure func(error) bool
+
+	mutex               sync.Mutex
+	state               State
+	consecutiveFailures int
+	lastErr             error
+	openedAt            time.Time
+	isTrialRunning      bool
+}
+
+// Call is a single call of a dependency. The context is cancelled once the per-call timeout has elapsed.
+type Call func(ctx context.Context) error
+
+// Do runs the call, failed calls are retried. Only use it for idempotent calls.
+func (d *Dependency) Do(ctx context.Context, call Call) error {
+	backoff := d.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := d.DoOnce(ctx, call)
+		if err == nil || !d.isRetryable(err) || attempt >= d.cfg.MaxRetries {
+			return err
+		}
+
+		// Jitter by +/- 50%, so that concurrent callers don't retry at the same time
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff)+1))
+		backoff *= 2
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// DoOnce runs the call without retries, e.g. for calls which are not idempotent
+func (d *Dependency) DoOnce(ctx context.Context, call Call) error {
+	if err := d.allow(); err != nil {
+		d.metrics.observeCall(d.name, callResultRejected)
+		return err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	err := call(callCtx)
+
+	// Calls which have been cancelled by the caller say nothing about the dependency's health
+	if err != nil && ctx.Err() != nil {
+		d.release()
+		return err
+	}
+
+	failed := err != nil && (callCtx.Err() != nil || d.isFailure(err))
+	d.record(failed, err)
+	if failed {
+		d.metrics.observeCall(d.name, callResultFailure)
+		return fmt.Errorf("%v: %w", d.name, err)
+	}
+	d.metrics.observeCall(d.name, callResultSuccess)
+
+	return err
+}
+
+// Status returns the current state of the circuit breaker
+func (d *Dependency) Status() Status {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	status := Status{
+		Name:                d.name,
+		State:               d.currentState(),
+		ConsecutiveFailures: d.consecutiveFailures,
+	}
+	if d.lastErr != nil {
+		status.LastError = d.lastErr.Error()
+	}
+	if status.State != StateClosed {
+		openedAt := d.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// allow returns ErrCircuitOpen if the call must not be made. Once the open duration has elapsed, a single trial
+// call is allowed.
+func (d *Dependency) allow() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch d.currentState() {
+	case StateOpen:
+		return fmt.Errorf("%v: %w", d.name, ErrCircuitOpen)
+	case StateHalfOpen:
+		if d.isTrialRunning {
+			return fmt.Errorf("%v: %w", d.name, ErrCircuitOpen)
+		}
+		d.isTrialRunning = true
+	}
+	return nil
+}
+
+// release allows another trial call if the call has been cancelled by the caller
+func (d *Dependency) release() {
+	d.mutex.Lock()
+	d.isTrialRunning = false
+	d.mutex.Unlock()
+}
+
+func (d *Dependency) record(failed bool, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.isTrialRunning = false
+	if !failed {
+		d.consecutiveFailures = 0
+		d.setState(StateClosed)
+		return
+	}
+
+	d.consecutiveFailures++
+	d.lastErr = err
+	if d.cfg.FailureThreshold > 0 && (d.state != StateClosed || d.consecutiveFailures >= d.cfg.FailureThreshold) {
+		// A failed trial call opens the circuit for another open duration
+		d.openedAt = time.Now()
+		d.setState(StateOpen)
+	}
+}
+
+// currentState returns the state, open circuits are half open once the open duration has elapsed. The mutex must
+// be held.
+func (d *Dependency) currentState() State {
+	if d.state == StateOpen && time.Since(d.openedAt) >= d.cfg.OpenDuration {
+		d.setState(StateHalfOpen)
+	}
+	return d.state
+}
+
+// setState must be called with the mutex held
+func (d *Dependency) setState(state State) {
+	if d.state == state {
+		return
+	}
+	d.state = state
+	d.metrics.setState(d.name, state)
+}
+
+func (d *Dependency) isRetryable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return errors.Is(err, context.DeadlineExceeded) || d.isFailure(err)
+}
@@ -0,0 +1,142 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNotFound = errors.New("not found")
+
+func newTestDependency(cfg Config) *Dependency {
+	isFailure := func(err error) bool { return !errors.Is(err, errNotFound) }
+	return NewRegistry(nil).NewDependency("registry", 50*time.Millisecond, cfg, isFailure)
+}
+
+func TestDependencyRetries(t *testing.T) {
+	cfg := Config{MaxRetries: 2, RetryBackoff: time.Millisecond}
+	d := newTestDependency(cfg)
+
+	calls := 0
+	err := d.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Errors which are not failures are returned as is without retries
+	calls = 0
+	err = d.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errNotFound
+	})
+	assert.Equal(t, errNotFound, err)
+	assert.Equal(t, 1, calls)
+
+	// Slow calls are cancelled after the timeout and retried
+	calls = 0
+	err = d.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = d.DoOnce(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errors.New("unavailable")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDependencyCircuitBreaker(t *testing.T) {
+	cfg := Config{FailureThreshold: 2, OpenDuration: 20 * time.Millisecond}
+	d := newTestDependency(cfg)
+	fail := func(ctx context.Context) error { return errors.New("unavailable") }
+	succeed := func(ctx context.Context) error { return nil }
+
+	// Errors which are not failures reset the consecutive failures
+	assert.Error(t, d.Do(context.Background(), fail))
+	assert.Equal(t, errNotFound, d.Do(context.Background(), func(ctx context.Context) error { return errNotFound }))
+	assert.Error(t, d.Do(context.Background(), fail))
+	assert.Equal(t, StateClosed, d.Status().State)
+
+	assert.Error(t, d.Do(context.Background(), fail))
+	status := d.Status()
+	assert.Equal(t, StateOpen, status.State)
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+	assert.Equal(t, "unavailable", status.LastError)
+	require.NotNil(t, status.OpenedAt)
+
+	// Calls are rejected without calling the dependency while the circuit is open
+	called := false
+	err := d.Do(context.Background(), func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.False(t, called)
+
+	// A failed trial call opens the circuit again, a successful one closes it
+	time.Sleep(cfg.OpenDuration)
+	assert.Equal(t, StateHalfOpen, d.Status().State)
+	assert.Error(t, d.Do(context.Background(), fail))
+	assert.Equal(t, StateOpen, d.Status().State)
+
+	time.Sleep(cfg.OpenDuration)
+	assert.NoError(t, d.Do(context.Background(), succeed))
+	status = d.Status()
+	assert.Equal(t, StateClosed, status.State)
+	assert.Equal(t, 0, status.ConsecutiveFailures)
+	assert.Nil(t, status.OpenedAt)
+}
+
+func TestDependencyCancelledByCaller(t *testing.T) {
+	d := newTestDependency(Config{FailureThreshold: 1, OpenDuration: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := d.Do(ctx, func(ctx context.Context) error { return ctx.Err() })
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, StateClosed, d.Status().State)
+	assert.Equal(t, 0, d.Status().ConsecutiveFailures)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tt := []struct {
+		cfg   Config
+		isErr bool
+	}{
+		{Config{}, false},
+		{Config{MaxRetries: 1, RetryBackoff: time.Second}, false},
+		{Config{MaxRetries: 1}, true},
+		{Config{MaxRetries: -1}, true},
+		{Config{FailureThreshold: 3, OpenDuration: time.Second}, false},
+		{Config{FailureThreshold: 3}, true},
+		{Config{FailureThreshold: -1}, true},
+	}
+
+	for i, test := range tt {
+		err := test.cfg.Validate()
+		if test.isErr {
+			assert.Error(t, err, "Case: ", i)
+		} else {
+			assert.NoError(t, err, "Case: ", i)
+		}
+	}
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,60 @@
+package resilience
+
+import (
+	"github.com/cloudhut/kowl/backend/pkg/collectors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of dependency calls
+const (
+	callResultSuccess  = "success"
+	callResultFailure  = "failure"
+	callResultRejected = "rejected" // The circuit was open
+)
+
+// Metrics are the prometheus metrics of dependency calls. All methods can be called on a nil *Metrics, which
+// discards the observations.
+type Metrics struct {
+	calls        *prometheus.CounterVec
+	circuitState *prometheus.GaugeVec
+}
+
+// NewMetrics registers the dependency metrics on the default prometheus registry. Metrics which have been registered
+// with the same namespace before are reused.
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		calls: collectors.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dependency",
+			Name:      "calls_total",
+			Help:      "Calls of external dependencies by result (success, failure or rejected by an open circuit)",
+		}, []string{"dependency", "result"})).(*prometheus.CounterVec),
+		circuitState: collectors.Register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dependency",
+			Name:      "circuit_state",
+			Help:      "State of the dependency's circuit breaker (0 closed, 1 half open, 2 open)",
+		}, []string{"dependency"})).(*prometheus.GaugeVec),
+	}
+}
+
+func (m *Metrics) observeCall(dependency string, result string) {
+	if m == nil {
+		return
+	}
+	m.calls.WithLabelValues(dependency, result).Inc()
+}
+
+func (m *Metrics) setState(dependency string, state State) {
+	if m == nil {
+		return
+	}
+	value := 0.0
+	switch state {
+	case StateHalfOpen:
+		value = 1
+	case StateOpen:
+		value = 2
+	}
+	m.circuitState.WithLabelValues(dependency).Set(value)
+}
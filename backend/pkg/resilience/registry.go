@@ -0,0 +1,56 @@
+package resilience
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry creates dependencies and keeps track of them, so that the states of all circuit breakers can be reported
+// at once
+type Registry struct {
+	metrics *Metrics
+
+	mutex        sync.RWMutex
+	dependencies []*Dependency
+}
+
+// NewRegistry creates a registry whose dependencies report their calls and states to the given metrics. Metrics may
+// be nil.
+func NewRegistry(metrics *Metrics) *Registry {
+	return &Registry{metrics: metrics}
+}
+
+// NewDependency registers a dependency with the given name, which is used in errors, metrics and statuses. Each call
+// is cancelled after the timeout. isFailure tells whether an error of a call indicates that the dependency is
+// unavailable, errors caused by a cancelled or timed out call are always failures.
+func (r *Registry) NewDependency(name string, timeout time.Duration, cfg Config, isFailure func(error) bool) *Dependency {
+	d := &Dependency{
+		name:      name,
+		timeout:   timeout,
+		cfg:       cfg,
+		metrics:   r.metrics,
+		isFailure: isFailure,
+		state:     StateClosed,
+	}
+	r.metrics.setState(name, StateClosed)
+
+	r.mutex.Lock()
+	r.dependencies = append(r.dependencies, d)
+	r.mutex.Unlock()
+
+	return d
+}
+
+// Statuses returns the status of all registered dependencies ordered by name
+func (r *Registry) Statuses() []Status {
+	r.mutex.RLock()
+	statuses := make([]Status, len(r.dependencies))
+	for i, d := range r.dependencies {
+		statuses[i] = d.Status()
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
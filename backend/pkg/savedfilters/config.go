@@ -0,0 +1,52 @@
+package savedfilters
+
+import "fmt"
+
+// Storage types
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// Config for the saved filters, which are named filter code snippets that are shared between all users of a topic
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Storage is either 'memory' (filters are lost on restart) or 'file'
+	Storage string `yaml:"storage"`
+
+	// FilePath is the JSON file the filters are persisted in, if the storage is 'file'
+	FilePath string `yaml:"filePath"`
+
+	// MaxFiltersPerTopic limits how many filters can be saved for a single topic
+	MaxFiltersPerTopic int `yaml:"maxFiltersPerTopic"`
+}
+
+// SetDefaults for the saved filters config
+func (c *Config) SetDefaults() {
+	c.Storage = StorageMemory
+	c.MaxFiltersPerTopic = 100
+}
+
+// Validate the saved filters config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Storage {
+	case StorageMemory:
+	case StorageFile:
+		if c.FilePath == "" {
+			return fmt.Errorf("file path must be set if the storage is '%v'", StorageFile)
+		}
+	default:
+		return fmt.Errorf("storage must be either '%v' or '%v'", StorageMemory, StorageFile)
+	}
+
+	if c.MaxFiltersPerTopic <= 0 {
+		return fmt.Errorf("max filters per topic must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package savedfilters
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ErrInvalidFilter is returned if a filter which shall be saved does not pass the validation
+var ErrInvalidFilter = errors.New("invalid saved filter")
+
+// namePattern restricts filter names, so that they can be used in URL paths without escaping
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// Service validates saved filters and stores them in the configured store
+type Service struct {
+	cfg   Config
+	store Store
+}
+
+// NewService creates the store which is configured in the config. The config is expected to be validated.
+func NewService(cfg Config) (*Service, error) {
+	var store Store
+	switch cfg.Storage {
+	case StorageFile:
+		fileStore, err := newFileStore(cfg.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		store = fileStore
+	default:
+		store = newMemoryStore()
+	}
+
+	return NewServiceWithStore(cfg, store), nil
+}
+
+// NewServiceWithStore creates a service which persists the filters in a custom store
+func NewServiceWithStore(cfg Config, store Store) *Service {
+	return &Service{cfg: cfg, store: store}
+}
+
+// ListFilters returns all filters which have been saved for the topic
+func (s *Service) ListFilters(clusterName string, topicName string) ([]*SavedFilter, error) {
+	return s.store.List(clusterName, topicName)
+}
+
+// CreateFilter validates and saves a new filter. CreatedAt is set by the service.
+func (s *Service) CreateFilter(filter SavedFilter) (*SavedFilter, error) {
+	if !namePattern.MatchString(filter.Name) {
+		return nil, fmt.Errorf("%w: name must consist of 1 to 64 alphanumeric characters, '.', '_' or '-'", ErrInvalidFilter)
+	}
+	if filter.Code == "" {
+		return nil, fmt.Errorf("%w: filter code must not be empty", ErrInvalidFilter)
+	}
+
+	existing, err := s.store.List(filter.ClusterName, filter.TopicName)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= s.cfg.MaxFiltersPerTopic {
+		return nil, fmt.Errorf("%w: only %v filters can be saved per topic", ErrInvalidFilter, s.cfg.MaxFiltersPerTopic)
+	}
+
+	filter.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	if err := s.store.Create(&filter); err != nil {
+		return nil, err
+	}
+
+	return &filter, nil
+}
+
+// DeleteFilter deletes the filter with the given name
+func (s *Service) DeleteFilter(clusterName string, topicName string, name string) error {
+	return s.store.Delete(clusterName, topicName, name)
+}
@@ -0,0 +1,65 @@
+package savedfilters
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceCreateListDelete(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.MaxFiltersPerTopic = 2
+	svc, err := NewService(cfg)
+	require.NoError(t, err)
+
+	_, err = svc.CreateFilter(SavedFilter{ClusterName: "default", TopicName: "orders", Name: "paid", Code: "return value.status == 'paid'"})
+	require.NoError(t, err)
+	_, err = svc.CreateFilter(SavedFilter{ClusterName: "default", TopicName: "orders", Name: "big", Code: "return value.total > 100"})
+	require.NoError(t, err)
+
+	_, err = svc.CreateFilter(SavedFilter{ClusterName: "default", TopicName: "orders", Name: "paid", Code: "return true"})
+	assert.True(t, errors.Is(err, ErrInvalidFilter), "limit is checked first")
+	_, err = svc.CreateFilter(SavedFilter{ClusterName: "default", TopicName: "payments", Name: "has space", Code: "return true"})
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+	_, err = svc.CreateFilter(SavedFilter{ClusterName: "default", TopicName: "payments", Name: "empty"})
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+
+	filters, err := svc.ListFilters("default", "orders")
+	require.NoError(t, err)
+	require.Len(t, filters, 2)
+	assert.Equal(t, "big", filters[0].Name)
+	assert.Equal(t, "paid", filters[1].Name)
+	assert.False(t, filters[0].CreatedAt.IsZero())
+
+	require.NoError(t, svc.DeleteFilter("default", "orders", "paid"))
+	assert.True(t, errors.Is(svc.DeleteFilter("default", "orders", "paid"), ErrFilterNotFound))
+	_, err = svc.CreateFilter(SavedFilter{ClusterName: "default", TopicName: "orders", Name: "big", Code: "return true"})
+	assert.True(t, errors.Is(err, ErrFilterAlreadyExists))
+}
+
+func TestFileStorePersistsFilters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "saved-filters")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "filters.json")
+
+	store, err := newFileStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Create(&SavedFilter{ClusterName: "default", TopicName: "orders", Name: "paid", Code: "return true"}))
+	require.NoError(t, store.Create(&SavedFilter{ClusterName: "default", TopicName: "orders", Name: "big", Code: "return false"}))
+	require.NoError(t, store.Delete("default", "orders", "big"))
+
+	reloaded, err := newFileStore(path)
+	require.NoError(t, err)
+	filters, err := reloaded.List("default", "orders")
+	require.NoError(t, err)
+	require.Len(t, filters, 1)
+	assert.Equal(t, "paid", filters[0].Name)
+	assert.Equal(t, "return true", filters[0].Code)
+}
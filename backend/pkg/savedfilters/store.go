@@ -0,0 +1,232 @@
+package savedfilters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrFilterNotFound is returned if there is no saved filter with the given name for the topic
+	ErrFilterNotFound = errors.New("saved filter not found")
+	// ErrFilterAlreadyExists is returned if a filter with the same name has already been saved for the topic
+	ErrFilterAlreadyExists = errors.New("saved filter already exists")
+)
+
+// SavedFilter is a named JavaScript filter which has been saved for a topic
+type SavedFilter struct {
+	ClusterName string    `json:"clusterName"`
+	TopicName   string    `json:"topicName"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Code        string    `json:"code"` // Not base64 encoded
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Store persists saved filters. Implementations must be safe for concurrent use. Custom stores (e. g. backed by a
+// database) can be passed to NewServiceWithStore.
+type Store interface {
+	// List returns all filters of the topic sorted by name
+	List(clusterName string, topicName string) ([]*SavedFilter, error)
+	// Create saves a new filter or returns ErrFilterAlreadyExists
+	Create(filter *SavedFilter) error
+	// Delete removes a filter or returns ErrFilterNotFound
+	Delete(clusterName string, topicName string, name string) error
+}
+
+// topicKey identifies a topic across all clusters
+type topicKey struct {
+	ClusterName string
+	TopicName   string
+}
+
+// memoryStore keeps all filters in memory, they are lost on restart. The key of the inner map is the filter name.
+type memoryStore struct {
+	mutex   sync.RWMutex
+	filters map[topicKey]map[string]*SavedFilter
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{filters: make(map[topicKey]map[string]*SavedFilter)}
+}
+
+func (s *memoryStore) List(clusterName string, topicName string) ([]*SavedFilter, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	key := topicKey{ClusterName: clusterName, TopicName: topicName}
+	res := make([]*SavedFilter, 0, len(s.filters[key]))
+	for _, f := range s.filters[key] {
+		copied := *f
+		res = append(res, &copied)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+func (s *memoryStore) Create(filter *SavedFilter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.create(filter)
+}
+
+// create adds the filter, the mutex must be held
+func (s *memoryStore) create(filter *SavedFilter) error {
+	key := topicKey{ClusterName: filter.ClusterName, TopicName: filter.TopicName}
+	topicFilters, ok := s.filters[key]
+	if !ok {
+		topicFilters = make(map[string]*SavedFilter)
+		s.filters[key] = topicFilters
+	}
+	if _, exists := topicFilters[filter.Name]; exists {
+		return ErrFilterAlreadyExists
+	}
+	copied := *filter
+	topicFilters[filter.Name] = &copied
+
+	return nil
+}
+
+func (s *memoryStore) Delete(clusterName string, topicName string, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err := s.delete(topicKey{ClusterName: clusterName, TopicName: topicName}, name)
+	return err
+}
+
+// delete removes the filter and returns it, the mutex must be held
+func (s *memoryStore) delete(key topicKey, name string) (*SavedFilter, error) {
+	deleted, exists := s.filters[key][name]
+	if !exists {
+		return nil, ErrFilterNotFound
+	}
+	delete(s.filters[key], name)
+	if len(s.filters[key]) == 0 {
+		delete(s.filters, key)
+	}
+
+	return deleted, nil
+}
+
+// all returns the filters of all topics, the mutex must be held
+func (s *memoryStore) all() []*SavedFilter {
+	res := make([]*SavedFilter, 0)
+	for _, topicFilters := range s.filters {
+		for _, f := range topicFilters {
+			res = append(res, f)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].ClusterName != res[j].ClusterName {
+			return res[i].ClusterName < res[j].ClusterName
+		}
+		if res[i].TopicName != res[j].TopicName {
+			return res[i].TopicName < res[j].TopicName
+		}
+		return res[i].Name < res[j].Name
+	})
+
+	return res
+}
+
+// fileStore keeps all filters in memory and rewrites the whole JSON file on every change. That's good enough for
+// the expected number of filters and keeps the file human readable.
+type fileStore struct {
+	*memoryStore
+	path string
+}
+
+// newFileStore loads the filters from the given file. A missing file is created on the first change.
+func newFileStore(path string) (*fileStore, error) {
+	s := &fileStore{memoryStore: newMemoryStore(), path: path}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved filters file: %w", err)
+	}
+
+	var filters []*SavedFilter
+	if err := json.Unmarshal(content, &filters); err != nil {
+		return nil, fmt.Errorf("failed to parse saved filters file: %w", err)
+	}
+	for _, f := range filters {
+		if err := s.memoryStore.create(f); err != nil {
+			return nil, fmt.Errorf("saved filters file contains filter '%v' of topic '%v' in cluster '%v' more than once",
+				f.Name, f.TopicName, f.ClusterName)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *fileStore) Create(filter *SavedFilter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.memoryStore.create(filter); err != nil {
+		return err
+	}
+	if err := s.persist(); err != nil {
+		_, _ = s.memoryStore.delete(topicKey{ClusterName: filter.ClusterName, TopicName: filter.TopicName}, filter.Name)
+		return err
+	}
+
+	return nil
+}
+
+func (s *fileStore) Delete(clusterName string, topicName string, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deleted, err := s.memoryStore.delete(topicKey{ClusterName: clusterName, TopicName: topicName}, name)
+	if err != nil {
+		return err
+	}
+	if err := s.persist(); err != nil {
+		_ = s.memoryStore.create(deleted)
+		return err
+	}
+
+	return nil
+}
+
+// persist writes all filters into a temporary file which then replaces the actual file, so that the file is never
+// left half written. The mutex must be held.
+func (s *fileStore) persist() error {
+	content, err := json.MarshalIndent(s.all(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode saved filters: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary saved filters file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write saved filters file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write saved filters file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace saved filters file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+)
+
+// Client talks to the REST API of a Confluent compatible schema registry. If multiple URLs are configured, requests
+// are tried against each of them in order until one succeeds. All requests go through the dependency, which applies
+// the request timeout, retries and the circuit breaker.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+	dependency *resilience.Dependency
+}
+
+// RestError is the error body returned by the schema registry
+type RestError struct {
+	StatusCode int    `json:"-"`
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *RestError) Error() string {
+	return fmt.Sprintf("schema registry responded with status code %v (error code %v): %v", e.StatusCode, e.ErrorCode, e.Message)
+}
+
+// SchemaVersion is a specific version of a registered subject's schema
+type SchemaVersion struct {
+	Subject    string `json:"subject"`
+	Version    int    `json:"version"`
+	ID         int    `json:"id"`
+	SchemaType string `json:"schemaType"` // Empty for Avro, as older registries do not report the type
+	Schema     string `json:"schema"`
+}
+
+// ConfigResponse is the compatibility config of either a subject or the registry's global default
+type ConfigResponse struct {
+	CompatibilityLevel string `json:"compatibilityLevel"`
+}
+
+// errorCodeSubjectConfigNotFound is returned by the registry if no compatibility level is set for a subject
+const errorCodeSubjectConfigNotFound = 40408
+
+// NewClient creates a new schema registry client, which is registered as dependency with the given name
+func NewClient(cfg Config, dependencies *resilience.Registry, dependencyName string) *Client {
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{},
+		dependency: dependencies.NewDependency(dependencyName, cfg.RequestTimeout, cfg.Resilience, isFailure),
+	}
+}
+
+// isFailure returns false for errors which have been returned by a reachable registry, e.g. for unknown subjects
+func isFailure(err error) bool {
+	var restErr *RestError
+	return !errors.As(err, &restErr) || restErr.StatusCode >= http.StatusInternalServerError
+}
+
+// GetLatestSchema returns the latest schema version of the given subject
+func (c *Client) GetLatestSchema(ctx context.Context, subject string) (*SchemaVersion, error) {
+	return c.GetSchemaBySubject(ctx, subject, "latest")
+}
+
+// GetSchemaBySubject returns the schema of the given subject and version. The version is either a version number
+// or "latest".
+func (c *Client) GetSchemaBySubject(ctx context.Context, subject string, version string) (*SchemaVersion, error) {
+	var res SchemaVersion
+	err := c.get(ctx, fmt.Sprintf("/subjects/%v/versions/%v", url.PathEscape(subject), url.PathEscape(version)), &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version '%v' of subject '%v': %w", version, subject, err)
+	}
+
+	return &res, nil
+}
+
+// GetSchemaByID returns the schema with the given global id. The registry doesn't report a subject or version
+// for schema ids, hence they are empty.
+func (c *Client) GetSchemaByID(ctx context.Context, id int) (*SchemaVersion, error) {
+	var res SchemaVersion
+	err := c.get(ctx, fmt.Sprintf("/schemas/ids/%v", id), &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema with id '%v': %w", id, err)
+	}
+	res.ID = id
+
+	return &res, nil
+}
+
+// GetSubjects returns the names of all registered subjects
+func (c *Client) GetSubjects(ctx context.Context) ([]string, error) {
+	var res []string
+	err := c.get(ctx, "/subjects", &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subjects: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetSubjectVersions returns all registered version numbers of a subject
+func (c *Client) GetSubjectVersions(ctx context.Context, subject string) ([]int, error) {
+	var res []int
+	err := c.get(ctx, fmt.Sprintf("/subjects/%v/versions", url.PathEscape(subject)), &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get versions of subject '%v': %w", subject, err)
+	}
+
+	return res, nil
+}
+
+// GetGlobalConfig returns the registry's default compatibility level
+func (c *Client) GetGlobalConfig(ctx context.Context) (*ConfigResponse, error) {
+	var res ConfigResponse
+	err := c.get(ctx, "/config", &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get global config: %w", err)
+	}
+
+	return &res, nil
+}
+
+// GetSubjectConfig returns the compatibility level of a subject. It returns nil without error if the subject uses
+// the global default.
+func (c *Client) GetSubjectConfig(ctx context.Context, subject string) (*ConfigResponse, error) {
+	var res ConfigResponse
+	err := c.get(ctx, fmt.Sprintf("/config/%v", url.PathEscape(subject)), &res)
+	if err != nil {
+		var restErr *RestError
+		if errors.As(err, &restErr) && restErr.ErrorCode == errorCodeSubjectConfigNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get config of subject '%v': %w", subject, err)
+	}
+
+	return &res, nil
+}
+
+// get sends a GET request to the given path and decodes the JSON response into result
+func (c *Client) get(ctx context.Context, path string, result interface{}) error {
+	return c.dependency.Do(ctx, func(ctx context.Context) error {
+		return c.getFromAny(ctx, path, result)
+	})
+}
+
+// getFromAny tries the request against all URLs until one succeeds
+func (c *Client) getFromAny(ctx context.Context, path string, result interface{}) error {
+	var lastErr error
+	for _, baseURL := range c.cfg.URLs {
+		lastErr = c.getFrom(ctx, strings.TrimSuffix(baseURL, "/")+path, result)
+		if lastErr == nil {
+			return nil
+		}
+		if restErr, ok := lastErr.(*RestError); ok && restErr.StatusCode < http.StatusInternalServerError {
+			// The registry has been reached and rejected the request, other instances won't respond differently
+			return lastErr
+		}
+	}
+
+	return lastErr
+}
+
+func (c *Client) getFrom(ctx context.Context, reqURL string, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		restErr := &RestError{StatusCode: res.StatusCode}
+		_ = json.NewDecoder(res.Body).Decode(restErr)
+		return restErr
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
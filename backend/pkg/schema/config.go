@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+)
+
+// Config for connecting to a Confluent compatible schema registry
+type Config struct {
+	Enabled bool     `yaml:"enabled"`
+	URLs    []string `yaml:"urls"`
+
+	// Basic auth credentials, leave empty if the registry does not require authentication
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	// RequestTimeout bounds each request, including the attempts against all URLs
+	RequestTimeout time.Duration     `yaml:"requestTimeout"`
+	Resilience     resilience.Config `yaml:"resilience"`
+}
+
+// RegisterFlags for sensitive schema registry configs
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&c.Password, "schema.registry.password", "", "Password for authenticating against the schema registry")
+}
+
+// SetDefaults for the schema registry config
+func (c *Config) SetDefaults() {
+	c.RequestTimeout = 10 * time.Second
+	c.Resilience.SetDefaults()
+}
+
+// Validate the schema registry config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.URLs) == 0 {
+		return fmt.Errorf("at least one schema registry url must be configured if the schema registry is enabled")
+	}
+	for _, u := range c.URLs {
+		if _, err := url.ParseRequestURI(u); err != nil {
+			return fmt.Errorf("failed to parse schema registry url '%v': %w", u, err)
+		}
+	}
+	if c.RequestTimeout <= 0 {
+		return fmt.Errorf("schema registry request timeout must be greater than 0")
+	}
+	if err := c.Resilience.Validate(); err != nil {
+		return fmt.Errorf("failed to validate resilience config: %w", err)
+	}
+
+	return nil
+}
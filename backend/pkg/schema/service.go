@@ -0,0 +1,185 @@
+package schema
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"github.com/linkedin/goavro/v2"
+	"go.uber.org/zap"
+)
+
+// Service provides schema registry backed serialization
+type Service struct {
+	client *Client
+	logger *zap.Logger
+
+	codecsMutex sync.RWMutex
+	codecs      map[int]*goavro.Codec // By schema id
+}
+
+// NewService creates a new schema registry service, whose registry is registered as dependency with the given name
+func NewService(cfg Config, dependencies *resilience.Registry, dependencyName string, logger *zap.Logger) *Service {
+	return &Service{
+		client: NewClient(cfg, dependencies, dependencyName),
+		logger: logger,
+		codecs: make(map[int]*goavro.Codec),
+	}
+}
+
+// EncodeAvro serializes the given Avro JSON (unions must be written as {"type": value}) with the latest schema
+// of the given subject. The result is framed with the schema registry's wire format (magic byte and schema id).
+func (s *Service) EncodeAvro(ctx context.Context, subject string, avroJSON []byte) ([]byte, error) {
+	schema, err := s.client.GetLatestSchema(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := s.getCodec(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := codec.NativeFromTextual(avroJSON)
+	if err != nil {
+		return nil, fmt.Errorf("payload does not match the latest schema of subject '%v': %w", subject, err)
+	}
+
+	// Wire format: magic byte 0, 4 bytes big endian schema id, avro binary
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], uint32(schema.ID))
+	encoded, err := codec.BinaryFromNative(header, native)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize payload with the latest schema of subject '%v': %w", subject, err)
+	}
+
+	return encoded, nil
+}
+
+// DecodeAvro deserializes an Avro binary payload into Avro JSON. If schemaID is 0 the payload must be framed with
+// the schema registry's wire format and the schema id is taken from its header, otherwise the payload is expected
+// to be plain Avro binary written with the given schema.
+func (s *Service) DecodeAvro(ctx context.Context, payload []byte, schemaID int) ([]byte, error) {
+	if schemaID == 0 {
+		if len(payload) < 5 || payload[0] != 0x00 {
+			return nil, fmt.Errorf("payload is not framed with the schema registry wire format")
+		}
+		schemaID = int(binary.BigEndian.Uint32(payload[1:5]))
+		payload = payload[5:]
+	}
+
+	codec, err := s.getCodecByID(ctx, schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	native, remaining, err := codec.NativeFromBinary(payload)
+	if err != nil {
+		return nil, fmt.Errorf("payload does not match schema with id '%v': %w", schemaID, err)
+	}
+	if len(remaining) > 0 {
+		return nil, fmt.Errorf("payload has %v trailing bytes after the record of schema with id '%v'", len(remaining), schemaID)
+	}
+	avroJSON, err := codec.TextualFromNative(nil, native)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize payload as avro json: %w", err)
+	}
+
+	return avroJSON, nil
+}
+
+// getCodecByID returns the cached codec of the given schema id, the schema is fetched from the registry otherwise
+func (s *Service) getCodecByID(ctx context.Context, schemaID int) (*goavro.Codec, error) {
+	s.codecsMutex.RLock()
+	codec, exists := s.codecs[schemaID]
+	s.codecsMutex.RUnlock()
+	if exists {
+		return codec, nil
+	}
+
+	schema, err := s.client.GetSchemaByID(ctx, schemaID)
+	if err != nil {
+		return nil, err
+	}
+	if schema.SchemaType != "" && schema.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("schema with id '%v' is a %v schema", schemaID, schema.SchemaType)
+	}
+
+	return s.getCodec(schema)
+}
+
+func (s *Service) getCodec(schema *SchemaVersion) (*goavro.Codec, error) {
+	s.codecsMutex.RLock()
+	codec, exists := s.codecs[schema.ID]
+	s.codecsMutex.RUnlock()
+	if exists {
+		return codec, nil
+	}
+
+	codec, err := goavro.NewCodec(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse avro schema with id '%v': %w", schema.ID, err)
+	}
+
+	s.codecsMutex.Lock()
+	s.codecs[schema.ID] = codec
+	s.codecsMutex.Unlock()
+
+	return codec, nil
+}
+
+// SubjectDetails is a single version of a subject along with all available versions and its compatibility level
+type SubjectDetails struct {
+	Subject            string         `json:"subject"`
+	CompatibilityLevel string         `json:"compatibilityLevel"`
+	IsGlobalDefault    bool           `json:"isGlobalDefault"` // True if the subject has no own compatibility level
+	Versions           []int          `json:"versions"`
+	Schema             *SchemaVersion `json:"schema"`
+}
+
+// GetSubjects returns the names of all registered subjects
+func (s *Service) GetSubjects(ctx context.Context) ([]string, error) {
+	return s.client.GetSubjects(ctx)
+}
+
+// GetGlobalConfig returns the registry's default compatibility level
+func (s *Service) GetGlobalConfig(ctx context.Context) (*ConfigResponse, error) {
+	return s.client.GetGlobalConfig(ctx)
+}
+
+// GetSubjectDetails returns the requested version ("latest" or a version number) of a subject along with its
+// compatibility level. The global compatibility level is reported if the subject has no own level.
+func (s *Service) GetSubjectDetails(ctx context.Context, subject string, version string) (*SubjectDetails, error) {
+	versions, err := s.client.GetSubjectVersions(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := s.client.GetSchemaBySubject(ctx, subject, version)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &SubjectDetails{
+		Subject:  subject,
+		Versions: versions,
+		Schema:   schema,
+	}
+
+	cfg, err := s.client.GetSubjectConfig(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg, err = s.client.GetGlobalConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details.IsGlobalDefault = true
+	}
+	details.CompatibilityLevel = cfg.CompatibilityLevel
+
+	return details, nil
+}
@@ -0,0 +1,68 @@
+package scim
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Storage types
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// Config for the SCIM 2.0 endpoint, which identity providers use to provision users and groups
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// BearerToken must be sent by the identity provider with each request
+	BearerToken string `yaml:"bearerToken"`
+
+	// Storage is either 'memory' (users and groups are lost on restart, the identity provider pushes them again on
+	// its next sync) or 'file'
+	Storage string `yaml:"storage"`
+
+	// FilePath is the JSON file the users and groups are persisted in, if the storage is 'file'
+	FilePath string `yaml:"filePath"`
+
+	// MaxResults is the maximum number of resources returned by a single list request
+	MaxResults int `yaml:"maxResults"`
+}
+
+// RegisterFlags for sensitive SCIM configs
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&c.BearerToken, "scim.bearer-token", "", "Bearer token the identity provider must send to the SCIM endpoint")
+}
+
+// SetDefaults for the SCIM config
+func (c *Config) SetDefaults() {
+	c.Storage = StorageMemory
+	c.MaxResults = 200
+}
+
+// Validate the SCIM config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.BearerToken == "" {
+		return fmt.Errorf("bearer token must be set if scim is enabled")
+	}
+
+	switch c.Storage {
+	case StorageMemory:
+	case StorageFile:
+		if c.FilePath == "" {
+			return fmt.Errorf("file path must be set if the storage is '%v'", StorageFile)
+		}
+	default:
+		return fmt.Errorf("storage must be either '%v' or '%v'", StorageMemory, StorageFile)
+	}
+
+	if c.MaxResults <= 0 {
+		return fmt.Errorf("max results must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,455 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Directory stores the users and groups which have been provisioned by the identity provider. If a file path is
+// configured, the whole directory is rewritten to the file on every change.
+type Directory struct {
+	mutex  sync.RWMutex
+	users  map[string]*User
+	groups map[string]*Group
+	path   string // Empty if the directory is kept in memory only
+}
+
+// directoryFile is the content of the file the directory is persisted in
+type directoryFile struct {
+	Users  []*User  `json:"users"`
+	Groups []*Group `json:"groups"`
+}
+
+// NewDirectory creates the directory which is configured in the config. The config is expected to be validated.
+func NewDirectory(cfg Config) (*Directory, error) {
+	d := &Directory{users: make(map[string]*User), groups: make(map[string]*Group)}
+	if cfg.Storage != StorageFile {
+		return d, nil
+	}
+
+	d.path = cfg.FilePath
+	content, err := ioutil.ReadFile(d.path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scim directory file: %w", err)
+	}
+	var file directoryFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse scim directory file: %w", err)
+	}
+	for _, u := range file.Users {
+		d.users[u.ID] = u
+	}
+	for _, g := range file.Groups {
+		d.groups[g.ID] = g
+	}
+
+	return d, nil
+}
+
+// ListUsers returns the users matching the filter sorted by user name, along with the total number of matches.
+// StartIndex is 1-based like in SCIM.
+func (d *Directory) ListUsers(filter Filter, startIndex int, count int) ([]*User, int, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	matches := make([]*User, 0)
+	for _, u := range d.users {
+		isMatch, err := filter.matchesUser(u)
+		if err != nil {
+			return nil, 0, err
+		}
+		if isMatch {
+			matches = append(matches, d.withGroups(u))
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].UserName < matches[j].UserName })
+
+	from, to := pageBounds(len(matches), startIndex, count)
+	return matches[from:to], len(matches), nil
+}
+
+// GetUser returns the user with the given id
+func (d *Directory) GetUser(id string) (*User, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	u, ok := d.users[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: user '%v'", ErrNotFound, id)
+	}
+	return d.withGroups(u), nil
+}
+
+// CreateUser provisions a new user. The id and metadata are assigned by the directory.
+func (d *Directory) CreateUser(user User) (*User, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now().UTC()
+	user.ID = newID()
+	user.Meta = Meta{ResourceType: "User", Created: now, LastModified: now}
+	if err := d.putUser(&user); err != nil {
+		return nil, err
+	}
+	if err := d.persist(); err != nil {
+		delete(d.users, user.ID)
+		return nil, err
+	}
+
+	return d.withGroups(&user), nil
+}
+
+// ReplaceUser replaces all attributes of an existing user
+func (d *Directory) ReplaceUser(id string, user User) (*User, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, ok := d.users[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: user '%v'", ErrNotFound, id)
+	}
+	return d.updateUser(existing, user)
+}
+
+// PatchUser applies the patch operations on an existing user
+func (d *Directory) PatchUser(id string, ops []PatchOperation) (*User, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, ok := d.users[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: user '%v'", ErrNotFound, id)
+	}
+	var patched User
+	if err := patchResource(existing, userAttributes, ops, &patched); err != nil {
+		return nil, err
+	}
+	return d.updateUser(existing, patched)
+}
+
+// updateUser stores the new version of an existing user, the mutex must be held
+func (d *Directory) updateUser(existing *User, user User) (*User, error) {
+	user.ID = existing.ID
+	user.Meta = existing.Meta
+	user.Meta.LastModified = time.Now().UTC()
+	if err := d.putUser(&user); err != nil {
+		return nil, err
+	}
+	if err := d.persist(); err != nil {
+		d.users[existing.ID] = existing
+		return nil, err
+	}
+
+	return d.withGroups(&user), nil
+}
+
+// DeleteUser deprovisions the user and removes it from all groups
+func (d *Directory) DeleteUser(id string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, ok := d.users[id]
+	if !ok {
+		return fmt.Errorf("%w: user '%v'", ErrNotFound, id)
+	}
+	previousGroups := make(map[string]*Group)
+	for groupID, g := range d.groups {
+		members := make([]Reference, 0, len(g.Members))
+		for _, m := range g.Members {
+			if m.Value != id {
+				members = append(members, m)
+			}
+		}
+		if len(members) != len(g.Members) {
+			previousGroups[groupID] = g
+			updated := *g
+			updated.Members = members
+			d.groups[groupID] = &updated
+		}
+	}
+	delete(d.users, id)
+
+	if err := d.persist(); err != nil {
+		d.users[id] = existing
+		for groupID, g := range previousGroups {
+			d.groups[groupID] = g
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ListGroups returns the groups matching the filter sorted by display name, along with the total number of matches
+func (d *Directory) ListGroups(filter Filter, startIndex int, count int) ([]*Group, int, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	matches := make([]*Group, 0)
+	for _, g := range d.groups {
+		isMatch, err := filter.matchesGroup(g)
+		if err != nil {
+			return nil, 0, err
+		}
+		if isMatch {
+			copied := *g
+			matches = append(matches, &copied)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].DisplayName < matches[j].DisplayName })
+
+	from, to := pageBounds(len(matches), startIndex, count)
+	return matches[from:to], len(matches), nil
+}
+
+// GetGroup returns the group with the given id
+func (d *Directory) GetGroup(id string) (*Group, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	g, ok := d.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: group '%v'", ErrNotFound, id)
+	}
+	copied := *g
+	return &copied, nil
+}
+
+// CreateGroup provisions a new group. Members must be existing users.
+func (d *Directory) CreateGroup(group Group) (*Group, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now().UTC()
+	group.ID = newID()
+	group.Meta = Meta{ResourceType: "Group", Created: now, LastModified: now}
+	if err := d.putGroup(&group); err != nil {
+		return nil, err
+	}
+	if err := d.persist(); err != nil {
+		delete(d.groups, group.ID)
+		return nil, err
+	}
+
+	copied := group
+	return &copied, nil
+}
+
+// ReplaceGroup replaces all attributes of an existing group including its members
+func (d *Directory) ReplaceGroup(id string, group Group) (*Group, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, ok := d.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: group '%v'", ErrNotFound, id)
+	}
+	return d.updateGroup(existing, group)
+}
+
+// PatchGroup applies the patch operations on an existing group, e.g. to add or remove members
+func (d *Directory) PatchGroup(id string, ops []PatchOperation) (*Group, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, ok := d.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: group '%v'", ErrNotFound, id)
+	}
+	var patched Group
+	if err := patchResource(existing, groupAttributes, ops, &patched); err != nil {
+		return nil, err
+	}
+	return d.updateGroup(existing, patched)
+}
+
+// updateGroup stores the new version of an existing group, the mutex must be held
+func (d *Directory) updateGroup(existing *Group, group Group) (*Group, error) {
+	group.ID = existing.ID
+	group.Meta = existing.Meta
+	group.Meta.LastModified = time.Now().UTC()
+	if err := d.putGroup(&group); err != nil {
+		return nil, err
+	}
+	if err := d.persist(); err != nil {
+		d.groups[existing.ID] = existing
+		return nil, err
+	}
+
+	copied := group
+	return &copied, nil
+}
+
+// DeleteGroup deprovisions the group
+func (d *Directory) DeleteGroup(id string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, ok := d.groups[id]
+	if !ok {
+		return fmt.Errorf("%w: group '%v'", ErrNotFound, id)
+	}
+	delete(d.groups, id)
+	if err := d.persist(); err != nil {
+		d.groups[id] = existing
+		return err
+	}
+
+	return nil
+}
+
+// GroupsOfUser returns the display names of all groups the user with the given user name is a member of, sorted by
+// name. Deactivated and unknown users are not a member of any group.
+func (d *Directory) GroupsOfUser(userName string) []string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	res := make([]string, 0)
+	for _, u := range d.users {
+		if !strings.EqualFold(u.UserName, userName) || !u.IsActive() {
+			continue
+		}
+		for _, g := range d.withGroups(u).Groups {
+			res = append(res, g.Display)
+		}
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// putUser validates the user and stores it, the mutex must be held
+func (d *Directory) putUser(user *User) error {
+	if err := user.validate(); err != nil {
+		return err
+	}
+	for _, u := range d.users {
+		if u.ID != user.ID && strings.EqualFold(u.UserName, user.UserName) {
+			return fmt.Errorf("%w: user name '%v' is already taken", ErrUniqueness, user.UserName)
+		}
+	}
+	user.Schemas = []string{SchemaUser}
+	user.Groups = nil // Derived from the group members
+	if user.Active == nil {
+		isActive := true
+		user.Active = &isActive
+	}
+	d.users[user.ID] = user
+
+	return nil
+}
+
+// putGroup validates the group and its members and stores it, the mutex must be held
+func (d *Directory) putGroup(group *Group) error {
+	if err := group.validate(); err != nil {
+		return err
+	}
+	for _, g := range d.groups {
+		if g.ID != group.ID && g.DisplayName == group.DisplayName {
+			return fmt.Errorf("%w: group '%v' already exists", ErrUniqueness, group.DisplayName)
+		}
+	}
+
+	members := make([]Reference, 0, len(group.Members))
+	seen := make(map[string]struct{}, len(group.Members))
+	for _, m := range group.Members {
+		u, ok := d.users[m.Value]
+		if !ok {
+			return fmt.Errorf("%w: member '%v' is not a provisioned user", ErrInvalidValue, m.Value)
+		}
+		if _, isDuplicate := seen[m.Value]; isDuplicate {
+			continue
+		}
+		seen[m.Value] = struct{}{}
+		members = append(members, Reference{Value: u.ID, Display: u.UserName})
+	}
+	group.Schemas = []string{SchemaGroup}
+	group.Members = members
+	d.groups[group.ID] = group
+
+	return nil
+}
+
+// withGroups returns a copy of the user with its group memberships, the mutex must be held
+func (d *Directory) withGroups(u *User) *User {
+	copied := *u
+	copied.Groups = make([]Reference, 0)
+	for _, g := range d.groups {
+		for _, m := range g.Members {
+			if m.Value == u.ID {
+				copied.Groups = append(copied.Groups, Reference{Value: g.ID, Display: g.DisplayName})
+				break
+			}
+		}
+	}
+	sort.Slice(copied.Groups, func(i, j int) bool { return copied.Groups[i].Display < copied.Groups[j].Display })
+
+	return &copied
+}
+
+// pageBounds returns the slice bounds of the requested page. StartIndex is 1-based.
+func pageBounds(length int, startIndex int, count int) (int, int) {
+	from := startIndex - 1
+	if from < 0 {
+		from = 0
+	}
+	if from > length {
+		from = length
+	}
+	to := from + count
+	if to > length {
+		to = length
+	}
+	return from, to
+}
+
+// persist writes the directory into a temporary file which then replaces the actual file, so that the file is never
+// left half written. The mutex must be held.
+func (d *Directory) persist() error {
+	if d.path == "" {
+		return nil
+	}
+
+	file := directoryFile{Users: make([]*User, 0, len(d.users)), Groups: make([]*Group, 0, len(d.groups))}
+	for _, u := range d.users {
+		file.Users = append(file.Users, u)
+	}
+	for _, g := range d.groups {
+		file.Groups = append(file.Groups, g)
+	}
+	sort.Slice(file.Users, func(i, j int) bool { return file.Users[i].UserName < file.Users[j].UserName })
+	sort.Slice(file.Groups, func(i, j int) bool { return file.Groups[i].DisplayName < file.Groups[j].DisplayName })
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode scim directory: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(d.path), filepath.Base(d.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary scim directory file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write scim directory file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write scim directory file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), d.path); err != nil {
+		return fmt.Errorf("failed to replace scim directory file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package scim
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectory_CreateUser(t *testing.T) {
+	d, err := NewDirectory(Config{Storage: StorageMemory})
+	require.NoError(t, err)
+
+	created, err := d.CreateUser(User{UserName: "jane@mycompany.com"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.True(t, created.IsActive())
+
+	_, err = d.CreateUser(User{UserName: "JANE@mycompany.com"})
+	assert.True(t, errors.Is(err, ErrUniqueness))
+
+	_, err = d.CreateUser(User{})
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+
+	users, total, err := d.ListUsers(Filter{Attribute: "username", Value: "Jane@MyCompany.com"}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, created.ID, users[0].ID)
+}
+
+func TestDirectory_PatchUser(t *testing.T) {
+	d, err := NewDirectory(Config{Storage: StorageMemory})
+	require.NoError(t, err)
+	created, err := d.CreateUser(User{UserName: "jane@mycompany.com"})
+	require.NoError(t, err)
+
+	// Azure AD sends booleans as strings
+	patched, err := d.PatchUser(created.ID, []PatchOperation{
+		{Op: "Replace", Path: "active", Value: "False"},
+		{Op: "replace", Path: "name.givenName", Value: "Jane"},
+	})
+	require.NoError(t, err)
+	assert.False(t, patched.IsActive())
+	require.NotNil(t, patched.Name)
+	assert.Equal(t, "Jane", patched.Name.GivenName)
+
+	_, err = d.PatchUser(created.ID, []PatchOperation{{Op: "replace", Path: "id", Value: "other"}})
+	assert.True(t, errors.Is(err, ErrInvalidPath))
+
+	_, err = d.PatchUser("unknown", nil)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestDirectory_GroupMembers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scim")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	d, err := NewDirectory(Config{Storage: StorageFile, FilePath: filepath.Join(dir, "scim.json")})
+	require.NoError(t, err)
+	jane, err := d.CreateUser(User{UserName: "jane@mycompany.com"})
+	require.NoError(t, err)
+	john, err := d.CreateUser(User{UserName: "john@mycompany.com"})
+	require.NoError(t, err)
+
+	_, err = d.CreateGroup(Group{DisplayName: "kafka-admins", Members: []Reference{{Value: "unknown"}}})
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+
+	group, err := d.CreateGroup(Group{DisplayName: "kafka-admins", Members: []Reference{{Value: jane.ID}}})
+	require.NoError(t, err)
+	assert.Equal(t, []Reference{{Value: jane.ID, Display: "jane@mycompany.com"}}, group.Members)
+
+	group, err = d.PatchGroup(group.ID, []PatchOperation{
+		{Op: "add", Path: "members", Value: []interface{}{map[string]interface{}{"value": john.ID}}},
+		{Op: "remove", Path: `members[value eq "` + jane.ID + `"]`},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Reference{{Value: john.ID, Display: "john@mycompany.com"}}, group.Members)
+	assert.Equal(t, []string{"kafka-admins"}, d.GroupsOfUser("John@mycompany.com"))
+	assert.Empty(t, d.GroupsOfUser("jane@mycompany.com"))
+
+	// Reloading the file must restore all users and groups
+	reloaded, err := NewDirectory(Config{Storage: StorageFile, FilePath: d.path})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kafka-admins"}, reloaded.GroupsOfUser("john@mycompany.com"))
+
+	// Deactivated and deleted users lose their memberships
+	_, err = d.PatchUser(john.ID, []PatchOperation{{Op: "replace", Value: map[string]interface{}{"active": false}}})
+	require.NoError(t, err)
+	assert.Empty(t, d.GroupsOfUser("john@mycompany.com"))
+	require.NoError(t, d.DeleteUser(john.ID))
+	group, err = d.GetGroup(group.ID)
+	require.NoError(t, err)
+	assert.Empty(t, group.Members)
+}
+
+func TestParseFilter(t *testing.T) {
+	tt := []struct {
+		filter   string
+		expected Filter
+		isValid  bool
+	}{
+		{"", Filter{}, true},
+		{`userName eq "jane@mycompany.com"`, Filter{Attribute: "username", Value: "jane@mycompany.com"}, true},
+		{`displayName EQ "say \"hi\""`, Filter{Attribute: "displayname", Value: `say "hi"`}, true},
+		{`userName sw "jane"`, Filter{}, false},
+		{`userName eq jane`, Filter{}, false},
+	}
+
+	for _, test := range tt {
+		actual, err := ParseFilter(test.filter)
+		if !test.isValid {
+			assert.True(t, errors.Is(err, ErrInvalidFilter), test.filter)
+			continue
+		}
+		require.NoError(t, err, test.filter)
+		assert.Equal(t, test.expected, actual, test.filter)
+	}
+}
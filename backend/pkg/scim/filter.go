@@ -0,0 +1,79 @@
+package scim
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter is a parsed list filter. Identity providers only use equality filters to look up existing resources,
+// hence that's the only supported operator. The zero value matches all resources.
+type Filter struct {
+	Attribute string // Lower case
+	Value     string
+}
+
+var filterPattern = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9.]*)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// ParseFilter parses filters of the form `attribute eq "value"`
+func ParseFilter(filter string) (Filter, error) {
+	if strings.TrimSpace(filter) == "" {
+		return Filter{}, nil
+	}
+	match := filterPattern.FindStringSubmatch(filter)
+	if match == nil {
+		return Filter{}, fmt.Errorf("%w: only filters of the form 'attribute eq \"value\"' are supported", ErrInvalidFilter)
+	}
+	value, err := strconv.Unquote(match[2])
+	if err != nil {
+		return Filter{}, fmt.Errorf("%w: failed to parse value: %v", ErrInvalidFilter, err)
+	}
+
+	return Filter{Attribute: strings.ToLower(match[1]), Value: value}, nil
+}
+
+// matchesUser reports whether the user matches. User names are compared case insensitive like RFC 7643 demands.
+func (f Filter) matchesUser(u *User) (bool, error) {
+	switch f.Attribute {
+	case "":
+		return true, nil
+	case "id":
+		return u.ID == f.Value, nil
+	case "username":
+		return strings.EqualFold(u.UserName, f.Value), nil
+	case "externalid":
+		return u.ExternalID == f.Value, nil
+	case "displayname":
+		return u.DisplayName == f.Value, nil
+	case "emails.value", "emails":
+		for _, e := range u.Emails {
+			if strings.EqualFold(e.Value, f.Value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: users can't be filtered by '%v'", ErrInvalidFilter, f.Attribute)
+}
+
+func (f Filter) matchesGroup(g *Group) (bool, error) {
+	switch f.Attribute {
+	case "":
+		return true, nil
+	case "id":
+		return g.ID == f.Value, nil
+	case "displayname":
+		return g.DisplayName == f.Value, nil
+	case "externalid":
+		return g.ExternalID == f.Value, nil
+	case "members.value", "members":
+		for _, m := range g.Members {
+			if m.Value == f.Value {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: groups can't be filtered by '%v'", ErrInvalidFilter, f.Attribute)
+}
@@ -0,0 +1,262 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PatchRequest is the body of PATCH requests
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation adds, replaces or removes the value at the given path. Without path the value is an object whose
+// attributes are applied one by one.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// canonicalAttributes maps lower case attribute names to the names used in the JSON documents, attribute names are
+// case insensitive in SCIM
+var canonicalAttributes = map[string]string{
+	"username":    "userName",
+	"displayname": "displayName",
+	"externalid":  "externalId",
+	"name":        "name",
+	"formatted":   "formatted",
+	"familyname":  "familyName",
+	"givenname":   "givenName",
+	"active":      "active",
+	"emails":      "emails",
+	"value":       "value",
+	"type":        "type",
+	"primary":     "primary",
+	"members":     "members",
+	"display":     "display",
+}
+
+// Writable top level attributes of each resource type
+var (
+	userAttributes  = []string{"userName", "displayName", "externalId", "name", "active", "emails"}
+	groupAttributes = []string{"displayName", "externalId", "members"}
+)
+
+// pathPattern matches paths like `emails[type eq "work"].value`
+var pathPattern = regexp.MustCompile(`(?i)^([a-z][a-z0-9]*)(?:\[\s*([a-z][a-z0-9]*)\s+eq\s+("(?:[^"\\]|\\.)*")\s*\])?(?:\.([a-z][a-z0-9]*))?$`)
+
+// attributePath is a parsed patch path. Filter and sub attribute are optional.
+type attributePath struct {
+	attribute       string
+	filterAttribute string
+	filterValue     string
+	subAttribute    string
+}
+
+func (p attributePath) hasFilter() bool {
+	return p.filterAttribute != ""
+}
+
+// patchDocument applies the operations to a resource which has been encoded as JSON object. Only the given top
+// level attributes can be changed. Attributes of schema extensions are ignored, because we don't store them.
+func patchDocument(doc map[string]interface{}, writable []string, ops []PatchOperation) error {
+	for _, op := range ops {
+		if err := applyOperation(doc, writable, strings.ToLower(op.Op), op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+
+	// Some identity providers send booleans as strings
+	if active, ok := doc["active"].(string); ok {
+		isActive, err := strconv.ParseBool(active)
+		if err != nil {
+			return fmt.Errorf("%w: active must be a boolean", ErrInvalidValue)
+		}
+		doc["active"] = isActive
+	}
+
+	return nil
+}
+
+func applyOperation(doc map[string]interface{}, writable []string, op string, path string, value interface{}) error {
+	if op != "add" && op != "replace" && op != "remove" {
+		return fmt.Errorf("%w: unknown patch operation '%v'", ErrInvalidValue, op)
+	}
+
+	if path == "" {
+		if op == "remove" {
+			return fmt.Errorf("%w: remove operations require a path", ErrInvalidPath)
+		}
+		attributes, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: operations without path require an object value", ErrInvalidValue)
+		}
+		for name, v := range attributes {
+			if name == "id" || name == "schemas" || name == "meta" || strings.HasPrefix(name, "urn:") {
+				continue
+			}
+			if err := applyOperation(doc, writable, op, name, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		path = strings.TrimPrefix(path, schema+":")
+	}
+	if strings.HasPrefix(path, "urn:") {
+		return nil // Attribute of a schema extension
+	}
+	p, err := parsePath(path, writable)
+	if err != nil {
+		return err
+	}
+
+	if !p.hasFilter() {
+		if p.subAttribute == "" {
+			setValue(doc, op, p.attribute, value)
+			return nil
+		}
+		obj, ok := doc[p.attribute].(map[string]interface{})
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			obj = make(map[string]interface{})
+			doc[p.attribute] = obj
+		}
+		setValue(obj, op, p.subAttribute, value)
+		return nil
+	}
+
+	return applyFilteredOperation(doc, op, p, value)
+}
+
+// applyFilteredOperation changes the elements of a multi-valued attribute which match the path's filter
+func applyFilteredOperation(doc map[string]interface{}, op string, p attributePath, value interface{}) error {
+	elements, _ := doc[p.attribute].([]interface{})
+	res := make([]interface{}, 0, len(elements))
+	matched := false
+	for _, e := range elements {
+		element, ok := e.(map[string]interface{})
+		if !ok || fmt.Sprint(element[p.filterAttribute]) != p.filterValue {
+			res = append(res, e)
+			continue
+		}
+		matched = true
+		switch {
+		case op == "remove" && p.subAttribute == "":
+			continue
+		case p.subAttribute != "":
+			setValue(element, op, p.subAttribute, value)
+		default:
+			if replacement, ok := value.(map[string]interface{}); ok {
+				element = replacement
+			}
+		}
+		res = append(res, element)
+	}
+
+	if !matched && op != "remove" {
+		if p.subAttribute == "" {
+			return fmt.Errorf("%w: no value matches the filter of path", ErrInvalidPath)
+		}
+		res = append(res, map[string]interface{}{p.filterAttribute: p.filterValue, p.subAttribute: value})
+	}
+	doc[p.attribute] = res
+
+	return nil
+}
+
+// setValue applies a single operation on an attribute. Adding values to a multi-valued attribute appends them.
+func setValue(obj map[string]interface{}, op string, attribute string, value interface{}) {
+	switch op {
+	case "remove":
+		delete(obj, attribute)
+	case "add":
+		existing, isArray := obj[attribute].([]interface{})
+		if added, ok := value.([]interface{}); ok && isArray {
+			obj[attribute] = append(existing, added...)
+			return
+		}
+		obj[attribute] = value
+	default:
+		obj[attribute] = value
+	}
+}
+
+func parsePath(path string, writable []string) (attributePath, error) {
+	match := pathPattern.FindStringSubmatch(path)
+	if match == nil {
+		return attributePath{}, fmt.Errorf("%w: unsupported path '%v'", ErrInvalidPath, path)
+	}
+
+	canonical := func(name string) (string, error) {
+		if name == "" {
+			return "", nil
+		}
+		c, ok := canonicalAttributes[strings.ToLower(name)]
+		if !ok {
+			return "", fmt.Errorf("%w: unknown attribute '%v'", ErrInvalidPath, name)
+		}
+		return c, nil
+	}
+
+	var p attributePath
+	var err error
+	if p.attribute, err = canonical(match[1]); err != nil {
+		return p, err
+	}
+	isWritable := false
+	for _, w := range writable {
+		isWritable = isWritable || w == p.attribute
+	}
+	if !isWritable {
+		return p, fmt.Errorf("%w: attribute '%v' can't be modified", ErrInvalidPath, p.attribute)
+	}
+	if p.filterAttribute, err = canonical(match[2]); err != nil {
+		return p, err
+	}
+	if match[3] != "" {
+		if p.filterValue, err = strconv.Unquote(match[3]); err != nil {
+			return p, fmt.Errorf("%w: failed to parse filter value: %v", ErrInvalidPath, err)
+		}
+	}
+	if p.subAttribute, err = canonical(match[4]); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// patchResource applies the operations on a copy of the resource and decodes the result into patched
+func patchResource(resource interface{}, writable []string, ops []PatchOperation, patched interface{}) error {
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("failed to encode resource: %w", err)
+	}
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return fmt.Errorf("failed to decode resource: %w", err)
+	}
+
+	if err := patchDocument(doc, writable, ops); err != nil {
+		return err
+	}
+
+	encoded, err = json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode patched resource: %w", err)
+	}
+	if err := json.Unmarshal(encoded, patched); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+
+	return nil
+}
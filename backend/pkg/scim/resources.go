@@ -0,0 +1,147 @@
+package scim
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schema URNs defined by RFC 7643 and RFC 7644
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+var (
+	// ErrNotFound is returned if there is no user or group with the given id
+	ErrNotFound = errors.New("resource not found")
+	// ErrUniqueness is returned if a user name or group display name is already taken
+	ErrUniqueness = errors.New("resource already exists")
+	// ErrInvalidValue is returned if a resource is missing required attributes or has invalid values
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrInvalidFilter is returned for filters which are not supported
+	ErrInvalidFilter = errors.New("invalid filter")
+	// ErrInvalidPath is returned for patch operations whose path can't be applied
+	ErrInvalidPath = errors.New("invalid path")
+)
+
+// Meta is the metadata of a resource. The location is set by the HTTP handler, because it depends on the URL the
+// endpoint is served at.
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// Name are the components of a user's real name
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// Email is a single email address of a user
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Reference points to another resource, e.g. a group member or a group the user belongs to
+type Reference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// User is a provisioned user. Groups are read only and derived from the group memberships.
+type User struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Name        *Name       `json:"name,omitempty"`
+	Active      *bool       `json:"active,omitempty"` // Users are active unless provisioned otherwise
+	Emails      []Email     `json:"emails,omitempty"`
+	Groups      []Reference `json:"groups,omitempty"`
+	Meta        Meta        `json:"meta"`
+}
+
+// IsActive returns whether the user has not been deactivated
+func (u *User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// Group is a provisioned group, members are users
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members"`
+	Meta        Meta        `json:"meta"`
+}
+
+// ListResponse is the response of list and search requests
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// ErrorResponse is the body of all error responses
+type ErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// ScimType returns the SCIM error type of an error returned by the directory, it's empty for unexpected errors
+func ScimType(err error) string {
+	switch {
+	case errors.Is(err, ErrUniqueness):
+		return "uniqueness"
+	case errors.Is(err, ErrInvalidValue):
+		return "invalidValue"
+	case errors.Is(err, ErrInvalidFilter):
+		return "invalidFilter"
+	case errors.Is(err, ErrInvalidPath):
+		return "invalidPath"
+	}
+	return ""
+}
+
+func (u *User) validate() error {
+	if strings.TrimSpace(u.UserName) == "" {
+		return fmt.Errorf("%w: userName is required", ErrInvalidValue)
+	}
+	return nil
+}
+
+func (g *Group) validate() error {
+	if strings.TrimSpace(g.DisplayName) == "" {
+		return fmt.Errorf("%w: displayName is required", ErrInvalidValue)
+	}
+	return nil
+}
+
+// newID returns a random (version 4) UUID
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,91 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/filter"
+	"github.com/cloudhut/kowl/backend/pkg/topicpattern"
+	"github.com/dop251/goja/parser"
+)
+
+// Config for admin defined templates
+type Config struct {
+	// Consume templates are pre-configured message searches which can be assigned to roles
+	Consume []ConsumeTemplate `yaml:"consume"`
+
+	// SavedSearches are curated searches with parameters which can be run via the API
+	SavedSearches []SavedSearch `yaml:"savedSearches"`
+}
+
+// ConsumeTemplate is a named, pre-configured message search. Users who start a search from a template are bound to
+// the template's topic pattern and limits, so that less experienced users start from safe queries.
+type ConsumeTemplate struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+
+	// TopicPattern is a regex which must match the whole topic name the template is used for
+	TopicPattern string `yaml:"topicPattern" json:"topicPattern"`
+
+	// DefaultFilter is JavaScript filter code (not base64 encoded) which is used if the user doesn't submit own code
+	DefaultFilter string `yaml:"defaultFilter" json:"defaultFilter"`
+
+	// MaskingProfile is the name of the masking profile which shall be applied to consumed messages
+	MaskingProfile string `yaml:"maskingProfile" json:"maskingProfile,omitempty"`
+
+	Limits ConsumeLimits `yaml:"limits" json:"limits"`
+
+	// Roles the template is assigned to. If empty the template is available to everyone.
+	Roles []string `yaml:"roles" json:"-"`
+
+	topicRegex *regexp.Regexp
+}
+
+// ConsumeLimits restrict searches started from a template. Zero values mean no additional restriction.
+type ConsumeLimits struct {
+	MaxMessageCount        uint16        `yaml:"maxMessageCount" json:"maxMessageCount"`
+	MaxSearchExecutionTime time.Duration `yaml:"maxSearchExecutionTime" json:"maxSearchExecutionTime"`
+}
+
+// Validate the templates config
+func (c *Config) Validate() error {
+	names := make(map[string]struct{}, len(c.Consume))
+	for i, t := range c.Consume {
+		if t.Name == "" {
+			return fmt.Errorf("name of consume template at index '%v' must be set", i)
+		}
+		if _, exists := names[t.Name]; exists {
+			return fmt.Errorf("consume template name '%v' is used more than once", t.Name)
+		}
+		names[t.Name] = struct{}{}
+
+		if _, err := topicpattern.Compile(t.TopicPattern); err != nil {
+			return fmt.Errorf("topic pattern of consume template '%v' is invalid: %w", t.Name, err)
+		}
+
+		if t.DefaultFilter != "" {
+			if _, err := parser.ParseFunction(filter.FunctionParameters, t.DefaultFilter); err != nil {
+				return fmt.Errorf("default filter of consume template '%v' can not be parsed: %w", t.Name, err)
+			}
+		}
+
+		if t.Limits.MaxSearchExecutionTime < 0 {
+			return fmt.Errorf("max search execution time of consume template '%v' must not be negative", t.Name)
+		}
+	}
+
+	ids := make(map[string]struct{}, len(c.SavedSearches))
+	for i := range c.SavedSearches {
+		s := &c.SavedSearches[i]
+		if err := s.validate(); err != nil {
+			return err
+		}
+		if _, exists := ids[s.ID]; exists {
+			return fmt.Errorf("saved search id '%v' is used more than once", s.ID)
+		}
+		ids[s.ID] = struct{}{}
+	}
+
+	return nil
+}
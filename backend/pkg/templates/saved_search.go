@@ -0,0 +1,213 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/query"
+)
+
+// Parameter types of saved searches
+const (
+	ParameterTypeString = "string"
+	ParameterTypeNumber = "number"
+)
+
+// Start positions of saved searches
+const (
+	SearchStartRecent = "recent"
+	SearchStartOldest = "oldest"
+)
+
+const (
+	defaultSavedSearchMaxResults = 50
+	maxSavedSearchMaxResults     = 500
+	defaultSavedSearchTimeout    = 30 * time.Second
+)
+
+// ErrInvalidParameters is returned if the parameters of a saved search run are missing, unknown or invalid
+var ErrInvalidParameters = errors.New("invalid parameters")
+
+// savedSearchIDPattern restricts ids, so that they can be used in URL paths without escaping
+var savedSearchIDPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// SavedSearch is a curated message search which can be run by its id, e.g. by runbooks and bots. The query may
+// contain ${name} placeholders which are bound to the parameters of each run.
+type SavedSearch struct {
+	ID          string `yaml:"id" json:"id"`
+	Description string `yaml:"description" json:"description"`
+	TopicName   string `yaml:"topicName" json:"topicName"`
+
+	// Query is a search query, e.g. value.orderId = ${orderId} AND header("source") = "billing"
+	Query      string            `yaml:"query" json:"query"`
+	Parameters []SearchParameter `yaml:"parameters" json:"parameters"`
+
+	// Start is either 'recent' (default, the newest MaxResults messages of each partition are scanned) or 'oldest'.
+	// If MaxAge is set, only messages of that age are scanned instead.
+	Start  string        `yaml:"start" json:"start"`
+	MaxAge time.Duration `yaml:"maxAge" json:"maxAge"`
+
+	// MaxResults defaults to 50, Timeout to 30s
+	MaxResults int           `yaml:"maxResults" json:"maxResults"`
+	Timeout    time.Duration `yaml:"timeout" json:"timeout"`
+
+	// MaskingProfile is the name of the masking profile which shall be applied to found messages
+	MaskingProfile string `yaml:"maskingProfile" json:"maskingProfile,omitempty"`
+
+	// Roles the saved search is assigned to. If empty the search is available to everyone.
+	Roles []string `yaml:"roles" json:"-"`
+}
+
+// SearchParameter is a variable of a saved search, which is passed as URL query parameter
+type SearchParameter struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+
+	// Type is either 'string' (default) or 'number'
+	Type string `yaml:"type" json:"type"`
+
+	// Pattern is an optional regex which must match the whole value
+	Pattern string `yaml:"pattern" json:"pattern,omitempty"`
+
+	// Default is used if the parameter is omitted. Parameters without default are required.
+	Default string `yaml:"default" json:"default,omitempty"`
+
+	patternRegex *regexp.Regexp
+}
+
+// validate checks the saved search and compiles the patterns of its parameters
+func (s *SavedSearch) validate() error {
+	if !savedSearchIDPattern.MatchString(s.ID) {
+		return fmt.Errorf("id '%v' must consist of 1 to 64 alphanumeric characters, '.', '_' or '-'", s.ID)
+	}
+	if s.TopicName == "" {
+		return fmt.Errorf("topic name of saved search '%v' must be set", s.ID)
+	}
+
+	switch s.Start {
+	case "":
+		s.Start = SearchStartRecent
+	case SearchStartRecent, SearchStartOldest:
+	default:
+		return fmt.Errorf("start of saved search '%v' must be either '%v' or '%v'", s.ID, SearchStartRecent, SearchStartOldest)
+	}
+	if s.MaxAge < 0 {
+		return fmt.Errorf("max age of saved search '%v' must not be negative", s.ID)
+	}
+	if s.MaxResults == 0 {
+		s.MaxResults = defaultSavedSearchMaxResults
+	}
+	if s.MaxResults < 0 || s.MaxResults > maxSavedSearchMaxResults {
+		return fmt.Errorf("max results of saved search '%v' must be between 1 and %v", s.ID, maxSavedSearchMaxResults)
+	}
+	if s.Timeout == 0 {
+		s.Timeout = defaultSavedSearchTimeout
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout of saved search '%v' must not be negative", s.ID)
+	}
+
+	// The query must be valid for any value, sample values reveal syntax errors early
+	names := make(map[string]struct{}, len(s.Parameters))
+	samples := make(map[string]interface{}, len(s.Parameters))
+	for i := range s.Parameters {
+		p := &s.Parameters[i]
+		if p.Name == "" {
+			return fmt.Errorf("name of parameter at index '%v' of saved search '%v' must be set", i, s.ID)
+		}
+		if _, exists := names[p.Name]; exists {
+			return fmt.Errorf("parameter '%v' of saved search '%v' is defined more than once", p.Name, s.ID)
+		}
+		names[p.Name] = struct{}{}
+
+		switch p.Type {
+		case "":
+			p.Type = ParameterTypeString
+		case ParameterTypeString, ParameterTypeNumber:
+		default:
+			return fmt.Errorf("type of parameter '%v' of saved search '%v' must be either '%v' or '%v'", p.Name, s.ID, ParameterTypeString, ParameterTypeNumber)
+		}
+		if p.Pattern != "" {
+			regex, err := regexp.Compile("^(?:" + p.Pattern + ")$")
+			if err != nil {
+				return fmt.Errorf("pattern of parameter '%v' of saved search '%v' is invalid: %w", p.Name, s.ID, err)
+			}
+			p.patternRegex = regex
+		}
+		if p.Default != "" {
+			if _, err := p.parse(p.Default); err != nil {
+				return fmt.Errorf("default of parameter '%v' of saved search '%v' is invalid: %w", p.Name, s.ID, err)
+			}
+		}
+
+		samples[p.Name] = ""
+		if p.Type == ParameterTypeNumber {
+			samples[p.Name] = float64(0)
+		}
+	}
+
+	bound, err := query.Bind(s.Query, samples)
+	if err != nil {
+		return fmt.Errorf("query of saved search '%v' is invalid: %w", s.ID, err)
+	}
+	if _, err := query.Parse(bound); err != nil {
+		return fmt.Errorf("query of saved search '%v' is invalid: %w", s.ID, err)
+	}
+
+	return nil
+}
+
+// BindQuery returns the query with all placeholders replaced by the given values, or by the parameters' defaults.
+// Missing, unknown and invalid values are reported with ErrInvalidParameters.
+func (s *SavedSearch) BindQuery(values url.Values) (string, error) {
+	known := make(map[string]struct{}, len(s.Parameters))
+	bound := make(map[string]interface{}, len(s.Parameters))
+	for i := range s.Parameters {
+		p := &s.Parameters[i]
+		known[p.Name] = struct{}{}
+
+		value := values.Get(p.Name)
+		if _, exists := values[p.Name]; !exists {
+			if p.Default == "" {
+				return "", fmt.Errorf("%w: parameter '%v' is required", ErrInvalidParameters, p.Name)
+			}
+			value = p.Default
+		}
+		parsed, err := p.parse(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: parameter '%v' %v", ErrInvalidParameters, p.Name, err.Error())
+		}
+		bound[p.Name] = parsed
+	}
+	for name := range values {
+		if _, exists := known[name]; !exists {
+			return "", fmt.Errorf("%w: unknown parameter '%v'", ErrInvalidParameters, name)
+		}
+	}
+
+	return query.Bind(s.Query, bound)
+}
+
+// IsAssignedTo returns true if the saved search is assigned to at least one of the given roles
+func (s *SavedSearch) IsAssignedTo(roles []string) bool {
+	return isAssignedTo(s.Roles, roles)
+}
+
+// parse converts the value to the parameter's type and checks the pattern
+func (p *SearchParameter) parse(value string) (interface{}, error) {
+	if p.patternRegex != nil && !p.patternRegex.MatchString(value) {
+		return nil, fmt.Errorf("doesn't match the pattern '%v'", p.Pattern)
+	}
+	if p.Type == ParameterTypeNumber {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return number, nil
+	}
+	return value, nil
+}
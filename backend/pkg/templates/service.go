@@ -0,0 +1,115 @@
+package templates
+
+import (
+	"fmt"
+
+	"github.com/cloudhut/kowl/backend/pkg/topicpattern"
+)
+
+// RolesAll is a wildcard role which grants access to all templates
+const RolesAll = "all"
+
+// Service provides lookups of the configured templates
+type Service struct {
+	consumeTemplates []*ConsumeTemplate
+	byName           map[string]*ConsumeTemplate
+
+	savedSearches []*SavedSearch
+	searchesByID  map[string]*SavedSearch
+}
+
+// NewService compiles the topic patterns of all configured templates. The config is expected to be validated.
+func NewService(cfg Config) (*Service, error) {
+	svc := &Service{
+		consumeTemplates: make([]*ConsumeTemplate, len(cfg.Consume)),
+		byName:           make(map[string]*ConsumeTemplate, len(cfg.Consume)),
+		savedSearches:    make([]*SavedSearch, len(cfg.SavedSearches)),
+		searchesByID:     make(map[string]*SavedSearch, len(cfg.SavedSearches)),
+	}
+	for i := range cfg.Consume {
+		t := cfg.Consume[i]
+		regex, err := topicpattern.Compile(t.TopicPattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile topic pattern of consume template '%v': %w", t.Name, err)
+		}
+		t.topicRegex = regex
+		svc.consumeTemplates[i] = &t
+		svc.byName[t.Name] = &t
+	}
+	for i := range cfg.SavedSearches {
+		s := cfg.SavedSearches[i]
+		s.Parameters = append([]SearchParameter(nil), s.Parameters...)
+		if err := s.validate(); err != nil { // Compiles the parameter patterns
+			return nil, err
+		}
+		svc.savedSearches[i] = &s
+		svc.searchesByID[s.ID] = &s
+	}
+
+	return svc, nil
+}
+
+// ConsumeTemplate returns the consume template with the given name
+func (s *Service) ConsumeTemplate(name string) (*ConsumeTemplate, bool) {
+	t, exists := s.byName[name]
+	return t, exists
+}
+
+// ConsumeTemplatesForRoles returns all consume templates which are assigned to at least one of the given roles
+func (s *Service) ConsumeTemplatesForRoles(roles []string) []*ConsumeTemplate {
+	res := make([]*ConsumeTemplate, 0)
+	for _, t := range s.consumeTemplates {
+		if t.IsAssignedTo(roles) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// SavedSearch returns the saved search with the given id
+func (s *Service) SavedSearch(id string) (*SavedSearch, bool) {
+	search, exists := s.searchesByID[id]
+	return search, exists
+}
+
+// SavedSearchesForRoles returns all saved searches which are assigned to at least one of the given roles
+func (s *Service) SavedSearchesForRoles(roles []string) []*SavedSearch {
+	res := make([]*SavedSearch, 0)
+	for _, search := range s.savedSearches {
+		if search.IsAssignedTo(roles) {
+			res = append(res, search)
+		}
+	}
+	return res
+}
+
+// IsAssignedTo returns true if the template is assigned to at least one of the given roles
+func (t *ConsumeTemplate) IsAssignedTo(roles []string) bool {
+	return isAssignedTo(t.Roles, roles)
+}
+
+// isAssignedTo returns true if no roles are assigned or if at least one of the roles is assigned
+func isAssignedTo(assignedRoles []string, roles []string) bool {
+	if len(assignedRoles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if role == RolesAll {
+			return true
+		}
+		for _, assigned := range assignedRoles {
+			if role == assigned {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchesTopic returns true if the template may be used to search messages in the given topic
+func (t *ConsumeTemplate) MatchesTopic(topicName string) bool {
+	if t.topicRegex == nil {
+		return false
+	}
+	return t.topicRegex.MatchString(topicName)
+}
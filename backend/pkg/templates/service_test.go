@@ -0,0 +1,95 @@
+package templates
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumeTemplate_Access(t *testing.T) {
+	svc, err := NewService(Config{Consume: []ConsumeTemplate{
+		{Name: "orders", TopicPattern: "orders-.*", Roles: []string{"support"}},
+		{Name: "everything"},
+	}})
+	require.NoError(t, err)
+
+	orders, exists := svc.ConsumeTemplate("orders")
+	require.True(t, exists)
+
+	tt := []struct {
+		template *ConsumeTemplate
+		roles    []string
+		topic    string
+		assigned bool
+		matches  bool
+	}{
+		{orders, []string{"support"}, "orders-eu", true, true},
+		{orders, []string{"dev"}, "orders", false, false},
+		{orders, []string{RolesAll}, "customer-orders-eu", true, false},
+		{svc.byName["everything"], nil, "any-topic", true, true},
+	}
+
+	for i, table := range tt {
+		assert.Equal(t, table.assigned, table.template.IsAssignedTo(table.roles), "expected other assignment. Case: ", i)
+		assert.Equal(t, table.matches, table.template.MatchesTopic(table.topic), "expected other topic match. Case: ", i)
+	}
+	assert.Len(t, svc.ConsumeTemplatesForRoles([]string{"dev"}), 1)
+}
+
+func TestSavedSearch_BindQuery(t *testing.T) {
+	svc, err := NewService(Config{SavedSearches: []SavedSearch{{
+		ID:        "failed-orders",
+		TopicName: "orders",
+		Query:     `value.orderId = ${orderId} AND value.status = ${status} AND js("return '${orderId}' != ''")`,
+		Parameters: []SearchParameter{
+			{Name: "orderId", Type: ParameterTypeNumber},
+			{Name: "status", Pattern: "[A-Z]+", Default: "FAILED"},
+		},
+	}}})
+	require.NoError(t, err)
+	search, exists := svc.SavedSearch("failed-orders")
+	require.True(t, exists)
+	assert.Equal(t, defaultSavedSearchMaxResults, search.MaxResults)
+
+	tt := []struct {
+		values   url.Values
+		expected string
+		isValid  bool
+	}{
+		{url.Values{"orderId": {"123"}}, `value.orderId = 123 AND value.status = "FAILED" AND js("return '${orderId}' != ''")`, true},
+		{url.Values{"orderId": {"1.5"}, "status": {"OK"}}, `value.orderId = 1.5 AND value.status = "OK" AND js("return '${orderId}' != ''")`, true},
+		{url.Values{}, "", false},                                            // Missing required parameter
+		{url.Values{"orderId": {"abc"}}, "", false},                          // Not a number
+		{url.Values{"orderId": {"1"}, "status": {`" OR "" = "`}}, "", false}, // Doesn't match the pattern
+		{url.Values{"orderId": {"1"}, "limit": {"5"}}, "", false},            // Unknown parameter
+	}
+
+	for i, test := range tt {
+		bound, err := search.BindQuery(test.values)
+		if !test.isValid {
+			assert.True(t, errors.Is(err, ErrInvalidParameters), "Case: ", i)
+			continue
+		}
+		require.NoError(t, err, "Case: ", i)
+		assert.Equal(t, test.expected, bound, "Case: ", i)
+	}
+}
+
+func TestConfig_ValidateSavedSearches(t *testing.T) {
+	valid := SavedSearch{ID: "orders", TopicName: "orders", Query: "offset > ${offset}", Parameters: []SearchParameter{{Name: "offset", Type: ParameterTypeNumber}}}
+	withStringOffset := valid
+	withStringOffset.Parameters = []SearchParameter{{Name: "offset"}}
+	withUnknownPlaceholder := valid
+	withUnknownPlaceholder.Query = "offset > ${from}"
+	withInvalidID := valid
+	withInvalidID.ID = "failed orders"
+
+	assert.NoError(t, (&Config{SavedSearches: []SavedSearch{valid}}).Validate())
+	assert.Error(t, (&Config{SavedSearches: []SavedSearch{valid, valid}}).Validate())
+	assert.Error(t, (&Config{SavedSearches: []SavedSearch{withStringOffset}}).Validate())
+	assert.Error(t, (&Config{SavedSearches: []SavedSearch{withUnknownPlaceholder}}).Validate())
+	assert.Error(t, (&Config{SavedSearches: []SavedSearch{withInvalidID}}).Validate())
+}
@@ -0,0 +1,373 @@
+// Package testharness starts a throwaway Kafka cluster (and optionally a Schema Registry and a Kafka Connect
+// cluster) in docker containers, so that the consume, produce and admin subsystems can be tested end-to-end against
+// a real broker. The containers are managed via the docker CLI, hence docker must be installed and running.
+//
+// The integration tests which use the harness are guarded by the 'integration' build tag:
+//
+//	go test -tags integration ./pkg/testharness/...
+package testharness
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/connect"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+	"go.uber.org/zap"
+)
+
+// ErrDockerUnavailable is returned if the docker CLI is not installed or the docker daemon is not reachable
+var ErrDockerUnavailable = errors.New("docker is not available")
+
+// Options configure which containers are started by the harness
+type Options struct {
+	KafkaImage          string
+	SchemaRegistryImage string
+	ConnectImage        string
+
+	// SchemaRegistry and Connect start the respective containers in addition to the Kafka broker
+	SchemaRegistry bool
+	Connect        bool
+
+	// StartupTimeout is the maximum duration to wait for each container to become ready
+	StartupTimeout time.Duration
+}
+
+// SetDefaults for the harness options
+func (o *Options) SetDefaults() {
+	o.KafkaImage = "apache/kafka:3.7.0"
+	o.SchemaRegistryImage = "confluentinc/cp-schema-registry:7.6.0"
+	o.ConnectImage = "confluentinc/cp-kafka-connect:7.6.0"
+	o.StartupTimeout = 3 * time.Minute
+}
+
+// Harness is a running set of containers which share a docker network. Close must be called to remove them.
+type Harness struct {
+	// Brokers are the bootstrap addresses of the Kafka broker, reachable from the host
+	Brokers []string
+	// SchemaRegistryURL and ConnectURL are empty unless the respective container has been started
+	SchemaRegistryURL string
+	ConnectURL        string
+
+	opts       Options
+	logger     *zap.Logger
+	id         string
+	network    string
+	containers []string
+}
+
+// Start creates the docker network and starts all containers. It blocks until all of them are ready to serve
+// requests. If any step fails, everything that has been started so far is removed again.
+func Start(ctx context.Context, opts Options, logger *zap.Logger) (*Harness, error) {
+	if _, err := docker(ctx, "version", "--format", "{{.Server.Version}}"); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	h := &Harness{opts: opts, logger: logger, id: id, network: "kowl-it-" + id}
+
+	if err := h.start(ctx); err != nil {
+		if closeErr := h.Close(); closeErr != nil {
+			logger.Warn("failed to clean up test harness", zap.Error(closeErr))
+		}
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *Harness) start(ctx context.Context) error {
+	if _, err := docker(ctx, "network", "create", h.network); err != nil {
+		return fmt.Errorf("failed to create docker network: %w", err)
+	}
+
+	if err := h.startKafka(ctx); err != nil {
+		return err
+	}
+	if h.opts.SchemaRegistry {
+		if err := h.startSchemaRegistry(ctx); err != nil {
+			return err
+		}
+	}
+	if h.opts.Connect {
+		if err := h.startConnect(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// KafkaConfig returns a Kafka config which connects to the harness' broker
+func (h *Harness) KafkaConfig() kafka.Config {
+	cfg := kafka.Config{}
+	cfg.SetDefaults()
+	cfg.Brokers = h.Brokers
+	cfg.ClusterVersion = "2.4.0"
+	return cfg
+}
+
+// SchemaRegistryConfig returns a schema registry config, which is disabled unless the schema registry is started
+func (h *Harness) SchemaRegistryConfig() schema.Config {
+	cfg := schema.Config{}
+	cfg.SetDefaults()
+	if h.SchemaRegistryURL != "" {
+		cfg.Enabled = true
+		cfg.URLs = []string{h.SchemaRegistryURL}
+	}
+	return cfg
+}
+
+// ConnectConfig returns a Kafka connect config, which is disabled unless the connect cluster is started
+func (h *Harness) ConnectConfig() connect.Config {
+	cfg := connect.Config{}
+	cfg.SetDefaults()
+	if h.ConnectURL != "" {
+		cfg.Enabled = true
+		cfg.Clusters = []connect.ConfigCluster{{Name: "harness", URL: h.ConnectURL}}
+	}
+	return cfg
+}
+
+// kafkaHost is the hostname of the broker within the docker network. Containers connect to the INTERNAL listener,
+// whereas the host connects to the PLAINTEXT listener on the published port.
+func (h *Harness) kafkaHost() string {
+	return "kafka-" + h.id
+}
+
+func (h *Harness) startKafka(ctx context.Context) error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	host := h.kafkaHost()
+	env := map[string]string{
+		"KAFKA_NODE_ID":                                  "1",
+		"KAFKA_PROCESS_ROLES":                            "broker,controller",
+		"KAFKA_LISTENERS":                                "PLAINTEXT://:9092,INTERNAL://:29092,CONTROLLER://:9093",
+		"KAFKA_ADVERTISED_LISTENERS":                     fmt.Sprintf("PLAINTEXT://localhost:%d,INTERNAL://%v:29092", port, host),
+		"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP":           "CONTROLLER:PLAINTEXT,PLAINTEXT:PLAINTEXT,INTERNAL:PLAINTEXT",
+		"KAFKA_CONTROLLER_LISTENER_NAMES":                "CONTROLLER",
+		"KAFKA_INTER_BROKER_LISTENER_NAME":               "INTERNAL",
+		"KAFKA_CONTROLLER_QUORUM_VOTERS":                 fmt.Sprintf("1@%v:9093", host),
+		"KAFKA_OFFSETS_TOPIC_REPLICATION_FACTOR":         "1",
+		"KAFKA_TRANSACTION_STATE_LOG_REPLICATION_FACTOR": "1",
+		"KAFKA_TRANSACTION_STATE_LOG_MIN_ISR":            "1",
+		"KAFKA_GROUP_INITIAL_REBALANCE_DELAY_MS":         "0",
+	}
+	if err := h.run(ctx, host, h.opts.KafkaImage, port, 9092, env); err != nil {
+		return fmt.Errorf("failed to start kafka: %w", err)
+	}
+	h.Brokers = []string{fmt.Sprintf("localhost:%d", port)}
+
+	h.logger.Info("waiting for kafka to become ready", zap.Strings("brokers", h.Brokers))
+	return h.waitUntil(ctx, "kafka", func() error {
+		cfg := sarama.NewConfig()
+		cfg.Version = sarama.V1_0_0_0
+		client, err := sarama.NewClient(h.Brokers, cfg)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		// Group coordination requires the offsets topic, which is created on the first lookup
+		_, err = client.Coordinator("kowl-test-harness")
+		return err
+	})
+}
+
+func (h *Harness) startSchemaRegistry(ctx context.Context) error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	name := "schema-registry-" + h.id
+	env := map[string]string{
+		"SCHEMA_REGISTRY_HOST_NAME":                    name,
+		"SCHEMA_REGISTRY_LISTENERS":                    "http://0.0.0.0:8081",
+		"SCHEMA_REGISTRY_KAFKASTORE_BOOTSTRAP_SERVERS": fmt.Sprintf("PLAINTEXT://%v:29092", h.kafkaHost()),
+	}
+	if err := h.run(ctx, name, h.opts.SchemaRegistryImage, port, 8081, env); err != nil {
+		return fmt.Errorf("failed to start schema registry: %w", err)
+	}
+	h.SchemaRegistryURL = fmt.Sprintf("http://localhost:%d", port)
+
+	h.logger.Info("waiting for schema registry to become ready", zap.String("url", h.SchemaRegistryURL))
+	return h.waitUntil(ctx, "schema registry", httpReady(h.SchemaRegistryURL+"/subjects"))
+}
+
+func (h *Harness) startConnect(ctx context.Context) error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	name := "connect-" + h.id
+	env := map[string]string{
+		"CONNECT_BOOTSTRAP_SERVERS":                 fmt.Sprintf("%v:29092", h.kafkaHost()),
+		"CONNECT_REST_PORT":                         "8083",
+		"CONNECT_REST_ADVERTISED_HOST_NAME":         name,
+		"CONNECT_GROUP_ID":                          "kowl-test-harness",
+		"CONNECT_CONFIG_STORAGE_TOPIC":              "_connect-configs",
+		"CONNECT_OFFSET_STORAGE_TOPIC":              "_connect-offsets",
+		"CONNECT_STATUS_STORAGE_TOPIC":              "_connect-status",
+		"CONNECT_CONFIG_STORAGE_REPLICATION_FACTOR": "1",
+		"CONNECT_OFFSET_STORAGE_REPLICATION_FACTOR": "1",
+		"CONNECT_STATUS_STORAGE_REPLICATION_FACTOR": "1",
+		"CONNECT_KEY_CONVERTER":                     "org.apache.kafka.connect.storage.StringConverter",
+		"CONNECT_VALUE_CONVERTER":                   "org.apache.kafka.connect.storage.StringConverter",
+		"CONNECT_PLUGIN_PATH":                       "/usr/share/java",
+	}
+	if err := h.run(ctx, name, h.opts.ConnectImage, port, 8083, env); err != nil {
+		return fmt.Errorf("failed to start kafka connect: %w", err)
+	}
+	h.ConnectURL = fmt.Sprintf("http://localhost:%d", port)
+
+	h.logger.Info("waiting for kafka connect to become ready", zap.String("url", h.ConnectURL))
+	return h.waitUntil(ctx, "kafka connect", httpReady(h.ConnectURL+"/connectors"))
+}
+
+// run starts a detached container in the harness' network and publishes the container port on the given host port
+func (h *Harness) run(ctx context.Context, name string, image string, hostPort int, containerPort int, env map[string]string) error {
+	args := []string{
+		"run", "--detach", "--rm",
+		"--name", name,
+		"--hostname", name,
+		"--network", h.network,
+		"--label", "kowl-test-harness=" + h.id,
+		"--publish", fmt.Sprintf("%d:%d", hostPort, containerPort),
+	}
+	for key, value := range env {
+		args = append(args, "--env", key+"="+value)
+	}
+	args = append(args, image)
+
+	if _, err := docker(ctx, args...); err != nil {
+		return err
+	}
+	h.containers = append(h.containers, name)
+
+	return nil
+}
+
+// waitUntil calls ready until it succeeds, the startup timeout has elapsed or the context is cancelled. The
+// container logs are attached to the error so that failed startups can be debugged.
+func (h *Harness) waitUntil(ctx context.Context, what string, ready func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.StartupTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		err := ready()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v did not become ready within %v: %w\n%v", what, h.opts.StartupTimeout, err, h.logs())
+		case <-ticker.C:
+		}
+	}
+}
+
+// logs returns the recent output of the most recently started container
+func (h *Harness) logs() string {
+	if len(h.containers) == 0 {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := docker(ctx, "logs", "--tail", "50", h.containers[len(h.containers)-1])
+	if err != nil {
+		return err.Error()
+	}
+	return out
+}
+
+// Close removes all containers and the network which have been created by the harness
+func (h *Harness) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var errs []string
+	for i := len(h.containers) - 1; i >= 0; i-- {
+		if _, err := docker(ctx, "rm", "--force", "--volumes", h.containers[i]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	h.containers = nil
+
+	if _, err := docker(ctx, "network", "rm", h.network); err != nil && !strings.Contains(err.Error(), "not found") {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to remove test harness: %v", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %v: %w: %v", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String() + stderr.String()), nil
+}
+
+func httpReady(url string) func() error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func() error {
+		res, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code %v", res.StatusCode)
+		}
+		return nil
+	}
+}
+
+// freePort asks the kernel for a free port. The port could be taken by someone else before the container binds
+// it, which is unlikely enough for tests.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate harness id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
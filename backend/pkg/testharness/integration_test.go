@@ -0,0 +1,267 @@
+//go:build integration
+// +build integration
+
+package testharness
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/connect"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/cloudhut/kowl/backend/pkg/owl"
+	"github.com/cloudhut/kowl/backend/pkg/resilience"
+	"github.com/cloudhut/kowl/backend/pkg/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// Shared by all tests, the containers are started once in TestMain
+var (
+	harness    *Harness
+	kafkaSvc   *kafka.Service
+	schemaSvc  *schema.Service
+	connectSvc *connect.Service
+	owlSvc     *owl.Service
+)
+
+// TestMain starts the harness. Schema Registry and Kafka Connect are only started if KOWL_IT_SCHEMA_REGISTRY or
+// KOWL_IT_CONNECT are set to 'true', because their images are large and slow to start.
+func TestMain(m *testing.M) {
+	logger := zap.NewNop()
+	if os.Getenv("KOWL_IT_VERBOSE") == "true" {
+		logger, _ = zap.NewDevelopment()
+	}
+
+	opts := Options{}
+	opts.SetDefaults()
+	opts.SchemaRegistry = os.Getenv("KOWL_IT_SCHEMA_REGISTRY") == "true"
+	opts.Connect = os.Getenv("KOWL_IT_CONNECT") == "true"
+
+	var err error
+	harness, err = Start(context.Background(), opts, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start test harness: %v\n", err)
+		os.Exit(1)
+	}
+
+	kafkaCfg := harness.KafkaConfig()
+	kafkaSvc, err = kafka.NewService(&kafkaCfg, "kowl", logger)
+	if err != nil {
+		harness.Close()
+		fmt.Fprintf(os.Stderr, "failed to create kafka service: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.SchemaRegistry {
+		schemaSvc = schema.NewService(harness.SchemaRegistryConfig(), resilience.NewRegistry(nil), "schema_registry", logger)
+	}
+	if opts.Connect {
+		connectSvc = connect.NewService(harness.ConnectConfig(), resilience.NewRegistry(nil), logger)
+	}
+	owlSvc = owl.NewService(kafkaSvc, nil, schemaSvc, nil, owl.ConsumeLimits{}, logger)
+
+	code := m.Run()
+
+	kafkaSvc.Client.Close()
+	if err := harness.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	os.Exit(code)
+}
+
+// collectingProgress implements kafka.IListMessagesProgress and keeps all messages in memory
+type collectingProgress struct {
+	mutex    sync.Mutex
+	messages []*kafka.TopicMessage
+	errors   []string
+}
+
+func (p *collectingProgress) OnPhase(_ string)                                             {}
+func (p *collectingProgress) OnConsumeRequests(_ map[int32]*kafka.PartitionConsumeRequest) {}
+func (p *collectingProgress) OnMessageConsumed(_ int32, _ int64, _ int64)                  {}
+func (p *collectingProgress) OnMessageMatched(_ int32)                                     {}
+func (p *collectingProgress) OnMessagesDropped(_ int64)                                    {}
+func (p *collectingProgress) OnWaterMarks(_ map[int32]*kafka.WaterMark)                    {}
+func (p *collectingProgress) OnInconsistency(_ kafka.PartitionInconsistency)               {}
+func (p *collectingProgress) OnComplete(_ int64, _ bool)                                   {}
+
+func (p *collectingProgress) OnMessage(msg *kafka.TopicMessage) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.messages = append(p.messages, msg)
+}
+
+func (p *collectingProgress) OnError(msg string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.errors = append(p.errors, msg)
+}
+
+func createTestTopic(t *testing.T, partitionCount int32) string {
+	topicName := fmt.Sprintf("%v-%v", t.Name(), time.Now().UnixNano())
+	_, err := owlSvc.CreateTopic(context.Background(), owl.CreateTopicRequest{
+		TopicName:         topicName,
+		PartitionCount:    partitionCount,
+		ReplicationFactor: 1,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = owlSvc.DeleteTopic(context.Background(), topicName)
+	})
+
+	// Metadata of new topics is propagated asynchronously
+	require.Eventually(t, func() bool {
+		partitions, err := kafkaSvc.ListPartitions(topicName)
+		return err == nil && len(partitions) == int(partitionCount)
+	}, 30*time.Second, 500*time.Millisecond)
+
+	return topicName
+}
+
+func TestTopicAdministration(t *testing.T) {
+	ctx := context.Background()
+	topicName := createTestTopic(t, 3)
+
+	_, err := owlSvc.CreateTopic(ctx, owl.CreateTopicRequest{TopicName: topicName, PartitionCount: 1, ReplicationFactor: 1})
+	assert.True(t, errors.Is(err, owl.ErrTopicAlreadyExists))
+
+	overviews, err := owlSvc.GetTopicsOverview()
+	require.NoError(t, err)
+	var overview *owl.TopicOverview
+	for _, o := range overviews {
+		if o.TopicName == topicName {
+			overview = o
+		}
+	}
+	require.NotNil(t, overview)
+	assert.Equal(t, 3, overview.PartitionCount)
+	assert.Equal(t, 1, overview.ReplicationFactor)
+
+	retention := "3600000"
+	_, err = owlSvc.AlterTopicConfig(ctx, owl.AlterTopicConfigRequest{
+		TopicName: topicName,
+		Configs:   map[string]*string{"retention.ms": &retention},
+	})
+	require.NoError(t, err)
+	configs, err := owlSvc.GetTopicConfigs(topicName, []string{"retention.ms"})
+	require.NoError(t, err)
+	entry := configs.GetConfigEntryByName("retention.ms")
+	require.NotNil(t, entry)
+	assert.Equal(t, retention, entry.Value)
+	assert.False(t, entry.IsDefault)
+
+	require.NoError(t, owlSvc.DeleteTopic(ctx, topicName))
+	assert.True(t, errors.Is(owlSvc.DeleteTopic(ctx, topicName), owl.ErrTopicNotFound))
+}
+
+func TestProduceAndConsume(t *testing.T) {
+	ctx := context.Background()
+	topicName := createTestTopic(t, 2)
+
+	const messageCount = 20
+	for i := 0; i < messageCount; i++ {
+		_, err := owlSvc.ProduceMessage(ctx, owl.ProduceRequest{
+			TopicName:   topicName,
+			PartitionID: int32(i % 2),
+			Partitioner: kafka.PartitionerManual,
+			Key:         &owl.ProducePayload{Encoding: owl.PayloadEncodingText, Data: fmt.Sprintf("key-%v", i)},
+			Value:       &owl.ProducePayload{Encoding: owl.PayloadEncodingJSON, Data: fmt.Sprintf(`{"n": %v}`, i)},
+			Headers:     []owl.ProduceHeader{{Key: "source", Value: owl.ProducePayload{Encoding: owl.PayloadEncodingText, Data: "harness"}}},
+		})
+		require.NoError(t, err)
+	}
+
+	waterMarks, err := kafkaSvc.WaterMarks(topicName, []int32{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(messageCount/2), waterMarks[0].High)
+	assert.Equal(t, int64(messageCount/2), waterMarks[1].High)
+
+	progress := &collectingProgress{}
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	err = owlSvc.ListMessages(listCtx, owl.ListMessageRequest{
+		TopicName:    topicName,
+		PartitionID:  -1,
+		StartOffset:  owl.StartOffsetOldest,
+		MessageCount: messageCount,
+	}, progress)
+	require.NoError(t, err)
+	assert.Empty(t, progress.errors)
+	require.Len(t, progress.messages, messageCount)
+
+	for _, msg := range progress.messages {
+		assert.Equal(t, "json", msg.ValueType)
+		assert.True(t, bytes.HasPrefix(msg.Value.Value, []byte(`{"n":`)), string(msg.Value.Value))
+		require.Len(t, msg.Headers, 1)
+		assert.Equal(t, "source", msg.Headers[0].Key)
+	}
+}
+
+func TestConsumerGroupLag(t *testing.T) {
+	ctx := context.Background()
+	topicName := createTestTopic(t, 1)
+	groupID := topicName + "-group"
+
+	for i := 0; i < 10; i++ {
+		_, err := kafkaSvc.Produce(kafka.ProduceRecord{TopicName: topicName, Partitioner: kafka.PartitionerManual, Value: []byte("v")}, kafka.ProduceOptions{})
+		require.NoError(t, err)
+	}
+	require.NoError(t, kafkaSvc.CommitConsumerGroupOffsets(groupID, map[string]map[int32]int64{topicName: {0: 4}}))
+
+	groups, err := owlSvc.GetConsumerGroupsOverview(ctx)
+	require.NoError(t, err)
+	var group *owl.ConsumerGroupOverview
+	for _, g := range groups {
+		if g.GroupID == groupID {
+			group = g
+		}
+	}
+	require.NotNil(t, group)
+	assert.Equal(t, "Empty", group.State)
+	require.NotNil(t, group.Lags)
+	topicLag := group.Lags.GetTopicLag(topicName)
+	require.NotNil(t, topicLag)
+	assert.Equal(t, int64(6), topicLag.SummedLag)
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	if schemaSvc == nil {
+		t.Skip("schema registry has not been started, set KOWL_IT_SCHEMA_REGISTRY=true")
+	}
+	ctx := context.Background()
+
+	subject := t.Name() + "-value"
+	body := `{"schema": "{\"type\": \"record\", \"name\": \"Test\", \"fields\": [{\"name\": \"n\", \"type\": \"int\"}]}"}`
+	res, err := http.Post(harness.SchemaRegistryURL+"/subjects/"+subject+"/versions", "application/vnd.schemaregistry.v1+json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	subjects, err := schemaSvc.GetSubjects(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, subjects, subject)
+
+	encoded, err := schemaSvc.EncodeAvro(ctx, subject, []byte(`{"n": 42}`))
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), encoded[0], "avro payloads start with the magic byte")
+}
+
+func TestConnect(t *testing.T) {
+	if connectSvc == nil {
+		t.Skip("kafka connect has not been started, set KOWL_IT_CONNECT=true")
+	}
+
+	client, err := connectSvc.Client("harness")
+	require.NoError(t, err)
+	connectors, err := client.ListConnectors(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, connectors)
+}
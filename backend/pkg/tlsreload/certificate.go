@@ -0,0 +1,134 @@
+package tlsreload
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Certificate is a certificate and key pair which is loaded from files and reloaded once the files have changed,
+// e.g. when cert-manager has rotated them. The files are checked during TLS handshakes, at most once per interval,
+// so that no goroutine is needed. If the changed files can't be loaded (e.g. because only one of them has been
+// written yet), the previous certificate is used until the next check.
+type Certificate struct {
+	certPath   string
+	keyPath    string
+	passphrase string
+	interval   time.Duration
+	logger     *zap.Logger
+
+	mutex       sync.Mutex
+	cert        *tls.Certificate
+	certPEM     []byte
+	keyPEM      []byte
+	lastChecked time.Time
+}
+
+// NewCertificate loads the certificate and key. The key may be encrypted with the passphrase. An interval of 0
+// disables reloading.
+func NewCertificate(certPath string, keyPath string, passphrase string, interval time.Duration, logger *zap.Logger) (*Certificate, error) {
+	c := &Certificate{
+		certPath:   certPath,
+		keyPath:    keyPath,
+		passphrase: passphrase,
+		interval:   interval,
+		logger:     logger.With(zap.String("cert_filepath", certPath)),
+	}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	c.lastChecked = time.Now()
+
+	return c, nil
+}
+
+// GetCertificate can be set as tls.Config.GetCertificate of servers
+func (c *Certificate) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.current(), nil
+}
+
+// GetClientCertificate can be set as tls.Config.GetClientCertificate of clients
+func (c *Certificate) GetClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return c.current(), nil
+}
+
+// current returns the certificate after reloading it if the interval has elapsed and the files have changed
+func (c *Certificate) current() *tls.Certificate {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.interval > 0 && time.Since(c.lastChecked) >= c.interval {
+		c.lastChecked = time.Now()
+		isReloaded, err := c.reload()
+		switch {
+		case err != nil:
+			c.logger.Warn("failed to reload changed certificate, the previous certificate is still used", zap.Error(err))
+		case isReloaded:
+			c.logger.Info("reloaded changed certificate", zap.Time("not_after", c.cert.Leaf.NotAfter))
+		}
+	}
+
+	return c.cert
+}
+
+// reload loads the files and replaces the certificate if their content has changed. The mutex must be held, unless
+// the certificate is being created.
+func (c *Certificate) reload() (bool, error) {
+	certPEM, err := ioutil.ReadFile(c.certPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	keyPEM, err := ioutil.ReadFile(c.keyPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read key: %w", err)
+	}
+	if bytes.Equal(certPEM, c.certPEM) && bytes.Equal(keyPEM, c.keyPEM) {
+		return false, nil
+	}
+
+	decodedKeyPEM, err := DecodePrivateKey(keyPEM, c.passphrase)
+	if err != nil {
+		return false, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, decodedKeyPEM)
+	if err != nil {
+		return false, fmt.Errorf("failed to load certificate and key pair: %w", err)
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	c.cert = &cert
+	c.certPEM = certPEM
+	c.keyPEM = keyPEM
+	return true, nil
+}
+
+// DecodePrivateKey returns the private key in 'keyBytes', in a PEM-encoded format.
+// If the private key is encrypted, 'passphrase' is used to decrypted the private key.
+func DecodePrivateKey(keyBytes []byte, passphrase string) ([]byte, error) {
+	// this section makes some small changes to code from notary/tuf/utils/x509.go
+	pemBlock, _ := pem.Decode(keyBytes)
+	if pemBlock == nil {
+		return nil, fmt.Errorf("no valid private key found")
+	}
+
+	var err error
+	if x509.IsEncryptedPEMBlock(pemBlock) {
+		keyBytes, err = x509.DecryptPEMBlock(pemBlock, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("private key is encrypted, but could not decrypt it: '%s'", err)
+		}
+		keyBytes = pem.EncodeToMemory(&pem.Block{Type: pemBlock.Type, Bytes: keyBytes})
+	}
+
+	return keyBytes, nil
+}
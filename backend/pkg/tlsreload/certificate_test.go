@@ -0,0 +1,76 @@
+package tlsreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// writeCertificate writes a self-signed certificate with the given common name and its key
+func writeCertificate(t *testing.T, certPath string, keyPath string, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kowl-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+
+	_, err = NewCertificate(certPath, keyPath, "", time.Minute, zap.NewNop())
+	assert.Error(t, err)
+
+	writeCertificate(t, certPath, keyPath, "first")
+	cert, err := NewCertificate(certPath, keyPath, "", time.Minute, zap.NewNop())
+	require.NoError(t, err)
+	current, err := cert.GetClientCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "first", current.Leaf.Subject.CommonName)
+
+	// Changed files are only loaded once the interval has elapsed
+	writeCertificate(t, certPath, keyPath, "second")
+	current, _ = cert.GetCertificate(nil)
+	assert.Equal(t, "first", current.Leaf.Subject.CommonName)
+	cert.lastChecked = time.Now().Add(-time.Minute)
+	current, _ = cert.GetCertificate(nil)
+	assert.Equal(t, "second", current.Leaf.Subject.CommonName)
+
+	// The previous certificate is kept if the changed files can't be loaded, e.g. while they are being written
+	require.NoError(t, ioutil.WriteFile(keyPath, []byte("partial"), 0600))
+	cert.lastChecked = time.Now().Add(-time.Minute)
+	current, _ = cert.GetCertificate(nil)
+	assert.Equal(t, "second", current.Leaf.Subject.CommonName)
+
+	writeCertificate(t, certPath, keyPath, "third")
+	cert.lastChecked = time.Now().Add(-time.Minute)
+	current, _ = cert.GetCertificate(nil)
+	assert.Equal(t, "third", current.Leaf.Subject.CommonName)
+}
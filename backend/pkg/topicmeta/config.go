@@ -0,0 +1,80 @@
+package topicmeta
+
+import (
+	"fmt"
+	"time"
+)
+
+// Storage types
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+	StorageKafka  = "kafka"
+)
+
+// Config for the topic metadata, which assigns owners, tags and descriptions to topics so that they can be found and
+// filtered in large clusters
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Storage is either 'memory' (metadata is lost on restart), 'file' or 'kafka'
+	Storage string `yaml:"storage"`
+
+	// FilePath is the JSON file the metadata is persisted in, if the storage is 'file'
+	FilePath string `yaml:"filePath"`
+
+	// Topic of the default cluster in which the metadata is stored as JSON records keyed by topic, if the storage is
+	// 'kafka'. It must exist and should be compacted. All instances which share the topic see each other's changes.
+	Topic string `yaml:"topic"`
+
+	// RetryInterval is the time to wait before the metadata topic is read again after an error
+	RetryInterval time.Duration `yaml:"retryInterval"`
+
+	// MaxTags limits how many tags can be assigned to a single topic
+	MaxTags int `yaml:"maxTags"`
+
+	// MaxDescriptionLength limits the number of characters of a topic's description
+	MaxDescriptionLength int `yaml:"maxDescriptionLength"`
+}
+
+// SetDefaults for the topic metadata config
+func (c *Config) SetDefaults() {
+	c.Storage = StorageMemory
+	c.Topic = "__kowl_topic_metadata"
+	c.RetryInterval = 30 * time.Second
+	c.MaxTags = 20
+	c.MaxDescriptionLength = 2000
+}
+
+// Validate the topic metadata config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Storage {
+	case StorageMemory:
+	case StorageFile:
+		if c.FilePath == "" {
+			return fmt.Errorf("file path must be set if the storage is '%v'", StorageFile)
+		}
+	case StorageKafka:
+		if c.Topic == "" {
+			return fmt.Errorf("topic must be set if the storage is '%v'", StorageKafka)
+		}
+		if c.RetryInterval < time.Second {
+			return fmt.Errorf("retry interval must be at least 1s")
+		}
+	default:
+		return fmt.Errorf("storage must be either '%v', '%v' or '%v'", StorageMemory, StorageFile, StorageKafka)
+	}
+
+	if c.MaxTags <= 0 {
+		return fmt.Errorf("max tags must be greater than 0")
+	}
+	if c.MaxDescriptionLength <= 0 {
+		return fmt.Errorf("max description length must be greater than 0")
+	}
+
+	return nil
+}
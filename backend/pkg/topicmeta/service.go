@@ -0,0 +1,188 @@
+package topicmeta
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidMetadata is returned if metadata which shall be stored does not pass the validation
+var ErrInvalidMetadata = errors.New("invalid topic metadata")
+
+// tagPattern restricts tags, so that they can be used in query parameters without escaping. Tags are lower case.
+var tagPattern = regexp.MustCompile(`^[a-z0-9._:-]{1,64}$`)
+
+// maxOwnerLength limits owners, which are team names, user names or email addresses
+const maxOwnerLength = 128
+
+// Service validates topic metadata and stores it in the configured store
+type Service struct {
+	cfg        Config
+	store      Store
+	kafkaStore *kafkaStore
+}
+
+// NewService creates the store which is configured in the config. The cluster is only used by the kafka storage.
+// The config is expected to be validated.
+func NewService(cfg Config, cluster kafka.Cluster, logger *zap.Logger) (*Service, error) {
+	switch cfg.Storage {
+	case StorageFile:
+		fileStore, err := newFileStore(cfg.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		return NewServiceWithStore(cfg, fileStore), nil
+	case StorageKafka:
+		kafkaStore := newKafkaStore(cfg, cluster, logger.With(zap.String("source", "topic_metadata")))
+		svc := NewServiceWithStore(cfg, kafkaStore)
+		svc.kafkaStore = kafkaStore
+		return svc, nil
+	default:
+		return NewServiceWithStore(cfg, newMemoryStore()), nil
+	}
+}
+
+// NewServiceWithStore creates a service which persists the metadata in a custom store
+func NewServiceWithStore(cfg Config, store Store) *Service {
+	return &Service{cfg: cfg, store: store}
+}
+
+// Start reads the metadata topic if the storage is 'kafka'. It's a no-op if the service is nil, which is the case if
+// topic metadata is disabled.
+func (s *Service) Start() {
+	if s == nil || s.kafkaStore == nil {
+		return
+	}
+	s.kafkaStore.Start()
+}
+
+// IsLoading returns true while the metadata which has been stored before the start is still being read, lists may
+// be incomplete until then
+func (s *Service) IsLoading() bool {
+	return s.kafkaStore != nil && s.kafkaStore.IsLoading()
+}
+
+// Filter selects topics by their metadata. Empty fields match all topics.
+type Filter struct {
+	// Tags must all be assigned to a topic
+	Tags []string
+
+	// Owner must match the topic's owner, case-insensitive
+	Owner string
+}
+
+// IsEmpty returns true if the filter matches all topics, including topics without metadata
+func (f Filter) IsEmpty() bool {
+	return len(f.Tags) == 0 && f.Owner == ""
+}
+
+// Matches returns true if the metadata matches the filter. Nil metadata only matches the empty filter.
+func (f Filter) Matches(m *TopicMetadata) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	if m == nil {
+		return false
+	}
+	if f.Owner != "" && !strings.EqualFold(f.Owner, m.Owner) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !containsString(m.Tags, strings.ToLower(tag)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListMetadata returns the metadata of all topics of the cluster which match the filter
+func (s *Service) ListMetadata(clusterName string, filter Filter) ([]*TopicMetadata, error) {
+	all, err := s.store.List(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*TopicMetadata, 0, len(all))
+	for _, m := range all {
+		if filter.Matches(m) {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+// MetadataByTopic returns the metadata of all topics of the cluster by topic name
+func (s *Service) MetadataByTopic(clusterName string) (map[string]*TopicMetadata, error) {
+	all, err := s.store.List(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*TopicMetadata, len(all))
+	for _, m := range all {
+		res[m.TopicName] = m
+	}
+	return res, nil
+}
+
+// GetMetadata returns the metadata of the topic or ErrMetadataNotFound
+func (s *Service) GetMetadata(clusterName string, topicName string) (*TopicMetadata, error) {
+	return s.store.Get(clusterName, topicName)
+}
+
+// PutMetadata validates and stores the metadata of a topic, replacing its previous metadata. Tags are normalized to
+// lower case, deduplicated and sorted. UpdatedAt is set by the service.
+func (s *Service) PutMetadata(metadata TopicMetadata) (*TopicMetadata, error) {
+	metadata.Owner = strings.TrimSpace(metadata.Owner)
+	if utf8.RuneCountInString(metadata.Owner) > maxOwnerLength {
+		return nil, fmt.Errorf("%w: owner must not be longer than %v characters", ErrInvalidMetadata, maxOwnerLength)
+	}
+	metadata.Description = strings.TrimSpace(metadata.Description)
+	if utf8.RuneCountInString(metadata.Description) > s.cfg.MaxDescriptionLength {
+		return nil, fmt.Errorf("%w: description must not be longer than %v characters", ErrInvalidMetadata, s.cfg.MaxDescriptionLength)
+	}
+
+	tags := make([]string, 0, len(metadata.Tags))
+	for _, tag := range metadata.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("%w: tag '%v' must consist of 1 to 64 lower case alphanumeric characters, '.', '_', ':' or '-'", ErrInvalidMetadata, tag)
+		}
+		if !containsString(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > s.cfg.MaxTags {
+		return nil, fmt.Errorf("%w: only %v tags can be assigned to a topic", ErrInvalidMetadata, s.cfg.MaxTags)
+	}
+	sort.Strings(tags)
+	metadata.Tags = tags
+
+	metadata.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	if err := s.store.Put(&metadata); err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+// DeleteMetadata removes all metadata of the topic
+func (s *Service) DeleteMetadata(clusterName string, topicName string) error {
+	return s.store.Delete(clusterName, topicName)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package topicmeta
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestServicePutNormalizesAndValidates(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.MaxTags = 2
+	cfg.MaxDescriptionLength = 10
+	svc, err := NewService(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	stored, err := svc.PutMetadata(TopicMetadata{ClusterName: "default", TopicName: "orders", Owner: " team-a ", Tags: []string{"PCI", "core", "pci"}})
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", stored.Owner)
+	assert.Equal(t, []string{"core", "pci"}, stored.Tags)
+	assert.False(t, stored.UpdatedAt.IsZero())
+
+	invalid := []TopicMetadata{
+		{ClusterName: "default", TopicName: "orders", Tags: []string{"a", "b", "c"}},
+		{ClusterName: "default", TopicName: "orders", Tags: []string{"has space"}},
+		{ClusterName: "default", TopicName: "orders", Tags: []string{""}},
+		{ClusterName: "default", TopicName: "orders", Description: "longer than ten"},
+		{ClusterName: "default", TopicName: "orders", Owner: strings.Repeat("a", maxOwnerLength+1)},
+	}
+	for i, m := range invalid {
+		_, err := svc.PutMetadata(m)
+		assert.True(t, errors.Is(err, ErrInvalidMetadata), "Case: ", i)
+	}
+
+	got, err := svc.GetMetadata("default", "orders")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"core", "pci"}, got.Tags, "invalid metadata must not be stored")
+
+	require.NoError(t, svc.DeleteMetadata("default", "orders"))
+	assert.True(t, errors.Is(svc.DeleteMetadata("default", "orders"), ErrMetadataNotFound))
+	_, err = svc.GetMetadata("default", "orders")
+	assert.True(t, errors.Is(err, ErrMetadataNotFound))
+}
+
+func TestServiceListMetadataFilter(t *testing.T) {
+	cfg := Config{}
+	cfg.SetDefaults()
+	svc := NewServiceWithStore(cfg, newMemoryStore())
+	for _, m := range []TopicMetadata{
+		{ClusterName: "default", TopicName: "payments", Owner: "Team-A", Tags: []string{"pci", "core"}},
+		{ClusterName: "default", TopicName: "orders", Owner: "team-a", Tags: []string{"core"}},
+		{ClusterName: "default", TopicName: "logs", Owner: "team-b"},
+		{ClusterName: "other", TopicName: "orders", Owner: "team-a", Tags: []string{"core"}},
+	} {
+		_, err := svc.PutMetadata(m)
+		require.NoError(t, err)
+	}
+
+	tt := []struct {
+		filter Filter
+		topics []string
+	}{
+		{Filter{}, []string{"logs", "orders", "payments"}},
+		{Filter{Tags: []string{"core"}}, []string{"orders", "payments"}},
+		{Filter{Tags: []string{"CORE", "pci"}}, []string{"payments"}},
+		{Filter{Owner: "team-a"}, []string{"orders", "payments"}},
+		{Filter{Owner: "team-b", Tags: []string{"core"}}, []string{}},
+	}
+	for i, test := range tt {
+		metadata, err := svc.ListMetadata("default", test.filter)
+		require.NoError(t, err, "Case: ", i)
+		topics := make([]string, len(metadata))
+		for j, m := range metadata {
+			topics[j] = m.TopicName
+		}
+		assert.Equal(t, test.topics, topics, "Case: ", i)
+	}
+
+	assert.True(t, Filter{}.Matches(nil))
+	assert.False(t, Filter{Owner: "team-a"}.Matches(nil))
+}
+
+func TestFileStorePersistsMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topic-metadata")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metadata.json")
+
+	store, err := newFileStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(&TopicMetadata{ClusterName: "default", TopicName: "orders", Owner: "team-a"}))
+	require.NoError(t, store.Put(&TopicMetadata{ClusterName: "default", TopicName: "logs", Owner: "team-b"}))
+	require.NoError(t, store.Put(&TopicMetadata{ClusterName: "default", TopicName: "orders", Owner: "team-c"}))
+	require.NoError(t, store.Delete("default", "logs"))
+
+	reloaded, err := newFileStore(path)
+	require.NoError(t, err)
+	metadata, err := reloaded.List("default")
+	require.NoError(t, err)
+	require.Len(t, metadata, 1)
+	assert.Equal(t, "team-c", metadata[0].Owner)
+
+	// A failed write must not change the in-memory state
+	require.NoError(t, os.RemoveAll(dir))
+	assert.Error(t, reloaded.Put(&TopicMetadata{ClusterName: "default", TopicName: "logs", Owner: "team-b"}))
+	_, err = reloaded.Get("default", "logs")
+	assert.True(t, errors.Is(err, ErrMetadataNotFound))
+}
+
+func TestKafkaStoreSharesMetadata(t *testing.T) {
+	fakeCfg := kafka.FakeConfig{}
+	fakeCfg.SetDefaults()
+	fakeCfg.Topics = 0
+	cluster := kafka.NewFakeCluster(fakeCfg, zap.NewNop())
+
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Storage = StorageKafka
+	cfg.RetryInterval = 10 * time.Millisecond
+	require.NoError(t, cluster.CreateTopic(cfg.Topic, 3, 1, nil, false))
+
+	writer, err := NewService(cfg, cluster, zap.NewNop())
+	require.NoError(t, err)
+	_, err = writer.PutMetadata(TopicMetadata{ClusterName: "default", TopicName: "orders", Owner: "team-a", Tags: []string{"core"}})
+	require.NoError(t, err)
+	_, err = writer.PutMetadata(TopicMetadata{ClusterName: "default", TopicName: "logs", Owner: "team-b"})
+	require.NoError(t, err)
+	require.NoError(t, writer.DeleteMetadata("default", "logs"))
+
+	// A second instance reads all changes from the topic
+	reader, err := NewService(cfg, cluster, zap.NewNop())
+	require.NoError(t, err)
+	reader.Start()
+	require.Eventually(t, func() bool {
+		metadata, err := reader.ListMetadata("default", Filter{})
+		return err == nil && len(metadata) == 1 && metadata[0].TopicName == "orders" && !reader.IsLoading()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	metadata, err := reader.GetMetadata("default", "orders")
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", metadata.Owner)
+	assert.Equal(t, []string{"core"}, metadata.Tags)
+}
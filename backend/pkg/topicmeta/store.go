@@ -0,0 +1,233 @@
+package topicmeta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrMetadataNotFound is returned if no metadata has been assigned to the topic
+var ErrMetadataNotFound = errors.New("topic metadata not found")
+
+// TopicMetadata is the owner, tags and description which have been assigned to a topic
+type TopicMetadata struct {
+	ClusterName string    `json:"clusterName"`
+	TopicName   string    `json:"topicName"`
+	Owner       string    `json:"owner"`
+	Tags        []string  `json:"tags"`
+	Description string    `json:"description"`
+	UpdatedBy   string    `json:"updatedBy"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Store persists topic metadata. Implementations must be safe for concurrent use. Custom stores (e. g. backed by a
+// database) can be passed to NewServiceWithStore.
+type Store interface {
+	// List returns the metadata of all topics of the cluster sorted by topic name
+	List(clusterName string) ([]*TopicMetadata, error)
+	// Get returns the metadata of the topic or ErrMetadataNotFound
+	Get(clusterName string, topicName string) (*TopicMetadata, error)
+	// Put creates or replaces the metadata of the topic
+	Put(metadata *TopicMetadata) error
+	// Delete removes the metadata of the topic or returns ErrMetadataNotFound
+	Delete(clusterName string, topicName string) error
+}
+
+// topicKey identifies a topic across all clusters
+type topicKey struct {
+	ClusterName string `json:"clusterName"`
+	TopicName   string `json:"topicName"`
+}
+
+// memoryStore keeps all metadata in memory, it's lost on restart
+type memoryStore struct {
+	mutex    sync.RWMutex
+	metadata map[topicKey]*TopicMetadata
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{metadata: make(map[topicKey]*TopicMetadata)}
+}
+
+func (s *memoryStore) List(clusterName string) ([]*TopicMetadata, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	res := make([]*TopicMetadata, 0)
+	for key, m := range s.metadata {
+		if key.ClusterName != clusterName {
+			continue
+		}
+		res = append(res, copyMetadata(m))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].TopicName < res[j].TopicName })
+
+	return res, nil
+}
+
+func (s *memoryStore) Get(clusterName string, topicName string) (*TopicMetadata, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	m, exists := s.metadata[topicKey{ClusterName: clusterName, TopicName: topicName}]
+	if !exists {
+		return nil, ErrMetadataNotFound
+	}
+	return copyMetadata(m), nil
+}
+
+func (s *memoryStore) Put(metadata *TopicMetadata) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.put(metadata)
+	return nil
+}
+
+// put replaces the metadata and returns the previous metadata, nil if there was none. The mutex must be held.
+func (s *memoryStore) put(metadata *TopicMetadata) *TopicMetadata {
+	key := topicKey{ClusterName: metadata.ClusterName, TopicName: metadata.TopicName}
+	previous := s.metadata[key]
+	s.metadata[key] = copyMetadata(metadata)
+	return previous
+}
+
+func (s *memoryStore) Delete(clusterName string, topicName string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err := s.delete(topicKey{ClusterName: clusterName, TopicName: topicName})
+	return err
+}
+
+// delete removes the metadata and returns it, the mutex must be held
+func (s *memoryStore) delete(key topicKey) (*TopicMetadata, error) {
+	deleted, exists := s.metadata[key]
+	if !exists {
+		return nil, ErrMetadataNotFound
+	}
+	delete(s.metadata, key)
+
+	return deleted, nil
+}
+
+// all returns the metadata of all topics, the mutex must be held
+func (s *memoryStore) all() []*TopicMetadata {
+	res := make([]*TopicMetadata, 0, len(s.metadata))
+	for _, m := range s.metadata {
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].ClusterName != res[j].ClusterName {
+			return res[i].ClusterName < res[j].ClusterName
+		}
+		return res[i].TopicName < res[j].TopicName
+	})
+
+	return res
+}
+
+func copyMetadata(m *TopicMetadata) *TopicMetadata {
+	copied := *m
+	copied.Tags = append([]string(nil), m.Tags...)
+	return &copied
+}
+
+// fileStore keeps all metadata in memory and rewrites the whole JSON file on every change. That's good enough for
+// thousands of topics and keeps the file human readable.
+type fileStore struct {
+	*memoryStore
+	path string
+}
+
+// newFileStore loads the metadata from the given file. A missing file is created on the first change.
+func newFileStore(path string) (*fileStore, error) {
+	s := &fileStore{memoryStore: newMemoryStore(), path: path}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topic metadata file: %w", err)
+	}
+
+	var metadata []*TopicMetadata
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse topic metadata file: %w", err)
+	}
+	for _, m := range metadata {
+		if previous := s.memoryStore.put(m); previous != nil {
+			return nil, fmt.Errorf("topic metadata file contains topic '%v' in cluster '%v' more than once", m.TopicName, m.ClusterName)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *fileStore) Put(metadata *TopicMetadata) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous := s.memoryStore.put(metadata)
+	if err := s.persist(); err != nil {
+		if previous != nil {
+			s.memoryStore.put(previous)
+		} else {
+			_, _ = s.memoryStore.delete(topicKey{ClusterName: metadata.ClusterName, TopicName: metadata.TopicName})
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *fileStore) Delete(clusterName string, topicName string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deleted, err := s.memoryStore.delete(topicKey{ClusterName: clusterName, TopicName: topicName})
+	if err != nil {
+		return err
+	}
+	if err := s.persist(); err != nil {
+		s.memoryStore.put(deleted)
+		return err
+	}
+
+	return nil
+}
+
+// persist writes all metadata into a temporary file which then replaces the actual file, so that the file is never
+// left half written. The mutex must be held.
+func (s *fileStore) persist() error {
+	content, err := json.MarshalIndent(s.all(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode topic metadata: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary topic metadata file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write topic metadata file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write topic metadata file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace topic metadata file: %w", err)
+	}
+
+	return nil
+}
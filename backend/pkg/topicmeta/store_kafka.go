@@ -0,0 +1,117 @@
+package topicmeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudhut/kowl/backend/pkg/kafka"
+	"go.uber.org/zap"
+)
+
+// kafkaStore keeps all metadata in memory and stores each change as a JSON record in a compacted topic, keyed by the
+// cluster and topic name. Deletions are stored as tombstones. All instances tail the topic, so that they apply the
+// changes of each other. Changes are applied locally right away, so that requesters see their own changes.
+type kafkaStore struct {
+	*memoryStore
+	cluster kafka.Cluster
+	topic   string
+	tailer  *kafka.TopicTailer
+	logger  *zap.Logger
+}
+
+func newKafkaStore(cfg Config, cluster kafka.Cluster, logger *zap.Logger) *kafkaStore {
+	s := &kafkaStore{
+		memoryStore: newMemoryStore(),
+		cluster:     cluster,
+		topic:       cfg.Topic,
+		logger:      logger,
+	}
+	// All records of the topic must be read, compaction keeps the latest record of each topic
+	retention := time.Since(time.Unix(0, 0))
+	s.tailer = kafka.NewTopicTailer(cluster, cfg.Topic, retention, cfg.RetryInterval, s.apply, logger)
+
+	return s
+}
+
+// Start reads all metadata from the topic and keeps following changes until the process exits
+func (s *kafkaStore) Start() {
+	s.tailer.Start()
+}
+
+// IsLoading returns true until all metadata which has been stored before the start has been read
+func (s *kafkaStore) IsLoading() bool {
+	return s.tailer.IsBackfilling()
+}
+
+func (s *kafkaStore) Put(metadata *TopicMetadata) error {
+	value, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode topic metadata: %w", err)
+	}
+	if err := s.produce(topicKey{ClusterName: metadata.ClusterName, TopicName: metadata.TopicName}, value); err != nil {
+		return err
+	}
+
+	return s.memoryStore.Put(metadata)
+}
+
+func (s *kafkaStore) Delete(clusterName string, topicName string) error {
+	key := topicKey{ClusterName: clusterName, TopicName: topicName}
+	if _, err := s.memoryStore.Get(clusterName, topicName); err != nil {
+		return err
+	}
+	if err := s.produce(key, nil); err != nil {
+		return err
+	}
+
+	// The metadata may have been deleted concurrently, which is fine
+	_ = s.memoryStore.Delete(clusterName, topicName)
+	return nil
+}
+
+func (s *kafkaStore) produce(key topicKey, value []byte) error {
+	encodedKey, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode topic metadata key: %w", err)
+	}
+
+	_, err = s.cluster.Produce(kafka.ProduceRecord{
+		TopicName:   s.topic,
+		Partitioner: kafka.PartitionerHash,
+		Key:         encodedKey,
+		Value:       value,
+	}, kafka.ProduceOptions{Acks: "all"})
+	if err != nil {
+		return fmt.Errorf("failed to store topic metadata: %w", err)
+	}
+
+	return nil
+}
+
+// apply applies a record of the metadata topic to the in-memory metadata. Records of the same topic are always in
+// the same partition, hence they are applied in the order they have been produced.
+func (s *kafkaStore) apply(msg *sarama.ConsumerMessage) {
+	var key topicKey
+	if err := json.Unmarshal(msg.Key, &key); err != nil || key.TopicName == "" {
+		s.logger.Warn("skipping topic metadata record with invalid key", zap.Int32("partition", msg.Partition), zap.Int64("offset", msg.Offset))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if msg.Value == nil {
+		_, _ = s.memoryStore.delete(key)
+		return
+	}
+
+	var metadata TopicMetadata
+	if err := json.Unmarshal(msg.Value, &metadata); err != nil {
+		s.logger.Warn("skipping topic metadata record with invalid value", zap.Int32("partition", msg.Partition), zap.Int64("offset", msg.Offset), zap.Error(err))
+		return
+	}
+	metadata.ClusterName = key.ClusterName
+	metadata.TopicName = key.TopicName
+	s.memoryStore.put(&metadata)
+}
@@ -0,0 +1,11 @@
+package topicpattern
+
+import "regexp"
+
+// Compile anchors the pattern so that it must match the whole topic name. An empty pattern matches all topics.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = ".*"
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
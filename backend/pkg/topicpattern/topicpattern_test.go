@@ -0,0 +1,33 @@
+package topicpattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tt := []struct {
+		pattern string
+		topic   string
+		matches bool
+	}{
+		{"", "orders", true},
+		{"orders", "orders", true},
+		{"orders", "orders-dlq", false},
+		{"orders", "old-orders", false},
+		{"orders|payments", "payments", true},
+		{"orders|payments", "orders-payments", false},
+		{"orders\\..*", "orders.dlq", true},
+	}
+
+	for i, test := range tt {
+		regex, err := Compile(test.pattern)
+		require.NoError(t, err, "Case: ", i)
+		assert.Equal(t, test.matches, regex.MatchString(test.topic), "Case: ", i)
+	}
+
+	_, err := Compile("orders(")
+	assert.Error(t, err)
+}
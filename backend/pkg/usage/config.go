@@ -0,0 +1,55 @@
+package usage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Requester tracking modes, which decide how much is known about the users of a topic
+const (
+	// RequestersPlain records the requesters' names, e.g. the user names or IP addresses
+	RequestersPlain = "plain"
+	// RequestersPseudonymized records a hash of each requester which is stable until the backend restarts
+	RequestersPseudonymized = "pseudonymized"
+	// RequestersNone records only how often topics are used, not by whom
+	RequestersNone = "none"
+)
+
+// Config for the usage analytics, which count how often topics are browsed, searched and exported and by whom
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Requesters is either 'plain', 'pseudonymized' or 'none'
+	Requesters string `yaml:"requesters"`
+
+	// Retention is the time after which usage is forgotten. Usage is counted per day and kept in memory, hence it's
+	// lost on restart.
+	Retention time.Duration `yaml:"retention"`
+
+	// ExcludedRequesters are not tracked, e.g. service accounts which poll topics automatically
+	ExcludedRequesters []string `yaml:"excludedRequesters"`
+}
+
+// SetDefaults for the usage config
+func (c *Config) SetDefaults() {
+	c.Requesters = RequestersPseudonymized
+	c.Retention = 30 * 24 * time.Hour
+}
+
+// Validate the usage config
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Requesters {
+	case RequestersPlain, RequestersPseudonymized, RequestersNone:
+	default:
+		return fmt.Errorf("requesters must be one of: %v, %v, %v", RequestersPlain, RequestersPseudonymized, RequestersNone)
+	}
+	if c.Retention < 24*time.Hour {
+		return fmt.Errorf("retention must be at least 24h")
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package usage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Actions which are counted per topic and requester
+const (
+	ActionBrowse = "browse"
+	ActionSearch = "search"
+	ActionExport = "export"
+)
+
+// Counts are the number of times a topic has been browsed, searched and exported
+type Counts struct {
+	Browses  int64 `json:"browses"`
+	Searches int64 `json:"searches"`
+	Exports  int64 `json:"exports"`
+	Total    int64 `json:"total"`
+}
+
+func (c *Counts) add(action string, count int64) {
+	switch action {
+	case ActionBrowse:
+		c.Browses += count
+	case ActionSearch:
+		c.Searches += count
+	case ActionExport:
+		c.Exports += count
+	}
+	c.Total += count
+}
+
+// TopicUsage is the usage of a single topic. Requesters is the number of distinct requesters, it's 0 if requesters
+// are not tracked.
+type TopicUsage struct {
+	ClusterName string `json:"clusterName"`
+	TopicName   string `json:"topicName"`
+	Requesters  int    `json:"requesters"`
+	Counts
+}
+
+// RequesterUsage is the usage of a single requester across all topics
+type RequesterUsage struct {
+	Requester string `json:"requester"`
+	Topics    int    `json:"topics"`
+	Counts
+}
+
+// Report aggregates the usage of all days in the reported period. Topics and requesters are sorted by their total
+// usage, the most used first.
+type Report struct {
+	Since             time.Time        `json:"since"`
+	RequesterTracking string           `json:"requesterTracking"`
+	Topics            []TopicUsage     `json:"topics"`
+	Requesters        []RequesterUsage `json:"requesters"` // Empty if requesters are not tracked
+}
+
+// counterKey identifies a single counter of a day. The requester is empty if requesters are not tracked.
+type counterKey struct {
+	ClusterName string
+	TopicName   string
+	Requester   string
+	Action      string
+}
+
+// topicKey identifies a topic across all clusters
+type topicKey struct {
+	ClusterName string
+	TopicName   string
+}
+
+// Tracker counts the usage of topics per day in memory
+type Tracker struct {
+	cfg      Config
+	salt     []byte
+	excluded map[string]struct{}
+
+	mutex sync.Mutex
+	days  map[time.Time]map[counterKey]int64 // By start of the day in UTC
+	now   func() time.Time
+}
+
+// NewTracker creates the usage tracker. The config is expected to be validated.
+func NewTracker(cfg Config) (*Tracker, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to create pseudonymization salt: %w", err)
+	}
+
+	excluded := make(map[string]struct{}, len(cfg.ExcludedRequesters))
+	for _, requester := range cfg.ExcludedRequesters {
+		excluded[requester] = struct{}{}
+	}
+
+	return &Tracker{
+		cfg:      cfg,
+		salt:     salt,
+		excluded: excluded,
+		days:     make(map[time.Time]map[counterKey]int64),
+		now:      time.Now,
+	}, nil
+}
+
+// Record counts a single action of the requester on the topic. It's a no-op if the tracker is nil, which is the case
+// if usage analytics are disabled.
+func (t *Tracker) Record(clusterName string, topicName string, requester string, action string) {
+	if t == nil {
+		return
+	}
+	if _, isExcluded := t.excluded[requester]; isExcluded {
+		return
+	}
+
+	key := counterKey{
+		ClusterName: clusterName,
+		TopicName:   topicName,
+		Requester:   t.requesterName(requester),
+		Action:      action,
+	}
+	day := t.now().UTC().Truncate(24 * time.Hour)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	counters, ok := t.days[day]
+	if !ok {
+		t.removeExpiredDays(day)
+		counters = make(map[counterKey]int64)
+		t.days[day] = counters
+	}
+	counters[key]++
+}
+
+// Report aggregates the usage of the given number of days, including today. Days beyond the retention are not
+// available. Limit caps the number of reported topics and requesters, 0 reports all.
+func (t *Tracker) Report(days int, limit int) *Report {
+	today := t.now().UTC().Truncate(24 * time.Hour)
+	since := today.Add(-time.Duration(days-1) * 24 * time.Hour)
+
+	topics := make(map[topicKey]*TopicUsage)
+	topicRequesters := make(map[topicKey]map[string]struct{})
+	requesters := make(map[string]*RequesterUsage)
+	requesterTopics := make(map[string]map[topicKey]struct{})
+
+	t.mutex.Lock()
+	for day, counters := range t.days {
+		if day.Before(since) {
+			continue
+		}
+		for key, count := range counters {
+			tk := topicKey{ClusterName: key.ClusterName, TopicName: key.TopicName}
+			topic, ok := topics[tk]
+			if !ok {
+				topic = &TopicUsage{ClusterName: key.ClusterName, TopicName: key.TopicName}
+				topics[tk] = topic
+				topicRequesters[tk] = make(map[string]struct{})
+			}
+			topic.add(key.Action, count)
+
+			if key.Requester == "" {
+				continue
+			}
+			topicRequesters[tk][key.Requester] = struct{}{}
+			requester, ok := requesters[key.Requester]
+			if !ok {
+				requester = &RequesterUsage{Requester: key.Requester}
+				requesters[key.Requester] = requester
+				requesterTopics[key.Requester] = make(map[topicKey]struct{})
+			}
+			requester.add(key.Action, count)
+			requesterTopics[key.Requester][tk] = struct{}{}
+		}
+	}
+	t.mutex.Unlock()
+
+	report := &Report{
+		Since:             since,
+		RequesterTracking: t.cfg.Requesters,
+		Topics:            make([]TopicUsage, 0, len(topics)),
+		Requesters:        make([]RequesterUsage, 0, len(requesters)),
+	}
+	for tk, topic := range topics {
+		topic.Requesters = len(topicRequesters[tk])
+		report.Topics = append(report.Topics, *topic)
+	}
+	for name, requester := range requesters {
+		requester.Topics = len(requesterTopics[name])
+		report.Requesters = append(report.Requesters, *requester)
+	}
+	sort.Slice(report.Topics, func(i, j int) bool {
+		a, b := report.Topics[i], report.Topics[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		if a.ClusterName != b.ClusterName {
+			return a.ClusterName < b.ClusterName
+		}
+		return a.TopicName < b.TopicName
+	})
+	sort.Slice(report.Requesters, func(i, j int) bool {
+		a, b := report.Requesters[i], report.Requesters[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Requester < b.Requester
+	})
+	if limit > 0 && len(report.Topics) > limit {
+		report.Topics = report.Topics[:limit]
+	}
+	if limit > 0 && len(report.Requesters) > limit {
+		report.Requesters = report.Requesters[:limit]
+	}
+
+	return report
+}
+
+// MaxReportDays is the number of days which are covered by the configured retention
+func (t *Tracker) MaxReportDays() int {
+	return int(t.cfg.Retention / (24 * time.Hour))
+}
+
+// requesterName returns the name under which the requester is recorded according to the configured tracking mode
+func (t *Tracker) requesterName(requester string) string {
+	switch t.cfg.Requesters {
+	case RequestersPlain:
+		return requester
+	case RequestersPseudonymized:
+		h := sha256.New()
+		h.Write(t.salt)
+		h.Write([]byte(requester))
+		return hex.EncodeToString(h.Sum(nil)[:8])
+	}
+	return ""
+}
+
+// removeExpiredDays drops all days which are beyond the retention. Callers must hold the mutex.
+func (t *Tracker) removeExpiredDays(today time.Time) {
+	oldest := today.Add(-time.Duration(t.MaxReportDays()-1) * 24 * time.Hour)
+	for day := range t.days {
+		if day.Before(oldest) {
+			delete(t.days, day)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTracker(t *testing.T, requesters string) *Tracker {
+	cfg := Config{}
+	cfg.SetDefaults()
+	cfg.Enabled = true
+	cfg.Requesters = requesters
+	cfg.Retention = 7 * 24 * time.Hour
+	cfg.ExcludedRequesters = []string{"service-account"}
+	tracker, err := NewTracker(cfg)
+	require.NoError(t, err)
+	return tracker
+}
+
+func TestTrackerReport(t *testing.T) {
+	tracker := newTestTracker(t, RequestersPlain)
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record("default", "orders", "alice", ActionBrowse)
+	tracker.Record("default", "orders", "alice", ActionSearch)
+	tracker.Record("default", "orders", "bob", ActionExport)
+	tracker.Record("default", "customers", "alice", ActionBrowse)
+	tracker.Record("default", "orders", "service-account", ActionSearch) // Excluded
+
+	report := tracker.Report(7, 0)
+	require.Len(t, report.Topics, 2)
+	assert.Equal(t, TopicUsage{ClusterName: "default", TopicName: "orders", Requesters: 2, Counts: Counts{Browses: 1, Searches: 1, Exports: 1, Total: 3}}, report.Topics[0])
+	assert.Equal(t, "customers", report.Topics[1].TopicName)
+	require.Len(t, report.Requesters, 2)
+	assert.Equal(t, RequesterUsage{Requester: "alice", Topics: 2, Counts: Counts{Browses: 2, Searches: 1, Total: 3}}, report.Requesters[0])
+
+	// Usage of previous days is only reported if the period covers it
+	now = now.Add(24 * time.Hour)
+	tracker.Record("default", "customers", "bob", ActionSearch)
+	report = tracker.Report(1, 0)
+	require.Len(t, report.Topics, 1)
+	assert.Equal(t, int64(1), report.Topics[0].Searches)
+	assert.Len(t, tracker.Report(2, 1).Topics, 1)
+
+	// Days beyond the retention are dropped once a new day starts
+	now = now.Add(7 * 24 * time.Hour)
+	tracker.Record("default", "orders", "alice", ActionBrowse)
+	assert.Len(t, tracker.days, 1)
+}
+
+func TestTrackerRequesterPrivacy(t *testing.T) {
+	tracker := newTestTracker(t, RequestersPseudonymized)
+	tracker.Record("default", "orders", "alice", ActionBrowse)
+	tracker.Record("default", "orders", "alice", ActionSearch)
+	report := tracker.Report(1, 0)
+	require.Len(t, report.Requesters, 1)
+	assert.NotEqual(t, "alice", report.Requesters[0].Requester)
+	assert.Len(t, report.Requesters[0].Requester, 16)
+	assert.Equal(t, int64(2), report.Requesters[0].Total)
+
+	tracker = newTestTracker(t, RequestersNone)
+	tracker.Record("default", "orders", "alice", ActionBrowse)
+	report = tracker.Report(1, 0)
+	assert.Empty(t, report.Requesters)
+	require.Len(t, report.Topics, 1)
+	assert.Equal(t, 0, report.Topics[0].Requesters)
+	assert.Equal(t, RequestersNone, report.RequesterTracking)
+}